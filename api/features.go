@@ -223,6 +223,14 @@ func featureName(f CoreFeatures) string {
 		return "exception-handling"
 	case CoreFeatureSIMD << 5: // experimental.CoreFeaturesTypedFunctionReferences
 		return "typed-function-references"
+	case CoreFeatureSIMD << 6: // experimental.CoreFeaturesMultiMemory
+		return "multi-memory"
+	case CoreFeatureSIMD << 7: // experimental.CoreFeaturesMemory64
+		return "memory64"
+	case CoreFeatureSIMD << 8: // experimental.CoreFeaturesRelaxedSIMD
+		return "relaxed-simd"
+	case CoreFeatureSIMD << 9: // experimental.CoreFeaturesGC
+		return "gc"
 	}
 	return ""
 }
@@ -107,6 +107,13 @@ const (
 	//
 	// Note: The usage of this type is toggled with api.CoreFeatureBulkMemoryOperations.
 	ValueTypeExternref ValueType = 0x6f
+
+	// ValueTypeFuncref is a funcref type, the element type of a Table of
+	// functions.
+	//
+	// Note: in wazero, funcref type values are opaque and only valid in the
+	// wazero.Runtime they were obtained from. Zero is the null reference.
+	ValueTypeFuncref ValueType = 0x70
 )
 
 // ValueTypeName returns the type name of the given ValueType as a string.
@@ -125,6 +132,8 @@ func ValueTypeName(t ValueType) string {
 		return "f64"
 	case ValueTypeExternref:
 		return "externref"
+	case ValueTypeFuncref:
+		return "funcref"
 	}
 	return "unknown"
 }
@@ -164,7 +173,12 @@ type Module interface {
 	// definitions in this module, keyed on export name.
 	ExportedFunctionDefinitions() map[string]FunctionDefinition
 
-	// TODO: Table
+	// ExportedTable returns a table exported from this module or nil if it wasn't.
+	ExportedTable(name string) Table
+
+	// ExportedTableDefinitions returns all the exported table definitions in
+	// this module, keyed on export name.
+	ExportedTableDefinitions() map[string]TableDefinition
 
 	// ExportedMemory returns a memory exported from this module or nil if it wasn't.
 	//
@@ -289,6 +303,32 @@ type MemoryDefinition interface {
 	internalapi.WazeroOnly
 }
 
+// TableDefinition is a WebAssembly table exported in a module
+// (wazero.CompiledModule). Units are in elements.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#exports%E2%91%A0
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type TableDefinition interface {
+	ExportDefinition
+
+	// Type returns the type of the table elements, which is either
+	// ValueTypeFuncref or ValueTypeExternref.
+	Type() ValueType
+
+	// Min returns the possibly zero initial count of elements.
+	Min() uint32
+
+	// Max returns the possibly zero max count of elements, or false if
+	// unbounded.
+	Max() (uint32, bool)
+
+	internalapi.WazeroOnly
+}
+
 // FunctionDefinition is a WebAssembly function exported in a module
 // (wazero.CompiledModule).
 //
@@ -550,6 +590,45 @@ type MutableGlobal interface {
 	internalapi.WazeroOnly
 }
 
+// Table allows access to a module's table.
+//
+// Elements are encoded the same way as the corresponding parameters and
+// results of Function: an externref is encoded with EncodeExternref, and a
+// funcref is an opaque value which is only valid in the wazero.Runtime it was
+// obtained from, e.g. via Get or a Function result. Zero is the null
+// reference for both.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#table-instances%E2%91%A0
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+//   - Tables are not safe for concurrent use with functions that access them.
+type Table interface {
+	// Definition is metadata about this table from its defining module.
+	Definition() TableDefinition
+
+	// Size returns the current count of elements.
+	Size() uint32
+
+	// Grow increases the table by delta elements, each set to init. The return
+	// val is the previous count of elements, or false if the delta was ignored
+	// as it exceeds TableDefinition.Max.
+	//
+	// Note: This is the same as the "table.grow" instruction defined in the
+	// WebAssembly Core Specification, except returns false instead of -1.
+	Grow(delta uint32, init uint64) (previousSize uint32, ok bool)
+
+	// Get returns the element at the offset or false if out of range.
+	Get(offset uint32) (uint64, bool)
+
+	// Set sets the element at the offset to v or returns false if out of range.
+	Set(offset uint32, v uint64) bool
+
+	internalapi.WazeroOnly
+}
+
 // Memory allows restricted access to a module's memory. Notably, this does not allow growing.
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#storage%E2%91%A0
@@ -680,6 +759,57 @@ type Memory interface {
 	// WriteString writes the string to the underlying buffer at the offset or returns false if out of range.
 	WriteString(offset uint32, v string) bool
 
+	// Size64 is like Size, but doesn't overflow when the memory has 65536 pages or more.
+	//
+	// Note: Memories larger than 4GiB can only be defined with experimental.CoreFeaturesMemory64.
+	Size64() uint64
+
+	// ReadByte64 is like ReadByte, but accepts a 64-bit offset. This is needed to access the whole
+	// space of memories defined with experimental.CoreFeaturesMemory64, which can exceed 4GiB.
+	ReadByte64(offset uint64) (byte, bool)
+
+	// ReadUint16Le64 is like ReadUint16Le, but accepts a 64-bit offset.
+	ReadUint16Le64(offset uint64) (uint16, bool)
+
+	// ReadUint32Le64 is like ReadUint32Le, but accepts a 64-bit offset.
+	ReadUint32Le64(offset uint64) (uint32, bool)
+
+	// ReadFloat32Le64 is like ReadFloat32Le, but accepts a 64-bit offset.
+	ReadFloat32Le64(offset uint64) (float32, bool)
+
+	// ReadUint64Le64 is like ReadUint64Le, but accepts a 64-bit offset.
+	ReadUint64Le64(offset uint64) (uint64, bool)
+
+	// ReadFloat64Le64 is like ReadFloat64Le, but accepts a 64-bit offset.
+	ReadFloat64Le64(offset uint64) (float64, bool)
+
+	// Read64 is like Read, but accepts a 64-bit offset and byteCount.
+	Read64(offset, byteCount uint64) ([]byte, bool)
+
+	// WriteByte64 is like WriteByte, but accepts a 64-bit offset.
+	WriteByte64(offset uint64, v byte) bool
+
+	// WriteUint16Le64 is like WriteUint16Le, but accepts a 64-bit offset.
+	WriteUint16Le64(offset uint64, v uint16) bool
+
+	// WriteUint32Le64 is like WriteUint32Le, but accepts a 64-bit offset.
+	WriteUint32Le64(offset uint64, v uint32) bool
+
+	// WriteFloat32Le64 is like WriteFloat32Le, but accepts a 64-bit offset.
+	WriteFloat32Le64(offset uint64, v float32) bool
+
+	// WriteUint64Le64 is like WriteUint64Le, but accepts a 64-bit offset.
+	WriteUint64Le64(offset uint64, v uint64) bool
+
+	// WriteFloat64Le64 is like WriteFloat64Le, but accepts a 64-bit offset.
+	WriteFloat64Le64(offset uint64, v float64) bool
+
+	// Write64 is like Write, but accepts a 64-bit offset.
+	Write64(offset uint64, v []byte) bool
+
+	// WriteString64 is like WriteString, but accepts a 64-bit offset.
+	WriteString64(offset uint64, v string) bool
+
 	internalapi.WazeroOnly
 }
 
@@ -2,9 +2,11 @@ package wazero
 
 import (
 	"context"
+	"math"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
 )
 
 // HostFunctionBuilder defines a host function (in Go), so that a
@@ -188,6 +190,42 @@ type HostModuleBuilder interface {
 	// NewFunctionBuilder begins the definition of a host function.
 	NewFunctionBuilder() HostFunctionBuilder
 
+	// ExportTable exports a table of the given element type, which is either
+	// api.ValueTypeFuncref or api.ValueTypeExternref, with min initial null
+	// elements. max is the maximum count of elements, or math.MaxUint32 if
+	// unbounded.
+	//
+	// Each instantiation creates a new table, which can be accessed with
+	// api.Module ExportedTable, e.g. to set the functions called indirectly by
+	// a module importing it.
+	//
+	// Note: api.ValueTypeExternref requires api.CoreFeatureReferenceTypes.
+	ExportTable(name string, elemType api.ValueType, min, max uint32) HostModuleBuilder
+
+	// ExportMemory exports a memory with minPages initial pages (64KB each).
+	// maxPages is the maximum count of pages, or math.MaxUint32 if unbounded,
+	// in which case RuntimeConfig.WithMemoryLimitPages applies.
+	//
+	// Each instantiation creates a new memory, allocated with the
+	// experimental.MemoryAllocator of the context passed to Instantiate or
+	// Runtime.InstantiateModule, if any.
+	ExportMemory(name string, minPages, maxPages uint32) HostModuleBuilder
+
+	// ExportSharedMemory is like ExportMemory, except the memory is shared
+	// between threads and maxPages is required.
+	//
+	// Note: This requires experimental.CoreFeaturesThreads.
+	ExportSharedMemory(name string, minPages, maxPages uint32) HostModuleBuilder
+
+	// ExportGlobal exports a global of the given type, initialized to value
+	// which is encoded as documented on api.Global. If mutable, the global
+	// can be updated with api.MutableGlobal Set, or by a module importing it.
+	//
+	// Note: A global of api.ValueTypeExternref or api.ValueTypeFuncref must
+	// be initialized to null, i.e. zero. v128 globals are not supported, as a
+	// uint64 can't hold their value, so Compile returns an error for them.
+	ExportGlobal(name string, valType api.ValueType, value uint64, mutable bool) HostModuleBuilder
+
 	// Compile returns a CompiledModule that can be instantiated by Runtime.
 	Compile(context.Context) (CompiledModule, error)
 
@@ -221,6 +259,10 @@ type hostModuleBuilder struct {
 	moduleName     string
 	exportNames    []string
 	nameToHostFunc map[string]*wasm.HostFunc
+	tags           []*wasm.HostTag
+	tables         []*wasm.HostTable
+	memories       []*wasm.HostMemory
+	globals        []*wasm.HostGlobal
 }
 
 // NewHostModuleBuilder implements Runtime.NewHostModuleBuilder
@@ -310,6 +352,92 @@ func (b *hostModuleBuilder) ExportHostFunc(fn *wasm.HostFunc) {
 	b.nameToHostFunc[fn.ExportName] = fn
 }
 
+// ExportTable implements HostModuleBuilder.ExportTable
+func (b *hostModuleBuilder) ExportTable(name string, elemType api.ValueType, min, max uint32) HostModuleBuilder {
+	t := &wasm.HostTable{ExportName: name, Type: wasm.ValueType(elemType), Min: min}
+	if max != math.MaxUint32 {
+		t.Max = &max
+	}
+	for i, e := range b.tables {
+		if e.ExportName == name { // replace the existing table
+			b.tables[i] = t
+			return b
+		}
+	}
+	b.tables = append(b.tables, t)
+	return b
+}
+
+// ExportMemory implements HostModuleBuilder.ExportMemory
+func (b *hostModuleBuilder) ExportMemory(name string, minPages, maxPages uint32) HostModuleBuilder {
+	return b.exportMemory(name, minPages, maxPages, false)
+}
+
+// ExportSharedMemory implements HostModuleBuilder.ExportSharedMemory
+func (b *hostModuleBuilder) ExportSharedMemory(name string, minPages, maxPages uint32) HostModuleBuilder {
+	return b.exportMemory(name, minPages, maxPages, true)
+}
+
+func (b *hostModuleBuilder) exportMemory(name string, minPages, maxPages uint32, shared bool) HostModuleBuilder {
+	mem := &wasm.Memory{Min: minPages, IsShared: shared}
+	if maxPages != math.MaxUint32 {
+		mem.Max, mem.IsMaxEncoded = maxPages, true
+	}
+	m := &wasm.HostMemory{ExportName: name, Memory: mem}
+	for i, e := range b.memories {
+		if e.ExportName == name { // replace the existing memory
+			b.memories[i] = m
+			return b
+		}
+	}
+	b.memories = append(b.memories, m)
+	return b
+}
+
+// ExportGlobal implements HostModuleBuilder.ExportGlobal
+func (b *hostModuleBuilder) ExportGlobal(name string, valType api.ValueType, value uint64, mutable bool) HostModuleBuilder {
+	g := &wasm.HostGlobal{
+		ExportName: name,
+		Type:       wasm.GlobalType{ValType: wasm.ValueType(valType), Mutable: mutable},
+		Value:      value,
+	}
+	for i, e := range b.globals {
+		if e.ExportName == name { // replace the existing global
+			b.globals[i] = g
+			return b
+		}
+	}
+	b.globals = append(b.globals, g)
+	return b
+}
+
+// hostMemories returns the memories to define, sized according to the
+// runtime configuration.
+func (b *hostModuleBuilder) hostMemories() []*wasm.HostMemory {
+	ret := make([]*wasm.HostMemory, 0, len(b.memories))
+	for _, hm := range b.memories {
+		mem := *hm.Memory
+		var maxPages *uint32
+		if mem.IsMaxEncoded {
+			maxPages = &hm.Memory.Max
+		}
+		mem.Min, mem.Cap, mem.Max = binary.SizeMemory(mem.Min, maxPages, b.r.memoryLimitPages, b.r.memoryCapacityFromMax)
+		ret = append(ret, &wasm.HostMemory{ExportName: hm.ExportName, Memory: &mem})
+	}
+	return ret
+}
+
+// ExportHostTag implements wasm.HostTagExporter
+func (b *hostModuleBuilder) ExportHostTag(tag *wasm.HostTag) {
+	for i, t := range b.tags {
+		if t.ExportName == tag.ExportName { // replace the existing tag
+			b.tags[i] = tag
+			return
+		}
+	}
+	b.tags = append(b.tags, tag)
+}
+
 // NewFunctionBuilder implements HostModuleBuilder.NewFunctionBuilder
 func (b *hostModuleBuilder) NewFunctionBuilder() HostFunctionBuilder {
 	return &hostFunctionBuilder{b: b}
@@ -320,17 +448,28 @@ func (b *hostModuleBuilder) Compile(ctx context.Context) (CompiledModule, error)
 	module, err := wasm.NewHostModule(b.moduleName, b.exportNames, b.nameToHostFunc, b.r.enabledFeatures)
 	if err != nil {
 		return nil, err
+	} else if err = module.AddHostTags(b.tags, b.r.enabledFeatures); err != nil {
+		return nil, err
+	} else if err = module.AddHostTables(b.tables); err != nil {
+		return nil, err
+	} else if err = module.AddHostMemories(b.hostMemories(), b.r.enabledFeatures, b.r.memoryLimitPages); err != nil {
+		return nil, err
+	} else if err = module.AddHostGlobals(b.globals); err != nil {
+		return nil, err
 	} else if err = module.Validate(b.r.enabledFeatures); err != nil {
 		return nil, err
 	}
 
+	// Now that the module is validated, cache the memory definitions.
+	module.BuildMemoryDefinitions()
+
 	c := &compiledModule{module: module, compiledEngine: b.r.store.Engine}
 	listeners, err := buildFunctionListeners(ctx, module)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = b.r.store.Engine.CompileModule(ctx, module, listeners, false); err != nil {
+	if err = b.r.store.Engine.CompileModule(ctx, module, listeners, false, false, false); err != nil {
 		return nil, err
 	}
 
@@ -352,6 +491,11 @@ func (h hostModuleInstance) ExportedFunction(name string) api.Function {
 	panic("calling ExportedFunction is forbidden on host modules. See the note on ExportedFunction interface")
 }
 
+// Unwrap returns the wrapped api.Module, for use by experimental packages.
+func (h hostModuleInstance) Unwrap() api.Module {
+	return h.Module
+}
+
 // Instantiate implements HostModuleBuilder.Instantiate
 func (b *hostModuleBuilder) Instantiate(ctx context.Context) (api.Module, error) {
 	if compiled, err := b.Compile(ctx); err != nil {
@@ -2,9 +2,13 @@ package wazero
 
 import (
 	"context"
+	"math"
 	"testing"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -12,6 +16,7 @@ import (
 // TestNewHostModuleBuilder_Compile only covers a few scenarios to avoid duplicating tests in internal/wasm/host_test.go
 func TestNewHostModuleBuilder_Compile(t *testing.T) {
 	i32, i64 := wasm.ValueTypeI32, wasm.ValueTypeI64
+	tableMax := uint32(10)
 
 	uint32_uint32 := func(context.Context, uint32) uint32 {
 		return 0
@@ -302,6 +307,113 @@ func TestNewHostModuleBuilder_Compile(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "ExportTable",
+			input: func(r Runtime) HostModuleBuilder {
+				return r.NewHostModuleBuilder("host").
+					NewFunctionBuilder().WithFunc(uint32_uint32).Export("1").
+					ExportTable("table", api.ValueTypeFuncref, 2, math.MaxUint32).
+					ExportTable("bounded", api.ValueTypeExternref, 1, 10)
+			},
+			expected: &wasm.Module{
+				TypeSection: []wasm.FunctionType{
+					{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+				},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []wasm.Code{wasm.MustParseGoReflectFuncCode(uint32_uint32)},
+				TableSection: []wasm.Table{
+					{Type: wasm.RefTypeFuncref, Min: 2},
+					{Type: wasm.RefTypeExternref, Min: 1, Max: &tableMax},
+				},
+				ExportSection: []wasm.Export{
+					{Name: "1", Type: wasm.ExternTypeFunc, Index: 0},
+					{Name: "table", Type: wasm.ExternTypeTable, Index: 0},
+					{Name: "bounded", Type: wasm.ExternTypeTable, Index: 1},
+				},
+				Exports: map[string]*wasm.Export{
+					"1":       {Name: "1", Type: wasm.ExternTypeFunc, Index: 0},
+					"table":   {Name: "table", Type: wasm.ExternTypeTable, Index: 0},
+					"bounded": {Name: "bounded", Type: wasm.ExternTypeTable, Index: 1},
+				},
+				NameSection: &wasm.NameSection{
+					FunctionNames: wasm.NameMap{{Index: 0, Name: "1"}},
+					ModuleName:    "host",
+				},
+			},
+		},
+		{
+			name: "ExportMemory",
+			input: func(r Runtime) HostModuleBuilder {
+				return r.NewHostModuleBuilder("host").ExportMemory("memory", 1, 10)
+			},
+			expected: &wasm.Module{
+				MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 10, IsMaxEncoded: true}},
+				ExportSection: []wasm.Export{{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0}},
+				Exports: map[string]*wasm.Export{
+					"memory": {Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+				},
+				NameSection: &wasm.NameSection{ModuleName: "host"},
+			},
+		},
+		{
+			name: "ExportMemory unbounded",
+			input: func(r Runtime) HostModuleBuilder {
+				return r.NewHostModuleBuilder("host").ExportMemory("memory", 1, math.MaxUint32)
+			},
+			expected: &wasm.Module{
+				MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: wasm.MemoryLimitPages}},
+				ExportSection: []wasm.Export{{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0}},
+				Exports: map[string]*wasm.Export{
+					"memory": {Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+				},
+				NameSection: &wasm.NameSection{ModuleName: "host"},
+			},
+		},
+		{
+			name: "ExportGlobal",
+			input: func(r Runtime) HostModuleBuilder {
+				return r.NewHostModuleBuilder("host").
+					ExportGlobal("i32", api.ValueTypeI32, api.EncodeI32(-1), false).
+					ExportGlobal("i64", api.ValueTypeI64, api.EncodeI64(2), true).
+					ExportGlobal("f32", api.ValueTypeF32, api.EncodeF32(1.5), false).
+					ExportGlobal("f64", api.ValueTypeF64, api.EncodeF64(2.5), true).
+					ExportGlobal("externref", api.ValueTypeExternref, 0, true).
+					ExportGlobal("i32", api.ValueTypeI32, api.EncodeI32(1), true) // replaces the first
+			},
+			expected: &wasm.Module{
+				GlobalSection: []wasm.Global{
+					{Type: wasm.GlobalType{ValType: i32, Mutable: true}, Init: wasm.NewConstantExpressionFromI32(1)},
+					{Type: wasm.GlobalType{ValType: i64, Mutable: true}, Init: wasm.NewConstantExpressionFromI64(2)},
+					{
+						Type: wasm.GlobalType{ValType: wasm.ValueTypeF32},
+						Init: wasm.NewConstantExpressionFromOpcode(wasm.OpcodeF32Const, []byte{0, 0, 0xc0, 0x3f}),
+					},
+					{
+						Type: wasm.GlobalType{ValType: wasm.ValueTypeF64, Mutable: true},
+						Init: wasm.NewConstantExpressionFromOpcode(wasm.OpcodeF64Const, []byte{0, 0, 0, 0, 0, 0, 0x4, 0x40}),
+					},
+					{
+						Type: wasm.GlobalType{ValType: wasm.ValueTypeExternref, Mutable: true},
+						Init: wasm.NewConstantExpressionFromOpcode(wasm.OpcodeRefNull, []byte{wasm.ValueTypeExternref.Kind()}),
+					},
+				},
+				ExportSection: []wasm.Export{
+					{Name: "i32", Type: wasm.ExternTypeGlobal, Index: 0},
+					{Name: "i64", Type: wasm.ExternTypeGlobal, Index: 1},
+					{Name: "f32", Type: wasm.ExternTypeGlobal, Index: 2},
+					{Name: "f64", Type: wasm.ExternTypeGlobal, Index: 3},
+					{Name: "externref", Type: wasm.ExternTypeGlobal, Index: 4},
+				},
+				Exports: map[string]*wasm.Export{
+					"i32":       {Name: "i32", Type: wasm.ExternTypeGlobal, Index: 0},
+					"i64":       {Name: "i64", Type: wasm.ExternTypeGlobal, Index: 1},
+					"f32":       {Name: "f32", Type: wasm.ExternTypeGlobal, Index: 2},
+					"f64":       {Name: "f64", Type: wasm.ExternTypeGlobal, Index: 3},
+					"externref": {Name: "externref", Type: wasm.ExternTypeGlobal, Index: 4},
+				},
+				NameSection: &wasm.NameSection{ModuleName: "host"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -350,6 +462,43 @@ func TestNewHostModuleBuilder_Compile_Errors(t *testing.T) {
 			},
 			expectedErr: `func[host.fn] param[0] is unsupported: string`,
 		},
+		{
+			name: "shared memory without threads",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").ExportSharedMemory("memory", 1, 10)
+			},
+			expectedErr: `memory[host.memory] shared memory requested but feature "threads" is disabled`,
+		},
+		{
+			name: "memory over limit",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").ExportMemory("memory", wasm.MemoryLimitPages+1, math.MaxUint32)
+			},
+			expectedErr: `memory[host.memory] min 65537 pages (4 Gi) over limit of 65536 pages (4 Gi)`,
+		},
+		{
+			name: "non-null externref global",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").ExportGlobal("g", api.ValueTypeExternref, 1, false)
+			},
+			expectedErr: `global[host.g] externref must be initialized to null`,
+		},
+		{
+			name: "v128 global",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").ExportGlobal("g", api.ValueType(wasm.ValueTypeV128), 0, false)
+			},
+			expectedErr: `global[host.g] v128 is not supported as its value doesn't fit in a uint64`,
+		},
+		{
+			name: "duplicate export name",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").
+					ExportMemory("x", 1, 1).
+					ExportGlobal("x", api.ValueTypeI32, 0, false)
+			},
+			expectedErr: `global[host.x] export name conflicts with another export`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -390,6 +539,112 @@ func TestNewHostModuleBuilder_Instantiate_Errors(t *testing.T) {
 	require.EqualError(t, err, "module[env] has already been instantiated")
 }
 
+// TestNewHostModuleBuilder_Exports ensures a Wasm module can import memories and
+// globals defined by the host, and that the host sees the updates of the guest.
+func TestNewHostModuleBuilder_Exports(t *testing.T) {
+	const i32 = wasm.ValueTypeI32
+	// The guest exports "push" which adds its param to the imported
+	// "__stack_pointer", stores the result at offset 0 of the imported memory,
+	// and returns it converted to f64 plus the imported "base".
+	guestWasm := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{wasm.ValueTypeF64}}},
+		ImportSection: []wasm.Import{
+			{Module: "env", Name: "memory", Type: wasm.ExternTypeMemory, DescMem: &wasm.Memory{Min: 1}},
+			{Module: "env", Name: "__stack_pointer", Type: wasm.ExternTypeGlobal, DescGlobal: wasm.GlobalType{ValType: i32, Mutable: true}},
+			{Module: "env", Name: "base", Type: wasm.ExternTypeGlobal, DescGlobal: wasm.GlobalType{ValType: wasm.ValueTypeF64}},
+		},
+		ImportMemoryCount: 1,
+		ImportGlobalCount: 2,
+		FunctionSection:   []wasm.Index{0},
+		CodeSection: []wasm.Code{{Body: []byte{
+			wasm.OpcodeGlobalGet, 0,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Add,
+			wasm.OpcodeGlobalSet, 0,
+			wasm.OpcodeI32Const, 0,
+			wasm.OpcodeGlobalGet, 0,
+			wasm.OpcodeI32Store, 0x2, 0x0,
+			wasm.OpcodeGlobalGet, 0,
+			wasm.OpcodeF64ConvertI32S,
+			wasm.OpcodeGlobalGet, 1,
+			wasm.OpcodeF64Add,
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []wasm.Export{{Name: "push", Type: wasm.ExternTypeFunc, Index: 0}},
+	})
+
+	for _, tc := range []struct {
+		name   string
+		config RuntimeConfig
+	}{
+		{name: "compiler", config: NewRuntimeConfigCompiler()},
+		{name: "interpreter", config: NewRuntimeConfigInterpreter()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.name == "compiler" && !platform.CompilerSupported() {
+				t.Skip("Compiler is not supported on this host")
+			}
+			r := NewRuntimeWithConfig(testCtx, tc.config.WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesThreads))
+			defer r.Close(testCtx)
+
+			var allocated int
+			ctx := experimental.WithMemoryAllocator(testCtx, experimental.MemoryAllocatorFunc(func(cap, max uint64) experimental.LinearMemory {
+				allocated++
+				return &fixedLinearMemory{buf: make([]byte, 0, max)}
+			}))
+			host, err := r.NewHostModuleBuilder("env").
+				ExportSharedMemory("memory", 1, 10).
+				ExportGlobal("__stack_pointer", api.ValueTypeI32, api.EncodeI32(100), true).
+				ExportGlobal("base", api.ValueTypeF64, api.EncodeF64(0.5), false).
+				Instantiate(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 1, allocated)
+
+			mem := host.ExportedMemory("memory")
+			require.Equal(t, mem, host.Memory())
+			maxPages, ok := mem.Definition().Max()
+			require.True(t, ok)
+			require.Equal(t, uint32(10), maxPages)
+			sp := host.ExportedGlobal("__stack_pointer").(api.MutableGlobal)
+			require.Equal(t, api.ValueTypeF64, host.ExportedGlobal("base").Type())
+			_, mutable := host.ExportedGlobal("base").(api.MutableGlobal)
+			require.False(t, mutable)
+
+			mod, err := r.Instantiate(testCtx, guestWasm)
+			require.NoError(t, err)
+
+			res, err := mod.ExportedFunction("push").Call(testCtx, api.EncodeI32(-4))
+			require.NoError(t, err)
+			require.Equal(t, 96.5, api.DecodeF64(res[0]))
+			require.Equal(t, int32(96), api.DecodeI32(sp.Get()))
+			v, ok := mem.ReadUint32Le(0)
+			require.True(t, ok)
+			require.Equal(t, uint32(96), v)
+
+			// The guest sees the updates of the host.
+			sp.Set(api.EncodeI32(10))
+			res, err = mod.ExportedFunction("push").Call(testCtx, api.EncodeI32(1))
+			require.NoError(t, err)
+			require.Equal(t, 11.5, api.DecodeF64(res[0]))
+		})
+	}
+}
+
+// fixedLinearMemory is an experimental.LinearMemory which never moves.
+type fixedLinearMemory struct {
+	buf []byte
+}
+
+func (m *fixedLinearMemory) Reallocate(size uint64) []byte {
+	if size > uint64(cap(m.buf)) {
+		return nil
+	}
+	m.buf = m.buf[:size]
+	return m.buf
+}
+
+func (m *fixedLinearMemory) Free() {}
+
 // requireHostModuleEquals is redefined from internal/wasm/host_test.go to avoid an import cycle extracting it.
 func requireHostModuleEquals(t *testing.T, expected, actual *wasm.Module) {
 	// `require.Equal(t, expected, actual)` fails reflect pointers don't match, so brute compare:
@@ -7,7 +7,6 @@ import (
 	"io"
 	"io/fs"
 	"math"
-	"net"
 	"time"
 
 	"github.com/tetratelabs/wazero/api"
@@ -54,13 +53,15 @@ type RuntimeConfig interface {
 
 	// WithMemoryLimitPages overrides the maximum pages allowed per memory. The
 	// default is 65536, allowing 4GB total memory per instance if the maximum is
-	// not encoded in a Wasm binary. Setting a value larger than default will panic.
+	// not encoded in a Wasm binary. Setting a value larger than 16777216 (1TB)
+	// will panic.
 	//
 	// This example reduces the largest possible memory size from 4GB to 128KB:
 	//	rConfig = wazero.NewRuntimeConfig().WithMemoryLimitPages(2)
 	//
 	// Note: Wasm has 32-bit memory and each page is 65536 (2^16) bytes. This
-	// implies a max of 65536 (2^16) addressable pages.
+	// implies a max of 65536 (2^16) addressable pages. Values larger than that
+	// only apply to 64-bit memories (experimental.CoreFeaturesMemory64).
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#grow-mem
 	WithMemoryLimitPages(memoryLimitPages uint32) RuntimeConfig
 
@@ -168,6 +169,40 @@ type RuntimeConfig interface {
 	// When the invocations of api.Function are closed due to this, sys.ExitError is raised to the callers and
 	// the api.Module from which the functions are derived is made closed.
 	WithCloseOnContextDone(bool) RuntimeConfig
+
+	// WithFuelMetering makes the executions of functions consume fuel, so that the amount of work done by
+	// untrusted Wasm binaries can be bounded deterministically, regardless of the speed of the machine.
+	//
+	// When enabled, each executed Wasm instruction consumes one unit of fuel, the same in the interpreter and
+	// the compiler, including the instructions of inlined functions. The fuel is consumed at once on entry to
+	// each straight-line sequence of instructions, so a call fails with the fuel left when the next sequence
+	// doesn't fit in it, before executing any of its instructions. The fuel budget of each call of api.Function is set with
+	// experimental.WithFuel on the context.Context passed to Call, and host functions can query the fuel
+	// left with experimental.RemainingFuel. Calls made with a context without a budget are not limited.
+	//
+	// When the fuel is exhausted, sys.ExitError with the exit code sys.ExitCodeFuelExhausted is raised to
+	// the callers and the api.Module from which the functions are derived is made closed, the same as
+	// WithCloseOnContextDone.
+	//
+	// Note that this comes with an extra cost on each branch and function call when enabled. For that reason,
+	// this is disabled by default.
+	WithFuelMetering(bool) RuntimeConfig
+
+	// WithMemoryGuardPages makes the compiler elide the bounds checks of the accesses to the memories with 32-bit
+	// addresses. Instead, each of these memories reserves 8GiB of the address space, of which only the pages within
+	// the current size are accessible, and the faults of the out of bounds accesses are converted into the same
+	// error as the bounds checks raise.
+	//
+	// This is only supported by the compiler on linux/amd64. On the other platforms, including linux/arm64, and with
+	// the interpreter, this is ignored and the bounds checks are kept. When enabled, the memories with 32-bit
+	// addresses are allocated regardless of experimental.WithMemoryAllocator, and wazero installs its own SIGSEGV
+	// handler, which forwards the signals not raised by the compiled code on the stack of a call in progress to the
+	// Go runtime. That handler is verified each time a Runtime is created: if it has been replaced since, e.g. by a
+	// cgo library, the bounds checks are kept, and compiling a module for a Runtime which elides them fails.
+	//
+	// Note that this consumes a lot of the virtual address space, which might be limited by, e.g. `ulimit -v`.
+	// For that reason, this is disabled by default.
+	WithMemoryGuardPages(bool) RuntimeConfig
 }
 
 // NewRuntimeConfig returns a RuntimeConfig using the compiler if it is supported in this environment,
@@ -190,6 +225,8 @@ type runtimeConfig struct {
 	cache                 CompilationCache
 	storeCustomSections   bool
 	ensureTermination     bool
+	fuelMetering          bool
+	memoryGuardPages      bool
 }
 
 // engineLessConfig helps avoid copy/pasting the wrong defaults.
@@ -206,6 +243,7 @@ const (
 	engineKindAuto engineKind = iota - 1
 	engineKindCompiler
 	engineKindInterpreter
+	engineKindTiered
 	engineKindCount
 )
 
@@ -245,6 +283,32 @@ func NewRuntimeConfigInterpreter() RuntimeConfig {
 	return ret
 }
 
+// NewRuntimeConfigTiered starts executing WebAssembly modules with the interpreter, and compiles their functions into
+// assembly on the background once they get hot, i.e. are called or loop many times. Each hot function is then swapped
+// for its compiled code, which runs on the same state of the module instance as the interpreter.
+//
+// Note the following limitations:
+//   - The functions are compiled one by one, so the compiled code calls the other functions of the module via their
+//     function references, which is slower than the direct calls of NewRuntimeConfigCompiler.
+//   - There is no on-stack replacement: a function being interpreted keeps being interpreted until it returns, so a
+//     long-running loop, e.g. the main loop of a program, only gets faster in what it calls.
+//
+// This is useful for large modules which take long to compile before the first call with NewRuntimeConfigCompiler,
+// as only their hot functions are compiled.
+//
+// The modules using any of the following are always interpreted:
+//   - experimental.FunctionListener
+//   - RuntimeConfig.WithFuelMetering
+//   - the exception handling or the garbage collection proposals
+//
+// The functions are also interpreted while the snapshotter of experimental.WithSnapshotter is enabled. If the
+// compiler is not supported on the platform, this is the same as NewRuntimeConfigInterpreter.
+func NewRuntimeConfigTiered() RuntimeConfig {
+	ret := engineLessConfig.clone()
+	ret.engineKind = engineKindTiered
+	return ret
+}
+
 // clone makes a deep copy of this runtime config.
 func (c *runtimeConfig) clone() *runtimeConfig {
 	ret := *c // copy except maps which share a ref
@@ -265,12 +329,26 @@ func (c *runtimeConfig) WithCloseOnContextDone(ensure bool) RuntimeConfig {
 	return ret
 }
 
+// WithFuelMetering implements RuntimeConfig.WithFuelMetering
+func (c *runtimeConfig) WithFuelMetering(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.fuelMetering = enabled
+	return ret
+}
+
+// WithMemoryGuardPages implements RuntimeConfig.WithMemoryGuardPages
+func (c *runtimeConfig) WithMemoryGuardPages(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.memoryGuardPages = enabled
+	return ret
+}
+
 // WithMemoryLimitPages implements RuntimeConfig.WithMemoryLimitPages
 func (c *runtimeConfig) WithMemoryLimitPages(memoryLimitPages uint32) RuntimeConfig {
 	ret := c.clone()
 	// This panics instead of returning an error as it is unlikely.
-	if memoryLimitPages > wasm.MemoryLimitPages {
-		panic(fmt.Errorf("memoryLimitPages invalid: %d > %d", memoryLimitPages, wasm.MemoryLimitPages))
+	if memoryLimitPages > wasm.Memory64LimitPages {
+		panic(fmt.Errorf("memoryLimitPages invalid: %d > %d", memoryLimitPages, wasm.Memory64LimitPages))
 	}
 	ret.memoryLimitPages = memoryLimitPages
 	return ret
@@ -871,13 +949,15 @@ func (c *moduleConfig) toSysContext() (sysCtx *internalsys.Context, err error) {
 
 	var fs []experimentalsys.FS
 	var guestPaths []string
+	var maxOpenFiles uint32
 	if f, ok := c.fsConfig.(*fsConfig); ok {
 		fs, guestPaths = f.preopens()
+		maxOpenFiles = f.maxOpenFiles
 	}
 
-	var listeners []*net.TCPListener
+	var socks *internalsock.Sockets
 	if n := c.sockConfig; n != nil {
-		if listeners, err = n.BuildTCPListeners(); err != nil {
+		if socks, err = n.BuildSockets(); err != nil {
 			return
 		}
 	}
@@ -894,6 +974,7 @@ func (c *moduleConfig) toSysContext() (sysCtx *internalsys.Context, err error) {
 		c.nanotime, c.nanotimeResolution,
 		c.nanosleep, c.osyield,
 		fs, guestPaths,
-		listeners,
+		maxOpenFiles,
+		socks,
 	)
 }
@@ -72,6 +72,16 @@ func TestRuntimeConfig(t *testing.T) {
 			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithCloseOnContextDone(true) },
 			expected: &runtimeConfig{ensureTermination: true},
 		},
+		{
+			name:     "WithFuelMetering",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithFuelMetering(true) },
+			expected: &runtimeConfig{fuelMetering: true},
+		},
+		{
+			name:     "WithMemoryGuardPages",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithMemoryGuardPages(true) },
+			expected: &runtimeConfig{memoryGuardPages: true},
+		},
 	}
 
 	for _, tt := range tests {
@@ -89,9 +99,9 @@ func TestRuntimeConfig(t *testing.T) {
 	t.Run("memoryLimitPages invalid panics", func(t *testing.T) {
 		err := require.CapturePanic(func() {
 			input := &runtimeConfig{}
-			input.WithMemoryLimitPages(wasm.MemoryLimitPages + 1)
+			input.WithMemoryLimitPages(wasm.Memory64LimitPages + 1)
 		})
-		require.EqualError(t, err, "memoryLimitPages invalid: 65537 > 65536")
+		require.EqualError(t, err, "memoryLimitPages invalid: 16777217 > 16777216")
 	})
 }
 
@@ -647,7 +657,7 @@ func Test_compiledModule_Close(t *testing.T) {
 		var cs []*compiledModule
 		for i := 0; i < 10; i++ {
 			m := &wasm.Module{}
-			err := e.CompileModule(ctx, m, nil, false)
+			err := e.CompileModule(ctx, m, nil, false, false, false)
 			require.NoError(t, err)
 			cs = append(cs, &compiledModule{module: m, compiledEngine: e})
 		}
@@ -672,3 +682,11 @@ func TestNewRuntimeConfig(t *testing.T) {
 	// Ensures if the correct engine is selected.
 	require.Equal(t, engineKindAuto, c.engineKind)
 }
+
+func TestNewRuntimeConfigTiered(t *testing.T) {
+	c, ok := NewRuntimeConfigTiered().(*runtimeConfig)
+	require.True(t, ok)
+	// Should be cloned from the source.
+	require.NotEqual(t, engineLessConfig, c)
+	require.Equal(t, engineKindTiered, c.engineKind)
+}
@@ -0,0 +1,150 @@
+// Package component instantiates WebAssembly components, which are composed
+// of core modules whose functions are lifted to and lowered from the types
+// of the component model with the canonical ABI.
+//
+// Components import instances implemented by the host, such as the WASI 0.2
+// interfaces in imports/wasip2. Components nested in others, and importing
+// or exporting values, are not supported.
+//
+// See https://github.com/WebAssembly/component-model
+package component
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/component"
+	"github.com/tetratelabs/wazero/internal/internalapi"
+)
+
+// CompiledComponent is a component whose core modules are compiled by a
+// wazero.Runtime, which can be instantiated many times.
+type CompiledComponent interface {
+	internalapi.WazeroOnly
+
+	// Close releases the compiled core modules.
+	Close(context.Context) error
+}
+
+// Instance is an instantiated component.
+type Instance interface {
+	internalapi.WazeroOnly
+	api.Closer
+
+	// ExportedFunction returns a function exported by name, or nil if there
+	// is none. A function exported by an exported instance is named with
+	// both, separated by '#', e.g. "wasi:cli/run@0.2.0#run".
+	//
+	// A name without a version matches an export with any, and versions
+	// match if they are semver compatible.
+	ExportedFunction(name string) Function
+
+	// CloseWithExitCode closes the core module instances of the component
+	// with the exit code.
+	CloseWithExitCode(ctx context.Context, exitCode uint32) error
+
+	// IsClosed returns true if the instance was closed.
+	IsClosed() bool
+}
+
+// Function is a function exported by a component.
+type Function interface {
+	internalapi.WazeroOnly
+
+	// Call calls the function with params, and returns its results.
+	//
+	// Values are represented in Go according to their type:
+	//
+	//   - bool, s8...u64, f32, f64: bool, int8...uint64, float32, float64
+	//   - char: rune
+	//   - string: string
+	//   - list<u8>: []byte
+	//   - other lists, records and tuples: []any
+	//   - variant, option and result: Variant
+	//   - enum: uint32, the index of the case
+	//   - flags: uint32, with bit i set if label i is
+	//   - own and borrow: the representation of the resource
+	Call(ctx context.Context, params ...any) ([]any, error)
+}
+
+// HostInstance is an instance implemented by the host, which satisfies the
+// imports of instances with a compatible name, e.g. one of the interfaces
+// returned by wasip2.HostInstances.
+type HostInstance interface {
+	internalapi.WazeroOnly
+}
+
+// Variant is the value of a variant, option or result.
+type Variant = component.Variant
+
+// None is the value of an empty option.
+var None = component.None
+
+// Some returns the value of an option holding v.
+func Some(v any) Variant {
+	return component.Some(v)
+}
+
+// Ok returns the value of a successful result, with an optional payload.
+func Ok(v any) Variant {
+	return component.Ok(v)
+}
+
+// Err returns the value of a failed result, with an optional payload.
+func Err(v any) Variant {
+	return component.Err(v)
+}
+
+// IsComponent returns true if the binary is a component, as opposed to a
+// core module.
+func IsComponent(binary []byte) bool {
+	return component.IsComponent(binary)
+}
+
+// Compile decodes a component, and compiles its core modules with r.
+func Compile(ctx context.Context, r wazero.Runtime, binary []byte) (CompiledComponent, error) {
+	c, err := component.Compile(ctx, r, binary)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Instantiate instantiates a component compiled by the same runtime, with
+// the host instances it imports.
+//
+// Each core module of the component is instantiated anonymously with
+// config, and without calling its start functions. The first one owns the
+// system context, e.g. the file system and the standard streams, which is
+// used by host instances such as the ones of wasip2.
+func Instantiate(ctx context.Context, r wazero.Runtime, compiled CompiledComponent, config wazero.ModuleConfig, imports ...HostInstance) (Instance, error) {
+	hosts := make([]*component.HostInstance, len(imports))
+	for i, h := range imports {
+		hosts[i] = h.(*component.HostInstance)
+	}
+	inst, err := compiled.(*component.CompiledComponent).Instantiate(ctx, r, config, hosts)
+	if err != nil {
+		return nil, err
+	}
+	return &instance{inst}, nil
+}
+
+// instance implements Instance.
+type instance struct {
+	*component.ComponentInstance
+}
+
+// ExportedFunction implements Instance.ExportedFunction
+func (i *instance) ExportedFunction(name string) Function {
+	if f := i.ComponentInstance.ExportedFunction(name); f != nil {
+		return &function{Func: f}
+	}
+	return nil
+}
+
+// function implements Function.
+type function struct {
+	internalapi.WazeroOnlyType
+	*component.Func
+}
@@ -0,0 +1,112 @@
+// Package exception allows host functions to interoperate with the exception
+// handling proposal, enabled by experimental.CoreFeaturesExceptionHandling.
+//
+// Host functions can throw a Wasm exception into the calling Wasm code with
+// Throw, callers of api.Function can inspect an exception that escaped the
+// call with FromError, and host modules can define tags with ExportTag.
+//
+// Note: exceptions never cross a nested api.Function call. An exception that
+// escapes such a call is returned as an error, which the host function may
+// re-throw with Throw.
+package exception
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// Tag is an instantiated exception tag. Tags are compared by identity: two
+// tags are equal with == only if they are the same instance, even if their
+// types are the same.
+//
+// The zero value is not a valid tag.
+type Tag struct {
+	tag *wasm.TagInstance
+}
+
+// ParamTypes returns the types of the exception payload of this tag.
+func (t Tag) ParamTypes() []api.ValueType {
+	return wasm.ToApiValueType(t.tag.Type.Params)
+}
+
+// Exception is a Wasm exception that escaped an api.Function call.
+type Exception struct {
+	// Tag is the tag the exception was thrown with.
+	Tag Tag
+	// Params holds the payload, encoded the same way as api.Function
+	// parameters and matching Tag.ParamTypes.
+	Params []uint64
+}
+
+// ExportedTag returns the tag exported by the module under the given name,
+// or false if there is no such tag.
+//
+// The module is either instantiated from Wasm or built with
+// wazero.HostModuleBuilder, in which case the tag is defined with ExportTag.
+func ExportedTag(module api.Module, name string) (Tag, bool) {
+	m := moduleInstance(module)
+	if m == nil {
+		return Tag{}, false
+	}
+	if t := m.ExportedTag(name); t != nil {
+		return Tag{tag: t}, true
+	}
+	return Tag{}, false
+}
+
+func moduleInstance(module api.Module) *wasm.ModuleInstance {
+	switch m := module.(type) {
+	case *wasm.ModuleInstance:
+		return m
+	case interface{ Unwrap() api.Module }: // host module instance.
+		return moduleInstance(m.Unwrap())
+	}
+	return nil
+}
+
+// Throw throws an exception with the given tag and payload into the Wasm code
+// that called the current host function. If no handler catches it, the
+// api.Function call that started the execution returns an error, for which
+// FromError reports the exception.
+//
+// This panics, so it must only be called from an api.GoFunction or
+// api.GoModuleFunction, and never returns. It panics with an error instead if
+// the params don't match the tag's ParamTypes.
+func Throw(tag Tag, params ...uint64) {
+	if tag.tag == nil {
+		panic(errors.New("exception: invalid tag"))
+	}
+	if expected := len(tag.tag.Type.Params); len(params) != expected {
+		panic(fmt.Errorf("exception: expected %d params, but passed %d", expected, len(params)))
+	}
+	panic(&wasm.Exception{Tag: tag.tag, Params: append([]uint64(nil), params...)})
+}
+
+// FromError returns the exception that escaped the api.Function call which
+// returned err, or false if err was not caused by an uncaught exception.
+func FromError(err error) (*Exception, bool) {
+	var exnErr *wasm.ExceptionError
+	if !errors.As(err, &exnErr) {
+		return nil, false
+	}
+	exn := exnErr.Exception
+	return &Exception{Tag: Tag{tag: exn.Tag}, Params: exn.Params}, true
+}
+
+// ExportTag defines a new tag with the given payload types in the host module
+// under construction, and exports it under the given name. Each instantiation
+// of the host module creates a distinct tag, which ExportedTag looks up.
+//
+// Compiling the host module fails unless
+// experimental.CoreFeaturesExceptionHandling is enabled.
+func ExportTag(builder wazero.HostModuleBuilder, name string, params ...api.ValueType) wazero.HostModuleBuilder {
+	builder.(wasm.HostTagExporter).ExportHostTag(&wasm.HostTag{
+		ExportName: name,
+		ParamTypes: wasm.FromApiValueType(params),
+	})
+	return builder
+}
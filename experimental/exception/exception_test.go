@@ -0,0 +1,176 @@
+package exception_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/experimental/exception"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+const i32 = wasm.ValueTypeI32
+
+// guestWasm imports the tag "env.err" and the host function "env.throw", and
+// exports:
+//   - "catch" which calls env.throw and returns the payload it threw, or -1.
+//   - "call" which calls env.throw without a handler.
+//   - "throw" which throws the tag itself.
+var guestWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+	},
+	ImportSection: []wasm.Import{
+		{Module: "env", Name: "throw", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		{Module: "env", Name: "err", Type: wasm.ExternTypeTag, DescTag: 0},
+	},
+	ImportFunctionCount: 1,
+	ImportTagCount:      1,
+	FunctionSection:     []wasm.Index{1, 0, 0},
+	CodeSection: []wasm.Code{
+		{Body: []byte{
+			wasm.OpcodeBlock, i32.Kind(),
+			wasm.OpcodeTryTable, 0x40, 1, 0x00, 0, 0, // (catch 0 0)
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Const, 0x7f, // -1
+			wasm.OpcodeReturn,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeThrow, 0, wasm.OpcodeEnd}},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "catch", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "call", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "throw", Type: wasm.ExternTypeFunc, Index: 3},
+	},
+})
+
+func TestException(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config wazero.RuntimeConfig
+	}{
+		{name: "interpreter", config: wazero.NewRuntimeConfigInterpreter()},
+		{name: "default", config: wazero.NewRuntimeConfig()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := wazero.NewRuntimeWithConfig(ctx, tc.config.
+				WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesExceptionHandling))
+			defer r.Close(ctx)
+
+			var errTag exception.Tag
+			b := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+				WithFunc(func(ctx context.Context, v uint32) {
+					if v == 0 {
+						return
+					}
+					exception.Throw(errTag, uint64(v))
+				}).Export("throw")
+			host, err := exception.ExportTag(b, "err", api.ValueTypeI32).Instantiate(ctx)
+			require.NoError(t, err)
+
+			var ok bool
+			errTag, ok = exception.ExportedTag(host, "err")
+			require.True(t, ok)
+			require.Equal(t, []api.ValueType{api.ValueTypeI32}, errTag.ParamTypes())
+			_, ok = exception.ExportedTag(host, "throw")
+			require.False(t, ok)
+
+			mod, err := r.Instantiate(ctx, guestWasm)
+			require.NoError(t, err)
+
+			t.Run("caught by guest", func(t *testing.T) {
+				res, err := mod.ExportedFunction("catch").Call(ctx, 42)
+				require.NoError(t, err)
+				require.Equal(t, uint64(42), res[0])
+
+				res, err = mod.ExportedFunction("catch").Call(ctx, 0)
+				require.NoError(t, err)
+				require.Equal(t, uint64(0xffffffff), res[0])
+			})
+
+			t.Run("thrown by host", func(t *testing.T) {
+				_, err := mod.ExportedFunction("call").Call(ctx, 7)
+				require.ErrorIs(t, err, wasmruntime.ErrRuntimeUncaughtException)
+
+				exn, ok := exception.FromError(err)
+				require.True(t, ok)
+				require.True(t, exn.Tag == errTag)
+				require.Equal(t, []uint64{7}, exn.Params)
+			})
+
+			t.Run("thrown by guest", func(t *testing.T) {
+				_, err := mod.ExportedFunction("throw").Call(ctx, 8)
+				require.ErrorIs(t, err, wasmruntime.ErrRuntimeUncaughtException)
+
+				exn, ok := exception.FromError(err)
+				require.True(t, ok)
+				require.True(t, exn.Tag == errTag)
+				require.Equal(t, []uint64{8}, exn.Params)
+			})
+
+			t.Run("not an exception", func(t *testing.T) {
+				_, ok := exception.FromError(wasmruntime.ErrRuntimeUnreachable)
+				require.False(t, ok)
+			})
+		})
+	}
+}
+
+func TestThrow_invalidParams(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesExceptionHandling))
+	defer r.Close(ctx)
+
+	var errTag exception.Tag
+	b := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, v uint32) {
+			exception.Throw(errTag)
+		}).Export("throw")
+	host, err := exception.ExportTag(b, "err", api.ValueTypeI32).Instantiate(ctx)
+	require.NoError(t, err)
+	errTag, _ = exception.ExportedTag(host, "err")
+
+	mod, err := r.Instantiate(ctx, guestWasm)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("catch").Call(ctx, 1)
+	require.EqualError(t, err, `exception: expected 1 params, but passed 0 (recovered by wazero)
+wasm stack trace:
+	env.throw(i32)
+	.$1(i32) i32`)
+}
+
+func TestExportTag(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		r := wazero.NewRuntime(ctx)
+		defer r.Close(ctx)
+
+		_, err := exception.ExportTag(r.NewHostModuleBuilder("env"), "err").Instantiate(ctx)
+		require.EqualError(t, err, "tag[env.err] feature \"exception-handling\" is disabled")
+	})
+
+	t.Run("conflicts with another export", func(t *testing.T) {
+		r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+			WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesExceptionHandling))
+		defer r.Close(ctx)
+
+		b := r.NewHostModuleBuilder("env").NewFunctionBuilder().WithFunc(func() {}).Export("err")
+		_, err := exception.ExportTag(b, "err").Instantiate(ctx)
+		require.EqualError(t, err, "tag[env.err] export name conflicts with another export")
+	})
+}
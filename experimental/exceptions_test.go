@@ -17,31 +17,39 @@ var cppExceptionsWasm []byte
 func TestCppExceptions(t *testing.T) {
 	ctx := context.Background()
 
-	cfg := wazero.NewRuntimeConfig().
-		WithCoreFeatures(api.CoreFeaturesV2 | experimental.CoreFeaturesExceptionHandling)
-
-	r := wazero.NewRuntimeWithConfig(ctx, cfg)
-	defer r.Close(ctx)
-
-	mod, err := r.InstantiateWithConfig(ctx, cppExceptionsWasm,
-		wazero.NewModuleConfig().WithStartFunctions("_initialize"))
-	require.NoError(t, err)
-
-	tests := []struct {
-		name     string
-		expected int32
+	for _, tc := range []struct {
+		name string
+		cfg  wazero.RuntimeConfig
 	}{
-		{"test_no_throw", 42},
-		{"test_catch_specific", -1},
-		{"test_catch_base", 1},
-		{"test_rethrow", -42},
-	}
-
-	for _, tc := range tests {
+		{"interpreter", wazero.NewRuntimeConfigInterpreter()},
+		{"default", wazero.NewRuntimeConfig()},
+	} {
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := mod.ExportedFunction(tc.name).Call(ctx)
+			r := wazero.NewRuntimeWithConfig(ctx, tc.cfg.
+				WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesExceptionHandling))
+			defer r.Close(ctx)
+
+			mod, err := r.InstantiateWithConfig(ctx, cppExceptionsWasm,
+				wazero.NewModuleConfig().WithStartFunctions("_initialize"))
 			require.NoError(t, err)
-			require.Equal(t, tc.expected, api.DecodeI32(res[0]))
+
+			tests := []struct {
+				name     string
+				expected int32
+			}{
+				{"test_no_throw", 42},
+				{"test_catch_specific", -1},
+				{"test_catch_base", 1},
+				{"test_rethrow", -42},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					res, err := mod.ExportedFunction(tt.name).Call(ctx)
+					require.NoError(t, err)
+					require.Equal(t, tt.expected, api.DecodeI32(res[0]))
+				})
+			}
 		})
 	}
 }
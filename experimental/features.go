@@ -37,3 +37,66 @@ const CoreFeaturesExceptionHandling = api.CoreFeatureSIMD << 4
 //
 // See https://github.com/WebAssembly/function-references for further details.
 const CoreFeaturesTypedFunctionReferences = api.CoreFeatureSIMD << 5
+
+// CoreFeaturesMultiMemory enables multiple memories ("multi-memory").
+//
+// # Notes
+//
+//   - Modules may import and define any number of memories, and every memory
+//     instruction can address a memory by index.
+//   - api.Module Memory still returns the memory at index zero. Use
+//     api.Module ExportedMemory to access other memories.
+//
+// See https://github.com/WebAssembly/multi-memory for further details.
+const CoreFeaturesMultiMemory = api.CoreFeatureSIMD << 6
+
+// CoreFeaturesMemory64 enables 64-bit memories ("memory64").
+//
+// # Notes
+//
+//   - Memories declared with the i64 address type are addressed with i64
+//     values, and their memarg offsets, memory.size and memory.grow use i64.
+//   - The maximum number of pages of a 64-bit memory is bound by
+//     wazero.RuntimeConfig WithMemoryLimitPages, which may exceed 65536 pages
+//     for such memories.
+//   - Host functions can reach the whole memory with the api.Memory methods
+//     suffixed with 64, such as Read64 and Write64.
+//
+// See https://github.com/WebAssembly/memory64 for further details.
+const CoreFeaturesMemory64 = api.CoreFeatureSIMD << 7
+
+// CoreFeaturesRelaxedSIMD enables relaxed SIMD instructions ("relaxed-simd").
+//
+// # Notes
+//
+//   - This requires api.CoreFeatureSIMD to be enabled as well.
+//   - The results of relaxed instructions may differ between the compiler and
+//     the interpreter, or between CPU architectures, when inputs are outside the
+//     ranges for which the proposal defines a single result, for example NaN
+//     inputs to relaxed min/max, or out of range lanes in relaxed swizzle.
+//   - The compiler uses fused multiply-add for relaxed_madd when the CPU supports
+//     it, whereas the interpreter never fuses the multiplication and the addition.
+//
+// See https://github.com/WebAssembly/relaxed-simd for further details.
+const CoreFeaturesRelaxedSIMD = api.CoreFeatureSIMD << 8
+
+// CoreFeaturesGC enables garbage collected struct and array types, i31
+// references, subtyping and casts ("gc").
+//
+// # Notes
+//
+//   - This requires CoreFeaturesTypedFunctionReferences to be enabled as well.
+//   - Only the interpreter supports this feature for now. The compiler fails
+//     to compile modules which use struct or array types or GC instructions.
+//   - Structs and arrays are allocated in a heap owned by the module instance
+//     that allocates them, which is collected when allocations reach a
+//     threshold proportional to the number of live objects.
+//   - References to structs and arrays are opaque handles when returned to the
+//     host, which are only valid while reachable from the Store's module
+//     instances. The collector doesn't see the references held by the host,
+//     including the ones in the payload of an exception returned by a call,
+//     so the host must store them in a global or a table to use them after
+//     the call which returned them.
+//
+// See https://github.com/WebAssembly/gc for further details.
+const CoreFeaturesGC = api.CoreFeatureSIMD << 9
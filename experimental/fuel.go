@@ -0,0 +1,37 @@
+package experimental
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+)
+
+// WithFuel sets the fuel budget of each api.Function call made with the
+// returned context.Context, when fuel metering is enabled with
+// wazero.RuntimeConfig WithFuelMetering. Otherwise, this has no effect.
+//
+// When the budget is exhausted, the call fails with sys.ExitError with the
+// exit code sys.ExitCodeFuelExhausted, and the api.Module is closed.
+//
+// Note: Nested calls made by host functions with the context they are passed
+// share the budget of the call in progress, rather than getting a new one,
+// even from other goroutines. Threads spawned by the call get a budget of
+// their own, if any.
+func WithFuel(ctx context.Context, fuel uint64) context.Context {
+	return context.WithValue(ctx, expctxkeys.FuelKey{}, fuel)
+}
+
+// RemainingFuel returns the fuel left in the api.Function call in progress,
+// or false if it is not limited by WithFuel. This is meant to be called from a
+// host function with the context.Context it is passed.
+func RemainingFuel(ctx context.Context) (uint64, bool) {
+	if m, ok := ctx.Value(expctxkeys.FuelMeterKey{}).(fuelMeter); ok {
+		return m.RemainingFuel(), true
+	}
+	return 0, false
+}
+
+// fuelMeter is implemented by the engines to report the fuel left.
+type fuelMeter interface {
+	RemainingFuel() uint64
+}
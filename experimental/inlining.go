@@ -0,0 +1,30 @@
+package experimental
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+)
+
+// WithInliningBudget enables the compiler to inline the direct calls to the
+// small functions defined in the same module, when the module is compiled
+// with the returned context.Context. Inlining is disabled by default.
+//
+// A function is inlined when its body is at most `budget` bytes long, and it
+// is straight-line code, e.g. a getter, a load wrapper, or a stack pointer
+// helper. Functions are never inlined when function listeners are enabled.
+//
+// Note: Traps in an inlined function are reported in stack traces with the
+// frame of the inlined function, followed by the one of its caller, as if it
+// weren't inlined. For that reason, the compiled code records the source
+// offsets of its instructions when inlining is enabled, even without DWARF.
+func WithInliningBudget(ctx context.Context, budget int) context.Context {
+	return context.WithValue(ctx, expctxkeys.InliningBudget{}, budget)
+}
+
+// GetInliningBudget returns the inlining budget set by WithInliningBudget.
+// Zero means inlining is disabled.
+func GetInliningBudget(ctx context.Context) int {
+	budget, _ := ctx.Value(expctxkeys.InliningBudget{}).(int)
+	return max(budget, 0)
+}
@@ -6,14 +6,30 @@ import (
 	"github.com/tetratelabs/wazero/internal/sock"
 )
 
-// Config configures the host to open TCP sockets and allows guest access to
-// them.
+// Config configures the host to open TCP and UDP sockets and allows guest
+// access to them.
 //
-// Instantiating a module with listeners results in pre-opened sockets
-// associated with file-descriptors numerically after pre-opened files.
+// Instantiating a module with listeners or UDP sockets results in pre-opened
+// sockets associated with file-descriptors numerically after pre-opened
+// files, in the order TCP listeners then UDP sockets.
+//
+// Guests can also open outbound sockets with `sock_open`, and connect or send
+// datagrams to destinations allowed by WithAllowedDestination.
 type Config interface {
 	// WithTCPListener configures the host to set up the given host:port listener.
 	WithTCPListener(host string, port int) Config
+
+	// WithUDPSocket configures the host to set up a UDP socket bound to the
+	// given host:port. Guests receive datagrams from any address, but only
+	// send them to the ones allowed by WithAllowedDestination.
+	WithUDPSocket(host string, port int) Config
+
+	// WithAllowedDestination allows guests to connect, or send datagrams, to
+	// the given host:port. host is an IP address, or a CIDR prefix such as
+	// "10.0.0.0/8", and port zero allows any port.
+	//
+	// Without any allowed destination, guests cannot open sockets.
+	WithAllowedDestination(host string, port int) Config
 }
 
 // NewConfig returns a Config for module instantiation.
@@ -33,9 +49,21 @@ func (c *internalSockConfig) WithTCPListener(host string, port int) Config {
 	return &internalSockConfig{cNew}
 }
 
+// WithUDPSocket implements Config.WithUDPSocket
+func (c *internalSockConfig) WithUDPSocket(host string, port int) Config {
+	cNew := c.c.WithUDPSocket(host, port)
+	return &internalSockConfig{cNew}
+}
+
+// WithAllowedDestination implements Config.WithAllowedDestination
+func (c *internalSockConfig) WithAllowedDestination(host string, port int) Config {
+	cNew := c.c.WithAllowedDestination(host, port)
+	return &internalSockConfig{cNew}
+}
+
 // WithConfig registers the given Config into the given context.Context.
 func WithConfig(ctx context.Context, config Config) context.Context {
-	if config, ok := config.(*internalSockConfig); ok && len(config.c.TCPAddresses) > 0 {
+	if config, ok := config.(*internalSockConfig); ok && !config.c.IsEmpty() {
 		return context.WithValue(ctx, sock.ConfigKey{}, config.c)
 	}
 	return ctx
@@ -34,6 +34,16 @@ func TestWithSockConfig(t *testing.T) {
 			sockCfg:  sock.NewConfig().WithTCPListener("", 0),
 			expected: true,
 		},
+		{
+			name:     "decorates with UDP socket",
+			sockCfg:  sock.NewConfig().WithUDPSocket("", 0),
+			expected: true,
+		},
+		{
+			name:     "decorates with allowed destination",
+			sockCfg:  sock.NewConfig().WithAllowedDestination("127.0.0.1", 0),
+			expected: true,
+		},
 	}
 
 	for _, tt := range tests {
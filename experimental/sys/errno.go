@@ -41,6 +41,13 @@ const (
 	ENOTSUP
 	EPERM
 	EROFS
+	EAFNOSUPPORT
+	ECONNREFUSED
+	ENOTCONN
+	EDQUOT
+	EFBIG
+	EMFILE
+	ENOSPC
 
 	// NOTE ENOTCAPABLE is defined in wasip1, but not in POSIX. wasi-libc
 	// converts it to EBADF, ESPIPE or EINVAL depending on the call site.
@@ -92,6 +99,20 @@ func (e Errno) Error() string {
 		return "operation not permitted"
 	case EROFS:
 		return "read-only file system"
+	case EAFNOSUPPORT:
+		return "address family not supported"
+	case ECONNREFUSED:
+		return "connection refused"
+	case ENOTCONN:
+		return "socket is not connected"
+	case EDQUOT:
+		return "disk quota exceeded"
+	case EFBIG:
+		return "file too large"
+	case EMFILE:
+		return "too many open files"
+	case ENOSPC:
+		return "no space left on device"
 	default:
 		return "Errno(" + strconv.Itoa(int(e)) + ")"
 	}
@@ -20,9 +20,9 @@ const (
 )
 
 // Pollable is implemented by custom readers that support polling for
-// readiness. If a custom io.Reader passed to WithStdin implements this
-// interface, poll_oneoff will use it for asynchronous I/O instead of
-// returning "always ready".
+// readiness. If a custom io.Reader passed to WithStdin, or io.Writer passed
+// to WithStdout or WithStderr, implements this interface, poll_oneoff will use
+// it for asynchronous I/O instead of returning "always ready".
 //
 // # Parameters
 //
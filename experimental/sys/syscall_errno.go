@@ -14,14 +14,22 @@ func syscallToErrno(err error) (Errno, bool) {
 		return 0, true
 	case syscall.EACCES:
 		return EACCES, true
+	case syscall.EAFNOSUPPORT:
+		return EAFNOSUPPORT, true
 	case syscall.EAGAIN:
 		return EAGAIN, true
 	case syscall.EBADF:
 		return EBADF, true
+	case syscall.ECONNREFUSED:
+		return ECONNREFUSED, true
+	case syscall.EDQUOT:
+		return EDQUOT, true
 	case syscall.EEXIST:
 		return EEXIST, true
 	case syscall.EFAULT:
 		return EFAULT, true
+	case syscall.EFBIG:
+		return EFBIG, true
 	case syscall.EINTR:
 		return EINTR, true
 	case syscall.EINVAL:
@@ -32,12 +40,18 @@ func syscallToErrno(err error) (Errno, bool) {
 		return EISDIR, true
 	case syscall.ELOOP:
 		return ELOOP, true
+	case syscall.EMFILE:
+		return EMFILE, true
 	case syscall.ENAMETOOLONG:
 		return ENAMETOOLONG, true
 	case syscall.ENOENT:
 		return ENOENT, true
+	case syscall.ENOSPC:
+		return ENOSPC, true
 	case syscall.ENOSYS:
 		return ENOSYS, true
+	case syscall.ENOTCONN:
+		return ENOTCONN, true
 	case syscall.ENOTDIR:
 		return ENOTDIR, true
 	case syscall.ERANGE:
@@ -64,14 +78,22 @@ func (e Errno) Unwrap() error {
 		return nil
 	case EACCES:
 		return syscall.EACCES
+	case EAFNOSUPPORT:
+		return syscall.EAFNOSUPPORT
 	case EAGAIN:
 		return syscall.EAGAIN
 	case EBADF:
 		return syscall.EBADF
+	case ECONNREFUSED:
+		return syscall.ECONNREFUSED
+	case EDQUOT:
+		return syscall.EDQUOT
 	case EEXIST:
 		return syscall.EEXIST
 	case EFAULT:
 		return syscall.EFAULT
+	case EFBIG:
+		return syscall.EFBIG
 	case EINTR:
 		return syscall.EINTR
 	case EINVAL:
@@ -82,12 +104,18 @@ func (e Errno) Unwrap() error {
 		return syscall.EISDIR
 	case ELOOP:
 		return syscall.ELOOP
+	case EMFILE:
+		return syscall.EMFILE
 	case ENAMETOOLONG:
 		return syscall.ENAMETOOLONG
 	case ENOENT:
 		return syscall.ENOENT
+	case ENOSPC:
+		return syscall.ENOSPC
 	case ENOSYS:
 		return syscall.ENOSYS
+	case ENOTCONN:
+		return syscall.ENOTCONN
 	case ENOTDIR:
 		return syscall.ENOTDIR
 	case ENOTEMPTY:
@@ -21,6 +21,12 @@ func errorToErrno(err error) Errno {
 			// POSIX read and write functions expect EBADF, not EACCES when not
 			// open for reading or writing.
 			return EBADF
+		case windows.WSAEAFNOSUPPORT:
+			return EAFNOSUPPORT
+		case windows.WSAECONNREFUSED:
+			return ECONNREFUSED
+		case windows.WSAENOTCONN:
+			return ENOTCONN
 		case windows.ERROR_PRIVILEGE_NOT_HELD:
 			return EPERM
 		case windows.ERROR_NEGATIVE_SEEK, windows.ERROR_NOT_A_REPARSE_POINT, windows.ERROR_INVALID_NAME:
@@ -13,4 +13,23 @@ type FSConfig interface {
 	//
 	// This is an alternative to WithFSMount, allowing more features.
 	WithSysFSMount(fs experimentalsys.FS, guestPath string) wazero.FSConfig
+
+	// WithMaxOpenFiles limits the count of file descriptors a module instance
+	// can have open at the same time, including stdio and pre-opened mounts
+	// or sockets. Defaults to zero, which is no limit.
+	//
+	// Functions which open a file or socket when the limit is reached return
+	// sys.EMFILE, e.g. `path_open` in WASI. Mounts and sockets pre-opened at
+	// instantiation are never refused.
+	//
+	// Note: Use QuotaFS to limit the contents of a mount instead.
+	WithMaxOpenFiles(maxOpenFiles uint32) wazero.FSConfig
+
+	// WithAuditHook calls the hook around each function of all mounted
+	// filesystems, e.g. to stream an audit log, or to deny some paths.
+	// Defaults to nil, which disables auditing.
+	//
+	// Each mount is wrapped in an AuditFS, whose Mount is its guest path
+	// without any trailing slash, e.g. "/" or "/tmp".
+	WithAuditHook(hook AuditHook) wazero.FSConfig
 }
@@ -1,10 +1,14 @@
 package sysfs_test
 
 import (
+	"fmt"
 	"io/fs"
+	"log"
+	"strings"
 	"testing/fstest"
 
 	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental/sys"
 	"github.com/tetratelabs/wazero/experimental/sysfs"
 )
 
@@ -24,6 +28,39 @@ func ExampleAdaptFS() {
 	// Output:
 }
 
+// auditLog prints each event, and denies deleting files under "/etc".
+type auditLog struct{}
+
+// Before implements sysfs.AuditHook.Before
+func (auditLog) Before(e sysfs.AuditEvent) sys.Errno {
+	if e.Op == sysfs.AuditUnlink && strings.HasPrefix(e.GuestPath(), "/etc/") {
+		return sys.EPERM
+	}
+	return 0
+}
+
+// After implements sysfs.AuditHook.After
+func (auditLog) After(e sysfs.AuditEvent) {
+	fmt.Println(e.Op, e.GuestPath(), e.Errno)
+}
+
+// This example shows how to audit functions of all mounted filesystems.
+func ExampleAuditHook() {
+	moduleConfig = wazero.NewModuleConfig().
+		WithFSConfig(wazero.NewFSConfig().(sysfs.FSConfig).
+			WithSysFSMount(sysfs.MemFS(), "/").(sysfs.FSConfig).
+			WithAuditHook(auditLog{}))
+
+	// The guest would call functions such as these:
+	root := &sysfs.AuditFS{FS: sysfs.MemFS(), Mount: "/", Hook: auditLog{}}
+	_ = root.Mkdir("etc", 0o755)
+	_ = root.Unlink("etc/passwd")
+
+	// Output:
+	// Mkdir /etc success
+	// Unlink /etc/passwd operation not permitted
+}
+
 // This example shows how to configure a sysfs.DirFS
 func ExampleDirFS() {
 	root := sysfs.DirFS(".")
@@ -34,6 +71,52 @@ func ExampleDirFS() {
 	// Output:
 }
 
+// This example shows how to configure a sysfs.MemFS
+func ExampleMemFS() {
+	root := sysfs.MemFS()
+
+	moduleConfig = wazero.NewModuleConfig().
+		WithFSConfig(wazero.NewFSConfig().(sysfs.FSConfig).WithSysFSMount(root, "/"))
+
+	// Output:
+}
+
+// This example shows how to configure a sysfs.OverlayFS
+func ExampleOverlayFS() {
+	root := &sysfs.OverlayFS{Lower: sysfs.DirFS("."), Upper: sysfs.MemFS()}
+
+	moduleConfig = wazero.NewModuleConfig().
+		WithFSConfig(wazero.NewFSConfig().(sysfs.FSConfig).WithSysFSMount(root, "/"))
+
+	// After running the guest, list the files it changed.
+	changes, errno := root.Changes()
+	if errno != 0 {
+		log.Panicln(errno)
+	}
+	for _, c := range changes {
+		fmt.Println(c.Kind, c.Path)
+	}
+
+	// Output:
+}
+
+// This example shows how to configure a sysfs.QuotaFS
+func ExampleQuotaFS() {
+	root := &sysfs.QuotaFS{
+		FS:              sysfs.DirFS("."),
+		MaxBytesWritten: 64 << 20,
+		MaxFileSize:     16 << 20,
+		MaxFilesCreated: 1000,
+	}
+
+	moduleConfig = wazero.NewModuleConfig().
+		WithFSConfig(wazero.NewFSConfig().(sysfs.FSConfig).
+			WithSysFSMount(root, "/").(sysfs.FSConfig).
+			WithMaxOpenFiles(256))
+
+	// Output:
+}
+
 // This example shows how to configure a sysfs.ReadFS
 func ExampleReadFS() {
 	root := sysfs.DirFS(".")
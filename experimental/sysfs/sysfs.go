@@ -21,11 +21,105 @@ import (
 // enforce flag behavior.
 type AdaptFS = sysfs.AdaptFS
 
+// AuditFS calls a Hook around each function of an existing sys.FS, which
+// receives a structured AuditEvent. For example, this can stream an audit log
+// of paths a guest opened, renamed or deleted, or deny some by policy.
+//
+// Functions of files opened, such as writes, are not audited, except paths
+// they pass to sys.FS. Use FSConfig.WithAuditHook to audit all mounts.
+type AuditFS = sysfs.AuditFS
+
+// AuditHook is called by AuditFS around each function of a sys.FS.
+//
+// Note: Hooks are called synchronously, and may be called concurrently by
+// different module instances. Implementations should not block.
+type AuditHook = sysfs.AuditHook
+
+// AuditEvent is a call to a function of an AuditFS.
+type AuditEvent = sysfs.AuditEvent
+
+// AuditOp is the sys.FS function of an AuditEvent.
+type AuditOp = sysfs.AuditOp
+
+const (
+	AuditOpenFile = sysfs.AuditOpenFile
+	AuditLstat    = sysfs.AuditLstat
+	AuditStat     = sysfs.AuditStat
+	AuditMkdir    = sysfs.AuditMkdir
+	AuditChmod    = sysfs.AuditChmod
+	AuditRename   = sysfs.AuditRename
+	AuditRmdir    = sysfs.AuditRmdir
+	AuditUnlink   = sysfs.AuditUnlink
+	AuditLink     = sysfs.AuditLink
+	AuditSymlink  = sysfs.AuditSymlink
+	AuditReadlink = sysfs.AuditReadlink
+	AuditUtimens  = sysfs.AuditUtimens
+)
+
 // DirFS is like os.DirFS except it returns sys.FS, which has more features.
 func DirFS(dir string) experimentalsys.FS {
 	return sysfs.DirFS(dir)
 }
 
+// MemFS returns a new sys.FS which only exists in memory, starting with an
+// empty root directory. This is useful for tests, or to give each guest a
+// writable sandbox which never touches the host file system.
+//
+// All functions of sys.FS are supported, including symbolic and hard links.
+// Files have unique inodes, and timestamps are updated like POSIX.
+//
+// Note: Permissions are recorded, but not enforced, as if the guest was the
+// owner of all files with elevated privileges.
+func MemFS() experimentalsys.FS {
+	return sysfs.MemFS()
+}
+
+// OverlayFS is a copy-on-write sys.FS, which merges a read-only Lower layer,
+// such as a DirFS, with a writable Upper one, such as a MemFS. This allows a
+// guest to change files it can read, without the host seeing those changes.
+//
+// Files are copied to Upper before they are written, and deleted paths are
+// hidden from Lower. Symbolic links resolve across both layers. Lower is
+// never written, so after the guest exits, Upper can be inspected, or
+// discarded. Use Changes to list what the guest changed.
+//
+// Note: The zero value is ready to use once Lower and Upper are set. Neither
+// layer should be changed by anything else while the OverlayFS is in use.
+type OverlayFS = sysfs.OverlayFS
+
+// Change is a path changed in an OverlayFS, as returned by Changes.
+type Change = sysfs.Change
+
+// ChangeKind is the kind of Change.
+type ChangeKind = sysfs.ChangeKind
+
+const (
+	// ChangeAdded is a path which doesn't exist in the lower layer.
+	ChangeAdded = sysfs.ChangeAdded
+	// ChangeModified is a path which exists in both layers.
+	ChangeModified = sysfs.ChangeModified
+	// ChangeDeleted is a path which exists in the lower layer, but was
+	// deleted.
+	ChangeDeleted = sysfs.ChangeDeleted
+)
+
+// QuotaFS limits writes to an existing sys.FS, so that a guest with a
+// writable mount can't fill the host disk. Each limit is disabled when zero:
+//
+//   - MaxBytesWritten limits bytes written to all files, returning
+//     sys.ENOSPC. Growing a file with Truncate counts as writing zeros.
+//   - MaxFileSize limits the size of any file written, returning sys.EFBIG.
+//   - MaxFilesCreated limits files, directories and links created, returning
+//     sys.EDQUOT.
+//
+// Like POSIX, a write which only partially fits is short, and the next one
+// returns the error.
+//
+// Note: Usage is counted since the QuotaFS was made, and isn't given back
+// when files are deleted. It is shared by all module instances which mount
+// it, so configure a new QuotaFS per module instance to limit each one.
+type QuotaFS = sysfs.QuotaFS
+
 // ReadFS is used to mask an existing sys.FS for reads. Notably, this allows
 // the CLI to do read-only mounts of directories the host user can write, but
 // doesn't want the guest wasm to. For example, Python libraries shouldn't be
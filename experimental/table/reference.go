@@ -0,0 +1,35 @@
+package table
+
+import (
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// FunctionReference returns the funcref of the function exported by the given
+// api.Module under the given name, or false if there is no such function.
+//
+// The result can be stored in a funcref api.Table with Set, e.g. to install a
+// host function as a callback into the table of a Wasm module. Unlike
+// api.Module ExportedFunction, this supports modules built with
+// wazero.HostModuleBuilder.
+func FunctionReference(module api.Module, name string) (uint64, bool) {
+	m := moduleInstance(module)
+	if m == nil {
+		return 0, false
+	}
+	exp, ok := m.Exports[name]
+	if !ok || exp.Type != wasm.ExternTypeFunc {
+		return 0, false
+	}
+	return uint64(m.Engine.FunctionInstanceReference(exp.Index)), true
+}
+
+func moduleInstance(module api.Module) *wasm.ModuleInstance {
+	switch m := module.(type) {
+	case *wasm.ModuleInstance:
+		return m
+	case interface{ Unwrap() api.Module }: // host module instance.
+		return moduleInstance(m.Unwrap())
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package table_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/table"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+func TestFunctionReference(t *testing.T) {
+	const i32 = wasm.ValueTypeI32
+	// The guest calls the functions in the table it imports from the host,
+	// which it re-exports, and exports a table of its own.
+	guestWasm := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+		},
+		ImportSection: []wasm.Import{
+			{Module: "env", Name: "table", Type: wasm.ExternTypeTable, DescTable: wasm.Table{Type: wasm.RefTypeFuncref}},
+		},
+		ImportTableCount: 1,
+		TableSection:     []wasm.Table{{Type: wasm.RefTypeExternref, Min: 1}},
+		FunctionSection:  []wasm.Index{1},
+		CodeSection: []wasm.Code{{Body: []byte{
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCallIndirect, 0, 0,
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []wasm.Export{
+			{Name: "call", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "reexported", Type: wasm.ExternTypeTable, Index: 0},
+			{Name: "own", Type: wasm.ExternTypeTable, Index: 1},
+		},
+	})
+
+	for _, tc := range []struct {
+		name   string
+		config wazero.RuntimeConfig
+	}{
+		{name: "interpreter", config: wazero.NewRuntimeConfigInterpreter()},
+		{name: "default", config: wazero.NewRuntimeConfig()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := wazero.NewRuntimeWithConfig(ctx, tc.config)
+			defer r.Close(ctx)
+
+			host, err := r.NewHostModuleBuilder("env").
+				NewFunctionBuilder().
+				WithFunc(func(ctx context.Context, v uint32) uint32 { return v * 2 }).
+				Export("double").
+				ExportTable("table", api.ValueTypeFuncref, 1, math.MaxUint32).
+				Instantiate(ctx)
+			require.NoError(t, err)
+
+			_, ok := table.FunctionReference(host, "table")
+			require.False(t, ok)
+			_, ok = table.FunctionReference(host, "missing")
+			require.False(t, ok)
+			double, ok := table.FunctionReference(host, "double")
+			require.True(t, ok)
+
+			hostTable := host.ExportedTable("table")
+			require.NotNil(t, hostTable)
+			require.Equal(t, uint32(1), hostTable.Size())
+			require.True(t, hostTable.Set(0, double))
+
+			mod, err := r.Instantiate(ctx, guestWasm)
+			require.NoError(t, err)
+			call := mod.ExportedFunction("call")
+
+			res, err := call.Call(ctx, 0, 21)
+			require.NoError(t, err)
+			require.Equal(t, uint64(42), res[0])
+
+			t.Run("re-exported", func(t *testing.T) {
+				reexported := mod.ExportedTable("reexported")
+				require.Equal(t, hostTable.Definition(), reexported.Definition())
+				v, ok := reexported.Get(0)
+				require.True(t, ok)
+				require.Equal(t, double, v)
+
+				prev, ok := reexported.Grow(1, double)
+				require.True(t, ok)
+				require.Equal(t, uint32(1), prev)
+				require.Equal(t, uint32(2), hostTable.Size())
+
+				res, err := call.Call(ctx, 1, 5)
+				require.NoError(t, err)
+				require.Equal(t, uint64(10), res[0])
+
+				require.True(t, reexported.Set(1, 0))
+				_, err = call.Call(ctx, 1, 5)
+				require.ErrorIs(t, err, wasmruntime.ErrRuntimeInvalidTableAccess)
+				_, err = call.Call(ctx, 2, 5)
+				require.ErrorIs(t, err, wasmruntime.ErrRuntimeInvalidTableAccess)
+			})
+
+			t.Run("externref", func(t *testing.T) {
+				own := mod.ExportedTable("own")
+				def := own.Definition()
+				require.Equal(t, api.ValueTypeExternref, def.Type())
+				require.Equal(t, []string{"own"}, def.ExportNames())
+
+				v, ok := own.Get(0)
+				require.True(t, ok)
+				require.Equal(t, uint64(0), v)
+				require.True(t, own.Set(0, api.EncodeExternref(123)))
+				v, _ = own.Get(0)
+				require.Equal(t, uintptr(123), api.DecodeExternref(v))
+				_, ok = own.Get(1)
+				require.False(t, ok)
+			})
+
+			defs := mod.ExportedTableDefinitions()
+			require.Equal(t, 2, len(defs))
+			require.Equal(t, api.ValueTypeFuncref, defs["reexported"].Type())
+			require.Equal(t, api.ValueTypeExternref, defs["own"].Type())
+			require.Nil(t, mod.ExportedTable("call"))
+		})
+	}
+}
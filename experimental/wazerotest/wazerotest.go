@@ -99,6 +99,20 @@ func (m *Module) ExportedMemoryDefinitions() map[string]api.MemoryDefinition {
 	return m.exportedMemoryDefinitions
 }
 
+// ExportedTable implements the same method as documented on api.Module.
+//
+// Tables are not supported, so this always returns nil.
+func (m *Module) ExportedTable(name string) api.Table {
+	return nil
+}
+
+// ExportedTableDefinitions implements the same method as documented on api.Module.
+//
+// Tables are not supported, so this always returns an empty map.
+func (m *Module) ExportedTableDefinitions() map[string]api.TableDefinition {
+	return map[string]api.TableDefinition{}
+}
+
 // ExportedGlobal implements the same method as documented on api.Module.
 func (m *Module) ExportedGlobal(name string) api.Global {
 	m.once.Do(m.initialize)
@@ -525,7 +539,15 @@ func (m *Memory) Grow(deltaPages uint32) (previousPages uint32, ok bool) {
 	return previousPages, true
 }
 
+func (m *Memory) Size64() uint64 {
+	return uint64(len(m.Bytes))
+}
+
 func (m *Memory) ReadByte(offset uint32) (byte, bool) {
+	return m.ReadByte64(uint64(offset))
+}
+
+func (m *Memory) ReadByte64(offset uint64) (byte, bool) {
 	if m.isOutOfRange(offset, 1) {
 		return 0, false
 	}
@@ -533,6 +555,10 @@ func (m *Memory) ReadByte(offset uint32) (byte, bool) {
 }
 
 func (m *Memory) ReadUint16Le(offset uint32) (uint16, bool) {
+	return m.ReadUint16Le64(uint64(offset))
+}
+
+func (m *Memory) ReadUint16Le64(offset uint64) (uint16, bool) {
 	if m.isOutOfRange(offset, 2) {
 		return 0, false
 	}
@@ -540,6 +566,10 @@ func (m *Memory) ReadUint16Le(offset uint32) (uint16, bool) {
 }
 
 func (m *Memory) ReadUint32Le(offset uint32) (uint32, bool) {
+	return m.ReadUint32Le64(uint64(offset))
+}
+
+func (m *Memory) ReadUint32Le64(offset uint64) (uint32, bool) {
 	if m.isOutOfRange(offset, 4) {
 		return 0, false
 	}
@@ -547,6 +577,10 @@ func (m *Memory) ReadUint32Le(offset uint32) (uint32, bool) {
 }
 
 func (m *Memory) ReadUint64Le(offset uint32) (uint64, bool) {
+	return m.ReadUint64Le64(uint64(offset))
+}
+
+func (m *Memory) ReadUint64Le64(offset uint64) (uint64, bool) {
 	if m.isOutOfRange(offset, 8) {
 		return 0, false
 	}
@@ -554,16 +588,28 @@ func (m *Memory) ReadUint64Le(offset uint32) (uint64, bool) {
 }
 
 func (m *Memory) ReadFloat32Le(offset uint32) (float32, bool) {
-	v, ok := m.ReadUint32Le(offset)
+	return m.ReadFloat32Le64(uint64(offset))
+}
+
+func (m *Memory) ReadFloat32Le64(offset uint64) (float32, bool) {
+	v, ok := m.ReadUint32Le64(offset)
 	return math.Float32frombits(v), ok
 }
 
 func (m *Memory) ReadFloat64Le(offset uint32) (float64, bool) {
-	v, ok := m.ReadUint64Le(offset)
+	return m.ReadFloat64Le64(uint64(offset))
+}
+
+func (m *Memory) ReadFloat64Le64(offset uint64) (float64, bool) {
+	v, ok := m.ReadUint64Le64(offset)
 	return math.Float64frombits(v), ok
 }
 
 func (m *Memory) Read(offset, length uint32) ([]byte, bool) {
+	return m.Read64(uint64(offset), uint64(length))
+}
+
+func (m *Memory) Read64(offset, length uint64) ([]byte, bool) {
 	if m.isOutOfRange(offset, length) {
 		return nil, false
 	}
@@ -571,6 +617,10 @@ func (m *Memory) Read(offset, length uint32) ([]byte, bool) {
 }
 
 func (m *Memory) WriteByte(offset uint32, value byte) bool {
+	return m.WriteByte64(uint64(offset), value)
+}
+
+func (m *Memory) WriteByte64(offset uint64, value byte) bool {
 	if m.isOutOfRange(offset, 1) {
 		return false
 	}
@@ -579,6 +629,10 @@ func (m *Memory) WriteByte(offset uint32, value byte) bool {
 }
 
 func (m *Memory) WriteUint16Le(offset uint32, value uint16) bool {
+	return m.WriteUint16Le64(uint64(offset), value)
+}
+
+func (m *Memory) WriteUint16Le64(offset uint64, value uint16) bool {
 	if m.isOutOfRange(offset, 2) {
 		return false
 	}
@@ -587,6 +641,10 @@ func (m *Memory) WriteUint16Le(offset uint32, value uint16) bool {
 }
 
 func (m *Memory) WriteUint32Le(offset uint32, value uint32) bool {
+	return m.WriteUint32Le64(uint64(offset), value)
+}
+
+func (m *Memory) WriteUint32Le64(offset uint64, value uint32) bool {
 	if m.isOutOfRange(offset, 4) {
 		return false
 	}
@@ -595,7 +653,11 @@ func (m *Memory) WriteUint32Le(offset uint32, value uint32) bool {
 }
 
 func (m *Memory) WriteUint64Le(offset uint32, value uint64) bool {
-	if m.isOutOfRange(offset, 4) {
+	return m.WriteUint64Le64(uint64(offset), value)
+}
+
+func (m *Memory) WriteUint64Le64(offset uint64, value uint64) bool {
+	if m.isOutOfRange(offset, 8) {
 		return false
 	}
 	binary.LittleEndian.PutUint64(m.Bytes[offset:], value)
@@ -603,15 +665,27 @@ func (m *Memory) WriteUint64Le(offset uint32, value uint64) bool {
 }
 
 func (m *Memory) WriteFloat32Le(offset uint32, value float32) bool {
-	return m.WriteUint32Le(offset, math.Float32bits(value))
+	return m.WriteUint32Le64(uint64(offset), math.Float32bits(value))
+}
+
+func (m *Memory) WriteFloat32Le64(offset uint64, value float32) bool {
+	return m.WriteUint32Le64(offset, math.Float32bits(value))
 }
 
 func (m *Memory) WriteFloat64Le(offset uint32, value float64) bool {
-	return m.WriteUint64Le(offset, math.Float64bits(value))
+	return m.WriteUint64Le64(uint64(offset), math.Float64bits(value))
+}
+
+func (m *Memory) WriteFloat64Le64(offset uint64, value float64) bool {
+	return m.WriteUint64Le64(offset, math.Float64bits(value))
 }
 
 func (m *Memory) Write(offset uint32, value []byte) bool {
-	if m.isOutOfRange(offset, uint32(len(value))) {
+	return m.Write64(uint64(offset), value)
+}
+
+func (m *Memory) Write64(offset uint64, value []byte) bool {
+	if m.isOutOfRange(offset, uint64(len(value))) {
 		return false
 	}
 	copy(m.Bytes[offset:], value)
@@ -619,15 +693,19 @@ func (m *Memory) Write(offset uint32, value []byte) bool {
 }
 
 func (m *Memory) WriteString(offset uint32, value string) bool {
-	if m.isOutOfRange(offset, uint32(len(value))) {
+	return m.WriteString64(uint64(offset), value)
+}
+
+func (m *Memory) WriteString64(offset uint64, value string) bool {
+	if m.isOutOfRange(offset, uint64(len(value))) {
 		return false
 	}
 	copy(m.Bytes[offset:], value)
 	return true
 }
 
-func (m *Memory) isOutOfRange(offset, length uint32) bool {
-	size := m.Size()
+func (m *Memory) isOutOfRange(offset, length uint64) bool {
+	size := m.Size64()
 	return offset >= size || length > size || offset > (size-length)
 }
 
@@ -140,6 +140,11 @@ type fsConfig struct {
 	// guestPathToFS are the normalized paths to the currently configured
 	// filesystems, used for de-duplicating.
 	guestPathToFS map[string]int
+	// maxOpenFiles is the maximum count of open file descriptors, or zero for
+	// no limit.
+	maxOpenFiles uint32
+	// auditHook is called around functions of all filesystems, when not nil.
+	auditHook sysfs.AuditHook
 }
 
 // NewFSConfig returns a FSConfig that can be used for configuring module instantiation.
@@ -198,6 +203,20 @@ func (c *fsConfig) WithSysFSMount(fs experimentalsys.FS, guestPath string) FSCon
 	return ret
 }
 
+// WithMaxOpenFiles implements sysfs.FSConfig
+func (c *fsConfig) WithMaxOpenFiles(maxOpenFiles uint32) FSConfig {
+	ret := c.clone()
+	ret.maxOpenFiles = maxOpenFiles
+	return ret
+}
+
+// WithAuditHook implements sysfs.FSConfig
+func (c *fsConfig) WithAuditHook(hook sysfs.AuditHook) FSConfig {
+	ret := c.clone()
+	ret.auditHook = hook
+	return ret
+}
+
 // preopens returns the possible nil index-correlated preopened filesystems
 // with guest paths.
 func (c *fsConfig) preopens() ([]experimentalsys.FS, []string) {
@@ -209,5 +228,11 @@ func (c *fsConfig) preopens() ([]experimentalsys.FS, []string) {
 	copy(fs, c.fs)
 	guestPaths := make([]string, len(c.guestPaths))
 	copy(guestPaths, c.guestPaths)
+	if c.auditHook != nil {
+		for i, guestPath := range guestPaths {
+			mount := "/" + sys.StripPrefixesAndTrailingSlash(guestPath)
+			fs[i] = &sysfs.AuditFS{FS: fs[i], Mount: mount, Hook: c.auditHook}
+		}
+	}
 	return fs, guestPaths
 }
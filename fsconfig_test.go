@@ -84,3 +84,36 @@ func TestFSConfig_clone(t *testing.T) {
 	// Ensure the guestPaths slice is not shared
 	require.Zero(t, len(cloned.guestPaths))
 }
+
+func TestFSConfig_WithMaxOpenFiles(t *testing.T) {
+	base := NewFSConfig().(*fsConfig)
+
+	fc := base.WithMaxOpenFiles(10).(*fsConfig)
+
+	require.Equal(t, uint32(10), fc.maxOpenFiles)
+	require.Zero(t, base.maxOpenFiles) // immutable
+}
+
+type noopAuditHook struct{}
+
+func (noopAuditHook) Before(sysfs.AuditEvent) sys.Errno { return 0 }
+
+func (noopAuditHook) After(sysfs.AuditEvent) {}
+
+func TestFSConfig_WithAuditHook(t *testing.T) {
+	base := NewFSConfig().WithDirMount(".", "/").WithDirMount("/tmp", "/tmp/")
+	hook := noopAuditHook{}
+
+	fc := base.(*fsConfig).WithAuditHook(hook).(*fsConfig)
+
+	// Each mount is wrapped with its guest path.
+	fs, guestPaths := fc.preopens()
+	require.Equal(t, []sys.FS{
+		&sysfs.AuditFS{FS: sysfs.DirFS("."), Mount: "/", Hook: hook},
+		&sysfs.AuditFS{FS: sysfs.DirFS("/tmp"), Mount: "/tmp", Hook: hook},
+	}, fs)
+	require.Equal(t, []string{"/", "/tmp/"}, guestPaths)
+
+	// immutable
+	require.Nil(t, base.(*fsConfig).auditHook)
+}
@@ -160,8 +160,10 @@ func fdDatasyncFn(_ context.Context, mod api.Module, params []uint64) experiment
 //   - fs_filetype 1 byte: the file type
 //   - fs_flags 2 bytes: the file descriptor flag
 //   - 5 pad bytes
-//   - fs_right_base 8 bytes: ignored as rights were removed from WASI.
-//   - fs_right_inheriting 8 bytes: ignored as rights were removed from WASI.
+//   - fs_right_base 8 bytes: rights of the file descriptor, only enforced
+//     by a Policy, as rights were removed from WASI.
+//   - fs_right_inheriting 8 bytes: maximum rights of file descriptors opened
+//     from this one, only enforced by a Policy.
 //
 // For example, with a file corresponding with `fd` was a directory (=3) opened
 // with `fd_read` right (=1) and no fs_flags (=0), parameter resultFdstat=1,
@@ -210,27 +212,32 @@ func fdFdstatGetFn(_ context.Context, mod api.Module, params []uint64) experimen
 		fdflags |= wasip1.FD_NONBLOCK
 	}
 
-	var fsRightsBase uint32
-	var fsRightsInheriting uint32
 	fileType := getExtendedWasiFiletype(f.File, st.Mode)
+	fsRightsBase, fsRightsInheriting := defaultRights(fileType)
+	if r := f.Rights; r != nil { // restricted by a Policy
+		fsRightsBase, fsRightsInheriting = uint32(r.Base), uint32(r.Inheriting)
+	}
+
+	writeFdstat(buf, fileType, fdflags, fsRightsBase, fsRightsInheriting)
+	return 0
+}
 
+// defaultRights returns the rights of a file descriptor of the file type,
+// unless restricted by a Policy.
+func defaultRights(fileType uint8) (base, inheriting uint32) {
 	switch fileType {
 	case wasip1.FILETYPE_DIRECTORY:
 		// To satisfy wasi-testsuite, we must advertise that directories cannot
 		// be given seek permission (RIGHT_FD_SEEK).
-		fsRightsBase = dirRightsBase
-		fsRightsInheriting = fileRightsBase | dirRightsBase
+		return dirRightsBase, fileRightsBase | dirRightsBase
 	case wasip1.FILETYPE_CHARACTER_DEVICE:
 		// According to wasi-libc,
 		// > A tty is a character device that we can't seek or tell on.
 		// See https://github.com/WebAssembly/wasi-libc/blob/a6f871343313220b76009827ed0153586361c0d5/libc-bottom-half/sources/isatty.c#L13-L18
-		fsRightsBase = fileRightsBase &^ wasip1.RIGHT_FD_SEEK &^ wasip1.RIGHT_FD_TELL
+		return fileRightsBase &^ wasip1.RIGHT_FD_SEEK &^ wasip1.RIGHT_FD_TELL, 0
 	default:
-		fsRightsBase = fileRightsBase
+		return fileRightsBase, 0
 	}
-
-	writeFdstat(buf, fileType, fdflags, fsRightsBase, fsRightsInheriting)
-	return 0
 }
 
 // isPreopenedStdio returns true if the FD is sys.FdStdin, sys.FdStdout or
@@ -424,6 +431,10 @@ func getExtendedWasiFiletype(file experimentalsys.File, fm fs.FileMode) (ftype u
 			ftype = wasip1.FILETYPE_SOCKET_STREAM
 		} else if _, ok = file.(socketapi.TCPConn); ok {
 			ftype = wasip1.FILETYPE_SOCKET_STREAM
+		} else if _, ok = file.(socketapi.TCPSocket); ok {
+			ftype = wasip1.FILETYPE_SOCKET_STREAM
+		} else if _, ok = file.(socketapi.UDPConn); ok {
+			ftype = wasip1.FILETYPE_SOCKET_DGRAM
 		}
 	}
 	return
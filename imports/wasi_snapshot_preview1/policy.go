@@ -0,0 +1,559 @@
+package wasi_snapshot_preview1
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+	socketapi "github.com/tetratelabs/wazero/internal/sock"
+	"github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/wasip1"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// Rights are the capabilities of a file descriptor, as a bitmask defined by
+// WASI. For example, RightFdRead is the right to invoke fd_read.
+//
+// See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#rights
+type Rights uint64
+
+const (
+	RightFdDatasync           = Rights(wasip1.RIGHT_FD_DATASYNC)
+	RightFdRead               = Rights(wasip1.RIGHT_FD_READ)
+	RightFdSeek               = Rights(wasip1.RIGHT_FD_SEEK)
+	RightFdstatSetFlags       = Rights(wasip1.RIGHT_FDSTAT_SET_FLAGS)
+	RightFdSync               = Rights(wasip1.RIGHT_FD_SYNC)
+	RightFdTell               = Rights(wasip1.RIGHT_FD_TELL)
+	RightFdWrite              = Rights(wasip1.RIGHT_FD_WRITE)
+	RightFdAdvise             = Rights(wasip1.RIGHT_FD_ADVISE)
+	RightFdAllocate           = Rights(wasip1.RIGHT_FD_ALLOCATE)
+	RightPathCreateDirectory  = Rights(wasip1.RIGHT_PATH_CREATE_DIRECTORY)
+	RightPathCreateFile       = Rights(wasip1.RIGHT_PATH_CREATE_FILE)
+	RightPathLinkSource       = Rights(wasip1.RIGHT_PATH_LINK_SOURCE)
+	RightPathLinkTarget       = Rights(wasip1.RIGHT_PATH_LINK_TARGET)
+	RightPathOpen             = Rights(wasip1.RIGHT_PATH_OPEN)
+	RightFdReaddir            = Rights(wasip1.RIGHT_FD_READDIR)
+	RightPathReadlink         = Rights(wasip1.RIGHT_PATH_READLINK)
+	RightPathRenameSource     = Rights(wasip1.RIGHT_PATH_RENAME_SOURCE)
+	RightPathRenameTarget     = Rights(wasip1.RIGHT_PATH_RENAME_TARGET)
+	RightPathFilestatGet      = Rights(wasip1.RIGHT_PATH_FILESTAT_GET)
+	RightPathFilestatSetSize  = Rights(wasip1.RIGHT_PATH_FILESTAT_SET_SIZE)
+	RightPathFilestatSetTimes = Rights(wasip1.RIGHT_PATH_FILESTAT_SET_TIMES)
+	RightFdFilestatGet        = Rights(wasip1.RIGHT_FD_FILESTAT_GET)
+	RightFdFilestatSetSize    = Rights(wasip1.RIGHT_FD_FILESTAT_SET_SIZE)
+	RightFdFilestatSetTimes   = Rights(wasip1.RIGHT_FD_FILESTAT_SET_TIMES)
+	RightPathSymlink          = Rights(wasip1.RIGHT_PATH_SYMLINK)
+	RightPathRemoveDirectory  = Rights(wasip1.RIGHT_PATH_REMOVE_DIRECTORY)
+	RightPathUnlinkFile       = Rights(wasip1.RIGHT_PATH_UNLINK_FILE)
+	RightPollFdReadwrite      = Rights(wasip1.RIGHT_POLL_FD_READWRITE)
+	RightSockShutdown         = Rights(wasip1.RIGHT_SOCK_SHUTDOWN)
+
+	// RightsAll are all rights defined by WASI.
+	RightsAll = RightSockShutdown<<1 - 1
+)
+
+// String implements fmt.Stringer
+func (r Rights) String() string {
+	return wasip1.RightsString(int(r))
+}
+
+// Policy restricts the functions a guest can call and the rights of its file
+// descriptors. Calls not permitted return ErrnoNotcapable.
+//
+// Rights are inherited like in WASI before they were removed: pre-opened
+// directories start with the rights configured by WithPreopenRights, and
+// path_open grants a new file descriptor the rights requested by the guest,
+// limited to the inheriting rights of its directory. Likewise, sock_accept
+// grants a new connection the inheriting rights of its listener. poll_oneoff
+// requires RightPollFdReadwrite of the file descriptors subscribed to.
+// fd_fdstat_set_rights can only drop rights, and fd_fdstat_get reports the
+// current ones.
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+//   - sock_shutdown is only restricted by name, as sockets don't have
+//     RightSockShutdown by default.
+//   - WASIX functions are restricted the same way when the policy is also
+//     passed to the Builder of package wasix. Functions are matched by name,
+//     so WithDeniedFunctions("sock_open") denies it in both modules.
+type Policy interface {
+	// WithAllowedFunctions limits the functions a guest can call to the ones
+	// named, e.g. "fd_read". Defaults to all functions.
+	WithAllowedFunctions(names ...string) Policy
+
+	// WithDeniedFunctions denies the guest calling the functions named, e.g.
+	// "path_unlink_file", even if allowed.
+	WithDeniedFunctions(names ...string) Policy
+
+	// WithPreopenRights limits the rights of the pre-opened directory at the
+	// guest path, e.g. "/tmp". `base` are the rights of the directory itself
+	// and `inheriting` the maximum rights of files opened from it. Defaults
+	// to all rights.
+	//
+	// For example, this makes "/" read-only:
+	//
+	//	readOnly := wasi_snapshot_preview1.RightsAll &^ (wasi_snapshot_preview1.RightFdWrite |
+	//		wasi_snapshot_preview1.RightPathCreateFile | ...)
+	//	policy = policy.WithPreopenRights("/", readOnly, readOnly)
+	WithPreopenRights(guestPath string, base, inheriting Rights) Policy
+
+	// WithSocketRights limits the rights of the pre-opened sockets and the
+	// ones opened by sock_open. `inheriting` are the maximum rights of the
+	// connections accepted from a listener. Defaults to all rights.
+	WithSocketRights(base, inheriting Rights) Policy
+
+	// WithAllowedPaths limits the paths functions such as path_open operate
+	// on to the guest paths, e.g. "/tmp", and the files under them. Defaults
+	// to all paths.
+	//
+	// For example, this denies path_open outside "/tmp/data", even if "/tmp"
+	// is mounted:
+	//
+	//	policy = policy.WithAllowedPaths("/tmp/data")
+	//
+	// Paths are resolved lexically from the pre-opened directory, without
+	// following symbolic links. Deny path_symlink to prevent the guest from
+	// creating one which escapes the allowed paths.
+	WithAllowedPaths(guestPaths ...string) Policy
+}
+
+// NewPolicy returns a Policy which allows all functions and rights.
+func NewPolicy() Policy {
+	return &policy{}
+}
+
+type policy struct {
+	// allowed is nil when all functions are allowed.
+	allowed, denied map[string]struct{}
+
+	// preopenRights are keyed by the guest path without prefixes and
+	// trailing slashes, like sys.StripPrefixesAndTrailingSlash.
+	preopenRights map[string]sys.FileRights
+
+	// socketRights are nil when sockets have all rights.
+	socketRights *sys.FileRights
+
+	// allowedPaths are nil when all paths are allowed. Otherwise, they are
+	// cleaned guest paths, like guestPath.
+	allowedPaths []string
+}
+
+// WithAllowedFunctions implements Policy.WithAllowedFunctions
+func (p *policy) WithAllowedFunctions(names ...string) Policy {
+	ret := p.clone()
+	ret.allowed = map[string]struct{}{}
+	for _, name := range names {
+		ret.allowed[name] = struct{}{}
+	}
+	return ret
+}
+
+// WithDeniedFunctions implements Policy.WithDeniedFunctions
+func (p *policy) WithDeniedFunctions(names ...string) Policy {
+	ret := p.clone()
+	denied := make(map[string]struct{}, len(p.denied)+len(names))
+	for name := range p.denied {
+		denied[name] = struct{}{}
+	}
+	for _, name := range names {
+		denied[name] = struct{}{}
+	}
+	ret.denied = denied
+	return ret
+}
+
+// WithPreopenRights implements Policy.WithPreopenRights
+func (p *policy) WithPreopenRights(guestPath string, base, inheriting Rights) Policy {
+	ret := p.clone()
+	preopenRights := make(map[string]sys.FileRights, len(p.preopenRights)+1)
+	for k, v := range p.preopenRights {
+		preopenRights[k] = v
+	}
+	preopenRights[sys.StripPrefixesAndTrailingSlash(guestPath)] = sys.FileRights{
+		Base:       uint64(base),
+		Inheriting: uint64(inheriting),
+	}
+	ret.preopenRights = preopenRights
+	return ret
+}
+
+// WithSocketRights implements Policy.WithSocketRights
+func (p *policy) WithSocketRights(base, inheriting Rights) Policy {
+	ret := p.clone()
+	ret.socketRights = &sys.FileRights{Base: uint64(base), Inheriting: uint64(inheriting)}
+	return ret
+}
+
+// WithAllowedPaths implements Policy.WithAllowedPaths
+func (p *policy) WithAllowedPaths(guestPaths ...string) Policy {
+	ret := p.clone()
+	ret.allowedPaths = make([]string, 0, len(guestPaths))
+	for _, guestPath := range guestPaths {
+		ret.allowedPaths = append(ret.allowedPaths, "/"+sys.StripPrefixesAndTrailingSlash(guestPath))
+	}
+	return ret
+}
+
+// clone makes a shallow copy of this policy. Maps are copied on write.
+func (p *policy) clone() *policy {
+	ret := *p
+	return &ret
+}
+
+// isAllowed returns true if the guest can call the function named.
+func (p *policy) isAllowed(name string) bool {
+	if _, ok := p.denied[name]; ok {
+		return false
+	} else if p.allowed == nil {
+		return true
+	}
+	_, ok := p.allowed[name]
+	return ok
+}
+
+// fdRight is a right required of the file descriptor at a parameter index.
+type fdRight struct {
+	param  int
+	rights uint32
+
+	// path is the parameter index of a path resolved from the file
+	// descriptor, followed by its length, or zero if there is none.
+	path int
+}
+
+// fdRights are the rights required to call a function by name. Functions not
+// listed here, such as fd_close, don't require rights. The ones listed with
+// zero rights only initialize them, so that fd_fdstat_get reports them.
+var fdRights = map[string][]fdRight{
+	wasip1.FdAdviseName:             {{0, wasip1.RIGHT_FD_ADVISE, 0}},
+	wasip1.FdAllocateName:           {{0, wasip1.RIGHT_FD_ALLOCATE, 0}},
+	wasip1.FdDatasyncName:           {{0, wasip1.RIGHT_FD_DATASYNC, 0}},
+	wasip1.FdFdstatGetName:          {{0, 0, 0}},
+	wasip1.FdFdstatSetFlagsName:     {{0, wasip1.RIGHT_FDSTAT_SET_FLAGS, 0}},
+	wasip1.FdFilestatGetName:        {{0, wasip1.RIGHT_FD_FILESTAT_GET, 0}},
+	wasip1.FdFilestatSetSizeName:    {{0, wasip1.RIGHT_FD_FILESTAT_SET_SIZE, 0}},
+	wasip1.FdFilestatSetTimesName:   {{0, wasip1.RIGHT_FD_FILESTAT_SET_TIMES, 0}},
+	wasip1.FdPreadName:              {{0, wasip1.RIGHT_FD_READ | wasip1.RIGHT_FD_SEEK, 0}},
+	wasip1.FdPwriteName:             {{0, wasip1.RIGHT_FD_WRITE | wasip1.RIGHT_FD_SEEK, 0}},
+	wasip1.FdReadName:               {{0, wasip1.RIGHT_FD_READ, 0}},
+	wasip1.FdReaddirName:            {{0, wasip1.RIGHT_FD_READDIR, 0}},
+	wasip1.FdSyncName:               {{0, wasip1.RIGHT_FD_SYNC, 0}},
+	wasip1.FdTellName:               {{0, wasip1.RIGHT_FD_TELL, 0}},
+	wasip1.FdWriteName:              {{0, wasip1.RIGHT_FD_WRITE, 0}},
+	wasip1.PathCreateDirectoryName:  {{0, wasip1.RIGHT_PATH_CREATE_DIRECTORY, 1}},
+	wasip1.PathFilestatGetName:      {{0, wasip1.RIGHT_PATH_FILESTAT_GET, 2}},
+	wasip1.PathFilestatSetTimesName: {{0, wasip1.RIGHT_PATH_FILESTAT_SET_TIMES, 2}},
+	wasip1.PathLinkName:             {{0, wasip1.RIGHT_PATH_LINK_SOURCE, 2}, {4, wasip1.RIGHT_PATH_LINK_TARGET, 5}},
+	wasip1.PathReadlinkName:         {{0, wasip1.RIGHT_PATH_READLINK, 1}},
+	wasip1.PathRemoveDirectoryName:  {{0, wasip1.RIGHT_PATH_REMOVE_DIRECTORY, 1}},
+	wasip1.PathRenameName:           {{0, wasip1.RIGHT_PATH_RENAME_SOURCE, 1}, {3, wasip1.RIGHT_PATH_RENAME_TARGET, 4}},
+	wasip1.PathSymlinkName:          {{2, wasip1.RIGHT_PATH_SYMLINK, 3}},
+	wasip1.PathUnlinkFileName:       {{0, wasip1.RIGHT_PATH_UNLINK_FILE, 1}},
+	wasip1.SockRecvName:             {{0, wasip1.RIGHT_FD_READ, 0}},
+	wasip1.SockRecvFromName:         {{0, wasip1.RIGHT_FD_READ, 0}},
+	wasip1.SockSendName:             {{0, wasip1.RIGHT_FD_WRITE, 0}},
+	wasip1.SockSendToName:           {{0, wasip1.RIGHT_FD_WRITE, 0}},
+}
+
+// wasixSockAcceptV2Name is the name of sock_accept_v2 in package wasix, which
+// is sock_accept returning the address of the peer, too.
+const wasixSockAcceptV2Name = "sock_accept_v2"
+
+// WrapHostFunc implements wasm.HostFuncWrapper.WrapHostFunc, returning the
+// function which enforces this policy before calling it.
+func (p *policy) WrapHostFunc(fn *wasm.HostFunc) *wasm.HostFunc {
+	name := fn.ExportName
+	if !p.isAllowed(name) {
+		return fn.WithGoModuleFunc(func(_ context.Context, _ api.Module, stack []uint64) {
+			stack[0] = uint64(wasip1.ErrnoNotcapable)
+		})
+	}
+
+	var check func(mod api.Module, params []uint64) wasip1.Errno
+	switch name {
+	case wasip1.FdFdstatSetRightsName:
+		// Replace the stub, as rights are implemented by the policy.
+		return fn.WithGoModuleFunc(p.fdFdstatSetRights)
+	case wasip1.PathOpenName:
+		return fn.WithGoModuleFunc(p.pathOpen(fn.Code.GoFunc.(api.GoModuleFunction)))
+	case wasip1.SockAcceptName, wasixSockAcceptV2Name:
+		return fn.WithGoModuleFunc(p.sockAccept(fn.Code.GoFunc.(api.GoModuleFunction)))
+	case wasip1.FdSeekName:
+		check = p.checkFdSeek
+	case wasip1.PollOneoffName:
+		check = p.checkPollOneoff
+	default:
+		required, ok := fdRights[name]
+		if !ok {
+			return fn
+		}
+		check = func(mod api.Module, params []uint64) wasip1.Errno {
+			for _, r := range required {
+				fd := int32(params[r.param])
+				if errno := p.checkRights(mod, fd, r.rights); errno != 0 {
+					return errno
+				} else if r.path == 0 {
+					continue
+				}
+				if errno := p.checkPath(mod, fd, uint32(params[r.path]), uint32(params[r.path+1])); errno != 0 {
+					return errno
+				}
+			}
+			return 0
+		}
+	}
+
+	inner := fn.Code.GoFunc.(api.GoModuleFunction)
+	return fn.WithGoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+		if errno := check(mod, stack); errno != 0 {
+			stack[0] = uint64(errno)
+		} else {
+			inner.Call(ctx, mod, stack)
+		}
+	})
+}
+
+// checkFdSeek checks RIGHT_FD_SEEK, or only RIGHT_FD_TELL when the call
+// doesn't change the offset.
+func (p *policy) checkFdSeek(mod api.Module, params []uint64) wasip1.Errno {
+	required := wasip1.RIGHT_FD_SEEK
+	if params[1] == 0 && uint32(params[2]) == io.SeekCurrent {
+		required = wasip1.RIGHT_FD_TELL
+	}
+	return p.checkRights(mod, int32(params[0]), required)
+}
+
+// checkPollOneoff checks RIGHT_POLL_FD_READWRITE of the file descriptors of
+// the fd_read and fd_write subscriptions. Invalid subscriptions are left to
+// poll_oneoff to fail.
+func (p *policy) checkPollOneoff(mod api.Module, params []uint64) wasip1.Errno {
+	in, nsubscriptions := uint32(params[0]), uint32(params[2])
+	inBuf, ok := mod.Memory().Read(in, nsubscriptions*48)
+	if !ok {
+		return 0
+	}
+	for i := uint32(0); i < nsubscriptions; i++ {
+		sub := inBuf[i*48:]
+		switch sub[8] { // +8 past userdata
+		case wasip1.EventTypeFdRead, wasip1.EventTypeFdWrite:
+			fd := int32(le.Uint32(sub[16:])) // +8 past userdata +8 contents_offset
+			if errno := p.checkRights(mod, fd, wasip1.RIGHT_POLL_FD_READWRITE); errno != 0 {
+				return errno
+			}
+		}
+	}
+	return 0
+}
+
+// checkRights returns ErrnoNotcapable unless the file descriptor has all the
+// required rights. Invalid file descriptors are left to the function to fail.
+func (p *policy) checkRights(mod api.Module, fd int32, required uint32) wasip1.Errno {
+	f, ok := mod.(*wasm.ModuleInstance).Sys.FS().LookupFile(fd)
+	if !ok {
+		return 0
+	}
+	rights, errno := p.rights(f)
+	if errno != 0 {
+		return errno
+	} else if rights.Base&uint64(required) != uint64(required) {
+		return wasip1.ErrnoNotcapable
+	}
+	return 0
+}
+
+// checkPath returns ErrnoNotcapable unless the path resolved from the
+// directory is allowed by WithAllowedPaths. Invalid file descriptors and
+// memory are left to the function to fail.
+func (p *policy) checkPath(mod api.Module, dirFD int32, pathPtr, pathLen uint32) wasip1.Errno {
+	if p.allowedPaths == nil {
+		return 0
+	}
+	dir, ok := mod.(*wasm.ModuleInstance).Sys.FS().LookupFile(dirFD)
+	if !ok {
+		return 0
+	}
+	guestPath, errno := p.resolvePath(mod, dir, pathPtr, pathLen)
+	if errno != 0 || guestPath == "" {
+		return errno
+	}
+	for _, allowed := range p.allowedPaths {
+		if allowed == "/" || guestPath == allowed || strings.HasPrefix(guestPath, allowed+"/") {
+			return 0
+		}
+	}
+	return wasip1.ErrnoNotcapable
+}
+
+// resolvePath returns the guest path of the path read from memory, relative
+// to the directory. This returns an empty path if the memory is invalid, and
+// ErrnoNotcapable if the guest path of the directory is unknown.
+func (p *policy) resolvePath(mod api.Module, dir *sys.FileEntry, pathPtr, pathLen uint32) (string, wasip1.Errno) {
+	var dirPath string
+	if dir.IsPreopen && dir.FS != nil {
+		dirPath = "/" + sys.StripPrefixesAndTrailingSlash(dir.Name)
+	} else if dir.Rights != nil && dir.Rights.Path != "" {
+		dirPath = dir.Rights.Path
+	} else {
+		return "", wasip1.ErrnoNotcapable
+	}
+	b, ok := mod.Memory().Read(pathPtr, pathLen)
+	if !ok {
+		return "", 0
+	}
+	return path.Join(dirPath, string(b)), 0
+}
+
+// rights returns the rights of the file, initializing them on first use to
+// the ones fd_fdstat_get reports, limited by WithPreopenRights or
+// WithSocketRights.
+func (p *policy) rights(f *sys.FileEntry) (*sys.FileRights, wasip1.Errno) {
+	if f.Rights != nil {
+		return f.Rights, 0
+	}
+	st, errno := f.File.Stat()
+	if errno != 0 {
+		return nil, wasip1.ToErrno(errno)
+	}
+	fileType := getExtendedWasiFiletype(f.File, st.Mode)
+	base, inheriting := defaultRights(fileType)
+	if _, ok := f.File.(socketapi.TCPSock); ok {
+		inheriting = base // the rights of the accepted connections
+	}
+	rights := &sys.FileRights{Base: uint64(base), Inheriting: uint64(inheriting)}
+	var limit sys.FileRights
+	var ok bool
+	switch {
+	case fileType == wasip1.FILETYPE_SOCKET_STREAM || fileType == wasip1.FILETYPE_SOCKET_DGRAM:
+		if ok = p.socketRights != nil; ok {
+			limit = *p.socketRights
+		}
+	case f.IsPreopen && f.FS != nil:
+		limit, ok = p.preopenRights[sys.StripPrefixesAndTrailingSlash(f.Name)]
+	}
+	if ok {
+		rights.Base &= limit.Base
+		rights.Inheriting &= limit.Inheriting
+	}
+	f.Rights = rights
+	return rights, 0
+}
+
+// pathOpen wraps path_open to check the rights of the directory and the path,
+// and grant the rights requested to the new file descriptor.
+func (p *policy) pathOpen(inner api.GoModuleFunction) api.GoModuleFunc {
+	return func(ctx context.Context, mod api.Module, stack []uint64) {
+		fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+		dir, ok := fsc.LookupFile(int32(stack[0]))
+		if !ok {
+			inner.Call(ctx, mod, stack)
+			return
+		}
+		dirRights, errno := p.rights(dir)
+		if errno == 0 {
+			errno = p.checkPath(mod, int32(stack[0]), uint32(stack[2]), uint32(stack[3]))
+		}
+		if errno != 0 {
+			stack[0] = uint64(errno)
+			return
+		}
+		// The guest path is empty when unknown, so that files opened from
+		// this one are denied if paths are restricted later on.
+		guestPath, _ := p.resolvePath(mod, dir, uint32(stack[2]), uint32(stack[3]))
+
+		required := wasip1.RIGHT_PATH_OPEN
+		oflags := uint16(stack[4])
+		if oflags&wasip1.O_CREAT != 0 {
+			required |= wasip1.RIGHT_PATH_CREATE_FILE
+		}
+		if oflags&wasip1.O_TRUNC != 0 {
+			required |= wasip1.RIGHT_PATH_FILESTAT_SET_SIZE
+		}
+
+		// Read and write decide the mode the file is opened in, so can't be
+		// dropped. Other rights are limited to what the directory allows, as
+		// guests commonly request all of them.
+		const rw = uint64(wasip1.RIGHT_FD_READ | wasip1.RIGHT_FD_WRITE)
+		base, inheriting := stack[5], stack[6]
+		if dirRights.Base&uint64(required) != uint64(required) || base&rw&^dirRights.Inheriting != 0 {
+			stack[0] = uint64(wasip1.ErrnoNotcapable)
+			return
+		}
+		base &= dirRights.Inheriting
+		inheriting &= dirRights.Inheriting
+		stack[5] = base
+		resultOpenedFD := uint32(stack[8])
+
+		inner.Call(ctx, mod, stack)
+		if stack[0] != uint64(wasip1.ErrnoSuccess) {
+			return
+		}
+		// The file descriptor was written, so we can read it back.
+		newFD, _ := mod.Memory().ReadUint32Le(resultOpenedFD)
+		if f, ok := fsc.LookupFile(int32(newFD)); ok {
+			f.Rights = &sys.FileRights{Base: base, Inheriting: inheriting, Path: guestPath}
+		}
+	}
+}
+
+// sockAccept wraps sock_accept, or sock_accept_v2 of WASIX, to check
+// RIGHT_FD_READ of the listener, and grant its inheriting rights to the new
+// connection.
+func (p *policy) sockAccept(inner api.GoModuleFunction) api.GoModuleFunc {
+	return func(ctx context.Context, mod api.Module, stack []uint64) {
+		fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+		listener, ok := fsc.LookupFile(int32(stack[0]))
+		if !ok {
+			inner.Call(ctx, mod, stack)
+			return
+		}
+		listenerRights, errno := p.rights(listener)
+		if errno == 0 && listenerRights.Base&uint64(wasip1.RIGHT_FD_READ) == 0 {
+			errno = wasip1.ErrnoNotcapable
+		}
+		if errno != 0 {
+			stack[0] = uint64(errno)
+			return
+		}
+		resultFd := uint32(stack[2])
+
+		inner.Call(ctx, mod, stack)
+		if stack[0] != uint64(wasip1.ErrnoSuccess) {
+			return
+		}
+		newFD, _ := mod.Memory().ReadUint32Le(resultFd)
+		if f, ok := fsc.LookupFile(int32(newFD)); ok {
+			base, _ := defaultRights(wasip1.FILETYPE_SOCKET_STREAM)
+			f.Rights = &sys.FileRights{Base: uint64(base) & listenerRights.Inheriting}
+		}
+	}
+}
+
+// fdFdstatSetRights implements fd_fdstat_set_rights, which can only drop
+// rights of a file descriptor.
+//
+// Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EBADF: `fd` is invalid
+//   - ErrnoNotcapable: the rights aren't a subset of the current ones
+func (p *policy) fdFdstatSetRights(_ context.Context, mod api.Module, stack []uint64) {
+	fd := int32(stack[0])
+	base, inheriting := stack[1], stack[2]
+
+	f, ok := mod.(*wasm.ModuleInstance).Sys.FS().LookupFile(fd)
+	if !ok {
+		stack[0] = uint64(wasip1.ErrnoBadf)
+		return
+	}
+	rights, errno := p.rights(f)
+	if errno == 0 && (base&^rights.Base != 0 || inheriting&^rights.Inheriting != 0) {
+		errno = wasip1.ErrnoNotcapable
+	} else if errno == 0 {
+		f.Rights = &sys.FileRights{Base: base, Inheriting: inheriting, Path: rights.Path}
+	}
+	stack[0] = uint64(errno)
+}
@@ -0,0 +1,272 @@
+package wasi_snapshot_preview1_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	experimentalsock "github.com/tetratelabs/wazero/experimental/sock"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/testing/proxy"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasip1"
+)
+
+// readOnly are the rights of a directory where nothing can be written.
+const readOnly = wasi_snapshot_preview1.RightsAll &^ (wasi_snapshot_preview1.RightFdWrite |
+	wasi_snapshot_preview1.RightPathCreateDirectory |
+	wasi_snapshot_preview1.RightPathCreateFile |
+	wasi_snapshot_preview1.RightPathRemoveDirectory |
+	wasi_snapshot_preview1.RightPathUnlinkFile)
+
+// preopenFD is the file descriptor of the directory mounted at "/".
+const preopenFD = 3
+
+// requirePolicyModule is like requireProxyModule, except the functions
+// enforce the policy.
+func requirePolicyModule(ctx context.Context, t *testing.T, policy wasi_snapshot_preview1.Policy, config wazero.ModuleConfig) (api.Module, api.Closer) {
+	r := wazero.NewRuntime(ctx)
+
+	wasiModuleCompiled, err := wasi_snapshot_preview1.NewBuilder(r).WithPolicy(policy).Compile(ctx)
+	require.NoError(t, err)
+
+	_, err = r.InstantiateModule(ctx, wasiModuleCompiled, config)
+	require.NoError(t, err)
+
+	proxyBin := proxy.NewModuleBinary(wasi_snapshot_preview1.ModuleName, wasiModuleCompiled)
+
+	proxyCompiled, err := r.CompileModule(ctx, proxyBin)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(ctx, proxyCompiled, config)
+	require.NoError(t, err)
+
+	return mod, r
+}
+
+// requirePathOpen opens the path from the directory with the rights, returning
+// the new file descriptor.
+func requirePathOpen(t *testing.T, mod api.Module, expectedErrno wasip1.Errno, dirFD uint32, path string, oflags uint16, rights wasi_snapshot_preview1.Rights) uint32 {
+	pathOffset, resultOpenedFd := uint32(0), uint32(32)
+	require.True(t, mod.Memory().Write(pathOffset, []byte(path)))
+
+	requireErrnoResult(t, expectedErrno, mod, wasip1.PathOpenName, uint64(dirFD), 0,
+		uint64(pathOffset), uint64(len(path)), uint64(oflags), uint64(rights), uint64(rights), 0, uint64(resultOpenedFd))
+
+	fd, ok := mod.Memory().ReadUint32Le(resultOpenedFd)
+	require.True(t, ok)
+	return fd
+}
+
+// requireFdstatRights returns the rights reported by fd_fdstat_get.
+func requireFdstatRights(t *testing.T, mod api.Module, fd uint32) (base, inheriting wasi_snapshot_preview1.Rights) {
+	resultFdstat := uint32(64)
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.FdFdstatGetName, uint64(fd), uint64(resultFdstat))
+
+	b, ok := mod.Memory().ReadUint64Le(resultFdstat + 8)
+	require.True(t, ok)
+	i, ok := mod.Memory().ReadUint64Le(resultFdstat + 16)
+	require.True(t, ok)
+	return wasi_snapshot_preview1.Rights(b), wasi_snapshot_preview1.Rights(i)
+}
+
+func TestPolicy_WithDeniedFunctions(t *testing.T) {
+	policy := wasi_snapshot_preview1.NewPolicy().WithDeniedFunctions(wasip1.RandomGetName)
+	mod, r := requirePolicyModule(testCtx, t, policy, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.RandomGetName, 0, 8)
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.ArgsSizesGetName, 0, 4)
+}
+
+func TestPolicy_WithAllowedFunctions(t *testing.T) {
+	policy := wasi_snapshot_preview1.NewPolicy().
+		WithAllowedFunctions(wasip1.RandomGetName, wasip1.ArgsSizesGetName).
+		WithDeniedFunctions(wasip1.ArgsSizesGetName) // denial wins
+	mod, r := requirePolicyModule(testCtx, t, policy, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.RandomGetName, 0, 8)
+	requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.ArgsSizesGetName, 0, 4)
+	requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.ClockTimeGetName, 0, 0, 0)
+}
+
+func TestPolicy_WithPreopenRights(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFile(t, tmpDir, "file", []byte("wazero"))
+
+	policy := wasi_snapshot_preview1.NewPolicy().WithPreopenRights("/", readOnly, readOnly)
+	fsConfig := wazero.NewFSConfig().WithDirMount(tmpDir, "/")
+	mod, r := requirePolicyModule(testCtx, t, policy, wazero.NewModuleConfig().WithFSConfig(fsConfig))
+	defer r.Close(testCtx)
+
+	// The pre-open reports its restricted rights.
+	base, inheriting := requireFdstatRights(t, mod, preopenFD)
+	require.Zero(t, base&wasi_snapshot_preview1.RightPathUnlinkFile)
+	require.Zero(t, inheriting&wasi_snapshot_preview1.RightFdWrite)
+	require.NotEqual(t, wasi_snapshot_preview1.Rights(0), base&wasi_snapshot_preview1.RightPathOpen)
+
+	path := uint32(0)
+	require.True(t, mod.Memory().Write(path, []byte("file")))
+	requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.PathUnlinkFileName, preopenFD, uint64(path), 4)
+	requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.PathCreateDirectoryName, preopenFD, uint64(path), 4)
+	require.Equal(t, "wazero", string(readFile(t, tmpDir, "file")))
+
+	t.Run("path_open write", func(t *testing.T) {
+		requirePathOpen(t, mod, wasip1.ErrnoNotcapable, preopenFD, "file", 0, wasi_snapshot_preview1.RightFdWrite)
+	})
+
+	t.Run("path_open create", func(t *testing.T) {
+		requirePathOpen(t, mod, wasip1.ErrnoNotcapable, preopenFD, "new", wasip1.O_CREAT, wasi_snapshot_preview1.RightFdRead)
+		_, err := os.Stat(joinPath(tmpDir, "new"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("path_open read", func(t *testing.T) {
+		// Guests commonly request all rights, which are limited to the ones
+		// inherited from the directory.
+		fd := requirePathOpen(t, mod, wasip1.ErrnoSuccess, preopenFD, "file", 0, wasi_snapshot_preview1.RightsAll&^wasi_snapshot_preview1.RightFdWrite)
+		base, fileInheriting := requireFdstatRights(t, mod, fd)
+		require.Equal(t, inheriting&^wasi_snapshot_preview1.RightFdWrite, base)
+		require.Equal(t, inheriting, fileInheriting)
+
+		iovs, resultNwritten := uint32(16), uint32(24)
+		require.True(t, mod.Memory().WriteUint32Le(iovs, 0))
+		require.True(t, mod.Memory().WriteUint32Le(iovs+4, 1))
+		requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.FdWriteName, uint64(fd), uint64(iovs), 1, uint64(resultNwritten))
+		requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.FdReadName, uint64(fd), uint64(iovs), 1, uint64(resultNwritten))
+	})
+}
+
+func TestPolicy_fdFdstatSetRights(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFile(t, tmpDir, "file", []byte("wazero"))
+
+	fsConfig := wazero.NewFSConfig().WithDirMount(tmpDir, "/")
+	mod, r := requirePolicyModule(testCtx, t, wasi_snapshot_preview1.NewPolicy(), wazero.NewModuleConfig().WithFSConfig(fsConfig))
+	defer r.Close(testCtx)
+
+	const readSeek = wasi_snapshot_preview1.RightFdRead | wasi_snapshot_preview1.RightFdSeek | wasi_snapshot_preview1.RightFdTell
+	fd := requirePathOpen(t, mod, wasip1.ErrnoSuccess, preopenFD, "file", 0, readSeek|wasi_snapshot_preview1.RightFdWrite)
+
+	// Rights can be dropped, but not added back.
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.FdFdstatSetRightsName, uint64(fd), uint64(wasi_snapshot_preview1.RightFdRead|wasi_snapshot_preview1.RightFdTell), 0)
+	requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.FdFdstatSetRightsName, uint64(fd), uint64(readSeek), 0)
+	base, _ := requireFdstatRights(t, mod, fd)
+	require.Equal(t, wasi_snapshot_preview1.RightFdRead|wasi_snapshot_preview1.RightFdTell, base)
+
+	// Telling the offset doesn't need the right to seek.
+	resultNewoffset := uint32(16)
+	requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.FdSeekName, uint64(fd), 1, io.SeekStart, uint64(resultNewoffset))
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.FdSeekName, uint64(fd), 0, io.SeekCurrent, uint64(resultNewoffset))
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.FdTellName, uint64(fd), uint64(resultNewoffset))
+
+	requireErrnoResult(t, wasip1.ErrnoBadf, mod, wasip1.FdFdstatSetRightsName, 42, 0, 0)
+}
+
+func TestPolicy_WithAllowedPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFile(t, tmpDir, "file", []byte("wazero"))
+	require.NoError(t, os.Mkdir(joinPath(tmpDir, "data"), 0o700))
+	writeFile(t, tmpDir, "data/file", []byte("wazero"))
+
+	policy := wasi_snapshot_preview1.NewPolicy().WithAllowedPaths("/data")
+	fsConfig := wazero.NewFSConfig().WithDirMount(tmpDir, "/")
+	mod, r := requirePolicyModule(testCtx, t, policy, wazero.NewModuleConfig().WithFSConfig(fsConfig))
+	defer r.Close(testCtx)
+
+	const read = wasi_snapshot_preview1.RightsAll &^ wasi_snapshot_preview1.RightFdWrite
+	requirePathOpen(t, mod, wasip1.ErrnoNotcapable, preopenFD, "file", 0, read)
+	requirePathOpen(t, mod, wasip1.ErrnoNotcapable, preopenFD, "data/../file", 0, read)
+	requirePathOpen(t, mod, wasip1.ErrnoSuccess, preopenFD, "data/file", 0, read)
+
+	// The directory opened remembers its guest path.
+	dirFD := requirePathOpen(t, mod, wasip1.ErrnoSuccess, preopenFD, "data", wasip1.O_DIRECTORY, read)
+	requirePathOpen(t, mod, wasip1.ErrnoSuccess, dirFD, "file", 0, read)
+	requirePathOpen(t, mod, wasip1.ErrnoNotcapable, dirFD, "../file", 0, read)
+
+	// Other functions resolving a path are restricted too.
+	path := uint32(0)
+	require.True(t, mod.Memory().Write(path, []byte("file")))
+	requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.PathUnlinkFileName, preopenFD, uint64(path), 4)
+	require.Equal(t, "wazero", string(readFile(t, tmpDir, "file")))
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.PathUnlinkFileName, uint64(dirFD), uint64(path), 4)
+	_, err := os.Stat(joinPath(tmpDir, "data/file"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPolicy_WithSocketRights(t *testing.T) {
+	const noWrite = wasi_snapshot_preview1.RightsAll &^ wasi_snapshot_preview1.RightFdWrite
+	policy := wasi_snapshot_preview1.NewPolicy().WithSocketRights(wasi_snapshot_preview1.RightsAll, noWrite)
+	ctx := experimentalsock.WithConfig(testCtx, experimentalsock.NewConfig().
+		WithTCPListener("127.0.0.1", 0).
+		WithAllowedDestination("127.0.0.1", 0))
+	mod, r := requirePolicyModule(ctx, t, policy, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	t.Run("sock_accept", func(t *testing.T) {
+		// Dial the socket so that a call to accept doesn't hang.
+		tcpAddr := requireTCPListenerAddr(t, mod)
+		tcp, err := net.DialTCP("tcp", nil, tcpAddr)
+		require.NoError(t, err)
+		defer tcp.Close() //nolint
+
+		resultFd := uint32(128)
+		requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.SockAcceptName, uint64(sys.FdPreopen), 0, uint64(resultFd))
+		connFd, _ := mod.Memory().ReadUint32Le(resultFd)
+
+		// The connection inherits the rights of the listener.
+		base, _ := requireFdstatRights(t, mod, connFd)
+		require.Zero(t, base&wasi_snapshot_preview1.RightFdWrite)
+		require.NotEqual(t, wasi_snapshot_preview1.Rights(0), base&wasi_snapshot_preview1.RightFdRead)
+		requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.SockSendName, uint64(connFd), 0, 0, 0, 0)
+
+		// Without the right to read, the listener can't accept.
+		_, inheriting := requireFdstatRights(t, mod, uint32(sys.FdPreopen))
+		requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.FdFdstatSetRightsName, uint64(sys.FdPreopen), 0, uint64(inheriting))
+		requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.SockAcceptName, uint64(sys.FdPreopen), 0, uint64(resultFd))
+	})
+
+	t.Run("sock_open", func(t *testing.T) {
+		policy := wasi_snapshot_preview1.NewPolicy().WithSocketRights(noWrite, 0)
+		mod, r := requirePolicyModule(ctx, t, policy, wazero.NewModuleConfig())
+		defer r.Close(testCtx)
+
+		resultFd := uint32(128)
+		requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.SockOpenName, uint64(wasip1.AddressFamilyInet4), uint64(wasip1.SockTypeDgram), uint64(resultFd))
+		fd, _ := mod.Memory().ReadUint32Le(resultFd)
+
+		base, _ := requireFdstatRights(t, mod, fd)
+		require.Zero(t, base&wasi_snapshot_preview1.RightFdWrite)
+		requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.SockSendToName, uint64(fd), 0, 0, 0, 0, 0, 0)
+	})
+}
+
+func TestPolicy_pollOneoff(t *testing.T) {
+	mod, r := requirePolicyModule(testCtx, t, wasi_snapshot_preview1.NewPolicy(), wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	in, out, resultNevents := uint32(0), uint32(128), uint32(512)
+	require.True(t, mod.Memory().Write(in, []byte{
+		0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, // userdata
+		wasip1.EventTypeFdRead, 0x0, 0x0, 0x0, // 4 bytes for type enum
+		0x0, 0x0, 0x0, 0x0, // 4 bytes padding
+		byte(sys.FdStdin), 0x0, 0x0, 0x0, // fd
+	}))
+
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.PollOneoffName, uint64(in), uint64(out), 1, uint64(resultNevents))
+
+	// Subscribing to an fd requires the right to poll it.
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.FdFdstatSetRightsName, uint64(sys.FdStdin),
+		uint64(wasi_snapshot_preview1.RightFdRead), 0)
+	requireErrnoResult(t, wasip1.ErrnoNotcapable, mod, wasip1.PollOneoffName, uint64(in), uint64(out), 1, uint64(resultNevents))
+}
+
+func TestRights_String(t *testing.T) {
+	require.Equal(t, "FD_READ|FD_WRITE", (wasi_snapshot_preview1.RightFdRead | wasi_snapshot_preview1.RightFdWrite).String())
+}
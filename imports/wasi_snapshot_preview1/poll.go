@@ -2,6 +2,7 @@ package wasi_snapshot_preview1
 
 import (
 	"context"
+	"math"
 	"time"
 
 	"github.com/tetratelabs/wazero/api"
@@ -25,7 +26,6 @@ import (
 //
 // The return value is 0 except the following error conditions:
 //   - sys.EINVAL: the parameters are invalid
-//   - sys.ENOTSUP: a parameters is valid, but not yet supported.
 //   - sys.EFAULT: there is not enough memory to read the subscriptions or
 //     write results.
 //
@@ -33,6 +33,14 @@ import (
 //
 //   - Since the `out` pointer nests Errno, the result is always 0.
 //   - This is similar to `poll` in POSIX.
+//   - Clock subscriptions with subscription_clock_abstime are relative to the
+//     clock configured with wazero.ModuleConfig, i.e. WithWalltime for
+//     clock_realtime and WithNanotime for clock_monotonic.
+//   - fd subscriptions are ready when the file implements sys.Pollable and
+//     polls ready for reading (fd_read) or writing (fd_write). Otherwise,
+//     their event has the error sys.ENOTSUP.
+//   - Clock subscriptions are always written to `out`, but only block when
+//     no fd subscription is ready.
 //
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#poll_oneoff
 // See https://linux.die.net/man/3/poll
@@ -81,15 +89,18 @@ func pollOneoffFn(_ context.Context, mod api.Module, params []uint64) sys.Errno
 
 	// Loop through all subscriptions and write their output.
 
-	// Extract FS context, used in the body of the for loop for FS access.
-	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
-	// blockingPollSubs are fd read subscriptions processed after the loop via polling.
-	var blockingPollSubs []struct {
-		evt  *event
-		file sys.File
-	}
-	// The timeout is initialized at max Duration, the loop will find the minimum.
-	var timeout time.Duration = 1<<63 - 1
+	// Extract the system context, used in the body of the for loop for clock
+	// and FS access.
+	sysCtx := mod.(*wasm.ModuleInstance).Sys
+	fsc := sysCtx.FS()
+	// pollSubs are fd subscriptions processed after the loop via polling.
+	var pollSubs []*pollSub
+	// fdEvents is true when the event of an fd subscription was written
+	// without polling, e.g. as its fd was invalid.
+	fdEvents := false
+	// The timeout is negative unless there is a clock subscription, in which
+	// case the loop will find the minimum.
+	timeout := time.Duration(-1)
 	// Count of all the subscriptions that have been already written back to outBuf.
 	// nevents*32 returns at all times the offset where the next event should be written:
 	// this way we ensure that there are no gaps between records.
@@ -114,98 +125,68 @@ func pollOneoffFn(_ context.Context, mod api.Module, params []uint64) sys.Errno
 
 		switch eventType {
 		case wasip1.EventTypeClock: // handle later
-			newTimeout, err := processClockEvent(argBuf)
+			newTimeout, err := processClockEvent(sysCtx, argBuf)
 			if err != 0 {
 				return err
 			}
 			// Min timeout.
-			if newTimeout < timeout {
+			if timeout < 0 || newTimeout < timeout {
 				timeout = newTimeout
 			}
 			// Ack the clock event to the outBuf.
 			writeEvent(outBuf[outOffset:], evt)
 			nevents++
-		case wasip1.EventTypeFdRead:
+		case wasip1.EventTypeFdRead, wasip1.EventTypeFdWrite:
 			fd := int32(le.Uint32(argBuf))
 			if fd < 0 {
 				return sys.EBADF
 			}
-			if file, ok := fsc.LookupFile(fd); !ok {
+			file, ok := fsc.LookupFile(fd)
+			if !ok {
 				evt.errno = wasip1.ErrnoBadf
-				writeEvent(outBuf[outOffset:], evt)
-				nevents++
-			} else if fd != internalsys.FdStdin && isNonblock(file.File) {
-				writeEvent(outBuf[outOffset:], evt)
-				nevents++
+			} else if p, ok := file.File.(sys.Pollable); ok {
+				// Do not ack yet, append to a slice for deferred polling
+				// evaluation.
+				flag := sys.POLLIN
+				if eventType == wasip1.EventTypeFdWrite {
+					flag = sys.POLLOUT
+				}
+				pollSubs = append(pollSubs, &pollSub{evt: evt, p: p, flag: flag})
+				continue
 			} else {
-				// The fd is in blocking mode; do not ack yet, append
-				// to a slice for deferred polling evaluation.
-				blockingPollSubs = append(blockingPollSubs, struct {
-					evt  *event
-					file sys.File
-				}{evt, file.File})
-			}
-		case wasip1.EventTypeFdWrite:
-			fd := int32(le.Uint32(argBuf))
-			if fd < 0 {
-				return sys.EBADF
-			}
-			if _, ok := fsc.LookupFile(fd); ok {
 				evt.errno = wasip1.ErrnoNotsup
-			} else {
-				evt.errno = wasip1.ErrnoBadf
 			}
-			nevents++
 			writeEvent(outBuf[outOffset:], evt)
+			nevents++
+			fdEvents = true
 		default:
 			return sys.EINVAL
 		}
 	}
 
-	sysCtx := mod.(*wasm.ModuleInstance).Sys
-	if nevents == nsubscriptions {
+	if len(pollSubs) == 0 {
 		// We already wrote back all the results. We already wrote this number
 		// earlier to offset `resultNevents`.
-		// We only need to observe the timeout (nonzero if there are clock subscriptions)
-		// and return.
-		if timeout > 0 {
+		// Only clock subscriptions block, until the earliest one expires.
+		if !fdEvents && timeout > 0 {
 			sysCtx.Nanosleep(int64(timeout))
 		}
 		return 0
 	}
 
-	// Wait for the timeout to expire, or for data to become available on
-	// each blocking fd subscriber. The remaining time budget is tracked
-	// across iterations so total wall time never exceeds the timeout.
-	remaining := timeout
-	for _, sub := range blockingPollSubs {
-		p, ok := sub.file.(sys.Pollable)
-		if !ok {
-			sub.evt.errno = wasip1.ErrnoNotsup
-			writeEvent(outBuf[nevents*32:], sub.evt)
-			nevents++
-			continue
-		}
-		start := time.Now()
-		ready, errno := p.Poll(sys.POLLIN, int32(remaining.Milliseconds()))
-		switch errno {
-		case 0:
-			if ready {
-				sub.evt.errno = 0
-				writeEvent(outBuf[nevents*32:], sub.evt)
-				nevents++
-			}
-		case sys.ENOSYS, sys.ENOTSUP:
-			sub.evt.errno = wasip1.ErrnoNotsup
+	// Don't block when an event is already available.
+	if fdEvents {
+		timeout = 0
+	}
+
+	// Wait for the timeout to expire, or for any fd subscription to be ready.
+	if errno := pollFds(pollSubs, timeout); errno != 0 {
+		return errno
+	}
+	for _, sub := range pollSubs {
+		if sub.ready {
 			writeEvent(outBuf[nevents*32:], sub.evt)
 			nevents++
-		default:
-			return errno
-		}
-		if elapsed := time.Since(start); elapsed < remaining {
-			remaining -= elapsed
-		} else {
-			remaining = 0
 		}
 	}
 
@@ -218,33 +199,116 @@ func pollOneoffFn(_ context.Context, mod api.Module, params []uint64) sys.Errno
 	return 0
 }
 
-// processClockEvent supports only relative name events, as that's what's used
-// to implement sleep in various compilers including Rust, Zig and TinyGo.
-func processClockEvent(inBuf []byte) (time.Duration, sys.Errno) {
-	_ /* ID */ = le.Uint32(inBuf[0:8])          // See below
-	timeout := le.Uint64(inBuf[8:16])           // nanos if relative
+// processClockEvent returns the timeout of a clock subscription, relative to
+// the current time of its clock when subscription_clock_abstime is set.
+func processClockEvent(sysCtx *internalsys.Context, inBuf []byte) (time.Duration, sys.Errno) {
+	id := le.Uint32(inBuf[0:8])
+	timeout := le.Uint64(inBuf[8:16])           // nanos
 	_ /* precision */ = le.Uint64(inBuf[16:24]) // Unused
 	flags := le.Uint16(inBuf[24:32])
 
-	var err sys.Errno
 	// subclockflags has only one flag defined:  subscription_clock_abstime
 	switch flags {
 	case 0: // relative time
-	case 1: // subscription_clock_abstime
-		err = sys.ENOTSUP
+		// https://linux.die.net/man/3/clock_settime says relative timers are
+		// unaffected. Since the timeout is relative, we can skip name ID
+		// validation and use a single sleep function.
+		return time.Duration(min(timeout, math.MaxInt64)), 0
+	case wasip1.SubclockflagsAbstime:
+		var now int64
+		switch id {
+		case wasip1.ClockIDRealtime:
+			now = sysCtx.WalltimeNanos()
+		case wasip1.ClockIDMonotonic:
+			now = sysCtx.Nanotime()
+		default:
+			return 0, sys.EINVAL
+		}
+		if deadline := int64(min(timeout, math.MaxInt64)); deadline > now {
+			return time.Duration(deadline - now), 0
+		}
+		return 0, 0 // the deadline already passed
 	default: // subclockflags has only one flag defined.
-		err = sys.EINVAL
+		return 0, sys.EINVAL
 	}
+}
 
-	if err != 0 {
-		return 0, err
-	} else {
-		// https://linux.die.net/man/3/clock_settime says relative timers are
-		// unaffected. Since this function only supports relative timeout, we can
-		// skip name ID validation and use a single sleep function.
+// pollSub is an fd subscription, ready when its event was polled or polling
+// isn't supported.
+type pollSub struct {
+	evt   *event
+	p     sys.Pollable
+	flag  sys.Pflag
+	ready bool
+}
+
+// poll polls the subscription, blocking up to timeoutMillis.
+func (s *pollSub) poll(timeoutMillis int32) sys.Errno {
+	ready, errno := s.p.Poll(s.flag, timeoutMillis)
+	switch errno {
+	case 0:
+		s.ready = ready
+	case sys.ENOSYS, sys.ENOTSUP:
+		s.evt.errno = wasip1.ErrnoNotsup
+		s.ready = true
+	default:
+		return errno
+	}
+	return 0
+}
+
+// pollInterval is the maximum duration a subscription is polled for when
+// there are multiple, as they are polled one at a time.
+const pollInterval = 10 * time.Millisecond
+
+// pollFds polls the subscriptions until any is ready or the timeout expires,
+// which is never when it is negative.
+func pollFds(subs []*pollSub, timeout time.Duration) sys.Errno {
+	if len(subs) == 1 {
+		return subs[0].poll(durationMillis(timeout))
+	}
+
+	// Poll all subscriptions without blocking, then block on the first one
+	// in slices of pollInterval, so that the others are polled periodically.
+	// The deadline is tracked across iterations so total wall time never
+	// exceeds the timeout.
+	deadline := time.Now().Add(timeout)
+	wait := int32(0)
+	for {
+		ready := false
+		for i, sub := range subs {
+			timeoutMillis := int32(0)
+			if i == 0 {
+				timeoutMillis = wait
+			}
+			if errno := sub.poll(timeoutMillis); errno != 0 {
+				return errno
+			}
+			ready = ready || sub.ready
+		}
+		if ready {
+			return 0
+		}
+		slice := time.Duration(pollInterval)
+		if timeout >= 0 {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return 0
+			}
+			slice = min(slice, remaining)
+		}
+		wait = durationMillis(slice)
+	}
+}
 
-		return time.Duration(timeout), 0
+// durationMillis returns the duration rounded up to milliseconds, or -1 if
+// negative.
+func durationMillis(d time.Duration) int32 {
+	if d < 0 {
+		return -1
 	}
+	millis := (d + time.Millisecond - 1) / time.Millisecond
+	return int32(min(millis, math.MaxInt32))
 }
 
 // isNonblock returns true if the file implements PollableFile and is in
@@ -743,7 +743,8 @@ func Test_pollOneoff_NonStdinPollableTimeoutBudget(t *testing.T) {
 ==> wasi_snapshot_preview1.poll_oneoff(in=0,out=128,nsubscriptions=3)
 <== (nevents=1,errno=ESUCCESS)
 `, "\n"+log.String())
-	require.Equal(t, []int32{10}, firstPoller.timeouts)
+	// Both are polled without blocking first, which exhausts the budget.
+	require.Equal(t, []int32{0}, firstPoller.timeouts)
 	require.Equal(t, []int32{0}, secondPoller.timeouts)
 
 	outMem, ok := mod.Memory().Read(out, 96)
@@ -757,6 +758,196 @@ func Test_pollOneoff_NonStdinPollableTimeoutBudget(t *testing.T) {
 	require.Equal(t, uint32(1), nevents)
 }
 
+func Test_pollOneoff_NonStdinPollableSecondReady(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(tmpDir+"/one.txt", []byte("one"), 0o600))
+	require.NoError(t, os.WriteFile(tmpDir+"/two.txt", []byte("two"), 0o600))
+
+	cfg := wazero.NewModuleConfig().WithFSConfig(
+		wazero.NewFSConfig().WithDirMount(tmpDir, "/"),
+	)
+	mod, r, log := requireProxyModule(t, cfg)
+	defer r.Close(testCtx)
+	defer log.Reset()
+
+	firstFD := requirePollOpenFile(t, mod, "one.txt")
+	secondFD := requirePollOpenFile(t, mod, "two.txt")
+
+	firstPoller := &pollableFile{}
+	secondPoller := &pollableFile{ready: true}
+
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+	firstEntry, ok := fsc.LookupFile(firstFD)
+	require.True(t, ok)
+	firstEntry.File = firstPoller
+	secondEntry, ok := fsc.LookupFile(secondFD)
+	require.True(t, ok)
+	secondEntry.File = secondPoller
+
+	maskMemory(t, mod, 1024)
+
+	out := uint32(128)
+	resultNevents := uint32(512)
+	secondUserData := []byte{8, 9, 10, 11, 12, 13, 14, 15}
+	mod.Memory().Write(0,
+		concat(
+			fdReadSubFd(byte(firstFD)),
+			fdReadSubFdWithUserData(byte(secondFD), secondUserData),
+		),
+	)
+
+	// Without a clock subscription, this would block until an fd is ready.
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.PollOneoffName,
+		uint64(0), uint64(out), uint64(2), uint64(resultNevents))
+
+	require.Equal(t, `
+==> wasi_snapshot_preview1.poll_oneoff(in=0,out=128,nsubscriptions=2)
+<== (nevents=1,errno=ESUCCESS)
+`, "\n"+log.String())
+	require.Equal(t, []int32{0}, firstPoller.timeouts)
+	require.Equal(t, []int32{0}, secondPoller.timeouts)
+
+	outMem, ok := mod.Memory().Read(out, 64)
+	require.True(t, ok)
+	require.Equal(t, secondUserData, outMem[0:8])
+	require.Equal(t, byte(wasip1.ErrnoSuccess), outMem[8])
+	require.Equal(t, byte(wasip1.EventTypeFdRead), outMem[10])
+	require.Equal(t, make([]byte, 32), outMem[32:64])
+}
+
+func Test_pollOneoff_FdWrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(tmpDir+"/test.txt", []byte("data"), 0o600))
+
+	cfg := wazero.NewModuleConfig().WithFSConfig(
+		wazero.NewFSConfig().WithDirMount(tmpDir, "/"),
+	)
+	mod, r, log := requireProxyModule(t, cfg)
+	defer r.Close(testCtx)
+	defer log.Reset()
+
+	// pollableFile only supports POLLIN.
+	fd := requirePollOpenFile(t, mod, "test.txt")
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+	entry, ok := fsc.LookupFile(fd)
+	require.True(t, ok)
+	entry.File = &pollableFile{ready: true}
+
+	maskMemory(t, mod, 1024)
+
+	out := uint32(128)
+	resultNevents := uint32(512)
+	mod.Memory().Write(0,
+		concat(
+			fdWriteSubFd(byte(sys.FdStdout)),
+			fdWriteSubFd(byte(fd)),
+		),
+	)
+
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.PollOneoffName,
+		uint64(0), uint64(out), uint64(2), uint64(resultNevents))
+
+	require.Equal(t, `
+==> wasi_snapshot_preview1.poll_oneoff(in=0,out=128,nsubscriptions=2)
+<== (nevents=2,errno=ESUCCESS)
+`, "\n"+log.String())
+
+	// Stdout discards writes, so it is always ready.
+	outMem, ok := mod.Memory().Read(out, 64)
+	require.True(t, ok)
+	require.Equal(t, byte(wasip1.ErrnoSuccess), outMem[8])
+	require.Equal(t, byte(wasip1.EventTypeFdWrite), outMem[10])
+	require.Equal(t, byte(wasip1.ErrnoNotsup), outMem[32+8])
+	require.Equal(t, byte(wasip1.EventTypeFdWrite), outMem[32+10])
+}
+
+func Test_pollOneoff_Abstime(t *testing.T) {
+	const now = int64(10 * time.Second)
+	var slept []int64
+	cfg := wazero.NewModuleConfig().
+		WithWalltime(func() (sec int64, nsec int32) {
+			return now / int64(time.Second), int32(now % int64(time.Second))
+		}, sysapi.ClockResolution(1)).
+		WithNanotime(func() int64 { return now }, sysapi.ClockResolution(1)).
+		WithNanosleep(func(ns int64) { slept = append(slept, ns) })
+	mod, r, log := requireProxyModule(t, cfg)
+	defer r.Close(testCtx)
+
+	tests := []struct {
+		name          string
+		clockID       uint32
+		timeout       uint64
+		flags         uint16
+		expectedErrno wasip1.Errno
+		expectedSlept []int64
+	}{
+		{
+			name:          "realtime",
+			clockID:       wasip1.ClockIDRealtime,
+			timeout:       uint64(now + int64(time.Millisecond)),
+			flags:         wasip1.SubclockflagsAbstime,
+			expectedSlept: []int64{int64(time.Millisecond)},
+		},
+		{
+			name:          "monotonic",
+			clockID:       wasip1.ClockIDMonotonic,
+			timeout:       uint64(now + int64(time.Second)),
+			flags:         wasip1.SubclockflagsAbstime,
+			expectedSlept: []int64{int64(time.Second)},
+		},
+		{
+			name:    "deadline passed",
+			clockID: wasip1.ClockIDMonotonic,
+			timeout: uint64(now - 1),
+			flags:   wasip1.SubclockflagsAbstime,
+		},
+		{
+			name:          "relative",
+			clockID:       wasip1.ClockIDMonotonic,
+			timeout:       uint64(now),
+			expectedSlept: []int64{now},
+		},
+		{
+			name:          "invalid clock ID",
+			clockID:       2, // process_cputime_id
+			timeout:       uint64(now),
+			flags:         wasip1.SubclockflagsAbstime,
+			expectedErrno: wasip1.ErrnoInval,
+		},
+		{
+			name:          "invalid flags",
+			clockID:       wasip1.ClockIDMonotonic,
+			timeout:       uint64(now),
+			flags:         2,
+			expectedErrno: wasip1.ErrnoInval,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+			slept = nil
+
+			maskMemory(t, mod, 1024)
+			mod.Memory().Write(0, clockSub(tc.clockID, tc.timeout, tc.flags))
+
+			out := uint32(128)
+			resultNevents := uint32(512)
+			requireErrnoResult(t, tc.expectedErrno, mod, wasip1.PollOneoffName,
+				uint64(0), uint64(out), uint64(1), uint64(resultNevents))
+			require.Equal(t, tc.expectedSlept, slept)
+
+			if tc.expectedErrno == wasip1.ErrnoSuccess {
+				outMem, ok := mod.Memory().Read(out, 32)
+				require.True(t, ok)
+				require.Equal(t, byte(wasip1.ErrnoSuccess), outMem[8])
+				require.Equal(t, byte(wasip1.EventTypeClock), outMem[10])
+			}
+		})
+	}
+}
+
 func requirePollOpenFile(t *testing.T, mod api.Module, name string) int32 {
 	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
 	preopen, ok := fsc.LookupFile(sys.FdPreopen)
@@ -787,6 +978,19 @@ func clockNsSub(ns uint64) []byte {
 	}
 }
 
+// subscription for a given clock, timeout in ns and subclockflags
+func clockSub(clockID uint32, ns uint64, flags uint16) []byte {
+	return []byte{
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, // userdata
+		wasip1.EventTypeClock, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, // event type and padding
+		byte(clockID), byte(clockID >> 8), byte(clockID >> 16), byte(clockID >> 24), 0x0, 0x0, 0x0, 0x0,
+		byte(ns), byte(ns >> 8), byte(ns >> 16), byte(ns >> 24),
+		byte(ns >> 32), byte(ns >> 40), byte(ns >> 48), byte(ns >> 56), // timeout (ns)
+		0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, // precision (ns)
+		byte(flags), byte(flags >> 8), 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, // flags
+	}
+}
+
 // subscription for an EventTypeFdRead on a given fd
 func fdReadSubFd(fd byte) []byte {
 	return []byte{
@@ -800,6 +1004,13 @@ func fdReadSubFd(fd byte) []byte {
 	}
 }
 
+// subscription for an EventTypeFdWrite on a given fd
+func fdWriteSubFd(fd byte) []byte {
+	sub := fdReadSubFd(fd)
+	sub[8] = wasip1.EventTypeFdWrite
+	return sub
+}
+
 func fdReadSubFdWithUserData(fd byte, userdata []byte) []byte {
 	return concat(
 		userdata,
@@ -2,6 +2,7 @@ package wasi_snapshot_preview1
 
 import (
 	"context"
+	"net/netip"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental/sys"
@@ -186,3 +187,326 @@ func sockShutdownFn(_ context.Context, mod api.Module, params []uint64) sys.Errn
 	// TODO: Map this instead of relying on syscall symbols.
 	return conn.Shutdown(sysHow)
 }
+
+// sockOpen is the WASI function named SockOpenName which opens a socket,
+// connected with sockConnect if a stream, or used with sockSendTo and
+// sockRecvFrom if a datagram socket.
+//
+// # Parameters
+//
+//   - af: address family, wasip1.AddressFamilyInet4 or
+//     wasip1.AddressFamilyInet6
+//   - socktype: socket type, wasip1.SockTypeDgram for UDP, or
+//     wasip1.SockTypeStream or wasip1.SockTypeAny for TCP
+//   - resultFd: offset to write the file descriptor of the socket
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EACCES: no destination is allowed, see experimental/sock.Config
+//   - sys.EAFNOSUPPORT: `af` is invalid
+//   - sys.EINVAL: `socktype` is invalid
+//
+// See https://github.com/second-state/wasmedge_wasi_socket
+var sockOpen = newHostFunc(
+	wasip1.SockOpenName,
+	sockOpenFn,
+	[]wasm.ValueType{i32, i32, i32},
+	"af", "socktype", "result.fd",
+)
+
+func sockOpenFn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	af := uint8(params[0])
+	socktype := uint8(params[1])
+	resultFd := uint32(params[2])
+
+	var ipv6 bool
+	switch af {
+	case wasip1.AddressFamilyInet4:
+	case wasip1.AddressFamilyInet6:
+		ipv6 = true
+	default:
+		return sys.EAFNOSUPPORT
+	}
+
+	var dgram bool
+	switch socktype {
+	case wasip1.SockTypeAny, wasip1.SockTypeStream:
+	case wasip1.SockTypeDgram:
+		dgram = true
+	default:
+		return sys.EINVAL
+	}
+
+	fd, errno := fsc.SockOpen(ipv6, dgram)
+	if errno != 0 {
+		return errno
+	}
+	if !mem.WriteUint32Le(resultFd, uint32(fd)) {
+		_ = fsc.CloseFile(fd)
+		return sys.EFAULT
+	}
+	return 0
+}
+
+// sockConnect is the WASI function named SockConnectName which connects a
+// stream socket opened by sockOpen.
+//
+// # Parameters
+//
+//   - fd: file descriptor of the socket
+//   - addr: offset of the address to connect to, see readSockAddr
+//   - port: port to connect to
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EACCES: the destination is not allowed
+//   - sys.EBADF: `fd` isn't a stream socket which isn't connected yet
+//   - sys.ECONNREFUSED: nothing listens on the destination
+//   - sys.EINTR: the context of the call was done before connecting
+//
+// # Notes
+//
+//   - This blocks until connected or the context of the call is done, even
+//     if the socket is non-blocking.
+//
+// See https://github.com/second-state/wasmedge_wasi_socket
+var sockConnect = newHostFunc(
+	wasip1.SockConnectName,
+	sockConnectFn,
+	[]wasm.ValueType{i32, i32, i32},
+	"fd", "addr", "port",
+)
+
+func sockConnectFn(ctx context.Context, mod api.Module, params []uint64) sys.Errno {
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	fd := int32(params[0])
+	addr, errno := readSockAddr(mod.Memory(), uint32(params[1]), uint32(params[2]))
+	if errno != 0 {
+		return errno
+	}
+	return fsc.SockConnect(ctx, fd, addr)
+}
+
+// sockSendTo is the WASI function named SockSendToName which sends a datagram
+// to an address.
+//
+// # Parameters
+//
+//   - fd: file descriptor of the datagram socket
+//   - siData: offset of the iovec array of the datagram
+//   - siDataLen: count of iovec in `siData`
+//   - addr: offset of the address to send to, see readSockAddr
+//   - port: port to send to
+//   - siFlags: must be zero
+//   - resultSoDatalen: offset to write the count of bytes sent
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EACCES: the destination is not allowed
+//   - sys.EBADF: `fd` isn't a datagram socket
+//
+// See https://github.com/second-state/wasmedge_wasi_socket
+var sockSendTo = newHostFunc(
+	wasip1.SockSendToName,
+	sockSendToFn,
+	[]wasm.ValueType{i32, i32, i32, i32, i32, i32, i32},
+	"fd", "si_data", "si_data_len", "addr", "port", "si_flags", "result.so_datalen",
+)
+
+func sockSendToFn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	fd := int32(params[0])
+	siData := uint32(params[1])
+	siDataCount := uint32(params[2])
+	siFlags := uint32(params[5])
+	resultSoDatalen := uint32(params[6])
+
+	if siFlags != 0 {
+		return sys.ENOTSUP
+	}
+
+	addr, errno := readSockAddr(mem, uint32(params[3]), uint32(params[4]))
+	if errno != 0 {
+		return errno
+	}
+	bufs, errno := iovsBufs(mem, siData, siDataCount)
+	if errno != 0 {
+		return errno
+	}
+
+	n, errno := fsc.SockSendTo(fd, bufs, addr)
+	if errno != 0 {
+		return errno
+	}
+	mem.WriteUint32Le(resultSoDatalen, uint32(n))
+	return 0
+}
+
+// sockRecvFrom is the WASI function named SockRecvFromName which receives a
+// datagram, and the address it was sent from.
+//
+// # Parameters
+//
+//   - fd: file descriptor of the datagram socket
+//   - riData: offset of the iovec array to receive the datagram into
+//   - riDataLen: count of iovec in `riData`
+//   - addr: offset of the address to write the sender to, see readSockAddr
+//   - riFlags: must be zero
+//   - resultPort: offset to write the port of the sender
+//   - resultRoDatalen: offset to write the count of bytes received
+//   - resultRoFlags: offset to write zero
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EAGAIN: `fd` is non-blocking and no datagram is available
+//   - sys.EBADF: `fd` isn't a datagram socket
+//
+// # Notes
+//
+//   - The rest of a datagram larger than `riData` is discarded.
+//
+// See https://github.com/second-state/wasmedge_wasi_socket
+var sockRecvFrom = newHostFunc(
+	wasip1.SockRecvFromName,
+	sockRecvFromFn,
+	[]wasm.ValueType{i32, i32, i32, i32, i32, i32, i32, i32},
+	"fd", "ri_data", "ri_data_len", "addr", "ri_flags", "result.port", "result.ro_datalen", "result.ro_flags",
+)
+
+func sockRecvFromFn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	fd := int32(params[0])
+	riData := uint32(params[1])
+	riDataCount := uint32(params[2])
+	addrOffset := uint32(params[3])
+	riFlags := uint8(params[4])
+	resultPort := uint32(params[5])
+	resultRoDatalen := uint32(params[6])
+	resultRoFlags := uint32(params[7])
+
+	if riFlags != 0 {
+		return sys.ENOTSUP
+	}
+
+	bufs, errno := iovsBufs(mem, riData, riDataCount)
+	if errno != 0 {
+		return errno
+	}
+	n, addr, errno := fsc.SockRecvFrom(fd, bufs)
+	if errno != 0 {
+		return errno
+	}
+
+	if errno = writeSockAddr(mem, addrOffset, addr); errno != 0 {
+		return errno
+	}
+	mem.WriteUint32Le(resultPort, uint32(addr.Port()))
+	mem.WriteUint32Le(resultRoDatalen, uint32(n))
+	mem.WriteUint16Le(resultRoFlags, 0)
+	return 0
+}
+
+// sockAddrBufLen is the length of the buffer of an address, which starts with
+// its address family.
+const sockAddrBufLen = 128
+
+// readSockAddr reads the address at offset addr, with the given port.
+//
+// The address is the struct `{ buf: *u8, buf_len: u32 }`, where buf is the 4
+// or 16 bytes of an IPv4 or IPv6 address, or a buffer of sockAddrBufLen bytes
+// starting with its address family as a uint16le, followed by the address.
+func readSockAddr(mem api.Memory, addr, port uint32) (netip.AddrPort, sys.Errno) {
+	if port > 0xffff {
+		return netip.AddrPort{}, sys.EINVAL
+	}
+	buf, errno := sockAddrBuf(mem, addr)
+	if errno != 0 {
+		return netip.AddrPort{}, errno
+	}
+	if len(buf) == sockAddrBufLen {
+		switch uint8(le.Uint16(buf)) {
+		case wasip1.AddressFamilyInet4:
+			buf = buf[2:6]
+		case wasip1.AddressFamilyInet6:
+			buf = buf[2:18]
+		default:
+			return netip.AddrPort{}, sys.EAFNOSUPPORT
+		}
+	}
+	ip, ok := netip.AddrFromSlice(buf)
+	if !ok {
+		return netip.AddrPort{}, sys.EINVAL
+	}
+	return netip.AddrPortFrom(ip, uint16(port)), 0
+}
+
+// writeSockAddr writes addr to the address at offset addrOffset, in the
+// format documented on readSockAddr.
+func writeSockAddr(mem api.Memory, addrOffset uint32, addr netip.AddrPort) sys.Errno {
+	buf, errno := sockAddrBuf(mem, addrOffset)
+	if errno != 0 {
+		return errno
+	}
+	ip := addr.Addr().Unmap()
+	switch len(buf) {
+	case sockAddrBufLen:
+		if ip.Is4() {
+			le.PutUint16(buf, uint16(wasip1.AddressFamilyInet4))
+		} else {
+			le.PutUint16(buf, uint16(wasip1.AddressFamilyInet6))
+		}
+		copy(buf[2:], ip.AsSlice())
+	case 4:
+		if !ip.Is4() {
+			return sys.EINVAL
+		}
+		copy(buf, ip.AsSlice())
+	default: // 16
+		ip16 := ip.As16()
+		copy(buf, ip16[:])
+	}
+	return 0
+}
+
+// sockAddrBuf returns the buffer of the address at offset addr.
+func sockAddrBuf(mem api.Memory, addr uint32) ([]byte, sys.Errno) {
+	bufOffset, ok := mem.ReadUint32Le(addr)
+	if !ok {
+		return nil, sys.EFAULT
+	}
+	bufLen, ok := mem.ReadUint32Le(addr + 4)
+	if !ok {
+		return nil, sys.EFAULT
+	}
+	switch bufLen {
+	case 4, 16, sockAddrBufLen:
+	default:
+		return nil, sys.EINVAL
+	}
+	buf, ok := mem.Read(bufOffset, bufLen)
+	if !ok {
+		return nil, sys.EFAULT
+	}
+	return buf, 0
+}
+
+// iovsBufs returns the buffers of an iovec array.
+func iovsBufs(mem api.Memory, iovs, iovsCount uint32) (bufs [][]byte, errno sys.Errno) {
+	_, errno = readv(mem, iovs, iovsCount, func(b []byte) (int, sys.Errno) {
+		bufs = append(bufs, b)
+		return len(b), 0
+	})
+	return
+}
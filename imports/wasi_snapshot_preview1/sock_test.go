@@ -2,6 +2,8 @@ package wasi_snapshot_preview1_test
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"net"
 	"strings"
 	"testing"
@@ -10,6 +12,7 @@ import (
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	experimentalsock "github.com/tetratelabs/wazero/experimental/sock"
+	socketapi "github.com/tetratelabs/wazero/internal/sock"
 	"github.com/tetratelabs/wazero/internal/sys"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasip1"
@@ -376,3 +379,259 @@ func requireTCPListenerAddr(t *testing.T, mod api.Module) *net.TCPAddr {
 	require.True(t, ok)
 	return sock.File.(addr).Addr()
 }
+
+func Test_sockOpen(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        experimentalsock.Config
+		af, socktype  uint8
+		expectedErrno wasip1.Errno
+		expectedLog   string
+	}{
+		{
+			name:          "no allowed destination",
+			config:        experimentalsock.NewConfig().WithTCPListener("127.0.0.1", 0),
+			af:            wasip1.AddressFamilyInet4,
+			socktype:      wasip1.SockTypeStream,
+			expectedErrno: wasip1.ErrnoAcces,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_open(af=1,socktype=2)
+<== (fd=,errno=EACCES)
+`,
+		},
+		{
+			name:          "invalid af",
+			config:        experimentalsock.NewConfig().WithAllowedDestination("127.0.0.1", 0),
+			af:            wasip1.AddressFamilyUnspec,
+			socktype:      wasip1.SockTypeStream,
+			expectedErrno: wasip1.ErrnoAfnosupport,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_open(af=0,socktype=2)
+<== (fd=,errno=EAFNOSUPPORT)
+`,
+		},
+		{
+			name:          "invalid socktype",
+			config:        experimentalsock.NewConfig().WithAllowedDestination("127.0.0.1", 0),
+			af:            wasip1.AddressFamilyInet4,
+			socktype:      3,
+			expectedErrno: wasip1.ErrnoInval,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_open(af=1,socktype=3)
+<== (fd=,errno=EINVAL)
+`,
+		},
+		{
+			name:     "stream",
+			config:   experimentalsock.NewConfig().WithAllowedDestination("127.0.0.1", 0),
+			af:       wasip1.AddressFamilyInet4,
+			socktype: wasip1.SockTypeStream,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_open(af=1,socktype=2)
+<== (fd=3,errno=ESUCCESS)
+`,
+		},
+		{
+			name:     "datagram",
+			config:   experimentalsock.NewConfig().WithAllowedDestination("127.0.0.1", 0),
+			af:       wasip1.AddressFamilyInet4,
+			socktype: wasip1.SockTypeDgram,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_open(af=1,socktype=1)
+<== (fd=3,errno=ESUCCESS)
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := experimentalsock.WithConfig(testCtx, tc.config)
+
+			mod, r, log := requireProxyModuleWithContext(ctx, t, wazero.NewModuleConfig())
+			defer r.Close(testCtx)
+
+			requireErrnoResult(t, tc.expectedErrno, mod, wasip1.SockOpenName, uint64(tc.af), uint64(tc.socktype), 128)
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+
+			if tc.expectedErrno == wasip1.ErrnoSuccess {
+				fd, _ := mod.Memory().ReadUint32Le(128)
+				f, ok := mod.(*wasm.ModuleInstance).Sys.FS().LookupFile(int32(fd))
+				require.True(t, ok)
+				if tc.socktype == wasip1.SockTypeDgram {
+					require.NotNil(t, f.File.(socketapi.UDPConn))
+				} else {
+					require.NotNil(t, f.File.(socketapi.TCPSocket))
+				}
+			}
+		})
+	}
+}
+
+func Test_sockConnect(t *testing.T) {
+	listener, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		name          string
+		allowedPort   int
+		addr          []byte
+		expectedErrno wasip1.Errno
+	}{
+		{
+			name:        "ipv4",
+			allowedPort: port,
+			addr:        []byte{127, 0, 0, 1},
+		},
+		{
+			name:        "ipv4 with address family",
+			allowedPort: 0,
+			addr:        append([]byte{wasip1.AddressFamilyInet4, 0, 127, 0, 0, 1}, make([]byte, 122)...),
+		},
+		{
+			name:          "not allowed",
+			allowedPort:   port + 1,
+			addr:          []byte{127, 0, 0, 1},
+			expectedErrno: wasip1.ErrnoAcces,
+		},
+		{
+			name:          "invalid address length",
+			allowedPort:   port,
+			addr:          []byte{127, 0, 0},
+			expectedErrno: wasip1.ErrnoInval,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := experimentalsock.WithConfig(testCtx, experimentalsock.NewConfig().WithAllowedDestination("127.0.0.0/8", tc.allowedPort))
+
+			mod, r, log := requireProxyModuleWithContext(ctx, t, wazero.NewModuleConfig())
+			defer r.Close(testCtx)
+
+			requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.SockOpenName, uint64(wasip1.AddressFamilyInet4), uint64(wasip1.SockTypeStream), 0)
+			fd, _ := mod.Memory().ReadUint32Le(0)
+
+			addr := uint32(8)
+			requireSockAddr(t, mod, addr, tc.addr)
+
+			log.Reset()
+			requireErrnoResult(t, tc.expectedErrno, mod, wasip1.SockConnectName, uint64(fd), uint64(addr), uint64(port))
+			require.Equal(t, fmt.Sprintf(`
+==> wasi_snapshot_preview1.sock_connect(fd=%d,addr=%d,port=%d)
+<== errno=%s
+`, fd, addr, port, wasip1.ErrnoName(tc.expectedErrno)), "\n"+log.String())
+			if tc.expectedErrno != wasip1.ErrnoSuccess {
+				return
+			}
+
+			conn, err := listener.Accept()
+			require.NoError(t, err)
+			defer conn.Close()
+
+			// The socket is now a connection, so it can send.
+			iovs := uint32(256)
+			require.True(t, mod.Memory().Write(iovs, []byte{
+				8, 1, 0, 0, // = iovs[0].offset
+				6, 0, 0, 0, // = iovs[0].length
+			}))
+			require.True(t, mod.Memory().WriteString(264, "wazero"))
+			requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.SockSendName, uint64(fd), uint64(iovs), 1, 0, 0)
+
+			buf := make([]byte, 6)
+			_, err = io.ReadFull(conn, buf)
+			require.NoError(t, err)
+			require.Equal(t, "wazero", string(buf))
+		})
+	}
+}
+
+func Test_sockSendToRecvFrom(t *testing.T) {
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+	peerPort := peer.LocalAddr().(*net.UDPAddr).Port
+
+	config := experimentalsock.NewConfig().
+		WithUDPSocket("127.0.0.1", 0).
+		WithAllowedDestination("127.0.0.1", peerPort)
+	ctx := experimentalsock.WithConfig(testCtx, config)
+
+	mod, r, log := requireProxyModuleWithContext(ctx, t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	fd := sys.FdPreopen
+	sock, ok := mod.(*wasm.ModuleInstance).Sys.FS().LookupFile(fd)
+	require.True(t, ok)
+	sockAddr := sock.File.(interface{ Addr() *net.UDPAddr }).Addr()
+
+	// Send "wazero" to the peer in two iovecs, which are one datagram.
+	addr := uint32(8)
+	requireSockAddr(t, mod, addr, []byte{127, 0, 0, 1})
+	iovs := uint32(64)
+	require.True(t, mod.Memory().Write(iovs, []byte{
+		128, 0, 0, 0, // = iovs[0].offset
+		4, 0, 0, 0, // = iovs[0].length
+		132, 0, 0, 0, // = iovs[1].offset
+		2, 0, 0, 0, // = iovs[1].length
+	}))
+	require.True(t, mod.Memory().WriteString(128, "wazero"))
+	resultSoDatalen := uint32(200)
+
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.SockSendToName,
+		uint64(fd), uint64(iovs), 2, uint64(addr), uint64(peerPort), 0, uint64(resultSoDatalen))
+	soDatalen, _ := mod.Memory().ReadUint32Le(resultSoDatalen)
+	require.Equal(t, uint32(6), soDatalen)
+
+	buf := make([]byte, 16)
+	n, from, err := peer.ReadFromUDP(buf)
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(buf[:n]))
+	require.Equal(t, sockAddr.Port, from.Port)
+
+	// Sending to a port which isn't allowed fails.
+	requireErrnoResult(t, wasip1.ErrnoAcces, mod, wasip1.SockSendToName,
+		uint64(fd), uint64(iovs), 2, uint64(addr), uint64(peerPort+1), 0, uint64(resultSoDatalen))
+
+	// Reply, and wait for it with poll_oneoff as the descriptor is shared.
+	_, err = peer.WriteToUDP([]byte("hello"), sockAddr)
+	require.NoError(t, err)
+
+	in := uint32(256)
+	require.True(t, mod.Memory().Write(in, fdReadSubFd(byte(fd))))
+	out, resultNevents := uint32(512), uint32(544)
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.PollOneoffName,
+		uint64(in), uint64(out), 1, uint64(resultNevents))
+	nevents, _ := mod.Memory().ReadUint32Le(resultNevents)
+	require.Equal(t, uint32(1), nevents)
+
+	// Receive into a 128-byte address, so it is prefixed by its family.
+	requireSockAddr(t, mod, addr, make([]byte, 128))
+	require.True(t, mod.Memory().Write(iovs, []byte{
+		128, 0, 0, 0, // = iovs[0].offset
+		16, 0, 0, 0, // = iovs[0].length
+	}))
+	resultPort, resultRoDatalen, resultRoFlags := uint32(200), uint32(204), uint32(208)
+
+	log.Reset()
+	requireErrnoResult(t, wasip1.ErrnoSuccess, mod, wasip1.SockRecvFromName,
+		uint64(fd), uint64(iovs), 1, uint64(addr), 0, uint64(resultPort), uint64(resultRoDatalen), uint64(resultRoFlags))
+	require.Equal(t, fmt.Sprintf(`
+==> wasi_snapshot_preview1.sock_recv_from(fd=3,ri_data=64,ri_data_len=1,addr=8,ri_flags=)
+<== (port=%d,ro_datalen=5,ro_flags=,errno=ESUCCESS)
+`, peerPort), "\n"+log.String())
+
+	data, _ := mod.Memory().Read(128, 5)
+	require.Equal(t, "hello", string(data))
+	addrBuf, _ := mod.Memory().Read(1024, 6)
+	require.Equal(t, []byte{wasip1.AddressFamilyInet4, 0, 127, 0, 0, 1}, addrBuf)
+}
+
+// requireSockAddr writes the address struct at offset addr, pointing to buf
+// written at offset 1024.
+func requireSockAddr(t *testing.T, mod api.Module, addr uint32, buf []byte) {
+	require.True(t, mod.Memory().WriteUint32Le(addr, 1024))
+	require.True(t, mod.Memory().WriteUint32Le(addr+4, uint32(len(buf))))
+	require.True(t, mod.Memory().Write(1024, buf))
+}
@@ -63,6 +63,12 @@ func Instantiate(ctx context.Context, r wazero.Runtime) (api.Closer, error) {
 //   - This is an interface for decoupling, not third-party implementations.
 //     All implementations are in wazero.
 type Builder interface {
+	// WithPolicy restricts the functions a guest can call and the rights of
+	// its file descriptors. Defaults to no restrictions.
+	//
+	// Note: Calls the policy doesn't permit return ErrnoNotcapable.
+	WithPolicy(Policy) Builder
+
 	// Compile compiles the ModuleName module. Call this before Instantiate.
 	//
 	// Note: This has the same effect as the same function on wazero.HostModuleBuilder.
@@ -76,15 +82,27 @@ type Builder interface {
 
 // NewBuilder returns a new Builder.
 func NewBuilder(r wazero.Runtime) Builder {
-	return &builder{r}
+	return &builder{r: r}
+}
+
+type builder struct {
+	r wazero.Runtime
+
+	// policy is nil when there are no restrictions.
+	policy *policy
 }
 
-type builder struct{ r wazero.Runtime }
+// WithPolicy implements Builder.WithPolicy
+func (b *builder) WithPolicy(p Policy) Builder {
+	ret := *b // copy
+	ret.policy = p.(*policy)
+	return &ret
+}
 
 // hostModuleBuilder returns a new wazero.HostModuleBuilder for ModuleName
 func (b *builder) hostModuleBuilder() wazero.HostModuleBuilder {
 	ret := b.r.NewHostModuleBuilder(ModuleName)
-	exportFunctions(ret)
+	exportFunctions(ret, b.policy)
 	return ret
 }
 
@@ -140,7 +158,7 @@ type functionExporter struct{}
 
 // ExportFunctions implements FunctionExporter.ExportFunctions
 func (functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
-	exportFunctions(builder)
+	exportFunctions(builder, nil)
 }
 
 // ## Translation notes
@@ -170,60 +188,70 @@ func (functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
 // See https://github.com/WebAssembly/WASI/issues/215
 // See https://wwa.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-instances%E2%91%A0.
 
-// exportFunctions adds all go functions that implement wasi.
-// These should be exported in the module named ModuleName.
-func exportFunctions(builder wazero.HostModuleBuilder) {
+// exportFunctions adds all go functions that implement wasi, enforcing the
+// policy unless nil. These should be exported in the module named ModuleName.
+func exportFunctions(builder wazero.HostModuleBuilder, p *policy) {
 	exporter := builder.(wasm.HostFuncExporter)
+	export := exporter.ExportHostFunc
+	if p != nil {
+		export = func(fn *wasm.HostFunc) { exporter.ExportHostFunc(p.WrapHostFunc(fn)) }
+	}
 
 	// Note: these are ordered per spec for consistency even if the resulting
 	// map can't guarantee that.
 	// See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#functions
-	exporter.ExportHostFunc(argsGet)
-	exporter.ExportHostFunc(argsSizesGet)
-	exporter.ExportHostFunc(environGet)
-	exporter.ExportHostFunc(environSizesGet)
-	exporter.ExportHostFunc(clockResGet)
-	exporter.ExportHostFunc(clockTimeGet)
-	exporter.ExportHostFunc(fdAdvise)
-	exporter.ExportHostFunc(fdAllocate)
-	exporter.ExportHostFunc(fdClose)
-	exporter.ExportHostFunc(fdDatasync)
-	exporter.ExportHostFunc(fdFdstatGet)
-	exporter.ExportHostFunc(fdFdstatSetFlags)
-	exporter.ExportHostFunc(fdFdstatSetRights)
-	exporter.ExportHostFunc(fdFilestatGet)
-	exporter.ExportHostFunc(fdFilestatSetSize)
-	exporter.ExportHostFunc(fdFilestatSetTimes)
-	exporter.ExportHostFunc(fdPread)
-	exporter.ExportHostFunc(fdPrestatGet)
-	exporter.ExportHostFunc(fdPrestatDirName)
-	exporter.ExportHostFunc(fdPwrite)
-	exporter.ExportHostFunc(fdRead)
-	exporter.ExportHostFunc(fdReaddir)
-	exporter.ExportHostFunc(fdRenumber)
-	exporter.ExportHostFunc(fdSeek)
-	exporter.ExportHostFunc(fdSync)
-	exporter.ExportHostFunc(fdTell)
-	exporter.ExportHostFunc(fdWrite)
-	exporter.ExportHostFunc(pathCreateDirectory)
-	exporter.ExportHostFunc(pathFilestatGet)
-	exporter.ExportHostFunc(pathFilestatSetTimes)
-	exporter.ExportHostFunc(pathLink)
-	exporter.ExportHostFunc(pathOpen)
-	exporter.ExportHostFunc(pathReadlink)
-	exporter.ExportHostFunc(pathRemoveDirectory)
-	exporter.ExportHostFunc(pathRename)
-	exporter.ExportHostFunc(pathSymlink)
-	exporter.ExportHostFunc(pathUnlinkFile)
-	exporter.ExportHostFunc(pollOneoff)
-	exporter.ExportHostFunc(procExit)
-	exporter.ExportHostFunc(procRaise)
-	exporter.ExportHostFunc(schedYield)
-	exporter.ExportHostFunc(randomGet)
-	exporter.ExportHostFunc(sockAccept)
-	exporter.ExportHostFunc(sockRecv)
-	exporter.ExportHostFunc(sockSend)
-	exporter.ExportHostFunc(sockShutdown)
+	export(argsGet)
+	export(argsSizesGet)
+	export(environGet)
+	export(environSizesGet)
+	export(clockResGet)
+	export(clockTimeGet)
+	export(fdAdvise)
+	export(fdAllocate)
+	export(fdClose)
+	export(fdDatasync)
+	export(fdFdstatGet)
+	export(fdFdstatSetFlags)
+	export(fdFdstatSetRights)
+	export(fdFilestatGet)
+	export(fdFilestatSetSize)
+	export(fdFilestatSetTimes)
+	export(fdPread)
+	export(fdPrestatGet)
+	export(fdPrestatDirName)
+	export(fdPwrite)
+	export(fdRead)
+	export(fdReaddir)
+	export(fdRenumber)
+	export(fdSeek)
+	export(fdSync)
+	export(fdTell)
+	export(fdWrite)
+	export(pathCreateDirectory)
+	export(pathFilestatGet)
+	export(pathFilestatSetTimes)
+	export(pathLink)
+	export(pathOpen)
+	export(pathReadlink)
+	export(pathRemoveDirectory)
+	export(pathRename)
+	export(pathSymlink)
+	export(pathUnlinkFile)
+	export(pollOneoff)
+	export(procExit)
+	export(procRaise)
+	export(schedYield)
+	export(randomGet)
+	export(sockAccept)
+	export(sockRecv)
+	export(sockSend)
+	export(sockShutdown)
+
+	// Note: these are not defined in WASI preview 1, rather WasmEdge.
+	export(sockOpen)
+	export(sockConnect)
+	export(sockSendTo)
+	export(sockRecvFrom)
 }
 
 // writeOffsetsAndNullTerminatedValues is used to write NUL-terminated values
@@ -0,0 +1,111 @@
+// Package wasi_threads contains the Go-defined function "thread-spawn",
+// imported by programs compiled for wasi-threads under the module name "wasi",
+// e.g. with `clang --target=wasm32-wasi-threads -pthread`.
+//
+// wasi-threads requires experimental.CoreFeaturesThreads, and a guest module
+// which imports its shared memory and exports "wasi_thread_start". Each
+// spawned thread runs on its own goroutine, in a new instance of the guest
+// module sharing the same memory.
+//
+// e.g. Call Instantiate before instantiating any wasm binary that imports
+// "wasi.thread-spawn", Otherwise, it will error due to missing imports.
+//
+//	ctx := context.Background()
+//	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+//		WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesThreads))
+//	defer r.Close(ctx) // This closes everything this Runtime created.
+//
+//	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+//	wasi_threads.MustInstantiate(ctx, r)
+//	mod, _ := r.Instantiate(ctx, wasm)
+//
+// # Relationship to WASI
+//
+// Threads share the configuration of the module which spawned them, such as
+// its file system and standard I/O, and use the functions it imports from
+// wasi_snapshot_preview1. When any thread exits with "proc_exit" or traps, the
+// module which spawned it and all its other threads are closed with the same
+// exit code, or 1 on trap. Closing the module closes all its threads.
+//
+// Note: A thread only notices that it was closed by another when it calls a
+// host function, unless wazero.RuntimeConfig WithCloseOnContextDone is set.
+//
+// See https://github.com/WebAssembly/wasi-threads
+package wasi_threads
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/threads"
+	"github.com/tetratelabs/wazero/internal/wasip1"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// ModuleName is the module name "thread-spawn" is exported into.
+const ModuleName = "wasi"
+
+const (
+	// ThreadSpawnName is the name of the function which spawns a thread.
+	ThreadSpawnName = "thread-spawn"
+
+	// ThreadStartName is the name of the function the guest module exports
+	// for wazero to call on each spawned thread.
+	ThreadStartName = threads.StartName
+)
+
+const i32 = wasm.ValueTypeI32
+
+// MustInstantiate calls Instantiate or panics on error.
+//
+// This is a simpler function for those who know the module ModuleName is not
+// already instantiated, and don't need to unload it.
+func MustInstantiate(ctx context.Context, r wazero.Runtime) {
+	if _, err := Instantiate(ctx, r); err != nil {
+		panic(err)
+	}
+}
+
+// Instantiate instantiates the ModuleName module into the runtime.
+//
+// # Notes
+//
+//   - Failure cases are documented on wazero.Runtime InstantiateModule.
+//   - Closing the wazero.Runtime has the same effect as closing the result.
+func Instantiate(ctx context.Context, r wazero.Runtime) (api.Closer, error) {
+	builder := r.NewHostModuleBuilder(ModuleName)
+	builder.(wasm.HostFuncExporter).ExportHostFunc(newThreadSpawn())
+	return builder.Instantiate(ctx)
+}
+
+// newThreadSpawn returns the function named ThreadSpawnName which spawns a
+// thread running ThreadStartName, and returns its thread ID.
+//
+// # Parameters
+//
+//   - startArg: opaque argument passed to ThreadStartName.
+//
+// Result is the positive thread ID, or a negated wasip1.Errno on failure.
+//
+// See https://github.com/WebAssembly/wasi-threads#api
+func newThreadSpawn() *wasm.HostFunc {
+	s := threads.NewSpawner()
+	return &wasm.HostFunc{
+		ExportName:  ThreadSpawnName,
+		Name:        ThreadSpawnName,
+		ParamTypes:  []wasm.ValueType{i32},
+		ParamNames:  []string{"start_arg"},
+		ResultTypes: []wasm.ValueType{i32},
+		ResultNames: []string{"tid"},
+		Code: wasm.Code{GoFunc: api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			startArg := uint32(stack[0])
+			tid, errno := s.Spawn(ctx, mod.(*wasm.ModuleInstance), startArg)
+			if errno != 0 {
+				stack[0] = uint64(uint32(-int32(wasip1.ToErrno(errno))))
+				return
+			}
+			stack[0] = uint64(tid)
+		})},
+	}
+}
@@ -0,0 +1,29 @@
+package wasi_threads_test
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/imports/wasi_threads"
+)
+
+// This shows how to instantiate the imports needed by programs compiled for
+// wasi-threads.
+func Example_instantiate() {
+	ctx := context.Background()
+
+	// wasi-threads programs use shared memory and atomics.
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesThreads))
+	defer r.Close(ctx) // This closes everything this Runtime created.
+
+	// This adds the "wasi_snapshot_preview1" module used by the program, and
+	// the "wasi" module with "thread-spawn" used to create threads.
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	wasi_threads.MustInstantiate(ctx, r)
+
+	// Output:
+}
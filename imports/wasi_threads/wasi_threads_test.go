@@ -0,0 +1,220 @@
+package wasi_threads
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasip1"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+var testCtx = context.Background()
+
+// threadsWasm imports its shared memory, "env.started" which is called first
+// on each thread with its thread ID and start argument, and proc_exit. It
+// exports:
+//   - "spawn" which calls ThreadSpawnName with the start argument.
+//   - ThreadStartName which returns if the start argument is 0, loops forever
+//     if it is 1, traps if it is 2, and otherwise calls proc_exit with it.
+//   - "exit" which calls proc_exit.
+var threadsWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32, i32}},
+		{Params: []wasm.ValueType{i32}},
+	},
+	ImportSection: []wasm.Import{
+		{Module: "env", Name: "memory", Type: wasm.ExternTypeMemory, DescMem: &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
+		{Module: ModuleName, Name: ThreadSpawnName, Type: wasm.ExternTypeFunc, DescFunc: 0},
+		{Module: "env", Name: "started", Type: wasm.ExternTypeFunc, DescFunc: 1},
+		{Module: wasi_snapshot_preview1.ModuleName, Name: wasip1.ProcExitName, Type: wasm.ExternTypeFunc, DescFunc: 2},
+	},
+	ImportMemoryCount:   1,
+	ImportFunctionCount: 3,
+	FunctionSection:     []wasm.Index{0, 1, 2},
+	CodeSection: []wasm.Code{
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeCall, 1,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeI32Eqz,
+			wasm.OpcodeBrIf, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeI32Const, 1,
+			wasm.OpcodeI32Eq,
+			wasm.OpcodeIf, 0x40,
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeBr, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeI32Const, 2,
+			wasm.OpcodeI32Eq,
+			wasm.OpcodeIf, 0x40,
+			wasm.OpcodeUnreachable,
+			wasm.OpcodeEnd,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeCall, 2,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 2, wasm.OpcodeEnd}},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "spawn", Type: wasm.ExternTypeFunc, Index: 3},
+		{Name: ThreadStartName, Type: wasm.ExternTypeFunc, Index: 4},
+		{Name: "exit", Type: wasm.ExternTypeFunc, Index: 5},
+	},
+})
+
+type started struct {
+	mod      api.Module
+	tid, arg uint32
+}
+
+func TestThreadSpawn(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config wazero.RuntimeConfig
+	}{
+		{name: "interpreter", config: wazero.NewRuntimeConfigInterpreter()},
+		{name: "default", config: wazero.NewRuntimeConfig()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := wazero.NewRuntimeWithConfig(testCtx, tc.config.
+				WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesThreads).
+				WithCloseOnContextDone(true))
+			defer r.Close(testCtx)
+
+			startedCh := make(chan started, 4)
+			_, err := r.NewHostModuleBuilder("env").
+				NewFunctionBuilder().
+				WithFunc(func(ctx context.Context, mod api.Module, tid, arg uint32) {
+					startedCh <- started{mod: mod, tid: tid, arg: arg}
+				}).Export("started").
+				ExportSharedMemory("memory", 1, 1).
+				Instantiate(testCtx)
+			require.NoError(t, err)
+			wasi_snapshot_preview1.MustInstantiate(testCtx, r)
+			MustInstantiate(testCtx, r)
+
+			compiled, err := r.CompileModule(testCtx, threadsWasm)
+			require.NoError(t, err)
+
+			// instantiate returns the module and a channel receiving its exit code.
+			instantiate := func(t *testing.T) (api.Module, <-chan uint32) {
+				exitCh := make(chan uint32, 1)
+				ctx := experimental.WithCloseNotifier(testCtx, experimental.CloseNotifyFunc(
+					func(_ context.Context, exitCode uint32) { exitCh <- exitCode }))
+				mod, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(t.Name()))
+				require.NoError(t, err)
+				return mod, exitCh
+			}
+			spawn := func(t *testing.T, mod api.Module, arg uint32) started {
+				res, err := mod.ExportedFunction("spawn").Call(testCtx, uint64(arg))
+				require.NoError(t, err)
+				s := <-startedCh
+				require.Equal(t, uint32(res[0]), s.tid)
+				require.Equal(t, arg, s.arg)
+				require.NotEqual(t, mod, s.mod)
+				return s
+			}
+
+			t.Run("returns", func(t *testing.T) {
+				mod, _ := instantiate(t)
+				defer mod.Close(testCtx)
+
+				for i := uint32(1); i <= 2; i++ {
+					s := spawn(t, mod, 0)
+					require.Equal(t, i, s.tid)
+					waitClosed(t, s.mod)
+				}
+				require.False(t, mod.IsClosed())
+			})
+
+			t.Run("proc_exit from thread", func(t *testing.T) {
+				mod, exitCh := instantiate(t)
+				spinning := spawn(t, mod, 1)
+				spawn(t, mod, 7)
+
+				require.Equal(t, uint32(7), <-exitCh)
+				require.True(t, mod.IsClosed())
+				waitClosed(t, spinning.mod)
+			})
+
+			t.Run("trap in thread", func(t *testing.T) {
+				mod, exitCh := instantiate(t)
+				spawn(t, mod, 2)
+
+				require.Equal(t, uint32(1), <-exitCh)
+				require.True(t, mod.IsClosed())
+			})
+
+			t.Run("proc_exit from main", func(t *testing.T) {
+				mod, exitCh := instantiate(t)
+				spinning := spawn(t, mod, 1)
+
+				_, err := mod.ExportedFunction("exit").Call(testCtx, 3)
+				var exitErr *sys.ExitError
+				require.True(t, errors.As(err, &exitErr), err)
+				require.Equal(t, uint32(3), exitErr.ExitCode())
+				require.Equal(t, uint32(3), <-exitCh)
+				require.True(t, spinning.mod.IsClosed())
+
+				_, err = mod.ExportedFunction("spawn").Call(testCtx, 0)
+				require.Error(t, err)
+			})
+		})
+	}
+}
+
+func TestThreadSpawn_memoryNotImported(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+	MustInstantiate(testCtx, r)
+
+	mod, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32}},
+		},
+		ImportSection: []wasm.Import{
+			{Module: ModuleName, Name: ThreadSpawnName, Type: wasm.ExternTypeFunc, DescFunc: 0},
+		},
+		ImportFunctionCount: 1,
+		MemorySection:       []wasm.Memory{{Min: 1}},
+		FunctionSection:     []wasm.Index{0, 1},
+		CodeSection: []wasm.Code{
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeEnd}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "spawn", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: ThreadStartName, Type: wasm.ExternTypeFunc, Index: 2},
+		},
+	}))
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("spawn").Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, -int32(wasip1.ErrnoNotsup), int32(res[0]))
+}
+
+// waitClosed waits for a thread to be closed by the goroutine running it.
+func waitClosed(t *testing.T, mod api.Module) {
+	for deadline := time.Now().Add(10 * time.Second); !mod.IsClosed(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("thread not closed")
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package wasip2
+
+import (
+	"bytes"
+	"context"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/component"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+var (
+	terminalInputType  = &component.ResourceType{Name: "terminal-input"}
+	terminalOutputType = &component.ResourceType{Name: "terminal-output"}
+)
+
+// cliEnvironment implements "wasi:cli/environment".
+var cliEnvironment = &component.HostInstance{
+	Name: "wasi:cli/environment@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"get-environment": func(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+			environ := inst.Sys().Environ()
+			ret := make([]any, len(environ))
+			for i, e := range environ {
+				k, v, _ := bytes.Cut(e, []byte{'='})
+				ret[i] = []any{string(k), string(v)}
+			}
+			return []any{ret}
+		},
+		"get-arguments": func(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+			args := inst.Sys().Args()
+			ret := make([]any, len(args))
+			for i, a := range args {
+				ret[i] = string(a)
+			}
+			return []any{ret}
+		},
+		// initial-cwd is unknown, as paths are relative to pre-opens.
+		"initial-cwd": func(context.Context, *component.ComponentInstance, []any) []any {
+			return []any{component.None}
+		},
+	},
+}
+
+// cliExit implements "wasi:cli/exit", which exits with code zero on success,
+// or one on error.
+var cliExit = &component.HostInstance{
+	Name: "wasi:cli/exit@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"exit": func(ctx context.Context, inst *component.ComponentInstance, params []any) []any {
+			exitCode := params[0].(component.Variant).Case
+
+			// Ensure other callers see the exit code.
+			_ = inst.CloseWithExitCode(ctx, exitCode)
+
+			// Prevent any code from executing after this function.
+			panic(sys.NewExitError(exitCode))
+		},
+	},
+}
+
+// cliStdin implements "wasi:cli/stdin".
+var cliStdin = &component.HostInstance{
+	Name: "wasi:cli/stdin@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"get-stdin": func(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+			return []any{&inputStream{file: stdioFile(inst, internalsys.FdStdin), offset: -1}}
+		},
+	},
+}
+
+// cliStdout implements "wasi:cli/stdout".
+var cliStdout = &component.HostInstance{
+	Name: "wasi:cli/stdout@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"get-stdout": func(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+			return []any{&outputStream{file: stdioFile(inst, internalsys.FdStdout), offset: -1}}
+		},
+	},
+}
+
+// cliStderr implements "wasi:cli/stderr".
+var cliStderr = &component.HostInstance{
+	Name: "wasi:cli/stderr@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"get-stderr": func(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+			return []any{&outputStream{file: stdioFile(inst, internalsys.FdStderr), offset: -1}}
+		},
+	},
+}
+
+// stdioFile returns the file of a standard stream of the instance, or traps
+// if it was closed.
+func stdioFile(inst *component.ComponentInstance, fd int32) experimentalsys.File {
+	f, ok := inst.Sys().FS().LookupFile(fd)
+	if !ok {
+		panic(experimentalsys.EBADF)
+	}
+	return f.File
+}
+
+// cliTerminalInput implements "wasi:cli/terminal-input".
+var cliTerminalInput = &component.HostInstance{
+	Name:      "wasi:cli/terminal-input@" + Version,
+	Resources: map[string]*component.ResourceType{"terminal-input": terminalInputType},
+}
+
+// cliTerminalOutput implements "wasi:cli/terminal-output".
+var cliTerminalOutput = &component.HostInstance{
+	Name:      "wasi:cli/terminal-output@" + Version,
+	Resources: map[string]*component.ResourceType{"terminal-output": terminalOutputType},
+}
+
+// noTerminal returns none, as no standard stream is a terminal.
+func noTerminal(context.Context, *component.ComponentInstance, []any) []any {
+	return []any{component.None}
+}
+
+// cliTerminalStdin implements "wasi:cli/terminal-stdin".
+var cliTerminalStdin = &component.HostInstance{
+	Name:  "wasi:cli/terminal-stdin@" + Version,
+	Funcs: map[string]component.HostFunc{"get-terminal-stdin": noTerminal},
+}
+
+// cliTerminalStdout implements "wasi:cli/terminal-stdout".
+var cliTerminalStdout = &component.HostInstance{
+	Name:  "wasi:cli/terminal-stdout@" + Version,
+	Funcs: map[string]component.HostFunc{"get-terminal-stdout": noTerminal},
+}
+
+// cliTerminalStderr implements "wasi:cli/terminal-stderr".
+var cliTerminalStderr = &component.HostInstance{
+	Name:  "wasi:cli/terminal-stderr@" + Version,
+	Funcs: map[string]component.HostFunc{"get-terminal-stderr": noTerminal},
+}
@@ -0,0 +1,42 @@
+package wasip2
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/component"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestCLIEnvironment(t *testing.T) {
+	inst := newTestInstance(t, wazero.NewModuleConfig().
+		WithArgs("a", "b").
+		WithEnv("K", "V=1"))
+
+	require.Equal(t, []any{[]any{[]any{"K", "V=1"}}}, call(inst, cliEnvironment, "get-environment"))
+	require.Equal(t, []any{[]any{"a", "b"}}, call(inst, cliEnvironment, "get-arguments"))
+	require.Equal(t, []any{component.None}, call(inst, cliEnvironment, "initial-cwd"))
+}
+
+func TestCLIStdio(t *testing.T) {
+	var stdout, stderr strings.Builder
+	inst := newTestInstance(t, wazero.NewModuleConfig().
+		WithStdin(strings.NewReader("in")).
+		WithStdout(&stdout).
+		WithStderr(&stderr))
+
+	stdin := call(inst, cliStdin, "get-stdin")[0]
+	require.Equal(t, []any{component.Ok([]byte("in"))}, call(inst, ioStreams, "[method]input-stream.blocking-read", stdin, uint64(10)))
+	require.Equal(t, []any{component.Err(component.Variant{Case: 1})}, call(inst, ioStreams, "[method]input-stream.read", stdin, uint64(10)))
+
+	out := call(inst, cliStdout, "get-stdout")[0]
+	require.Equal(t, []any{component.Ok(nil)}, call(inst, ioStreams, "[method]output-stream.write", out, []byte("out")))
+	require.Equal(t, "out", stdout.String())
+
+	errOut := call(inst, cliStderr, "get-stderr")[0]
+	require.Equal(t, []any{component.Ok(nil)}, call(inst, ioStreams, "[method]output-stream.blocking-write-zeroes-and-flush", errOut, uint64(2)))
+	require.Equal(t, "\x00\x00", stderr.String())
+
+	require.Equal(t, []any{component.None}, call(inst, cliTerminalStdout, "get-terminal-stdout"))
+}
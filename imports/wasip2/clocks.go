@@ -0,0 +1,48 @@
+package wasip2
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/internal/component"
+)
+
+// clocksMonotonicClock implements "wasi:clocks/monotonic-clock", where an
+// instant and a duration are nanoseconds.
+var clocksMonotonicClock = &component.HostInstance{
+	Name: "wasi:clocks/monotonic-clock@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"now": func(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+			return []any{uint64(inst.Sys().Nanotime())}
+		},
+		"resolution": func(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+			return []any{uint64(inst.Sys().NanotimeResolution())}
+		},
+		"subscribe-instant": func(_ context.Context, inst *component.ComponentInstance, params []any) []any {
+			return []any{&pollable{sys: inst.Sys(), deadline: int64(params[0].(uint64))}}
+		},
+		"subscribe-duration": func(_ context.Context, inst *component.ComponentInstance, params []any) []any {
+			sys := inst.Sys()
+			return []any{&pollable{sys: sys, deadline: sys.Nanotime() + int64(params[0].(uint64))}}
+		},
+	},
+}
+
+// datetime returns the value of the record "datetime" for nanoseconds since
+// the epoch.
+func datetime(nanos int64) []any {
+	return []any{uint64(nanos / 1e9), uint32(nanos % 1e9)}
+}
+
+// clocksWallClock implements "wasi:clocks/wall-clock".
+var clocksWallClock = &component.HostInstance{
+	Name: "wasi:clocks/wall-clock@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"now": func(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+			sec, nsec := inst.Sys().Walltime()
+			return []any{[]any{uint64(sec), uint32(nsec)}}
+		},
+		"resolution": func(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+			return []any{datetime(int64(inst.Sys().WalltimeResolution()))}
+		},
+	},
+}
@@ -0,0 +1,41 @@
+package wasip2
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestClocks(t *testing.T) {
+	var nanotime int64
+	inst := newTestInstance(t, wazero.NewModuleConfig().
+		WithNanotime(func() int64 { return nanotime }, 1).
+		WithNanosleep(func(ns int64) { nanotime += ns }).
+		WithWalltime(func() (int64, int32) { return 1640995200, 5 }, 1))
+
+	require.Equal(t, []any{[]any{uint64(1640995200), uint32(5)}}, call(inst, clocksWallClock, "now"))
+	require.Equal(t, []any{[]any{uint64(0), uint32(1)}}, call(inst, clocksWallClock, "resolution"))
+
+	nanotime = 100
+	require.Equal(t, []any{uint64(100)}, call(inst, clocksMonotonicClock, "now"))
+	require.Equal(t, []any{uint64(1)}, call(inst, clocksMonotonicClock, "resolution"))
+
+	soon := call(inst, clocksMonotonicClock, "subscribe-duration", uint64(50))[0]
+	later := call(inst, clocksMonotonicClock, "subscribe-instant", uint64(300))[0]
+	ready := call(inst, ioStreams, "[method]input-stream.subscribe", nil)[0]
+	require.Equal(t, []any{false}, call(inst, ioPoll, "[method]pollable.ready", soon))
+
+	// A pollable of a stream is always ready.
+	require.Equal(t, []any{[]any{uint32(2)}}, call(inst, ioPoll, "poll", []any{soon, later, ready}))
+
+	// Otherwise, poll blocks until the earliest deadline.
+	require.Equal(t, []any{[]any{uint32(0)}}, call(inst, ioPoll, "poll", []any{soon, later}))
+	require.Equal(t, int64(150), nanotime)
+
+	call(inst, ioPoll, "[method]pollable.block", later)
+	require.Equal(t, int64(300), nanotime)
+
+	err := require.CapturePanic(func() { call(inst, ioPoll, "poll", []any{}) })
+	require.EqualError(t, err, "poll: no pollables")
+}
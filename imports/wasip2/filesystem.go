@@ -0,0 +1,504 @@
+package wasip2
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"strings"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/component"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+var (
+	descriptorType           = &component.ResourceType{Name: "descriptor", Drop: func(rep any) { rep.(*descriptor).close() }}
+	directoryEntryStreamType = &component.ResourceType{Name: "directory-entry-stream", Drop: func(rep any) { _ = rep.(experimentalsys.File).Close() }}
+)
+
+// error-code is the enum of errors of wasi:filesystem.
+const (
+	errorCodeAccess uint32 = iota
+	errorCodeWouldBlock
+	errorCodeAlready
+	errorCodeBadDescriptor
+	errorCodeBusy
+	errorCodeDeadlock
+	errorCodeQuota
+	errorCodeExist
+	errorCodeFileTooLarge
+	errorCodeIllegalByteSequence
+	errorCodeInProgress
+	errorCodeInterrupted
+	errorCodeInvalid
+	errorCodeIO
+	errorCodeIsDirectory
+	errorCodeLoop
+	errorCodeTooManyLinks
+	errorCodeMessageSize
+	errorCodeNameTooLong
+	errorCodeNoDevice
+	errorCodeNoEntry
+	errorCodeNoLock
+	errorCodeInsufficientMemory
+	errorCodeInsufficientSpace
+	errorCodeNotDirectory
+	errorCodeNotEmpty
+	errorCodeNotRecoverable
+	errorCodeUnsupported
+	errorCodeNoTTY
+	errorCodeNoSuchDevice
+	errorCodeOverflow
+	errorCodeNotPermitted
+	errorCodePipe
+	errorCodeReadOnly
+	errorCodeInvalidSeek
+	errorCodeTextFileBusy
+	errorCodeCrossDevice
+)
+
+// errorCode returns the error-code of a non-zero errno.
+func errorCode(errno experimentalsys.Errno) uint32 {
+	switch errno {
+	case experimentalsys.EACCES:
+		return errorCodeAccess
+	case experimentalsys.EAGAIN:
+		return errorCodeWouldBlock
+	case experimentalsys.EBADF:
+		return errorCodeBadDescriptor
+	case experimentalsys.EEXIST:
+		return errorCodeExist
+	case experimentalsys.EINTR:
+		return errorCodeInterrupted
+	case experimentalsys.EFAULT, experimentalsys.EINVAL, experimentalsys.ENOTSOCK:
+		return errorCodeInvalid
+	case experimentalsys.EISDIR:
+		return errorCodeIsDirectory
+	case experimentalsys.ELOOP:
+		return errorCodeLoop
+	case experimentalsys.ENAMETOOLONG:
+		return errorCodeNameTooLong
+	case experimentalsys.ENOENT:
+		return errorCodeNoEntry
+	case experimentalsys.ENOSYS, experimentalsys.ENOTSUP:
+		return errorCodeUnsupported
+	case experimentalsys.ENOTDIR:
+		return errorCodeNotDirectory
+	case experimentalsys.ERANGE:
+		return errorCodeOverflow
+	case experimentalsys.ENOTEMPTY:
+		return errorCodeNotEmpty
+	case experimentalsys.EPERM:
+		return errorCodeNotPermitted
+	case experimentalsys.EROFS:
+		return errorCodeReadOnly
+	default:
+		return errorCodeIO
+	}
+}
+
+// result returns the value of a result<T, error-code>, which fails with the
+// error code of errno if it isn't zero.
+func result(v any, errno experimentalsys.Errno) []any {
+	if errno != 0 {
+		return []any{component.Err(errorCode(errno))}
+	}
+	return []any{component.Ok(v)}
+}
+
+// descriptor-type is the enum of the types of files.
+const (
+	descriptorTypeUnknown uint32 = iota
+	descriptorTypeBlockDevice
+	descriptorTypeCharacterDevice
+	descriptorTypeDirectory
+	descriptorTypeFifo
+	descriptorTypeSymbolicLink
+	descriptorTypeRegularFile
+	descriptorTypeSocket
+)
+
+// fileType returns the descriptor-type of a file mode.
+func fileType(mode fs.FileMode) uint32 {
+	switch mode.Type() {
+	case 0:
+		return descriptorTypeRegularFile
+	case fs.ModeDir:
+		return descriptorTypeDirectory
+	case fs.ModeSymlink:
+		return descriptorTypeSymbolicLink
+	case fs.ModeNamedPipe:
+		return descriptorTypeFifo
+	case fs.ModeSocket:
+		return descriptorTypeSocket
+	case fs.ModeDevice:
+		return descriptorTypeBlockDevice
+	case fs.ModeDevice | fs.ModeCharDevice:
+		return descriptorTypeCharacterDevice
+	default:
+		return descriptorTypeUnknown
+	}
+}
+
+// descriptor-flags, path-flags and open-flags are flags of descriptors and
+// the paths opened relative to them.
+const (
+	descriptorFlagsRead uint32 = 1 << iota
+	descriptorFlagsWrite
+	descriptorFlagsFileIntegritySync
+	descriptorFlagsDataIntegritySync
+	descriptorFlagsRequestedWriteSync
+	descriptorFlagsMutateDirectory
+)
+
+const pathFlagsSymlinkFollow uint32 = 1
+
+const (
+	openFlagsCreate uint32 = 1 << iota
+	openFlagsDirectory
+	openFlagsExclusive
+	openFlagsTruncate
+)
+
+// descriptor is the representation of the resource "descriptor", a file of
+// the file system of a pre-open.
+type descriptor struct {
+	fs experimentalsys.FS
+	// path is the path of the file in fs, or "." for the pre-open.
+	path string
+	file experimentalsys.File
+	// flags are the descriptor-flags it was opened with.
+	flags uint32
+	// isPreopen is true if the file is owned by the FSContext.
+	isPreopen bool
+}
+
+func (d *descriptor) close() {
+	if !d.isPreopen {
+		_ = d.file.Close()
+	}
+}
+
+// at returns the path of a file relative to the directory d. Like
+// wasi_snapshot_preview1, a path must not be absolute or escape d.
+func (d *descriptor) at(p string) (string, experimentalsys.Errno) {
+	// Keep a trailing slash, which requires the file to be a directory.
+	hasTrailingSlash := strings.HasSuffix(p, "/")
+	p = path.Clean(p)
+	if !fs.ValidPath(p) {
+		return "", experimentalsys.EPERM
+	}
+	if hasTrailingSlash {
+		p += "/"
+	}
+	if d.path != "." {
+		// Join via concat to avoid name conflict on path.Join
+		p = d.path + "/" + p
+	}
+	return p, 0
+}
+
+// stat returns the stat of the file at p relative to d, following a symbolic
+// link if pathFlags has symlink-follow.
+func (d *descriptor) stat(pathFlags uint32, p string) (sys.Stat_t, experimentalsys.Errno) {
+	p, errno := d.at(p)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	if pathFlags&pathFlagsSymlinkFollow != 0 {
+		return d.fs.Stat(p)
+	}
+	return d.fs.Lstat(p)
+}
+
+// descriptorStat returns the value of the record "descriptor-stat".
+func descriptorStat(st sys.Stat_t) []any {
+	return []any{
+		fileType(st.Mode),
+		st.Nlink,
+		uint64(st.Size),
+		component.Some(datetime(st.Atim)),
+		component.Some(datetime(st.Mtim)),
+		component.Some(datetime(st.Ctim)),
+	}
+}
+
+// metadataHash returns the value of the record "metadata-hash-value", which
+// is unique for each file of a device.
+func metadataHash(st sys.Stat_t) []any {
+	return []any{st.Ino, st.Dev}
+}
+
+// timestamp returns the epoch nanoseconds of a new-timestamp to pass to
+// Utimens.
+func timestamp(inst *component.ComponentInstance, v any) int64 {
+	switch ts := v.(component.Variant); ts.Case {
+	case 0: // no-change
+		return experimentalsys.UTIME_OMIT
+	case 1: // now
+		return inst.Sys().WalltimeNanos()
+	default: // timestamp
+		dt := ts.Value.([]any)
+		return int64(dt[0].(uint64))*1e9 + int64(dt[1].(uint32))
+	}
+}
+
+// fsFunc adapts a function of a descriptor with its params to a HostFunc.
+func fsFunc(fn func(inst *component.ComponentInstance, d *descriptor, params []any) []any) component.HostFunc {
+	return func(_ context.Context, inst *component.ComponentInstance, params []any) []any {
+		return fn(inst, params[0].(*descriptor), params[1:])
+	}
+}
+
+// pathFunc adapts a function of a path relative to a descriptor, which is
+// its first param, to a HostFunc.
+func pathFunc(fn func(fs experimentalsys.FS, p string) experimentalsys.Errno) component.HostFunc {
+	return fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+		p, errno := d.at(params[0].(string))
+		if errno == 0 {
+			errno = fn(d.fs, p)
+		}
+		return result(nil, errno)
+	})
+}
+
+// filesystemTypes implements "wasi:filesystem/types". The functions which
+// advise or sync succeed without effect if the file system doesn't support
+// them.
+var filesystemTypes = &component.HostInstance{
+	Name: "wasi:filesystem/types@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"[method]descriptor.read-via-stream": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			return result(&inputStream{file: d.file, offset: int64(params[0].(uint64))}, 0)
+		}),
+		"[method]descriptor.write-via-stream": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			return result(&outputStream{file: d.file, offset: int64(params[0].(uint64))}, 0)
+		}),
+		"[method]descriptor.append-via-stream": fsFunc(func(_ *component.ComponentInstance, d *descriptor, _ []any) []any {
+			return result(&outputStream{file: d.file, append: true}, 0)
+		}),
+		"[method]descriptor.advise": fsFunc(func(*component.ComponentInstance, *descriptor, []any) []any {
+			return result(nil, 0)
+		}),
+		"[method]descriptor.sync-data": fsFunc(func(_ *component.ComponentInstance, d *descriptor, _ []any) []any {
+			return result(nil, ignoreENOSYS(d.file.Datasync()))
+		}),
+		"[method]descriptor.get-flags": fsFunc(func(_ *component.ComponentInstance, d *descriptor, _ []any) []any {
+			return result(d.flags, 0)
+		}),
+		"[method]descriptor.get-type": fsFunc(func(_ *component.ComponentInstance, d *descriptor, _ []any) []any {
+			st, errno := d.file.Stat()
+			return result(fileType(st.Mode), errno)
+		}),
+		"[method]descriptor.set-size": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			return result(nil, d.file.Truncate(int64(params[0].(uint64))))
+		}),
+		"[method]descriptor.set-times": fsFunc(func(inst *component.ComponentInstance, d *descriptor, params []any) []any {
+			return result(nil, d.file.Utimens(timestamp(inst, params[0]), timestamp(inst, params[1])))
+		}),
+		"[method]descriptor.read": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			s := &inputStream{file: d.file, offset: int64(params[1].(uint64))}
+			buf, errno, eof := s.read(params[0].(uint64))
+			return result([]any{buf, eof}, errno)
+		}),
+		"[method]descriptor.write": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			buf := params[0].([]byte)
+			n, errno := d.file.Pwrite(buf, int64(params[1].(uint64)))
+			return result(uint64(n), errno)
+		}),
+		"[method]descriptor.read-directory": fsFunc(func(_ *component.ComponentInstance, d *descriptor, _ []any) []any {
+			// Open the directory again, so each stream reads all entries.
+			dir, errno := d.fs.OpenFile(d.path, experimentalsys.O_RDONLY|experimentalsys.O_DIRECTORY, 0)
+			return result(dir, errno)
+		}),
+		"[method]descriptor.sync": fsFunc(func(_ *component.ComponentInstance, d *descriptor, _ []any) []any {
+			return result(nil, ignoreENOSYS(d.file.Sync()))
+		}),
+		"[method]descriptor.create-directory-at": pathFunc(func(fs experimentalsys.FS, p string) experimentalsys.Errno {
+			return fs.Mkdir(p, 0o777)
+		}),
+		"[method]descriptor.stat": fsFunc(func(_ *component.ComponentInstance, d *descriptor, _ []any) []any {
+			st, errno := d.file.Stat()
+			return result(descriptorStat(st), errno)
+		}),
+		"[method]descriptor.stat-at": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			st, errno := d.stat(params[0].(uint32), params[1].(string))
+			return result(descriptorStat(st), errno)
+		}),
+		"[method]descriptor.set-times-at": fsFunc(func(inst *component.ComponentInstance, d *descriptor, params []any) []any {
+			p, errno := d.at(params[1].(string))
+			if errno == 0 {
+				errno = d.fs.Utimens(p, timestamp(inst, params[2]), timestamp(inst, params[3]))
+			}
+			return result(nil, errno)
+		}),
+		"[method]descriptor.link-at": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			return d.pathsAt(params[1].(string), params[2].(*descriptor), params[3].(string), d.fs.Link)
+		}),
+		"[method]descriptor.open-at": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			return d.openAt(params[0].(uint32), params[1].(string), params[2].(uint32), params[3].(uint32))
+		}),
+		"[method]descriptor.readlink-at": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			p, errno := d.at(params[0].(string))
+			if errno != 0 {
+				return result(nil, errno)
+			}
+			return result(d.fs.Readlink(p))
+		}),
+		"[method]descriptor.remove-directory-at": pathFunc(func(fs experimentalsys.FS, p string) experimentalsys.Errno {
+			return fs.Rmdir(p)
+		}),
+		"[method]descriptor.rename-at": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			return d.pathsAt(params[0].(string), params[1].(*descriptor), params[2].(string), d.fs.Rename)
+		}),
+		"[method]descriptor.symlink-at": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			// The old path is the target of the link, which isn't resolved.
+			p, errno := d.at(params[1].(string))
+			if errno == 0 {
+				errno = d.fs.Symlink(params[0].(string), p)
+			}
+			return result(nil, errno)
+		}),
+		"[method]descriptor.unlink-file-at": pathFunc(func(fs experimentalsys.FS, p string) experimentalsys.Errno {
+			return fs.Unlink(p)
+		}),
+		"[method]descriptor.is-same-object": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			st, errno := d.file.Stat()
+			other, otherErrno := params[0].(*descriptor).file.Stat()
+			return []any{errno == 0 && otherErrno == 0 && st.Dev == other.Dev && st.Ino == other.Ino}
+		}),
+		"[method]descriptor.metadata-hash": fsFunc(func(_ *component.ComponentInstance, d *descriptor, _ []any) []any {
+			st, errno := d.file.Stat()
+			return result(metadataHash(st), errno)
+		}),
+		"[method]descriptor.metadata-hash-at": fsFunc(func(_ *component.ComponentInstance, d *descriptor, params []any) []any {
+			st, errno := d.stat(params[0].(uint32), params[1].(string))
+			return result(metadataHash(st), errno)
+		}),
+		"[method]directory-entry-stream.read-directory-entry": func(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+			return readDirectoryEntry(params[0].(experimentalsys.File))
+		},
+		"filesystem-error-code": func(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+			if e, ok := params[0].(*ioErr); ok {
+				return []any{component.Some(errorCode(e.errno))}
+			}
+			return []any{component.None}
+		},
+	},
+	Resources: map[string]*component.ResourceType{
+		"descriptor":             descriptorType,
+		"directory-entry-stream": directoryEntryStreamType,
+	},
+}
+
+// ignoreENOSYS returns zero if errno is ENOSYS.
+func ignoreENOSYS(errno experimentalsys.Errno) experimentalsys.Errno {
+	if errno == experimentalsys.ENOSYS {
+		return 0
+	}
+	return errno
+}
+
+// pathsAt returns the result of fn with a path relative to d and another
+// relative to newDir, which must be in the same file system.
+func (d *descriptor) pathsAt(oldPath string, newDir *descriptor, newPath string, fn func(from, to string) experimentalsys.Errno) []any {
+	if newDir.fs != d.fs {
+		return []any{component.Err(errorCodeCrossDevice)}
+	}
+	from, errno := d.at(oldPath)
+	if errno == 0 {
+		var to string
+		if to, errno = newDir.at(newPath); errno == 0 {
+			errno = fn(from, to)
+		}
+	}
+	return result(nil, errno)
+}
+
+// openAt implements "[method]descriptor.open-at".
+func (d *descriptor) openAt(pathFlags uint32, p string, openFlags, flags uint32) []any {
+	p, errno := d.at(p)
+	if errno != 0 {
+		return result(nil, errno)
+	}
+
+	var oflag experimentalsys.Oflag
+	switch flags & (descriptorFlagsRead | descriptorFlagsWrite) {
+	case descriptorFlagsWrite:
+		oflag = experimentalsys.O_WRONLY
+	case descriptorFlagsRead | descriptorFlagsWrite:
+		oflag = experimentalsys.O_RDWR
+	default:
+		oflag = experimentalsys.O_RDONLY
+	}
+	if openFlags&openFlagsCreate != 0 {
+		oflag |= experimentalsys.O_CREAT
+	}
+	if openFlags&openFlagsDirectory != 0 {
+		oflag |= experimentalsys.O_DIRECTORY
+	}
+	if openFlags&openFlagsExclusive != 0 {
+		oflag |= experimentalsys.O_EXCL
+	}
+	if openFlags&openFlagsTruncate != 0 {
+		oflag |= experimentalsys.O_TRUNC
+	}
+	if pathFlags&pathFlagsSymlinkFollow == 0 {
+		oflag |= experimentalsys.O_NOFOLLOW
+	}
+	if flags&(descriptorFlagsFileIntegritySync|descriptorFlagsRequestedWriteSync) != 0 {
+		oflag |= experimentalsys.O_SYNC
+	} else if flags&descriptorFlagsDataIntegritySync != 0 {
+		oflag |= experimentalsys.O_DSYNC
+	}
+
+	f, errno := d.fs.OpenFile(p, oflag, 0o666)
+	if errno != 0 {
+		return result(nil, errno)
+	}
+	return result(&descriptor{fs: d.fs, path: strings.TrimSuffix(p, "/"), file: f, flags: flags}, 0)
+}
+
+// readDirectoryEntry returns the value of result<option<directory-entry>,
+// error-code> for the next entry of dir, except "." and "..".
+func readDirectoryEntry(dir experimentalsys.File) []any {
+	for {
+		dirents, errno := dir.Readdir(1)
+		if errno != 0 {
+			return result(nil, errno)
+		} else if len(dirents) == 0 {
+			return result(component.None, 0)
+		}
+		if name := dirents[0].Name; name != "." && name != ".." {
+			return result(component.Some([]any{fileType(dirents[0].Type), name}), 0)
+		}
+	}
+}
+
+// filesystemPreopens implements "wasi:filesystem/preopens", with the
+// directories pre-opened by wazero.ModuleConfig WithFSConfig.
+var filesystemPreopens = &component.HostInstance{
+	Name: "wasi:filesystem/preopens@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"get-directories": func(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+			var ret []any
+			fsc := inst.Sys().FS()
+			for fd := internalsys.FdPreopen; ; fd++ {
+				f, ok := fsc.LookupFile(fd)
+				if !ok || !f.IsPreopen {
+					break
+				}
+				d := &descriptor{
+					fs:        f.FS,
+					path:      ".",
+					file:      f.File,
+					flags:     descriptorFlagsRead | descriptorFlagsMutateDirectory,
+					isPreopen: true,
+				}
+				ret = append(ret, []any{d, f.Name})
+			}
+			return []any{ret}
+		},
+	},
+}
@@ -0,0 +1,81 @@
+package wasip2
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/component"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestFilesystem(t *testing.T) {
+	inst := newTestInstance(t, wazero.NewModuleConfig().
+		WithFSConfig(wazero.NewFSConfig().WithDirMount(t.TempDir(), "/")))
+
+	fs := func(name string, params ...any) any {
+		return call(inst, filesystemTypes, "[method]descriptor."+name, params...)[0]
+	}
+
+	preopens := call(inst, filesystemPreopens, "get-directories")[0].([]any)
+	require.Equal(t, 1, len(preopens))
+	root := preopens[0].([]any)[0].(*descriptor)
+	require.Equal(t, "/", preopens[0].([]any)[1])
+	require.Equal(t, component.Ok(descriptorTypeDirectory), fs("get-type", root))
+
+	require.Equal(t, component.Ok(nil), fs("create-directory-at", root, "dir"))
+	dir := fs("open-at", root, pathFlagsSymlinkFollow, "dir", openFlagsDirectory, descriptorFlagsRead).(component.Variant).Value.(*descriptor)
+	defer dir.close()
+
+	t.Run("read and write", func(t *testing.T) {
+		f := fs("open-at", dir, uint32(0), "a.txt", openFlagsCreate|openFlagsExclusive, descriptorFlagsRead|descriptorFlagsWrite).(component.Variant).Value.(*descriptor)
+		defer f.close()
+
+		require.Equal(t, component.Ok(uint64(5)), fs("write", f, []byte("hello"), uint64(0)))
+		require.Equal(t, component.Ok([]any{[]byte("ell"), false}), fs("read", f, uint64(3), uint64(1)))
+		require.Equal(t, component.Ok([]any{[]byte{}, true}), fs("read", f, uint64(3), uint64(5)))
+
+		out := fs("append-via-stream", f).(component.Variant).Value
+		require.Equal(t, []any{component.Ok(nil)}, call(inst, ioStreams, "[method]output-stream.write", out, []byte("!")))
+		in := fs("read-via-stream", f, uint64(4)).(component.Variant).Value
+		require.Equal(t, []any{component.Ok([]byte("o!"))}, call(inst, ioStreams, "[method]input-stream.read", in, uint64(10)))
+
+		st := fs("stat-at", root, uint32(0), "dir/a.txt").(component.Variant).Value.([]any)
+		require.Equal(t, descriptorTypeRegularFile, st[0])
+		require.Equal(t, uint64(6), st[2])
+		require.Equal(t, []any{true}, call(inst, filesystemTypes, "[method]descriptor.is-same-object", f, f))
+		require.Equal(t, []any{false}, call(inst, filesystemTypes, "[method]descriptor.is-same-object", f, dir))
+	})
+
+	t.Run("directory entries", func(t *testing.T) {
+		entries := fs("read-directory", dir).(component.Variant).Value
+		defer directoryEntryStreamType.Drop(entries)
+
+		next := func() any {
+			return call(inst, filesystemTypes, "[method]directory-entry-stream.read-directory-entry", entries)[0]
+		}
+		require.Equal(t, component.Ok(component.Some([]any{descriptorTypeRegularFile, "a.txt"})), next())
+		require.Equal(t, component.Ok(component.None), next())
+	})
+
+	t.Run("rename and unlink", func(t *testing.T) {
+		require.Equal(t, component.Ok(nil), fs("rename-at", dir, "a.txt", root, "b.txt"))
+		require.Equal(t, component.Err(errorCodeNoEntry), fs("stat-at", dir, uint32(0), "a.txt"))
+		require.Equal(t, component.Ok(nil), fs("unlink-file-at", root, "b.txt"))
+		require.Equal(t, component.Ok(nil), fs("create-directory-at", dir, "sub"))
+		require.Equal(t, component.Err(errorCodeNotEmpty), fs("remove-directory-at", root, "dir"))
+		require.Equal(t, component.Ok(nil), fs("remove-directory-at", dir, "sub"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		require.Equal(t, component.Err(errorCodeNotPermitted), fs("open-at", dir, uint32(0), "../../etc", uint32(0), descriptorFlagsRead))
+		require.Equal(t, component.Err(errorCodeNotPermitted), fs("stat-at", root, uint32(0), "/etc"))
+		require.Equal(t, component.Err(errorCodeExist), fs("create-directory-at", root, "dir"))
+
+		res := fs("open-at", root, uint32(0), "missing", uint32(0), descriptorFlagsRead)
+		require.Equal(t, component.Err(errorCodeNoEntry), res)
+
+		streamErr := call(inst, ioStreams, "[method]input-stream.read", &inputStream{file: dir.file, offset: 0}, uint64(1))[0]
+		e := streamErr.(component.Variant).Value.(component.Variant).Value
+		require.Equal(t, []any{component.Some(errorCodeIsDirectory)}, call(inst, filesystemTypes, "filesystem-error-code", e))
+	})
+}
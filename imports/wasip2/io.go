@@ -0,0 +1,234 @@
+package wasip2
+
+import (
+	"context"
+	"errors"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/component"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+)
+
+// maxIOLen is the maximum count of bytes read or written by a call to a
+// stream, which bounds the memory allocated for it.
+const maxIOLen = 64 * 1024
+
+var (
+	errorType        = &component.ResourceType{Name: "error"}
+	pollableType     = &component.ResourceType{Name: "pollable"}
+	inputStreamType  = &component.ResourceType{Name: "input-stream"}
+	outputStreamType = &component.ResourceType{Name: "output-stream"}
+)
+
+// ioErr is the representation of the resource "error", the cause of a
+// failed operation.
+type ioErr struct {
+	errno experimentalsys.Errno
+}
+
+// ioError implements "wasi:io/error".
+var ioError = &component.HostInstance{
+	Name: "wasi:io/error@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"[method]error.to-debug-string": func(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+			return []any{params[0].(*ioErr).errno.Error()}
+		},
+	},
+	Resources: map[string]*component.ResourceType{"error": errorType},
+}
+
+// pollable is the representation of the resource "pollable", which is ready
+// when the monotonic clock reaches its deadline, or always if it is zero.
+type pollable struct {
+	sys      *internalsys.Context
+	deadline int64
+}
+
+func (p *pollable) ready() bool {
+	return p.deadline == 0 || p.sys.Nanotime() >= p.deadline
+}
+
+func (p *pollable) block() {
+	if !p.ready() {
+		p.sys.Nanosleep(p.deadline - p.sys.Nanotime())
+	}
+}
+
+// readyPollable returns a pollable which is always ready.
+func readyPollable(inst *component.ComponentInstance) *pollable {
+	return &pollable{sys: inst.Sys()}
+}
+
+// ioPoll implements "wasi:io/poll".
+var ioPoll = &component.HostInstance{
+	Name: "wasi:io/poll@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"[method]pollable.ready": func(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+			return []any{params[0].(*pollable).ready()}
+		},
+		"[method]pollable.block": func(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+			params[0].(*pollable).block()
+			return nil
+		},
+		"poll": func(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+			in := params[0].([]any)
+			if len(in) == 0 {
+				panic(errors.New("poll: no pollables"))
+			}
+			for {
+				var ready []any
+				var next *pollable
+				for i, v := range in {
+					if p := v.(*pollable); p.ready() {
+						ready = append(ready, uint32(i))
+					} else if next == nil || p.deadline < next.deadline {
+						next = p
+					}
+				}
+				if len(ready) > 0 {
+					return []any{ready}
+				}
+				next.block()
+			}
+		},
+	},
+	Resources: map[string]*component.ResourceType{"pollable": pollableType},
+}
+
+// inputStream is the representation of the resource "input-stream", which
+// reads a file sequentially if offset is negative.
+type inputStream struct {
+	file   experimentalsys.File
+	offset int64
+}
+
+// read reads up to n bytes, and returns true at the end of the stream.
+func (s *inputStream) read(n uint64) ([]byte, experimentalsys.Errno, bool) {
+	buf := make([]byte, min(n, maxIOLen))
+	if len(buf) == 0 {
+		return buf, 0, false
+	}
+	var read int
+	var errno experimentalsys.Errno
+	if s.offset < 0 {
+		read, errno = s.file.Read(buf)
+	} else if read, errno = s.file.Pread(buf, s.offset); errno == 0 {
+		s.offset += int64(read)
+	}
+	return buf[:read], errno, errno == 0 && read == 0
+}
+
+// outputStream is the representation of the resource "output-stream", which
+// writes a file sequentially if offset is negative, or at its end if append
+// is set.
+type outputStream struct {
+	file   experimentalsys.File
+	offset int64
+	append bool
+}
+
+// write writes all of buf.
+func (s *outputStream) write(buf []byte) experimentalsys.Errno {
+	if s.append {
+		st, errno := s.file.Stat()
+		if errno != 0 {
+			return errno
+		}
+		s.offset = st.Size
+	}
+	for len(buf) > 0 {
+		var n int
+		var errno experimentalsys.Errno
+		if s.offset < 0 {
+			n, errno = s.file.Write(buf)
+		} else if n, errno = s.file.Pwrite(buf, s.offset); errno == 0 {
+			s.offset += int64(n)
+		}
+		if errno != 0 {
+			return errno
+		}
+		buf = buf[n:]
+	}
+	return 0
+}
+
+// streamResult returns the value of a result<T, stream-error>, which fails
+// with the error errno if it isn't zero, or when closed.
+func streamResult(v any, errno experimentalsys.Errno, closed bool) []any {
+	if errno != 0 {
+		return []any{component.Err(component.Variant{Case: 0, Value: &ioErr{errno: errno}})}
+	} else if closed {
+		return []any{component.Err(component.Variant{Case: 1})}
+	}
+	return []any{component.Ok(v)}
+}
+
+func streamRead(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+	buf, errno, eof := params[0].(*inputStream).read(params[1].(uint64))
+	return streamResult(buf, errno, eof)
+}
+
+func streamSkip(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+	buf, errno, eof := params[0].(*inputStream).read(params[1].(uint64))
+	return streamResult(uint64(len(buf)), errno, eof)
+}
+
+func streamSubscribe(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+	return []any{readyPollable(inst)}
+}
+
+func streamCheckWrite(context.Context, *component.ComponentInstance, []any) []any {
+	return streamResult(uint64(maxIOLen), 0, false)
+}
+
+func streamWrite(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+	return streamResult(nil, params[0].(*outputStream).write(params[1].([]byte)), false)
+}
+
+// streamFlush succeeds, as writes aren't buffered.
+func streamFlush(context.Context, *component.ComponentInstance, []any) []any {
+	return streamResult(nil, 0, false)
+}
+
+func streamWriteZeroes(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+	n := params[1].(uint64)
+	if n > maxIOLen {
+		panic(errors.New("write-zeroes: length exceeds check-write"))
+	}
+	return streamResult(nil, params[0].(*outputStream).write(make([]byte, n)), false)
+}
+
+func streamSplice(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+	buf, errno, eof := params[1].(*inputStream).read(params[2].(uint64))
+	if errno == 0 && !eof {
+		errno = params[0].(*outputStream).write(buf)
+	}
+	return streamResult(uint64(len(buf)), errno, eof)
+}
+
+// ioStreams implements "wasi:io/streams". Blocking and non-blocking
+// functions are the same, as streams are always ready.
+var ioStreams = &component.HostInstance{
+	Name: "wasi:io/streams@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"[method]input-stream.read":                             streamRead,
+		"[method]input-stream.blocking-read":                    streamRead,
+		"[method]input-stream.skip":                             streamSkip,
+		"[method]input-stream.blocking-skip":                    streamSkip,
+		"[method]input-stream.subscribe":                        streamSubscribe,
+		"[method]output-stream.check-write":                     streamCheckWrite,
+		"[method]output-stream.write":                           streamWrite,
+		"[method]output-stream.blocking-write-and-flush":        streamWrite,
+		"[method]output-stream.flush":                           streamFlush,
+		"[method]output-stream.blocking-flush":                  streamFlush,
+		"[method]output-stream.subscribe":                       streamSubscribe,
+		"[method]output-stream.write-zeroes":                    streamWriteZeroes,
+		"[method]output-stream.blocking-write-zeroes-and-flush": streamWriteZeroes,
+		"[method]output-stream.splice":                          streamSplice,
+		"[method]output-stream.blocking-splice":                 streamSplice,
+	},
+	Resources: map[string]*component.ResourceType{
+		"input-stream":  inputStreamType,
+		"output-stream": outputStreamType,
+	},
+}
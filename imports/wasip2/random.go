@@ -0,0 +1,61 @@
+package wasip2
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/tetratelabs/wazero/internal/component"
+)
+
+// randomBytes reads n bytes from the random source of the instance.
+func randomBytes(inst *component.ComponentInstance, n uint64) []byte {
+	if n > math.MaxUint32 {
+		panic(errors.New("random: length too large"))
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(inst.Sys().RandSource(), buf); err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+func getRandomBytes(_ context.Context, inst *component.ComponentInstance, params []any) []any {
+	return []any{randomBytes(inst, params[0].(uint64))}
+}
+
+func getRandomU64(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+	return []any{binary.LittleEndian.Uint64(randomBytes(inst, 8))}
+}
+
+// randomRandom implements "wasi:random/random".
+var randomRandom = &component.HostInstance{
+	Name: "wasi:random/random@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"get-random-bytes": getRandomBytes,
+		"get-random-u64":   getRandomU64,
+	},
+}
+
+// randomInsecure implements "wasi:random/insecure" with the same source as
+// randomRandom.
+var randomInsecure = &component.HostInstance{
+	Name: "wasi:random/insecure@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"get-insecure-random-bytes": getRandomBytes,
+		"get-insecure-random-u64":   getRandomU64,
+	},
+}
+
+// randomInsecureSeed implements "wasi:random/insecure-seed".
+var randomInsecureSeed = &component.HostInstance{
+	Name: "wasi:random/insecure-seed@" + Version,
+	Funcs: map[string]component.HostFunc{
+		"insecure-seed": func(_ context.Context, inst *component.ComponentInstance, _ []any) []any {
+			b := randomBytes(inst, 16)
+			return []any{[]any{binary.LittleEndian.Uint64(b), binary.LittleEndian.Uint64(b[8:])}}
+		},
+	},
+}
@@ -0,0 +1,22 @@
+package wasip2
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestRandom(t *testing.T) {
+	inst := newTestInstance(t, wazero.NewModuleConfig().
+		WithRandSource(bytes.NewReader(bytes.Repeat([]byte{1}, 30))))
+
+	require.Equal(t, []any{[]byte{1, 1, 1}}, call(inst, randomRandom, "get-random-bytes", uint64(3)))
+	require.Equal(t, []any{uint64(0x0101010101010101)}, call(inst, randomInsecure, "get-insecure-random-u64"))
+	require.Equal(t, []any{[]any{uint64(0x0101010101010101), uint64(0x0101010101010101)}}, call(inst, randomInsecureSeed, "insecure-seed"))
+
+	// The source is exhausted.
+	err := require.CapturePanic(func() { call(inst, randomRandom, "get-random-u64") })
+	require.EqualError(t, err, "unexpected EOF")
+}
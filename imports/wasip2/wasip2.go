@@ -0,0 +1,102 @@
+// Package wasip2 contains Go-defined host instances of the interfaces of WASI
+// 0.2, also known as WASI Preview 2, imported by components such as those
+// compiled with `cargo component` or `GOOS=wasip2`.
+//
+// The host instances are backed by the same system context as
+// wasi_snapshot_preview1, which is configured with wazero.ModuleConfig, e.g.
+// its arguments, environment, standard streams, clocks, random source and
+// file systems.
+//
+// e.g. Call Instantiate to instantiate a component compiled with the same
+// runtime, and Run to call its "wasi:cli/run" export.
+//
+//	ctx := context.Background()
+//	r := wazero.NewRuntime(ctx)
+//	defer r.Close(ctx) // This closes everything this Runtime created.
+//
+//	compiled, _ := component.Compile(ctx, r, wasm)
+//	inst, _ := wasip2.Instantiate(ctx, r, compiled, wazero.NewModuleConfig().WithStdout(os.Stdout))
+//	err := wasip2.Run(ctx, inst)
+//
+// # Notes
+//
+//   - The implemented interfaces are the ones of the "wasi:cli/command"
+//     world: wasi:cli, wasi:io, wasi:clocks, wasi:random and wasi:filesystem.
+//     They satisfy imports of any version compatible with Version.
+//   - Streams and pollables are always ready, except the ones of clocks, so
+//     reading a stream blocks until data is available.
+//   - None of the standard streams are terminals.
+//
+// See https://github.com/WebAssembly/WASI/tree/main/wasip2
+package wasip2
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental/component"
+	internalcomponent "github.com/tetratelabs/wazero/internal/component"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Version is the version of the WASI interfaces implemented.
+const Version = "0.2.0"
+
+// RunName is the name of the function of the "wasi:cli/run" interface, which
+// Run calls.
+const RunName = "wasi:cli/run@" + Version + "#run"
+
+// HostInstances returns the host instances of all interfaces implemented, to
+// import into a component with component.Instantiate.
+func HostInstances() []component.HostInstance {
+	hosts := []*internalcomponent.HostInstance{
+		cliEnvironment, cliExit, cliStdin, cliStdout, cliStderr,
+		cliTerminalInput, cliTerminalOutput, cliTerminalStdin, cliTerminalStdout, cliTerminalStderr,
+		ioError, ioPoll, ioStreams,
+		clocksMonotonicClock, clocksWallClock,
+		randomRandom, randomInsecure, randomInsecureSeed,
+		filesystemTypes, filesystemPreopens,
+	}
+	ret := make([]component.HostInstance, len(hosts))
+	for i, h := range hosts {
+		ret[i] = h
+	}
+	return ret
+}
+
+// Instantiate instantiates a component, importing the HostInstances.
+//
+// See component.Instantiate for the use of config.
+func Instantiate(ctx context.Context, r wazero.Runtime, compiled component.CompiledComponent, config wazero.ModuleConfig) (component.Instance, error) {
+	return component.Instantiate(ctx, r, compiled, config, HostInstances()...)
+}
+
+// Run calls the function RunName of a component instantiated with the
+// HostInstances, and closes it.
+//
+// The error is nil if the function succeeded or the component exited with
+// code zero. Otherwise, it is a sys.ExitError, with code one if the function
+// returned an error.
+func Run(ctx context.Context, inst component.Instance) error {
+	defer inst.Close(ctx)
+
+	run := inst.ExportedFunction(RunName)
+	if run == nil {
+		return fmt.Errorf("%s is not exported", RunName)
+	}
+	res, err := run.Call(ctx)
+	if se, ok := err.(*sys.ExitError); ok {
+		if se.ExitCode() == 0 { // Don't err on success.
+			return nil
+		}
+		return se
+	} else if err != nil {
+		return err
+	}
+	if res[0].(component.Variant).Case != 0 {
+		_ = inst.CloseWithExitCode(ctx, 1)
+		return sys.NewExitError(1)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package wasip2
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental/component"
+	internalcomponent "github.com/tetratelabs/wazero/internal/component"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/componentencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+var testCtx = context.Background()
+
+const i32 = wasm.ValueTypeI32
+
+// libcWasm exports a memory, and a realloc which bumps a pointer.
+var libcWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection:   []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32, i32, i32}, Results: []wasm.ValueType{i32}}},
+	MemorySection: []wasm.Memory{{Min: 1}},
+	GlobalSection: []wasm.Global{{
+		Type: wasm.GlobalType{ValType: i32, Mutable: true},
+		Init: wasm.NewConstantExpressionFromI32(1024),
+	}},
+	FunctionSection: []wasm.Index{0},
+	CodeSection: []wasm.Code{{LocalTypes: []wasm.ValueType{i32}, Body: []byte{
+		// ptr := (next + align - 1) & -align
+		wasm.OpcodeGlobalGet, 0,
+		wasm.OpcodeLocalGet, 2,
+		wasm.OpcodeI32Add,
+		wasm.OpcodeI32Const, 1,
+		wasm.OpcodeI32Sub,
+		wasm.OpcodeI32Const, 0,
+		wasm.OpcodeLocalGet, 2,
+		wasm.OpcodeI32Sub,
+		wasm.OpcodeI32And,
+		wasm.OpcodeLocalTee, 4,
+		// next = ptr + size
+		wasm.OpcodeLocalGet, 3,
+		wasm.OpcodeI32Add,
+		wasm.OpcodeGlobalSet, 0,
+		wasm.OpcodeLocalGet, 4,
+		wasm.OpcodeEnd,
+	}}},
+	ExportSection: []wasm.Export{
+		{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+		{Name: "realloc", Type: wasm.ExternTypeFunc, Index: 0},
+	},
+})
+
+// helloWasm exports "run", which writes "hello\n" to the output stream
+// returned by "host.get-stdout", drops it, and returns the discriminant of
+// the result of the write.
+var helloWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32, i32, i32, i32}},
+		{Params: []wasm.ValueType{i32}},
+	},
+	ImportSection: []wasm.Import{
+		{Module: "host", Name: "get-stdout", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		{Module: "host", Name: "write", Type: wasm.ExternTypeFunc, DescFunc: 1},
+		{Module: "host", Name: "drop", Type: wasm.ExternTypeFunc, DescFunc: 2},
+		{Module: "host", Name: "memory", Type: wasm.ExternTypeMemory, DescMem: &wasm.Memory{Min: 1}},
+	},
+	ImportFunctionCount: 3,
+	ImportMemoryCount:   1,
+	FunctionSection:     []wasm.Index{0},
+	CodeSection: []wasm.Code{{LocalTypes: []wasm.ValueType{i32}, Body: []byte{
+		wasm.OpcodeCall, 0,
+		wasm.OpcodeLocalSet, 0,
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeI32Const, 16,
+		wasm.OpcodeI32Const, 6,
+		wasm.OpcodeI32Const, 32,
+		wasm.OpcodeCall, 1,
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeCall, 2,
+		wasm.OpcodeI32Const, 32,
+		wasm.OpcodeI32Load8U, 0, 0,
+		wasm.OpcodeEnd,
+	}}},
+	DataSection: []wasm.DataSegment{{
+		OffsetExpression: wasm.NewConstantExpressionFromI32(16),
+		Init:             []byte("hello\n"),
+	}},
+	ExportSection: []wasm.Export{{Name: "run", Type: wasm.ExternTypeFunc, Index: 3}},
+})
+
+func valType(kind internalcomponent.ValTypeKind) internalcomponent.ValTypeRef {
+	return internalcomponent.ValTypeRef{Primitive: kind}
+}
+
+func typeRef(index uint32) *internalcomponent.ValTypeRef {
+	return &internalcomponent.ValTypeRef{Index: index}
+}
+
+func typeDecl(def internalcomponent.TypeDef) internalcomponent.Decl {
+	return internalcomponent.Decl{Kind: internalcomponent.DeclKindType, Type: def}
+}
+
+func exportDecl(name string, sort internalcomponent.Sort, index uint32) internalcomponent.Decl {
+	return internalcomponent.Decl{Kind: internalcomponent.DeclKindExport, Name: name, Desc: internalcomponent.ExternDesc{Sort: sort, Index: index}}
+}
+
+func outerTypeDecl(index uint32) internalcomponent.Decl {
+	return internalcomponent.Decl{Kind: internalcomponent.DeclKindAlias, Alias: &internalcomponent.Alias{
+		Sort: internalcomponent.SortType, Kind: internalcomponent.AliasKindOuter, Count: 1, Index: index,
+	}}
+}
+
+func aliasExport(sort internalcomponent.Sort, instance uint32, name string) *internalcomponent.Alias {
+	kind := internalcomponent.AliasKindExport
+	if sort.IsCore() {
+		kind = internalcomponent.AliasKindCoreExport
+	}
+	return &internalcomponent.Alias{Sort: sort, Kind: kind, Instance: instance, Name: name}
+}
+
+// helloComponent imports the interfaces needed to write to stdout, and
+// exports the run function of helloWasm as "wasi:cli/run".
+var helloComponent = componentencoding.Encode(&internalcomponent.Component{Definitions: []internalcomponent.Definition{
+	// type 0
+	&internalcomponent.Type{Def: &internalcomponent.InstanceTypeDef{Decls: []internalcomponent.Decl{
+		{Kind: internalcomponent.DeclKindExport, Name: "error", Desc: internalcomponent.ExternDesc{Sort: internalcomponent.SortType, SubResource: true}},
+	}}},
+	&internalcomponent.Import{Name: "wasi:io/error@0.2.0", Desc: internalcomponent.ExternDesc{Sort: internalcomponent.SortInstance, Index: 0}},
+	aliasExport(internalcomponent.SortType, 0, "error"), // type 1
+	// type 2
+	&internalcomponent.Type{Def: &internalcomponent.InstanceTypeDef{Decls: []internalcomponent.Decl{
+		outerTypeDecl(1),
+		exportDecl("error", internalcomponent.SortType, 0),
+		{Kind: internalcomponent.DeclKindExport, Name: "output-stream", Desc: internalcomponent.ExternDesc{Sort: internalcomponent.SortType, SubResource: true}},
+		typeDecl(&internalcomponent.ValTypeDef{Kind: internalcomponent.ValTypeKindOwn, Resource: 1}),
+		typeDecl(&internalcomponent.ValTypeDef{Kind: internalcomponent.ValTypeKindVariant, Cases: []internalcomponent.CaseDef{
+			{Name: "last-operation-failed", Type: typeRef(3)},
+			{Name: "closed"},
+		}}),
+		typeDecl(&internalcomponent.ValTypeDef{Kind: internalcomponent.ValTypeKindBorrow, Resource: 2}),
+		typeDecl(&internalcomponent.ValTypeDef{Kind: internalcomponent.ValTypeKindList, Elem: valType(internalcomponent.ValTypeKindU8)}),
+		typeDecl(&internalcomponent.ValTypeDef{Kind: internalcomponent.ValTypeKindResult, Err: typeRef(4)}),
+		typeDecl(&internalcomponent.FuncTypeDef{
+			Params:  []internalcomponent.LabeledValType{{Name: "self", Type: *typeRef(5)}, {Name: "contents", Type: *typeRef(6)}},
+			Results: []internalcomponent.LabeledValType{{Type: *typeRef(7)}},
+		}),
+		exportDecl("[method]output-stream.blocking-write-and-flush", internalcomponent.SortFunc, 8),
+	}}},
+	&internalcomponent.Import{Name: "wasi:io/streams@0.2.0", Desc: internalcomponent.ExternDesc{Sort: internalcomponent.SortInstance, Index: 2}},
+	aliasExport(internalcomponent.SortType, 1, "output-stream"), // type 3
+	// type 4
+	&internalcomponent.Type{Def: &internalcomponent.InstanceTypeDef{Decls: []internalcomponent.Decl{
+		outerTypeDecl(3),
+		exportDecl("output-stream", internalcomponent.SortType, 0),
+		typeDecl(&internalcomponent.ValTypeDef{Kind: internalcomponent.ValTypeKindOwn, Resource: 1}),
+		typeDecl(&internalcomponent.FuncTypeDef{Results: []internalcomponent.LabeledValType{{Type: *typeRef(2)}}}),
+		exportDecl("get-stdout", internalcomponent.SortFunc, 3),
+	}}},
+	&internalcomponent.Import{Name: "wasi:cli/stdout@0.2.0", Desc: internalcomponent.ExternDesc{Sort: internalcomponent.SortInstance, Index: 4}},
+	aliasExport(internalcomponent.SortFunc, 2, "get-stdout"),                                     // func 0
+	aliasExport(internalcomponent.SortFunc, 1, "[method]output-stream.blocking-write-and-flush"), // func 1
+
+	&internalcomponent.CoreModule{Binary: libcWasm},
+	&internalcomponent.CoreInstance{Module: 0},
+	aliasExport(internalcomponent.SortCoreMemory, 0, "memory"),            // core memory 0
+	aliasExport(internalcomponent.SortCoreFunc, 0, "realloc"),             // core func 0
+	&internalcomponent.Canon{Kind: internalcomponent.CanonLower, Func: 0}, // core func 1
+	&internalcomponent.Canon{Kind: internalcomponent.CanonLower, Func: 1, Options: internalcomponent.CanonOptions{ // core func 2
+		Memory: 0, HasMemory: true, Realloc: 0, HasRealloc: true,
+	}},
+	&internalcomponent.Canon{Kind: internalcomponent.CanonResourceDrop, Type: 3}, // core func 3
+	&internalcomponent.CoreInstance{IsExports: true, Exports: []internalcomponent.Export{
+		{Name: "get-stdout", Sort: internalcomponent.SortCoreFunc, Index: 1},
+		{Name: "write", Sort: internalcomponent.SortCoreFunc, Index: 2},
+		{Name: "drop", Sort: internalcomponent.SortCoreFunc, Index: 3},
+		{Name: "memory", Sort: internalcomponent.SortCoreMemory, Index: 0},
+	}},
+	&internalcomponent.CoreModule{Binary: helloWasm},
+	&internalcomponent.CoreInstance{Module: 1, Args: []internalcomponent.CoreInstantiateArg{{Name: "host", Instance: 1}}},
+	aliasExport(internalcomponent.SortCoreFunc, 2, "run"), // core func 4
+
+	&internalcomponent.Type{Def: &internalcomponent.ValTypeDef{Kind: internalcomponent.ValTypeKindResult}},                         // type 5
+	&internalcomponent.Type{Def: &internalcomponent.FuncTypeDef{Results: []internalcomponent.LabeledValType{{Type: *typeRef(5)}}}}, // type 6
+	&internalcomponent.Canon{Kind: internalcomponent.CanonLift, Func: 4, Type: 6},                                                  // func 2
+	&internalcomponent.Instance{IsExports: true, Exports: []internalcomponent.Export{{Name: "run", Sort: internalcomponent.SortFunc, Index: 2}}},
+	&internalcomponent.Export{Name: "wasi:cli/run@0.2.0", Sort: internalcomponent.SortInstance, Index: 3},
+}})
+
+func TestRun(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config wazero.RuntimeConfig
+	}{
+		{name: "interpreter", config: wazero.NewRuntimeConfigInterpreter()},
+		{name: "default", config: wazero.NewRuntimeConfig()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := wazero.NewRuntimeWithConfig(testCtx, tc.config)
+			defer r.Close(testCtx)
+
+			compiled, err := component.Compile(testCtx, r, helloComponent)
+			require.NoError(t, err)
+
+			var stdout bytes.Buffer
+			inst, err := Instantiate(testCtx, r, compiled, wazero.NewModuleConfig().WithStdout(&stdout))
+			require.NoError(t, err)
+
+			require.NoError(t, Run(testCtx, inst))
+			require.Equal(t, "hello\n", stdout.String())
+			require.True(t, inst.IsClosed())
+		})
+	}
+}
+
+func TestRun_notExported(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	compiled, err := component.Compile(testCtx, r, componentencoding.Encode(libcComponent))
+	require.NoError(t, err)
+
+	inst, err := Instantiate(testCtx, r, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	err = Run(testCtx, inst)
+	require.EqualError(t, err, "wasi:cli/run@0.2.0#run is not exported")
+}
+
+// libcComponent only instantiates libcWasm, so that tests can call host
+// functions with its instance.
+var libcComponent = &internalcomponent.Component{Definitions: []internalcomponent.Definition{
+	&internalcomponent.CoreModule{Binary: libcWasm},
+	&internalcomponent.CoreInstance{Module: 0},
+}}
+
+// newTestInstance instantiates libcComponent with config.
+func newTestInstance(t *testing.T, config wazero.ModuleConfig) *internalcomponent.ComponentInstance {
+	r := wazero.NewRuntime(testCtx)
+	t.Cleanup(func() { r.Close(testCtx) })
+
+	compiled, err := internalcomponent.Compile(testCtx, r, componentencoding.Encode(libcComponent))
+	require.NoError(t, err)
+
+	inst, err := compiled.Instantiate(testCtx, r, config, nil)
+	require.NoError(t, err)
+	return inst
+}
+
+// call calls the function name of host with params.
+func call(inst *internalcomponent.ComponentInstance, host *internalcomponent.HostInstance, name string, params ...any) []any {
+	return host.Funcs[name](testCtx, inst, params)
+}
+
+func TestExit(t *testing.T) {
+	inst := newTestInstance(t, wazero.NewModuleConfig())
+
+	err := require.CapturePanic(func() { call(inst, cliExit, "exit", component.Err(nil)) })
+	require.Equal(t, uint32(1), err.(*sys.ExitError).ExitCode())
+	require.True(t, inst.IsClosed())
+}
@@ -0,0 +1,162 @@
+package wasix
+
+import (
+	"context"
+	"math"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+const (
+	futexWaitName    = "futex_wait"
+	futexWakeName    = "futex_wake"
+	futexWakeAllName = "futex_wake_all"
+)
+
+// optionTimestampSome is the tag of a `__wasi_option_timestamp_t` which has
+// a value. The tag is a byte at offset zero, and the value a uint64le at
+// offset eight.
+const optionTimestampSome = 1
+
+// futexWait is the WASIX function named futexWaitName which waits until the
+// futex is woken, unless it doesn't hold the expected value.
+//
+// # Parameters
+//
+//   - futex: offset of the uint32le futex, which must be 4-byte aligned
+//   - expected: value the futex must hold for this to wait
+//   - timeout: offset of an optional relative timeout in nanoseconds, see
+//     optionTimestampSome. This waits forever when it has no value.
+//   - resultWoken: offset to write true if woken, or false if the futex
+//     didn't hold `expected` or the timeout elapsed
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EFAULT: `futex`, `timeout` or `resultWoken` is out of memory
+//   - sys.EINVAL: `futex` isn't aligned
+//
+// # Notes
+//
+//   - This is like memory.atomic.wait32, and woken by memory.atomic.notify
+//     on the same address, too.
+//   - Waiting forever without other threads which could wake the futex never
+//     returns, even if the module is closed or its context is done.
+//
+// See https://wasix.org/docs/api-reference/wasix/futex_wait
+var futexWait = newHostFunc(
+	futexWaitName,
+	futexWaitFn,
+	[]wasm.ValueType{i32, i32, i32, i32},
+	"futex", "expected", "timeout", "result.woken",
+)
+
+func futexWaitFn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	mem := mod.Memory()
+	memInst := mod.(*wasm.ModuleInstance).MemoryInstance
+
+	futex := uint32(params[0])
+	expected := uint32(params[1])
+	timeoutOffset := uint32(params[2])
+	resultWoken := uint32(params[3])
+
+	if errno := checkFutex(mem, futex); errno != 0 {
+		return errno
+	}
+
+	timeout := int64(-1) // forever
+	if tag, ok := mem.ReadByte(timeoutOffset); !ok {
+		return sys.EFAULT
+	} else if tag == optionTimestampSome {
+		ns, ok := mem.ReadUint64Le(timeoutOffset + 8)
+		if !ok {
+			return sys.EFAULT
+		}
+		timeout = int64(min(ns, math.MaxInt64))
+	}
+
+	res := memInst.Wait32(uint64(futex), expected, timeout, func(mem *wasm.MemoryInstance, offset uint64) uint32 {
+		mem.Mux.Lock()
+		defer mem.Mux.Unlock()
+		value, _ := mem.ReadUint32Le64(offset)
+		return value
+	})
+	if !writeBool(mem, resultWoken, res == 0) {
+		return sys.EFAULT
+	}
+	return 0
+}
+
+// futexWake is the WASIX function named futexWakeName which wakes one thread
+// waiting on the futex.
+//
+// # Parameters
+//
+//   - futex: offset of the uint32le futex, which must be 4-byte aligned
+//   - resultWoken: offset to write true if a thread was woken
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EFAULT: `futex` or `resultWoken` is out of memory
+//   - sys.EINVAL: `futex` isn't aligned
+//
+// See https://wasix.org/docs/api-reference/wasix/futex_wake
+var futexWake = newHostFunc(
+	futexWakeName,
+	func(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+		return futexWakeN(mod, uint32(params[0]), uint32(params[1]), 1)
+	},
+	[]wasm.ValueType{i32, i32},
+	"futex", "result.woken",
+)
+
+// futexWakeAll is the WASIX function named futexWakeAllName which wakes all
+// threads waiting on the futex.
+//
+// # Parameters
+//
+//   - futex: offset of the uint32le futex, which must be 4-byte aligned
+//   - resultWoken: offset to write true if any thread was woken
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EFAULT: `futex` or `resultWoken` is out of memory
+//   - sys.EINVAL: `futex` isn't aligned
+//
+// See https://wasix.org/docs/api-reference/wasix/futex_wake_all
+var futexWakeAll = newHostFunc(
+	futexWakeAllName,
+	func(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+		return futexWakeN(mod, uint32(params[0]), uint32(params[1]), math.MaxUint32)
+	},
+	[]wasm.ValueType{i32, i32},
+	"futex", "result.woken",
+)
+
+// futexWakeN wakes up to count threads waiting on the futex.
+func futexWakeN(mod api.Module, futex, resultWoken, count uint32) sys.Errno {
+	mem := mod.Memory()
+	if errno := checkFutex(mem, futex); errno != 0 {
+		return errno
+	}
+	n := mod.(*wasm.ModuleInstance).MemoryInstance.Notify(uint64(futex), count)
+	if !writeBool(mem, resultWoken, n > 0) {
+		return sys.EFAULT
+	}
+	return 0
+}
+
+// checkFutex returns an error if the futex at the given offset isn't in
+// memory or isn't aligned.
+func checkFutex(mem api.Memory, futex uint32) sys.Errno {
+	if futex%4 != 0 {
+		return sys.EINVAL
+	} else if _, ok := mem.ReadUint32Le(futex); !ok {
+		return sys.EFAULT
+	}
+	return 0
+}
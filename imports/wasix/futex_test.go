@@ -0,0 +1,82 @@
+package wasix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasip1"
+)
+
+func Test_futexWait(t *testing.T) {
+	const futex, timeout, resultWoken = 16, 32, 48
+
+	mod, r := requireProxyModule(t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+	mem := mod.Memory()
+
+	require.True(t, mem.WriteUint32Le(futex, 1))
+	require.True(t, mem.WriteByte(timeout, optionTimestampSome))
+	require.True(t, mem.WriteUint64Le(timeout+8, uint64(time.Millisecond)))
+
+	t.Run("not expected", func(t *testing.T) {
+		require.True(t, mem.WriteByte(resultWoken, 1))
+		requireErrno(t, 0, mod, futexWaitName, futex, 2, timeout, resultWoken)
+		woken, _ := mem.ReadByte(resultWoken)
+		require.Equal(t, byte(0), woken)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		require.True(t, mem.WriteByte(resultWoken, 1))
+		requireErrno(t, 0, mod, futexWaitName, futex, 1, timeout, resultWoken)
+		woken, _ := mem.ReadByte(resultWoken)
+		require.Equal(t, byte(0), woken)
+	})
+
+	t.Run("unaligned", func(t *testing.T) {
+		requireErrno(t, wasip1.ErrnoInval, mod, futexWaitName, futex+1, 1, timeout, resultWoken)
+	})
+
+	t.Run("out of memory", func(t *testing.T) {
+		requireErrno(t, wasip1.ErrnoFault, mod, futexWaitName, uint64(mem.Size()), 1, timeout, resultWoken)
+	})
+}
+
+func Test_futexWake(t *testing.T) {
+	const futex, timeout, waiterWoken, resultWoken = 16, 32, 48, 52
+
+	for _, name := range []string{futexWakeName, futexWakeAllName} {
+		t.Run(name, func(t *testing.T) {
+			mod, r := requireProxyModule(t, wazero.NewModuleConfig())
+			defer r.Close(testCtx)
+			mem := mod.Memory()
+
+			// Nothing waits yet.
+			requireErrno(t, 0, mod, name, futex, resultWoken)
+			woken, _ := mem.ReadByte(resultWoken)
+			require.Equal(t, byte(0), woken)
+
+			// Wait forever on another goroutine.
+			require.True(t, mem.WriteByte(timeout, 0))
+			waitErrno := make(chan uint64, 1)
+			go func() {
+				res, err := mod.ExportedFunction(futexWaitName).Call(testCtx, futex, 0, timeout, waiterWoken)
+				require.NoError(t, err)
+				waitErrno <- res[0]
+			}()
+
+			// Wake until the waiter is woken, as it might not wait yet.
+			for woken == 0 {
+				time.Sleep(time.Millisecond)
+				requireErrno(t, 0, mod, name, futex, resultWoken)
+				woken, _ = mem.ReadByte(resultWoken)
+			}
+			require.Equal(t, uint64(0), <-waitErrno)
+			woken, _ = mem.ReadByte(waiterWoken)
+			require.Equal(t, byte(1), woken)
+
+			requireErrno(t, wasip1.ErrnoInval, mod, name, futex+2, resultWoken)
+		})
+	}
+}
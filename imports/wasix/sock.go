@@ -0,0 +1,488 @@
+package wasix
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/sys"
+	socketapi "github.com/tetratelabs/wazero/internal/sock"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/wasip1"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+const (
+	sockAcceptV2Name  = "sock_accept_v2"
+	sockAddrLocalName = "sock_addr_local"
+	sockAddrPeerName  = "sock_addr_peer"
+	sockConnectName   = "sock_connect"
+	sockOpenName      = "sock_open"
+	sockRecvFromName  = "sock_recv_from"
+	sockSendToName    = "sock_send_to"
+)
+
+// Socket types and protocols of sockOpen. Unlike WasmEdge, stream is one and
+// datagram is two.
+const (
+	sockTypeStream = 1
+	sockTypeDgram  = 2
+
+	sockProtoTCP = 6
+	sockProtoUDP = 17
+)
+
+// sockOpen is the WASIX function named sockOpenName which opens a socket.
+//
+// # Parameters
+//
+//   - af: address family, wasip1.AddressFamilyInet4 or Inet6
+//   - socktype: socket type, sockTypeStream for TCP or sockTypeDgram for UDP
+//   - sockProto: protocol, zero for the default of `socktype`, or the one
+//     matching it: sockProtoTCP or sockProtoUDP
+//   - resultFd: offset to write the file descriptor of the socket
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EACCES: no destination is allowed, see experimental/sock.Config
+//   - sys.EAFNOSUPPORT: `af` is invalid
+//   - sys.EINVAL: `socktype` or `sockProto` is invalid
+//
+// See https://wasix.org/docs/api-reference/wasix/sock_open
+var sockOpen = newHostFunc(
+	sockOpenName,
+	sockOpenFn,
+	[]wasm.ValueType{i32, i32, i32, i32},
+	"af", "socktype", "sock_proto", "result.fd",
+)
+
+func sockOpenFn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	af := uint8(params[0])
+	socktype := uint32(params[1])
+	sockProto := uint32(params[2])
+	resultFd := uint32(params[3])
+
+	var ipv6 bool
+	switch af {
+	case wasip1.AddressFamilyInet4:
+	case wasip1.AddressFamilyInet6:
+		ipv6 = true
+	default:
+		return sys.EAFNOSUPPORT
+	}
+
+	var dgram bool
+	switch {
+	case socktype == sockTypeStream && (sockProto == 0 || sockProto == sockProtoTCP):
+	case socktype == sockTypeDgram && (sockProto == 0 || sockProto == sockProtoUDP):
+		dgram = true
+	default:
+		return sys.EINVAL
+	}
+
+	fd, errno := fsc.SockOpen(ipv6, dgram)
+	if errno != 0 {
+		return errno
+	}
+	if !mem.WriteUint32Le(resultFd, uint32(fd)) {
+		_ = fsc.CloseFile(fd)
+		return sys.EFAULT
+	}
+	return 0
+}
+
+// sockConnect is the WASIX function named sockConnectName which connects a
+// stream socket opened by sockOpen.
+//
+// # Parameters
+//
+//   - fd: file descriptor of the socket
+//   - addr: offset of the address to connect to, see readAddrPort
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EACCES: the destination is not allowed
+//   - sys.EBADF: `fd` isn't a stream socket which isn't connected yet
+//   - sys.ECONNREFUSED: nothing listens on the destination
+//   - sys.EINTR: the context of the call was done before connecting
+//
+// # Notes
+//
+//   - This blocks until connected or the context of the call is done, even
+//     if the socket is non-blocking.
+//
+// See https://wasix.org/docs/api-reference/wasix/sock_connect
+var sockConnect = newHostFunc(
+	sockConnectName,
+	sockConnectFn,
+	[]wasm.ValueType{i32, i32},
+	"fd", "addr",
+)
+
+func sockConnectFn(ctx context.Context, mod api.Module, params []uint64) sys.Errno {
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	fd := int32(params[0])
+	addr, errno := readAddrPort(mod.Memory(), uint32(params[1]))
+	if errno != 0 {
+		return errno
+	}
+	return fsc.SockConnect(ctx, fd, addr)
+}
+
+// sockAcceptV2 is the WASIX function named sockAcceptV2Name which accepts a
+// new incoming connection, like sock_accept in WASI preview 1, and also
+// returns the address of the peer.
+//
+// # Parameters
+//
+//   - fd: file descriptor of the listener
+//   - flags: fdflags of the connection, which may only be wasip1.FD_NONBLOCK
+//   - resultFd: offset to write the file descriptor of the connection
+//   - resultAddr: offset to write the address of the peer, see writeAddrPort
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EAGAIN: `fd` is non-blocking and no connection is pending
+//   - sys.EBADF: `fd` isn't a listener
+//
+// See https://wasix.org/docs/api-reference/wasix/sock_accept_v2
+var sockAcceptV2 = newHostFunc(
+	sockAcceptV2Name,
+	sockAcceptV2Fn,
+	[]wasm.ValueType{i32, i32, i32, i32},
+	"fd", "flags", "result.fd", "result.addr",
+)
+
+func sockAcceptV2Fn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	fd := int32(params[0])
+	flags := uint32(params[1])
+	resultFd := uint32(params[2])
+	resultAddr := uint32(params[3])
+	nonblock := flags&uint32(wasip1.FD_NONBLOCK) != 0
+
+	connFD, errno := fsc.SockAccept(fd, nonblock)
+	if errno != 0 {
+		return errno
+	}
+	addr, errno := remoteAddr(fsc, connFD)
+	if errno == 0 {
+		errno = writeAddrPort(mem, resultAddr, addr)
+	}
+	if errno == 0 && !mem.WriteUint32Le(resultFd, uint32(connFD)) {
+		errno = sys.EFAULT
+	}
+	if errno != 0 {
+		_ = fsc.CloseFile(connFD)
+	}
+	return errno
+}
+
+// sockAddrLocal is the WASIX function named sockAddrLocalName which returns
+// the address a socket is bound to, like getsockname in POSIX.
+//
+// # Parameters
+//
+//   - fd: file descriptor of the socket
+//   - resultAddr: offset to write the address, see writeAddrPort
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EBADF: `fd` isn't open
+//   - sys.ENOTSOCK: `fd` isn't a socket
+//
+// See https://wasix.org/docs/api-reference/wasix/sock_addr_local
+var sockAddrLocal = newHostFunc(
+	sockAddrLocalName,
+	sockAddrLocalFn,
+	[]wasm.ValueType{i32, i32},
+	"fd", "result.addr",
+)
+
+func sockAddrLocalFn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	a, errno := addresser(fsc, int32(params[0]))
+	if errno != 0 {
+		return errno
+	}
+	return writeAddrPort(mod.Memory(), uint32(params[1]), a.LocalAddr())
+}
+
+// sockAddrPeer is the WASIX function named sockAddrPeerName which returns
+// the address a socket is connected to, like getpeername in POSIX.
+//
+// # Parameters
+//
+//   - fd: file descriptor of the socket
+//   - resultAddr: offset to write the address, see writeAddrPort
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EBADF: `fd` isn't open
+//   - sys.ENOTCONN: `fd` isn't connected
+//   - sys.ENOTSOCK: `fd` isn't a socket
+//
+// See https://wasix.org/docs/api-reference/wasix/sock_addr_peer
+var sockAddrPeer = newHostFunc(
+	sockAddrPeerName,
+	sockAddrPeerFn,
+	[]wasm.ValueType{i32, i32},
+	"fd", "result.addr",
+)
+
+func sockAddrPeerFn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	addr, errno := remoteAddr(fsc, int32(params[0]))
+	if errno != 0 {
+		return errno
+	}
+	return writeAddrPort(mod.Memory(), uint32(params[1]), addr)
+}
+
+// sockSendTo is the WASIX function named sockSendToName which sends a
+// datagram to an address.
+//
+// # Parameters
+//
+//   - fd: file descriptor of the datagram socket
+//   - siData: offset of the iovec array of the datagram
+//   - siDataLen: count of iovec in `siData`
+//   - siFlags: must be zero
+//   - addr: offset of the address to send to, see readAddrPort
+//   - resultSoDatalen: offset to write the count of bytes sent
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EACCES: the destination is not allowed
+//   - sys.EBADF: `fd` isn't a datagram socket
+//
+// See https://wasix.org/docs/api-reference/wasix/sock_send_to
+var sockSendTo = newHostFunc(
+	sockSendToName,
+	sockSendToFn,
+	[]wasm.ValueType{i32, i32, i32, i32, i32, i32},
+	"fd", "si_data", "si_data_len", "si_flags", "addr", "result.so_datalen",
+)
+
+func sockSendToFn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	fd := int32(params[0])
+	siData := uint32(params[1])
+	siDataCount := uint32(params[2])
+	siFlags := uint32(params[3])
+	resultSoDatalen := uint32(params[5])
+
+	if siFlags != 0 {
+		return sys.ENOTSUP
+	}
+
+	addr, errno := readAddrPort(mem, uint32(params[4]))
+	if errno != 0 {
+		return errno
+	}
+	iovs, errno := readIovs(mem, siData, siDataCount)
+	if errno != 0 {
+		return errno
+	}
+
+	n, errno := fsc.SockSendTo(fd, iovs, addr)
+	if errno != 0 {
+		return errno
+	}
+	if !mem.WriteUint32Le(resultSoDatalen, uint32(n)) {
+		return sys.EFAULT
+	}
+	return 0
+}
+
+// sockRecvFrom is the WASIX function named sockRecvFromName which receives a
+// datagram, and the address it was sent from.
+//
+// # Parameters
+//
+//   - fd: file descriptor of the datagram socket
+//   - riData: offset of the iovec array to receive the datagram into
+//   - riDataLen: count of iovec in `riData`
+//   - riFlags: must be zero
+//   - resultRoDatalen: offset to write the count of bytes received
+//   - resultRoFlags: offset to write zero
+//   - resultAddr: offset to write the sender to, see writeAddrPort
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EAGAIN: `fd` is non-blocking and no datagram is available
+//   - sys.EBADF: `fd` isn't a datagram socket
+//
+// # Notes
+//
+//   - The rest of a datagram larger than `riData` is discarded.
+//
+// See https://wasix.org/docs/api-reference/wasix/sock_recv_from
+var sockRecvFrom = newHostFunc(
+	sockRecvFromName,
+	sockRecvFromFn,
+	[]wasm.ValueType{i32, i32, i32, i32, i32, i32, i32},
+	"fd", "ri_data", "ri_data_len", "ri_flags", "result.ro_datalen", "result.ro_flags", "result.addr",
+)
+
+func sockRecvFromFn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	fd := int32(params[0])
+	riData := uint32(params[1])
+	riDataCount := uint32(params[2])
+	riFlags := uint16(params[3])
+	resultRoDatalen := uint32(params[4])
+	resultRoFlags := uint32(params[5])
+	resultAddr := uint32(params[6])
+
+	if riFlags != 0 {
+		return sys.ENOTSUP
+	}
+
+	iovs, errno := readIovs(mem, riData, riDataCount)
+	if errno != 0 {
+		return errno
+	}
+	n, addr, errno := fsc.SockRecvFrom(fd, iovs)
+	if errno != 0 {
+		return errno
+	}
+
+	if errno = writeAddrPort(mem, resultAddr, addr); errno != 0 {
+		return errno
+	}
+	if !mem.WriteUint32Le(resultRoDatalen, uint32(n)) || !mem.WriteUint16Le(resultRoFlags, 0) {
+		return sys.EFAULT
+	}
+	return 0
+}
+
+// addresser returns the addresses of the socket of fd.
+func addresser(fsc *internalsys.FSContext, fd int32) (socketapi.Addresser, sys.Errno) {
+	if e, ok := fsc.LookupFile(fd); !ok {
+		return nil, sys.EBADF // Not open
+	} else if a, ok := e.File.(socketapi.Addresser); !ok {
+		return nil, sys.ENOTSOCK
+	} else {
+		return a, 0
+	}
+}
+
+// remoteAddr returns the address the socket of fd is connected to.
+func remoteAddr(fsc *internalsys.FSContext, fd int32) (netip.AddrPort, sys.Errno) {
+	a, errno := addresser(fsc, fd)
+	if errno != 0 {
+		return netip.AddrPort{}, errno
+	}
+	return a.RemoteAddr()
+}
+
+// Offsets in the struct `__wasi_addr_port_t`, which is an address family as
+// a byte, a byte of padding, and then the port as uint16le followed by the
+// address. IPv4 addresses are their four bytes, while IPv6 addresses are
+// their eight segments, each as uint16le.
+const (
+	addrPortFamily = 0
+	addrPortPort   = 2
+	addrPortAddr   = 4
+
+	addrPortInet4Len = addrPortAddr + 4
+	addrPortInet6Len = addrPortAddr + 16
+)
+
+// readAddrPort reads the `__wasi_addr_port_t` at offset addr.
+func readAddrPort(mem api.Memory, addr uint32) (netip.AddrPort, sys.Errno) {
+	family, ok := mem.ReadByte(addr + addrPortFamily)
+	if !ok {
+		return netip.AddrPort{}, sys.EFAULT
+	}
+
+	var ip netip.Addr
+	var buf []byte
+	switch family {
+	case wasip1.AddressFamilyInet4:
+		if buf, ok = mem.Read(addr, addrPortInet4Len); !ok {
+			return netip.AddrPort{}, sys.EFAULT
+		}
+		ip = netip.AddrFrom4([4]byte(buf[addrPortAddr:]))
+	case wasip1.AddressFamilyInet6:
+		if buf, ok = mem.Read(addr, addrPortInet6Len); !ok {
+			return netip.AddrPort{}, sys.EFAULT
+		}
+		var ip16 [16]byte
+		for i := 0; i < 16; i += 2 {
+			segment := le.Uint16(buf[addrPortAddr+i:])
+			ip16[i], ip16[i+1] = byte(segment>>8), byte(segment)
+		}
+		ip = netip.AddrFrom16(ip16)
+	default:
+		return netip.AddrPort{}, sys.EAFNOSUPPORT
+	}
+	return netip.AddrPortFrom(ip, le.Uint16(buf[addrPortPort:])), 0
+}
+
+// writeAddrPort writes addr as a `__wasi_addr_port_t`, in the format
+// documented on readAddrPort, to offset resultAddr.
+func writeAddrPort(mem api.Memory, resultAddr uint32, addr netip.AddrPort) sys.Errno {
+	ip := addr.Addr().Unmap()
+	var buf []byte
+	var ok bool
+	if ip.Is4() {
+		if buf, ok = mem.Read(resultAddr, addrPortInet4Len); !ok {
+			return sys.EFAULT
+		}
+		buf[addrPortFamily] = wasip1.AddressFamilyInet4
+		ip4 := ip.As4()
+		copy(buf[addrPortAddr:], ip4[:])
+	} else {
+		if buf, ok = mem.Read(resultAddr, addrPortInet6Len); !ok {
+			return sys.EFAULT
+		}
+		buf[addrPortFamily] = wasip1.AddressFamilyInet6
+		ip16 := ip.As16()
+		for i := 0; i < 16; i += 2 {
+			le.PutUint16(buf[addrPortAddr+i:], uint16(ip16[i])<<8|uint16(ip16[i+1]))
+		}
+	}
+	buf[addrPortFamily+1] = 0
+	le.PutUint16(buf[addrPortPort:], addr.Port())
+	return 0
+}
+
+// readIovs returns the buffers of an iovec array.
+func readIovs(mem api.Memory, iovs, iovsCount uint32) ([][]byte, sys.Errno) {
+	iovsBuf, ok := mem.Read(iovs, iovsCount<<3) // iovsCount * 8
+	if !ok {
+		return nil, sys.EFAULT
+	}
+	ret := make([][]byte, 0, iovsCount)
+	for iovsPos := uint32(0); iovsPos < uint32(len(iovsBuf)); iovsPos += 8 {
+		offset := le.Uint32(iovsBuf[iovsPos:])
+		l := le.Uint32(iovsBuf[iovsPos+4:])
+		b, ok := mem.Read(offset, l)
+		if !ok {
+			return nil, sys.EFAULT
+		}
+		ret = append(ret, b)
+	}
+	return ret, 0
+}
@@ -0,0 +1,255 @@
+package wasix
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	experimentalsock "github.com/tetratelabs/wazero/experimental/sock"
+	"github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasip1"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func Test_sockOpen(t *testing.T) {
+	const resultFd = 16
+
+	tests := []struct {
+		name                string
+		config              experimentalsock.Config
+		af, socktype, proto uint64
+		expectedErrno       wasip1.Errno
+	}{
+		{
+			name:     "stream",
+			config:   experimentalsock.NewConfig().WithAllowedDestination("127.0.0.1", 0),
+			af:       uint64(wasip1.AddressFamilyInet4),
+			socktype: sockTypeStream,
+		},
+		{
+			name:     "stream tcp",
+			config:   experimentalsock.NewConfig().WithAllowedDestination("127.0.0.1", 0),
+			af:       uint64(wasip1.AddressFamilyInet4),
+			socktype: sockTypeStream,
+			proto:    sockProtoTCP,
+		},
+		{
+			name:     "datagram udp",
+			config:   experimentalsock.NewConfig().WithAllowedDestination("127.0.0.1", 0),
+			af:       uint64(wasip1.AddressFamilyInet4),
+			socktype: sockTypeDgram,
+			proto:    sockProtoUDP,
+		},
+		{
+			name:          "no allowed destination",
+			config:        experimentalsock.NewConfig(),
+			af:            uint64(wasip1.AddressFamilyInet4),
+			socktype:      sockTypeStream,
+			expectedErrno: wasip1.ErrnoAcces,
+		},
+		{
+			name:          "invalid address family",
+			config:        experimentalsock.NewConfig().WithAllowedDestination("127.0.0.1", 0),
+			af:            3, // unix
+			socktype:      sockTypeStream,
+			expectedErrno: wasip1.ErrnoAfnosupport,
+		},
+		{
+			name:          "mismatched protocol",
+			config:        experimentalsock.NewConfig().WithAllowedDestination("127.0.0.1", 0),
+			af:            uint64(wasip1.AddressFamilyInet4),
+			socktype:      sockTypeStream,
+			proto:         sockProtoUDP,
+			expectedErrno: wasip1.ErrnoInval,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := experimentalsock.WithConfig(testCtx, tc.config)
+			mod, r := requireProxyModuleWithContext(ctx, t, wazero.NewModuleConfig())
+			defer r.Close(testCtx)
+
+			requireErrno(t, tc.expectedErrno, mod, sockOpenName, tc.af, tc.socktype, tc.proto, resultFd)
+			if tc.expectedErrno == 0 {
+				fd, ok := mod.Memory().ReadUint32Le(resultFd)
+				require.True(t, ok)
+				require.Equal(t, uint32(3), fd) // after stdio
+			}
+		})
+	}
+}
+
+func Test_sockConnect(t *testing.T) {
+	const resultFd, addr, resultAddr = 16, 32, 64
+
+	listener, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer listener.Close()
+	listenAddr := listener.Addr().(*net.TCPAddr).AddrPort()
+
+	ctx := experimentalsock.WithConfig(testCtx, experimentalsock.NewConfig().
+		WithAllowedDestination("127.0.0.1", int(listenAddr.Port())))
+	mod, r := requireProxyModuleWithContext(ctx, t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	requireErrno(t, 0, mod, sockOpenName, uint64(wasip1.AddressFamilyInet4), sockTypeStream, 0, resultFd)
+	fd, _ := mod.Memory().ReadUint32Le(resultFd)
+
+	// Not connected yet.
+	requireErrno(t, wasip1.ErrnoNotconn, mod, sockAddrPeerName, uint64(fd), resultAddr)
+	requireErrno(t, 0, mod, sockAddrLocalName, uint64(fd), resultAddr)
+	requireAddrPort(t, mod, resultAddr, netip.MustParseAddrPort("0.0.0.0:0"))
+
+	// The port isn't allowed.
+	require.EqualErrno(t, 0, writeAddrPort(mod.Memory(), addr, netip.AddrPortFrom(listenAddr.Addr(), listenAddr.Port()+1)))
+	requireErrno(t, wasip1.ErrnoAcces, mod, sockConnectName, uint64(fd), addr)
+
+	require.EqualErrno(t, 0, writeAddrPort(mod.Memory(), addr, listenAddr))
+	requireErrno(t, 0, mod, sockConnectName, uint64(fd), addr)
+
+	conn, err := listener.AcceptTCP()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	requireErrno(t, 0, mod, sockAddrPeerName, uint64(fd), resultAddr)
+	requireAddrPort(t, mod, resultAddr, listenAddr)
+	requireErrno(t, 0, mod, sockAddrLocalName, uint64(fd), resultAddr)
+	requireAddrPort(t, mod, resultAddr, conn.RemoteAddr().(*net.TCPAddr).AddrPort())
+}
+
+func Test_sockAcceptV2(t *testing.T) {
+	const listenerFd, resultFd, resultAddr = 3, 16, 32
+
+	ctx := experimentalsock.WithConfig(testCtx, experimentalsock.NewConfig().WithTCPListener("127.0.0.1", 0))
+	mod, r := requireProxyModuleWithContext(ctx, t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	requireErrno(t, 0, mod, sockAddrLocalName, listenerFd, resultAddr)
+	listenAddr, errno := readAddrPort(mod.Memory(), resultAddr)
+	require.EqualErrno(t, 0, errno)
+
+	// Dial the socket so that a call to accept doesn't hang.
+	conn, err := net.DialTCP("tcp4", nil, net.TCPAddrFromAddrPort(listenAddr))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	requireErrno(t, 0, mod, sockAcceptV2Name, listenerFd, uint64(wasip1.FD_NONBLOCK), resultFd, resultAddr)
+	fd, _ := mod.Memory().ReadUint32Le(resultFd)
+	require.Equal(t, uint32(4), fd)
+	requireAddrPort(t, mod, resultAddr, conn.LocalAddr().(*net.TCPAddr).AddrPort())
+
+	f, ok := mod.(*wasm.ModuleInstance).Sys.FS().LookupFile(int32(fd))
+	require.True(t, ok)
+	require.True(t, f.File.(sys.PollableFile).IsNonblock())
+
+	requireErrno(t, wasip1.ErrnoNotsock, mod, sockAddrLocalName, 0, resultAddr) // stdin
+	requireErrno(t, wasip1.ErrnoBadf, mod, sockAddrLocalName, 42, resultAddr)
+}
+
+func Test_sockSendToRecvFrom(t *testing.T) {
+	const udpFd, iovs, addr, resultLen, resultFlags, resultAddr = 3, 16, 32, 64, 68, 72
+
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+	peerAddr := peer.LocalAddr().(*net.UDPAddr).AddrPort()
+
+	ctx := experimentalsock.WithConfig(testCtx, experimentalsock.NewConfig().
+		WithUDPSocket("127.0.0.1", 0).
+		WithAllowedDestination("127.0.0.1", int(peerAddr.Port())))
+	mod, r := requireProxyModuleWithContext(ctx, t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+	mem := mod.Memory()
+
+	// Send "wazero" from two iovecs.
+	require.True(t, mem.Write(128, []byte("wazero")))
+	require.True(t, mem.Write(iovs, []byte{
+		128, 0, 0, 0, 2, 0, 0, 0, // "wa"
+		130, 0, 0, 0, 4, 0, 0, 0, // "zero"
+	}))
+	require.EqualErrno(t, 0, writeAddrPort(mem, addr, peerAddr))
+	requireErrno(t, 0, mod, sockSendToName, udpFd, iovs, 2, 0, addr, resultLen)
+	n, _ := mem.ReadUint32Le(resultLen)
+	require.Equal(t, uint32(6), n)
+
+	buf := make([]byte, 10)
+	nr, from, err := peer.ReadFromUDPAddrPort(buf)
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(buf[:nr]))
+
+	// Receive "hello" into two iovecs.
+	_, err = peer.WriteToUDPAddrPort([]byte("hello"), from)
+	require.NoError(t, err)
+	require.True(t, mem.Write(iovs, []byte{
+		0, 1, 0, 0, 3, 0, 0, 0, // 256
+		0, 2, 0, 0, 3, 0, 0, 0, // 512
+	}))
+	requireErrno(t, 0, mod, sockRecvFromName, udpFd, iovs, 2, 0, resultLen, resultFlags, resultAddr)
+	n, _ = mem.ReadUint32Le(resultLen)
+	require.Equal(t, uint32(5), n)
+	b, _ := mem.Read(256, 3)
+	require.Equal(t, "hel", string(b))
+	b, _ = mem.Read(512, 2)
+	require.Equal(t, "lo", string(b))
+	requireAddrPort(t, mod, resultAddr, peerAddr)
+
+	// The destination isn't allowed.
+	require.EqualErrno(t, 0, writeAddrPort(mem, addr, netip.AddrPortFrom(peerAddr.Addr(), peerAddr.Port()+1)))
+	requireErrno(t, wasip1.ErrnoAcces, mod, sockSendToName, udpFd, iovs, 2, 0, addr, resultLen)
+
+	// Not a datagram socket.
+	requireErrno(t, wasip1.ErrnoBadf, mod, sockSendToName, 1, iovs, 2, 0, addr, resultLen)
+}
+
+func Test_addrPort(t *testing.T) {
+	mod, r := requireProxyModule(t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+	mem := mod.Memory()
+
+	tests := []struct {
+		addr     netip.AddrPort
+		expected []byte
+	}{
+		{
+			addr:     netip.MustParseAddrPort("127.0.0.1:8080"),
+			expected: []byte{1, 0, 0x90, 0x1f, 127, 0, 0, 1},
+		},
+		{
+			addr: netip.MustParseAddrPort("[2001:db8::1]:443"),
+			expected: []byte{
+				2, 0, 0xbb, 0x01,
+				0x01, 0x20, 0xb8, 0x0d, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0, // segments
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.addr.String(), func(t *testing.T) {
+			require.EqualErrno(t, 0, writeAddrPort(mem, 0, tc.addr))
+			b, _ := mem.Read(0, uint32(len(tc.expected)))
+			require.Equal(t, tc.expected, b)
+
+			addr, errno := readAddrPort(mem, 0)
+			require.EqualErrno(t, 0, errno)
+			require.Equal(t, tc.addr, addr)
+		})
+	}
+
+	require.True(t, mem.WriteByte(0, 3)) // unix
+	_, errno := readAddrPort(mem, 0)
+	require.EqualErrno(t, sys.EAFNOSUPPORT, errno)
+}
+
+// requireAddrPort requires the `__wasi_addr_port_t` at offset addr is expected.
+func requireAddrPort(t *testing.T, mod api.Module, addr uint32, expected netip.AddrPort) {
+	t.Helper()
+	actual, errno := readAddrPort(mod.Memory(), addr)
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, expected, actual)
+}
@@ -0,0 +1,187 @@
+package wasix
+
+import (
+	"context"
+	"math"
+	"runtime"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/threads"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+const (
+	threadExitName        = "thread_exit"
+	threadIDName          = "thread_id"
+	threadJoinName        = "thread_join"
+	threadParallelismName = "thread_parallelism"
+	threadSleepName       = "thread_sleep"
+	threadSpawnV2Name     = "thread_spawn_v2"
+)
+
+// newThreadSpawnV2 returns the WASIX function named threadSpawnV2Name which
+// spawns a thread running the function named threads.StartName, exported by
+// the guest, with its thread ID and `startPtr`.
+//
+// The guest must import its memory, which is shared with the thread. Threads
+// behave like those spawned with wasi-threads: when any exits with proc_exit
+// or traps, all threads of the module are closed.
+//
+// # Parameters
+//
+//   - startPtr: offset of the `__wasi_thread_start_t` of the thread, which is
+//     only read by the guest
+//   - resultTid: offset to write the positive thread ID
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EAGAIN: the module is closed, or no thread ID is left
+//   - sys.EFAULT: `resultTid` is out of memory
+//   - sys.ENOTSUP: the guest doesn't import its memory or doesn't export
+//     threads.StartName
+//
+// See https://wasix.org/docs/api-reference/wasix/thread_spawn
+func newThreadSpawnV2(s *threads.Spawner) *wasm.HostFunc {
+	return newHostFunc(
+		threadSpawnV2Name,
+		func(ctx context.Context, mod api.Module, params []uint64) sys.Errno {
+			startPtr := uint32(params[0])
+			resultTid := uint32(params[1])
+
+			// Check resultTid before the thread runs, as it can't be undone.
+			if _, ok := mod.Memory().ReadUint32Le(resultTid); !ok {
+				return sys.EFAULT
+			}
+			tid, errno := s.Spawn(ctx, mod.(*wasm.ModuleInstance), startPtr)
+			if errno != 0 {
+				return errno
+			}
+			mod.Memory().WriteUint32Le(resultTid, tid)
+			return 0
+		},
+		[]wasm.ValueType{i32, i32},
+		"start_ptr", "result.tid",
+	)
+}
+
+// newThreadID returns the WASIX function named threadIDName which returns the
+// ID of the calling thread.
+//
+// # Parameters
+//
+//   - resultTid: offset to write the thread ID, which is zero for the module
+//     which isn't a spawned thread
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EFAULT: `resultTid` is out of memory
+//
+// See https://wasix.org/docs/api-reference/wasix/thread_id
+func newThreadID(s *threads.Spawner) *wasm.HostFunc {
+	return newHostFunc(
+		threadIDName,
+		func(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+			tid := s.ThreadID(mod.(*wasm.ModuleInstance))
+			if !mod.Memory().WriteUint32Le(uint32(params[0]), tid) {
+				return sys.EFAULT
+			}
+			return 0
+		},
+		[]wasm.ValueType{i32},
+		"result.tid",
+	)
+}
+
+// newThreadJoin returns the WASIX function named threadJoinName which waits
+// for a thread to exit. This returns immediately if it already exited.
+//
+// # Parameters
+//
+//   - tid: ID of the thread to join
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EINTR: the context of the call is done
+//   - sys.EINVAL: `tid` is the ID of the calling thread
+//
+// See https://wasix.org/docs/api-reference/wasix/thread_join
+func newThreadJoin(s *threads.Spawner) *wasm.HostFunc {
+	return newHostFunc(
+		threadJoinName,
+		func(ctx context.Context, mod api.Module, params []uint64) sys.Errno {
+			return s.Join(ctx, mod.(*wasm.ModuleInstance), uint32(params[0]))
+		},
+		[]wasm.ValueType{i32},
+		"tid",
+	)
+}
+
+// newThreadExit returns the WASIX function named threadExitName which exits
+// the calling thread. When called by the module which isn't a spawned thread,
+// this is the same as proc_exit.
+//
+// # Parameters
+//
+//   - rval: exit code of the thread
+//
+// See https://wasix.org/docs/api-reference/wasix/thread_exit
+func newThreadExit(s *threads.Spawner) *wasm.HostFunc {
+	return &wasm.HostFunc{
+		ExportName: threadExitName,
+		Name:       threadExitName,
+		ParamTypes: []wasm.ValueType{i32},
+		ParamNames: []string{"rval"},
+		Code: wasm.Code{GoFunc: api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			s.Exit(ctx, mod.(*wasm.ModuleInstance), uint32(stack[0]))
+		})},
+	}
+}
+
+// threadParallelism is the WASIX function named threadParallelismName which
+// returns how many threads can run in parallel.
+//
+// # Parameters
+//
+//   - resultParallelism: offset to write the count, which is
+//     runtime.GOMAXPROCS
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EFAULT: `resultParallelism` is out of memory
+//
+// See https://wasix.org/docs/api-reference/wasix/thread_parallelism
+var threadParallelism = newHostFunc(
+	threadParallelismName,
+	func(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+		if !mod.Memory().WriteUint32Le(uint32(params[0]), uint32(runtime.GOMAXPROCS(0))) {
+			return sys.EFAULT
+		}
+		return 0
+	},
+	[]wasm.ValueType{i32},
+	"result.parallelism",
+)
+
+// threadSleep is the WASIX function named threadSleepName which suspends the
+// calling thread, using the sys.Nanosleep configured on the module.
+//
+// # Parameters
+//
+//   - duration: nanoseconds to sleep
+//
+// See https://wasix.org/docs/api-reference/wasix/thread_sleep
+var threadSleep = newHostFunc(
+	threadSleepName,
+	func(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+		sysCtx := mod.(*wasm.ModuleInstance).Sys
+		sysCtx.Nanosleep(int64(min(params[0], math.MaxInt64)))
+		return 0
+	},
+	[]wasm.ValueType{i64},
+	"duration",
+)
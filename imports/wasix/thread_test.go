@@ -0,0 +1,180 @@
+package wasix
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/threads"
+	"github.com/tetratelabs/wazero/internal/wasip1"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// threadsWasm imports its shared memory, the thread functions of ModuleName
+// and "env.started", which is called on each thread with its thread ID and
+// start pointer after it writes its thread ID at `tid*4`. It exports:
+//   - "spawn" which calls threadSpawnV2Name with the start pointer, writing the
+//     thread ID at offset zero.
+//   - "id", "join" and "exit" which call the thread function of the same name.
+//   - threads.StartName which returns if the start pointer is 0, and
+//     otherwise calls threadExitName with it.
+var threadsWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32, i32}},
+	},
+	ImportSection: []wasm.Import{
+		{Module: "env", Name: "memory", Type: wasm.ExternTypeMemory, DescMem: &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
+		{Module: ModuleName, Name: threadSpawnV2Name, Type: wasm.ExternTypeFunc, DescFunc: 0},
+		{Module: ModuleName, Name: threadIDName, Type: wasm.ExternTypeFunc, DescFunc: 1},
+		{Module: ModuleName, Name: threadJoinName, Type: wasm.ExternTypeFunc, DescFunc: 1},
+		{Module: ModuleName, Name: threadExitName, Type: wasm.ExternTypeFunc, DescFunc: 2},
+		{Module: "env", Name: "started", Type: wasm.ExternTypeFunc, DescFunc: 3},
+	},
+	ImportMemoryCount:   1,
+	ImportFunctionCount: 5,
+	FunctionSection:     []wasm.Index{1, 1, 1, 2, 3},
+	CodeSection: []wasm.Code{
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 0, wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 2, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 3, wasm.OpcodeEnd}},
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Const, 4,
+			wasm.OpcodeI32Mul,
+			wasm.OpcodeCall, 1,
+			wasm.OpcodeDrop,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeCall, 4,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeI32Eqz,
+			wasm.OpcodeBrIf, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeCall, 3,
+			wasm.OpcodeEnd,
+		}},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "spawn", Type: wasm.ExternTypeFunc, Index: 5},
+		{Name: "id", Type: wasm.ExternTypeFunc, Index: 6},
+		{Name: "join", Type: wasm.ExternTypeFunc, Index: 7},
+		{Name: "exit", Type: wasm.ExternTypeFunc, Index: 8},
+		{Name: threads.StartName, Type: wasm.ExternTypeFunc, Index: 9},
+	},
+})
+
+type started struct {
+	mod      api.Module
+	tid, arg uint32
+}
+
+func Test_thread(t *testing.T) {
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfig().
+		WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesThreads))
+	defer r.Close(testCtx)
+
+	startedCh := make(chan started, 4)
+	env, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, tid, arg uint32) {
+			startedCh <- started{mod: mod, tid: tid, arg: arg}
+		}).Export("started").
+		ExportSharedMemory("memory", 1, 1).
+		Instantiate(testCtx)
+	require.NoError(t, err)
+	mem := env.Memory()
+	MustInstantiate(testCtx, r)
+
+	compiled, err := r.CompileModule(testCtx, threadsWasm)
+	require.NoError(t, err)
+
+	instantiate := func(t *testing.T) api.Module {
+		mod, err := r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig().WithName(t.Name()))
+		require.NoError(t, err)
+		return mod
+	}
+	call := func(t *testing.T, mod api.Module, name string, param uint32) wasip1.Errno {
+		res, err := mod.ExportedFunction(name).Call(testCtx, uint64(param))
+		require.NoError(t, err)
+		return wasip1.Errno(res[0])
+	}
+	spawn := func(t *testing.T, mod api.Module, arg uint32) started {
+		require.Equal(t, wasip1.ErrnoSuccess, call(t, mod, "spawn", arg))
+		tid, _ := mem.ReadUint32Le(0)
+		s := <-startedCh
+		require.Equal(t, tid, s.tid)
+		require.Equal(t, arg, s.arg)
+		require.NotEqual(t, mod, s.mod)
+		return s
+	}
+
+	t.Run("spawn and join", func(t *testing.T) {
+		mod := instantiate(t)
+		defer mod.Close(testCtx)
+
+		for i := uint32(1); i <= 2; i++ {
+			s := spawn(t, mod, 0)
+			require.Equal(t, i, s.tid)
+			require.Equal(t, wasip1.ErrnoSuccess, call(t, mod, "join", s.tid))
+			id, _ := mem.ReadUint32Le(s.tid * 4)
+			require.Equal(t, s.tid, id)
+			waitClosed(t, s.mod)
+		}
+		require.False(t, mod.IsClosed())
+
+		// Joining an unknown thread returns immediately.
+		require.Equal(t, wasip1.ErrnoSuccess, call(t, mod, "join", 42))
+	})
+
+	t.Run("thread_id of main", func(t *testing.T) {
+		mod := instantiate(t)
+		defer mod.Close(testCtx)
+
+		require.True(t, mem.WriteUint32Le(64, 42))
+		require.Equal(t, wasip1.ErrnoSuccess, call(t, mod, "id", 64))
+		id, _ := mem.ReadUint32Le(64)
+		require.Zero(t, id)
+
+		require.Equal(t, wasip1.ErrnoFault, call(t, mod, "id", mem.Size()))
+	})
+
+	t.Run("thread_exit from thread", func(t *testing.T) {
+		mod := instantiate(t)
+		defer mod.Close(testCtx)
+
+		s := spawn(t, mod, 7)
+		require.Equal(t, wasip1.ErrnoSuccess, call(t, mod, "join", s.tid))
+		waitClosed(t, s.mod)
+		require.False(t, mod.IsClosed())
+	})
+
+	t.Run("thread_exit from main", func(t *testing.T) {
+		mod := instantiate(t)
+
+		_, err := mod.ExportedFunction("exit").Call(testCtx, 3)
+		var exitErr *sys.ExitError
+		require.True(t, errors.As(err, &exitErr), err)
+		require.Equal(t, uint32(3), exitErr.ExitCode())
+		require.True(t, mod.IsClosed())
+	})
+}
+
+// waitClosed waits for a thread to be closed by the goroutine running it.
+func waitClosed(t *testing.T, mod api.Module) {
+	for deadline := time.Now().Add(10 * time.Second); !mod.IsClosed(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("thread not closed")
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package wasix
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/sys"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+const (
+	ttyGetName = "tty_get"
+	ttySetName = "tty_set"
+)
+
+// ttyLen is the length of the struct `__wasi_tty_t`, which is four uint32le
+// fields: cols, rows, width and height, followed by five bools: stdin_tty,
+// stdout_tty, stderr_tty, echo and line_buffered, padded to 4 bytes.
+const ttyLen = 24
+
+// Dimensions of the terminal reported by ttyGet, as the size of the host
+// terminal isn't exposed.
+const (
+	ttyCols   = 80
+	ttyRows   = 25
+	ttyWidth  = 800
+	ttyHeight = 600
+)
+
+// ttyGet is the WASIX function named ttyGetName which returns the state of
+// the terminal.
+//
+// Each of stdin_tty, stdout_tty and stderr_tty is true if the corresponding
+// file descriptor is a character device, e.g. the host terminal. If stdin
+// is, echo and line_buffered are true, as the host terminal is never changed
+// from its default mode.
+//
+// # Parameters
+//
+//   - resultState: offset to write the `__wasi_tty_t`, see ttyLen
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EFAULT: `resultState` is out of memory
+//
+// See https://wasix.org/docs/api-reference/wasix/tty_get
+var ttyGet = newHostFunc(
+	ttyGetName,
+	ttyGetFn,
+	[]wasm.ValueType{i32},
+	"result.state",
+)
+
+func ttyGetFn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	if !mod.Memory().Write(uint32(params[0]), ttyState(fsc)) {
+		return sys.EFAULT
+	}
+	return 0
+}
+
+// ttySet is the WASIX function named ttySetName which changes the state of
+// the terminal. This only succeeds if the state is unchanged from ttyGet,
+// as guests may not change the host terminal.
+//
+// # Parameters
+//
+//   - state: offset of the `__wasi_tty_t`, see ttyLen
+//
+// # Result (Errno)
+//
+// The return value is 0 except the following error conditions:
+//   - sys.EFAULT: `state` is out of memory
+//   - sys.ENOTSUP: `state` differs from the current state
+//
+// See https://wasix.org/docs/api-reference/wasix/tty_set
+var ttySet = newHostFunc(
+	ttySetName,
+	ttySetFn,
+	[]wasm.ValueType{i32},
+	"state",
+)
+
+func ttySetFn(_ context.Context, mod api.Module, params []uint64) sys.Errno {
+	fsc := mod.(*wasm.ModuleInstance).Sys.FS()
+
+	buf, ok := mod.Memory().Read(uint32(params[0]), ttyLen)
+	if !ok {
+		return sys.EFAULT
+	}
+	// Compare all but the padding.
+	if !bytes.Equal(buf[:ttyLen-3], ttyState(fsc)[:ttyLen-3]) {
+		return sys.ENOTSUP
+	}
+	return 0
+}
+
+// ttyState returns the current `__wasi_tty_t`, as documented on ttyGet.
+func ttyState(fsc *internalsys.FSContext) []byte {
+	stdin := isTerminal(fsc, internalsys.FdStdin)
+
+	buf := make([]byte, ttyLen)
+	le.PutUint32(buf, ttyCols)
+	le.PutUint32(buf[4:], ttyRows)
+	le.PutUint32(buf[8:], ttyWidth)
+	le.PutUint32(buf[12:], ttyHeight)
+	for i, v := range []bool{
+		stdin,
+		isTerminal(fsc, internalsys.FdStdout),
+		isTerminal(fsc, internalsys.FdStderr),
+		stdin, // echo
+		stdin, // line_buffered
+	} {
+		if v {
+			buf[16+i] = 1
+		}
+	}
+	return buf
+}
+
+// isTerminal returns true if fd is a character device.
+func isTerminal(fsc *internalsys.FSContext, fd int32) bool {
+	f, ok := fsc.LookupFile(fd)
+	if !ok {
+		return false
+	}
+	st, errno := f.File.Stat()
+	return errno == 0 && st.Mode&fs.ModeCharDevice != 0
+}
@@ -0,0 +1,40 @@
+package wasix
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasip1"
+)
+
+func Test_ttyGetSet(t *testing.T) {
+	const resultState = 16
+
+	mod, r := requireProxyModule(t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+	mem := mod.Memory()
+
+	requireErrno(t, 0, mod, ttyGetName, resultState)
+	state, _ := mem.Read(resultState, ttyLen)
+	require.Equal(t, []byte{
+		80, 0, 0, 0, // cols
+		25, 0, 0, 0, // rows
+		0x20, 0x03, 0, 0, // width
+		0x58, 0x02, 0, 0, // height
+		0, 0, 0, // stdin_tty, stdout_tty and stderr_tty, as stdio are not terminals
+		0, 0, // echo and line_buffered
+		0, 0, 0, // padding
+	}, state)
+
+	// Setting the same state succeeds, even if the padding differs.
+	state[ttyLen-1] = 1
+	requireErrno(t, 0, mod, ttySetName, resultState)
+
+	// Enabling echo isn't supported.
+	state[19] = 1
+	requireErrno(t, wasip1.ErrnoNotsup, mod, ttySetName, resultState)
+
+	requireErrno(t, wasip1.ErrnoFault, mod, ttyGetName, uint64(mem.Size()))
+	requireErrno(t, wasip1.ErrnoFault, mod, ttySetName, uint64(mem.Size()))
+}
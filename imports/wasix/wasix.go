@@ -0,0 +1,256 @@
+// Package wasix contains Go-defined functions of WASIX, a superset of WASI
+// preview 1 imported by programs compiled for it under the module name
+// "wasix_32v1", e.g. with `cargo wasix build`.
+//
+// WASIX programs also import the WASI preview 1 functions they use from
+// "wasi_snapshot_preview1", so that module must be instantiated, too. Both
+// share the configuration of the importing module, such as its file
+// descriptors, as defined by wazero.ModuleConfig and
+// experimental/sock.Config.
+//
+// e.g. Call Instantiate before instantiating any wasm binary that imports
+// "wasix_32v1", Otherwise, it will error due to missing imports.
+//
+//	ctx := context.Background()
+//	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+//		WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesThreads))
+//	defer r.Close(ctx) // This closes everything this Runtime created.
+//
+//	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+//	wasix.MustInstantiate(ctx, r)
+//	mod, _ := r.Instantiate(ctx, wasm)
+//
+// # Supported functions
+//
+// Only the subsets of WASIX which can be sandboxed are implemented:
+//   - Sockets: guests can accept connections on listeners configured with
+//     experimental/sock.Config, and open sockets to connect or send to its
+//     allowed destinations. Binding and listening is not supported.
+//   - Futexes: waiting on and waking addresses in memory.
+//   - Threads: spawned like with wasi-threads, see package wasi_threads.
+//   - TTY: the state of the terminal can be read, but not changed.
+//
+// Other functions, such as those to spawn processes, are not exported. Use
+// NewFunctionExporter to add them, if needed.
+//
+// A wasi_snapshot_preview1.Policy restricts these functions like the WASI
+// preview 1 ones when passed to Builder.WithPolicy.
+//
+// See https://wasix.org/docs/api-reference
+package wasix
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/threads"
+	"github.com/tetratelabs/wazero/internal/wasip1"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// ModuleName is the module name WASIX functions are exported into, for
+// programs using 32-bit memory.
+const ModuleName = "wasix_32v1"
+
+const i32, i64 = wasm.ValueTypeI32, wasm.ValueTypeI64
+
+var le = binary.LittleEndian
+
+// MustInstantiate calls Instantiate or panics on error.
+//
+// This is a simpler function for those who know the module ModuleName is not
+// already instantiated, and don't need to unload it.
+func MustInstantiate(ctx context.Context, r wazero.Runtime) {
+	if _, err := Instantiate(ctx, r); err != nil {
+		panic(err)
+	}
+}
+
+// Instantiate instantiates the ModuleName module into the runtime.
+//
+// # Notes
+//
+//   - Failure cases are documented on wazero.Runtime InstantiateModule.
+//   - Closing the wazero.Runtime has the same effect as closing the result.
+func Instantiate(ctx context.Context, r wazero.Runtime) (api.Closer, error) {
+	return NewBuilder(r).Instantiate(ctx)
+}
+
+// Builder configures the ModuleName module for later use via Compile or Instantiate.
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type Builder interface {
+	// WithPolicy restricts the functions a guest can call and the rights of
+	// its file descriptors, like the same function of
+	// wasi_snapshot_preview1.Builder. Defaults to no restrictions.
+	//
+	// Pass the same policy to both modules, as WASIX programs use the
+	// functions of both on the same file descriptors, e.g.:
+	//
+	//	policy := wasi_snapshot_preview1.NewPolicy().WithDeniedFunctions("sock_open")
+	//	wasi_snapshot_preview1.NewBuilder(r).WithPolicy(policy).Instantiate(ctx)
+	//	wasix.NewBuilder(r).WithPolicy(policy).Instantiate(ctx)
+	//
+	// Note: Calls the policy doesn't permit return ErrnoNotcapable.
+	WithPolicy(wasi_snapshot_preview1.Policy) Builder
+
+	// Compile compiles the ModuleName module. Call this before Instantiate.
+	//
+	// Note: This has the same effect as the same function on wazero.HostModuleBuilder.
+	Compile(context.Context) (wazero.CompiledModule, error)
+
+	// Instantiate instantiates the ModuleName module and returns a function to close it.
+	//
+	// Note: This has the same effect as the same function on wazero.HostModuleBuilder.
+	Instantiate(context.Context) (api.Closer, error)
+}
+
+// NewBuilder returns a new Builder.
+func NewBuilder(r wazero.Runtime) Builder {
+	return &builder{r: r}
+}
+
+type builder struct {
+	r wazero.Runtime
+
+	// policy is nil when there are no restrictions.
+	policy wasm.HostFuncWrapper
+}
+
+// WithPolicy implements Builder.WithPolicy
+func (b *builder) WithPolicy(p wasi_snapshot_preview1.Policy) Builder {
+	ret := *b // copy
+	ret.policy = p.(wasm.HostFuncWrapper)
+	return &ret
+}
+
+// hostModuleBuilder returns a new wazero.HostModuleBuilder for ModuleName
+func (b *builder) hostModuleBuilder() wazero.HostModuleBuilder {
+	ret := b.r.NewHostModuleBuilder(ModuleName)
+	exportFunctions(ret, b.policy)
+	return ret
+}
+
+// Compile implements Builder.Compile
+func (b *builder) Compile(ctx context.Context) (wazero.CompiledModule, error) {
+	return b.hostModuleBuilder().Compile(ctx)
+}
+
+// Instantiate implements Builder.Instantiate
+func (b *builder) Instantiate(ctx context.Context) (api.Closer, error) {
+	return b.hostModuleBuilder().Instantiate(ctx)
+}
+
+// FunctionExporter exports functions into a wazero.HostModuleBuilder.
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type FunctionExporter interface {
+	ExportFunctions(wazero.HostModuleBuilder)
+}
+
+// NewFunctionExporter returns a new FunctionExporter. This is used to add
+// functions to the ModuleName module which aren't implemented by this
+// package, or override a builtin function with an alternate implementation.
+//
+// # Example of stubbing an unsupported function
+//
+//	wasixBuilder := r.NewHostModuleBuilder(wasix.ModuleName)
+//	wasix.NewFunctionExporter().ExportFunctions(wasixBuilder)
+//
+//	// Subsequent calls to NewFunctionBuilder add or override exports.
+//	wasixBuilder.NewFunctionBuilder().
+//		WithFunc(func(ctx context.Context, fd, backlog uint32) uint32 {
+//			return 58 // ENOTSUP
+//		}).Export("sock_listen")
+func NewFunctionExporter() FunctionExporter {
+	return &functionExporter{}
+}
+
+type functionExporter struct{}
+
+// ExportFunctions implements FunctionExporter.ExportFunctions
+func (functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
+	exportFunctions(builder, nil)
+}
+
+// exportFunctions adds all go functions that implement WASIX, enforcing the
+// policy unless nil. These should be exported in the module named ModuleName.
+func exportFunctions(builder wazero.HostModuleBuilder, p wasm.HostFuncWrapper) {
+	exporter := builder.(wasm.HostFuncExporter)
+	export := exporter.ExportHostFunc
+	if p != nil {
+		export = func(fn *wasm.HostFunc) { exporter.ExportHostFunc(p.WrapHostFunc(fn)) }
+	}
+
+	// Threads of the module are tracked by the functions using them.
+	s := threads.NewSpawner()
+
+	export(futexWait)
+	export(futexWake)
+	export(futexWakeAll)
+	export(sockAcceptV2)
+	export(sockAddrLocal)
+	export(sockAddrPeer)
+	export(sockConnect)
+	export(sockOpen)
+	export(sockRecvFrom)
+	export(sockSendTo)
+	export(newThreadExit(s))
+	export(newThreadID(s))
+	export(newThreadJoin(s))
+	export(threadParallelism)
+	export(threadSleep)
+	export(newThreadSpawnV2(s))
+	export(ttyGet)
+	export(ttySet)
+}
+
+func newHostFunc(
+	name string,
+	goFunc wasixFunc,
+	paramTypes []wasm.ValueType,
+	paramNames ...string,
+) *wasm.HostFunc {
+	return &wasm.HostFunc{
+		ExportName:  name,
+		Name:        name,
+		ParamTypes:  paramTypes,
+		ParamNames:  paramNames,
+		ResultTypes: []wasm.ValueType{i32},
+		ResultNames: []string{"errno"},
+		Code:        wasm.Code{GoFunc: goFunc},
+	}
+}
+
+// wasixFunc special cases that all WASIX functions return a single Errno
+// result, which is the same as in WASI preview 1. The returned value will be
+// written back to the stack at index zero.
+type wasixFunc func(ctx context.Context, mod api.Module, params []uint64) sys.Errno
+
+// Call implements the same method as documented on api.GoModuleFunction.
+func (f wasixFunc) Call(ctx context.Context, mod api.Module, stack []uint64) {
+	if errno := f(ctx, mod, stack); errno != 0 {
+		stack[0] = uint64(wasip1.ToErrno(errno))
+	} else {
+		stack[0] = 0
+	}
+}
+
+// writeBool writes a WASIX bool, which is a single byte.
+func writeBool(mem api.Memory, offset uint32, v bool) bool {
+	var b byte
+	if v {
+		b = 1
+	}
+	return mem.WriteByte(offset, b)
+}
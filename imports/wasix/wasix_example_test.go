@@ -0,0 +1,29 @@
+package wasix_test
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/imports/wasix"
+)
+
+// This shows how to instantiate the imports needed by programs compiled for
+// WASIX.
+func Example_instantiate() {
+	ctx := context.Background()
+
+	// WASIX threads use shared memory and atomics.
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesThreads))
+	defer r.Close(ctx) // This closes everything this Runtime created.
+
+	// WASIX extends "wasi_snapshot_preview1", so programs import both it and
+	// the "wasix_32v1" module.
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	wasix.MustInstantiate(ctx, r)
+
+	// Output:
+}
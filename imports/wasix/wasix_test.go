@@ -0,0 +1,83 @@
+package wasix
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	experimentalsock "github.com/tetratelabs/wazero/experimental/sock"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/testing/proxy"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasip1"
+)
+
+var testCtx = context.Background()
+
+// requireProxyModule returns a module exporting all functions of ModuleName,
+// configured with config.
+func requireProxyModule(t *testing.T, config wazero.ModuleConfig) (api.Module, api.Closer) {
+	return requireProxyModuleWithContext(testCtx, t, config)
+}
+
+// requireProxyModuleWithContext is like requireProxyModule, except the proxy
+// module is instantiated with ctx, e.g. to configure sockets.
+func requireProxyModuleWithContext(ctx context.Context, t *testing.T, config wazero.ModuleConfig) (api.Module, api.Closer) {
+	return requirePolicyModule(ctx, t, wasi_snapshot_preview1.NewPolicy(), config)
+}
+
+// requirePolicyModule is like requireProxyModuleWithContext, except the
+// functions enforce the policy.
+func requirePolicyModule(ctx context.Context, t *testing.T, policy wasi_snapshot_preview1.Policy, config wazero.ModuleConfig) (api.Module, api.Closer) {
+	r := wazero.NewRuntime(testCtx)
+
+	compiled, err := NewBuilder(r).WithPolicy(policy).Compile(testCtx)
+	require.NoError(t, err)
+	_, err = r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	proxyCompiled, err := r.CompileModule(testCtx, proxy.NewModuleBinary(ModuleName, compiled))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(ctx, proxyCompiled, config)
+	require.NoError(t, err)
+	return mod, r
+}
+
+// requireErrno calls the function of mod with the given name and params, and
+// requires it returns the given errno.
+func requireErrno(t *testing.T, expected wasip1.Errno, mod api.Module, funcName string, params ...uint64) {
+	t.Helper()
+	results, err := mod.ExportedFunction(funcName).Call(testCtx, params...)
+	require.NoError(t, err)
+	errno := wasip1.Errno(results[0])
+	require.Equal(t, expected, errno, "want %s but have %s", wasip1.ErrnoName(expected), wasip1.ErrnoName(errno))
+}
+
+func TestBuilder_WithPolicy(t *testing.T) {
+	const resultFd = 16
+	ctx := experimentalsock.WithConfig(testCtx, experimentalsock.NewConfig().
+		WithAllowedDestination("127.0.0.1", 0))
+
+	t.Run("denied function", func(t *testing.T) {
+		policy := wasi_snapshot_preview1.NewPolicy().WithDeniedFunctions(sockOpenName)
+		mod, r := requirePolicyModule(ctx, t, policy, wazero.NewModuleConfig())
+		defer r.Close(testCtx)
+
+		requireErrno(t, wasip1.ErrnoNotcapable, mod, sockOpenName, uint64(wasip1.AddressFamilyInet4), sockTypeDgram, 0, resultFd)
+	})
+
+	t.Run("socket rights", func(t *testing.T) {
+		const noWrite = wasi_snapshot_preview1.RightsAll &^ wasi_snapshot_preview1.RightFdWrite
+		policy := wasi_snapshot_preview1.NewPolicy().WithSocketRights(noWrite, 0)
+		mod, r := requirePolicyModule(ctx, t, policy, wazero.NewModuleConfig())
+		defer r.Close(testCtx)
+
+		requireErrno(t, 0, mod, sockOpenName, uint64(wasip1.AddressFamilyInet4), sockTypeDgram, 0, resultFd)
+		fd, _ := mod.Memory().ReadUint32Le(resultFd)
+
+		requireErrno(t, wasip1.ErrnoNotcapable, mod, sockSendToName, uint64(fd), 0, 0, 0, 0, 0)
+		requireErrno(t, 0, mod, sockAddrLocalName, uint64(fd), 64) // no rights required
+	})
+}
@@ -0,0 +1,711 @@
+package component
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// The canonical ABI passes parameters and results as core values up to
+// these limits, and otherwise through linear memory.
+//
+// See https://github.com/WebAssembly/component-model/blob/main/design/mvp/CanonicalABI.md
+const (
+	maxFlatParams  = 16
+	maxFlatResults = 1
+)
+
+// latin1UTF16Tag is set in the length of a string encoded in UTF-16 with
+// StringEncodingLatin1UTF16.
+const latin1UTF16Tag = 1 << 31
+
+// maxStringByteLength is the maximum number of bytes of a string in memory.
+const maxStringByteLength = 1<<31 - 1
+
+func alignTo(ptr, align uint32) uint32 {
+	return (ptr + align - 1) &^ (align - 1)
+}
+
+// discriminantSize returns the size of the discriminant of a variant with n
+// cases.
+func discriminantSize(n int) uint32 {
+	switch {
+	case n <= 1<<8:
+		return 1
+	case n <= 1<<16:
+		return 2
+	}
+	return 4
+}
+
+// flagsSize returns the size of flags with n labels.
+func flagsSize(n int) uint32 {
+	switch {
+	case n == 0:
+		return 0
+	case n <= 8:
+		return 1
+	case n <= 16:
+		return 2
+	}
+	return 4
+}
+
+// alignment returns the alignment of t in linear memory.
+func alignment(t *ValType) uint32 {
+	switch t.Kind {
+	case ValTypeKindBool, ValTypeKindS8, ValTypeKindU8:
+		return 1
+	case ValTypeKindS16, ValTypeKindU16:
+		return 2
+	case ValTypeKindS64, ValTypeKindU64, ValTypeKindF64:
+		return 8
+	case ValTypeKindRecord, ValTypeKindTuple:
+		return fieldsAlignment(t.Fields)
+	case ValTypeKindVariant, ValTypeKindEnum, ValTypeKindOption, ValTypeKindResult:
+		return max(discriminantSize(len(t.Cases)), casesAlignment(t.Cases))
+	case ValTypeKindFlags:
+		return max(flagsSize(len(t.Labels)), 1)
+	}
+	// s32, u32, f32, char, string, list, own and borrow
+	return 4
+}
+
+// size returns the size of t in linear memory.
+func size(t *ValType) uint32 {
+	switch t.Kind {
+	case ValTypeKindBool, ValTypeKindS8, ValTypeKindU8:
+		return 1
+	case ValTypeKindS16, ValTypeKindU16:
+		return 2
+	case ValTypeKindS64, ValTypeKindU64, ValTypeKindF64, ValTypeKindString, ValTypeKindList:
+		return 8
+	case ValTypeKindRecord, ValTypeKindTuple:
+		return fieldsSize(t.Fields)
+	case ValTypeKindVariant, ValTypeKindEnum, ValTypeKindOption, ValTypeKindResult:
+		s := alignTo(discriminantSize(len(t.Cases)), casesAlignment(t.Cases))
+		var maxCaseSize uint32
+		for _, c := range t.Cases {
+			if c.Type != nil {
+				maxCaseSize = max(maxCaseSize, size(c.Type))
+			}
+		}
+		return alignTo(s+maxCaseSize, alignment(t))
+	case ValTypeKindFlags:
+		return flagsSize(len(t.Labels))
+	}
+	// s32, u32, f32, char, own and borrow
+	return 4
+}
+
+func fieldsAlignment(fields []Field) uint32 {
+	a := uint32(1)
+	for _, f := range fields {
+		a = max(a, alignment(f.Type))
+	}
+	return a
+}
+
+func fieldsSize(fields []Field) uint32 {
+	var s uint32
+	for _, f := range fields {
+		s = alignTo(s, alignment(f.Type)) + size(f.Type)
+	}
+	return alignTo(s, fieldsAlignment(fields))
+}
+
+func casesAlignment(cases []Case) uint32 {
+	a := uint32(1)
+	for _, c := range cases {
+		if c.Type != nil {
+			a = max(a, alignment(c.Type))
+		}
+	}
+	return a
+}
+
+// flatten appends the core value types which represent t to ret.
+func flatten(t *ValType, ret []api.ValueType) []api.ValueType {
+	switch t.Kind {
+	case ValTypeKindS64, ValTypeKindU64:
+		return append(ret, api.ValueTypeI64)
+	case ValTypeKindF32:
+		return append(ret, api.ValueTypeF32)
+	case ValTypeKindF64:
+		return append(ret, api.ValueTypeF64)
+	case ValTypeKindString, ValTypeKindList:
+		return append(ret, api.ValueTypeI32, api.ValueTypeI32)
+	case ValTypeKindRecord, ValTypeKindTuple:
+		for _, f := range t.Fields {
+			ret = flatten(f.Type, ret)
+		}
+		return ret
+	case ValTypeKindVariant, ValTypeKindOption, ValTypeKindResult:
+		ret = append(ret, api.ValueTypeI32)
+		return append(ret, flattenCases(t.Cases)...)
+	case ValTypeKindFlags:
+		if len(t.Labels) == 0 {
+			return ret
+		}
+	}
+	// bool, s8...u32, char, enum, flags, own and borrow
+	return append(ret, api.ValueTypeI32)
+}
+
+// flattenCases returns the core value types which represent the payload of
+// any of the cases, which follow the discriminant of a variant.
+func flattenCases(cases []Case) (ret []api.ValueType) {
+	for _, c := range cases {
+		if c.Type == nil {
+			continue
+		}
+		for i, ft := range flatten(c.Type, nil) {
+			if i < len(ret) {
+				ret[i] = join(ret[i], ft)
+			} else {
+				ret = append(ret, ft)
+			}
+		}
+	}
+	return
+}
+
+// join returns the core value type which can hold values of either type.
+func join(a, b api.ValueType) api.ValueType {
+	if a == b {
+		return a
+	}
+	if (a == api.ValueTypeI32 && b == api.ValueTypeF32) || (a == api.ValueTypeF32 && b == api.ValueTypeI32) {
+		return api.ValueTypeI32
+	}
+	return api.ValueTypeI64
+}
+
+// flattenFields returns the core value types of the fields.
+func flattenFields(fields []Field) (ret []api.ValueType) {
+	for _, f := range fields {
+		ret = flatten(f.Type, ret)
+	}
+	return
+}
+
+// flatSignature returns the core signature of a lifted or lowered function.
+// Parameters are passed through memory when there are more than
+// maxFlatParams of them. Results are returned through memory when there are
+// more than maxFlatResults of them, with a pointer returned by a lifted
+// function, or passed as a last parameter to a lowered function.
+func flatSignature(t *FuncType, lower bool) (params, results []api.ValueType) {
+	params, results = flattenFields(t.Params), flattenFields(t.Results)
+	if len(params) > maxFlatParams {
+		params = []api.ValueType{api.ValueTypeI32}
+	}
+	if len(results) > maxFlatResults {
+		if lower {
+			params = append(params, api.ValueTypeI32)
+			results = nil
+		} else {
+			results = []api.ValueType{api.ValueTypeI32}
+		}
+	}
+	return
+}
+
+// callContext is the state of a call through the canonical ABI.
+type callContext struct {
+	ctx  context.Context
+	inst *ComponentInstance
+	opts *canonOptions
+	// lenders are the handles whose resource was lent for a call to the
+	// host, which can't be dropped until it returns.
+	lenders []*handle
+	// borrows is the number of borrowed handles lent to the component for
+	// this call, which must be dropped before it returns.
+	borrows int
+}
+
+// trap aborts a call through the canonical ABI with an error. Callers from
+// the host recover it, and calls from core modules are aborted by the engine.
+func trap(format string, args ...any) {
+	panic(fmt.Errorf(format, args...))
+}
+
+func (cx *callContext) memory() api.Memory {
+	if cx.opts.mem == nil {
+		trap("canonical ABI requires a memory")
+	}
+	return cx.opts.mem
+}
+
+// realloc allocates memory in the component with its realloc function.
+func (cx *callContext) realloc(align, byteCount uint32) uint32 {
+	if cx.opts.realloc == nil {
+		trap("canonical ABI requires a realloc function")
+	}
+	res, err := cx.opts.realloc.function().Call(cx.ctx, 0, 0, uint64(align), uint64(byteCount))
+	if err != nil {
+		panic(err)
+	}
+	ptr := uint32(res[0])
+	if ptr != alignTo(ptr, align) {
+		trap("realloc returned an unaligned pointer: %d", ptr)
+	}
+	if uint64(ptr)+uint64(byteCount) > uint64(cx.memory().Size()) {
+		trap("realloc returned an out of bounds pointer: %d", ptr)
+	}
+	return ptr
+}
+
+func (cx *callContext) read(ptr, byteCount uint32) []byte {
+	b, ok := cx.memory().Read(ptr, byteCount)
+	if !ok {
+		trap("out of bounds memory access: %d+%d", ptr, byteCount)
+	}
+	return b
+}
+
+func (cx *callContext) readUint(ptr, byteCount uint32) (ret uint64) {
+	b := cx.read(ptr, byteCount)
+	for i := int(byteCount) - 1; i >= 0; i-- {
+		ret = ret<<8 | uint64(b[i])
+	}
+	return
+}
+
+func (cx *callContext) writeUint(ptr, byteCount uint32, v uint64) {
+	b := cx.read(ptr, byteCount)
+	for i := range b {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
+
+// flatReader reads the core values of a value being lifted.
+type flatReader struct {
+	vals []uint64
+	i    int
+}
+
+func (r *flatReader) next() uint64 {
+	v := r.vals[r.i]
+	r.i++
+	return v
+}
+
+// liftFlat lifts a value of type t from core values.
+func (cx *callContext) liftFlat(r *flatReader, t *ValType) any {
+	switch t.Kind {
+	case ValTypeKindS64:
+		return int64(r.next())
+	case ValTypeKindU64:
+		return r.next()
+	case ValTypeKindF32:
+		return math.Float32frombits(uint32(r.next()))
+	case ValTypeKindF64:
+		return math.Float64frombits(r.next())
+	case ValTypeKindString:
+		ptr, n := uint32(r.next()), uint32(r.next())
+		return cx.loadString(ptr, n)
+	case ValTypeKindList:
+		ptr, n := uint32(r.next()), uint32(r.next())
+		return cx.loadList(ptr, n, t.Elem)
+	case ValTypeKindRecord, ValTypeKindTuple:
+		ret := make([]any, len(t.Fields))
+		for i, f := range t.Fields {
+			ret[i] = cx.liftFlat(r, f.Type)
+		}
+		return ret
+	case ValTypeKindVariant, ValTypeKindOption, ValTypeKindResult:
+		c := cx.liftCase(t, uint32(r.next()))
+		end := r.i + len(flattenCases(t.Cases))
+		var v any
+		if ct := t.Cases[c].Type; ct != nil {
+			v = cx.liftFlat(r, ct)
+		}
+		r.i = end
+		return Variant{Case: c, Value: v}
+	case ValTypeKindFlags:
+		if len(t.Labels) == 0 {
+			return uint32(0)
+		}
+	}
+	return cx.liftInt(t, uint32(r.next()))
+}
+
+// liftInt lifts a value of type t represented by an i32.
+func (cx *callContext) liftInt(t *ValType, v uint32) any {
+	switch t.Kind {
+	case ValTypeKindBool:
+		return v != 0
+	case ValTypeKindS8:
+		return int8(v)
+	case ValTypeKindU8:
+		return uint8(v)
+	case ValTypeKindS16:
+		return int16(v)
+	case ValTypeKindU16:
+		return uint16(v)
+	case ValTypeKindS32:
+		return int32(v)
+	case ValTypeKindU32:
+		return v
+	case ValTypeKindChar:
+		if v >= 0x110000 || (v >= 0xd800 && v < 0xe000) {
+			trap("invalid char: %#x", v)
+		}
+		return rune(v)
+	case ValTypeKindEnum:
+		return cx.liftCase(t, v)
+	case ValTypeKindFlags:
+		if n := len(t.Labels); n < 32 {
+			v &= 1<<n - 1
+		}
+		return v
+	case ValTypeKindOwn:
+		return cx.liftOwn(t.Resource, v)
+	case ValTypeKindBorrow:
+		return cx.liftBorrow(t.Resource, v)
+	}
+	panic(fmt.Sprintf("BUG: %s is not represented by an i32", t.Kind))
+}
+
+func (cx *callContext) liftCase(t *ValType, c uint32) uint32 {
+	if c >= uint32(len(t.Cases)) {
+		trap("invalid case of %s: %d", t, c)
+	}
+	return c
+}
+
+// load lifts a value of type t from memory.
+func (cx *callContext) load(ptr uint32, t *ValType) any {
+	switch t.Kind {
+	case ValTypeKindS64:
+		return int64(cx.readUint(ptr, 8))
+	case ValTypeKindU64:
+		return cx.readUint(ptr, 8)
+	case ValTypeKindF32:
+		return math.Float32frombits(uint32(cx.readUint(ptr, 4)))
+	case ValTypeKindF64:
+		return math.Float64frombits(cx.readUint(ptr, 8))
+	case ValTypeKindString:
+		return cx.loadString(uint32(cx.readUint(ptr, 4)), uint32(cx.readUint(ptr+4, 4)))
+	case ValTypeKindList:
+		return cx.loadList(uint32(cx.readUint(ptr, 4)), uint32(cx.readUint(ptr+4, 4)), t.Elem)
+	case ValTypeKindRecord, ValTypeKindTuple:
+		return cx.loadFields(ptr, t.Fields)
+	case ValTypeKindVariant, ValTypeKindOption, ValTypeKindResult:
+		ds := discriminantSize(len(t.Cases))
+		c := cx.liftCase(t, uint32(cx.readUint(ptr, ds)))
+		var v any
+		if ct := t.Cases[c].Type; ct != nil {
+			v = cx.load(ptr+alignTo(ds, casesAlignment(t.Cases)), ct)
+		}
+		return Variant{Case: c, Value: v}
+	case ValTypeKindFlags:
+		if len(t.Labels) == 0 {
+			return uint32(0)
+		}
+	}
+	return cx.liftInt(t, uint32(cx.readUint(ptr, size(t))))
+}
+
+func (cx *callContext) loadFields(ptr uint32, fields []Field) []any {
+	ret := make([]any, len(fields))
+	for i, f := range fields {
+		ptr = alignTo(ptr, alignment(f.Type))
+		ret[i] = cx.load(ptr, f.Type)
+		ptr += size(f.Type)
+	}
+	return ret
+}
+
+func (cx *callContext) loadString(ptr, taggedLen uint32) string {
+	encoding, n := cx.opts.encoding, taggedLen
+	if encoding == StringEncodingLatin1UTF16 {
+		if taggedLen&latin1UTF16Tag != 0 {
+			encoding, n = StringEncodingUTF16, taggedLen&^latin1UTF16Tag
+		}
+	}
+	switch encoding {
+	case StringEncodingUTF16:
+		if ptr%2 != 0 {
+			trap("unaligned string: %d", ptr)
+		}
+		if uint64(n)*2 > maxStringByteLength {
+			trap("string too long: %d", n)
+		}
+		b := cx.read(ptr, n*2)
+		units := make([]uint16, n)
+		for i := range units {
+			units[i] = uint16(b[2*i]) | uint16(b[2*i+1])<<8
+		}
+		return string(utf16.Decode(units))
+	case StringEncodingLatin1UTF16:
+		b := cx.read(ptr, n)
+		runes := make([]rune, n)
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return string(runes)
+	}
+	b := cx.read(ptr, n)
+	if !utf8.Valid(b) {
+		trap("invalid UTF-8 string")
+	}
+	return string(b)
+}
+
+func (cx *callContext) loadList(ptr, n uint32, elem *ValType) any {
+	if ptr != alignTo(ptr, alignment(elem)) {
+		trap("unaligned list: %d", ptr)
+	}
+	elemSize := size(elem)
+	if uint64(ptr)+uint64(n)*uint64(elemSize) > uint64(cx.memory().Size()) {
+		trap("list out of bounds: %d+%d", ptr, n)
+	}
+	if elem.Kind == ValTypeKindU8 {
+		b := cx.read(ptr, n)
+		return append(make([]byte, 0, n), b...)
+	}
+	ret := make([]any, n)
+	for i := range ret {
+		ret[i] = cx.load(ptr+uint32(i)*elemSize, elem)
+	}
+	return ret
+}
+
+// lowerFlat appends the core values which represent v, of type t, to ret.
+func (cx *callContext) lowerFlat(ret []uint64, t *ValType, v any) []uint64 {
+	switch t.Kind {
+	case ValTypeKindS64:
+		return append(ret, uint64(valueOf[int64](t, v)))
+	case ValTypeKindU64:
+		return append(ret, valueOf[uint64](t, v))
+	case ValTypeKindF32:
+		return append(ret, uint64(math.Float32bits(valueOf[float32](t, v))))
+	case ValTypeKindF64:
+		return append(ret, math.Float64bits(valueOf[float64](t, v)))
+	case ValTypeKindString:
+		ptr, n := cx.storeString(valueOf[string](t, v))
+		return append(ret, uint64(ptr), uint64(n))
+	case ValTypeKindList:
+		ptr, n := cx.storeList(t.Elem, v)
+		return append(ret, uint64(ptr), uint64(n))
+	case ValTypeKindRecord, ValTypeKindTuple:
+		fields := cx.fieldsOf(t, v)
+		for i, f := range t.Fields {
+			ret = cx.lowerFlat(ret, f.Type, fields[i])
+		}
+		return ret
+	case ValTypeKindVariant, ValTypeKindOption, ValTypeKindResult:
+		c := cx.caseOf(t, v)
+		ret = append(ret, uint64(c.Case))
+		flat := flattenCases(t.Cases)
+		start := len(ret)
+		if ct := t.Cases[c.Case].Type; ct != nil {
+			ret = cx.lowerFlat(ret, ct, c.Value)
+		}
+		for len(ret) < start+len(flat) {
+			ret = append(ret, 0)
+		}
+		return ret
+	case ValTypeKindFlags:
+		if len(t.Labels) == 0 {
+			return ret
+		}
+	}
+	return append(ret, uint64(cx.lowerInt(t, v)))
+}
+
+// lowerInt lowers a value of type t which is represented by an i32.
+func (cx *callContext) lowerInt(t *ValType, v any) uint32 {
+	switch t.Kind {
+	case ValTypeKindBool:
+		if valueOf[bool](t, v) {
+			return 1
+		}
+		return 0
+	case ValTypeKindS8:
+		return uint32(valueOf[int8](t, v))
+	case ValTypeKindU8:
+		return uint32(valueOf[uint8](t, v))
+	case ValTypeKindS16:
+		return uint32(valueOf[int16](t, v))
+	case ValTypeKindU16:
+		return uint32(valueOf[uint16](t, v))
+	case ValTypeKindS32:
+		return uint32(valueOf[int32](t, v))
+	case ValTypeKindU32:
+		return valueOf[uint32](t, v)
+	case ValTypeKindChar:
+		r := valueOf[rune](t, v)
+		if !utf8.ValidRune(r) {
+			trap("invalid char: %#x", r)
+		}
+		return uint32(r)
+	case ValTypeKindEnum:
+		c := valueOf[uint32](t, v)
+		if c >= uint32(len(t.Cases)) {
+			trap("invalid case of %s: %d", t, c)
+		}
+		return c
+	case ValTypeKindFlags:
+		return valueOf[uint32](t, v)
+	case ValTypeKindOwn:
+		return cx.lowerOwn(t.Resource, v)
+	case ValTypeKindBorrow:
+		return cx.lowerBorrow(t.Resource, v)
+	}
+	panic(fmt.Sprintf("BUG: %s is not represented by an i32", t.Kind))
+}
+
+// store lowers a value of type t into memory.
+func (cx *callContext) store(ptr uint32, t *ValType, v any) {
+	switch t.Kind {
+	case ValTypeKindS64:
+		cx.writeUint(ptr, 8, uint64(valueOf[int64](t, v)))
+	case ValTypeKindU64:
+		cx.writeUint(ptr, 8, valueOf[uint64](t, v))
+	case ValTypeKindF32:
+		cx.writeUint(ptr, 4, uint64(math.Float32bits(valueOf[float32](t, v))))
+	case ValTypeKindF64:
+		cx.writeUint(ptr, 8, math.Float64bits(valueOf[float64](t, v)))
+	case ValTypeKindString:
+		p, n := cx.storeString(valueOf[string](t, v))
+		cx.writeUint(ptr, 4, uint64(p))
+		cx.writeUint(ptr+4, 4, uint64(n))
+	case ValTypeKindList:
+		p, n := cx.storeList(t.Elem, v)
+		cx.writeUint(ptr, 4, uint64(p))
+		cx.writeUint(ptr+4, 4, uint64(n))
+	case ValTypeKindRecord, ValTypeKindTuple:
+		cx.storeFields(ptr, t.Fields, cx.fieldsOf(t, v))
+	case ValTypeKindVariant, ValTypeKindOption, ValTypeKindResult:
+		c := cx.caseOf(t, v)
+		ds := discriminantSize(len(t.Cases))
+		cx.writeUint(ptr, ds, uint64(c.Case))
+		if ct := t.Cases[c.Case].Type; ct != nil {
+			cx.store(ptr+alignTo(ds, casesAlignment(t.Cases)), ct, c.Value)
+		}
+	case ValTypeKindFlags:
+		if len(t.Labels) > 0 {
+			cx.writeUint(ptr, size(t), uint64(valueOf[uint32](t, v)))
+		}
+	default:
+		cx.writeUint(ptr, size(t), uint64(cx.lowerInt(t, v)))
+	}
+}
+
+func (cx *callContext) storeFields(ptr uint32, fields []Field, vals []any) {
+	for i, f := range fields {
+		ptr = alignTo(ptr, alignment(f.Type))
+		cx.store(ptr, f.Type, vals[i])
+		ptr += size(f.Type)
+	}
+}
+
+// storeString copies s into memory allocated with realloc, and returns its
+// pointer and length, which is tagged with latin1UTF16Tag if needed.
+func (cx *callContext) storeString(s string) (ptr, taggedLen uint32) {
+	switch cx.opts.encoding {
+	case StringEncodingUTF16:
+		return cx.storeUTF16(s)
+	case StringEncodingLatin1UTF16:
+		latin1 := make([]byte, 0, len(s))
+		for _, r := range s {
+			if r > 0xff {
+				ptr, n := cx.storeUTF16(s)
+				return ptr, n | latin1UTF16Tag
+			}
+			latin1 = append(latin1, byte(r))
+		}
+		if len(latin1) > maxStringByteLength {
+			trap("string too long: %d", len(latin1))
+		}
+		n := uint32(len(latin1))
+		ptr = cx.realloc(2, n)
+		cx.memory().Write(ptr, latin1)
+		return ptr, n
+	}
+	if len(s) > maxStringByteLength {
+		trap("string too long: %d", len(s))
+	}
+	n := uint32(len(s))
+	ptr = cx.realloc(1, n)
+	cx.memory().Write(ptr, []byte(s))
+	return ptr, n
+}
+
+func (cx *callContext) storeUTF16(s string) (ptr, n uint32) {
+	units := utf16.Encode([]rune(s))
+	if len(units)*2 > maxStringByteLength {
+		trap("string too long: %d", len(units))
+	}
+	b := make([]byte, len(units)*2)
+	for i, u := range units {
+		b[2*i], b[2*i+1] = byte(u), byte(u>>8)
+	}
+	n = uint32(len(units))
+	ptr = cx.realloc(2, n*2)
+	cx.memory().Write(ptr, b)
+	return ptr, n
+}
+
+// storeList copies v, a list of elem, into memory allocated with realloc,
+// and returns its pointer and length.
+func (cx *callContext) storeList(elem *ValType, v any) (ptr, n uint32) {
+	if b, ok := v.([]byte); ok && elem.Kind == ValTypeKindU8 {
+		if uint64(len(b)) > math.MaxUint32 {
+			trap("list too long: %d", len(b))
+		}
+		n = uint32(len(b))
+		ptr = cx.realloc(1, n)
+		cx.memory().Write(ptr, b)
+		return
+	}
+	elems, ok := v.([]any)
+	if !ok {
+		trap("expected list<%s> but was %T", elem, v)
+	}
+	elemSize := size(elem)
+	if uint64(len(elems))*uint64(elemSize) > math.MaxUint32 {
+		trap("list too long: %d", len(elems))
+	}
+	n = uint32(len(elems))
+	ptr = cx.realloc(alignment(elem), n*elemSize)
+	for i, e := range elems {
+		cx.store(ptr+uint32(i)*elemSize, elem, e)
+	}
+	return
+}
+
+func (cx *callContext) fieldsOf(t *ValType, v any) []any {
+	fields, ok := v.([]any)
+	if !ok || len(fields) != len(t.Fields) {
+		trap("expected %s but was %v", t, v)
+	}
+	return fields
+}
+
+func (cx *callContext) caseOf(t *ValType, v any) Variant {
+	c, ok := v.(Variant)
+	if !ok {
+		trap("expected %s but was %T", t, v)
+	}
+	if c.Case >= uint32(len(t.Cases)) {
+		trap("invalid case of %s: %d", t, c.Case)
+	}
+	return c
+}
+
+// valueOf returns v as a T, the Go type representing t.
+func valueOf[T any](t *ValType, v any) T {
+	ret, ok := v.(T)
+	if !ok {
+		trap("expected %s but was %T", t, v)
+	}
+	return ret
+}
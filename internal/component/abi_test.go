@@ -0,0 +1,239 @@
+package component
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var (
+	u8     = primitiveTypes[ValTypeKindU8]
+	u16    = primitiveTypes[ValTypeKindU16]
+	u32    = primitiveTypes[ValTypeKindU32]
+	s64    = primitiveTypes[ValTypeKindS64]
+	f32    = primitiveTypes[ValTypeKindF32]
+	f64    = primitiveTypes[ValTypeKindF64]
+	char   = primitiveTypes[ValTypeKindChar]
+	strTyp = primitiveTypes[ValTypeKindString]
+
+	record = &ValType{Kind: ValTypeKindRecord, Fields: []Field{{Name: "a", Type: u8}, {Name: "b", Type: u32}, {Name: "c", Type: u16}}}
+	// variant has payloads which are joined into an i64.
+	variant = &ValType{Kind: ValTypeKindVariant, Cases: []Case{{Name: "a", Type: f32}, {Name: "b"}, {Name: "c", Type: s64}}}
+	option  = &ValType{Kind: ValTypeKindOption, Cases: []Case{{Name: "none"}, {Name: "some", Type: u8}}}
+	enum    = &ValType{Kind: ValTypeKindEnum, Cases: []Case{{Name: "x"}, {Name: "y"}}}
+	flags   = &ValType{Kind: ValTypeKindFlags, Labels: []string{"r", "w", "x"}}
+	tuple   = &ValType{Kind: ValTypeKindTuple, Fields: []Field{{Type: f64}, {Type: char}}}
+)
+
+func TestLayout(t *testing.T) {
+	i32, i64 := api.ValueTypeI32, api.ValueTypeI64
+	tests := []struct {
+		name         string
+		t            *ValType
+		size, align  uint32
+		expectedFlat []api.ValueType
+	}{
+		{name: "u8", t: u8, size: 1, align: 1, expectedFlat: []api.ValueType{i32}},
+		{name: "s64", t: s64, size: 8, align: 8, expectedFlat: []api.ValueType{i64}},
+		{name: "f32", t: f32, size: 4, align: 4, expectedFlat: []api.ValueType{api.ValueTypeF32}},
+		{name: "string", t: strTyp, size: 8, align: 4, expectedFlat: []api.ValueType{i32, i32}},
+		{name: "list", t: &ValType{Kind: ValTypeKindList, Elem: s64}, size: 8, align: 4, expectedFlat: []api.ValueType{i32, i32}},
+		{name: "record", t: record, size: 12, align: 4, expectedFlat: []api.ValueType{i32, i32, i32}},
+		{name: "variant", t: variant, size: 16, align: 8, expectedFlat: []api.ValueType{i32, i64}},
+		{name: "option", t: option, size: 2, align: 1, expectedFlat: []api.ValueType{i32, i32}},
+		{name: "enum", t: enum, size: 1, align: 1, expectedFlat: []api.ValueType{i32}},
+		{name: "flags", t: flags, size: 1, align: 1, expectedFlat: []api.ValueType{i32}},
+		{name: "tuple", t: tuple, size: 16, align: 8, expectedFlat: []api.ValueType{api.ValueTypeF64, i32}},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.size, size(tc.t))
+			require.Equal(t, tc.align, alignment(tc.t))
+			require.Equal(t, tc.expectedFlat, flatten(tc.t, nil))
+		})
+	}
+}
+
+func TestFlatSignature(t *testing.T) {
+	i32 := api.ValueTypeI32
+	strs := make([]Field, 9)
+	for i := range strs {
+		strs[i].Type = strTyp
+	}
+	ft := &FuncType{Params: strs, Results: []Field{{Type: strTyp}}}
+
+	params, results := flatSignature(ft, false)
+	require.Equal(t, []api.ValueType{i32}, params)
+	require.Equal(t, []api.ValueType{i32}, results)
+
+	params, results = flatSignature(ft, true)
+	require.Equal(t, []api.ValueType{i32, i32}, params)
+	require.Nil(t, results)
+}
+
+func TestCallContext_roundTrip(t *testing.T) {
+	cx := &callContext{
+		ctx:  context.Background(),
+		opts: &canonOptions{mem: &wasm.MemoryInstance{Buffer: make([]byte, 64)}},
+	}
+	tests := []struct {
+		name string
+		t    *ValType
+		v    any
+	}{
+		{name: "u8", t: u8, v: uint8(0xfe)},
+		{name: "s64", t: s64, v: int64(-2)},
+		{name: "f64", t: f64, v: math.Pi},
+		{name: "char", t: char, v: '世'},
+		{name: "record", t: record, v: []any{uint8(1), uint32(2), uint16(3)}},
+		{name: "variant f32", t: variant, v: Variant{Case: 0, Value: float32(1.5)}},
+		{name: "variant empty", t: variant, v: Variant{Case: 1}},
+		{name: "variant s64", t: variant, v: Variant{Case: 2, Value: int64(-1)}},
+		{name: "option", t: option, v: Some(uint8(7))},
+		{name: "none", t: option, v: None},
+		{name: "enum", t: enum, v: uint32(1)},
+		{name: "flags", t: flags, v: uint32(0b101)},
+		{name: "tuple", t: tuple, v: []any{float64(-0.5), 'a'}},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			flat := cx.lowerFlat(nil, tc.t, tc.v)
+			require.Equal(t, len(flatten(tc.t, nil)), len(flat))
+			require.Equal(t, tc.v, cx.liftFlat(&flatReader{vals: flat}, tc.t))
+
+			cx.store(8, tc.t, tc.v)
+			require.Equal(t, tc.v, cx.load(8, tc.t))
+		})
+	}
+}
+
+func TestCallContext_traps(t *testing.T) {
+	mem := &wasm.MemoryInstance{Buffer: make([]byte, 16)}
+	cx := &callContext{ctx: context.Background(), opts: &canonOptions{mem: mem}}
+
+	tests := []struct {
+		name        string
+		fn          func()
+		expectedErr string
+	}{
+		{
+			name:        "invalid char",
+			fn:          func() { cx.liftFlat(&flatReader{vals: []uint64{0xd800}}, char) },
+			expectedErr: "invalid char: 0xd800",
+		},
+		{
+			name:        "invalid case",
+			fn:          func() { cx.liftFlat(&flatReader{vals: []uint64{2}}, enum) },
+			expectedErr: "invalid case of enum{x, y}: 2",
+		},
+		{
+			name:        "wrong Go type",
+			fn:          func() { cx.lowerFlat(nil, u32, 1) },
+			expectedErr: "expected u32 but was int",
+		},
+		{
+			name:        "out of bounds string",
+			fn:          func() { cx.liftFlat(&flatReader{vals: []uint64{8, 16}}, strTyp) },
+			expectedErr: "out of bounds memory access: 8+16",
+		},
+		{
+			name: "invalid UTF-8",
+			fn: func() {
+				mem.Buffer[0] = 0xff
+				cx.liftFlat(&flatReader{vals: []uint64{0, 1}}, strTyp)
+			},
+			expectedErr: "invalid UTF-8 string",
+		},
+		{
+			name:        "no realloc",
+			fn:          func() { cx.lowerFlat(nil, strTyp, "a") },
+			expectedErr: "canonical ABI requires a realloc function",
+		},
+		{
+			name:        "invalid handle",
+			fn:          func() { cx.liftOwn(&ResourceType{Name: "r"}, 1) },
+			expectedErr: "invalid handle: 1",
+		},
+	}
+	cx.inst = &ComponentInstance{}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			err := require.CapturePanic(tc.fn)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestCallContext_strings(t *testing.T) {
+	mem := &wasm.MemoryInstance{Buffer: make([]byte, 32)}
+	copy(mem.Buffer, []byte{'h', 0, 0xe9, 0, 0x16, 0x4e})
+
+	tests := []struct {
+		name      string
+		encoding  StringEncoding
+		taggedLen uint32
+		expected  string
+	}{
+		{name: "utf8", encoding: StringEncodingUTF8, taggedLen: 1, expected: "h"},
+		{name: "utf16", encoding: StringEncodingUTF16, taggedLen: 3, expected: "hé世"},
+		{name: "latin1", encoding: StringEncodingLatin1UTF16, taggedLen: 3, expected: "h\x00é"},
+		{name: "latin1 tagged utf16", encoding: StringEncodingLatin1UTF16, taggedLen: 2 | latin1UTF16Tag, expected: "hé"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			cx := &callContext{opts: &canonOptions{encoding: tc.encoding, mem: mem}}
+			require.Equal(t, tc.expected, cx.loadString(0, tc.taggedLen))
+		})
+	}
+}
+
+func TestHandleTable(t *testing.T) {
+	rt := &ResourceType{Name: "r"}
+	var table handleTable
+
+	i := table.add(&handle{rt: rt, rep: "a", own: true})
+	require.Equal(t, uint32(1), i)
+	require.Equal(t, uint32(2), table.add(&handle{rt: rt, rep: "b", own: true}))
+
+	require.Equal(t, "a", table.remove(i, rt).rep)
+	// The index of a removed handle is reused.
+	require.Equal(t, i, table.add(&handle{rt: rt, rep: "c", own: true}))
+	require.Equal(t, "c", table.get(i, rt).rep)
+
+	err := require.CapturePanic(func() { table.get(i, &ResourceType{Name: "other"}) })
+	require.EqualError(t, err, "handle 1 is not a other")
+	require.Equal(t, 2, len(table.removeAll()))
+}
+
+func TestVersionsCompatible(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "0.2.0", b: "0.2.3", expected: true},
+		{a: "0.2.0", b: "0.3.0", expected: false},
+		{a: "1.0.0", b: "1.2.0", expected: true},
+		{a: "1.0.0", b: "2.0.0", expected: false},
+		{a: "0.2.0-rc-2023-11-10", b: "0.2.0", expected: true},
+		{a: "invalid", b: "0.2.0", expected: false},
+	}
+	for _, tc := range tests {
+		require.Equal(t, tc.expected, versionsCompatible(tc.a, tc.b), "%s %s", tc.a, tc.b)
+	}
+
+	require.True(t, nameMatches("wasi:cli/stdout@0.2.0", "wasi:cli/stdout@0.2.1"))
+	require.True(t, nameMatches("wasi:cli/stdout@0.2.0", "wasi:cli/stdout"))
+	require.False(t, nameMatches("wasi:cli/stdout@0.2.0", "wasi:cli/stderr@0.2.0"))
+}
@@ -0,0 +1,452 @@
+// Package component implements the WebAssembly Component Model on top of
+// internal/wasm: a decoder for the component binary format, the canonical ABI
+// which lifts and lowers values between core modules and the host, and the
+// linker which instantiates the core modules of a component.
+//
+// See https://github.com/WebAssembly/component-model
+package component
+
+// Component is a decoded WebAssembly component.
+//
+// Unlike a core module, definitions of a component are not grouped by kind:
+// each one may refer to those before it, so they are kept in binary order.
+//
+// See https://github.com/WebAssembly/component-model/blob/main/design/mvp/Binary.md
+type Component struct {
+	Definitions []Definition
+}
+
+// Definition is one of CoreModule, CoreInstance, CoreType, Nested, Instance,
+// Alias, Type, Canon, Start, Import or Export.
+type Definition interface {
+	definition()
+}
+
+// Sort is the kind of item in an index space. Core sorts have the same value
+// as in the binary format, and the other ones are offset by SortComponentBase.
+type Sort uint16
+
+const (
+	SortCoreFunc     Sort = 0x00
+	SortCoreTable    Sort = 0x01
+	SortCoreMemory   Sort = 0x02
+	SortCoreGlobal   Sort = 0x03
+	SortCoreTag      Sort = 0x04
+	SortCoreType     Sort = 0x10
+	SortCoreModule   Sort = 0x11
+	SortCoreInstance Sort = 0x12
+
+	SortComponentBase Sort = 0x100
+	SortFunc               = SortComponentBase | 0x01
+	SortValue              = SortComponentBase | 0x02
+	SortType               = SortComponentBase | 0x03
+	SortComponent          = SortComponentBase | 0x04
+	SortInstance           = SortComponentBase | 0x05
+)
+
+// IsCore returns true if the sort is one of a core index space.
+func (s Sort) IsCore() bool {
+	return s < SortComponentBase
+}
+
+// String implements fmt.Stringer.
+func (s Sort) String() string {
+	switch s {
+	case SortCoreFunc:
+		return "core func"
+	case SortCoreTable:
+		return "core table"
+	case SortCoreMemory:
+		return "core memory"
+	case SortCoreGlobal:
+		return "core global"
+	case SortCoreTag:
+		return "core tag"
+	case SortCoreType:
+		return "core type"
+	case SortCoreModule:
+		return "core module"
+	case SortCoreInstance:
+		return "core instance"
+	case SortFunc:
+		return "func"
+	case SortValue:
+		return "value"
+	case SortType:
+		return "type"
+	case SortComponent:
+		return "component"
+	case SortInstance:
+		return "instance"
+	}
+	return "unknown"
+}
+
+// CoreModule is an embedded core module, which isn't decoded until compiled.
+type CoreModule struct {
+	Binary []byte
+}
+
+// CoreInstance either instantiates a core module, or bundles core
+// definitions as the exports of a new core instance when Exports is set.
+type CoreInstance struct {
+	// Module is the core module to instantiate, unless Exports is set.
+	Module uint32
+	// Args are the core instances providing the imports of Module.
+	Args []CoreInstantiateArg
+	// Exports are the exports of a core instance which instantiates nothing.
+	Exports []Export
+	// IsExports is true if this bundles Exports instead of instantiating.
+	IsExports bool
+}
+
+// CoreInstantiateArg names the core instance to resolve the imports of a
+// module with the given module name.
+type CoreInstantiateArg struct {
+	Name     string
+	Instance uint32
+}
+
+// CoreType is a core function or module type. These only serve validation,
+// so are retained undecoded.
+type CoreType struct {
+	Binary []byte
+}
+
+// Nested is a component defined inside another.
+type Nested struct {
+	Component *Component
+}
+
+// Instance either instantiates a component, or bundles definitions as the
+// exports of a new instance when IsExports is set.
+type Instance struct {
+	// Component is the component to instantiate, unless IsExports is set.
+	Component uint32
+	// Args are the definitions providing the imports of Component.
+	Args []Export
+	// Exports are the exports of an instance which instantiates nothing.
+	Exports []Export
+	// IsExports is true if this bundles Exports instead of instantiating.
+	IsExports bool
+}
+
+// AliasKind is the kind of target of an Alias.
+type AliasKind byte
+
+const (
+	// AliasKindExport aliases an export of an instance.
+	AliasKindExport AliasKind = 0x00
+	// AliasKindCoreExport aliases an export of a core instance.
+	AliasKindCoreExport AliasKind = 0x01
+	// AliasKindOuter aliases a definition of an enclosing component.
+	AliasKindOuter AliasKind = 0x02
+)
+
+// Alias adds a definition to the index space of Sort, from the export Name
+// of the Instance, or the definition Index of the enclosing component Count
+// levels up.
+type Alias struct {
+	Sort     Sort
+	Kind     AliasKind
+	Instance uint32
+	Name     string
+	Count    uint32
+	Index    uint32
+}
+
+// Type is a type definition, one of *ValTypeDef, *FuncTypeDef,
+// *ComponentTypeDef, *InstanceTypeDef or *ResourceTypeDef.
+type Type struct {
+	Def TypeDef
+}
+
+// TypeDef is the definition of a Type.
+type TypeDef interface {
+	typeDef()
+}
+
+// CanonKind is the kind of function a Canon defines.
+type CanonKind byte
+
+const (
+	// CanonLift lifts a core function to a function.
+	CanonLift CanonKind = 0x00
+	// CanonLower lowers a function to a core function.
+	CanonLower CanonKind = 0x01
+	// CanonResourceNew defines a core function creating a handle.
+	CanonResourceNew CanonKind = 0x02
+	// CanonResourceDrop defines a core function dropping a handle.
+	CanonResourceDrop CanonKind = 0x03
+	// CanonResourceRep defines a core function returning the representation
+	// of a handle.
+	CanonResourceRep CanonKind = 0x04
+)
+
+// Canon defines a function with the canonical ABI.
+type Canon struct {
+	Kind CanonKind
+	// Func is the core function lifted, or the function lowered.
+	Func uint32
+	// Options configure how values are lifted and lowered.
+	Options CanonOptions
+	// Type is the function type of CanonLift, or the resource type of the
+	// resource functions.
+	Type uint32
+}
+
+// StringEncoding is the encoding of strings in the linear memory.
+type StringEncoding byte
+
+const (
+	StringEncodingUTF8 StringEncoding = iota
+	StringEncodingUTF16
+	StringEncodingLatin1UTF16
+)
+
+// CanonOptions are the options of the canonical ABI.
+type CanonOptions struct {
+	StringEncoding StringEncoding
+	// Memory is the core memory strings and lists are read from and written
+	// to, if HasMemory.
+	Memory    uint32
+	HasMemory bool
+	// Realloc is the core function allocating memory, if HasRealloc.
+	Realloc    uint32
+	HasRealloc bool
+	// PostReturn is the core function called after lifted results are read,
+	// if HasPostReturn.
+	PostReturn    uint32
+	HasPostReturn bool
+}
+
+// Start calls a function when the component is instantiated.
+type Start struct {
+	Func    uint32
+	Args    []uint32
+	Results uint32
+}
+
+// Import is an import of a component, which adds a definition to the index
+// space of the kind of Desc.
+type Import struct {
+	Name string
+	Desc ExternDesc
+}
+
+// Export is an export of an instance or a component, of the definition Index
+// in the index space of Sort. Exports of a component also add a definition.
+type Export struct {
+	Name  string
+	Sort  Sort
+	Index uint32
+	// Desc is the optional type ascribed to the export of a component.
+	Desc *ExternDesc
+}
+
+func (*CoreModule) definition()   {}
+func (*CoreInstance) definition() {}
+func (*CoreType) definition()     {}
+func (*Nested) definition()       {}
+func (*Instance) definition()     {}
+func (*Alias) definition()        {}
+func (*Type) definition()         {}
+func (*Canon) definition()        {}
+func (*Start) definition()        {}
+func (*Import) definition()       {}
+func (*Export) definition()       {}
+
+// ExternDesc is the type of an import or export. Sort is one of
+// SortCoreModule, SortFunc, SortValue, SortType, SortComponent or
+// SortInstance.
+type ExternDesc struct {
+	Sort Sort
+	// Index is the type of the definition, or for SortType unless
+	// SubResource is set, the type it is equal to.
+	Index uint32
+	// SubResource is true for SortType bound to a fresh resource type.
+	SubResource bool
+}
+
+// ValTypeKind is the kind of value type.
+type ValTypeKind byte
+
+const (
+	ValTypeKindBool ValTypeKind = iota + 1
+	ValTypeKindS8
+	ValTypeKindU8
+	ValTypeKindS16
+	ValTypeKindU16
+	ValTypeKindS32
+	ValTypeKindU32
+	ValTypeKindS64
+	ValTypeKindU64
+	ValTypeKindF32
+	ValTypeKindF64
+	ValTypeKindChar
+	ValTypeKindString
+	ValTypeKindRecord
+	ValTypeKindVariant
+	ValTypeKindList
+	ValTypeKindTuple
+	ValTypeKindFlags
+	ValTypeKindEnum
+	ValTypeKindOption
+	ValTypeKindResult
+	ValTypeKindOwn
+	ValTypeKindBorrow
+)
+
+// IsPrimitive returns true if the kind has no type parameters.
+func (k ValTypeKind) IsPrimitive() bool {
+	return k >= ValTypeKindBool && k <= ValTypeKindString
+}
+
+// String implements fmt.Stringer.
+func (k ValTypeKind) String() string {
+	switch k {
+	case ValTypeKindBool:
+		return "bool"
+	case ValTypeKindS8:
+		return "s8"
+	case ValTypeKindU8:
+		return "u8"
+	case ValTypeKindS16:
+		return "s16"
+	case ValTypeKindU16:
+		return "u16"
+	case ValTypeKindS32:
+		return "s32"
+	case ValTypeKindU32:
+		return "u32"
+	case ValTypeKindS64:
+		return "s64"
+	case ValTypeKindU64:
+		return "u64"
+	case ValTypeKindF32:
+		return "f32"
+	case ValTypeKindF64:
+		return "f64"
+	case ValTypeKindChar:
+		return "char"
+	case ValTypeKindString:
+		return "string"
+	case ValTypeKindRecord:
+		return "record"
+	case ValTypeKindVariant:
+		return "variant"
+	case ValTypeKindList:
+		return "list"
+	case ValTypeKindTuple:
+		return "tuple"
+	case ValTypeKindFlags:
+		return "flags"
+	case ValTypeKindEnum:
+		return "enum"
+	case ValTypeKindOption:
+		return "option"
+	case ValTypeKindResult:
+		return "result"
+	case ValTypeKindOwn:
+		return "own"
+	case ValTypeKindBorrow:
+		return "borrow"
+	}
+	return "unknown"
+}
+
+// ValTypeRef refers to a value type, either primitive or by its index.
+type ValTypeRef struct {
+	// Primitive is the kind of a primitive type, or zero for Index.
+	Primitive ValTypeKind
+	Index     uint32
+}
+
+// LabeledValType is a field of a record, or a named parameter or result.
+type LabeledValType struct {
+	Name string
+	Type ValTypeRef
+}
+
+// CaseDef is a case of a variant, which has a payload if Type is set.
+type CaseDef struct {
+	Name string
+	Type *ValTypeRef
+}
+
+// ValTypeDef defines a value type. The fields used depend on the Kind.
+type ValTypeDef struct {
+	Kind ValTypeKind
+	// Fields are the fields of a record.
+	Fields []LabeledValType
+	// Cases are the cases of a variant.
+	Cases []CaseDef
+	// Types are the types of a tuple.
+	Types []ValTypeRef
+	// Labels are the labels of flags or an enum.
+	Labels []string
+	// Elem is the type of the elements of a list, or the payload of an
+	// option.
+	Elem ValTypeRef
+	// Ok and Err are the optional payloads of a result.
+	Ok, Err *ValTypeRef
+	// Resource is the resource type of own and borrow.
+	Resource uint32
+}
+
+// FuncTypeDef defines a function type.
+type FuncTypeDef struct {
+	Params []LabeledValType
+	// Results has one element without a name for a single result.
+	Results []LabeledValType
+}
+
+// DeclKind is the kind of a Decl.
+type DeclKind byte
+
+const (
+	DeclKindCoreType DeclKind = 0x00
+	DeclKindType     DeclKind = 0x01
+	DeclKindAlias    DeclKind = 0x02
+	DeclKindImport   DeclKind = 0x03
+	DeclKindExport   DeclKind = 0x04
+)
+
+// Decl is a declaration in a component or instance type.
+type Decl struct {
+	Kind DeclKind
+	// CoreType is set for DeclKindCoreType.
+	CoreType *CoreType
+	// Type is set for DeclKindType.
+	Type TypeDef
+	// Alias is set for DeclKindAlias.
+	Alias *Alias
+	// Name and Desc are set for DeclKindImport and DeclKindExport.
+	Name string
+	Desc ExternDesc
+}
+
+// ComponentTypeDef defines the imports and exports of a component.
+type ComponentTypeDef struct {
+	Decls []Decl
+}
+
+// InstanceTypeDef defines the exports of an instance.
+type InstanceTypeDef struct {
+	Decls []Decl
+}
+
+// ResourceTypeDef defines a resource type with the representation i32, and
+// an optional destructor.
+type ResourceTypeDef struct {
+	// Dtor is the core function called when an owned handle is dropped, if
+	// HasDtor.
+	Dtor    uint32
+	HasDtor bool
+}
+
+func (*ValTypeDef) typeDef()       {}
+func (*FuncTypeDef) typeDef()      {}
+func (*ComponentTypeDef) typeDef() {}
+func (*InstanceTypeDef) typeDef()  {}
+func (*ResourceTypeDef) typeDef()  {}
@@ -0,0 +1,906 @@
+package component
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/tetratelabs/wazero/internal/leb128"
+)
+
+// Magic is the magic number shared by core modules and components.
+var Magic = []byte{0x00, 0x61, 0x73, 0x6D}
+
+// preamble is the version and layer which distinguish a component from a
+// core module.
+var preamble = []byte{0x0d, 0x00, 0x01, 0x00}
+
+var (
+	ErrInvalidMagicNumber = errors.New("invalid magic number")
+	ErrInvalidVersion     = errors.New("invalid component version header")
+)
+
+// SectionID identifies the sections of a component.
+type SectionID = byte
+
+const (
+	SectionIDCustom SectionID = iota
+	SectionIDCoreModule
+	SectionIDCoreInstance
+	SectionIDCoreType
+	SectionIDComponent
+	SectionIDInstance
+	SectionIDAlias
+	SectionIDType
+	SectionIDCanon
+	SectionIDStart
+	SectionIDImport
+	SectionIDExport
+	SectionIDValue
+)
+
+// SectionIDName returns the canonical name of a component section.
+func SectionIDName(sectionID SectionID) string {
+	switch sectionID {
+	case SectionIDCustom:
+		return "custom"
+	case SectionIDCoreModule:
+		return "core module"
+	case SectionIDCoreInstance:
+		return "core instance"
+	case SectionIDCoreType:
+		return "core type"
+	case SectionIDComponent:
+		return "component"
+	case SectionIDInstance:
+		return "instance"
+	case SectionIDAlias:
+		return "alias"
+	case SectionIDType:
+		return "type"
+	case SectionIDCanon:
+		return "canon"
+	case SectionIDStart:
+		return "start"
+	case SectionIDImport:
+		return "import"
+	case SectionIDExport:
+		return "export"
+	case SectionIDValue:
+		return "value"
+	}
+	return "unknown"
+}
+
+// IsComponent returns true if the binary begins with the preamble of a
+// component, as opposed to the one of a core module.
+func IsComponent(binary []byte) bool {
+	return len(binary) >= 8 && bytes.Equal(binary[:4], Magic) && bytes.Equal(binary[4:8], preamble)
+}
+
+// Decode decodes a component in the binary format.
+//
+// See https://github.com/WebAssembly/component-model/blob/main/design/mvp/Binary.md
+func Decode(binary []byte) (*Component, error) {
+	r := bytes.NewReader(binary)
+
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err != nil || !bytes.Equal(buf, Magic) {
+		return nil, ErrInvalidMagicNumber
+	}
+	if _, err := io.ReadFull(r, buf); err != nil || !bytes.Equal(buf, preamble) {
+		return nil, ErrInvalidVersion
+	}
+
+	c := &Component{}
+	for {
+		sectionID, err := r.ReadByte()
+		if err == io.EOF {
+			return c, nil
+		}
+
+		sectionSize, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return nil, fmt.Errorf("get size of section %s: %v", SectionIDName(sectionID), err)
+		}
+		section := make([]byte, sectionSize)
+		if _, err = io.ReadFull(r, section); err != nil {
+			return nil, fmt.Errorf("section %s: %v", SectionIDName(sectionID), io.ErrUnexpectedEOF)
+		}
+
+		if err = c.decodeSection(sectionID, section); err != nil {
+			return nil, fmt.Errorf("section %s: %w", SectionIDName(sectionID), err)
+		}
+	}
+}
+
+func (c *Component) decodeSection(sectionID SectionID, section []byte) (err error) {
+	r := bytes.NewReader(section)
+	switch sectionID {
+	case SectionIDCustom:
+		return nil // Custom sections, such as names, are ignored.
+	case SectionIDCoreModule:
+		c.Definitions = append(c.Definitions, &CoreModule{Binary: section})
+		return nil
+	case SectionIDComponent:
+		var nested *Component
+		if nested, err = Decode(section); err != nil {
+			return err
+		}
+		c.Definitions = append(c.Definitions, &Nested{Component: nested})
+		return nil
+	case SectionIDStart:
+		var start *Start
+		if start, err = decodeStart(r); err != nil {
+			return err
+		}
+		c.Definitions = append(c.Definitions, start)
+	case SectionIDValue:
+		return errors.New("values are not supported")
+	default:
+		decodeItem, ok := sectionItemDecoders[sectionID]
+		if !ok {
+			return fmt.Errorf("%w: %#x", errInvalidSectionID, sectionID)
+		}
+		var count uint32
+		if count, _, err = leb128.DecodeUint32(r); err != nil {
+			return fmt.Errorf("get size of vector: %w", err)
+		}
+		for i := uint32(0); i < count; i++ {
+			var def Definition
+			if def, err = decodeItem(r); err != nil {
+				return fmt.Errorf("read %d: %w", i, err)
+			}
+			c.Definitions = append(c.Definitions, def)
+		}
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%d bytes remaining", r.Len())
+	}
+	return nil
+}
+
+var errInvalidSectionID = errors.New("invalid section id")
+
+// sectionItemDecoders decode the items of the sections which are vectors.
+var sectionItemDecoders = map[SectionID]func(r *bytes.Reader) (Definition, error){
+	SectionIDCoreInstance: func(r *bytes.Reader) (Definition, error) { return decodeCoreInstance(r) },
+	SectionIDCoreType:     func(r *bytes.Reader) (Definition, error) { return decodeCoreType(r) },
+	SectionIDInstance:     func(r *bytes.Reader) (Definition, error) { return decodeInstance(r) },
+	SectionIDAlias:        func(r *bytes.Reader) (Definition, error) { return decodeAlias(r) },
+	SectionIDType: func(r *bytes.Reader) (Definition, error) {
+		def, err := decodeTypeDef(r)
+		if err != nil {
+			return nil, err
+		}
+		return &Type{Def: def}, nil
+	},
+	SectionIDCanon:  func(r *bytes.Reader) (Definition, error) { return decodeCanon(r) },
+	SectionIDImport: func(r *bytes.Reader) (Definition, error) { return decodeImport(r) },
+	SectionIDExport: func(r *bytes.Reader) (Definition, error) { return decodeExport(r) },
+}
+
+func decodeCoreInstance(r *bytes.Reader) (*CoreInstance, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch b {
+	case 0x00:
+		ret := &CoreInstance{}
+		if ret.Module, _, err = leb128.DecodeUint32(r); err != nil {
+			return nil, fmt.Errorf("read module index: %w", err)
+		}
+		err = decodeVec(r, func(r *bytes.Reader) error {
+			var arg CoreInstantiateArg
+			if arg.Name, err = decodeName(r); err != nil {
+				return err
+			}
+			if b, err = r.ReadByte(); err != nil {
+				return err
+			} else if b != byte(SortCoreInstance) {
+				return fmt.Errorf("invalid instantiate arg sort: %#x", b)
+			}
+			if arg.Instance, _, err = leb128.DecodeUint32(r); err != nil {
+				return err
+			}
+			ret.Args = append(ret.Args, arg)
+			return nil
+		})
+		return ret, err
+	case 0x01:
+		ret := &CoreInstance{IsExports: true}
+		err = decodeVec(r, func(r *bytes.Reader) error {
+			var exp Export
+			if exp.Name, err = decodeName(r); err != nil {
+				return err
+			}
+			if b, err = r.ReadByte(); err != nil {
+				return err
+			}
+			if exp.Sort, err = decodeCoreSort(b); err != nil {
+				return err
+			}
+			if exp.Index, _, err = leb128.DecodeUint32(r); err != nil {
+				return err
+			}
+			ret.Exports = append(ret.Exports, exp)
+			return nil
+		})
+		return ret, err
+	}
+	return nil, fmt.Errorf("invalid core instance: %#x", b)
+}
+
+func decodeInstance(r *bytes.Reader) (*Instance, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch b {
+	case 0x00:
+		ret := &Instance{}
+		if ret.Component, _, err = leb128.DecodeUint32(r); err != nil {
+			return nil, fmt.Errorf("read component index: %w", err)
+		}
+		err = decodeVec(r, func(r *bytes.Reader) error {
+			var arg Export
+			if arg.Name, err = decodeName(r); err != nil {
+				return err
+			}
+			if arg.Sort, arg.Index, err = decodeSortIndex(r); err != nil {
+				return err
+			}
+			ret.Args = append(ret.Args, arg)
+			return nil
+		})
+		return ret, err
+	case 0x01:
+		ret := &Instance{IsExports: true}
+		err = decodeVec(r, func(r *bytes.Reader) error {
+			var exp Export
+			if exp.Name, err = decodeExternName(r); err != nil {
+				return err
+			}
+			if exp.Sort, exp.Index, err = decodeSortIndex(r); err != nil {
+				return err
+			}
+			ret.Exports = append(ret.Exports, exp)
+			return nil
+		})
+		return ret, err
+	}
+	return nil, fmt.Errorf("invalid instance: %#x", b)
+}
+
+func decodeAlias(r *bytes.Reader) (ret *Alias, err error) {
+	ret = &Alias{}
+	if ret.Sort, err = decodeSort(r); err != nil {
+		return nil, err
+	}
+	var b byte
+	if b, err = r.ReadByte(); err != nil {
+		return nil, err
+	}
+	ret.Kind = AliasKind(b)
+	switch ret.Kind {
+	case AliasKindExport, AliasKindCoreExport:
+		if ret.Instance, _, err = leb128.DecodeUint32(r); err != nil {
+			return nil, fmt.Errorf("read instance index: %w", err)
+		}
+		if ret.Name, err = decodeName(r); err != nil {
+			return nil, err
+		}
+		if (ret.Kind == AliasKindCoreExport) != ret.Sort.IsCore() {
+			return nil, fmt.Errorf("invalid sort for alias: %s", ret.Sort)
+		}
+	case AliasKindOuter:
+		if ret.Count, _, err = leb128.DecodeUint32(r); err != nil {
+			return nil, fmt.Errorf("read outer count: %w", err)
+		}
+		if ret.Index, _, err = leb128.DecodeUint32(r); err != nil {
+			return nil, fmt.Errorf("read outer index: %w", err)
+		}
+		switch ret.Sort {
+		case SortCoreModule, SortCoreType, SortType, SortComponent:
+		default:
+			return nil, fmt.Errorf("invalid sort for outer alias: %s", ret.Sort)
+		}
+	default:
+		return nil, fmt.Errorf("invalid alias target: %#x", b)
+	}
+	return ret, nil
+}
+
+func decodeCanon(r *bytes.Reader) (ret *Canon, err error) {
+	var b byte
+	if b, err = r.ReadByte(); err != nil {
+		return nil, err
+	}
+	ret = &Canon{Kind: CanonKind(b)}
+	switch ret.Kind {
+	case CanonLift, CanonLower:
+		if b, err = r.ReadByte(); err != nil {
+			return nil, err
+		} else if b != 0x00 {
+			return nil, fmt.Errorf("invalid canon %#x: %#x", ret.Kind, b)
+		}
+		if ret.Func, _, err = leb128.DecodeUint32(r); err != nil {
+			return nil, fmt.Errorf("read function index: %w", err)
+		}
+		if ret.Options, err = decodeCanonOptions(r); err != nil {
+			return nil, err
+		}
+		if ret.Kind == CanonLift {
+			if ret.Type, _, err = leb128.DecodeUint32(r); err != nil {
+				return nil, fmt.Errorf("read type index: %w", err)
+			}
+		}
+	case CanonResourceNew, CanonResourceDrop, CanonResourceRep:
+		if ret.Type, _, err = leb128.DecodeUint32(r); err != nil {
+			return nil, fmt.Errorf("read type index: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported canon: %#x", b)
+	}
+	return ret, nil
+}
+
+func decodeCanonOptions(r *bytes.Reader) (ret CanonOptions, err error) {
+	err = decodeVec(r, func(r *bytes.Reader) error {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch b {
+		case 0x00:
+			ret.StringEncoding = StringEncodingUTF8
+		case 0x01:
+			ret.StringEncoding = StringEncodingUTF16
+		case 0x02:
+			ret.StringEncoding = StringEncodingLatin1UTF16
+		case 0x03:
+			ret.Memory, _, err = leb128.DecodeUint32(r)
+			ret.HasMemory = true
+		case 0x04:
+			ret.Realloc, _, err = leb128.DecodeUint32(r)
+			ret.HasRealloc = true
+		case 0x05:
+			ret.PostReturn, _, err = leb128.DecodeUint32(r)
+			ret.HasPostReturn = true
+		default:
+			return fmt.Errorf("unsupported canon option: %#x", b)
+		}
+		return err
+	})
+	return
+}
+
+func decodeStart(r *bytes.Reader) (ret *Start, err error) {
+	ret = &Start{}
+	if ret.Func, _, err = leb128.DecodeUint32(r); err != nil {
+		return nil, fmt.Errorf("read function index: %w", err)
+	}
+	err = decodeVec(r, func(r *bytes.Reader) error {
+		arg, _, err := leb128.DecodeUint32(r)
+		ret.Args = append(ret.Args, arg)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ret.Results, _, err = leb128.DecodeUint32(r); err != nil {
+		return nil, fmt.Errorf("read result count: %w", err)
+	}
+	return ret, nil
+}
+
+func decodeImport(r *bytes.Reader) (ret *Import, err error) {
+	ret = &Import{}
+	if ret.Name, err = decodeExternName(r); err != nil {
+		return nil, err
+	}
+	if ret.Desc, err = decodeExternDesc(r); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func decodeExport(r *bytes.Reader) (ret *Export, err error) {
+	ret = &Export{}
+	if ret.Name, err = decodeExternName(r); err != nil {
+		return nil, err
+	}
+	if ret.Sort, ret.Index, err = decodeSortIndex(r); err != nil {
+		return nil, err
+	}
+	var b byte
+	if b, err = r.ReadByte(); err != nil {
+		return nil, err
+	} else if b == 0x01 {
+		var desc ExternDesc
+		if desc, err = decodeExternDesc(r); err != nil {
+			return nil, err
+		}
+		ret.Desc = &desc
+	} else if b != 0x00 {
+		return nil, fmt.Errorf("invalid export type: %#x", b)
+	}
+	return ret, nil
+}
+
+func decodeExternDesc(r *bytes.Reader) (ret ExternDesc, err error) {
+	var b byte
+	if b, err = r.ReadByte(); err != nil {
+		return
+	}
+	switch b {
+	case 0x00:
+		if b, err = r.ReadByte(); err != nil {
+			return
+		} else if b != byte(SortCoreModule) {
+			err = fmt.Errorf("invalid core extern: %#x", b)
+			return
+		}
+		ret.Sort = SortCoreModule
+	case 0x01, 0x04, 0x05:
+		ret.Sort = SortComponentBase | Sort(b)
+	case 0x02:
+		err = errors.New("values are not supported")
+		return
+	case 0x03:
+		ret.Sort = SortType
+		if b, err = r.ReadByte(); err != nil {
+			return
+		}
+		switch b {
+		case 0x00:
+		case 0x01:
+			ret.SubResource = true
+			return
+		default:
+			err = fmt.Errorf("invalid type bound: %#x", b)
+			return
+		}
+	default:
+		err = fmt.Errorf("invalid extern: %#x", b)
+		return
+	}
+	ret.Index, _, err = leb128.DecodeUint32(r)
+	return
+}
+
+func decodeTypeDef(r *bytes.Reader) (TypeDef, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if kind, ok := primitiveValTypes[b]; ok {
+		return &ValTypeDef{Kind: kind}, nil
+	}
+	switch b {
+	case 0x72:
+		ret := &ValTypeDef{Kind: ValTypeKindRecord}
+		ret.Fields, err = decodeLabeledValTypes(r)
+		return ret, err
+	case 0x71:
+		ret := &ValTypeDef{Kind: ValTypeKindVariant}
+		err = decodeVec(r, func(r *bytes.Reader) error {
+			var c CaseDef
+			if c.Name, err = decodeName(r); err != nil {
+				return err
+			}
+			if c.Type, err = decodeOptionalValType(r); err != nil {
+				return err
+			}
+			if b, err = r.ReadByte(); err != nil {
+				return err
+			} else if b != 0x00 {
+				return errors.New("case refinement is not supported")
+			}
+			ret.Cases = append(ret.Cases, c)
+			return nil
+		})
+		return ret, err
+	case 0x70:
+		ret := &ValTypeDef{Kind: ValTypeKindList}
+		ret.Elem, err = decodeValType(r)
+		return ret, err
+	case 0x6f:
+		ret := &ValTypeDef{Kind: ValTypeKindTuple}
+		err = decodeVec(r, func(r *bytes.Reader) error {
+			t, err := decodeValType(r)
+			ret.Types = append(ret.Types, t)
+			return err
+		})
+		return ret, err
+	case 0x6e, 0x6d:
+		ret := &ValTypeDef{Kind: ValTypeKindFlags}
+		if b == 0x6d {
+			ret.Kind = ValTypeKindEnum
+		}
+		err = decodeVec(r, func(r *bytes.Reader) error {
+			l, err := decodeName(r)
+			ret.Labels = append(ret.Labels, l)
+			return err
+		})
+		return ret, err
+	case 0x6b:
+		ret := &ValTypeDef{Kind: ValTypeKindOption}
+		ret.Elem, err = decodeValType(r)
+		return ret, err
+	case 0x6a:
+		ret := &ValTypeDef{Kind: ValTypeKindResult}
+		if ret.Ok, err = decodeOptionalValType(r); err != nil {
+			return nil, err
+		}
+		ret.Err, err = decodeOptionalValType(r)
+		return ret, err
+	case 0x69, 0x68:
+		ret := &ValTypeDef{Kind: ValTypeKindOwn}
+		if b == 0x68 {
+			ret.Kind = ValTypeKindBorrow
+		}
+		ret.Resource, _, err = leb128.DecodeUint32(r)
+		return ret, err
+	case 0x40:
+		ret := &FuncTypeDef{}
+		if ret.Params, err = decodeLabeledValTypes(r); err != nil {
+			return nil, err
+		}
+		if b, err = r.ReadByte(); err != nil {
+			return nil, err
+		}
+		switch b {
+		case 0x00:
+			var t ValTypeRef
+			if t, err = decodeValType(r); err != nil {
+				return nil, err
+			}
+			ret.Results = []LabeledValType{{Type: t}}
+		case 0x01:
+			ret.Results, err = decodeLabeledValTypes(r)
+		default:
+			err = fmt.Errorf("invalid result list: %#x", b)
+		}
+		return ret, err
+	case 0x41:
+		ret := &ComponentTypeDef{}
+		ret.Decls, err = decodeDecls(r, true)
+		return ret, err
+	case 0x42:
+		ret := &InstanceTypeDef{}
+		ret.Decls, err = decodeDecls(r, false)
+		return ret, err
+	case 0x3f:
+		if b, err = r.ReadByte(); err != nil {
+			return nil, err
+		} else if b != 0x7f {
+			return nil, fmt.Errorf("invalid resource representation: %#x", b)
+		}
+		ret := &ResourceTypeDef{}
+		if b, err = r.ReadByte(); err != nil {
+			return nil, err
+		}
+		switch b {
+		case 0x00:
+		case 0x01:
+			ret.HasDtor = true
+			ret.Dtor, _, err = leb128.DecodeUint32(r)
+		default:
+			err = fmt.Errorf("invalid resource destructor: %#x", b)
+		}
+		return ret, err
+	}
+	return nil, fmt.Errorf("unsupported type: %#x", b)
+}
+
+// primitiveValTypes maps the encoding of primitive value types to their kind.
+var primitiveValTypes = map[byte]ValTypeKind{
+	0x7f: ValTypeKindBool,
+	0x7e: ValTypeKindS8,
+	0x7d: ValTypeKindU8,
+	0x7c: ValTypeKindS16,
+	0x7b: ValTypeKindU16,
+	0x7a: ValTypeKindS32,
+	0x79: ValTypeKindU32,
+	0x78: ValTypeKindS64,
+	0x77: ValTypeKindU64,
+	0x76: ValTypeKindF32,
+	0x75: ValTypeKindF64,
+	0x74: ValTypeKindChar,
+	0x73: ValTypeKindString,
+}
+
+func decodeDecls(r *bytes.Reader, isComponent bool) (ret []Decl, err error) {
+	err = decodeVec(r, func(r *bytes.Reader) error {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		d := Decl{Kind: DeclKind(b)}
+		switch d.Kind {
+		case DeclKindCoreType:
+			d.CoreType, err = decodeCoreType(r)
+		case DeclKindType:
+			d.Type, err = decodeTypeDef(r)
+		case DeclKindAlias:
+			d.Alias, err = decodeAlias(r)
+		case DeclKindImport, DeclKindExport:
+			if d.Kind == DeclKindImport && !isComponent {
+				return errors.New("import in instance type")
+			}
+			if d.Name, err = decodeExternName(r); err != nil {
+				return err
+			}
+			d.Desc, err = decodeExternDesc(r)
+		default:
+			return fmt.Errorf("invalid declaration: %#x", b)
+		}
+		ret = append(ret, d)
+		return err
+	})
+	return
+}
+
+func decodeLabeledValTypes(r *bytes.Reader) (ret []LabeledValType, err error) {
+	err = decodeVec(r, func(r *bytes.Reader) error {
+		var l LabeledValType
+		if l.Name, err = decodeName(r); err != nil {
+			return err
+		}
+		if l.Type, err = decodeValType(r); err != nil {
+			return err
+		}
+		ret = append(ret, l)
+		return nil
+	})
+	return
+}
+
+func decodeValType(r *bytes.Reader) (ValTypeRef, error) {
+	v, _, err := leb128.DecodeInt33AsInt64(r)
+	if err != nil {
+		return ValTypeRef{}, err
+	}
+	if v >= 0 {
+		return ValTypeRef{Index: uint32(v)}, nil
+	}
+	if kind, ok := primitiveValTypes[byte(v&0x7f)]; ok && v >= -0x40 {
+		return ValTypeRef{Primitive: kind}, nil
+	}
+	return ValTypeRef{}, fmt.Errorf("invalid value type: %d", v)
+}
+
+func decodeOptionalValType(r *bytes.Reader) (*ValTypeRef, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch b {
+	case 0x00:
+		return nil, nil
+	case 0x01:
+		t, err := decodeValType(r)
+		return &t, err
+	}
+	return nil, fmt.Errorf("invalid optional value type: %#x", b)
+}
+
+func decodeCoreType(r *bytes.Reader) (*CoreType, error) {
+	start := r.Size() - int64(r.Len())
+	if err := skipCoreType(r); err != nil {
+		return nil, err
+	}
+	ret := &CoreType{Binary: make([]byte, r.Size()-int64(r.Len())-start)}
+	_, _ = r.ReadAt(ret.Binary, start)
+	return ret, nil
+}
+
+// skipCoreType skips a core function or module type.
+func skipCoreType(r *bytes.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch b {
+	case 0x60:
+		for i := 0; i < 2; i++ { // params and results
+			if err = decodeVec(r, skipCoreValType); err != nil {
+				return err
+			}
+		}
+		return nil
+	case 0x50:
+		return decodeVec(r, func(r *bytes.Reader) error {
+			b, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			switch b {
+			case 0x00: // import
+				for i := 0; i < 2; i++ { // module and name
+					if _, err = decodeName(r); err != nil {
+						return err
+					}
+				}
+				return skipCoreImportDesc(r)
+			case 0x01:
+				return skipCoreType(r)
+			case 0x02: // outer alias
+				if b, err = r.ReadByte(); err != nil {
+					return err
+				} else if b != byte(SortCoreType) {
+					return fmt.Errorf("invalid sort for core alias: %#x", b)
+				}
+				if b, err = r.ReadByte(); err != nil {
+					return err
+				} else if b != byte(AliasKindOuter) {
+					return fmt.Errorf("invalid core alias target: %#x", b)
+				}
+				return skipUint32s(r, 2)
+			case 0x03: // export
+				if _, err = decodeName(r); err != nil {
+					return err
+				}
+				return skipCoreImportDesc(r)
+			}
+			return fmt.Errorf("invalid module declaration: %#x", b)
+		})
+	}
+	return fmt.Errorf("unsupported core type: %#x", b)
+}
+
+func skipCoreImportDesc(r *bytes.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch b {
+	case 0x00: // func
+		return skipUint32s(r, 1)
+	case 0x01: // table
+		if err = skipCoreValType(r); err != nil {
+			return err
+		}
+		return skipLimits(r)
+	case 0x02: // memory
+		return skipLimits(r)
+	case 0x03: // global
+		if err = skipCoreValType(r); err != nil {
+			return err
+		}
+		_, err = r.ReadByte() // mutability
+		return err
+	case 0x04: // tag
+		if _, err = r.ReadByte(); err != nil { // attribute
+			return err
+		}
+		return skipUint32s(r, 1)
+	}
+	return fmt.Errorf("invalid core import: %#x", b)
+}
+
+func skipCoreValType(r *bytes.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch b {
+	case 0x7f, 0x7e, 0x7d, 0x7c, 0x7b, 0x70, 0x6f:
+		return nil
+	case 0x63, 0x64: // (ref null? ht)
+		_, _, err = leb128.DecodeInt33AsInt64(r)
+		return err
+	}
+	return fmt.Errorf("invalid core value type: %#x", b)
+}
+
+func skipLimits(r *bytes.Reader) error {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if _, _, err = leb128.DecodeUint64(r); err != nil {
+		return err
+	}
+	if flags&0x01 != 0 {
+		_, _, err = leb128.DecodeUint64(r)
+	}
+	return err
+}
+
+func skipUint32s(r *bytes.Reader, n int) error {
+	for i := 0; i < n; i++ {
+		if _, _, err := leb128.DecodeUint32(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeSortIndex(r *bytes.Reader) (s Sort, index uint32, err error) {
+	if s, err = decodeSort(r); err != nil {
+		return
+	}
+	index, _, err = leb128.DecodeUint32(r)
+	return
+}
+
+func decodeSort(r *bytes.Reader) (Sort, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch b {
+	case 0x00:
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		return decodeCoreSort(b)
+	case 0x01, 0x02, 0x03, 0x04, 0x05:
+		return SortComponentBase | Sort(b), nil
+	}
+	return 0, fmt.Errorf("invalid sort: %#x", b)
+}
+
+func decodeCoreSort(b byte) (Sort, error) {
+	switch s := Sort(b); s {
+	case SortCoreFunc, SortCoreTable, SortCoreMemory, SortCoreGlobal, SortCoreTag,
+		SortCoreType, SortCoreModule, SortCoreInstance:
+		return s, nil
+	}
+	return 0, fmt.Errorf("invalid core sort: %#x", b)
+}
+
+// decodeExternName decodes the name of an import or export, ignoring any
+// version suffix.
+func decodeExternName(r *bytes.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	switch b {
+	case 0x00:
+		return decodeName(r)
+	case 0x01:
+		name, err := decodeName(r)
+		if err != nil {
+			return "", err
+		}
+		_, err = decodeName(r)
+		return name, err
+	}
+	return "", fmt.Errorf("invalid extern name: %#x", b)
+}
+
+func decodeName(r *bytes.Reader) (string, error) {
+	size, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return "", fmt.Errorf("read name size: %w", err)
+	}
+	if int(size) > r.Len() {
+		return "", fmt.Errorf("read name: %w", io.ErrUnexpectedEOF)
+	}
+	buf := make([]byte, size)
+	_, _ = io.ReadFull(r, buf)
+	if !utf8.Valid(buf) {
+		return "", errors.New("name is not valid UTF-8")
+	}
+	return string(buf), nil
+}
+
+func decodeVec(r *bytes.Reader, decodeElem func(r *bytes.Reader) error) error {
+	count, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return fmt.Errorf("get size of vector: %w", err)
+	}
+	for i := uint32(0); i < count; i++ {
+		if err = decodeElem(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
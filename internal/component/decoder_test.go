@@ -0,0 +1,192 @@
+package component_test
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/component"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/componentencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// TestDecode relies on componentencoding.Encode, so that each input is
+// tested as a round trip.
+func TestDecode(t *testing.T) {
+	u32 := component.ValTypeRef{Primitive: component.ValTypeKindU32}
+	str := component.ValTypeRef{Primitive: component.ValTypeKindString}
+
+	tests := []struct {
+		name  string
+		input *component.Component
+	}{
+		{
+			name:  "empty",
+			input: &component.Component{},
+		},
+		{
+			name: "core module and instances",
+			input: &component.Component{Definitions: []component.Definition{
+				&component.CoreModule{Binary: binaryencoding.EncodeModule(&wasm.Module{})},
+				&component.CoreInstance{Module: 0},
+				&component.CoreInstance{IsExports: true, Exports: []component.Export{
+					{Name: "memory", Sort: component.SortCoreMemory, Index: 0},
+					{Name: "f", Sort: component.SortCoreFunc, Index: 1},
+				}},
+				&component.CoreInstance{Module: 0, Args: []component.CoreInstantiateArg{{Name: "env", Instance: 1}}},
+			}},
+		},
+		{
+			name: "core type",
+			input: &component.Component{Definitions: []component.Definition{
+				&component.CoreType{Binary: []byte{0x60, 1, 0x7f, 0}},
+				&component.CoreType{Binary: []byte{0x50, 2, 0x01, 0x60, 0, 0, 0x03, 1, 'f', 0x00, 0}},
+			}},
+		},
+		{
+			name: "nested component",
+			input: &component.Component{Definitions: []component.Definition{
+				&component.Nested{Component: &component.Component{}},
+				&component.Instance{Component: 0, Args: []component.Export{{Name: "a", Sort: component.SortFunc, Index: 0}}},
+			}},
+		},
+		{
+			name: "value types",
+			input: &component.Component{Definitions: []component.Definition{
+				&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindU8}},
+				&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindRecord, Fields: []component.LabeledValType{
+					{Name: "a", Type: u32}, {Name: "b", Type: component.ValTypeRef{Index: 0}},
+				}}},
+				&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindVariant, Cases: []component.CaseDef{
+					{Name: "a"}, {Name: "b", Type: &str},
+				}}},
+				&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindList, Elem: component.ValTypeRef{Index: 1}}},
+				&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindTuple, Types: []component.ValTypeRef{u32, str}}},
+				&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindFlags, Labels: []string{"r", "w"}}},
+				&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindEnum, Labels: []string{"x", "y", "z"}}},
+				&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindOption, Elem: str}},
+				&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindResult, Ok: &u32}},
+				&component.Type{Def: &component.ResourceTypeDef{HasDtor: true, Dtor: 3}},
+				&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindOwn, Resource: 9}},
+				&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindBorrow, Resource: 9}},
+			}},
+		},
+		{
+			name: "function types",
+			input: &component.Component{Definitions: []component.Definition{
+				&component.Type{Def: &component.FuncTypeDef{}},
+				&component.Type{Def: &component.FuncTypeDef{
+					Params:  []component.LabeledValType{{Name: "a", Type: u32}},
+					Results: []component.LabeledValType{{Type: str}},
+				}},
+				&component.Type{Def: &component.FuncTypeDef{
+					Results: []component.LabeledValType{{Name: "a", Type: u32}, {Name: "b", Type: str}},
+				}},
+			}},
+		},
+		{
+			name: "instance import",
+			input: &component.Component{Definitions: []component.Definition{
+				&component.Type{Def: &component.InstanceTypeDef{Decls: []component.Decl{
+					{Kind: component.DeclKindExport, Name: "r", Desc: component.ExternDesc{Sort: component.SortType, SubResource: true}},
+					{Kind: component.DeclKindType, Type: &component.ValTypeDef{Kind: component.ValTypeKindOwn}},
+					{Kind: component.DeclKindType, Type: &component.FuncTypeDef{Results: []component.LabeledValType{{Type: component.ValTypeRef{Index: 1}}}}},
+					{Kind: component.DeclKindExport, Name: "[constructor]r", Desc: component.ExternDesc{Sort: component.SortFunc, Index: 2}},
+					{Kind: component.DeclKindAlias, Alias: &component.Alias{Sort: component.SortType, Kind: component.AliasKindOuter, Count: 1, Index: 0}},
+				}}},
+				&component.Import{Name: "wasi:cli/stdout@0.2.0", Desc: component.ExternDesc{Sort: component.SortInstance, Index: 0}},
+				&component.Alias{Sort: component.SortType, Kind: component.AliasKindExport, Instance: 0, Name: "r"},
+			}},
+		},
+		{
+			name: "canon and exports",
+			input: &component.Component{Definitions: []component.Definition{
+				&component.Alias{Sort: component.SortCoreFunc, Kind: component.AliasKindCoreExport, Instance: 0, Name: "f"},
+				&component.Canon{Kind: component.CanonLift, Func: 0, Type: 1, Options: component.CanonOptions{
+					StringEncoding: component.StringEncodingUTF16,
+					Memory:         1, HasMemory: true,
+					Realloc: 2, HasRealloc: true,
+					PostReturn: 3, HasPostReturn: true,
+				}},
+				&component.Canon{Kind: component.CanonLower, Func: 0},
+				&component.Canon{Kind: component.CanonResourceNew, Type: 2},
+				&component.Canon{Kind: component.CanonResourceDrop, Type: 2},
+				&component.Canon{Kind: component.CanonResourceRep, Type: 2},
+				&component.Instance{IsExports: true, Exports: []component.Export{{Name: "run", Sort: component.SortFunc, Index: 0}}},
+				&component.Export{Name: "run", Sort: component.SortFunc, Index: 0},
+				&component.Export{Name: "r", Sort: component.SortType, Index: 2,
+					Desc: &component.ExternDesc{Sort: component.SortType, Index: 2}},
+			}},
+		},
+		{
+			name: "start",
+			input: &component.Component{Definitions: []component.Definition{
+				&component.Start{Func: 1, Args: []uint32{0}, Results: 1},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			binary := componentencoding.Encode(tc.input)
+			require.True(t, component.IsComponent(binary))
+			c, err := component.Decode(binary)
+			require.NoError(t, err)
+			require.Equal(t, tc.input, c)
+		})
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	header := []byte{0x00, 0x61, 0x73, 0x6d, 0x0d, 0x00, 0x01, 0x00}
+	tests := []struct {
+		name        string
+		input       []byte
+		expectedErr string
+	}{
+		{
+			name:        "wrong magic",
+			input:       []byte("wasm\x0d\x00\x01\x00"),
+			expectedErr: "invalid magic number",
+		},
+		{
+			name:        "core module",
+			input:       binaryencoding.EncodeModule(&wasm.Module{}),
+			expectedErr: "invalid component version header",
+		},
+		{
+			name:        "invalid section",
+			input:       append(header, 0x0d, 0),
+			expectedErr: "section unknown: invalid section id: 0xd",
+		},
+		{
+			name:        "value section",
+			input:       append(header, component.SectionIDValue, 1, 0),
+			expectedErr: "section value: values are not supported",
+		},
+		{
+			name:        "bytes remaining",
+			input:       append(header, component.SectionIDType, 3, 1, 0x7f, 0x7f),
+			expectedErr: "section type: 1 bytes remaining",
+		},
+		{
+			name:        "unsupported type",
+			input:       append(header, component.SectionIDType, 2, 1, 0x01),
+			expectedErr: "section type: read 0: unsupported type: 0x1",
+		},
+		{
+			name:        "truncated section",
+			input:       append(header, component.SectionIDType, 5, 1),
+			expectedErr: "section type: unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := component.Decode(tc.input)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package component
+
+import "sync"
+
+// handle is an entry of a handleTable.
+type handle struct {
+	rt  *ResourceType
+	rep any
+	own bool
+	// lendCount is the number of calls to which the resource of an owned
+	// handle is lent, during which it can't be dropped.
+	lendCount int
+	// cx is the call in which a borrowed handle was lent to the component.
+	cx *callContext
+}
+
+// handleTable is the table of resource handles of a component instance, which
+// are the indices of its entries. Index 0 is never used, and indices of
+// removed entries are reused.
+type handleTable struct {
+	mux     sync.Mutex
+	entries []*handle
+	free    []uint32
+}
+
+func (t *handleTable) add(h *handle) uint32 {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if n := len(t.free); n > 0 {
+		i := t.free[n-1]
+		t.free = t.free[:n-1]
+		t.entries[i] = h
+		return i
+	}
+	if len(t.entries) == 0 {
+		t.entries = append(t.entries, nil)
+	}
+	t.entries = append(t.entries, h)
+	return uint32(len(t.entries) - 1)
+}
+
+// get returns the handle at index i, or traps if it isn't one of rt.
+func (t *handleTable) get(i uint32, rt *ResourceType) *handle {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.getLocked(i, rt)
+}
+
+func (t *handleTable) getLocked(i uint32, rt *ResourceType) *handle {
+	if i >= uint32(len(t.entries)) || t.entries[i] == nil {
+		trap("invalid handle: %d", i)
+	}
+	h := t.entries[i]
+	if rt != nil && h.rt != rt {
+		trap("handle %d is not a %s", i, rt.Name)
+	}
+	return h
+}
+
+// remove removes and returns the handle at index i, or traps if it isn't one
+// of rt.
+func (t *handleTable) remove(i uint32, rt *ResourceType) *handle {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	h := t.getLocked(i, rt)
+	if h.own && h.lendCount > 0 {
+		trap("handle %d is borrowed", i)
+	}
+	t.entries[i] = nil
+	t.free = append(t.free, i)
+	return h
+}
+
+// removeAll removes and returns all handles.
+func (t *handleTable) removeAll() (ret []*handle) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for _, h := range t.entries {
+		if h != nil {
+			ret = append(ret, h)
+		}
+	}
+	t.entries, t.free = nil, nil
+	return
+}
+
+// liftOwn removes an owned handle from the table, to transfer ownership of
+// its resource, and returns its representation.
+func (cx *callContext) liftOwn(rt *ResourceType, i uint32) any {
+	h := cx.inst.handles.remove(i, rt)
+	if !h.own {
+		trap("handle %d is not owned", i)
+	}
+	return h.rep
+}
+
+// liftBorrow returns the representation of the resource of a handle, which
+// is lent until the call returns if the handle is owned.
+func (cx *callContext) liftBorrow(rt *ResourceType, i uint32) any {
+	t := &cx.inst.handles
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	h := t.getLocked(i, rt)
+	if h.own {
+		h.lendCount++
+		cx.lenders = append(cx.lenders, h)
+	}
+	return h.rep
+}
+
+// lowerOwn adds an owned handle of the resource with representation rep.
+func (cx *callContext) lowerOwn(rt *ResourceType, rep any) uint32 {
+	cx.checkRep(rt, rep)
+	return cx.inst.handles.add(&handle{rt: rt, rep: rep, own: true})
+}
+
+// lowerBorrow adds a borrowed handle of the resource with representation
+// rep, which must be dropped before the call returns. A component receives
+// the representation of its own resource types instead.
+func (cx *callContext) lowerBorrow(rt *ResourceType, rep any) uint32 {
+	cx.checkRep(rt, rep)
+	if rt.instance == cx.inst {
+		return rep.(uint32)
+	}
+	cx.borrows++
+	return cx.inst.handles.add(&handle{rt: rt, rep: rep, cx: cx})
+}
+
+// checkRep traps if rep can't represent a resource of type rt.
+func (cx *callContext) checkRep(rt *ResourceType, rep any) {
+	if rep == nil {
+		trap("nil representation of %s", rt.Name)
+	}
+	if _, ok := rep.(uint32); !ok && rt.instance != nil {
+		trap("representation of %s must be a uint32, but was %T", rt.Name, rep)
+	}
+}
+
+// release ends the loans of resources for the call.
+func (cx *callContext) release() {
+	if len(cx.lenders) == 0 {
+		return
+	}
+	t := &cx.inst.handles
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for _, h := range cx.lenders {
+		h.lendCount--
+	}
+	cx.lenders = nil
+}
+
+// dropHandle implements resource.drop, which removes a handle and destroys
+// its resource if it is owned.
+func (inst *ComponentInstance) dropHandle(cx *callContext, rt *ResourceType, i uint32) {
+	h := inst.handles.remove(i, rt)
+	if !h.own {
+		h.cx.borrows--
+		return
+	}
+	inst.destroy(cx, h)
+}
+
+// destroy calls the destructor of the resource of an owned handle.
+func (inst *ComponentInstance) destroy(cx *callContext, h *handle) {
+	rt := h.rt
+	if rt.instance == nil {
+		if rt.Drop != nil {
+			rt.Drop(h.rep)
+		}
+	} else if rt.dtor != nil {
+		if _, err := rt.dtor.function().Call(cx.ctx, uint64(h.rep.(uint32))); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package component
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tetratelabs/wazero/internal/internalapi"
+)
+
+// HostFunc is a function of a HostInstance. params and the results returned
+// are represented as documented on Variant, and a HostFunc may panic to trap.
+type HostFunc func(ctx context.Context, inst *ComponentInstance, params []any) []any
+
+// HostInstance is an instance implemented by the host, which satisfies the
+// import of an instance with the same name, e.g. "wasi:cli/stdout@0.2.0".
+type HostInstance struct {
+	internalapi.WazeroOnlyType
+
+	Name string
+	// Funcs are the functions by name.
+	Funcs map[string]HostFunc
+	// Resources are the resource types by name.
+	Resources map[string]*ResourceType
+}
+
+// splitVersion splits an interface name, e.g. "wasi:cli/stdout@0.2.0", into
+// its unversioned name and version, which is empty if it has none.
+func splitVersion(name string) (string, string) {
+	if i := strings.LastIndexByte(name, '@'); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+// nameMatches returns true if name, which is imported or exported, matches
+// the requested name. Versions match if they are semver compatible, and a
+// requested name without a version matches any.
+func nameMatches(name, requested string) bool {
+	if name == requested {
+		return true
+	}
+	base, version := splitVersion(name)
+	requestedBase, requestedVersion := splitVersion(requested)
+	if base != requestedBase {
+		return false
+	}
+	return requestedVersion == "" || versionsCompatible(version, requestedVersion)
+}
+
+// versionsCompatible returns true if a component built against version a can
+// use an implementation of version b: they have the same major version, or
+// the same minor version if the major version is 0.
+func versionsCompatible(a, b string) bool {
+	a, _, _ = strings.Cut(a, "-")
+	b, _, _ = strings.Cut(b, "-")
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	if len(aParts) != 3 || len(bParts) != 3 || aParts[0] != bParts[0] {
+		return false
+	}
+	return aParts[0] != "0" || aParts[1] == bParts[1]
+}
@@ -0,0 +1,823 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+	"github.com/tetratelabs/wazero/internal/internalapi"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// CompiledComponent is a decoded component whose core modules are compiled.
+type CompiledComponent struct {
+	internalapi.WazeroOnlyType
+
+	component *Component
+	modules   []wazero.CompiledModule
+}
+
+// Compile decodes a component and compiles its core modules with r.
+//
+// Components can only import instances, which are implemented by the host,
+// and can't contain other components nor a start function.
+func Compile(ctx context.Context, r wazero.Runtime, binary []byte) (*CompiledComponent, error) {
+	c, err := Decode(binary)
+	if err != nil {
+		return nil, err
+	}
+	ret := &CompiledComponent{component: c}
+	for _, d := range c.Definitions {
+		switch d := d.(type) {
+		case *CoreModule:
+			var m wazero.CompiledModule
+			if m, err = r.CompileModule(ctx, d.Binary); err != nil {
+				err = fmt.Errorf("core module %d: %w", len(ret.modules), err)
+			}
+			ret.modules = append(ret.modules, m)
+		case *Nested:
+			err = errors.New("nested components are not supported")
+		case *Instance:
+			if !d.IsExports {
+				err = errors.New("instantiating components is not supported")
+			}
+		case *Start:
+			err = errors.New("start functions are not supported")
+		case *Import:
+			if d.Desc.Sort != SortInstance {
+				err = fmt.Errorf("import %s: importing a %s is not supported", d.Name, d.Desc.Sort)
+			}
+		}
+		if err != nil {
+			_ = ret.Close(ctx)
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// Close releases the compiled core modules.
+func (c *CompiledComponent) Close(ctx context.Context) (err error) {
+	for _, m := range c.modules {
+		if m == nil {
+			continue
+		}
+		if e := m.Close(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// ComponentInstance is an instantiated component.
+type ComponentInstance struct {
+	internalapi.WazeroOnlyType
+
+	// modules are the core module instances, including the host modules of
+	// lowered functions, in order of instantiation.
+	modules []*wasm.ModuleInstance
+	// compiled are the host modules of lowered functions, which are closed
+	// with the instance.
+	compiled []wazero.CompiledModule
+	// exports are the exports by name: *Func, *instanceValue or a type.
+	exports map[string]any
+	handles handleTable
+	sys     *internalsys.Context
+	closed  atomic.Bool
+}
+
+// Sys returns the system context of the first core module instance, which
+// is configured by the wazero.ModuleConfig passed to Instantiate, or nil if
+// the component has none.
+func (inst *ComponentInstance) Sys() *internalsys.Context {
+	return inst.sys
+}
+
+// ExportedFunction returns a function exported by name, or nil if there is
+// none. A function exported by an exported instance is named with both,
+// separated by '#', e.g. "wasi:cli/run@0.2.0#run". The versions of names
+// match if they are compatible, and a name without a version matches any.
+func (inst *ComponentInstance) ExportedFunction(name string) *Func {
+	exports := inst.exports
+	if iface, fn, ok := strings.Cut(name, "#"); ok {
+		iv, ok := findExport(exports, iface).(*instanceValue)
+		if !ok {
+			return nil
+		}
+		exports, name = iv.exports, fn
+	}
+	f, _ := findExport(exports, name).(*Func)
+	return f
+}
+
+func findExport(exports map[string]any, name string) any {
+	if e, ok := exports[name]; ok {
+		return e
+	}
+	for n, e := range exports {
+		if nameMatches(n, name) {
+			return e
+		}
+	}
+	return nil
+}
+
+// IsClosed returns true if the instance was closed.
+func (inst *ComponentInstance) IsClosed() bool {
+	return inst.closed.Load()
+}
+
+// Close closes the instance with exit code 0.
+func (inst *ComponentInstance) Close(ctx context.Context) error {
+	return inst.CloseWithExitCode(ctx, 0)
+}
+
+// CloseWithExitCode closes the core module instances of the component with
+// the exit code, and drops the resources implemented by the host which it
+// still owns.
+func (inst *ComponentInstance) CloseWithExitCode(ctx context.Context, exitCode uint32) (err error) {
+	if !inst.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	for i := len(inst.modules) - 1; i >= 0; i-- {
+		if e := inst.modules[i].CloseWithExitCode(ctx, exitCode); e != nil && err == nil {
+			err = e
+		}
+	}
+	for _, c := range inst.compiled {
+		if e := c.Close(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	for _, h := range inst.handles.removeAll() {
+		if h.own && h.rt.instance == nil && h.rt.Drop != nil {
+			h.rt.Drop(h.rep)
+		}
+	}
+	return
+}
+
+// Func is a function of a component instance, which is implemented either
+// by the host, or by a core function lifted with the canonical ABI.
+type Func struct {
+	Type *FuncType
+
+	inst *ComponentInstance
+	host HostFunc
+	core *coreExtern
+	opts *canonOptions
+}
+
+// Call calls the function with params, and returns its results, which are
+// represented as documented on Variant.
+func (f *Func) Call(ctx context.Context, params ...any) (results []any, err error) {
+	if len(params) != len(f.Type.Params) {
+		return nil, fmt.Errorf("expected %d params, but passed %d", len(f.Type.Params), len(params))
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			results, err = nil, e
+		}
+	}()
+	if f.host != nil {
+		return f.host(ctx, f.inst, params), nil
+	}
+	return f.callLifted(ctx, params)
+}
+
+// callLifted lowers params into the core function, and lifts its results.
+func (f *Func) callLifted(ctx context.Context, params []any) ([]any, error) {
+	cx := &callContext{ctx: ctx, inst: f.inst, opts: f.opts}
+	var args []uint64
+	if len(flattenFields(f.Type.Params)) > maxFlatParams {
+		ptr := cx.realloc(fieldsAlignment(f.Type.Params), fieldsSize(f.Type.Params))
+		cx.storeFields(ptr, f.Type.Params, params)
+		args = []uint64{uint64(ptr)}
+	} else {
+		for i, p := range f.Type.Params {
+			args = cx.lowerFlat(args, p.Type, params[i])
+		}
+	}
+
+	res, err := f.core.function().Call(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []any
+	if len(flattenFields(f.Type.Results)) > maxFlatResults {
+		results = cx.loadFields(cx.checkPtr(uint32(res[0]), f.Type.Results), f.Type.Results)
+	} else {
+		r := &flatReader{vals: res}
+		results = make([]any, len(f.Type.Results))
+		for i, rt := range f.Type.Results {
+			results[i] = cx.liftFlat(r, rt.Type)
+		}
+	}
+	if f.opts.postReturn != nil {
+		if _, err = f.opts.postReturn.function().Call(ctx, res...); err != nil {
+			return nil, err
+		}
+	}
+	if cx.borrows != 0 {
+		trap("%d borrowed handles were not dropped", cx.borrows)
+	}
+	return results, nil
+}
+
+// callLowered implements the core function lowered from a host function:
+// it lifts the params from the stack, calls the host, and lowers its
+// results.
+func (f *Func) callLowered(ctx context.Context, opts *canonOptions, stack []uint64) {
+	cx := &callContext{ctx: ctx, inst: f.inst, opts: opts}
+	defer cx.release()
+
+	var params []any
+	flatParams := flattenFields(f.Type.Params)
+	if len(flatParams) > maxFlatParams {
+		params = cx.loadFields(cx.checkPtr(uint32(stack[0]), f.Type.Params), f.Type.Params)
+		flatParams = flatParams[:1]
+	} else {
+		r := &flatReader{vals: stack}
+		params = make([]any, len(f.Type.Params))
+		for i, p := range f.Type.Params {
+			params[i] = cx.liftFlat(r, p.Type)
+		}
+	}
+
+	results := f.host(ctx, f.inst, params)
+	if len(results) != len(f.Type.Results) {
+		trap("expected %d results, but returned %d", len(f.Type.Results), len(results))
+	}
+
+	if len(flattenFields(f.Type.Results)) > maxFlatResults {
+		cx.storeFields(cx.checkPtr(uint32(stack[len(flatParams)]), f.Type.Results), f.Type.Results, results)
+	} else {
+		var flat []uint64
+		for i, rt := range f.Type.Results {
+			flat = cx.lowerFlat(flat, rt.Type, results[i])
+		}
+		copy(stack, flat)
+	}
+}
+
+// checkPtr traps if ptr isn't aligned for a tuple of fields, or if the tuple
+// is out of bounds.
+func (cx *callContext) checkPtr(ptr uint32, fields []Field) uint32 {
+	if ptr != alignTo(ptr, fieldsAlignment(fields)) {
+		trap("unaligned pointer: %d", ptr)
+	}
+	if uint64(ptr)+uint64(fieldsSize(fields)) > uint64(cx.memory().Size()) {
+		trap("out of bounds pointer: %d", ptr)
+	}
+	return ptr
+}
+
+// coreExtern is an item of a core index space, which is exported by name by
+// a core module instance.
+type coreExtern struct {
+	sort Sort
+	// mod is nil until the pending host module of a lowered function is
+	// instantiated.
+	mod  *wasm.ModuleInstance
+	name string
+}
+
+func (e *coreExtern) function() api.Function {
+	return e.mod.ExportedFunction(e.name)
+}
+
+// coreInstance is an item of the core instance index space: either a core
+// module instance, or a set of core items.
+type coreInstance struct {
+	mod     *wasm.ModuleInstance
+	exports map[string]*coreExtern
+}
+
+func (ci *coreInstance) export(name string, sort Sort) (*coreExtern, error) {
+	if ci.mod != nil {
+		if e, ok := ci.mod.Exports[name]; ok && e.Type == wasm.ExternType(sort) {
+			return &coreExtern{sort: sort, mod: ci.mod, name: name}, nil
+		}
+	} else if e, ok := ci.exports[name]; ok && e.sort == sort {
+		return e, nil
+	}
+	return nil, fmt.Errorf("%s %s is not exported", sort, name)
+}
+
+// instanceValue is an item of the instance index space.
+type instanceValue struct {
+	// exports are the exports by name: *Func, *instanceValue or a type.
+	exports map[string]any
+}
+
+// canonOptions are the resolved CanonOptions.
+type canonOptions struct {
+	encoding                    StringEncoding
+	memory, realloc, postReturn *coreExtern
+	// mem is resolved once all core instances are instantiated.
+	mem api.Memory
+}
+
+// pendingFunc is a core function implemented by the host which is exported
+// by the next host module instantiated by linker.flush.
+type pendingFunc struct {
+	extern          *coreExtern
+	fn              api.GoModuleFunction
+	params, results []api.ValueType
+}
+
+// linker instantiates a component, building its index spaces from its
+// definitions in order.
+type linker struct {
+	ctx     context.Context
+	r       wazero.Runtime
+	config  wazero.ModuleConfig
+	imports []*HostInstance
+	inst    *ComponentInstance
+
+	types     typeScope
+	funcs     []*Func
+	instances []*instanceValue
+
+	coreFuncs, coreTables, coreMemories, coreGlobals, coreTags []*coreExtern
+	coreModules                                                []wazero.CompiledModule
+	coreInstances                                              []*coreInstance
+	coreTypes                                                  int
+
+	modules int
+	pending []pendingFunc
+	opts    []*canonOptions
+}
+
+// Instantiate instantiates the component with r, configuring its core module
+// instances with config, and importing instances from imports.
+func (c *CompiledComponent) Instantiate(ctx context.Context, r wazero.Runtime, config wazero.ModuleConfig, imports []*HostInstance) (*ComponentInstance, error) {
+	l := &linker{
+		ctx:     ctx,
+		r:       r,
+		config:  config.WithName("").WithStartFunctions(),
+		imports: imports,
+		inst:    &ComponentInstance{exports: map[string]any{}},
+	}
+	if err := l.link(c); err != nil {
+		_ = l.inst.Close(ctx)
+		return nil, err
+	}
+	return l.inst, nil
+}
+
+func (l *linker) link(c *CompiledComponent) error {
+	for i, d := range c.component.Definitions {
+		var err error
+		switch d := d.(type) {
+		case *CoreModule:
+			l.coreModules = append(l.coreModules, c.modules[l.modules])
+			l.modules++
+		case *CoreInstance:
+			err = l.coreInstance(d)
+		case *CoreType:
+			l.coreTypes++
+		case *Instance:
+			err = l.instance(d)
+		case *Alias:
+			err = l.alias(d)
+		case *Type:
+			err = l.typeDef(d)
+		case *Canon:
+			err = l.canon(d)
+		case *Import:
+			err = l.importInstance(d)
+		case *Export:
+			err = l.export(d)
+		default:
+			panic(fmt.Sprintf("BUG: unexpected definition %T", d))
+		}
+		if err != nil {
+			return fmt.Errorf("definition %d: %w", i, err)
+		}
+	}
+	if err := l.flush(); err != nil {
+		return err
+	}
+	for _, o := range l.opts {
+		if o.memory != nil {
+			o.mem = o.memory.mod.ExportedMemory(o.memory.name)
+		}
+	}
+	return nil
+}
+
+func indexAt[T any](space []T, index uint32, sort Sort) (ret T, err error) {
+	if index >= uint32(len(space)) {
+		err = fmt.Errorf("%s index out of range: %d", sort, index)
+		return
+	}
+	return space[index], nil
+}
+
+// coreIndexSpace returns the index space of a core sort with externs.
+func (l *linker) coreIndexSpace(sort Sort) (*[]*coreExtern, error) {
+	switch sort {
+	case SortCoreFunc:
+		return &l.coreFuncs, nil
+	case SortCoreTable:
+		return &l.coreTables, nil
+	case SortCoreMemory:
+		return &l.coreMemories, nil
+	case SortCoreGlobal:
+		return &l.coreGlobals, nil
+	case SortCoreTag:
+		return &l.coreTags, nil
+	}
+	return nil, fmt.Errorf("%s is not supported", sort)
+}
+
+func (l *linker) coreInstance(d *CoreInstance) error {
+	if d.IsExports {
+		ci := &coreInstance{exports: make(map[string]*coreExtern, len(d.Exports))}
+		for _, e := range d.Exports {
+			space, err := l.coreIndexSpace(e.Sort)
+			if err != nil {
+				return err
+			}
+			if ci.exports[e.Name], err = indexAt(*space, e.Index, e.Sort); err != nil {
+				return err
+			}
+		}
+		l.coreInstances = append(l.coreInstances, ci)
+		return nil
+	}
+
+	compiled, err := indexAt(l.coreModules, d.Module, SortCoreModule)
+	if err != nil {
+		return err
+	}
+	args := make(map[string]*coreInstance, len(d.Args))
+	for _, a := range d.Args {
+		if args[a.Name], err = indexAt(l.coreInstances, a.Instance, SortCoreInstance); err != nil {
+			return err
+		}
+	}
+	// Lowered functions must be instantiated before they are imported.
+	if err = l.flush(); err != nil {
+		return err
+	}
+
+	ctx := context.WithValue(l.ctx, expctxkeys.ExternResolverKey{}, wasm.ExternResolver(
+		func(moduleName, name string) (*wasm.ModuleInstance, string) {
+			arg, ok := args[moduleName]
+			if !ok {
+				return nil, ""
+			}
+			if arg.mod != nil {
+				return arg.mod, name
+			}
+			if e, ok := arg.exports[name]; ok {
+				return e.mod, e.name
+			}
+			return nil, ""
+		}))
+	mod, err := l.r.InstantiateModule(ctx, compiled, l.config)
+	if err != nil {
+		return err
+	}
+	m := mod.(*wasm.ModuleInstance)
+	if l.inst.sys == nil {
+		l.inst.sys = m.Sys
+	}
+	l.inst.modules = append(l.inst.modules, m)
+	l.coreInstances = append(l.coreInstances, &coreInstance{mod: m})
+	return nil
+}
+
+// flush instantiates the pending lowered functions in an anonymous host
+// module, as there may be several per component instance.
+func (l *linker) flush() error {
+	if len(l.pending) == 0 {
+		return nil
+	}
+	b := l.r.NewHostModuleBuilder("canon")
+	for i, p := range l.pending {
+		b.NewFunctionBuilder().WithGoModuleFunction(p.fn, p.params, p.results).Export(strconv.Itoa(i))
+	}
+	compiled, err := b.Compile(l.ctx)
+	if err != nil {
+		return err
+	}
+	l.inst.compiled = append(l.inst.compiled, compiled)
+	mod, err := l.r.InstantiateModule(l.ctx, compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		return err
+	}
+	m := mod.(*wasm.ModuleInstance)
+	l.inst.modules = append(l.inst.modules, m)
+	for i, p := range l.pending {
+		p.extern.mod, p.extern.name = m, strconv.Itoa(i)
+	}
+	l.pending = nil
+	return nil
+}
+
+// addPending adds a core function implemented by fn.
+func (l *linker) addPending(fn api.GoModuleFunc, params, results []api.ValueType) {
+	e := &coreExtern{sort: SortCoreFunc}
+	l.pending = append(l.pending, pendingFunc{extern: e, fn: fn, params: params, results: results})
+	l.coreFuncs = append(l.coreFuncs, e)
+}
+
+// value returns the item of a component index space.
+func (l *linker) value(sort Sort, index uint32) (any, error) {
+	switch sort {
+	case SortFunc:
+		return indexAt(l.funcs, index, sort)
+	case SortType:
+		return l.types.typeAt(index)
+	case SortInstance:
+		return indexAt(l.instances, index, sort)
+	}
+	return nil, fmt.Errorf("%s is not supported", sort)
+}
+
+// append adds an item to the index space of its sort.
+func (l *linker) append(sort Sort, v any) error {
+	switch sort {
+	case SortFunc:
+		f, ok := v.(*Func)
+		if !ok {
+			return fmt.Errorf("%T is not a function", v)
+		}
+		l.funcs = append(l.funcs, f)
+	case SortType:
+		if _, ok := v.(*Func); ok {
+			return fmt.Errorf("%T is not a type", v)
+		} else if _, ok = v.(*instanceValue); ok {
+			return fmt.Errorf("%T is not a type", v)
+		}
+		l.types.types = append(l.types.types, v)
+	case SortInstance:
+		iv, ok := v.(*instanceValue)
+		if !ok {
+			return fmt.Errorf("%T is not an instance", v)
+		}
+		l.instances = append(l.instances, iv)
+	default:
+		return fmt.Errorf("%s is not supported", sort)
+	}
+	return nil
+}
+
+func (l *linker) instance(d *Instance) error {
+	iv := &instanceValue{exports: make(map[string]any, len(d.Exports))}
+	for _, e := range d.Exports {
+		v, err := l.value(e.Sort, e.Index)
+		if err != nil {
+			return err
+		}
+		iv.exports[e.Name] = v
+	}
+	l.instances = append(l.instances, iv)
+	return nil
+}
+
+func (l *linker) alias(a *Alias) error {
+	switch a.Kind {
+	case AliasKindCoreExport:
+		ci, err := indexAt(l.coreInstances, a.Instance, SortCoreInstance)
+		if err != nil {
+			return err
+		}
+		space, err := l.coreIndexSpace(a.Sort)
+		if err != nil {
+			return err
+		}
+		e, err := ci.export(a.Name, a.Sort)
+		if err != nil {
+			return err
+		}
+		*space = append(*space, e)
+	case AliasKindExport:
+		iv, err := indexAt(l.instances, a.Instance, SortInstance)
+		if err != nil {
+			return err
+		}
+		v, ok := iv.exports[a.Name]
+		if !ok {
+			return fmt.Errorf("%s %s is not exported", a.Sort, a.Name)
+		}
+		return l.append(a.Sort, v)
+	case AliasKindOuter:
+		if a.Count != 0 {
+			return fmt.Errorf("outer alias count out of range: %d", a.Count)
+		}
+		switch a.Sort {
+		case SortType:
+			t, err := l.types.typeAt(a.Index)
+			if err != nil {
+				return err
+			}
+			l.types.types = append(l.types.types, t)
+		case SortCoreModule:
+			m, err := indexAt(l.coreModules, a.Index, a.Sort)
+			if err != nil {
+				return err
+			}
+			l.coreModules = append(l.coreModules, m)
+		case SortCoreType:
+			if a.Index >= uint32(l.coreTypes) {
+				return fmt.Errorf("%s index out of range: %d", a.Sort, a.Index)
+			}
+			l.coreTypes++
+		default:
+			return fmt.Errorf("outer alias of %s is not supported", a.Sort)
+		}
+	}
+	return nil
+}
+
+func (l *linker) typeDef(d *Type) error {
+	if def, ok := d.Def.(*ResourceTypeDef); ok {
+		rt := &ResourceType{Name: fmt.Sprintf("resource %d", len(l.types.types)), instance: l.inst}
+		if def.HasDtor {
+			var err error
+			if rt.dtor, err = indexAt(l.coreFuncs, def.Dtor, SortCoreFunc); err != nil {
+				return err
+			}
+		}
+		l.types.types = append(l.types.types, rt)
+		return nil
+	}
+	t, err := l.types.resolveTypeDef(d.Def)
+	if err != nil {
+		return err
+	}
+	l.types.types = append(l.types.types, t)
+	return nil
+}
+
+func (l *linker) canonOptions(o *CanonOptions) (ret *canonOptions, err error) {
+	ret = &canonOptions{encoding: o.StringEncoding}
+	if o.HasMemory {
+		if ret.memory, err = indexAt(l.coreMemories, o.Memory, SortCoreMemory); err != nil {
+			return
+		}
+	}
+	if o.HasRealloc {
+		if ret.realloc, err = indexAt(l.coreFuncs, o.Realloc, SortCoreFunc); err != nil {
+			return
+		}
+	}
+	if o.HasPostReturn {
+		if ret.postReturn, err = indexAt(l.coreFuncs, o.PostReturn, SortCoreFunc); err != nil {
+			return
+		}
+	}
+	l.opts = append(l.opts, ret)
+	return
+}
+
+func (l *linker) canon(c *Canon) error {
+	inst := l.inst
+	switch c.Kind {
+	case CanonLift:
+		core, err := indexAt(l.coreFuncs, c.Func, SortCoreFunc)
+		if err != nil {
+			return err
+		}
+		t, err := l.types.typeAt(c.Type)
+		if err != nil {
+			return err
+		}
+		ft, ok := t.(*FuncType)
+		if !ok {
+			return fmt.Errorf("type %d is not a function type", c.Type)
+		}
+		opts, err := l.canonOptions(&c.Options)
+		if err != nil {
+			return err
+		}
+		l.funcs = append(l.funcs, &Func{Type: ft, inst: inst, core: core, opts: opts})
+	case CanonLower:
+		f, err := indexAt(l.funcs, c.Func, SortFunc)
+		if err != nil {
+			return err
+		}
+		if f.host == nil {
+			return errors.New("lowering a lifted function is not supported")
+		}
+		opts, err := l.canonOptions(&c.Options)
+		if err != nil {
+			return err
+		}
+		params, results := flatSignature(f.Type, true)
+		l.addPending(func(ctx context.Context, _ api.Module, stack []uint64) {
+			f.callLowered(ctx, opts, stack)
+		}, params, results)
+	default:
+		t, err := l.types.typeAt(c.Type)
+		if err != nil {
+			return err
+		}
+		rt, ok := t.(*ResourceType)
+		if !ok {
+			return fmt.Errorf("type %d is not a resource type", c.Type)
+		}
+		i32 := []api.ValueType{api.ValueTypeI32}
+		switch c.Kind {
+		case CanonResourceNew:
+			if rt.instance != inst {
+				return fmt.Errorf("%s is not defined by the component", rt.Name)
+			}
+			l.addPending(func(ctx context.Context, _ api.Module, stack []uint64) {
+				stack[0] = uint64(inst.handles.add(&handle{rt: rt, rep: uint32(stack[0]), own: true}))
+			}, i32, i32)
+		case CanonResourceDrop:
+			l.addPending(func(ctx context.Context, _ api.Module, stack []uint64) {
+				inst.dropHandle(&callContext{ctx: ctx, inst: inst}, rt, uint32(stack[0]))
+			}, i32, nil)
+		case CanonResourceRep:
+			if rt.instance != inst {
+				return fmt.Errorf("%s is not defined by the component", rt.Name)
+			}
+			l.addPending(func(ctx context.Context, _ api.Module, stack []uint64) {
+				stack[0] = uint64(inst.handles.get(uint32(stack[0]), rt).rep.(uint32))
+			}, i32, i32)
+		}
+	}
+	return nil
+}
+
+// importInstance binds the import of an instance to the host instance with
+// a matching name, whose resource types replace the abstract ones of the
+// instance type.
+func (l *linker) importInstance(d *Import) error {
+	t, err := l.types.resolveExternDesc(d.Name, d.Desc)
+	if err != nil {
+		return fmt.Errorf("import %s: %w", d.Name, err)
+	}
+	var host *HostInstance
+	for _, h := range l.imports {
+		if nameMatches(h.Name, d.Name) {
+			host = h
+			break
+		}
+	}
+	if host == nil {
+		return fmt.Errorf("import %s: not provided", d.Name)
+	}
+
+	it := t.(*InstanceType)
+	names := slices.Sorted(maps.Keys(it.Exports))
+	bound := map[*ResourceType]*ResourceType{}
+	for _, name := range names {
+		if rt, ok := it.Exports[name].(*ResourceType); ok && rt.abstract {
+			if bound[rt], ok = host.Resources[name]; !ok {
+				return fmt.Errorf("import %s: resource %s not provided", d.Name, name)
+			}
+		}
+	}
+	it = bindInstanceType(it, bound)
+
+	iv := &instanceValue{exports: make(map[string]any, len(it.Exports))}
+	for _, name := range names {
+		switch e := it.Exports[name].(type) {
+		case *FuncType:
+			fn, ok := host.Funcs[name]
+			if !ok {
+				return fmt.Errorf("import %s: function %s not provided", d.Name, name)
+			}
+			iv.exports[name] = &Func{Type: e, inst: l.inst, host: fn}
+		case *InstanceType:
+			return fmt.Errorf("import %s: nested instances are not supported", d.Name)
+		default:
+			iv.exports[name] = e
+		}
+	}
+	l.instances = append(l.instances, iv)
+	return nil
+}
+
+func (l *linker) export(d *Export) error {
+	v, err := l.value(d.Sort, d.Index)
+	if err != nil {
+		return err
+	}
+	if rt, ok := v.(*ResourceType); ok && rt.instance == l.inst {
+		rt.Name = d.Name
+	}
+	l.inst.exports[d.Name] = v
+	return l.append(d.Sort, v)
+}
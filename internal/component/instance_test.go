@@ -0,0 +1,390 @@
+package component_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/component"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/componentencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var testCtx = context.Background()
+
+const i32 = wasm.ValueTypeI32
+
+// libcWasm exports a memory, and a realloc which bumps a pointer.
+var libcWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection:   []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32, i32, i32}, Results: []wasm.ValueType{i32}}},
+	MemorySection: []wasm.Memory{{Min: 1}},
+	GlobalSection: []wasm.Global{{
+		Type: wasm.GlobalType{ValType: i32, Mutable: true},
+		Init: wasm.NewConstantExpressionFromI32(1024),
+	}},
+	FunctionSection: []wasm.Index{0},
+	CodeSection: []wasm.Code{{LocalTypes: []wasm.ValueType{i32}, Body: []byte{
+		// ptr := (next + align - 1) & -align
+		wasm.OpcodeGlobalGet, 0,
+		wasm.OpcodeLocalGet, 2,
+		wasm.OpcodeI32Add,
+		wasm.OpcodeI32Const, 1,
+		wasm.OpcodeI32Sub,
+		wasm.OpcodeI32Const, 0,
+		wasm.OpcodeLocalGet, 2,
+		wasm.OpcodeI32Sub,
+		wasm.OpcodeI32And,
+		wasm.OpcodeLocalTee, 4,
+		// next = ptr + size
+		wasm.OpcodeLocalGet, 3,
+		wasm.OpcodeI32Add,
+		wasm.OpcodeGlobalSet, 0,
+		wasm.OpcodeLocalGet, 4,
+		wasm.OpcodeEnd,
+	}}},
+	ExportSection: []wasm.Export{
+		{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+		{Name: "realloc", Type: wasm.ExternTypeFunc, Index: 0},
+	},
+})
+
+// guestWasm imports the lowered functions from "host", and exports:
+//   - "greet" which calls log with its string.
+//   - "twice" which calls double.
+//   - "echo" which returns its string.
+//   - "count" which increments a new counter twice, and drops it.
+//   - "make" which returns a new resource represented by 42.
+var guestWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{i32, i32}},
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+		{Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+	},
+	ImportSection: []wasm.Import{
+		{Module: "host", Name: "log", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		{Module: "host", Name: "double", Type: wasm.ExternTypeFunc, DescFunc: 1},
+		{Module: "host", Name: "counter-new", Type: wasm.ExternTypeFunc, DescFunc: 2},
+		{Module: "host", Name: "counter-inc", Type: wasm.ExternTypeFunc, DescFunc: 1},
+		{Module: "host", Name: "counter-drop", Type: wasm.ExternTypeFunc, DescFunc: 3},
+		{Module: "host", Name: "r-new", Type: wasm.ExternTypeFunc, DescFunc: 1},
+		{Module: "host", Name: "memory", Type: wasm.ExternTypeMemory, DescMem: &wasm.Memory{Min: 1}},
+	},
+	ImportFunctionCount: 6,
+	ImportMemoryCount:   1,
+	FunctionSection:     []wasm.Index{0, 1, 4, 2, 2},
+	CodeSection: []wasm.Code{
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+		{Body: []byte{
+			wasm.OpcodeI32Const, 8,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Store, 2, 0,
+			wasm.OpcodeI32Const, 8,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeI32Store, 2, 4,
+			wasm.OpcodeI32Const, 8,
+			wasm.OpcodeEnd,
+		}},
+		{LocalTypes: []wasm.ValueType{i32}, Body: []byte{
+			wasm.OpcodeCall, 2,
+			wasm.OpcodeLocalSet, 0,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 3,
+			wasm.OpcodeDrop,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 3,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 4,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{wasm.OpcodeI32Const, 42, wasm.OpcodeCall, 5, wasm.OpcodeEnd}},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "greet", Type: wasm.ExternTypeFunc, Index: 6},
+		{Name: "twice", Type: wasm.ExternTypeFunc, Index: 7},
+		{Name: "echo", Type: wasm.ExternTypeFunc, Index: 8},
+		{Name: "count", Type: wasm.ExternTypeFunc, Index: 9},
+		{Name: "make", Type: wasm.ExternTypeFunc, Index: 10},
+	},
+})
+
+func valType(kind component.ValTypeKind) component.ValTypeRef {
+	return component.ValTypeRef{Primitive: kind}
+}
+
+func funcType(params []component.LabeledValType, result *component.ValTypeRef) *component.FuncTypeDef {
+	ret := &component.FuncTypeDef{Params: params}
+	if result != nil {
+		ret.Results = []component.LabeledValType{{Type: *result}}
+	}
+	return ret
+}
+
+func export(name string, sort component.Sort, index uint32) component.Decl {
+	return component.Decl{Kind: component.DeclKindExport, Name: name, Desc: component.ExternDesc{Sort: sort, Index: index}}
+}
+
+func aliasExport(sort component.Sort, instance uint32, name string) *component.Alias {
+	kind := component.AliasKindExport
+	if sort.IsCore() {
+		kind = component.AliasKindCoreExport
+	}
+	return &component.Alias{Sort: sort, Kind: kind, Instance: instance, Name: name}
+}
+
+// testComponent imports the instance "test:host/api@0.1.0", and lifts the
+// exports of guestWasm, also exported by the instance "test:guest/api".
+var testComponent = func() *component.Component {
+	str, u32 := valType(component.ValTypeKindString), valType(component.ValTypeKindU32)
+	mem := component.CanonOptions{Memory: 0, HasMemory: true, Realloc: 0, HasRealloc: true}
+	return &component.Component{Definitions: []component.Definition{
+		// type 0
+		&component.Type{Def: &component.InstanceTypeDef{Decls: []component.Decl{
+			{Kind: component.DeclKindType, Type: funcType([]component.LabeledValType{{Name: "msg", Type: str}}, nil)},
+			export("log", component.SortFunc, 0),
+			{Kind: component.DeclKindType, Type: funcType([]component.LabeledValType{{Name: "x", Type: u32}}, &u32)},
+			export("double", component.SortFunc, 1),
+			{Kind: component.DeclKindExport, Name: "counter", Desc: component.ExternDesc{Sort: component.SortType, SubResource: true}},
+			{Kind: component.DeclKindType, Type: &component.ValTypeDef{Kind: component.ValTypeKindOwn, Resource: 2}},
+			{Kind: component.DeclKindType, Type: funcType(nil, &component.ValTypeRef{Index: 3})},
+			export("[constructor]counter", component.SortFunc, 4),
+			{Kind: component.DeclKindType, Type: &component.ValTypeDef{Kind: component.ValTypeKindBorrow, Resource: 2}},
+			{Kind: component.DeclKindType, Type: funcType([]component.LabeledValType{{Name: "self", Type: component.ValTypeRef{Index: 5}}}, &u32)},
+			export("[method]counter.inc", component.SortFunc, 6),
+		}}},
+		&component.Import{Name: "test:host/api@0.1.0", Desc: component.ExternDesc{Sort: component.SortInstance, Index: 0}},
+		aliasExport(component.SortFunc, 0, "log"),                  // func 0
+		aliasExport(component.SortFunc, 0, "double"),               // func 1
+		aliasExport(component.SortFunc, 0, "[constructor]counter"), // func 2
+		aliasExport(component.SortFunc, 0, "[method]counter.inc"),  // func 3
+		aliasExport(component.SortType, 0, "counter"),              // type 1
+
+		&component.CoreModule{Binary: libcWasm},
+		&component.CoreInstance{Module: 0},
+		aliasExport(component.SortCoreMemory, 0, "memory"),                  // core memory 0
+		aliasExport(component.SortCoreFunc, 0, "realloc"),                   // core func 0
+		&component.Canon{Kind: component.CanonLower, Func: 0, Options: mem}, // core func 1
+		&component.Canon{Kind: component.CanonLower, Func: 1},               // core func 2
+		&component.Canon{Kind: component.CanonLower, Func: 2},               // core func 3
+		&component.Canon{Kind: component.CanonLower, Func: 3},               // core func 4
+		&component.Canon{Kind: component.CanonResourceDrop, Type: 1},        // core func 5
+		&component.Type{Def: &component.ResourceTypeDef{}},                  // type 2
+		&component.Canon{Kind: component.CanonResourceNew, Type: 2},         // core func 6
+		&component.CoreInstance{IsExports: true, Exports: []component.Export{
+			{Name: "log", Sort: component.SortCoreFunc, Index: 1},
+			{Name: "double", Sort: component.SortCoreFunc, Index: 2},
+			{Name: "counter-new", Sort: component.SortCoreFunc, Index: 3},
+			{Name: "counter-inc", Sort: component.SortCoreFunc, Index: 4},
+			{Name: "counter-drop", Sort: component.SortCoreFunc, Index: 5},
+			{Name: "r-new", Sort: component.SortCoreFunc, Index: 6},
+			{Name: "memory", Sort: component.SortCoreMemory, Index: 0},
+		}},
+		&component.CoreModule{Binary: guestWasm},
+		&component.CoreInstance{Module: 1, Args: []component.CoreInstantiateArg{{Name: "host", Instance: 1}}},
+		aliasExport(component.SortCoreFunc, 2, "greet"), // core func 7
+		aliasExport(component.SortCoreFunc, 2, "twice"), // core func 8
+		aliasExport(component.SortCoreFunc, 2, "echo"),  // core func 9
+		aliasExport(component.SortCoreFunc, 2, "count"), // core func 10
+		aliasExport(component.SortCoreFunc, 2, "make"),  // core func 11
+
+		&component.Type{Def: funcType([]component.LabeledValType{{Name: "name", Type: str}}, nil)}, // type 3
+		&component.Type{Def: funcType([]component.LabeledValType{{Name: "x", Type: u32}}, &u32)},   // type 4
+		&component.Type{Def: funcType([]component.LabeledValType{{Name: "s", Type: str}}, &str)},   // type 5
+		&component.Type{Def: funcType(nil, &u32)},                                                  // type 6
+		&component.Type{Def: &component.ValTypeDef{Kind: component.ValTypeKindOwn, Resource: 2}},   // type 7
+		&component.Type{Def: funcType(nil, &component.ValTypeRef{Index: 7})},                       // type 8
+		&component.Canon{Kind: component.CanonLift, Func: 7, Type: 3, Options: mem},                // func 4
+		&component.Canon{Kind: component.CanonLift, Func: 8, Type: 4},                              // func 5
+		&component.Canon{Kind: component.CanonLift, Func: 9, Type: 5, Options: mem},                // func 6
+		&component.Canon{Kind: component.CanonLift, Func: 10, Type: 6},                             // func 7
+		&component.Canon{Kind: component.CanonLift, Func: 11, Type: 8},                             // func 8
+		&component.Export{Name: "greet", Sort: component.SortFunc, Index: 4},
+		&component.Export{Name: "twice", Sort: component.SortFunc, Index: 5},
+		&component.Export{Name: "echo", Sort: component.SortFunc, Index: 6},
+		&component.Export{Name: "count", Sort: component.SortFunc, Index: 7},
+		&component.Export{Name: "make", Sort: component.SortFunc, Index: 8},
+		&component.Instance{IsExports: true, Exports: []component.Export{{Name: "greet", Sort: component.SortFunc, Index: 4}}},
+		&component.Export{Name: "test:guest/api@0.1.0", Sort: component.SortInstance, Index: 1},
+	}}
+}()
+
+// testHost implements "test:host/api", recording what the component logs
+// and the counters it drops.
+type testHost struct {
+	logged  []string
+	dropped []*uint32
+}
+
+func (h *testHost) instance() *component.HostInstance {
+	return &component.HostInstance{
+		Name: "test:host/api@0.1.2",
+		Funcs: map[string]component.HostFunc{
+			"log": func(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+				h.logged = append(h.logged, params[0].(string))
+				return nil
+			},
+			"double": func(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+				return []any{params[0].(uint32) * 2}
+			},
+			"[constructor]counter": func(context.Context, *component.ComponentInstance, []any) []any {
+				return []any{new(uint32)}
+			},
+			"[method]counter.inc": func(_ context.Context, _ *component.ComponentInstance, params []any) []any {
+				c := params[0].(*uint32)
+				*c++
+				return []any{*c}
+			},
+		},
+		Resources: map[string]*component.ResourceType{
+			"counter": {Name: "counter", Drop: func(rep any) { h.dropped = append(h.dropped, rep.(*uint32)) }},
+		},
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config wazero.RuntimeConfig
+	}{
+		{name: "interpreter", config: wazero.NewRuntimeConfigInterpreter()},
+		{name: "default", config: wazero.NewRuntimeConfig()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := wazero.NewRuntimeWithConfig(testCtx, tc.config)
+			defer r.Close(testCtx)
+
+			compiled, err := component.Compile(testCtx, r, componentencoding.Encode(testComponent))
+			require.NoError(t, err)
+			defer compiled.Close(testCtx)
+
+			host := &testHost{}
+			inst, err := compiled.Instantiate(testCtx, r, wazero.NewModuleConfig(), []*component.HostInstance{host.instance()})
+			require.NoError(t, err)
+			defer inst.Close(testCtx)
+
+			t.Run("string param", func(t *testing.T) {
+				_, err := inst.ExportedFunction("greet").Call(testCtx, "hello")
+				require.NoError(t, err)
+				_, err = inst.ExportedFunction("test:guest/api#greet").Call(testCtx, "world")
+				require.NoError(t, err)
+				require.Equal(t, []string{"hello", "world"}, host.logged)
+			})
+
+			t.Run("flat values", func(t *testing.T) {
+				res, err := inst.ExportedFunction("twice").Call(testCtx, uint32(21))
+				require.NoError(t, err)
+				require.Equal(t, []any{uint32(42)}, res)
+			})
+
+			t.Run("string result", func(t *testing.T) {
+				res, err := inst.ExportedFunction("echo").Call(testCtx, "wazero")
+				require.NoError(t, err)
+				require.Equal(t, []any{"wazero"}, res)
+			})
+
+			t.Run("host resource", func(t *testing.T) {
+				res, err := inst.ExportedFunction("count").Call(testCtx)
+				require.NoError(t, err)
+				require.Equal(t, []any{uint32(2)}, res)
+				require.Equal(t, 1, len(host.dropped))
+				require.Equal(t, uint32(2), *host.dropped[0])
+			})
+
+			t.Run("guest resource", func(t *testing.T) {
+				res, err := inst.ExportedFunction("make").Call(testCtx)
+				require.NoError(t, err)
+				require.Equal(t, []any{uint32(42)}, res)
+			})
+
+			t.Run("wrong param", func(t *testing.T) {
+				_, err := inst.ExportedFunction("twice").Call(testCtx, "21")
+				require.EqualError(t, err, "expected u32 but was string")
+			})
+
+			require.Nil(t, inst.ExportedFunction("missing"))
+		})
+	}
+}
+
+func TestInstantiate_Errors(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	compiled, err := component.Compile(testCtx, r, componentencoding.Encode(testComponent))
+	require.NoError(t, err)
+	defer compiled.Close(testCtx)
+
+	host := (&testHost{}).instance()
+	incompatible := *host
+	incompatible.Name = "test:host/api@0.2.0"
+	noFunc := *host
+	noFunc.Funcs = map[string]component.HostFunc{}
+	noResource := *host
+	noResource.Resources = nil
+
+	tests := []struct {
+		name        string
+		host        *component.HostInstance
+		expectedErr string
+	}{
+		{name: "not provided", host: &incompatible, expectedErr: "definition 1: import test:host/api@0.1.0: not provided"},
+		{name: "missing resource", host: &noResource, expectedErr: "definition 1: import test:host/api@0.1.0: resource counter not provided"},
+		{name: "missing function", host: &noFunc, expectedErr: "definition 1: import test:host/api@0.1.0: function [constructor]counter not provided"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := compiled.Instantiate(testCtx, r, wazero.NewModuleConfig(), []*component.HostInstance{tc.host})
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	tests := []struct {
+		name        string
+		input       *component.Component
+		expectedErr string
+	}{
+		{
+			name:        "nested component",
+			input:       &component.Component{Definitions: []component.Definition{&component.Nested{Component: &component.Component{}}}},
+			expectedErr: "nested components are not supported",
+		},
+		{
+			name:        "start",
+			input:       &component.Component{Definitions: []component.Definition{&component.Start{}}},
+			expectedErr: "start functions are not supported",
+		},
+		{
+			name: "function import",
+			input: &component.Component{Definitions: []component.Definition{
+				&component.Import{Name: "f", Desc: component.ExternDesc{Sort: component.SortFunc}},
+			}},
+			expectedErr: "import f: importing a func is not supported",
+		},
+		{
+			name: "invalid core module",
+			input: &component.Component{Definitions: []component.Definition{
+				&component.CoreModule{Binary: []byte{0}},
+			}},
+			expectedErr: "core module 0: invalid magic number",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := component.Compile(testCtx, r, componentencoding.Encode(tc.input))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
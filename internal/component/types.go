@@ -0,0 +1,431 @@
+package component
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValType is a resolved value type.
+//
+// The canonical ABI treats a tuple as a record, and an enum, option or
+// result as a variant, so these only differ in how values are represented.
+type ValType struct {
+	Kind ValTypeKind
+	// Fields are the fields of a record or a tuple, whose fields have no name.
+	Fields []Field
+	// Cases are the cases of a variant, enum, option or result.
+	Cases []Case
+	// Labels are the labels of flags.
+	Labels []string
+	// Elem is the type of the elements of a list.
+	Elem *ValType
+	// Resource is the resource type of own and borrow.
+	Resource *ResourceType
+}
+
+// Field is a field of a record, or a named parameter of a function.
+type Field struct {
+	Name string
+	Type *ValType
+}
+
+// Case is a case of a variant, which has a payload if Type is non-nil.
+type Case struct {
+	Name string
+	Type *ValType
+}
+
+// String implements fmt.Stringer.
+func (t *ValType) String() string {
+	switch t.Kind {
+	case ValTypeKindRecord:
+		fields := make([]string, len(t.Fields))
+		for i, f := range t.Fields {
+			fields[i] = f.Name + ": " + f.Type.String()
+		}
+		return "record{" + strings.Join(fields, ", ") + "}"
+	case ValTypeKindTuple:
+		fields := make([]string, len(t.Fields))
+		for i, f := range t.Fields {
+			fields[i] = f.Type.String()
+		}
+		return "tuple<" + strings.Join(fields, ", ") + ">"
+	case ValTypeKindVariant, ValTypeKindEnum:
+		cases := make([]string, len(t.Cases))
+		for i, c := range t.Cases {
+			cases[i] = c.Name
+			if c.Type != nil {
+				cases[i] += "(" + c.Type.String() + ")"
+			}
+		}
+		return t.Kind.String() + "{" + strings.Join(cases, ", ") + "}"
+	case ValTypeKindOption:
+		return "option<" + t.Cases[1].Type.String() + ">"
+	case ValTypeKindResult:
+		ok, err := "_", "_"
+		if c := t.Cases[0].Type; c != nil {
+			ok = c.String()
+		}
+		if c := t.Cases[1].Type; c != nil {
+			err = c.String()
+		}
+		return "result<" + ok + ", " + err + ">"
+	case ValTypeKindFlags:
+		return "flags{" + strings.Join(t.Labels, ", ") + "}"
+	case ValTypeKindList:
+		return "list<" + t.Elem.String() + ">"
+	case ValTypeKindOwn, ValTypeKindBorrow:
+		return t.Kind.String() + "<" + t.Resource.Name + ">"
+	}
+	return t.Kind.String()
+}
+
+// primitiveTypes are the resolved primitive types, indexed by kind.
+var primitiveTypes = func() (ret [ValTypeKindString + 1]*ValType) {
+	for k := ValTypeKindBool; k <= ValTypeKindString; k++ {
+		ret[k] = &ValType{Kind: k}
+	}
+	return
+}()
+
+// FuncType is a resolved function type.
+type FuncType struct {
+	Params []Field
+	// Results has at most one element, unless results are named.
+	Results []Field
+}
+
+// String implements fmt.Stringer.
+func (t *FuncType) String() string {
+	params := make([]string, len(t.Params))
+	for i, p := range t.Params {
+		params[i] = p.Name + ": " + p.Type.String()
+	}
+	ret := "func(" + strings.Join(params, ", ") + ")"
+	if len(t.Results) == 1 && t.Results[0].Name == "" {
+		return ret + " -> " + t.Results[0].Type.String()
+	} else if len(t.Results) > 0 {
+		results := make([]string, len(t.Results))
+		for i, r := range t.Results {
+			results[i] = r.Name + ": " + r.Type.String()
+		}
+		return ret + " -> (" + strings.Join(results, ", ") + ")"
+	}
+	return ret
+}
+
+// ResourceType is a resource type. Each definition or import of a resource
+// type is distinct, so resource types are compared by identity.
+type ResourceType struct {
+	// Name is the name of the resource type, for errors.
+	Name string
+	// Drop is called with the representation of a resource implemented by
+	// the host, when its owned handle is dropped. Nil if there is nothing to
+	// release.
+	Drop func(rep any)
+
+	// dtor is the destructor of a resource type defined by a component, which
+	// is called with its i32 representation.
+	dtor *coreExtern
+	// instance is the component instance which defined this resource type, or
+	// nil if implemented by the host.
+	instance *ComponentInstance
+	// abstract is true for a resource type imported by a type, which is bound
+	// to the one provided on instantiation.
+	abstract bool
+}
+
+// InstanceType is a resolved instance type.
+type InstanceType struct {
+	// Exports are the types of the exports by name: *FuncType for functions,
+	// *InstanceType for instances, and for types, the type exported, which
+	// is a *ResourceType for resources.
+	Exports map[string]any
+}
+
+// typeScope is the type index space when resolving type definitions, which
+// may refer to the one of an enclosing component or type.
+type typeScope struct {
+	types  []any
+	parent *typeScope
+}
+
+func (s *typeScope) typeAt(index uint32) (any, error) {
+	if index >= uint32(len(s.types)) {
+		return nil, fmt.Errorf("type index out of range: %d", index)
+	}
+	return s.types[index], nil
+}
+
+func (s *typeScope) outer(count, index uint32) (any, error) {
+	scope := s
+	for ; count > 0 && scope != nil; count-- {
+		scope = scope.parent
+	}
+	if scope == nil {
+		return nil, fmt.Errorf("outer alias count out of range: %d", count)
+	}
+	return scope.typeAt(index)
+}
+
+// resolveTypeDef resolves a type definition to a *ValType, *FuncType,
+// *InstanceType or *ResourceType.
+func (s *typeScope) resolveTypeDef(def TypeDef) (any, error) {
+	switch def := def.(type) {
+	case *ValTypeDef:
+		return s.resolveValTypeDef(def)
+	case *FuncTypeDef:
+		return s.resolveFuncTypeDef(def)
+	case *InstanceTypeDef:
+		return s.resolveInstanceTypeDef(def)
+	case *ComponentTypeDef:
+		return nil, fmt.Errorf("component types are not supported")
+	case *ResourceTypeDef:
+		return nil, fmt.Errorf("resource types can only be defined by a component")
+	}
+	panic(fmt.Sprintf("BUG: unknown type definition %T", def))
+}
+
+func (s *typeScope) resolveValType(ref ValTypeRef) (*ValType, error) {
+	if ref.Primitive != 0 {
+		return primitiveTypes[ref.Primitive], nil
+	}
+	t, err := s.typeAt(ref.Index)
+	if err != nil {
+		return nil, err
+	}
+	if vt, ok := t.(*ValType); ok {
+		return vt, nil
+	}
+	return nil, fmt.Errorf("type %d is not a value type", ref.Index)
+}
+
+func (s *typeScope) resolveOptionalValType(ref *ValTypeRef) (*ValType, error) {
+	if ref == nil {
+		return nil, nil
+	}
+	return s.resolveValType(*ref)
+}
+
+func (s *typeScope) resolveValTypeDef(def *ValTypeDef) (ret *ValType, err error) {
+	if def.Kind.IsPrimitive() {
+		return primitiveTypes[def.Kind], nil
+	}
+	ret = &ValType{Kind: def.Kind}
+	switch def.Kind {
+	case ValTypeKindRecord:
+		if ret.Fields, err = s.resolveFields(def.Fields); err != nil {
+			return nil, err
+		}
+	case ValTypeKindTuple:
+		ret.Fields = make([]Field, len(def.Types))
+		for i, t := range def.Types {
+			if ret.Fields[i].Type, err = s.resolveValType(t); err != nil {
+				return nil, err
+			}
+		}
+	case ValTypeKindVariant:
+		if len(def.Cases) == 0 {
+			return nil, fmt.Errorf("variant has no cases")
+		}
+		ret.Cases = make([]Case, len(def.Cases))
+		for i, c := range def.Cases {
+			ret.Cases[i].Name = c.Name
+			if ret.Cases[i].Type, err = s.resolveOptionalValType(c.Type); err != nil {
+				return nil, err
+			}
+		}
+	case ValTypeKindEnum:
+		if len(def.Labels) == 0 {
+			return nil, fmt.Errorf("enum has no cases")
+		}
+		ret.Cases = make([]Case, len(def.Labels))
+		for i, l := range def.Labels {
+			ret.Cases[i].Name = l
+		}
+	case ValTypeKindFlags:
+		if len(def.Labels) > 32 {
+			return nil, fmt.Errorf("flags has more than 32 labels")
+		}
+		ret.Labels = def.Labels
+	case ValTypeKindList:
+		if ret.Elem, err = s.resolveValType(def.Elem); err != nil {
+			return nil, err
+		}
+	case ValTypeKindOption:
+		var some *ValType
+		if some, err = s.resolveValType(def.Elem); err != nil {
+			return nil, err
+		}
+		ret.Cases = []Case{{Name: "none"}, {Name: "some", Type: some}}
+	case ValTypeKindResult:
+		ret.Cases = []Case{{Name: "ok"}, {Name: "error"}}
+		if ret.Cases[0].Type, err = s.resolveOptionalValType(def.Ok); err != nil {
+			return nil, err
+		}
+		if ret.Cases[1].Type, err = s.resolveOptionalValType(def.Err); err != nil {
+			return nil, err
+		}
+	case ValTypeKindOwn, ValTypeKindBorrow:
+		var t any
+		if t, err = s.typeAt(def.Resource); err != nil {
+			return nil, err
+		}
+		var ok bool
+		if ret.Resource, ok = t.(*ResourceType); !ok {
+			return nil, fmt.Errorf("type %d is not a resource type", def.Resource)
+		}
+	default:
+		panic(fmt.Sprintf("BUG: unknown value type kind %d", def.Kind))
+	}
+	return ret, nil
+}
+
+func (s *typeScope) resolveFields(defs []LabeledValType) (ret []Field, err error) {
+	ret = make([]Field, len(defs))
+	for i, f := range defs {
+		ret[i].Name = f.Name
+		if ret[i].Type, err = s.resolveValType(f.Type); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+func (s *typeScope) resolveFuncTypeDef(def *FuncTypeDef) (ret *FuncType, err error) {
+	ret = &FuncType{}
+	if ret.Params, err = s.resolveFields(def.Params); err != nil {
+		return nil, err
+	}
+	if ret.Results, err = s.resolveFields(def.Results); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// resolveInstanceTypeDef resolves an instance type. Each resource type it
+// exports is a new abstract type, which an import binds to the resource
+// type of the instance which satisfies it, with bindInstanceType.
+func (s *typeScope) resolveInstanceTypeDef(def *InstanceTypeDef) (*InstanceType, error) {
+	inner := &typeScope{parent: s}
+	ret := &InstanceType{Exports: map[string]any{}}
+	for i := range def.Decls {
+		d := &def.Decls[i]
+		switch d.Kind {
+		case DeclKindCoreType:
+		case DeclKindType:
+			t, err := inner.resolveTypeDef(d.Type)
+			if err != nil {
+				return nil, err
+			}
+			inner.types = append(inner.types, t)
+		case DeclKindAlias:
+			a := d.Alias
+			if a.Kind != AliasKindOuter || a.Sort != SortType {
+				return nil, fmt.Errorf("unsupported alias of %s in instance type", a.Sort)
+			}
+			t, err := inner.outer(a.Count, a.Index)
+			if err != nil {
+				return nil, err
+			}
+			inner.types = append(inner.types, t)
+		case DeclKindExport:
+			t, err := inner.resolveExternDesc(d.Name, d.Desc)
+			if err != nil {
+				return nil, fmt.Errorf("export %s: %w", d.Name, err)
+			}
+			if d.Desc.Sort == SortType {
+				inner.types = append(inner.types, t)
+			}
+			ret.Exports[d.Name] = t
+		}
+	}
+	return ret, nil
+}
+
+// resolveExternDesc resolves the type of an import or export.
+func (s *typeScope) resolveExternDesc(name string, desc ExternDesc) (any, error) {
+	switch desc.Sort {
+	case SortType:
+		if desc.SubResource {
+			return &ResourceType{Name: name, abstract: true}, nil
+		}
+		return s.typeAt(desc.Index)
+	case SortFunc:
+		t, err := s.typeAt(desc.Index)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := t.(*FuncType); !ok {
+			return nil, fmt.Errorf("type %d is not a function type", desc.Index)
+		}
+		return t, nil
+	case SortInstance:
+		t, err := s.typeAt(desc.Index)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := t.(*InstanceType); !ok {
+			return nil, fmt.Errorf("type %d is not an instance type", desc.Index)
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("%s is not supported", desc.Sort)
+}
+
+// bindInstanceType returns the instance type with its abstract resource
+// types replaced according to bound.
+func bindInstanceType(t *InstanceType, bound map[*ResourceType]*ResourceType) *InstanceType {
+	ret := &InstanceType{Exports: make(map[string]any, len(t.Exports))}
+	for name, e := range t.Exports {
+		ret.Exports[name] = bindType(e, bound)
+	}
+	return ret
+}
+
+func bindType(t any, bound map[*ResourceType]*ResourceType) any {
+	switch t := t.(type) {
+	case *ResourceType:
+		if b, ok := bound[t]; ok {
+			return b
+		}
+	case *ValType:
+		return bindValType(t, bound)
+	case *FuncType:
+		return &FuncType{Params: bindFields(t.Params, bound), Results: bindFields(t.Results, bound)}
+	case *InstanceType:
+		return bindInstanceType(t, bound)
+	}
+	return t
+}
+
+func bindValType(t *ValType, bound map[*ResourceType]*ResourceType) *ValType {
+	switch t.Kind {
+	case ValTypeKindRecord, ValTypeKindTuple:
+		return &ValType{Kind: t.Kind, Fields: bindFields(t.Fields, bound)}
+	case ValTypeKindVariant, ValTypeKindEnum, ValTypeKindOption, ValTypeKindResult:
+		cases := make([]Case, len(t.Cases))
+		for i, c := range t.Cases {
+			cases[i].Name = c.Name
+			if c.Type != nil {
+				cases[i].Type = bindValType(c.Type, bound)
+			}
+		}
+		return &ValType{Kind: t.Kind, Cases: cases}
+	case ValTypeKindList:
+		return &ValType{Kind: t.Kind, Elem: bindValType(t.Elem, bound)}
+	case ValTypeKindOwn, ValTypeKindBorrow:
+		if b, ok := bound[t.Resource]; ok {
+			return &ValType{Kind: t.Kind, Resource: b}
+		}
+	}
+	return t
+}
+
+func bindFields(fields []Field, bound map[*ResourceType]*ResourceType) []Field {
+	ret := make([]Field, len(fields))
+	for i, f := range fields {
+		ret[i] = Field{Name: f.Name, Type: bindValType(f.Type, bound)}
+	}
+	return ret
+}
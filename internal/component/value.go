@@ -0,0 +1,40 @@
+package component
+
+// Values are represented in Go according to their type:
+//
+//   - bool, s8...u64, f32, f64: bool, int8...uint64, float32, float64
+//   - char: rune
+//   - string: string
+//   - list<u8>: []byte
+//   - other lists, records and tuples: []any
+//   - variant, option and result: Variant
+//   - enum: uint32, the index of the case
+//   - flags: uint32, with bit i set if label i is
+//   - own and borrow: the representation of the resource, which is any value
+//     for resources implemented by the host, and uint32 otherwise.
+
+// Variant is the value of a variant, option or result.
+type Variant struct {
+	// Case is the index of the case, e.g. 0 for none or ok.
+	Case uint32
+	// Value is the payload, or nil if the case has none.
+	Value any
+}
+
+// None is the value of an empty option.
+var None = Variant{Case: 0}
+
+// Some returns the value of an option holding v.
+func Some(v any) Variant {
+	return Variant{Case: 1, Value: v}
+}
+
+// Ok returns the value of a successful result, with an optional payload.
+func Ok(v any) Variant {
+	return Variant{Case: 0, Value: v}
+}
+
+// Err returns the value of a failed result, with an optional payload.
+func Err(v any) Variant {
+	return Variant{Case: 1, Value: v}
+}
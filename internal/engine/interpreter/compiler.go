@@ -191,6 +191,10 @@ type compiler struct {
 	globals []wasm.GlobalType
 	// tags holds the type indexes for all declared tags in the module where the target function exists.
 	tags []uint32
+	// memories holds all the declared memories in the module where the target function exists.
+	memories []*wasm.Memory
+	// hasMemory64 is true if any of memories is a 64-bit memory.
+	hasMemory64 bool
 
 	// needSourceOffset is true if this module requires DWARF based stack trace.
 	needSourceOffset bool
@@ -198,6 +202,12 @@ type compiler struct {
 	bodyOffsetInCodeSection uint64
 
 	ensureTermination bool
+	// fuelMetering is true if the function entries, labels and returns from calls consume the fuel of the
+	// instructions until the next of them.
+	fuelMetering bool
+	// fuelCost is the index of the operationKindBuiltinFunctionConsumeFuel in result.Operations which the fuel of
+	// the current instruction is added to, or -1 if there is none as it is unreachable.
+	fuelCost int
 	// Pre-allocated bytes.Reader to be used in various places.
 	br             *bytes.Reader
 	funcTypeToSigs funcTypeToIRSignatures
@@ -282,8 +292,8 @@ type compilationResult struct {
 
 // newCompiler returns the new *compiler for the given parameters.
 // Use compiler.Next function to get compilation result per function.
-func newCompiler(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 int, module *wasm.Module, ensureTermination bool) (*compiler, error) {
-	functions, globals, mem, tables, tags, err := module.AllDeclarations()
+func newCompiler(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 int, module *wasm.Module, ensureTermination, fuelMetering bool) (*compiler, error) {
+	functions, globals, memories, tables, tags, err := module.AllDeclarations()
 	if err != nil {
 		return nil, err
 	}
@@ -292,13 +302,16 @@ func newCompiler(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 in
 		len(module.DataSection) > 0, len(module.ElementSection) > 0
 
 	var mt memoryType
-	switch {
-	case mem == nil:
-		mt = memoryTypeNone
-	case mem.IsShared:
-		mt = memoryTypeShared
-	default:
-		mt = memoryTypeStandard
+	var hasMemory64 bool
+	for _, mem := range memories {
+		hasMemory64 = hasMemory64 || mem.Is64
+		if mt != memoryTypeShared {
+			if mem.IsShared {
+				mt = memoryTypeShared
+			} else {
+				mt = memoryTypeStandard
+			}
+		}
 	}
 
 	types := module.TypeSection
@@ -321,8 +334,11 @@ func newCompiler(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 in
 		globals:           globals,
 		funcs:             functions,
 		tags:              tags,
+		memories:          memories,
+		hasMemory64:       hasMemory64,
 		types:             types,
 		ensureTermination: ensureTermination,
+		fuelMetering:      fuelMetering,
 		br:                bytes.NewReader(nil),
 		funcTypeToSigs: funcTypeToIRSignatures{
 			indirectCalls: make([]*signature, len(types)),
@@ -358,6 +374,7 @@ func (c *compiler) Next() (*compilationResult, error) {
 	c.currentFrameID = 0
 	c.stackLenInUint64 = 0
 	c.unreachableState.on, c.unreachableState.depth = false, 0
+	c.fuelCost = -1
 
 	if err := c.compile(sig, code.Body, code.LocalTypes, code.BodyOffsetInCodeSection); err != nil {
 		return nil, err
@@ -397,6 +414,11 @@ func (c *compiler) compile(sig *wasm.FunctionType, body []byte, localTypes []was
 		kind:      controlFrameKindFunction,
 	})
 
+	// Entering the function consumes the fuel of the instructions until the next label.
+	if c.fuelMetering {
+		c.emitConsumeFuel()
+	}
+
 	// Now, enter the function body.
 	for !c.controlFrames.empty() && c.pc < uint64(len(c.body)) {
 		if err := c.handleInstruction(); err != nil {
@@ -427,6 +449,10 @@ func (c *compiler) handleInstruction() error {
 		)
 	}
 
+	if c.fuelMetering && !c.unreachableState.on && c.fuelCost >= 0 {
+		c.result.Operations[c.fuelCost].U1++
+	}
+
 	var peekValueType unsignedType
 	if len(c.stack) > 0 {
 		peekValueType = c.stackPeek()
@@ -1153,15 +1179,21 @@ operatorSwitch:
 		)
 	case wasm.OpcodeMemorySize:
 		c.result.UsesMemory = true
-		c.pc++ // Skip the reserved one byte.
+		memoryIndex, err := c.readMemoryIndex()
+		if err != nil {
+			return err
+		}
 		c.emit(
-			newOperationMemorySize(),
+			newOperationMemorySize(memoryIndex),
 		)
 	case wasm.OpcodeMemoryGrow:
 		c.result.UsesMemory = true
-		c.pc++ // Skip the reserved one byte.
+		memoryIndex, err := c.readMemoryIndex()
+		if err != nil {
+			return err
+		}
 		c.emit(
-			newOperationMemoryGrow(),
+			newOperationMemoryGrow(memoryIndex),
 		)
 	case wasm.OpcodeI32Const:
 		val, num, err := leb128.LoadInt32(c.body[c.pc+1:])
@@ -1718,7 +1750,9 @@ operatorSwitch:
 	case wasm.OpcodeRefNull:
 		c.pc++
 		switch reftype := c.body[c.pc]; wasm.ValueType(reftype) {
-		case wasm.ValueTypeFuncref, wasm.ValueTypeExternref, wasm.ValueTypeExnref:
+		case wasm.ValueTypeFuncref, wasm.ValueTypeExternref, wasm.ValueTypeExnref,
+			wasm.ValueTypeAnyref, wasm.ValueTypeEqref, wasm.ValueTypeI31ref, wasm.ValueTypeStructref, wasm.ValueTypeArrayref,
+			wasm.ValueTypeNullref, wasm.ValueTypeNullfuncref, wasm.ValueTypeNullexternref, wasm.ValueTypeNullexnref:
 			// Abstract ref types are a single byte; already skipped.
 		default:
 			// Concrete type index encoded as LEB128; skip it.
@@ -1736,6 +1770,14 @@ operatorSwitch:
 		c.emit(
 			newOperationEqz(unsignedInt64),
 		)
+	case wasm.OpcodeRefEq:
+		c.emit(
+			newOperationRefEq(),
+		)
+	case wasm.OpcodeGCPrefix:
+		if err := c.handleGCInstruction(); err != nil {
+			return err
+		}
 	case wasm.OpcodeTableGet:
 		c.pc++
 		tableIndex, num, err := leb128.LoadUint32(c.body[c.pc:])
@@ -1803,9 +1845,13 @@ operatorSwitch:
 			if err != nil {
 				return fmt.Errorf("reading i32.const value: %v", err)
 			}
-			c.pc += num + 1 // +1 to skip the memory index which is fixed to zero.
+			c.pc += num
+			memoryIndex, err := c.readMemoryIndex()
+			if err != nil {
+				return err
+			}
 			c.emit(
-				newOperationMemoryInit(dataIndex),
+				newOperationMemoryInit(dataIndex, memoryIndex),
 			)
 		case wasm.OpcodeMiscDataDrop:
 			dataIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
@@ -1818,15 +1864,25 @@ operatorSwitch:
 			)
 		case wasm.OpcodeMiscMemoryCopy:
 			c.result.UsesMemory = true
-			c.pc += 2 // +2 to skip two memory indexes which are fixed to zero.
+			dstMemoryIndex, err := c.readMemoryIndex()
+			if err != nil {
+				return err
+			}
+			srcMemoryIndex, err := c.readMemoryIndex()
+			if err != nil {
+				return err
+			}
 			c.emit(
-				newOperationMemoryCopy(),
+				newOperationMemoryCopy(dstMemoryIndex, srcMemoryIndex),
 			)
 		case wasm.OpcodeMiscMemoryFill:
 			c.result.UsesMemory = true
-			c.pc += 1 // +1 to skip the memory index which is fixed to zero.
+			memoryIndex, err := c.readMemoryIndex()
+			if err != nil {
+				return err
+			}
 			c.emit(
-				newOperationMemoryFill(),
+				newOperationMemoryFill(memoryIndex),
 			)
 		case wasm.OpcodeMiscTableInit:
 			elemIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
@@ -1903,6 +1959,14 @@ operatorSwitch:
 		}
 	case wasm.OpcodeVecPrefix:
 		c.pc++
+		if relaxedOp, ok := wasm.RelaxedVectorOpcode(c.body[c.pc:]); ok {
+			// Relaxed SIMD opcodes are encoded in two bytes.
+			c.pc++
+			if err := c.emitRelaxedVectorInstruction(relaxedOp); err != nil {
+				return err
+			}
+			break operatorSwitch
+		}
 		switch vecOp := c.body[c.pc]; vecOp {
 		case wasm.OpcodeVecV128Const:
 			c.pc++
@@ -3537,20 +3601,9 @@ operatorSwitch:
 		}
 
 	case wasm.OpcodeTailCallReturnCall:
-		fdef := c.module.FunctionDefinition(index)
 		functionFrame := c.controlFrames.functionFrame()
-		// Currently we do not support imported functions, we treat them as regular calls.
-		// For details, see internal/engine/RATIONALE.md
-		if _, _, isImport := fdef.Import(); isImport {
-			c.emit(newOperationCall(index))
-			dropOp := newOperationDrop(c.getFrameDropRange(functionFrame, false))
-
-			// Cleanup the stack and then jmp to function frame's continuation (meaning return).
-			c.emit(dropOp)
-			c.emit(newOperationBr(functionFrame.asLabel()))
-		} else {
-			c.emit(newOperationTailCallReturnCall(index))
-		}
+		dropRange := c.getFrameDropRange(functionFrame, false)
+		c.emit(newOperationTailCallReturnCall(index, dropRange, functionFrame.asLabel()))
 
 		// Return operation is stack-polymorphic, and mark the state as unreachable.
 		// That means subsequent instructions in the current control frame are "unreachable"
@@ -3636,11 +3689,259 @@ operatorSwitch:
 		return fmt.Errorf("unsupported instruction in interpreterir: 0x%x", op)
 	}
 
+	if c.fuelMetering {
+		switch {
+		case c.unreachableState.on:
+			c.fuelCost = -1
+		case op == wasm.OpcodeCall || op == wasm.OpcodeCallIndirect || op == wasm.OpcodeCallRef:
+			// The instructions after the call consume fuel once it returns, so that Go functions see the
+			// fuel left by the instructions executed so far.
+			c.emitConsumeFuel()
+		}
+	}
+
 	// Move the program counter to point to the next instruction.
 	c.pc++
 	return nil
 }
 
+// handleGCInstruction handles the instruction of the GC proposal following the prefix at c.pc,
+// and leaves c.pc at its last byte.
+func (c *compiler) handleGCInstruction() error {
+	c.pc++
+	gcOp, num, err := leb128.LoadUint32(c.body[c.pc:])
+	if err != nil {
+		return fmt.Errorf("failed to read gc opcode: %v", err)
+	}
+	c.pc += num - 1
+	name := wasm.GCInstructionName(gcOp)
+
+	// Read the immediates first, as they must be skipped even when unreachable.
+	var imms [2]uint32
+	var flags byte
+	var heapTypes [2]wasm.ValueType
+	readIndex := func() (uint32, error) {
+		c.pc++
+		v, num, err := leb128.LoadUint32(c.body[c.pc:])
+		if err != nil {
+			return 0, fmt.Errorf("failed to read immediate for %s: %v", name, err)
+		}
+		c.pc += num - 1
+		return v, nil
+	}
+	readHeapType := func(nullable bool) (wasm.ValueType, error) {
+		c.br.Reset(c.body[c.pc+1:])
+		ht, num, err := leb128.DecodeInt33AsInt64(c.br)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read heap type for %s: %v", name, err)
+		}
+		c.pc += num
+		if vt, ok := wasm.AbstractRefType(ht); ok {
+			if !nullable {
+				vt = vt.AsNonNullable()
+			}
+			return vt, nil
+		}
+		return wasm.ValueTypeConcreteRef(uint32(ht), nullable), nil
+	}
+	switch gcOp {
+	case wasm.OpcodeGCStructNew, wasm.OpcodeGCStructNewDefault, wasm.OpcodeGCArrayNew, wasm.OpcodeGCArrayNewDefault,
+		wasm.OpcodeGCArrayGet, wasm.OpcodeGCArrayGetS, wasm.OpcodeGCArrayGetU, wasm.OpcodeGCArraySet, wasm.OpcodeGCArrayFill:
+		if imms[0], err = readIndex(); err != nil {
+			return err
+		}
+	case wasm.OpcodeGCStructGet, wasm.OpcodeGCStructGetS, wasm.OpcodeGCStructGetU, wasm.OpcodeGCStructSet,
+		wasm.OpcodeGCArrayNewFixed, wasm.OpcodeGCArrayNewData, wasm.OpcodeGCArrayNewElem,
+		wasm.OpcodeGCArrayInitData, wasm.OpcodeGCArrayInitElem, wasm.OpcodeGCArrayCopy:
+		if imms[0], err = readIndex(); err != nil {
+			return err
+		}
+		if imms[1], err = readIndex(); err != nil {
+			return err
+		}
+	case wasm.OpcodeGCRefTest, wasm.OpcodeGCRefTestNull, wasm.OpcodeGCRefCast, wasm.OpcodeGCRefCastNull:
+		if heapTypes[0], err = readHeapType(gcOp == wasm.OpcodeGCRefTestNull || gcOp == wasm.OpcodeGCRefCastNull); err != nil {
+			return err
+		}
+	case wasm.OpcodeGCBrOnCast, wasm.OpcodeGCBrOnCastFail:
+		c.pc++
+		flags = c.body[c.pc]
+		if imms[0], err = readIndex(); err != nil {
+			return err
+		}
+		if heapTypes[0], err = readHeapType(flags&1 != 0); err != nil {
+			return err
+		}
+		if heapTypes[1], err = readHeapType(flags&2 != 0); err != nil {
+			return err
+		}
+	}
+
+	if c.unreachableState.on {
+		return nil
+	}
+
+	// popN pops n values, which are the operands of the instruction and so are checked by the validation.
+	popN := func(n int) {
+		for i := 0; i < n; i++ {
+			c.stackPop()
+		}
+	}
+	switch gcOp {
+	case wasm.OpcodeGCStructNew, wasm.OpcodeGCStructNewDefault:
+		tp := &c.types[imms[0]]
+		isDefault := gcOp == wasm.OpcodeGCStructNewDefault
+		if !isDefault {
+			popN(len(tp.Fields))
+		}
+		c.emit(newOperationGCStructNew(imms[0], tp.StructSlots(), isDefault))
+		c.stackPush(unsignedTypeI64)
+	case wasm.OpcodeGCStructGet, wasm.OpcodeGCStructGetS, wasm.OpcodeGCStructGetU:
+		tp := &c.types[imms[0]]
+		field := tp.Fields[imms[1]]
+		c.stackPop()
+		c.emit(newOperationGCStructGet(tp.StructFieldSlot(imms[1]), gcStorageOf(field.Type), gcOp == wasm.OpcodeGCStructGetS))
+		c.stackPush(wasmValueTypeTounsignedType(field.Unpacked()))
+	case wasm.OpcodeGCStructSet:
+		tp := &c.types[imms[0]]
+		field := tp.Fields[imms[1]]
+		popN(2)
+		c.emit(newOperationGCStructSet(tp.StructFieldSlot(imms[1]), gcStorageOf(field.Type)))
+	case wasm.OpcodeGCArrayNew, wasm.OpcodeGCArrayNewDefault, wasm.OpcodeGCArrayNewFixed:
+		elem := c.types[imms[0]].Fields[0]
+		switch gcOp {
+		case wasm.OpcodeGCArrayNew:
+			popN(2)
+		case wasm.OpcodeGCArrayNewDefault:
+			popN(1)
+		default:
+			popN(int(imms[1]))
+		}
+		c.emit(newOperationGCArrayNew(imms[0], gcStorageOf(elem.Type), gcOp, imms[1]))
+		c.stackPush(unsignedTypeI64)
+	case wasm.OpcodeGCArrayNewData:
+		popN(2)
+		c.emit(newOperationGCArrayNewData(imms[0], imms[1], c.types[imms[0]].Fields[0].Type))
+		c.stackPush(unsignedTypeI64)
+	case wasm.OpcodeGCArrayNewElem:
+		popN(2)
+		c.emit(newOperationGCArrayNewElem(imms[0], imms[1]))
+		c.stackPush(unsignedTypeI64)
+	case wasm.OpcodeGCArrayInitData:
+		popN(4)
+		c.emit(newOperationGCArrayInitData(imms[1], c.types[imms[0]].Fields[0].Type))
+	case wasm.OpcodeGCArrayInitElem:
+		popN(4)
+		c.emit(newOperationGCArrayInitElem(imms[1]))
+	case wasm.OpcodeGCArrayGet, wasm.OpcodeGCArrayGetS, wasm.OpcodeGCArrayGetU:
+		elem := c.types[imms[0]].Fields[0]
+		popN(2)
+		c.emit(newOperationGCArrayGet(gcStorageOf(elem.Type), gcOp == wasm.OpcodeGCArrayGetS))
+		c.stackPush(wasmValueTypeTounsignedType(elem.Unpacked()))
+	case wasm.OpcodeGCArraySet:
+		popN(3)
+		c.emit(newOperationGCArraySet(gcStorageOf(c.types[imms[0]].Fields[0].Type)))
+	case wasm.OpcodeGCArrayLen:
+		c.stackPop()
+		c.emit(newOperationGCArrayLen())
+		c.stackPush(unsignedTypeI32)
+	case wasm.OpcodeGCArrayFill:
+		popN(4)
+		c.emit(newOperationGCArrayFill(gcStorageOf(c.types[imms[0]].Fields[0].Type)))
+	case wasm.OpcodeGCArrayCopy:
+		popN(5)
+		c.emit(newOperationGCArrayCopy(gcStorageOf(c.types[imms[0]].Fields[0].Type)))
+	case wasm.OpcodeGCRefTest, wasm.OpcodeGCRefTestNull, wasm.OpcodeGCRefCast, wasm.OpcodeGCRefCastNull:
+		cast := gcOp == wasm.OpcodeGCRefCast || gcOp == wasm.OpcodeGCRefCastNull
+		c.stackPop()
+		c.emit(newOperationGCRefTest(heapTypes[0], c.isConcreteFuncType(heapTypes[0]), cast))
+		if cast {
+			c.stackPush(unsignedTypeI64)
+		} else {
+			c.stackPush(unsignedTypeI32)
+		}
+	case wasm.OpcodeGCBrOnCast, wasm.OpcodeGCBrOnCastFail:
+		// The reference stays on the stack, both for the branch target and the fall-through.
+		targetFrame := c.controlFrames.get(int(imms[0]))
+		targetFrame.ensureContinuation()
+		drop := c.getFrameDropRange(targetFrame, false)
+		target := targetFrame.asLabel()
+		c.result.LabelCallers[target]++
+
+		continuationLabel := newLabel(labelKindHeader, c.nextFrameID())
+		c.result.LabelCallers[continuationLabel]++
+		c.emit(newOperationGCBrOnCast(target, continuationLabel, drop, heapTypes[1],
+			c.isConcreteFuncType(heapTypes[1]), gcOp == wasm.OpcodeGCBrOnCastFail))
+		c.emit(newOperationLabel(continuationLabel))
+	case wasm.OpcodeGCAnyConvertExtern:
+		c.emit(newOperationGCAnyConvertExtern())
+	case wasm.OpcodeGCExternConvertAny:
+		c.emit(newOperationGCExternConvertAny())
+	case wasm.OpcodeGCRefI31:
+		c.stackPop()
+		c.emit(newOperationGCRefI31())
+		c.stackPush(unsignedTypeI64)
+	case wasm.OpcodeGCI31GetS, wasm.OpcodeGCI31GetU:
+		c.stackPop()
+		c.emit(newOperationGCI31Get(gcOp == wasm.OpcodeGCI31GetS))
+		c.stackPush(unsignedTypeI32)
+	default:
+		return fmt.Errorf("unsupported gc instruction in interpreterir: %s", name)
+	}
+	return nil
+}
+
+// isConcreteFuncType returns true if t is a reference to a concrete function type, whose values
+// are function references rather than objects of the GC heap.
+func (c *compiler) isConcreteFuncType(t wasm.ValueType) bool {
+	return t.IsConcreteRef() && c.types[t.TypeIndex()].IsFunc()
+}
+
+// emitRelaxedVectorInstruction emits the operations for the relaxed SIMD instruction relaxedOp.
+// Most of them are lowered to their deterministic counterparts, which are valid relaxed results.
+func (c *compiler) emitRelaxedVectorInstruction(relaxedOp wasm.OpcodeVecRelaxed) error {
+	switch relaxedOp {
+	case wasm.OpcodeVecI8x16RelaxedSwizzle:
+		c.emit(newOperationV128Swizzle())
+	case wasm.OpcodeVecI32x4RelaxedTruncF32x4S:
+		c.emit(newOperationV128ITruncSatFromF(shapeF32x4, true))
+	case wasm.OpcodeVecI32x4RelaxedTruncF32x4U:
+		c.emit(newOperationV128ITruncSatFromF(shapeF32x4, false))
+	case wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero:
+		c.emit(newOperationV128ITruncSatFromF(shapeF64x2, true))
+	case wasm.OpcodeVecI32x4RelaxedTruncF64x2UZero:
+		c.emit(newOperationV128ITruncSatFromF(shapeF64x2, false))
+	case wasm.OpcodeVecF32x4RelaxedMadd:
+		c.emit(newOperationV128RelaxedMadd(shapeF32x4, false))
+	case wasm.OpcodeVecF32x4RelaxedNmadd:
+		c.emit(newOperationV128RelaxedMadd(shapeF32x4, true))
+	case wasm.OpcodeVecF64x2RelaxedMadd:
+		c.emit(newOperationV128RelaxedMadd(shapeF64x2, false))
+	case wasm.OpcodeVecF64x2RelaxedNmadd:
+		c.emit(newOperationV128RelaxedMadd(shapeF64x2, true))
+	case wasm.OpcodeVecI8x16RelaxedLaneselect, wasm.OpcodeVecI16x8RelaxedLaneselect,
+		wasm.OpcodeVecI32x4RelaxedLaneselect, wasm.OpcodeVecI64x2RelaxedLaneselect:
+		c.emit(newOperationV128Bitselect())
+	case wasm.OpcodeVecF32x4RelaxedMin:
+		c.emit(newOperationV128Min(shapeF32x4, false))
+	case wasm.OpcodeVecF32x4RelaxedMax:
+		c.emit(newOperationV128Max(shapeF32x4, false))
+	case wasm.OpcodeVecF64x2RelaxedMin:
+		c.emit(newOperationV128Min(shapeF64x2, false))
+	case wasm.OpcodeVecF64x2RelaxedMax:
+		c.emit(newOperationV128Max(shapeF64x2, false))
+	case wasm.OpcodeVecI16x8RelaxedQ15mulrS:
+		c.emit(newOperationV128Q15mulrSatS())
+	case wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S:
+		c.emit(newOperationV128RelaxedDot(false))
+	case wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS:
+		c.emit(newOperationV128RelaxedDot(true))
+	default:
+		return fmt.Errorf("unsupported vector instruction in interpreterir: %#x", relaxedOp)
+	}
+	return nil
+}
+
 func (c *compiler) nextFrameID() (id uint32) {
 	id = c.currentFrameID + 1
 	c.currentFrameID++
@@ -3690,6 +3991,11 @@ func (c *compiler) applyToStack(opcode wasm.Opcode) (index uint32, err error) {
 	if err != nil {
 		return 0, err
 	}
+	if c.hasMemory64 {
+		if s, err = c.memory64Signature(opcode, s); err != nil {
+			return 0, err
+		}
+	}
 
 	// Manipulate the stack according to the signature.
 	// Note that the following algorithm assumes that
@@ -3770,9 +4076,20 @@ func (c *compiler) emit(op unionOperation) {
 			c.result.IROperationSourceOffsetsInWasmBinary = append(c.result.IROperationSourceOffsetsInWasmBinary,
 				c.currentOpPC+c.bodyOffsetInCodeSection)
 		}
+		// Each label starts a block which consumes the fuel of its instructions on entry.
+		if op.Kind == operationKindLabel && c.fuelMetering {
+			c.emitConsumeFuel()
+		}
 	}
 }
 
+// emitConsumeFuel emits the operation consuming the fuel of the instructions handled next, which is counted by
+// handleInstruction until the next label or call.
+func (c *compiler) emitConsumeFuel() {
+	c.fuelCost = len(c.result.Operations)
+	c.emit(newOperationBuiltinFunctionConsumeFuel())
+}
+
 // Emit const expression with default values of the given type.
 func (c *compiler) emitDefaultValue(t wasm.ValueType) {
 	switch t {
@@ -3848,12 +4165,39 @@ func (c *compiler) readMemoryArg(tag string) (memoryArg, error) {
 		return memoryArg{}, fmt.Errorf("reading alignment for %s: %w", tag, err)
 	}
 	c.pc += num
-	offset, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+	var memoryIndex uint32
+	if alignment&wasm.MemArgMemoryIndexFlag != 0 {
+		alignment &^= wasm.MemArgMemoryIndexFlag
+		memoryIndex, num, err = leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return memoryArg{}, fmt.Errorf("reading memory index for %s: %w", tag, err)
+		}
+		c.pc += num
+	}
+	memory64 := int(memoryIndex) < len(c.memories) && c.memories[memoryIndex].Is64
+	var offset uint64
+	if memory64 {
+		offset, num, err = leb128.LoadUint64(c.body[c.pc+1:])
+	} else {
+		var offset32 uint32
+		offset32, num, err = leb128.LoadUint32(c.body[c.pc+1:])
+		offset = uint64(offset32)
+	}
 	if err != nil {
 		return memoryArg{}, fmt.Errorf("reading offset for %s: %w", tag, err)
 	}
 	c.pc += num
-	return memoryArg{Offset: offset, Alignment: alignment}, nil
+	return memoryArg{Offset: offset, Alignment: alignment, MemoryIndex: memoryIndex, Memory64: memory64}, nil
+}
+
+// readMemoryIndex reads the memory index immediate of memory.size, memory.grow and bulk memory instructions.
+func (c *compiler) readMemoryIndex() (uint32, error) {
+	memoryIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+	if err != nil {
+		return 0, fmt.Errorf("reading memory index: %w", err)
+	}
+	c.pc += num
+	return memoryIndex, nil
 }
 
 // parseCatchClause parses a single catch clause from the bytecode at c.pc,
@@ -157,7 +157,7 @@ func TestCompile(t *testing.T) {
 			expected: &compilationResult{
 				Operations: []unionOperation{ // begin with params: [$delta]
 					newOperationPick(0, false),                         // [$delta, $delta]
-					newOperationMemoryGrow(),                           // [$delta, $old_size]
+					newOperationMemoryGrow(0),                          // [$delta, $old_size]
 					newOperationDrop(inclusiveRange{Start: 1, End: 1}), // [$old_size]
 					newOperationBr(newLabel(labelKindReturn, 0)),       // return!
 				},
@@ -184,7 +184,7 @@ func TestCompile(t *testing.T) {
 			for _, tp := range tc.module.TypeSection {
 				tp.CacheNumInUint64()
 			}
-			c, err := newCompiler(enabledFeatures, 0, tc.module, false)
+			c, err := newCompiler(enabledFeatures, 0, tc.module, false, false)
 			require.NoError(t, err)
 
 			fn, err := c.Next()
@@ -267,7 +267,7 @@ func TestCompile_BulkMemoryOperations(t *testing.T) {
 	module := &wasm.Module{
 		TypeSection:     []wasm.FunctionType{v_v},
 		FunctionSection: []wasm.Index{0},
-		MemorySection:   &wasm.Memory{Min: 1},
+		MemorySection:   []wasm.Memory{{Min: 1}},
 		DataSection: []wasm.DataSegment{
 			{
 				OffsetExpression: wasm.NewConstantExpressionFromI32(0),
@@ -294,7 +294,7 @@ func TestCompile_BulkMemoryOperations(t *testing.T) {
 			newOperationConstI32(16),                     // [16]
 			newOperationConstI32(0),                      // [16, 0]
 			newOperationConstI32(7),                      // [16, 0, 7]
-			newOperationMemoryInit(1),                    // []
+			newOperationMemoryInit(1, 0),                 // []
 			newOperationDataDrop(1),                      // []
 			newOperationBr(newLabel(labelKindReturn, 0)), // return!
 		},
@@ -306,7 +306,7 @@ func TestCompile_BulkMemoryOperations(t *testing.T) {
 		Types:            []wasm.FunctionType{v_v},
 	}
 
-	c, err := newCompiler(api.CoreFeatureBulkMemoryOperations, 0, module, false)
+	c, err := newCompiler(api.CoreFeatureBulkMemoryOperations, 0, module, false, false)
 	require.NoError(t, err)
 
 	actual, err := c.Next()
@@ -590,7 +590,7 @@ func TestCompile_MultiValue(t *testing.T) {
 			for _, tp := range tc.module.TypeSection {
 				tp.CacheNumInUint64()
 			}
-			c, err := newCompiler(enabledFeatures, 0, tc.module, false)
+			c, err := newCompiler(enabledFeatures, 0, tc.module, false, false)
 			require.NoError(t, err)
 
 			actual, err := c.Next()
@@ -626,7 +626,7 @@ func TestCompile_NonTrappingFloatToIntConversion(t *testing.T) {
 		Functions:    []wasm.Index{0},
 		Types:        []wasm.FunctionType{f32_i32},
 	}
-	c, err := newCompiler(api.CoreFeatureNonTrappingFloatToIntConversion, 0, module, false)
+	c, err := newCompiler(api.CoreFeatureNonTrappingFloatToIntConversion, 0, module, false, false)
 	require.NoError(t, err)
 
 	actual, err := c.Next()
@@ -655,7 +655,7 @@ func TestCompile_SignExtensionOps(t *testing.T) {
 		Functions:    []wasm.Index{0},
 		Types:        []wasm.FunctionType{i32_i32},
 	}
-	c, err := newCompiler(api.CoreFeatureSignExtensionOps, 0, module, false)
+	c, err := newCompiler(api.CoreFeatureSignExtensionOps, 0, module, false, false)
 	require.NoError(t, err)
 
 	actual, err := c.Next()
@@ -667,7 +667,7 @@ func requireCompilationResult(t *testing.T, enabledFeatures api.CoreFeatures, ex
 	if enabledFeatures == 0 {
 		enabledFeatures = api.CoreFeaturesV2
 	}
-	c, err := newCompiler(enabledFeatures, 0, module, false)
+	c, err := newCompiler(enabledFeatures, 0, module, false, false)
 	require.NoError(t, err)
 
 	actual, err := c.Next()
@@ -710,7 +710,7 @@ func TestCompile_CallIndirectNonZeroTableIndex(t *testing.T) {
 		Types:        []wasm.FunctionType{v_v, v_v, v_v},
 	}
 
-	c, err := newCompiler(api.CoreFeatureBulkMemoryOperations, 0, module, false)
+	c, err := newCompiler(api.CoreFeatureBulkMemoryOperations, 0, module, false, false)
 	require.NoError(t, err)
 
 	actual, err := c.Next()
@@ -803,7 +803,7 @@ func TestCompile_Refs(t *testing.T) {
 				FunctionSection: []wasm.Index{0},
 				CodeSection:     []wasm.Code{{Body: tc.body}},
 			}
-			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false)
+			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false, false)
 			require.NoError(t, err)
 
 			actual, err := c.Next()
@@ -875,7 +875,7 @@ func TestCompile_TableGetOrSet(t *testing.T) {
 				CodeSection:     []wasm.Code{{Body: tc.body}},
 				TableSection:    []wasm.Table{{}},
 			}
-			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false)
+			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false, false)
 			require.NoError(t, err)
 
 			actual, err := c.Next()
@@ -947,7 +947,7 @@ func TestCompile_TableGrowFillSize(t *testing.T) {
 				CodeSection:     []wasm.Code{{Body: tc.body}},
 				TableSection:    []wasm.Table{{}},
 			}
-			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false)
+			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false, false)
 			require.NoError(t, err)
 
 			actual, err := c.Next()
@@ -1158,7 +1158,7 @@ func TestCompile_Locals(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			c, err := newCompiler(api.CoreFeaturesV2, 0, tc.mod, false)
+			c, err := newCompiler(api.CoreFeaturesV2, 0, tc.mod, false, false)
 			require.NoError(t, err)
 
 			actual, err := c.Next()
@@ -2638,10 +2638,10 @@ func TestCompile_Vec(t *testing.T) {
 			module := &wasm.Module{
 				TypeSection:     []wasm.FunctionType{v_v},
 				FunctionSection: []wasm.Index{0},
-				MemorySection:   &wasm.Memory{},
+				MemorySection:   []wasm.Memory{{}},
 				CodeSection:     []wasm.Code{{Body: tc.body}},
 			}
-			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false)
+			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false, false)
 			require.NoError(t, err)
 
 			res, err := c.Next()
@@ -2721,7 +2721,7 @@ func TestCompile_unreachable_Br_BrIf_BrTable(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			c, err := newCompiler(api.CoreFeaturesV2, 0, tc.mod, false)
+			c, err := newCompiler(api.CoreFeaturesV2, 0, tc.mod, false, false)
 			require.NoError(t, err)
 
 			actual, err := c.Next()
@@ -2764,7 +2764,7 @@ func TestCompile_drop_vectors(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			c, err := newCompiler(api.CoreFeaturesV2, 0, tc.mod, false)
+			c, err := newCompiler(api.CoreFeaturesV2, 0, tc.mod, false, false)
 			require.NoError(t, err)
 
 			actual, err := c.Next()
@@ -2837,7 +2837,7 @@ func TestCompile_select_vectors(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			c, err := newCompiler(api.CoreFeaturesV2, 0, tc.mod, false)
+			c, err := newCompiler(api.CoreFeaturesV2, 0, tc.mod, false, false)
 			require.NoError(t, err)
 
 			actual, err := c.Next()
@@ -3059,7 +3059,7 @@ func Test_ensureTermination(t *testing.T) {
 					},
 				}},
 			}
-			c, err := newCompiler(api.CoreFeaturesV2, 0, mod, tc.ensureTermination)
+			c, err := newCompiler(api.CoreFeaturesV2, 0, mod, tc.ensureTermination, false)
 			require.NoError(t, err)
 
 			actual, err := c.Next()
@@ -3069,6 +3069,36 @@ func Test_ensureTermination(t *testing.T) {
 	}
 }
 
+func Test_fuelMetering(t *testing.T) {
+	mod := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{v_v},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{{
+			Body: []byte{
+				wasm.OpcodeLoop, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeBrIf, 0, wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			},
+		}},
+	}
+	c, err := newCompiler(api.CoreFeaturesV2, 0, mod, true, true)
+	require.NoError(t, err)
+
+	actual, err := c.Next()
+	require.NoError(t, err)
+	require.Equal(t, `.entrypoint
+	BuiltinFunctionConsumeFuel 1
+	Br .L2
+.L2
+	BuiltinFunctionConsumeFuel 2
+	BuiltinFunctionCheckExitCode
+	ConstI32 0x1
+	BrIf .L2, .L3
+.L3
+	BuiltinFunctionConsumeFuel 2
+	Br .return
+`, format(actual.Operations))
+}
+
 func TestCompiler_threads(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -3700,10 +3730,10 @@ func TestCompiler_threads(t *testing.T) {
 			module := &wasm.Module{
 				TypeSection:     []wasm.FunctionType{v_v},
 				FunctionSection: []wasm.Index{0},
-				MemorySection:   &wasm.Memory{},
+				MemorySection:   []wasm.Memory{{}},
 				CodeSection:     []wasm.Code{{Body: body}},
 			}
-			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false)
+			c, err := newCompiler(api.CoreFeaturesV2, 0, module, false, false)
 			require.NoError(t, err)
 
 			res, err := c.Next()
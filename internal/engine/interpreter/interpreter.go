@@ -31,6 +31,8 @@ var callStackCeiling = 2000
 type compiledFunctionWithCount struct {
 	funcs    []compiledFunction
 	refCount int
+	// tier is non-nil if the functions of the module are compiled by engine.tier when they get hot.
+	tier *tieredModule
 }
 
 // engine is an interpreter implementation of wasm.Engine
@@ -38,6 +40,10 @@ type engine struct {
 	enabledFeatures   api.CoreFeatures
 	compiledFunctions map[wasm.ModuleID]*compiledFunctionWithCount // guarded by mutex.
 	mux               sync.Mutex
+	// tier is non-nil if this is created by NewTieredEngine.
+	tier Tier
+	// tierCompilations is non-nil if tier is.
+	tierCompilations *tieredCompilations
 }
 
 func NewEngine(_ context.Context, enabledFeatures api.CoreFeatures, _ filecache.Cache) wasm.Engine {
@@ -49,9 +55,15 @@ func NewEngine(_ context.Context, enabledFeatures api.CoreFeatures, _ filecache.
 
 // Close implements the same method as documented on wasm.Engine.
 func (e *engine) Close() (err error) {
+	if e.tierCompilations != nil {
+		e.tierCompilations.close()
+	}
 	e.mux.Lock()
 	defer e.mux.Unlock()
 	clear(e.compiledFunctions)
+	if e.tier != nil {
+		err = e.tier.Close()
+	}
 	return
 }
 
@@ -79,16 +91,19 @@ func (e *engine) deleteCompiledFunctions(module *wasm.Module) {
 		return
 	}
 	delete(e.compiledFunctions, module.ID)
+	if cf.tier != nil {
+		cf.tier.delete()
+	}
 }
 
-func (e *engine) addCompiledFunctions(module *wasm.Module, fs []compiledFunction) {
+func (e *engine) addCompiledFunctions(module *wasm.Module, fs []compiledFunction, tier *tieredModule) {
 	e.mux.Lock()
 	defer e.mux.Unlock()
 	if c, ok := e.compiledFunctions[module.ID]; ok {
 		c.refCount++
 		return
 	}
-	e.compiledFunctions[module.ID] = &compiledFunctionWithCount{funcs: fs, refCount: 1}
+	e.compiledFunctions[module.ID] = &compiledFunctionWithCount{funcs: fs, refCount: 1, tier: tier}
 }
 
 func (e *engine) getCompiledFunctions(module *wasm.Module, increaseRefCount bool) (fs []compiledFunction, ok bool) {
@@ -104,6 +119,15 @@ func (e *engine) getCompiledFunctions(module *wasm.Module, increaseRefCount bool
 	return
 }
 
+func (e *engine) getTieredModule(module *wasm.Module) *tieredModule {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if cf, ok := e.compiledFunctions[module.ID]; ok {
+		return cf.tier
+	}
+	return nil
+}
+
 // moduleEngine implements wasm.ModuleEngine
 type moduleEngine struct {
 	// codes are the compiled functions in a module instances.
@@ -112,6 +136,9 @@ type moduleEngine struct {
 
 	// parentEngine holds *engine from which this module engine is created from.
 	parentEngine *engine
+
+	// tier is non-nil if the functions are compiled when they get hot. See NewTieredEngine.
+	tier *tieredInstance
 }
 
 // GetGlobalValue implements the same method as documented on wasm.ModuleEngine.
@@ -128,7 +155,13 @@ func (e *moduleEngine) SetGlobalValue(idx wasm.Index, lo, hi uint64) {
 func (e *moduleEngine) OwnsGlobals() bool { return false }
 
 // MemoryGrown implements wasm.ModuleEngine.
-func (e *moduleEngine) MemoryGrown() {}
+func (e *moduleEngine) MemoryGrown() {
+	if e.tier != nil {
+		if c := e.tier.compiled.Load(); c != nil {
+			c.me.MemoryGrown()
+		}
+	}
+}
 
 // restorable is implemented by panic values that can restore callEngine state.
 // Both *snapshot (snapshotter API) and *thrownException (exception handling)
@@ -182,6 +215,13 @@ type callEngine struct {
 
 	// stackiterator for Listeners to walk frames and stack.
 	stackIterator stackIterator
+
+	// fuel is the fuel of the call in progress, or nil if it is not limited.
+	fuel *wasm.Fuel
+
+	// snapshotEnabled is true if the snapshotter is enabled for the call in progress, in which case the compiled
+	// code of the tiered engine isn't used as only the interpreter can take snapshots.
+	snapshotEnabled bool
 }
 
 // matchCatchClause checks whether a single catch clause matches the given exception.
@@ -210,7 +250,7 @@ func matchCatchClause(kind byte, clauseTag *wasm.TagInstance, exn *wasm.Exceptio
 // for a handler matching the given exception at the current PC. Returns the
 // matched clause and catch values, or nil if no handler matches. Searches
 // backwards so inner try_tables (which have higher indices) are checked first.
-// This function is pure — it does not modify callEngine state.
+// This function does not modify callEngine state.
 func searchExceptionTable(exn *wasm.Exception, frame *callFrame) (*exceptionTableCatchClause, []uint64) {
 	table := frame.f.parent.exceptionTable
 	pc := frame.pc
@@ -227,6 +267,11 @@ func searchExceptionTable(exn *wasm.Exception, frame *callFrame) (*exceptionTabl
 			}
 			matched, values := matchCatchClause(clause.kind, clauseTag, exn)
 			if matched {
+				if m := frame.f.moduleInstance; m.GCEnabled() &&
+					(clause.kind == wasm.CatchKindCatchRef || clause.kind == wasm.CatchKindCatchAllRef) {
+					// The exnref is pushed, so the references in the payload must be traced through it.
+					m.GCKeepException(exn)
+				}
 				return clause, values
 			}
 		}
@@ -259,6 +304,10 @@ func (ce *callEngine) callWithUnwind(ctx context.Context, m *wasm.ModuleInstance
 	func() {
 		defer func() {
 			if r := recover(); r != nil {
+				if exn, ok := r.(*wasm.Exception); ok {
+					// Thrown by a host function.
+					r = &thrownException{exception: exn}
+				}
 				if v, ok := r.(restorable); ok && v.canRestore(ce, callerFrameCount) {
 					v.doRestore(ce, callerFrameCount)
 					caught = true
@@ -297,6 +346,10 @@ func (ce *callEngine) popValue() (v uint64) {
 	return
 }
 
+func (ce *callEngine) peekValue() uint64 {
+	return ce.stack[len(ce.stack)-1]
+}
+
 func (ce *callEngine) popValues(v []uint64) {
 	stackTopIndex := len(ce.stack) - len(v)
 	copy(v, ce.stack[stackTopIndex:])
@@ -349,6 +402,9 @@ type callFrame struct {
 	// base index in the frame of this function, used to detect the count of
 	// values on the stack.
 	base int
+	// compiled is non-nil if this calls the compiled code of f, and a panic unwound its frames.
+	// See callCompiledFunc.
+	compiled *compiledFrames
 }
 
 type compiledFunction struct {
@@ -359,10 +415,14 @@ type compiledFunction struct {
 	offsetsInWasmBinary []uint64
 	hostFn              interface{}
 	ensureTermination   bool
+	fuelMetering        bool
 	index               wasm.Index
 }
 
 type function struct {
+	// tier is non-nil if this is created by the engine of NewTieredEngine. This must be the first field, as the
+	// compiled code calls this via the function references. See Tier.
+	tier           *tieredFunction
 	funcType       *wasm.FunctionType
 	moduleInstance *wasm.ModuleInstance
 	typeID         wasm.FunctionTypeID
@@ -504,13 +564,13 @@ func (f internalFunction) SourceOffsetForPC(pc experimental.ProgramCounter) uint
 const callFrameStackSize = 0
 
 // CompileModule implements the same method as documented on wasm.Engine.
-func (e *engine) CompileModule(_ context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) error {
+func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination, fuelMetering, _ bool) error {
 	if _, ok := e.getCompiledFunctions(module, true); ok { // cache hit!
 		return nil
 	}
 
 	funcs := make([]compiledFunction, len(module.FunctionSection))
-	irCompiler, err := newCompiler(e.enabledFeatures, callFrameStackSize, module, ensureTermination)
+	irCompiler, err := newCompiler(e.enabledFeatures, callFrameStackSize, module, ensureTermination, fuelMetering)
 	if err != nil {
 		return err
 	}
@@ -540,10 +600,11 @@ func (e *engine) CompileModule(_ context.Context, module *wasm.Module, listeners
 		}
 		compiled.source = module
 		compiled.ensureTermination = ensureTermination
+		compiled.fuelMetering = fuelMetering
 		compiled.listener = lsn
 		compiled.index = imported + uint32(i)
 	}
-	e.addCompiledFunctions(module, funcs)
+	e.addCompiledFunctions(module, funcs, e.newTieredModule(ctx, module, funcs, listeners, ensureTermination, fuelMetering))
 	return nil
 }
 
@@ -570,6 +631,9 @@ func (e *engine) NewModuleEngine(module *wasm.Module, instance *wasm.ModuleInsta
 			parent:         c,
 		}
 	}
+	if e.tier != nil {
+		e.newTieredFunctions(me, module, instance, e.getTieredModule(module))
+	}
 	return me, nil
 }
 
@@ -617,12 +681,12 @@ func (e *engine) lowerIR(ir *compilationResult, ret *compiledFunction) error {
 				target := op.Us[j]
 				e.setLabelAddress(&op.Us[j], label(target), labelAddressResolutions)
 			}
-		case operationKindTailCallReturnCallIndirect:
+		case operationKindTailCallReturnCall, operationKindTailCallReturnCallIndirect:
 			e.setLabelAddress(&op.Us[1], label(op.Us[1]), labelAddressResolutions)
 		case operationKindBrOnNull:
 			e.setLabelAddress(&op.U1, label(op.U1), labelAddressResolutions)
 			e.setLabelAddress(&op.U2, label(op.U2), labelAddressResolutions)
-		case operationKindBrOnNonNull:
+		case operationKindBrOnNonNull, operationKindGCBrOnCast:
 			e.setLabelAddress(&op.U1, label(op.U1), labelAddressResolutions)
 			e.setLabelAddress(&op.U2, label(op.U2), labelAddressResolutions)
 		case operationKindReturnCallRef:
@@ -677,7 +741,7 @@ func (e *moduleEngine) ResolveImportedFunction(index, descFunc, indexInImportedM
 }
 
 // ResolveImportedMemory implements wasm.ModuleEngine.
-func (e *moduleEngine) ResolveImportedMemory(wasm.ModuleEngine) {}
+func (e *moduleEngine) ResolveImportedMemory(wasm.Index, wasm.Index, wasm.ModuleEngine) {}
 
 // DoneInstantiation implements wasm.ModuleEngine.
 func (e *moduleEngine) DoneInstantiation() {}
@@ -754,10 +818,15 @@ func (ce *callEngine) call(ctx context.Context, params, results []uint64) (_ []u
 		}
 	}
 
-	if ctx.Value(expctxkeys.EnableSnapshotterKey{}) != nil {
+	ce.snapshotEnabled = ctx.Value(expctxkeys.EnableSnapshotterKey{}) != nil
+	if ce.snapshotEnabled {
 		ctx = context.WithValue(ctx, expctxkeys.SnapshotterKey{}, ce)
 	}
 
+	if ce.f.parent.fuelMetering {
+		ctx, ce.fuel = wasm.CallFuel(ctx)
+	}
+
 	defer func() {
 		// If the module closed during the call, and the call didn't err for another reason, set an ExitError.
 		if err == nil {
@@ -772,6 +841,11 @@ func (ce *callEngine) call(ctx context.Context, params, results []uint64) (_ []u
 
 	ce.pushValues(params)
 
+	if m.GCEnabled() {
+		m.GCCallEnter()
+		defer m.GCCallExit()
+	}
+
 	if ce.f.parent.ensureTermination {
 		done := m.CloseModuleOnCanceledOrTimeout(ctx)
 		defer done()
@@ -808,7 +882,14 @@ func (ce *callEngine) recoverOnCall(ctx context.Context, m *wasm.ModuleInstance,
 	}
 
 	// If an exception reached the top level without being caught, convert it to an uncaught exception error.
-	if _, ok := v.(*thrownException); ok {
+	var exn *wasm.Exception
+	switch e := v.(type) {
+	case *thrownException:
+		exn = e.exception
+	case *wasm.Exception:
+		exn = e
+	}
+	if exn != nil {
 		v = wasmruntime.ErrRuntimeUncaughtException
 	}
 
@@ -821,6 +902,13 @@ func (ce *callEngine) recoverOnCall(ctx context.Context, m *wasm.ModuleInstance,
 	}
 	for i := 0; i < frameCount; i++ {
 		frame := ce.popFrame()
+		if frame.compiled != nil {
+			// These include the frame of f.
+			for _, cf := range *frame.compiled {
+				builder.AddFrame(cf.funcName, cf.paramTypes, cf.resultTypes, cf.sources)
+			}
+			continue
+		}
 		f := frame.f
 		def := f.definition()
 		var sources []string
@@ -837,6 +925,9 @@ func (ce *callEngine) recoverOnCall(ctx context.Context, m *wasm.ModuleInstance,
 	}
 
 	err = builder.FromRecovered(v)
+	if exn != nil {
+		err = &wasm.ExceptionError{Exception: exn, Err: err}
+	}
 	for i := range functionListeners {
 		functionListeners[i].Abort(ctx, m, functionListeners[i].def, err)
 	}
@@ -851,6 +942,12 @@ func (ce *callEngine) callFunction(ctx context.Context, m *wasm.ModuleInstance,
 		ce.callGoFuncWithStack(ctx, m, f)
 	} else if lsn := f.parent.listener; lsn != nil {
 		ce.callNativeFuncWithListener(ctx, m, f, lsn)
+	} else if f.tier != nil && !ce.snapshotEnabled {
+		if compiled := f.tier.tierUp(); compiled != nil {
+			ce.callCompiledFunc(ctx, f, compiled)
+		} else {
+			ce.callNativeFunc(ctx, m, f)
+		}
 	} else {
 		ce.callNativeFunc(ctx, m, f)
 	}
@@ -888,7 +985,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 	frame := &callFrame{f: f, base: len(ce.stack)}
 	moduleInst := f.moduleInstance
 	functions := moduleInst.Engine.(*moduleEngine).functions
-	memoryInst := moduleInst.MemoryInstance
+	memories := moduleInst.Memories
 	globals := moduleInst.Globals
 	tables := moduleInst.Tables
 	typeIDs := moduleInst.TypeIDs
@@ -908,13 +1005,26 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				panic(err)
 			}
 			frame.pc++
+		case operationKindBuiltinFunctionConsumeFuel:
+			if fuel := ce.fuel; fuel != nil && op.U1 != 0 {
+				if fuel.Consume(int64(op.U1)) < 0 {
+					panic(m.CloseOnFuelExhausted(ctx))
+				}
+			}
+			frame.pc++
 		case operationKindUnreachable:
 			panic(wasmruntime.ErrRuntimeUnreachable)
 		case operationKindBr:
+			if op.U1 <= frame.pc && frame.f.tier != nil {
+				frame.f.tier.countBackEdge()
+			}
 			frame.pc = op.U1
 		case operationKindBrIf:
 			if ce.popValue() > 0 {
 				ce.drop(op.U3)
+				if op.U1 <= frame.pc && frame.f.tier != nil {
+					frame.f.tier.countBackEdge()
+				}
 				frame.pc = op.U1
 			} else {
 				frame.pc = op.U2
@@ -927,6 +1037,9 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			v *= 2
 			ce.drop(op.Us[v+1])
+			if op.Us[v] <= frame.pc && frame.f.tier != nil {
+				frame.f.tier.countBackEdge()
+			}
 			frame.pc = op.Us[v]
 		case operationKindCall:
 			frameUnwound := ce.callWithUnwind(ctx, f.moduleInstance, &functions[op.U1])
@@ -1003,16 +1116,17 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			g.Val = ce.popValue()
 			frame.pc++
 		case operationKindLoad:
+			memoryInst := memories[op.U3]
 			offset := ce.popMemoryOffset(op)
 			switch unsignedType(op.B1) {
 			case unsignedTypeI32, unsignedTypeF32:
-				if val, ok := memoryInst.ReadUint32Le(offset); !ok {
+				if val, ok := memoryInst.ReadUint32Le64(offset); !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				} else {
 					ce.pushValue(uint64(val))
 				}
 			case unsignedTypeI64, unsignedTypeF64:
-				if val, ok := memoryInst.ReadUint64Le(offset); !ok {
+				if val, ok := memoryInst.ReadUint64Le64(offset); !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				} else {
 					ce.pushValue(val)
@@ -1020,7 +1134,8 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindLoad8:
-			val, ok := memoryInst.ReadByte(ce.popMemoryOffset(op))
+			memoryInst := memories[op.U3]
+			val, ok := memoryInst.ReadByte64(ce.popMemoryOffset(op))
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
@@ -1035,8 +1150,9 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindLoad16:
+			memoryInst := memories[op.U3]
 
-			val, ok := memoryInst.ReadUint16Le(ce.popMemoryOffset(op))
+			val, ok := memoryInst.ReadUint16Le64(ce.popMemoryOffset(op))
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
@@ -1051,7 +1167,8 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindLoad32:
-			val, ok := memoryInst.ReadUint32Le(ce.popMemoryOffset(op))
+			memoryInst := memories[op.U3]
+			val, ok := memoryInst.ReadUint32Le64(ce.popMemoryOffset(op))
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
@@ -1063,46 +1180,60 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindStore:
+			memoryInst := memories[op.U3]
 			val := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			switch unsignedType(op.B1) {
 			case unsignedTypeI32, unsignedTypeF32:
-				if !memoryInst.WriteUint32Le(offset, uint32(val)) {
+				if !memoryInst.WriteUint32Le64(offset, uint32(val)) {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 			case unsignedTypeI64, unsignedTypeF64:
-				if !memoryInst.WriteUint64Le(offset, val) {
+				if !memoryInst.WriteUint64Le64(offset, val) {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 			}
 			frame.pc++
 		case operationKindStore8:
+			memoryInst := memories[op.U3]
 			val := byte(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteByte(offset, val) {
+			if !memoryInst.WriteByte64(offset, val) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindStore16:
+			memoryInst := memories[op.U3]
 			val := uint16(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteUint16Le(offset, val) {
+			if !memoryInst.WriteUint16Le64(offset, val) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindStore32:
+			memoryInst := memories[op.U3]
 			val := uint32(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteUint32Le(offset, val) {
+			if !memoryInst.WriteUint32Le64(offset, val) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindMemorySize:
+			memoryInst := memories[op.U1]
 			ce.pushValue(uint64(memoryInst.Pages()))
 			frame.pc++
 		case operationKindMemoryGrow:
+			memoryInst := memories[op.U1]
 			n := ce.popValue()
-			if res, ok := memoryInst.Grow(uint32(n)); !ok {
+			if memoryInst.Is64 {
+				if n > math.MaxUint32 {
+					ce.pushValue(math.MaxUint64) // = -1 in signed 64-bit integer.
+				} else if res, ok := memoryInst.Grow(uint32(n)); !ok {
+					ce.pushValue(math.MaxUint64)
+				} else {
+					ce.pushValue(uint64(res))
+				}
+			} else if res, ok := memoryInst.Grow(uint32(n)); !ok {
 				ce.pushValue(uint64(0xffffffff)) // = -1 in signed 32-bit integer.
 			} else {
 				ce.pushValue(uint64(res))
@@ -1892,12 +2023,13 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.pushValue(uint64(v))
 			frame.pc++
 		case operationKindMemoryInit:
+			memoryInst := memories[op.U2]
 			dataInstance := dataInstances[op.U1]
 			copySize := ce.popValue()
 			inDataOffset := ce.popValue()
 			inMemoryOffset := ce.popValue()
 			if inDataOffset+copySize > uint64(len(dataInstance)) ||
-				inMemoryOffset+copySize > uint64(len(memoryInst.Buffer)) {
+				outOfRange(inMemoryOffset, copySize, uint64(len(memoryInst.Buffer))) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			} else if copySize != 0 {
 				copy(memoryInst.Buffer[inMemoryOffset:inMemoryOffset+copySize], dataInstance[inDataOffset:])
@@ -1907,22 +2039,24 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			dataInstances[op.U1] = nil
 			frame.pc++
 		case operationKindMemoryCopy:
-			memLen := uint64(len(memoryInst.Buffer))
+			dstMemoryInst, srcMemoryInst := memories[op.U1], memories[op.U2]
 			copySize := ce.popValue()
 			sourceOffset := ce.popValue()
 			destinationOffset := ce.popValue()
-			if sourceOffset+copySize > memLen || destinationOffset+copySize > memLen {
+			if outOfRange(sourceOffset, copySize, uint64(len(srcMemoryInst.Buffer))) ||
+				outOfRange(destinationOffset, copySize, uint64(len(dstMemoryInst.Buffer))) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			} else if copySize != 0 {
-				copy(memoryInst.Buffer[destinationOffset:],
-					memoryInst.Buffer[sourceOffset:sourceOffset+copySize])
+				copy(dstMemoryInst.Buffer[destinationOffset:],
+					srcMemoryInst.Buffer[sourceOffset:sourceOffset+copySize])
 			}
 			frame.pc++
 		case operationKindMemoryFill:
+			memoryInst := memories[op.U1]
 			fillSize := ce.popValue()
 			value := byte(ce.popValue())
 			offset := ce.popValue()
-			if fillSize+offset > uint64(len(memoryInst.Buffer)) {
+			if outOfRange(offset, fillSize, uint64(len(memoryInst.Buffer))) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			} else if fillSize != 0 {
 				// Uses the copy trick for faster filling the buffer with the value.
@@ -2111,21 +2245,22 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindV128Load:
+			memoryInst := memories[op.U3]
 			offset := ce.popMemoryOffset(op)
 			switch op.B1 {
 			case v128LoadType128:
-				lo, ok := memoryInst.ReadUint64Le(offset)
+				lo, ok := memoryInst.ReadUint64Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(lo)
-				hi, ok := memoryInst.ReadUint64Le(offset + 8)
+				hi, ok := memoryInst.ReadUint64Le64(offset + 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(hi)
 			case v128LoadType8x8s:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2136,7 +2271,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					uint64(uint16(int8(data[7])))<<48 | uint64(uint16(int8(data[6])))<<32 | uint64(uint16(int8(data[5])))<<16 | uint64(uint16(int8(data[4]))),
 				)
 			case v128LoadType8x8u:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2147,7 +2282,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					uint64(data[7])<<48 | uint64(data[6])<<32 | uint64(data[5])<<16 | uint64(data[4]),
 				)
 			case v128LoadType16x4s:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2160,7 +2295,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 						uint64(uint32(int16(binary.LittleEndian.Uint16(data[4:])))),
 				)
 			case v128LoadType16x4u:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2171,21 +2306,21 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					uint64(binary.LittleEndian.Uint16(data[6:]))<<32 | uint64(binary.LittleEndian.Uint16(data[4:])),
 				)
 			case v128LoadType32x2s:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(uint64(int32(binary.LittleEndian.Uint32(data))))
 				ce.pushValue(uint64(int32(binary.LittleEndian.Uint32(data[4:]))))
 			case v128LoadType32x2u:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(uint64(binary.LittleEndian.Uint32(data)))
 				ce.pushValue(uint64(binary.LittleEndian.Uint32(data[4:])))
 			case v128LoadType8Splat:
-				v, ok := memoryInst.ReadByte(offset)
+				v, ok := memoryInst.ReadByte64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2194,7 +2329,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				ce.pushValue(v8)
 				ce.pushValue(v8)
 			case v128LoadType16Splat:
-				v, ok := memoryInst.ReadUint16Le(offset)
+				v, ok := memoryInst.ReadUint16Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2202,7 +2337,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				ce.pushValue(v4)
 				ce.pushValue(v4)
 			case v128LoadType32Splat:
-				v, ok := memoryInst.ReadUint32Le(offset)
+				v, ok := memoryInst.ReadUint32Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2210,21 +2345,21 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				ce.pushValue(vv)
 				ce.pushValue(vv)
 			case v128LoadType64Splat:
-				lo, ok := memoryInst.ReadUint64Le(offset)
+				lo, ok := memoryInst.ReadUint64Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(lo)
 				ce.pushValue(lo)
 			case v128LoadType32zero:
-				lo, ok := memoryInst.ReadUint32Le(offset)
+				lo, ok := memoryInst.ReadUint32Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(uint64(lo))
 				ce.pushValue(0)
 			case v128LoadType64zero:
-				lo, ok := memoryInst.ReadUint64Le(offset)
+				lo, ok := memoryInst.ReadUint64Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2233,11 +2368,12 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindV128LoadLane:
+			memoryInst := memories[op.U3]
 			hi, lo := ce.popValue(), ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			switch op.B1 {
 			case 8:
-				b, ok := memoryInst.ReadByte(offset)
+				b, ok := memoryInst.ReadByte64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2249,7 +2385,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					hi = (hi & ^(0xff << s)) | uint64(b)<<s
 				}
 			case 16:
-				b, ok := memoryInst.ReadUint16Le(offset)
+				b, ok := memoryInst.ReadUint16Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2261,7 +2397,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					hi = (hi & ^(0xff_ff << s)) | uint64(b)<<s
 				}
 			case 32:
-				b, ok := memoryInst.ReadUint32Le(offset)
+				b, ok := memoryInst.ReadUint32Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2273,7 +2409,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					hi = (hi & ^(0xff_ff_ff_ff << s)) | uint64(b)<<s
 				}
 			case 64:
-				b, ok := memoryInst.ReadUint64Le(offset)
+				b, ok := memoryInst.ReadUint64Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2287,48 +2423,50 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.pushValue(hi)
 			frame.pc++
 		case operationKindV128Store:
+			memoryInst := memories[op.U3]
 			hi, lo := ce.popValue(), ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			// Write the upper bytes first to trigger an early error if the memory access is out of bounds.
 			// Otherwise, the lower bytes might be written to memory, but the upper bytes might not.
-			if uint64(offset)+8 > math.MaxUint32 {
+			if offset > math.MaxUint64-8 {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
-			if ok := memoryInst.WriteUint64Le(offset+8, hi); !ok {
+			if ok := memoryInst.WriteUint64Le64(offset+8, hi); !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
-			if ok := memoryInst.WriteUint64Le(offset, lo); !ok {
+			if ok := memoryInst.WriteUint64Le64(offset, lo); !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindV128StoreLane:
+			memoryInst := memories[op.U3]
 			hi, lo := ce.popValue(), ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			var ok bool
 			switch op.B1 {
 			case 8:
 				if op.B2 < 8 {
-					ok = memoryInst.WriteByte(offset, byte(lo>>(op.B2*8)))
+					ok = memoryInst.WriteByte64(offset, byte(lo>>(op.B2*8)))
 				} else {
-					ok = memoryInst.WriteByte(offset, byte(hi>>((op.B2-8)*8)))
+					ok = memoryInst.WriteByte64(offset, byte(hi>>((op.B2-8)*8)))
 				}
 			case 16:
 				if op.B2 < 4 {
-					ok = memoryInst.WriteUint16Le(offset, uint16(lo>>(op.B2*16)))
+					ok = memoryInst.WriteUint16Le64(offset, uint16(lo>>(op.B2*16)))
 				} else {
-					ok = memoryInst.WriteUint16Le(offset, uint16(hi>>((op.B2-4)*16)))
+					ok = memoryInst.WriteUint16Le64(offset, uint16(hi>>((op.B2-4)*16)))
 				}
 			case 32:
 				if op.B2 < 2 {
-					ok = memoryInst.WriteUint32Le(offset, uint32(lo>>(op.B2*32)))
+					ok = memoryInst.WriteUint32Le64(offset, uint32(lo>>(op.B2*32)))
 				} else {
-					ok = memoryInst.WriteUint32Le(offset, uint32(hi>>((op.B2-2)*32)))
+					ok = memoryInst.WriteUint32Le64(offset, uint32(hi>>((op.B2-2)*32)))
 				}
 			case 64:
 				if op.B2 == 0 {
-					ok = memoryInst.WriteUint64Le(offset, lo)
+					ok = memoryInst.WriteUint64Le64(offset, lo)
 				} else {
-					ok = memoryInst.WriteUint64Le(offset, hi)
+					ok = memoryInst.WriteUint64Le64(offset, hi)
 				}
 			}
 			if !ok {
@@ -4178,10 +4316,43 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				}
 			}
 
+			ce.pushValue(retLo)
+			ce.pushValue(retHi)
+			frame.pc++
+		case operationKindV128RelaxedMadd:
+			zHi, zLo := ce.popValue(), ce.popValue()
+			yHi, yLo := ce.popValue(), ce.popValue()
+			xHi, xLo := ce.popValue(), ce.popValue()
+			var retLo, retHi uint64
+			if op.B1 == shapeF32x4 {
+				retLo = uint64(v128RelaxedMaddF32(uint32(xLo), uint32(yLo), uint32(zLo), op.B3)) |
+					uint64(v128RelaxedMaddF32(uint32(xLo>>32), uint32(yLo>>32), uint32(zLo>>32), op.B3))<<32
+				retHi = uint64(v128RelaxedMaddF32(uint32(xHi), uint32(yHi), uint32(zHi), op.B3)) |
+					uint64(v128RelaxedMaddF32(uint32(xHi>>32), uint32(yHi>>32), uint32(zHi>>32), op.B3))<<32
+			} else {
+				retLo = v128RelaxedMaddF64(xLo, yLo, zLo, op.B3)
+				retHi = v128RelaxedMaddF64(xHi, yHi, zHi, op.B3)
+			}
+			ce.pushValue(retLo)
+			ce.pushValue(retHi)
+			frame.pc++
+		case operationKindV128RelaxedDot:
+			var zHi, zLo uint64
+			if op.B3 {
+				zHi, zLo = ce.popValue(), ce.popValue()
+			}
+			yHi, yLo := ce.popValue(), ce.popValue()
+			xHi, xLo := ce.popValue(), ce.popValue()
+			retLo, retHi := v128RelaxedDot(xLo, yLo), v128RelaxedDot(xHi, yHi)
+			if op.B3 {
+				retLo = v128RelaxedDotAdd(retLo, zLo)
+				retHi = v128RelaxedDotAdd(retHi, zHi)
+			}
 			ce.pushValue(retLo)
 			ce.pushValue(retHi)
 			frame.pc++
 		case operationKindAtomicMemoryWait:
+			memoryInst := memories[op.U3]
 			timeout := int64(ce.popValue())
 			exp := ce.popValue()
 			offset := ce.popMemoryOffset(op)
@@ -4196,38 +4367,39 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				if offset%4 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				if int(offset) > len(memoryInst.Buffer)-4 {
+				if offset >= uint64(len(memoryInst.Buffer)) || uint64(len(memoryInst.Buffer))-offset < 4 {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
-				ce.pushValue(memoryInst.Wait32(offset, uint32(exp), timeout, func(mem *wasm.MemoryInstance, offset uint32) uint32 {
+				ce.pushValue(memoryInst.Wait32(offset, uint32(exp), timeout, func(mem *wasm.MemoryInstance, offset uint64) uint32 {
 					mem.Mux.Lock()
 					defer mem.Mux.Unlock()
-					value, _ := mem.ReadUint32Le(offset)
+					value, _ := mem.ReadUint32Le64(offset)
 					return value
 				}))
 			case unsignedTypeI64:
 				if offset%8 != 0 {
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
-				if int(offset) > len(memoryInst.Buffer)-8 {
+				if offset >= uint64(len(memoryInst.Buffer)) || uint64(len(memoryInst.Buffer))-offset < 8 {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
-				ce.pushValue(memoryInst.Wait64(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint32) uint64 {
+				ce.pushValue(memoryInst.Wait64(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint64) uint64 {
 					mem.Mux.Lock()
 					defer mem.Mux.Unlock()
-					value, _ := mem.ReadUint64Le(offset)
+					value, _ := mem.ReadUint64Le64(offset)
 					return value
 				}))
 			}
 			frame.pc++
 		case operationKindAtomicMemoryNotify:
+			memoryInst := memories[op.U3]
 			count := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			if offset%4 != 0 {
 				panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 			}
 			// Just a bounds check
-			if offset >= memoryInst.Size() {
+			if offset >= memoryInst.Size64() {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			res := memoryInst.Notify(offset, uint32(count))
@@ -4235,7 +4407,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			frame.pc++
 		case operationKindAtomicFence:
 			// Memory not required for fence only
-			if memoryInst != nil {
+			for _, memoryInst := range memories {
 				// An empty critical section can be used as a synchronization primitive, which is what
 				// fence is. Probably, there are no spectests or defined behavior to confirm this yet.
 				memoryInst.Mux.Lock()
@@ -4243,6 +4415,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindAtomicLoad:
+			memoryInst := memories[op.U3]
 			offset := ce.popMemoryOffset(op)
 			switch unsignedType(op.B1) {
 			case unsignedTypeI32:
@@ -4250,7 +4423,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
 				memoryInst.Mux.Lock()
-				val, ok := memoryInst.ReadUint32Le(offset)
+				val, ok := memoryInst.ReadUint32Le64(offset)
 				memoryInst.Mux.Unlock()
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
@@ -4261,7 +4434,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
 				memoryInst.Mux.Lock()
-				val, ok := memoryInst.ReadUint64Le(offset)
+				val, ok := memoryInst.ReadUint64Le64(offset)
 				memoryInst.Mux.Unlock()
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
@@ -4270,9 +4443,10 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindAtomicLoad8:
+			memoryInst := memories[op.U3]
 			offset := ce.popMemoryOffset(op)
 			memoryInst.Mux.Lock()
-			val, ok := memoryInst.ReadByte(offset)
+			val, ok := memoryInst.ReadByte64(offset)
 			memoryInst.Mux.Unlock()
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
@@ -4280,12 +4454,13 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.pushValue(uint64(val))
 			frame.pc++
 		case operationKindAtomicLoad16:
+			memoryInst := memories[op.U3]
 			offset := ce.popMemoryOffset(op)
 			if offset%2 != 0 {
 				panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 			}
 			memoryInst.Mux.Lock()
-			val, ok := memoryInst.ReadUint16Le(offset)
+			val, ok := memoryInst.ReadUint16Le64(offset)
 			memoryInst.Mux.Unlock()
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
@@ -4293,6 +4468,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.pushValue(uint64(val))
 			frame.pc++
 		case operationKindAtomicStore:
+			memoryInst := memories[op.U3]
 			val := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			switch unsignedType(op.B1) {
@@ -4301,7 +4477,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
 				memoryInst.Mux.Lock()
-				ok := memoryInst.WriteUint32Le(offset, uint32(val))
+				ok := memoryInst.WriteUint32Le64(offset, uint32(val))
 				memoryInst.Mux.Unlock()
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
@@ -4311,7 +4487,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
 				memoryInst.Mux.Lock()
-				ok := memoryInst.WriteUint64Le(offset, val)
+				ok := memoryInst.WriteUint64Le64(offset, val)
 				memoryInst.Mux.Unlock()
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
@@ -4319,29 +4495,32 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case operationKindAtomicStore8:
+			memoryInst := memories[op.U3]
 			val := byte(ce.popValue())
 			offset := ce.popMemoryOffset(op)
 			memoryInst.Mux.Lock()
-			ok := memoryInst.WriteByte(offset, val)
+			ok := memoryInst.WriteByte64(offset, val)
 			memoryInst.Mux.Unlock()
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindAtomicStore16:
+			memoryInst := memories[op.U3]
 			val := uint16(ce.popValue())
 			offset := ce.popMemoryOffset(op)
 			if offset%2 != 0 {
 				panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 			}
 			memoryInst.Mux.Lock()
-			ok := memoryInst.WriteUint16Le(offset, val)
+			ok := memoryInst.WriteUint16Le64(offset, val)
 			memoryInst.Mux.Unlock()
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case operationKindAtomicRMW:
+			memoryInst := memories[op.U3]
 			val := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			switch unsignedType(op.B1) {
@@ -4350,7 +4529,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
 				memoryInst.Mux.Lock()
-				old, ok := memoryInst.ReadUint32Le(offset)
+				old, ok := memoryInst.ReadUint32Le64(offset)
 				if !ok {
 					memoryInst.Mux.Unlock()
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
@@ -4370,7 +4549,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				case atomicArithmeticOpNop:
 					newVal = uint32(val)
 				}
-				memoryInst.WriteUint32Le(offset, newVal)
+				memoryInst.WriteUint32Le64(offset, newVal)
 				memoryInst.Mux.Unlock()
 				ce.pushValue(uint64(old))
 			case unsignedTypeI64:
@@ -4378,7 +4557,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
 				memoryInst.Mux.Lock()
-				old, ok := memoryInst.ReadUint64Le(offset)
+				old, ok := memoryInst.ReadUint64Le64(offset)
 				if !ok {
 					memoryInst.Mux.Unlock()
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
@@ -4398,16 +4577,17 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				case atomicArithmeticOpNop:
 					newVal = val
 				}
-				memoryInst.WriteUint64Le(offset, newVal)
+				memoryInst.WriteUint64Le64(offset, newVal)
 				memoryInst.Mux.Unlock()
 				ce.pushValue(old)
 			}
 			frame.pc++
 		case operationKindAtomicRMW8:
+			memoryInst := memories[op.U3]
 			val := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			memoryInst.Mux.Lock()
-			old, ok := memoryInst.ReadByte(offset)
+			old, ok := memoryInst.ReadByte64(offset)
 			if !ok {
 				memoryInst.Mux.Unlock()
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
@@ -4428,18 +4608,19 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			case atomicArithmeticOpNop:
 				newVal = arg
 			}
-			memoryInst.WriteByte(offset, newVal)
+			memoryInst.WriteByte64(offset, newVal)
 			memoryInst.Mux.Unlock()
 			ce.pushValue(uint64(old))
 			frame.pc++
 		case operationKindAtomicRMW16:
+			memoryInst := memories[op.U3]
 			val := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			if offset%2 != 0 {
 				panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 			}
 			memoryInst.Mux.Lock()
-			old, ok := memoryInst.ReadUint16Le(offset)
+			old, ok := memoryInst.ReadUint16Le64(offset)
 			if !ok {
 				memoryInst.Mux.Unlock()
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
@@ -4460,11 +4641,12 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			case atomicArithmeticOpNop:
 				newVal = arg
 			}
-			memoryInst.WriteUint16Le(offset, newVal)
+			memoryInst.WriteUint16Le64(offset, newVal)
 			memoryInst.Mux.Unlock()
 			ce.pushValue(uint64(old))
 			frame.pc++
 		case operationKindAtomicRMWCmpxchg:
+			memoryInst := memories[op.U3]
 			rep := ce.popValue()
 			exp := ce.popValue()
 			offset := ce.popMemoryOffset(op)
@@ -4474,13 +4656,13 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
 				memoryInst.Mux.Lock()
-				old, ok := memoryInst.ReadUint32Le(offset)
+				old, ok := memoryInst.ReadUint32Le64(offset)
 				if !ok {
 					memoryInst.Mux.Unlock()
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				if old == uint32(exp) {
-					memoryInst.WriteUint32Le(offset, uint32(rep))
+					memoryInst.WriteUint32Le64(offset, uint32(rep))
 				}
 				memoryInst.Mux.Unlock()
 				ce.pushValue(uint64(old))
@@ -4489,35 +4671,37 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 				}
 				memoryInst.Mux.Lock()
-				old, ok := memoryInst.ReadUint64Le(offset)
+				old, ok := memoryInst.ReadUint64Le64(offset)
 				if !ok {
 					memoryInst.Mux.Unlock()
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				if old == exp {
-					memoryInst.WriteUint64Le(offset, rep)
+					memoryInst.WriteUint64Le64(offset, rep)
 				}
 				memoryInst.Mux.Unlock()
 				ce.pushValue(old)
 			}
 			frame.pc++
 		case operationKindAtomicRMW8Cmpxchg:
+			memoryInst := memories[op.U3]
 			rep := byte(ce.popValue())
 			exp := byte(ce.popValue())
 			offset := ce.popMemoryOffset(op)
 			memoryInst.Mux.Lock()
-			old, ok := memoryInst.ReadByte(offset)
+			old, ok := memoryInst.ReadByte64(offset)
 			if !ok {
 				memoryInst.Mux.Unlock()
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			if old == exp {
-				memoryInst.WriteByte(offset, rep)
+				memoryInst.WriteByte64(offset, rep)
 			}
 			memoryInst.Mux.Unlock()
 			ce.pushValue(uint64(old))
 			frame.pc++
 		case operationKindAtomicRMW16Cmpxchg:
+			memoryInst := memories[op.U3]
 			rep := uint16(ce.popValue())
 			exp := uint16(ce.popValue())
 			offset := ce.popMemoryOffset(op)
@@ -4525,13 +4709,13 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				panic(wasmruntime.ErrRuntimeUnalignedAtomic)
 			}
 			memoryInst.Mux.Lock()
-			old, ok := memoryInst.ReadUint16Le(offset)
+			old, ok := memoryInst.ReadUint16Le64(offset)
 			if !ok {
 				memoryInst.Mux.Unlock()
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			if old == exp {
-				memoryInst.WriteUint16Le(offset, rep)
+				memoryInst.WriteUint16Le64(offset, rep)
 			}
 			memoryInst.Mux.Unlock()
 			ce.pushValue(uint64(old))
@@ -4565,33 +4749,49 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			panic(&thrownException{exception: exn})
 
-		case operationKindTailCallReturnCall:
-			f := &functions[op.U1]
-			ce.dropForTailCall(frame, f)
-			body, bodyLen = ce.resetPc(frame, f)
-
-		case operationKindTailCallReturnCallIndirect:
-			offset := ce.popValue()
-			table := tables[op.U2]
-			tf := ce.functionForOffset(table, offset, typeIDs[op.U1])
-
-			// We are allowing proper tail calls only across functions that belong to the same
-			// module; for indirect calls, we have to enforce it at run-time.
-			// For details, see internal/engine/RATIONALE.md
-			if tf.moduleInstance != f.moduleInstance {
-				// Revert to a normal call.
-				frameUnwound := ce.callWithUnwind(ctx, f.moduleInstance, tf)
-				if frameUnwound {
-					frame = ce.frames[len(ce.frames)-1]
-					body = frame.f.parent.body
-					bodyLen = uint64(len(body))
+		case operationKindTailCallReturnCall, operationKindTailCallReturnCallIndirect, operationKindReturnCallRef:
+			var tf *function
+			switch op.Kind {
+			case operationKindTailCallReturnCall:
+				tf = &functions[op.U1]
+			case operationKindTailCallReturnCallIndirect:
+				offset := ce.popValue()
+				tf = ce.functionForOffset(tables[op.U2], offset, typeIDs[op.U1])
+			default:
+				ref := ce.popValue()
+				if ref == 0 {
+					panic(wasmruntime.ErrRuntimeNullReference)
+				}
+				tf = functionFromUintptr(uintptr(ref))
+			}
+
+			if tf.moduleInstance != moduleInst {
+				if tf.parent.hostFn != nil || tf.parent.listener != nil {
+					// Host functions and functions with a listener need their own frame, so revert to
+					// a normal call. For details, see internal/engine/RATIONALE.md
+					frameUnwound := ce.callWithUnwind(ctx, f.moduleInstance, tf)
+					if frameUnwound {
+						frame = ce.frames[len(ce.frames)-1]
+						body = frame.f.parent.body
+						bodyLen = uint64(len(body))
+						continue
+					}
+					// Return
+					ce.drop(op.Us[0])
+					// Jump to the function frame (return)
+					frame.pc = op.Us[1]
 					continue
 				}
-				// Return
-				ce.drop(op.Us[0])
-				// Jump to the function frame (return)
-				frame.pc = op.Us[1]
-				continue
+				// The callee belongs to another module, so switch to its instance before reusing the frame.
+				f, moduleInst = tf, tf.moduleInstance
+				m = moduleInst
+				functions = moduleInst.Engine.(*moduleEngine).functions
+				memories = moduleInst.Memories
+				globals = moduleInst.Globals
+				tables = moduleInst.Tables
+				typeIDs = moduleInst.TypeIDs
+				dataInstances = moduleInst.DataInstances
+				elementInstances = moduleInst.ElementInstances
 			}
 
 			ce.dropForTailCall(frame, tf)
@@ -4613,29 +4813,6 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 
-		case operationKindReturnCallRef:
-			ref := ce.popValue()
-			if ref == 0 {
-				panic(wasmruntime.ErrRuntimeNullReference)
-			}
-			tf := functionFromUintptr(uintptr(ref))
-
-			if tf.moduleInstance != f.moduleInstance {
-				frameUnwound := ce.callWithUnwind(ctx, f.moduleInstance, tf)
-				if frameUnwound {
-					frame = ce.frames[len(ce.frames)-1]
-					body = frame.f.parent.body
-					bodyLen = uint64(len(body))
-					continue
-				}
-				ce.drop(op.Us[0])
-				frame.pc = op.Us[1]
-				continue
-			}
-
-			ce.dropForTailCall(frame, tf)
-			body, bodyLen = ce.resetPc(frame, tf)
-
 		case operationKindRefAsNonNull:
 			ref := ce.popValue()
 			if ref == 0 {
@@ -4664,6 +4841,167 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				frame.pc = op.U2
 			}
 
+		case operationKindRefEq:
+			if ce.popValue() == ce.popValue() {
+				ce.pushValue(1)
+			} else {
+				ce.pushValue(0)
+			}
+			frame.pc++
+		case operationKindGCStructNew:
+			ce.gcStructNew(moduleInst, op)
+			frame.pc++
+		case operationKindGCStructGet:
+			obj := moduleInst.GCObject(ce.popValue())
+			ce.pushGCValue(obj.Fields, op.U1, op.B1, op.B3)
+			frame.pc++
+		case operationKindGCStructSet:
+			lo, hi := ce.popGCValue(op.B1)
+			obj := moduleInst.GCObject(ce.popValue())
+			storeGCValue(obj.Fields, op.U1, op.B1, lo, hi)
+			frame.pc++
+		case operationKindGCArrayNew:
+			ce.gcArrayNew(moduleInst, op)
+			frame.pc++
+		case operationKindGCArrayNewData:
+			n := uint64(uint32(ce.popValue()))
+			offset := uint64(uint32(ce.popValue()))
+			data := dataInstances[op.U2]
+			if offset+n*uint64(op.B2) > uint64(len(data)) {
+				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+			}
+			ref, obj := moduleInst.GCNewObject(wasm.Index(op.U1), n*gcStorageSlots(op.B1), ce.stack)
+			loadGCValuesFromData(obj.Fields, 0, op.B1, op.B2, data[offset:], n)
+			ce.pushValue(ref)
+			frame.pc++
+		case operationKindGCArrayNewElem:
+			n := uint64(uint32(ce.popValue()))
+			offset := uint64(uint32(ce.popValue()))
+			elem := elementInstances[op.U2]
+			if offset+n > uint64(len(elem)) {
+				panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+			}
+			ref, obj := moduleInst.GCNewObject(wasm.Index(op.U1), n, ce.stack)
+			for i, r := range elem[offset : offset+n] {
+				obj.Fields[i] = uint64(r)
+			}
+			ce.pushValue(ref)
+			frame.pc++
+		case operationKindGCArrayInitData:
+			n := uint64(uint32(ce.popValue()))
+			offset := uint64(uint32(ce.popValue()))
+			d := uint64(uint32(ce.popValue()))
+			obj := moduleInst.GCObject(ce.popValue())
+			if d+n > uint64(obj.ArrayLen()) {
+				panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+			}
+			data := dataInstances[op.U2]
+			if offset+n*uint64(op.B2) > uint64(len(data)) {
+				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+			}
+			loadGCValuesFromData(obj.Fields, d*gcStorageSlots(op.B1), op.B1, op.B2, data[offset:], n)
+			frame.pc++
+		case operationKindGCArrayInitElem:
+			n := uint64(uint32(ce.popValue()))
+			offset := uint64(uint32(ce.popValue()))
+			d := uint64(uint32(ce.popValue()))
+			obj := moduleInst.GCObject(ce.popValue())
+			if d+n > uint64(obj.ArrayLen()) {
+				panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+			}
+			elem := elementInstances[op.U2]
+			if offset+n > uint64(len(elem)) {
+				panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+			}
+			for i, r := range elem[offset : offset+n] {
+				obj.Fields[d+uint64(i)] = uint64(r)
+			}
+			frame.pc++
+		case operationKindGCArrayGet:
+			i := uint64(uint32(ce.popValue()))
+			obj := moduleInst.GCObject(ce.popValue())
+			if i >= uint64(obj.ArrayLen()) {
+				panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+			}
+			ce.pushGCValue(obj.Fields, i*gcStorageSlots(op.B1), op.B1, op.B3)
+			frame.pc++
+		case operationKindGCArraySet:
+			lo, hi := ce.popGCValue(op.B1)
+			i := uint64(uint32(ce.popValue()))
+			obj := moduleInst.GCObject(ce.popValue())
+			if i >= uint64(obj.ArrayLen()) {
+				panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+			}
+			storeGCValue(obj.Fields, i*gcStorageSlots(op.B1), op.B1, lo, hi)
+			frame.pc++
+		case operationKindGCArrayLen:
+			obj := moduleInst.GCObject(ce.popValue())
+			ce.pushValue(uint64(obj.ArrayLen()))
+			frame.pc++
+		case operationKindGCArrayFill:
+			n := uint64(uint32(ce.popValue()))
+			lo, hi := ce.popGCValue(op.B1)
+			d := uint64(uint32(ce.popValue()))
+			obj := moduleInst.GCObject(ce.popValue())
+			if d+n > uint64(obj.ArrayLen()) {
+				panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+			}
+			slots := gcStorageSlots(op.B1)
+			for i := d; i < d+n; i++ {
+				storeGCValue(obj.Fields, i*slots, op.B1, lo, hi)
+			}
+			frame.pc++
+		case operationKindGCArrayCopy:
+			n := uint64(uint32(ce.popValue()))
+			s := uint64(uint32(ce.popValue()))
+			src := moduleInst.GCObject(ce.popValue())
+			d := uint64(uint32(ce.popValue()))
+			dst := moduleInst.GCObject(ce.popValue())
+			if d+n > uint64(dst.ArrayLen()) || s+n > uint64(src.ArrayLen()) {
+				panic(wasmruntime.ErrRuntimeOutOfBoundsArrayAccess)
+			}
+			slots := gcStorageSlots(op.B1)
+			copy(dst.Fields[d*slots:(d+n)*slots], src.Fields[s*slots:(s+n)*slots])
+			frame.pc++
+		case operationKindGCRefTest:
+			if ce.gcRefTest(moduleInst, ce.popValue(), wasm.ValueType(op.U1), op.B3) {
+				ce.pushValue(1)
+			} else {
+				ce.pushValue(0)
+			}
+			frame.pc++
+		case operationKindGCRefCast:
+			ref := ce.peekValue()
+			if !ce.gcRefTest(moduleInst, ref, wasm.ValueType(op.U1), op.B3) {
+				panic(wasmruntime.ErrRuntimeCastFailure)
+			}
+			frame.pc++
+		case operationKindGCBrOnCast:
+			ref := ce.peekValue()
+			if ce.gcRefTest(moduleInst, ref, wasm.ValueType(op.Us[0]), op.B3) != (op.B1 == 1) {
+				ce.drop(op.U3)
+				frame.pc = op.U1
+			} else {
+				frame.pc = op.U2
+			}
+		case operationKindGCAnyConvertExtern:
+			ref := ce.peekValue()
+			ce.stack[len(ce.stack)-1] = moduleInst.GCAnyConvertExtern(ref, ce.stack)
+			frame.pc++
+		case operationKindGCExternConvertAny:
+			ce.pushValue(moduleInst.GCExternConvertAny(ce.popValue()))
+			frame.pc++
+		case operationKindGCRefI31:
+			ce.pushValue(wasm.GCRefI31(uint32(ce.popValue())))
+			frame.pc++
+		case operationKindGCI31Get:
+			ref := ce.popValue()
+			if ref == 0 {
+				panic(wasmruntime.ErrRuntimeNullReference)
+			}
+			ce.pushValue(uint64(wasm.GCRefI31Value(ref, op.B3)))
+			frame.pc++
+
 		default:
 			frame.pc++
 		}
@@ -4678,8 +5016,7 @@ func (ce *callEngine) dropForTailCall(frame *callFrame, f *function) {
 }
 
 func (ce *callEngine) resetPc(frame *callFrame, f *function) (body []unionOperation, bodyLen uint64) {
-	// The compiler is currently allowing proper tail call only across functions
-	// that belong to the same module; thus, we can overwrite the frame in-place.
+	// The frame of a tail call is overwritten in-place, so the stack usage is constant.
 	// For details, see internal/engine/RATIONALE.md
 	frame.f = f
 	frame.base = len(ce.stack)
@@ -4914,14 +5251,28 @@ func (ce *callEngine) callNativeFuncWithListener(ctx context.Context, m *wasm.Mo
 
 // popMemoryOffset takes a memory offset off the stack for use in load and store instructions.
 // As the top of stack value is 64-bit, this ensures it is in range before returning it.
-func (ce *callEngine) popMemoryOffset(op *unionOperation) uint32 {
+func (ce *callEngine) popMemoryOffset(op *unionOperation) uint64 {
+	if op.B3 { // The memory is 64-bit, so the address is i64 and the effective address must not overflow.
+		addr := ce.popValue()
+		offset := addr + op.U2
+		if offset < addr {
+			panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+		}
+		return offset
+	}
 	// Memory addresses are i32; mask to 32 bits to ignore any
 	// garbage in the upper bits of the uint64 stack slot.
 	offset := op.U2 + uint64(uint32(ce.popValue()))
 	if offset > math.MaxUint32 {
 		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 	}
-	return uint32(offset)
+	return offset
+}
+
+// outOfRange returns true if [offset, offset+size) is not within [0, length).
+// Unlike a plain offset+size comparison, this doesn't overflow on i64 operands of 64-bit memories.
+func outOfRange(offset, size, length uint64) bool {
+	return offset > length || size > length-offset
 }
 
 func (ce *callEngine) callGoFuncWithStack(ctx context.Context, m *wasm.ModuleInstance, f *function) {
@@ -4950,6 +5301,46 @@ func (ce *callEngine) callGoFuncWithStack(ctx context.Context, m *wasm.ModuleIns
 // v128Dot performs a dot product of two 64-bit vectors.
 // Note: for some reason (which I suspect is due to a bug in Go compiler's regalloc),
 // inlining this function causes a bug which happens **only when** we run with -race AND arm64 AND Go 1.22.
+// v128RelaxedMaddF32 computes x*y+z, or -(x*y)+z if negate is true, on float32 bits without fusing.
+func v128RelaxedMaddF32(x, y, z uint32, negate bool) uint32 {
+	// The explicit conversions prevent the Go compiler from fusing the multiplication and the addition.
+	m := float32(math.Float32frombits(x) * math.Float32frombits(y))
+	if negate {
+		m = -m
+	}
+	return math.Float32bits(m + math.Float32frombits(z))
+}
+
+// v128RelaxedMaddF64 computes x*y+z, or -(x*y)+z if negate is true, on float64 bits without fusing.
+func v128RelaxedMaddF64(x, y, z uint64, negate bool) uint64 {
+	m := float64(math.Float64frombits(x) * math.Float64frombits(y))
+	if negate {
+		m = -m
+	}
+	return math.Float64bits(m + math.Float64frombits(z))
+}
+
+// v128RelaxedDot computes the four signed saturating 16-bit dot products of adjacent 8-bit lanes in half of a vector.
+func v128RelaxedDot(x, y uint64) (ret uint64) {
+	for i := 0; i < 64; i += 16 {
+		sum := int32(int8(x>>i))*int32(int8(y>>i)) + int32(int8(x>>(i+8)))*int32(int8(y>>(i+8)))
+		if sum > math.MaxInt16 {
+			sum = math.MaxInt16
+		} else if sum < math.MinInt16 {
+			sum = math.MinInt16
+		}
+		ret |= uint64(uint16(sum)) << i
+	}
+	return
+}
+
+// v128RelaxedDotAdd adds the pairwise sums of the 16-bit lanes in half of a vector to the 32-bit lanes of z.
+func v128RelaxedDotAdd(dot, z uint64) uint64 {
+	lo := int32(int16(dot)) + int32(int16(dot>>16)) + int32(uint32(z))
+	hi := int32(int16(dot>>32)) + int32(int16(dot>>48)) + int32(uint32(z>>32))
+	return uint64(uint32(lo)) | uint64(uint32(hi))<<32
+}
+
 func v128Dot(x1Hi, x1Lo, x2Hi, x2Lo uint64) (uint64, uint64) {
 	r1 := int32(int16(x1Lo>>0)) * int32(int16(x2Lo>>0))
 	r2 := int32(int16(x1Lo>>16)) * int32(int16(x2Lo>>16))
@@ -4961,3 +5352,137 @@ func v128Dot(x1Hi, x1Lo, x2Hi, x2Lo uint64) (uint64, uint64) {
 	r8 := int32(int16(x1Hi>>48)) * int32(int16(x2Hi>>48))
 	return uint64(uint32(r1+r2)) | (uint64(uint32(r3+r4)) << 32), uint64(uint32(r5+r6)) | (uint64(uint32(r7+r8)) << 32)
 }
+
+// gcStorageSlots returns the number of slots of wasm.GCObject taken by a value of the storage.
+func gcStorageSlots(storage gcStorage) uint64 {
+	if storage == gcStorageV128 {
+		return 2
+	}
+	return 1
+}
+
+// gcStructNew implements operationKindGCStructNew. The field values are popped after the
+// allocation, so that they are roots if the allocation triggers a collection.
+func (ce *callEngine) gcStructNew(m *wasm.ModuleInstance, op *unionOperation) {
+	ref, obj := m.GCNewObject(wasm.Index(op.U1), op.U2, ce.stack)
+	if !op.B3 {
+		top := len(ce.stack) - int(op.U2)
+		copy(obj.Fields, ce.stack[top:])
+		ce.stack = ce.stack[:top]
+		var slot uint64
+		for _, f := range obj.Type.Fields {
+			storage := gcStorageOf(f.Type)
+			if storage == gcStorageI8 || storage == gcStorageI16 {
+				storeGCValue(obj.Fields, slot, storage, obj.Fields[slot], 0)
+			}
+			slot += gcStorageSlots(storage)
+		}
+	}
+	ce.pushValue(ref)
+}
+
+// gcArrayNew implements operationKindGCArrayNew. As in gcStructNew, the element values are popped
+// after the allocation.
+func (ce *callEngine) gcArrayNew(m *wasm.ModuleInstance, op *unionOperation) {
+	storage, slots := op.B1, gcStorageSlots(op.B1)
+	switch wasm.OpcodeGC(op.B2) {
+	case wasm.OpcodeGCArrayNewDefault:
+		n := uint64(uint32(ce.popValue()))
+		ref, _ := m.GCNewObject(wasm.Index(op.U1), n*slots, ce.stack)
+		ce.pushValue(ref)
+	case wasm.OpcodeGCArrayNew:
+		n := uint64(uint32(ce.popValue()))
+		ref, obj := m.GCNewObject(wasm.Index(op.U1), n*slots, ce.stack)
+		lo, hi := ce.popGCValue(storage)
+		for i := uint64(0); i < n; i++ {
+			storeGCValue(obj.Fields, i*slots, storage, lo, hi)
+		}
+		ce.pushValue(ref)
+	default: // wasm.OpcodeGCArrayNewFixed
+		n := op.U2 * slots
+		ref, obj := m.GCNewObject(wasm.Index(op.U1), n, ce.stack)
+		top := len(ce.stack) - int(n)
+		copy(obj.Fields, ce.stack[top:])
+		ce.stack = ce.stack[:top]
+		if storage == gcStorageI8 || storage == gcStorageI16 {
+			for i := range obj.Fields {
+				storeGCValue(obj.Fields, uint64(i), storage, obj.Fields[i], 0)
+			}
+		}
+		ce.pushValue(ref)
+	}
+}
+
+// gcRefTest returns true if ref is a value of the reference type target, where isFuncType is true
+// if target is a concrete function type.
+func (ce *callEngine) gcRefTest(m *wasm.ModuleInstance, ref uint64, target wasm.ValueType, isFuncType bool) bool {
+	if !isFuncType || ref == 0 {
+		return m.GCRefTest(ref, target)
+	}
+	return m.GCIsSubtypeID(functionFromUintptr(uintptr(ref)).typeID, target.TypeIndex())
+}
+
+// pushGCValue pushes the value at fields[slot] stored as storage, which is sign-extended if signed.
+func (ce *callEngine) pushGCValue(fields []uint64, slot uint64, storage gcStorage, signed bool) {
+	v := fields[slot]
+	switch storage {
+	case gcStorageV128:
+		ce.pushValue(v)
+		ce.pushValue(fields[slot+1])
+		return
+	case gcStorageI8:
+		if signed {
+			v = uint64(uint32(int32(int8(v))))
+		}
+	case gcStorageI16:
+		if signed {
+			v = uint64(uint32(int32(int16(v))))
+		}
+	}
+	ce.pushValue(v)
+}
+
+// popGCValue pops a value to be stored as storage, where hi is only used by v128.
+func (ce *callEngine) popGCValue(storage gcStorage) (lo, hi uint64) {
+	if storage == gcStorageV128 {
+		hi = ce.popValue()
+	}
+	lo = ce.popValue()
+	return
+}
+
+// storeGCValue stores the value lo and hi at fields[slot] as storage, which truncates packed values.
+func storeGCValue(fields []uint64, slot uint64, storage gcStorage, lo, hi uint64) {
+	switch storage {
+	case gcStorageV128:
+		fields[slot], fields[slot+1] = lo, hi
+	case gcStorageI8:
+		fields[slot] = lo & 0xff
+	case gcStorageI16:
+		fields[slot] = lo & 0xffff
+	default:
+		fields[slot] = lo
+	}
+}
+
+// loadGCValuesFromData stores n values of byteSize bytes read from data into fields from the slot.
+func loadGCValuesFromData(fields []uint64, slot uint64, storage gcStorage, byteSize byte, data []byte, n uint64) {
+	slots := gcStorageSlots(storage)
+	for i := uint64(0); i < n; i++ {
+		b := data[i*uint64(byteSize):]
+		var lo, hi uint64
+		switch byteSize {
+		case 1:
+			lo = uint64(b[0])
+		case 2:
+			lo = uint64(binary.LittleEndian.Uint16(b))
+		case 4:
+			lo = uint64(binary.LittleEndian.Uint32(b))
+		case 8:
+			lo = binary.LittleEndian.Uint64(b)
+		default:
+			lo, hi = binary.LittleEndian.Uint64(b), binary.LittleEndian.Uint64(b[8:])
+		}
+		storeGCValue(fields, slot+i*slots, storage, lo, hi)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math"
 	"strings"
+
+	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
 // unsignedInt represents unsigned 32-bit or 64-bit integers.
@@ -413,8 +415,14 @@ func (o operationKind) String() (ret string) {
 		ret = "V128Narrow"
 	case operationKindV128ITruncSatFromF:
 		ret = "V128ITruncSatFromF"
+	case operationKindV128RelaxedMadd:
+		ret = "V128RelaxedMadd"
+	case operationKindV128RelaxedDot:
+		ret = "V128RelaxedDot"
 	case operationKindBuiltinFunctionCheckExitCode:
 		ret = "BuiltinFunctionCheckExitCode"
+	case operationKindBuiltinFunctionConsumeFuel:
+		ret = "BuiltinFunctionConsumeFuel"
 	case operationKindAtomicMemoryWait:
 		ret = "operationKindAtomicMemoryWait"
 	case operationKindAtomicMemoryNotify:
@@ -463,6 +471,48 @@ func (o operationKind) String() (ret string) {
 		ret = "operationKindBrOnNull"
 	case operationKindBrOnNonNull:
 		ret = "operationKindBrOnNonNull"
+	case operationKindRefEq:
+		ret = "operationKindRefEq"
+	case operationKindGCStructNew:
+		ret = "operationKindGCStructNew"
+	case operationKindGCStructGet:
+		ret = "operationKindGCStructGet"
+	case operationKindGCStructSet:
+		ret = "operationKindGCStructSet"
+	case operationKindGCArrayNew:
+		ret = "operationKindGCArrayNew"
+	case operationKindGCArrayNewData:
+		ret = "operationKindGCArrayNewData"
+	case operationKindGCArrayNewElem:
+		ret = "operationKindGCArrayNewElem"
+	case operationKindGCArrayInitData:
+		ret = "operationKindGCArrayInitData"
+	case operationKindGCArrayInitElem:
+		ret = "operationKindGCArrayInitElem"
+	case operationKindGCArrayGet:
+		ret = "operationKindGCArrayGet"
+	case operationKindGCArraySet:
+		ret = "operationKindGCArraySet"
+	case operationKindGCArrayLen:
+		ret = "operationKindGCArrayLen"
+	case operationKindGCArrayFill:
+		ret = "operationKindGCArrayFill"
+	case operationKindGCArrayCopy:
+		ret = "operationKindGCArrayCopy"
+	case operationKindGCRefTest:
+		ret = "operationKindGCRefTest"
+	case operationKindGCRefCast:
+		ret = "operationKindGCRefCast"
+	case operationKindGCBrOnCast:
+		ret = "operationKindGCBrOnCast"
+	case operationKindGCAnyConvertExtern:
+		ret = "operationKindGCAnyConvertExtern"
+	case operationKindGCExternConvertAny:
+		ret = "operationKindGCExternConvertAny"
+	case operationKindGCRefI31:
+		ret = "operationKindGCRefI31"
+	case operationKindGCI31Get:
+		ret = "operationKindGCI31Get"
 	default:
 		panic(fmt.Errorf("unknown operation %d", o))
 	}
@@ -749,9 +799,15 @@ const (
 	operationKindV128Narrow
 	// operationKindV128ITruncSatFromF is the Kind for NewOperationV128ITruncSatFromF.
 	operationKindV128ITruncSatFromF
+	// operationKindV128RelaxedMadd is the Kind for newOperationV128RelaxedMadd.
+	operationKindV128RelaxedMadd
+	// operationKindV128RelaxedDot is the Kind for newOperationV128RelaxedDot.
+	operationKindV128RelaxedDot
 
 	// operationKindBuiltinFunctionCheckExitCode is the Kind for NewOperationBuiltinFunctionCheckExitCode.
 	operationKindBuiltinFunctionCheckExitCode
+	// operationKindBuiltinFunctionConsumeFuel is the Kind for newOperationBuiltinFunctionConsumeFuel.
+	operationKindBuiltinFunctionConsumeFuel
 
 	// operationKindAtomicMemoryWait is the kind for NewOperationAtomicMemoryWait.
 	operationKindAtomicMemoryWait
@@ -807,6 +863,49 @@ const (
 	// operationKindBrOnNonNull is the Kind for br_on_non_null instruction.
 	operationKindBrOnNonNull
 
+	// operationKindRefEq is the Kind for ref.eq instruction.
+	operationKindRefEq
+	// operationKindGCStructNew is the Kind for struct.new and struct.new_default instructions.
+	operationKindGCStructNew
+	// operationKindGCStructGet is the Kind for struct.get, struct.get_s and struct.get_u instructions.
+	operationKindGCStructGet
+	// operationKindGCStructSet is the Kind for struct.set instruction.
+	operationKindGCStructSet
+	// operationKindGCArrayNew is the Kind for array.new, array.new_default and array.new_fixed instructions.
+	operationKindGCArrayNew
+	// operationKindGCArrayNewData is the Kind for array.new_data instruction.
+	operationKindGCArrayNewData
+	// operationKindGCArrayNewElem is the Kind for array.new_elem instruction.
+	operationKindGCArrayNewElem
+	// operationKindGCArrayInitData is the Kind for array.init_data instruction.
+	operationKindGCArrayInitData
+	// operationKindGCArrayInitElem is the Kind for array.init_elem instruction.
+	operationKindGCArrayInitElem
+	// operationKindGCArrayGet is the Kind for array.get, array.get_s and array.get_u instructions.
+	operationKindGCArrayGet
+	// operationKindGCArraySet is the Kind for array.set instruction.
+	operationKindGCArraySet
+	// operationKindGCArrayLen is the Kind for array.len instruction.
+	operationKindGCArrayLen
+	// operationKindGCArrayFill is the Kind for array.fill instruction.
+	operationKindGCArrayFill
+	// operationKindGCArrayCopy is the Kind for array.copy instruction.
+	operationKindGCArrayCopy
+	// operationKindGCRefTest is the Kind for ref.test instruction.
+	operationKindGCRefTest
+	// operationKindGCRefCast is the Kind for ref.cast instruction.
+	operationKindGCRefCast
+	// operationKindGCBrOnCast is the Kind for br_on_cast and br_on_cast_fail instructions.
+	operationKindGCBrOnCast
+	// operationKindGCAnyConvertExtern is the Kind for any.convert_extern instruction.
+	operationKindGCAnyConvertExtern
+	// operationKindGCExternConvertAny is the Kind for extern.convert_any instruction.
+	operationKindGCExternConvertAny
+	// operationKindGCRefI31 is the Kind for ref.i31 instruction.
+	operationKindGCRefI31
+	// operationKindGCI31Get is the Kind for i31.get_s and i31.get_u instructions.
+	operationKindGCI31Get
+
 	// operationKindEnd is always placed at the bottom of this iota definition to be used in the test.
 	operationKindEnd
 )
@@ -819,6 +918,14 @@ func newOperationBuiltinFunctionCheckExitCode() unionOperation {
 	return unionOperation{Kind: operationKindBuiltinFunctionCheckExitCode}
 }
 
+// newOperationBuiltinFunctionConsumeFuel is a constructor for unionOperation with Kind operationKindBuiltinFunctionConsumeFuel.
+//
+// OperationBuiltinFunctionConsumeFuel corresponds to the instruction to consume the fuel of the instructions until
+// the next label or call, counted in U1, and to close the api.Module if the fuel of the call in progress is exhausted.
+func newOperationBuiltinFunctionConsumeFuel() unionOperation {
+	return unionOperation{Kind: operationKindBuiltinFunctionConsumeFuel}
+}
+
 // label is the unique identifier for each block in a single function in interpreterir
 // where "block" consists of multiple operations, and must End with branching operations
 // (e.g. operationKindBr or operationKindBrIf).
@@ -933,6 +1040,9 @@ func (o unionOperation) String() string {
 		operationKindBuiltinFunctionCheckExitCode:
 		return o.Kind.String()
 
+	case operationKindBuiltinFunctionConsumeFuel:
+		return fmt.Sprintf("%s %d", o.Kind, o.U1)
+
 	case operationKindCall,
 		operationKindGlobalGet,
 		operationKindGlobalSet:
@@ -1119,6 +1229,20 @@ func (o unionOperation) String() string {
 			return fmt.Sprintf("%s.%sU", o.Kind, shapeName(o.B1))
 		}
 
+	case operationKindV128RelaxedMadd:
+		if o.B3 {
+			return fmt.Sprintf("%s.%s (negated)", o.Kind, shapeName(o.B1))
+		} else {
+			return fmt.Sprintf("%s.%s", o.Kind, shapeName(o.B1))
+		}
+
+	case operationKindV128RelaxedDot:
+		if o.B3 {
+			return fmt.Sprintf("%s (add)", o.Kind)
+		} else {
+			return o.Kind.String()
+		}
+
 	case operationKindAtomicMemoryWait,
 		operationKindAtomicMemoryNotify,
 		operationKindAtomicFence,
@@ -1137,7 +1261,7 @@ func (o unionOperation) String() string {
 		return o.Kind.String()
 
 	case operationKindTailCallReturnCall:
-		return fmt.Sprintf("%s %d %s", o.Kind, o.U1, label(o.U2).String())
+		return fmt.Sprintf("%s %d", o.Kind, o.U1)
 
 	case operationKindTailCallReturnCallIndirect:
 		return fmt.Sprintf("%s %d %d", o.Kind, o.U1, o.U2)
@@ -1163,6 +1287,43 @@ func (o unionOperation) String() string {
 	case operationKindBrOnNonNull:
 		return fmt.Sprintf("%s %s %s", o.Kind, label(o.U1).String(), label(o.U2).String())
 
+	case operationKindRefEq,
+		operationKindGCArrayLen,
+		operationKindGCAnyConvertExtern,
+		operationKindGCExternConvertAny,
+		operationKindGCRefI31:
+		return o.Kind.String()
+
+	case operationKindGCStructNew:
+		return fmt.Sprintf("%s %d (default=%v)", o.Kind, o.U1, o.B3)
+
+	case operationKindGCStructGet:
+		return fmt.Sprintf("%s %d (signed=%v)", o.Kind, o.U1, o.B3)
+
+	case operationKindGCStructSet:
+		return fmt.Sprintf("%s %d", o.Kind, o.U1)
+
+	case operationKindGCArrayNew:
+		return fmt.Sprintf("%s %d %d %d", o.Kind, o.U1, o.B2, o.U2)
+
+	case operationKindGCArrayNewData, operationKindGCArrayNewElem:
+		return fmt.Sprintf("%s %d %d", o.Kind, o.U1, o.U2)
+
+	case operationKindGCArrayInitData, operationKindGCArrayInitElem:
+		return fmt.Sprintf("%s %d", o.Kind, o.U2)
+
+	case operationKindGCArrayGet, operationKindGCI31Get:
+		return fmt.Sprintf("%s (signed=%v)", o.Kind, o.B3)
+
+	case operationKindGCArraySet, operationKindGCArrayFill, operationKindGCArrayCopy:
+		return fmt.Sprintf("%s %d", o.Kind, o.B1)
+
+	case operationKindGCRefTest, operationKindGCRefCast:
+		return fmt.Sprintf("%s %s", o.Kind, wasm.ValueTypeName(wasm.ValueType(o.U1)))
+
+	case operationKindGCBrOnCast:
+		return fmt.Sprintf("%s %s %s (fail=%v)", o.Kind, label(o.U1).String(), label(o.U2).String(), o.B1 == 1)
+
 	default:
 		panic(fmt.Sprintf("TODO: %v", o.Kind))
 	}
@@ -1345,7 +1506,17 @@ type memoryArg struct {
 
 	// Offset is the address offset added to the instruction's dynamic address operand, yielding a 33-bit effective
 	// address that is the zero-based index at which the memory is accessed. Default to zero.
-	Offset uint32
+	//
+	// When Memory64 is true, this is a 64-bit offset and the effective address must not overflow 64 bits.
+	Offset uint64
+
+	// MemoryIndex is the index of the accessed memory. This is always zero unless
+	// experimental.CoreFeaturesMultiMemory is enabled.
+	MemoryIndex uint32
+
+	// Memory64 is true when the accessed memory is indexed by i64 addresses. This is always false unless
+	// experimental.CoreFeaturesMemory64 is enabled.
+	Memory64 bool
 }
 
 // NewOperationLoad is a constructor for unionOperation with operationKindLoad.
@@ -1355,7 +1526,7 @@ type memoryArg struct {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise load the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationLoad(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindLoad, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindLoad, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationLoad8 is a constructor for unionOperation with operationKindLoad8.
@@ -1365,7 +1536,7 @@ func newOperationLoad(unsignedType unsignedType, arg memoryArg) unionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise load the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationLoad8(signedInt signedInt, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindLoad8, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindLoad8, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationLoad16 is a constructor for unionOperation with operationKindLoad16.
@@ -1375,7 +1546,7 @@ func newOperationLoad8(signedInt signedInt, arg memoryArg) unionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise load the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationLoad16(signedInt signedInt, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindLoad16, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindLoad16, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationLoad32 is a constructor for unionOperation with operationKindLoad32.
@@ -1389,7 +1560,7 @@ func newOperationLoad32(signed bool, arg memoryArg) unionOperation {
 	if signed {
 		sigB = 1
 	}
-	return unionOperation{Kind: operationKindLoad32, B1: sigB, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindLoad32, B1: sigB, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationStore is a constructor for unionOperation with operationKindStore.
@@ -1399,7 +1570,7 @@ func newOperationLoad32(signed bool, arg memoryArg) unionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationStore(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindStore, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindStore, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationStore8 is a constructor for unionOperation with operationKindStore8.
@@ -1409,7 +1580,7 @@ func newOperationStore(unsignedType unsignedType, arg memoryArg) unionOperation
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationStore8(arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindStore8, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindStore8, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationStore16 is a constructor for unionOperation with operationKindStore16.
@@ -1419,7 +1590,7 @@ func newOperationStore8(arg memoryArg) unionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationStore16(arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindStore16, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindStore16, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationStore32 is a constructor for unionOperation with operationKindStore32.
@@ -1429,16 +1600,16 @@ func newOperationStore16(arg memoryArg) unionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func newOperationStore32(arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindStore32, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindStore32, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationMemorySize is a constructor for unionOperation with operationKindMemorySize.
 //
 // This corresponds to wasm.OpcodeMemorySize.
 //
-// The engines are expected to push the current page size of the memory onto the stack.
-func newOperationMemorySize() unionOperation {
-	return unionOperation{Kind: operationKindMemorySize}
+// The engines are expected to push the current page size of the memory at memoryIndex onto the stack.
+func newOperationMemorySize(memoryIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindMemorySize, U1: uint64(memoryIndex)}
 }
 
 // NewOperationMemoryGrow is a constructor for unionOperation with operationKindMemoryGrow.
@@ -1446,10 +1617,10 @@ func newOperationMemorySize() unionOperation {
 // This corresponds to wasm.OpcodeMemoryGrow.
 //
 // The engines are expected to pop one value from the top of the stack, then
-// execute wasm.MemoryInstance Grow with the value, and push the previous
-// page size of the memory onto the stack.
-func newOperationMemoryGrow() unionOperation {
-	return unionOperation{Kind: operationKindMemoryGrow}
+// execute wasm.MemoryInstance Grow on the memory at memoryIndex with the value,
+// and push the previous page size of the memory onto the stack.
+func newOperationMemoryGrow(memoryIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindMemoryGrow, U1: uint64(memoryIndex)}
 }
 
 // NewOperationConstI32 is a constructor for unionOperation with OperationConstI32.
@@ -1931,9 +2102,9 @@ func newOperationSignExtend64From32() unionOperation {
 // This corresponds to wasm.OpcodeMemoryInitName.
 //
 // dataIndex is the index of the data instance in ModuleInstance.DataInstances
-// by which this operation instantiates a part of the memory.
-func newOperationMemoryInit(dataIndex uint32) unionOperation {
-	return unionOperation{Kind: operationKindMemoryInit, U1: uint64(dataIndex)}
+// by which this operation instantiates a part of the memory at memoryIndex.
+func newOperationMemoryInit(dataIndex, memoryIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindMemoryInit, U1: uint64(dataIndex), U2: uint64(memoryIndex)}
 }
 
 // NewOperationDataDrop implements Operation.
@@ -1949,13 +2120,15 @@ func newOperationDataDrop(dataIndex uint32) unionOperation {
 // NewOperationMemoryCopy is a consuctor for unionOperation with operationKindMemoryCopy.
 //
 // This corresponds to wasm.OpcodeMemoryCopyName.
-func newOperationMemoryCopy() unionOperation {
-	return unionOperation{Kind: operationKindMemoryCopy}
+//
+// dstMemoryIndex and srcMemoryIndex are the indexes of the destination and source memories.
+func newOperationMemoryCopy(dstMemoryIndex, srcMemoryIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindMemoryCopy, U1: uint64(dstMemoryIndex), U2: uint64(srcMemoryIndex)}
 }
 
 // NewOperationMemoryFill is a consuctor for unionOperation with operationKindMemoryFill.
-func newOperationMemoryFill() unionOperation {
-	return unionOperation{Kind: operationKindMemoryFill}
+func newOperationMemoryFill(memoryIndex uint32) unionOperation {
+	return unionOperation{Kind: operationKindMemoryFill, U1: uint64(memoryIndex)}
 }
 
 // NewOperationTableInit is a constructor for unionOperation with operationKindTableInit.
@@ -2126,7 +2299,7 @@ const (
 //	wasm.OpcodeVecV128Load32SplatName wasm.OpcodeVecV128Load64SplatName wasm.OpcodeVecV128Load32zeroName
 //	wasm.OpcodeVecV128Load64zeroName
 func newOperationV128Load(loadType v128LoadType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindV128Load, B1: loadType, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindV128Load, B1: loadType, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationV128LoadLane is a constructor for unionOperation with operationKindV128LoadLane.
@@ -2138,7 +2311,7 @@ func newOperationV128Load(loadType v128LoadType, arg memoryArg) unionOperation {
 // laneIndex is >=0 && <(128/LaneSize).
 // laneSize is either 8, 16, 32, or 64.
 func newOperationV128LoadLane(laneIndex, laneSize byte, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindV128LoadLane, B1: laneSize, B2: laneIndex, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindV128LoadLane, B1: laneSize, B2: laneIndex, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationV128Store is a constructor for unionOperation with operationKindV128Store.
@@ -2151,6 +2324,8 @@ func newOperationV128Store(arg memoryArg) unionOperation {
 		Kind: operationKindV128Store,
 		U1:   uint64(arg.Alignment),
 		U2:   uint64(arg.Offset),
+		U3:   uint64(arg.MemoryIndex),
+		B3:   arg.Memory64,
 	}
 }
 
@@ -2169,6 +2344,8 @@ func newOperationV128StoreLane(laneIndex byte, laneSize byte, arg memoryArg) uni
 		B2:   laneIndex,
 		U1:   uint64(arg.Alignment),
 		U2:   uint64(arg.Offset),
+		U3:   uint64(arg.MemoryIndex),
+		B3:   arg.Memory64,
 	}
 }
 
@@ -2712,6 +2889,31 @@ func newOperationV128ITruncSatFromF(originshape shape, signed bool) unionOperati
 	return unionOperation{Kind: operationKindV128ITruncSatFromF, B1: originshape, B3: signed}
 }
 
+// newOperationV128RelaxedMadd is a constructor for unionOperation with operationKindV128RelaxedMadd.
+//
+// This corresponds to
+//
+//	wasm.OpcodeVecF32x4RelaxedMaddName wasm.OpcodeVecF32x4RelaxedNmaddName
+//	wasm.OpcodeVecF64x2RelaxedMaddName wasm.OpcodeVecF64x2RelaxedNmaddName.
+//
+// shape is either shapeF32x4 or shapeF64x2, and negate is true for the nmadd variants.
+// The multiplication and the addition are never fused.
+func newOperationV128RelaxedMadd(shape shape, negate bool) unionOperation {
+	return unionOperation{Kind: operationKindV128RelaxedMadd, B1: shape, B3: negate}
+}
+
+// newOperationV128RelaxedDot is a constructor for unionOperation with operationKindV128RelaxedDot.
+//
+// This corresponds to
+//
+//	wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16SName wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName.
+//
+// add is true for the latter, which sums four products per 32-bit lane and adds the third operand.
+// Both operands are interpreted as signed 8-bit lanes.
+func newOperationV128RelaxedDot(add bool) unionOperation {
+	return unionOperation{Kind: operationKindV128RelaxedDot, B3: add}
+}
+
 // atomicArithmeticOp is the type for the operation kind of atomic arithmetic operations.
 type atomicArithmeticOp byte
 
@@ -2736,7 +2938,7 @@ const (
 //
 //	wasm.OpcodeAtomicWait32Name wasm.OpcodeAtomicWait64Name
 func newOperationAtomicMemoryWait(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicMemoryWait, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicMemoryWait, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicMemoryNotify is a constructor for unionOperation with operationKindAtomicMemoryNotify.
@@ -2745,7 +2947,7 @@ func newOperationAtomicMemoryWait(unsignedType unsignedType, arg memoryArg) unio
 //
 //	wasm.OpcodeAtomicNotifyName
 func newOperationAtomicMemoryNotify(arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicMemoryNotify, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicMemoryNotify, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicFence is a constructor for unionOperation with operationKindAtomicFence.
@@ -2763,7 +2965,7 @@ func newOperationAtomicFence() unionOperation {
 //
 //	wasm.OpcodeAtomicI32LoadName wasm.OpcodeAtomicI64LoadName
 func newOperationAtomicLoad(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicLoad, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicLoad, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicLoad8 is a constructor for unionOperation with operationKindAtomicLoad8.
@@ -2772,7 +2974,7 @@ func newOperationAtomicLoad(unsignedType unsignedType, arg memoryArg) unionOpera
 //
 //	wasm.OpcodeAtomicI32Load8UName wasm.OpcodeAtomicI64Load8UName
 func newOperationAtomicLoad8(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicLoad8, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicLoad8, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicLoad16 is a constructor for unionOperation with operationKindAtomicLoad16.
@@ -2781,7 +2983,7 @@ func newOperationAtomicLoad8(unsignedType unsignedType, arg memoryArg) unionOper
 //
 //	wasm.OpcodeAtomicI32Load16UName wasm.OpcodeAtomicI64Load16UName
 func newOperationAtomicLoad16(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicLoad16, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicLoad16, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicStore is a constructor for unionOperation with operationKindAtomicStore.
@@ -2790,7 +2992,7 @@ func newOperationAtomicLoad16(unsignedType unsignedType, arg memoryArg) unionOpe
 //
 //	wasm.OpcodeAtomicI32StoreName wasm.OpcodeAtomicI64StoreName
 func newOperationAtomicStore(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicStore, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicStore, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicStore8 is a constructor for unionOperation with operationKindAtomicStore8.
@@ -2799,7 +3001,7 @@ func newOperationAtomicStore(unsignedType unsignedType, arg memoryArg) unionOper
 //
 //	wasm.OpcodeAtomicI32Store8UName wasm.OpcodeAtomicI64Store8UName
 func newOperationAtomicStore8(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicStore8, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicStore8, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicStore16 is a constructor for unionOperation with operationKindAtomicStore16.
@@ -2808,7 +3010,7 @@ func newOperationAtomicStore8(unsignedType unsignedType, arg memoryArg) unionOpe
 //
 //	wasm.OpcodeAtomicI32Store16UName wasm.OpcodeAtomicI64Store16UName
 func newOperationAtomicStore16(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicStore16, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicStore16, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicRMW is a constructor for unionOperation with operationKindAtomicRMW.
@@ -2821,7 +3023,7 @@ func newOperationAtomicStore16(unsignedType unsignedType, arg memoryArg) unionOp
 //	wasm.OpcodeAtomicI32RMWOrName wasm.OpcodeAtomicI64RmwOrName
 //	wasm.OpcodeAtomicI32RMWXorName wasm.OpcodeAtomicI64RmwXorName
 func newOperationAtomicRMW(unsignedType unsignedType, arg memoryArg, op atomicArithmeticOp) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMW, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMW, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicRMW8 is a constructor for unionOperation with operationKindAtomicRMW8.
@@ -2834,7 +3036,7 @@ func newOperationAtomicRMW(unsignedType unsignedType, arg memoryArg, op atomicAr
 //	wasm.OpcodeAtomicI32RMW8OrUName wasm.OpcodeAtomicI64Rmw8OrUName
 //	wasm.OpcodeAtomicI32RMW8XorUName wasm.OpcodeAtomicI64Rmw8XorUName
 func newOperationAtomicRMW8(unsignedType unsignedType, arg memoryArg, op atomicArithmeticOp) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMW8, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMW8, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicRMW16 is a constructor for unionOperation with operationKindAtomicRMW16.
@@ -2847,7 +3049,7 @@ func newOperationAtomicRMW8(unsignedType unsignedType, arg memoryArg, op atomicA
 //	wasm.OpcodeAtomicI32RMW16OrUName wasm.OpcodeAtomicI64Rmw16OrUName
 //	wasm.OpcodeAtomicI32RMW16XorUName wasm.OpcodeAtomicI64Rmw16XorUName
 func newOperationAtomicRMW16(unsignedType unsignedType, arg memoryArg, op atomicArithmeticOp) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMW16, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMW16, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicRMWCmpxchg is a constructor for unionOperation with operationKindAtomicRMWCmpxchg.
@@ -2856,7 +3058,7 @@ func newOperationAtomicRMW16(unsignedType unsignedType, arg memoryArg, op atomic
 //
 //	wasm.OpcodeAtomicI32RMWCmpxchgName wasm.OpcodeAtomicI64RmwCmpxchgName
 func newOperationAtomicRMWCmpxchg(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMWCmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMWCmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicRMW8Cmpxchg is a constructor for unionOperation with operationKindAtomicRMW8Cmpxchg.
@@ -2865,7 +3067,7 @@ func newOperationAtomicRMWCmpxchg(unsignedType unsignedType, arg memoryArg) unio
 //
 //	wasm.OpcodeAtomicI32RMW8CmpxchgUName wasm.OpcodeAtomicI64Rmw8CmpxchgUName
 func newOperationAtomicRMW8Cmpxchg(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMW8Cmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMW8Cmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationAtomicRMW16Cmpxchg is a constructor for unionOperation with operationKindAtomicRMW16Cmpxchg.
@@ -2874,7 +3076,7 @@ func newOperationAtomicRMW8Cmpxchg(unsignedType unsignedType, arg memoryArg) uni
 //
 //	wasm.OpcodeAtomicI32RMW16CmpxchgUName wasm.OpcodeAtomicI64Rmw16CmpxchgUName
 func newOperationAtomicRMW16Cmpxchg(unsignedType unsignedType, arg memoryArg) unionOperation {
-	return unionOperation{Kind: operationKindAtomicRMW16Cmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return unionOperation{Kind: operationKindAtomicRMW16Cmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // newOperationTailCallReturnCall is a constructor for unionOperation with operationKindTailCallReturnCall.
@@ -2882,8 +3084,8 @@ func newOperationAtomicRMW16Cmpxchg(unsignedType unsignedType, arg memoryArg) un
 // This corresponds to
 //
 //	wasm.OpcodeTailCallReturnCall.
-func newOperationTailCallReturnCall(functionIndex uint32) unionOperation {
-	return unionOperation{Kind: operationKindTailCallReturnCall, U1: uint64(functionIndex)}
+func newOperationTailCallReturnCall(functionIndex uint32, dropDepth inclusiveRange, l label) unionOperation {
+	return unionOperation{Kind: operationKindTailCallReturnCall, U1: uint64(functionIndex), Us: []uint64{dropDepth.AsU64(), uint64(l)}}
 }
 
 // NewOperationCallIndirect is a constructor for unionOperation with operationKindTailCallReturnCallIndirect.
@@ -2976,3 +3178,193 @@ type pendingCatchClause struct {
 	targetLabel      label // unresolved label, resolved in lowerIR
 	targetStackDepth int   // = targetFrame.originalStackLenWithoutParamUint64
 }
+
+// gcStorage is how a struct field or an array element is stored in wasm.GCObject, which determines
+// how it is read and written by the GC operations.
+type gcStorage = byte
+
+const (
+	// gcStorageScalar is a value of a numeric or reference type stored in a single slot.
+	gcStorageScalar gcStorage = iota
+	// gcStorageV128 is a v128 value stored in two slots.
+	gcStorageV128
+	// gcStorageI8 is a packed i8 value stored zero-extended in a single slot.
+	gcStorageI8
+	// gcStorageI16 is a packed i16 value stored zero-extended in a single slot.
+	gcStorageI16
+)
+
+// gcStorageOf returns the gcStorage of values of the storage type t.
+func gcStorageOf(t wasm.ValueType) gcStorage {
+	switch t {
+	case wasm.ValueTypeV128:
+		return gcStorageV128
+	case wasm.ValueTypeI8:
+		return gcStorageI8
+	case wasm.ValueTypeI16:
+		return gcStorageI16
+	default:
+		return gcStorageScalar
+	}
+}
+
+// gcStorageByteSize returns the size in bytes of values of the storage type t in a data segment.
+func gcStorageByteSize(t wasm.ValueType) byte {
+	switch t {
+	case wasm.ValueTypeI8:
+		return 1
+	case wasm.ValueTypeI16:
+		return 2
+	case wasm.ValueTypeI32, wasm.ValueTypeF32:
+		return 4
+	case wasm.ValueTypeV128:
+		return 16
+	default:
+		return 8
+	}
+}
+
+// newOperationRefEq is a constructor for unionOperation with operationKindRefEq.
+func newOperationRefEq() unionOperation {
+	return unionOperation{Kind: operationKindRefEq}
+}
+
+// newOperationGCStructNew is a constructor for unionOperation with operationKindGCStructNew.
+// U1 is the type index, U2 the number of slots, and B3 is true for struct.new_default.
+func newOperationGCStructNew(typeIndex wasm.Index, slots int, isDefault bool) unionOperation {
+	return unionOperation{Kind: operationKindGCStructNew, U1: uint64(typeIndex), U2: uint64(slots), B3: isDefault}
+}
+
+// newOperationGCStructGet is a constructor for unionOperation with operationKindGCStructGet.
+// U1 is the slot of the field, B1 its gcStorage, and B3 is true for struct.get_s.
+func newOperationGCStructGet(slot int, storage gcStorage, signed bool) unionOperation {
+	return unionOperation{Kind: operationKindGCStructGet, U1: uint64(slot), B1: storage, B3: signed}
+}
+
+// newOperationGCStructSet is a constructor for unionOperation with operationKindGCStructSet.
+// U1 is the slot of the field, and B1 its gcStorage.
+func newOperationGCStructSet(slot int, storage gcStorage) unionOperation {
+	return unionOperation{Kind: operationKindGCStructSet, U1: uint64(slot), B1: storage}
+}
+
+// newOperationGCArrayNew is a constructor for unionOperation with operationKindGCArrayNew.
+// U1 is the type index, B1 the gcStorage of elements, B2 the opcode (array.new, array.new_default or
+// array.new_fixed), and U2 the length of array.new_fixed.
+func newOperationGCArrayNew(typeIndex wasm.Index, storage gcStorage, op wasm.OpcodeGC, fixedLength uint32) unionOperation {
+	return unionOperation{Kind: operationKindGCArrayNew, U1: uint64(typeIndex), B1: storage, B2: byte(op), U2: uint64(fixedLength)}
+}
+
+// newOperationGCArrayNewData is a constructor for unionOperation with operationKindGCArrayNewData.
+// U1 is the type index, U2 the data index, B1 the gcStorage of elements, and B2 their size in bytes.
+func newOperationGCArrayNewData(typeIndex, dataIndex wasm.Index, elem wasm.ValueType) unionOperation {
+	return unionOperation{
+		Kind: operationKindGCArrayNewData,
+		U1:   uint64(typeIndex),
+		U2:   uint64(dataIndex),
+		B1:   gcStorageOf(elem),
+		B2:   gcStorageByteSize(elem),
+	}
+}
+
+// newOperationGCArrayNewElem is a constructor for unionOperation with operationKindGCArrayNewElem.
+// U1 is the type index, and U2 the element index.
+func newOperationGCArrayNewElem(typeIndex, elemIndex wasm.Index) unionOperation {
+	return unionOperation{Kind: operationKindGCArrayNewElem, U1: uint64(typeIndex), U2: uint64(elemIndex)}
+}
+
+// newOperationGCArrayInitData is a constructor for unionOperation with operationKindGCArrayInitData.
+// U2 is the data index, B1 the gcStorage of elements, and B2 their size in bytes.
+func newOperationGCArrayInitData(dataIndex wasm.Index, elem wasm.ValueType) unionOperation {
+	return unionOperation{
+		Kind: operationKindGCArrayInitData,
+		U2:   uint64(dataIndex),
+		B1:   gcStorageOf(elem),
+		B2:   gcStorageByteSize(elem),
+	}
+}
+
+// newOperationGCArrayInitElem is a constructor for unionOperation with operationKindGCArrayInitElem.
+// U2 is the element index.
+func newOperationGCArrayInitElem(elemIndex wasm.Index) unionOperation {
+	return unionOperation{Kind: operationKindGCArrayInitElem, U2: uint64(elemIndex)}
+}
+
+// newOperationGCArrayGet is a constructor for unionOperation with operationKindGCArrayGet.
+// B1 is the gcStorage of elements, and B3 is true for array.get_s.
+func newOperationGCArrayGet(storage gcStorage, signed bool) unionOperation {
+	return unionOperation{Kind: operationKindGCArrayGet, B1: storage, B3: signed}
+}
+
+// newOperationGCArraySet is a constructor for unionOperation with operationKindGCArraySet.
+// B1 is the gcStorage of elements.
+func newOperationGCArraySet(storage gcStorage) unionOperation {
+	return unionOperation{Kind: operationKindGCArraySet, B1: storage}
+}
+
+// newOperationGCArrayLen is a constructor for unionOperation with operationKindGCArrayLen.
+func newOperationGCArrayLen() unionOperation {
+	return unionOperation{Kind: operationKindGCArrayLen}
+}
+
+// newOperationGCArrayFill is a constructor for unionOperation with operationKindGCArrayFill.
+// B1 is the gcStorage of elements.
+func newOperationGCArrayFill(storage gcStorage) unionOperation {
+	return unionOperation{Kind: operationKindGCArrayFill, B1: storage}
+}
+
+// newOperationGCArrayCopy is a constructor for unionOperation with operationKindGCArrayCopy.
+// B1 is the gcStorage of elements.
+func newOperationGCArrayCopy(storage gcStorage) unionOperation {
+	return unionOperation{Kind: operationKindGCArrayCopy, B1: storage}
+}
+
+// newOperationGCRefTest is a constructor for unionOperation with operationKindGCRefTest, or with
+// operationKindGCRefCast when cast is true. U1 is the target wasm.ValueType, and B3 is true if it is
+// a concrete function type.
+func newOperationGCRefTest(target wasm.ValueType, isFuncType, cast bool) unionOperation {
+	kind := operationKindGCRefTest
+	if cast {
+		kind = operationKindGCRefCast
+	}
+	return unionOperation{Kind: kind, U1: uint64(target), B3: isFuncType}
+}
+
+// newOperationGCBrOnCast is a constructor for unionOperation with operationKindGCBrOnCast.
+// If the cast of the reference on top of the stack to Us[0] succeeds (or fails when B1 is 1), this
+// branches to U1 with drop U3, otherwise continues at U2. B3 is true if Us[0] is a concrete function type.
+func newOperationGCBrOnCast(thenTarget, elseTarget label, thenDrop inclusiveRange, target wasm.ValueType, isFuncType, onFail bool) unionOperation {
+	var fail byte
+	if onFail {
+		fail = 1
+	}
+	return unionOperation{
+		Kind: operationKindGCBrOnCast,
+		U1:   uint64(thenTarget),
+		U2:   uint64(elseTarget),
+		U3:   thenDrop.AsU64(),
+		Us:   []uint64{uint64(target)},
+		B1:   fail,
+		B3:   isFuncType,
+	}
+}
+
+// newOperationGCAnyConvertExtern is a constructor for unionOperation with operationKindGCAnyConvertExtern.
+func newOperationGCAnyConvertExtern() unionOperation {
+	return unionOperation{Kind: operationKindGCAnyConvertExtern}
+}
+
+// newOperationGCExternConvertAny is a constructor for unionOperation with operationKindGCExternConvertAny.
+func newOperationGCExternConvertAny() unionOperation {
+	return unionOperation{Kind: operationKindGCExternConvertAny}
+}
+
+// newOperationGCRefI31 is a constructor for unionOperation with operationKindGCRefI31.
+func newOperationGCRefI31() unionOperation {
+	return unionOperation{Kind: operationKindGCRefI31}
+}
+
+// newOperationGCI31Get is a constructor for unionOperation with operationKindGCI31Get.
+// B3 is true for i31.get_s.
+func newOperationGCI31Get(signed bool) unionOperation {
+	return unionOperation{Kind: operationKindGCI31Get, B3: signed}
+}
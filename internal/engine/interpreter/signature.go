@@ -3,6 +3,7 @@ package interpreter
 import (
 	"fmt"
 
+	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
@@ -290,6 +291,11 @@ func (c *compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 	case wasm.OpcodeBrOnNonNull:
 		// Pop a ref (i64). If non-null, push and branch; if null, fall through.
 		return signature_I64_None, nil
+	case wasm.OpcodeRefEq:
+		return signature_I64I64_I32, nil
+	case wasm.OpcodeGCPrefix:
+		// Stack manipulation handled dynamically by the compiler, as it depends on the types.
+		return signature_None_None, nil
 	case wasm.OpcodeDrop:
 		return signature_Unknown_None, nil
 	case wasm.OpcodeSelect, wasm.OpcodeTypedSelect:
@@ -511,6 +517,26 @@ func (c *compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 			return nil, fmt.Errorf("unsupported misc instruction in interpreterir: 0x%x", op)
 		}
 	case wasm.OpcodeVecPrefix:
+		if relaxedOp, ok := wasm.RelaxedVectorOpcode(c.body[c.pc+1:]); ok {
+			switch relaxedOp {
+			case wasm.OpcodeVecI32x4RelaxedTruncF32x4S, wasm.OpcodeVecI32x4RelaxedTruncF32x4U,
+				wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero, wasm.OpcodeVecI32x4RelaxedTruncF64x2UZero:
+				return signature_V128_V128, nil
+			case wasm.OpcodeVecI8x16RelaxedSwizzle,
+				wasm.OpcodeVecF32x4RelaxedMin, wasm.OpcodeVecF32x4RelaxedMax,
+				wasm.OpcodeVecF64x2RelaxedMin, wasm.OpcodeVecF64x2RelaxedMax,
+				wasm.OpcodeVecI16x8RelaxedQ15mulrS, wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S:
+				return signature_V128V128_V128, nil
+			case wasm.OpcodeVecF32x4RelaxedMadd, wasm.OpcodeVecF32x4RelaxedNmadd,
+				wasm.OpcodeVecF64x2RelaxedMadd, wasm.OpcodeVecF64x2RelaxedNmadd,
+				wasm.OpcodeVecI8x16RelaxedLaneselect, wasm.OpcodeVecI16x8RelaxedLaneselect,
+				wasm.OpcodeVecI32x4RelaxedLaneselect, wasm.OpcodeVecI64x2RelaxedLaneselect,
+				wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS:
+				return signature_V128V128V128_V32, nil
+			default:
+				return nil, fmt.Errorf("unsupported vector instruction in interpreterir: %#x", relaxedOp)
+			}
+		}
 		switch vecOp := c.body[c.pc+1]; vecOp {
 		case wasm.OpcodeVecV128Const:
 			return signature_None_V128, nil
@@ -823,3 +849,139 @@ func wasmValueTypeToUnsignedInOutSignature(vt wasm.ValueType) *signature {
 	}
 	panic("unreachable")
 }
+
+// memory64Signature returns s with the address operands and results of the given memory instruction
+// widened to i64 when the instruction accesses 64-bit memories. Otherwise, s is returned as-is.
+func (c *compiler) memory64Signature(op wasm.Opcode, s *signature) (*signature, error) {
+	var addrIn []int
+	var addrOut bool
+	switch {
+	case op >= wasm.OpcodeI32Load && op <= wasm.OpcodeI64Store32:
+		memoryIndex, err := c.peekMemArgIndex(c.pc + 1)
+		if err != nil {
+			return nil, err
+		}
+		if !c.memories[memoryIndex].Is64 {
+			return s, nil
+		}
+		addrIn = []int{0}
+	case op == wasm.OpcodeMemorySize || op == wasm.OpcodeMemoryGrow:
+		memoryIndex, _, err := leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return nil, fmt.Errorf("reading memory index: %w", err)
+		}
+		if !c.memories[memoryIndex].Is64 {
+			return s, nil
+		}
+		if op == wasm.OpcodeMemoryGrow {
+			addrIn = []int{0}
+		}
+		addrOut = true
+	case op == wasm.OpcodeVecPrefix:
+		switch c.body[c.pc+1] {
+		case wasm.OpcodeVecV128Load, wasm.OpcodeVecV128Load8x8s, wasm.OpcodeVecV128Load8x8u,
+			wasm.OpcodeVecV128Load16x4s, wasm.OpcodeVecV128Load16x4u, wasm.OpcodeVecV128Load32x2s,
+			wasm.OpcodeVecV128Load32x2u, wasm.OpcodeVecV128Load8Splat, wasm.OpcodeVecV128Load16Splat,
+			wasm.OpcodeVecV128Load32Splat, wasm.OpcodeVecV128Load64Splat, wasm.OpcodeVecV128Load32zero,
+			wasm.OpcodeVecV128Load64zero, wasm.OpcodeVecV128Load8Lane, wasm.OpcodeVecV128Load16Lane,
+			wasm.OpcodeVecV128Load32Lane, wasm.OpcodeVecV128Load64Lane, wasm.OpcodeVecV128Store,
+			wasm.OpcodeVecV128Store8Lane, wasm.OpcodeVecV128Store16Lane, wasm.OpcodeVecV128Store32Lane,
+			wasm.OpcodeVecV128Store64Lane:
+		default:
+			return s, nil
+		}
+		memoryIndex, err := c.peekMemArgIndex(c.pc + 2)
+		if err != nil {
+			return nil, err
+		}
+		if !c.memories[memoryIndex].Is64 {
+			return s, nil
+		}
+		addrIn = []int{0}
+	case op == wasm.OpcodeAtomicPrefix:
+		if c.body[c.pc+1] == wasm.OpcodeAtomicFence {
+			return s, nil
+		}
+		memoryIndex, err := c.peekMemArgIndex(c.pc + 2)
+		if err != nil {
+			return nil, err
+		}
+		if !c.memories[memoryIndex].Is64 {
+			return s, nil
+		}
+		addrIn = []int{0}
+	case op == wasm.OpcodeMiscPrefix:
+		pc := c.pc + 2
+		switch c.body[c.pc+1] {
+		case wasm.OpcodeMiscMemoryInit:
+			_, num, err := leb128.LoadUint32(c.body[pc:])
+			if err != nil {
+				return nil, fmt.Errorf("reading data index: %w", err)
+			}
+			memoryIndex, _, err := leb128.LoadUint32(c.body[pc+uint64(num):])
+			if err != nil {
+				return nil, fmt.Errorf("reading memory index: %w", err)
+			}
+			if !c.memories[memoryIndex].Is64 {
+				return s, nil
+			}
+			addrIn = []int{0}
+		case wasm.OpcodeMiscMemoryCopy:
+			dst, num, err := leb128.LoadUint32(c.body[pc:])
+			if err != nil {
+				return nil, fmt.Errorf("reading memory index: %w", err)
+			}
+			src, _, err := leb128.LoadUint32(c.body[pc+uint64(num):])
+			if err != nil {
+				return nil, fmt.Errorf("reading memory index: %w", err)
+			}
+			dst64, src64 := c.memories[dst].Is64, c.memories[src].Is64
+			if dst64 {
+				addrIn = append(addrIn, 0)
+			}
+			if src64 {
+				addrIn = append(addrIn, 1)
+			}
+			if dst64 && src64 {
+				addrIn = append(addrIn, 2)
+			}
+		case wasm.OpcodeMiscMemoryFill:
+			memoryIndex, _, err := leb128.LoadUint32(c.body[pc:])
+			if err != nil {
+				return nil, fmt.Errorf("reading memory index: %w", err)
+			}
+			if !c.memories[memoryIndex].Is64 {
+				return s, nil
+			}
+			addrIn = []int{0, 2}
+		}
+	}
+	if len(addrIn) == 0 && !addrOut {
+		return s, nil
+	}
+
+	ret := &signature{in: append([]unsignedType{}, s.in...), out: append([]unsignedType{}, s.out...)}
+	for _, i := range addrIn {
+		ret.in[i] = unsignedTypeI64
+	}
+	if addrOut {
+		ret.out[0] = unsignedTypeI64
+	}
+	return ret, nil
+}
+
+// peekMemArgIndex returns the memory index encoded in the memarg immediate at pc without advancing c.pc.
+func (c *compiler) peekMemArgIndex(pc uint64) (uint32, error) {
+	alignment, num, err := leb128.LoadUint32(c.body[pc:])
+	if err != nil {
+		return 0, fmt.Errorf("reading alignment: %w", err)
+	}
+	if alignment&wasm.MemArgMemoryIndexFlag == 0 {
+		return 0, nil
+	}
+	memoryIndex, _, err := leb128.LoadUint32(c.body[pc+num:])
+	if err != nil {
+		return 0, fmt.Errorf("reading memory index: %w", err)
+	}
+	return memoryIndex, nil
+}
@@ -0,0 +1,424 @@
+package interpreter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/filecache"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// TierUpThreshold is the number of the calls and the back-edges of a function after which it is swapped for its
+// compiled code. This is exported so that the tests of the other packages can lower it.
+var TierUpThreshold uint32 = 1000
+
+// Tier is the compiler to which NewTieredEngine tiers up the hot functions. This is implemented in
+// internal/engine/tiered, so that the interpreter doesn't depend on the compiler.
+//
+// The compiled code runs on the wasm.ModuleInstance owned by the interpreter, and calls the functions, including the
+// local ones, via the function references of the interpreter. A function reference points to the function, whose
+// first field points to its tieredFunction, whose first field holds the entry returned by either NewFunctionEntry or
+// NewCallbackEntry.
+type Tier interface {
+	// CompileModule compiles what the functions of the module compiled by CompileFunction share. This is called on
+	// the background before the first CompileFunction of the module, with the same context.
+	CompileModule(ctx context.Context, module *wasm.Module, ensureTermination bool) error
+
+	// DeleteCompiledModule releases the module compiled by CompileModule.
+	DeleteCompiledModule(module *wasm.Module)
+
+	// CompileFunction compiles the local function at the index of the module. This is called on the background when
+	// the function gets hot, with the context given to the wasm.Engine CompileModule, which is cancelled by the
+	// wasm.Engine Close instead.
+	CompileFunction(ctx context.Context, module *wasm.Module, index wasm.Index, ensureTermination bool) error
+
+	// DeleteCompiledFunction releases the function compiled by CompileFunction.
+	DeleteCompiledFunction(module *wasm.Module, index wasm.Index)
+
+	// NewModuleEngine instantiates the module compiled by CompileModule on the instance, whose functions are called
+	// via functions. This returns the wasm.ModuleEngine, only used to create the api.Function of the local functions
+	// and to be notified of the memory growth.
+	NewModuleEngine(module *wasm.Module, instance *wasm.ModuleInstance, functions []wasm.Reference) (wasm.ModuleEngine, error)
+
+	// NewFunctionEntry returns the entry of the local function at the index compiled by CompileFunction, running on
+	// the wasm.ModuleEngine returned by NewModuleEngine, whose api.Function of the function calls it from then on.
+	NewFunctionEntry(me wasm.ModuleEngine, index wasm.Index) (unsafe.Pointer, error)
+
+	// NewCallbackEntry returns the entry calling fn with the caller module instance, the callerValue given to
+	// CallWithStack and the stack holding the params, to which the results are written.
+	NewCallbackEntry(typ *wasm.FunctionType, typeID wasm.FunctionTypeID,
+		fn func(ctx context.Context, caller *wasm.ModuleInstance, callerValue interface{}, stack []uint64)) unsafe.Pointer
+
+	// CallWithStack calls the api.Function of the wasm.ModuleEngine returned by NewModuleEngine, passing callerValue
+	// to the callbacks of NewCallbackEntry. The panics raised during the call are propagated as is, after the frames
+	// of the compiled code unwound by them are added to unwound.
+	CallWithStack(ctx context.Context, f api.Function, callerValue interface{}, unwound FrameRecorder, stack []uint64) error
+
+	// Close releases the compiled modules and functions. This is called once the compilations on the background are
+	// done.
+	Close() error
+}
+
+// FrameRecorder records the frames of the compiled code unwound by a panic, from the innermost one.
+type FrameRecorder interface {
+	AddFrame(funcName string, paramTypes, resultTypes []api.ValueType, sources []string)
+}
+
+// NewTieredEngine returns the interpreter which compiles the hot functions with the tier on the background, and then
+// calls their compiled code instead of interpreting them. The compiled code runs on the same wasm.ModuleInstance, so
+// the functions, memories, globals and tables are shared between both engines.
+//
+// The compilation is per function: each function is compiled once it gets hot, and then swapped in on its next call,
+// while the others keep being interpreted. There is no on-stack replacement, so a function being interpreted keeps
+// being interpreted until it returns, even if it is a long-running loop, though what it calls is swapped in.
+//
+// The wasm.Engine Close cancels the compilations on the background, and waits for them before closing the tier.
+func NewTieredEngine(ctx context.Context, enabledFeatures api.CoreFeatures, fc filecache.Cache, tier Tier) wasm.Engine {
+	e := NewEngine(ctx, enabledFeatures, fc).(*engine)
+	e.tier = tier
+	e.tierCompilations = newTieredCompilations()
+	return e
+}
+
+type (
+	// tieredCompilations tracks the compilations by the Tier on the background, so that the engine Close cancels
+	// and waits for them.
+	tieredCompilations struct {
+		// ctx is cancelled by close.
+		ctx    context.Context
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+		// mux guards closed, so that no compilation starts once wg is waited.
+		mux    sync.Mutex
+		closed bool
+	}
+
+	// tieredModule is the state of the compilation of a module by the Tier, which is shared by its instances.
+	tieredModule struct {
+		tier              Tier
+		module            *wasm.Module
+		ensureTermination bool
+		compilations      *tieredCompilations
+		// ctx is the one given to CompileModule without the cancellation, so that it carries the values such as
+		// experimental.WithInliningBudget to the compilation on the background.
+		ctx context.Context
+		// once compiles the module before the first of its functions.
+		once sync.Once
+		// mux guards deleted, compiled and the compiled field of functions.
+		mux      sync.Mutex
+		deleted  bool
+		compiled bool
+		// functions are the states of the compilation of the local functions.
+		functions []tieredModuleFunction
+	}
+
+	// tieredModuleFunction is the state of the compilation of a local function by the Tier.
+	tieredModuleFunction struct {
+		// requested is set once the compilation is started.
+		requested atomic.Bool
+		// compiled is set when the compilation succeeded, unless the module is deleted.
+		compiled atomic.Bool
+	}
+
+	// tieredInstance is the state of the compiled code running on a module instance.
+	tieredInstance struct {
+		module   *tieredModule
+		instance *wasm.ModuleInstance
+		me       *moduleEngine
+		compiled atomic.Pointer[compiledInstance]
+		// mux serializes the instantiation of the module and of its functions.
+		mux sync.Mutex
+		// failed is set if the compiled code couldn't be instantiated.
+		failed atomic.Bool
+	}
+
+	// compiledInstance is the instance of the compiled module, on which its compiled functions run.
+	compiledInstance struct {
+		me wasm.ModuleEngine
+		// functions are the pools of api.Function calling the compiled code, indexed by the local function index.
+		functions []sync.Pool
+	}
+
+	// compiledFrame is the frame of the compiled code unwound by a panic.
+	compiledFrame struct {
+		funcName                string
+		paramTypes, resultTypes []api.ValueType
+		sources                 []string
+	}
+
+	compiledFrames []compiledFrame
+
+	// tieredFunction is held by all the functions of the tiered engine, including the host ones, so that the
+	// compiled code can call them via function references. See Tier.
+	tieredFunction struct {
+		// entry is what the compiled code calls, which is swapped when this is tiered up. This must be the first
+		// field, and accessed atomically.
+		entry unsafe.Pointer
+		// compiled is the pool of compiledInstance.functions for this function, set when this is tiered up.
+		compiled atomic.Pointer[sync.Pool]
+		// hotness counts the calls and the back-edges of this function.
+		hotness atomic.Uint32
+		// interpreted is the entry calling back the interpreter, which entry points to until this is tiered up.
+		interpreted unsafe.Pointer
+		// instance is nil if this never tiers up, e.g. this is a host function.
+		instance *tieredInstance
+		// index is the local function index.
+		index wasm.Index
+	}
+)
+
+func newTieredCompilations() *tieredCompilations {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &tieredCompilations{ctx: ctx, cancel: cancel}
+}
+
+// start calls compile on the background with ctx, which is also cancelled by close, unless it is already closed.
+func (c *tieredCompilations) start(ctx context.Context, compile func(ctx context.Context)) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(c.ctx, cancel)
+		defer stop()
+		compile(ctx)
+	}()
+}
+
+// close cancels the compilations on the background, and waits for them.
+func (c *tieredCompilations) close() {
+	c.mux.Lock()
+	c.closed = true
+	c.mux.Unlock()
+	c.cancel()
+	c.wg.Wait()
+}
+
+// newTieredModule returns the tieredModule for the module, or nil if it cannot be compiled with the state shared.
+func (e *engine) newTieredModule(ctx context.Context, module *wasm.Module, funcs []compiledFunction,
+	listeners []experimental.FunctionListener, ensureTermination, fuelMetering bool,
+) *tieredModule {
+	if e.tier == nil || module.IsHostModule || len(listeners) > 0 || fuelMetering || module.UsesGC ||
+		len(module.TagSection) > 0 || module.ImportTagCount > 0 || len(funcs) == 0 {
+		return nil
+	}
+	for i := range funcs {
+		if len(funcs[i].exceptionTable) > 0 {
+			return nil
+		}
+	}
+	return &tieredModule{
+		tier:              e.tier,
+		module:            module,
+		ensureTermination: ensureTermination,
+		compilations:      e.tierCompilations,
+		ctx:               context.WithoutCancel(ctx),
+		functions:         make([]tieredModuleFunction, len(funcs)),
+	}
+}
+
+// requestCompile starts the compilation of the local function at the index on the background unless it is already
+// started.
+func (m *tieredModule) requestCompile(index wasm.Index) {
+	if m.functions[index].requested.CompareAndSwap(false, true) {
+		m.compilations.start(m.ctx, func(ctx context.Context) { m.compile(ctx, index) })
+	}
+}
+
+// compile compiles the local function at the index, compiling the module first if not yet.
+func (m *tieredModule) compile(ctx context.Context, index wasm.Index) {
+	m.once.Do(func() {
+		err := m.tier.CompileModule(ctx, m.module, m.ensureTermination)
+		m.mux.Lock()
+		defer m.mux.Unlock()
+		if err != nil {
+			return
+		} else if m.deleted {
+			m.tier.DeleteCompiledModule(m.module)
+		} else {
+			m.compiled = true
+		}
+	})
+	m.mux.Lock()
+	compiled := m.compiled
+	m.mux.Unlock()
+	if !compiled {
+		return
+	}
+
+	err := m.tier.CompileFunction(ctx, m.module, index, m.ensureTermination)
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if err != nil {
+		return
+	} else if m.deleted {
+		m.tier.DeleteCompiledFunction(m.module, index)
+	} else {
+		m.functions[index].compiled.Store(true)
+	}
+}
+
+func (m *tieredModule) delete() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.deleted = true
+	if !m.compiled {
+		return
+	}
+	m.tier.DeleteCompiledModule(m.module)
+	for i := range m.functions {
+		if m.functions[i].compiled.Load() {
+			m.tier.DeleteCompiledFunction(m.module, wasm.Index(i))
+		}
+	}
+}
+
+// newTieredFunctions sets the tieredFunction to the local functions of the module engine.
+func (e *engine) newTieredFunctions(me *moduleEngine, module *wasm.Module, instance *wasm.ModuleInstance, m *tieredModule) {
+	if m != nil {
+		me.tier = &tieredInstance{module: m, instance: instance, me: me}
+	}
+	tiers := make([]tieredFunction, len(module.FunctionSection))
+	for i := range tiers {
+		t := &tiers[i]
+		t.instance, t.index = me.tier, wasm.Index(i)
+		f := &me.functions[module.ImportFunctionCount+wasm.Index(i)]
+		t.interpreted = e.tier.NewCallbackEntry(f.funcType, f.typeID, func(ctx context.Context, caller *wasm.ModuleInstance, ce interface{}, stack []uint64) {
+			ce.(*callEngine).callFromCompiledFunc(ctx, caller, f, stack)
+		})
+		t.entry = t.interpreted
+		f.tier = t
+	}
+}
+
+// instantiate returns the compiledInstance, instantiating the compiled module if not yet. This must be called with
+// mux held.
+func (i *tieredInstance) instantiate() *compiledInstance {
+	if c := i.compiled.Load(); c != nil {
+		return c
+	}
+	m := i.module
+	functions := make([]wasm.Reference, int(m.module.ImportFunctionCount)+len(m.module.FunctionSection))
+	for j := range functions {
+		functions[j] = i.me.FunctionInstanceReference(wasm.Index(j))
+	}
+	me, err := m.tier.NewModuleEngine(m.module, i.instance, functions)
+	if err != nil {
+		return nil
+	}
+	c := &compiledInstance{me: me, functions: make([]sync.Pool, len(m.module.FunctionSection))}
+	for j := range c.functions {
+		index := m.module.ImportFunctionCount + wasm.Index(j)
+		c.functions[j].New = func() interface{} { return me.NewFunction(index) }
+	}
+	i.compiled.Store(c)
+	// The memory might have grown while instantiating, before MemoryGrown could see c.
+	me.MemoryGrown()
+	return c
+}
+
+// tierUp swaps in the compiled code of t, and returns the pool of api.Function calling it, or nil if it couldn't be
+// instantiated, e.g. the module was deleted before.
+func (i *tieredInstance) tierUp(t *tieredFunction) *sync.Pool {
+	if i.failed.Load() {
+		return nil
+	}
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	if p := t.compiled.Load(); p != nil || i.failed.Load() {
+		return p
+	}
+	c := i.instantiate()
+	if c == nil {
+		i.failed.Store(true)
+		return nil
+	}
+	entry, err := i.module.tier.NewFunctionEntry(c.me, t.index)
+	if err != nil {
+		i.failed.Store(true)
+		return nil
+	}
+	p := &c.functions[t.index]
+	atomic.StorePointer(&t.entry, entry)
+	t.compiled.Store(p)
+	return p
+}
+
+// tierUp counts the call to this function, and returns the pool of api.Function calling its compiled code if it is
+// tiered up. Once this is compiled, this is tiered up regardless of the count, as this got hot in another instance.
+func (t *tieredFunction) tierUp() *sync.Pool {
+	if p := t.compiled.Load(); p != nil {
+		return p
+	}
+	i := t.instance
+	if i == nil {
+		return nil
+	}
+	m := i.module
+	if !m.functions[t.index].compiled.Load() {
+		if t.hotness.Add(1) == TierUpThreshold {
+			m.requestCompile(t.index)
+		}
+		return nil
+	}
+	return i.tierUp(t)
+}
+
+// countBackEdge counts the back-edge of this function, which requests its compilation when this gets hot, as the
+// function can be hot even if it is rarely called, e.g. the main loop. This doesn't swap in the compiled code of this
+// function until its next call, as there is no on-stack replacement.
+func (t *tieredFunction) countBackEdge() {
+	if t.instance != nil && t.hotness.Add(1) == TierUpThreshold {
+		t.instance.module.requestCompile(t.index)
+	}
+}
+
+// callCompiledFunc calls the compiled code of f with the parameters on the stack, from the pool returned by
+// tieredFunction.tierUp.
+func (ce *callEngine) callCompiledFunc(ctx context.Context, f *function, pool *sync.Pool) {
+	typ := f.funcType
+	stack := make([]uint64, max(typ.ParamNumInUint64, typ.ResultNumInUint64))
+	ce.popValues(stack[:typ.ParamNumInUint64])
+	frame := &callFrame{f: f, base: len(ce.stack)}
+	ce.pushFrame(frame)
+
+	fn := pool.Get().(api.Function)
+	err := f.tier.instance.module.tier.CallWithStack(ctx, fn, ce, frame, stack)
+	pool.Put(fn)
+	if err != nil {
+		panic(err)
+	}
+
+	ce.popFrame()
+	ce.pushValues(stack[:typ.ResultNumInUint64])
+}
+
+// AddFrame implements FrameRecorder.
+func (frame *callFrame) AddFrame(funcName string, paramTypes, resultTypes []api.ValueType, sources []string) {
+	if frame.compiled == nil {
+		frame.compiled = &compiledFrames{}
+	}
+	*frame.compiled = append(*frame.compiled, compiledFrame{
+		funcName:    funcName,
+		paramTypes:  paramTypes,
+		resultTypes: resultTypes,
+		sources:     sources,
+	})
+}
+
+// callFromCompiledFunc calls f from the compiled code of the caller module instance m.
+func (ce *callEngine) callFromCompiledFunc(ctx context.Context, m *wasm.ModuleInstance, f *function, stack []uint64) {
+	typ := f.funcType
+	ce.pushValues(stack[:typ.ParamNumInUint64])
+	ce.callFunction(ctx, m, f)
+	ce.popValues(stack[:typ.ResultNumInUint64])
+}
@@ -0,0 +1,18 @@
+package interpreter
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestTieredFunction_entryOffset(t *testing.T) {
+	// The compiled code loads the entry from the function reference twice. See Tier.
+	require.Zero(t, unsafe.Offsetof(function{}.tier))
+	require.Zero(t, unsafe.Offsetof(tieredFunction{}.entry))
+
+	entry := unsafe.Pointer(new(int))
+	f := &function{tier: &tieredFunction{entry: entry}}
+	require.Equal(t, entry, **(**unsafe.Pointer)(unsafe.Pointer(f)))
+}
@@ -32,7 +32,7 @@ func TestCompile_LocalSetWithMultipleLocals_I32(t *testing.T) {
 	for _, tp := range module.TypeSection {
 		tp.CacheNumInUint64()
 	}
-	c, err := newCompiler(api.CoreFeaturesV2, 0, module, false)
+	c, err := newCompiler(api.CoreFeaturesV2, 0, module, false, false)
 	require.NoError(t, err)
 
 	result, err := c.Next()
@@ -85,7 +85,7 @@ func TestCompile_LocalSetWithMultipleConcreteRefLocals(t *testing.T) {
 	}
 
 	features := api.CoreFeaturesV2 | experimental.CoreFeaturesTypedFunctionReferences | experimental.CoreFeaturesTailCall
-	c, err := newCompiler(features, 0, module, false)
+	c, err := newCompiler(features, 0, module, false, false)
 	require.NoError(t, err)
 
 	result, err := c.Next()
@@ -137,7 +137,7 @@ func TestCompile_LocalSetWithMultipleFuncrefLocals(t *testing.T) {
 	for i := range module.TypeSection {
 		module.TypeSection[i].CacheNumInUint64()
 	}
-	c, err := newCompiler(api.CoreFeaturesV2, 0, module, false)
+	c, err := newCompiler(api.CoreFeaturesV2, 0, module, false, false)
 	require.NoError(t, err)
 
 	result, err := c.Next()
@@ -0,0 +1,57 @@
+// Package tiered wires the interpreter and wazevo together, so that neither of them depends on the other:
+// the interpreter tiers up the hot functions to the code compiled by wazevo via interpreter.Tier.
+package tiered
+
+import (
+	"context"
+	"unsafe"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/engine/interpreter"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo"
+	"github.com/tetratelabs/wazero/internal/filecache"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// NewEngine returns the interpreter tiering up the hot functions to wazevo. See interpreter.NewTieredEngine.
+//
+// If the compiler is not supported on the platform, this is the same as interpreter.NewEngine.
+func NewEngine(ctx context.Context, enabledFeatures api.CoreFeatures, fc filecache.Cache) wasm.Engine {
+	if !platform.CompilerSupports(enabledFeatures) {
+		return interpreter.NewEngine(ctx, enabledFeatures, fc)
+	}
+	return interpreter.NewTieredEngine(ctx, enabledFeatures, fc, newTier(ctx, enabledFeatures))
+}
+
+// tier implements interpreter.Tier with wazevo.SharedStateEngine.
+type tier struct {
+	*wazevo.SharedStateEngine
+}
+
+var _ interpreter.Tier = tier{}
+
+func newTier(ctx context.Context, enabledFeatures api.CoreFeatures) tier {
+	return tier{wazevo.NewSharedStateEngine(ctx, enabledFeatures)}
+}
+
+// NewFunctionEntry implements interpreter.Tier NewFunctionEntry.
+func (t tier) NewFunctionEntry(me wasm.ModuleEngine, index wasm.Index) (unsafe.Pointer, error) {
+	entry, err := t.SharedStateEngine.NewFunctionEntry(me, index)
+	if err != nil {
+		return nil, err
+	}
+	return unsafe.Pointer(entry), nil
+}
+
+// NewCallbackEntry implements interpreter.Tier NewCallbackEntry.
+func (t tier) NewCallbackEntry(typ *wasm.FunctionType, typeID wasm.FunctionTypeID,
+	fn func(ctx context.Context, caller *wasm.ModuleInstance, callerValue interface{}, stack []uint64),
+) unsafe.Pointer {
+	return unsafe.Pointer(t.NewGoFunctionEntry(typ, typeID, fn))
+}
+
+// CallWithStack implements interpreter.Tier CallWithStack.
+func (t tier) CallWithStack(ctx context.Context, f api.Function, callerValue interface{}, unwound interpreter.FrameRecorder, stack []uint64) error {
+	return t.SharedStateEngine.CallWithStack(ctx, f, callerValue, unwound, stack)
+}
@@ -0,0 +1,338 @@
+package tiered
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/engine/interpreter"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+var testCtx = context.Background()
+
+// countingTier counts the calls from the interpreter to the compiled code per function name, and records the names of
+// the compiled functions.
+type countingTier struct {
+	tier
+	mux      sync.Mutex
+	calls    map[string]int
+	compiled []string
+}
+
+// CompileFunction implements interpreter.Tier CompileFunction.
+func (t *countingTier) CompileFunction(ctx context.Context, module *wasm.Module, index wasm.Index, ensureTermination bool) error {
+	err := t.tier.CompileFunction(ctx, module, index, ensureTermination)
+	if err == nil {
+		t.mux.Lock()
+		t.compiled = append(t.compiled, module.FunctionDefinition(module.ImportFunctionCount+index).Name())
+		t.mux.Unlock()
+	}
+	return err
+}
+
+// CallWithStack implements interpreter.Tier CallWithStack.
+func (t *countingTier) CallWithStack(ctx context.Context, f api.Function, callerValue interface{}, unwound interpreter.FrameRecorder, stack []uint64) error {
+	t.mux.Lock()
+	t.calls[f.Definition().Name()]++
+	t.mux.Unlock()
+	return t.tier.CallWithStack(ctx, f, callerValue, unwound, stack)
+}
+
+func (t *countingTier) callCount(name string) int {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.calls[name]
+}
+
+// compiledFunctions returns the sorted names of the compiled functions.
+func (t *countingTier) compiledFunctions() []string {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	ret := slices.Clone(t.compiled)
+	slices.Sort(ret)
+	return ret
+}
+
+// requireCompiled waits for the compilation of the functions on the background, whose names must be sorted.
+func (t *countingTier) requireCompiled(tb testing.TB, names ...string) {
+	for i := 0; len(t.compiledFunctions()) < len(names); i++ {
+		require.True(tb, i < 10000, "not compiled")
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(tb, names, t.compiledFunctions())
+}
+
+// newTestStore returns the store of the engine, and the function instantiating the module in it.
+func newTestStore(t *testing.T, e wasm.Engine) func(m *wasm.Module, name string) *wasm.ModuleInstance {
+	s := wasm.NewStore(api.CoreFeaturesV2, e)
+	return func(m *wasm.Module, name string) *wasm.ModuleInstance {
+		bin := binaryencoding.EncodeModule(m)
+		m, err := binary.DecodeModule(bin, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, false, false)
+		require.NoError(t, err)
+		require.NoError(t, m.Validate(api.CoreFeaturesV2))
+		m.BuildMemoryDefinitions()
+		m.AssignModuleID(bin, nil, false, false, false, 0)
+		require.NoError(t, e.CompileModule(testCtx, m, nil, false, false, false))
+		typeIDs, err := s.GetFunctionTypeIDs(m.TypeSection)
+		require.NoError(t, err)
+		inst, err := s.Instantiate(testCtx, m, name, nil, typeIDs)
+		require.NoError(t, err)
+		return inst
+	}
+}
+
+func TestNewEngine(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	defer func(threshold uint32) { interpreter.TierUpThreshold = threshold }(interpreter.TierUpThreshold)
+	interpreter.TierUpThreshold = 2
+
+	const i32 = wasm.ValueTypeI32
+	tier := &countingTier{tier: newTier(testCtx, api.CoreFeaturesV2), calls: map[string]int{}}
+	e := interpreter.NewTieredEngine(testCtx, api.CoreFeaturesV2, nil, tier)
+	defer e.Close()
+	instantiate := newTestStore(t, e)
+
+	// env.check returns the param, or traps if it is zero.
+	instantiate(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Eqz,
+			wasm.OpcodeIf, 0x40,
+			wasm.OpcodeUnreachable,
+			wasm.OpcodeEnd,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []wasm.Export{{Name: "check", Type: wasm.ExternTypeFunc, Index: 0}},
+		NameSection:   &wasm.NameSection{ModuleName: "env", FunctionNames: wasm.NameMap{{Index: 0, Name: "check"}}},
+	}, "env")
+
+	// "add" adds env.check(param) to the global and the memory at zero, and returns the global.
+	// "grow" grows the memory.
+	m := &wasm.Module{
+		TypeSection: []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+		ImportSection: []wasm.Import{
+			{Module: "env", Name: "check", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		},
+		ImportFunctionCount: 1,
+		FunctionSection:     []wasm.Index{0, 0},
+		GlobalSection: []wasm.Global{{
+			Type: wasm.GlobalType{ValType: i32, Mutable: true},
+			Init: wasm.ConstantExpression{Data: []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeEnd}},
+		}},
+		MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 3, IsMaxEncoded: true}},
+		CodeSection: []wasm.Code{
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeCall, 0,
+				wasm.OpcodeLocalSet, 0,
+				wasm.OpcodeGlobalGet, 0,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeGlobalSet, 0,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeI32Load, 0x2, 0x0,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeI32Store, 0x2, 0x0,
+				wasm.OpcodeGlobalGet, 0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeEnd}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "add", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "grow", Type: wasm.ExternTypeFunc, Index: 2},
+		},
+		NameSection: &wasm.NameSection{ModuleName: "main", FunctionNames: wasm.NameMap{{Index: 1, Name: "add"}, {Index: 2, Name: "grow"}}},
+	}
+	inst := instantiate(m, "main")
+	add, grow := inst.ExportedFunction("add"), inst.ExportedFunction("grow")
+
+	var sum uint64
+	requireAdd := func(v uint64) {
+		sum += v
+		res, err := add.Call(testCtx, v)
+		require.NoError(t, err)
+		require.Equal(t, sum, res[0])
+		require.Equal(t, sum, inst.Globals[0].Val)
+		got, ok := inst.MemoryInstance.ReadUint32Le(0)
+		require.True(t, ok)
+		require.Equal(t, uint32(sum), got)
+	}
+
+	// The second call requests the compilation, but the function is interpreted until it is done on the background.
+	requireAdd(1)
+	requireAdd(2)
+	for v := uint64(3); tier.callCount("add") == 0; v++ {
+		require.True(t, v < 10000, "not tiered up")
+		time.Sleep(time.Millisecond)
+		requireAdd(v)
+	}
+
+	// Then the compiled code runs on the same global and memory, calling env.check via its function reference.
+	calls := tier.callCount("add")
+	requireAdd(1)
+	require.Equal(t, calls+1, tier.callCount("add"))
+
+	// The other functions are only compiled once they get hot themselves, like env.check called by "add".
+	tier.requireCompiled(t, "add", "check")
+	for tier.callCount("grow") == 0 {
+		require.Equal(t, uint32(1), inst.MemoryInstance.Pages())
+		res, err := grow.Call(testCtx, 0)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), res[0])
+		time.Sleep(time.Millisecond)
+	}
+	require.Equal(t, []string{"add", "check", "grow"}, tier.compiledFunctions())
+
+	// The memory grown by the compiled code is seen by both.
+	calls = tier.callCount("grow")
+	for _, expected := range []uint64{1, 2} {
+		res, err := grow.Call(testCtx, 1)
+		require.NoError(t, err)
+		require.Equal(t, expected, res[0])
+	}
+	require.Equal(t, calls+2, tier.callCount("grow"))
+	require.Equal(t, uint32(3), inst.MemoryInstance.Pages())
+	requireAdd(5)
+
+	// The trap in the interpreted function includes the frames of the compiled code.
+	_, err := add.Call(testCtx, 0)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeUnreachable)
+	require.Equal(t, `wasm error: unreachable
+wasm stack trace:
+	env.check(i32) i32
+	main.add(i32) i32`, err.Error())
+	requireAdd(6)
+}
+
+func TestNewEngine_localCall(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	defer func(threshold uint32) { interpreter.TierUpThreshold = threshold }(interpreter.TierUpThreshold)
+	interpreter.TierUpThreshold = 10
+
+	const i32 = wasm.ValueTypeI32
+	tier := &countingTier{tier: newTier(testCtx, api.CoreFeaturesV2), calls: map[string]int{}}
+	e := interpreter.NewTieredEngine(testCtx, api.CoreFeaturesV2, nil, tier)
+	defer e.Close()
+	instantiate := newTestStore(t, e)
+
+	// "outer" loops the param times, and then returns "inner" of the param, which is the param plus one.
+	inst := instantiate(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection: []wasm.Code{
+			{LocalTypes: []wasm.ValueType{i32}, Body: []byte{
+				wasm.OpcodeBlock, 0x40,
+				wasm.OpcodeLoop, 0x40,
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32GeU,
+				wasm.OpcodeBrIf, 1,
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeLocalSet, 1,
+				wasm.OpcodeBr, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeCall, 1,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd}},
+		},
+		ExportSection: []wasm.Export{{Name: "outer", Type: wasm.ExternTypeFunc, Index: 0}},
+		NameSection:   &wasm.NameSection{ModuleName: "main", FunctionNames: wasm.NameMap{{Index: 0, Name: "outer"}, {Index: 1, Name: "inner"}}},
+	}, "main")
+	outer := inst.ExportedFunction("outer")
+	requireOuter := func(v uint64) {
+		res, err := outer.Call(testCtx, v)
+		require.NoError(t, err)
+		require.Equal(t, v+1, res[0])
+	}
+
+	// The back-edges make "outer" hot, while "inner" is called once.
+	requireOuter(20)
+	tier.requireCompiled(t, "outer")
+
+	// The compiled "outer" calls back the interpreter for "inner", which isn't hot yet.
+	requireOuter(1)
+	require.Equal(t, 1, tier.callCount("outer"))
+	require.Equal(t, 0, tier.callCount("inner"))
+	for v := uint64(2); v < 10; v++ {
+		requireOuter(v)
+	}
+	tier.requireCompiled(t, "inner", "outer")
+
+	// Then "inner" is swapped in on its next call from the interpreter, after which the compiled "outer" calls it
+	// directly.
+	requireOuter(3)
+	requireOuter(4)
+	require.Equal(t, 11, tier.callCount("outer"))
+	require.Equal(t, 1, tier.callCount("inner"))
+}
+
+// blockingTier blocks CompileFunction until its context is done.
+type blockingTier struct {
+	tier
+	started, canceled chan struct{}
+}
+
+// CompileFunction implements interpreter.Tier CompileFunction.
+func (t *blockingTier) CompileFunction(ctx context.Context, _ *wasm.Module, _ wasm.Index, _ bool) error {
+	close(t.started)
+	<-ctx.Done()
+	close(t.canceled)
+	return ctx.Err()
+}
+
+func TestNewEngine_Close(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	defer func(threshold uint32) { interpreter.TierUpThreshold = threshold }(interpreter.TierUpThreshold)
+	interpreter.TierUpThreshold = 1
+
+	tier := &blockingTier{tier: newTier(testCtx, api.CoreFeaturesV2), started: make(chan struct{}), canceled: make(chan struct{})}
+	e := interpreter.NewTieredEngine(testCtx, api.CoreFeaturesV2, nil, tier)
+	inst := newTestStore(t, e)(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeEnd}}},
+		ExportSection:   []wasm.Export{{Name: "f", Type: wasm.ExternTypeFunc, Index: 0}},
+	}, "main")
+
+	_, err := inst.ExportedFunction("f").Call(testCtx)
+	require.NoError(t, err)
+	<-tier.started
+
+	// Close cancels the compilation on the background, and waits for it.
+	require.NoError(t, e.Close())
+	select {
+	case <-tier.canceled:
+	default:
+		t.Fatal("Close returned before the compilation")
+	}
+
+	// No compilation starts once closed.
+	_, err = inst.ExportedFunction("f").Call(testCtx)
+	require.NoError(t, err)
+}
@@ -2652,7 +2652,7 @@ L3 (SSA Block: blk3):
 
 			ssab := ssa.NewBuilder()
 			offset := wazevoapi.NewModuleContextOffsetData(tc.m, false)
-			fc := frontend.NewFrontendCompiler(tc.m, ssab, &offset, false, false, false)
+			fc := frontend.NewFrontendCompiler(tc.m, ssab, &offset, false, false, false, false)
 			machine := newMachine()
 			machine.DisableStackCheck()
 			be := backend.NewCompiler(context.Background(), machine, ssab)
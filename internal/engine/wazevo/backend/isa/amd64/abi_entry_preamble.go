@@ -52,6 +52,16 @@ func (m *machine) compileEntryPreamble(sig *ssa.Signature) *instruction {
 	// 		mov %goAllocatedStackPtr, %rsp
 	cur = m.move64(goAllocatedStackPtr, rspVReg, cur)
 
+	// Then push the record terminating the chain of the frame pointers: its caller RBP is zero so that
+	// the unwind/stack growth code can correctly detect the end of the stack, and it holds the execution
+	// context pointer so that it can be found from any frame, e.g. by the signal handler of the memory guard pages.
+	// 		sub $16, %rsp
+	// 		mov.q %executionContextPtrReg, 8(%rsp)
+	// 		xor %rbp, %rbp
+	// 		mov.q %rbp, (%rsp)
+	// 		movq %rsp, %rbp
+	cur = m.pushStackEndRecord(cur)
+
 	if stackSlotSize := abi.AlignedArgResultStackSlotSize(); stackSlotSize > 0 {
 		// Allocate stack slots for the arguments and return values.
 		// 		sub $stackSlotSize, %rsp
@@ -74,10 +84,6 @@ func (m *machine) compileEntryPreamble(sig *ssa.Signature) *instruction {
 		}
 	}
 
-	// Zero out RBP so that the unwind/stack growth code can correctly detect the end of the stack.
-	zerosRbp := m.allocateInstr().asAluRmiR(aluRmiROpcodeXor, newOperandReg(rbpVReg), rbpVReg, true)
-	cur = linkInstr(cur, zerosRbp)
-
 	// Now ready to call the real function. Note that at this point stack pointer is already set to the Go-allocated,
 	// which is aligned to 16 bytes.
 	call := m.allocateInstr().asCallIndirect(newOperandReg(functionExecutable), &abi)
@@ -105,6 +111,20 @@ func (m *machine) compileEntryPreamble(sig *ssa.Signature) *instruction {
 	return root
 }
 
+// pushStackEndRecord pushes the record terminating the chain of the frame pointers, and points RBP to it.
+// See UnwindStack for the layout.
+func (m *machine) pushStackEndRecord(cur *instruction) *instruction {
+	spDec := m.allocateInstr().asAluRmiR(aluRmiROpcodeSub, newOperandImm32(stackEndRecordSize), rspVReg, true)
+	cur = linkInstr(cur, spDec)
+	saveExecCtx := m.allocateInstr().asMovRM(executionContextPtrReg, newOperandMem(m.newAmodeImmReg(8, rspVReg)), 8)
+	cur = linkInstr(cur, saveExecCtx)
+	zerosRbp := m.allocateInstr().asAluRmiR(aluRmiROpcodeXor, newOperandReg(rbpVReg), rbpVReg, true)
+	cur = linkInstr(cur, zerosRbp)
+	saveZero := m.allocateInstr().asMovRM(rbpVReg, newOperandMem(m.newAmodeImmReg(0, rspVReg)), 8)
+	cur = linkInstr(cur, saveZero)
+	return m.move64(rspVReg, rbpVReg, cur)
+}
+
 // saveOriginalRSPRBP saves the original RSP and RBP into the execution context.
 func (m *machine) saveOriginalRSPRBP(cur *instruction) *instruction {
 	// 		mov %rbp, wazevoapi.ExecutionContextOffsetOriginalFramePointer(%executionContextPtrReg)
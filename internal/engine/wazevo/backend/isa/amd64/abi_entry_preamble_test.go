@@ -24,7 +24,11 @@ func TestMachineCompileEntryPreamble(t *testing.T) {
 	mov.q %rbp, 16(%rax)
 	mov.q %rsp, 24(%rax)
 	movq %r13, %rsp
+	sub $16, %rsp
+	mov.q %rax, 8(%rsp)
 	xor %rbp, %rbp
+	mov.q %rbp, (%rsp)
+	movq %rsp, %rbp
 	callq *%r14
 	movq 16(%rdx), %rbp
 	movq 24(%rdx), %rsp
@@ -42,13 +46,17 @@ func TestMachineCompileEntryPreamble(t *testing.T) {
 	mov.q %rbp, 16(%rax)
 	mov.q %rsp, 24(%rax)
 	movq %r13, %rsp
+	sub $16, %rsp
+	mov.q %rax, 8(%rsp)
+	xor %rbp, %rbp
+	mov.q %rbp, (%rsp)
+	movq %rsp, %rbp
 	movzx.lq (%r12), %rcx
 	movq 8(%r12), %rdi
 	movss 16(%r12), %xmm0
 	movsd 24(%r12), %xmm1
 	movdqu 32(%r12), %xmm2
 	movq 48(%r12), %rsi
-	xor %rbp, %rbp
 	callq *%r14
 	movq 16(%rdx), %rbp
 	movq 24(%rdx), %rsp
@@ -67,7 +75,11 @@ func TestMachineCompileEntryPreamble(t *testing.T) {
 	mov.q %rbp, 16(%rax)
 	mov.q %rsp, 24(%rax)
 	movq %r13, %rsp
+	sub $16, %rsp
+	mov.q %rax, 8(%rsp)
 	xor %rbp, %rbp
+	mov.q %rbp, (%rsp)
+	movq %rsp, %rbp
 	callq *%r14
 	mov.l %rax, (%r12)
 	movdqu %xmm0, 8(%r12)
@@ -91,13 +103,17 @@ func TestMachineCompileEntryPreamble(t *testing.T) {
 	mov.q %rbp, 16(%rax)
 	mov.q %rsp, 24(%rax)
 	movq %r13, %rsp
+	sub $16, %rsp
+	mov.q %rax, 8(%rsp)
+	xor %rbp, %rbp
+	mov.q %rbp, (%rsp)
+	movq %rsp, %rbp
 	movzx.lq (%r12), %rcx
 	movq 8(%r12), %rdi
 	movss 16(%r12), %xmm0
 	movsd 24(%r12), %xmm1
 	movdqu 32(%r12), %xmm2
 	movq 48(%r12), %rsi
-	xor %rbp, %rbp
 	callq *%r14
 	mov.l %rax, (%r12)
 	movdqu %xmm0, 8(%r12)
@@ -124,6 +140,11 @@ func TestMachineCompileEntryPreamble(t *testing.T) {
 	mov.q %rbp, 16(%rax)
 	mov.q %rsp, 24(%rax)
 	movq %r13, %rsp
+	sub $16, %rsp
+	mov.q %rax, 8(%rsp)
+	xor %rbp, %rbp
+	mov.q %rbp, (%rsp)
+	movq %rsp, %rbp
 	sub $64, %rsp
 	movzx.lq (%r12), %rcx
 	movq 8(%r12), %rdi
@@ -154,7 +175,6 @@ func TestMachineCompileEntryPreamble(t *testing.T) {
 	movdqu %xmm15, 40(%rsp)
 	movq 192(%r12), %r15
 	mov.q %r15, 56(%rsp)
-	xor %rbp, %rbp
 	callq *%r14
 	movq 16(%rdx), %rbp
 	movq 24(%rdx), %rsp
@@ -177,8 +197,12 @@ func TestMachineCompileEntryPreamble(t *testing.T) {
 	mov.q %rbp, 16(%rax)
 	mov.q %rsp, 24(%rax)
 	movq %r13, %rsp
-	sub $64, %rsp
+	sub $16, %rsp
+	mov.q %rax, 8(%rsp)
 	xor %rbp, %rbp
+	mov.q %rbp, (%rsp)
+	movq %rsp, %rbp
+	sub $64, %rsp
 	callq *%r14
 	mov.q %rax, (%r12)
 	mov.q %rbx, 8(%r12)
@@ -236,6 +260,11 @@ func TestMachineCompileEntryPreamble(t *testing.T) {
 	mov.q %rbp, 16(%rax)
 	mov.q %rsp, 24(%rax)
 	movq %r13, %rsp
+	sub $16, %rsp
+	mov.q %rax, 8(%rsp)
+	xor %rbp, %rbp
+	mov.q %rbp, (%rsp)
+	movq %rsp, %rbp
 	sub $128, %rsp
 	movzx.lq (%r12), %rcx
 	movq 8(%r12), %rdi
@@ -266,7 +295,6 @@ func TestMachineCompileEntryPreamble(t *testing.T) {
 	movdqu %xmm15, 40(%rsp)
 	movq 192(%r12), %r15
 	mov.q %r15, 56(%rsp)
-	xor %rbp, %rbp
 	callq *%r14
 	mov.q %rax, (%r12)
 	mov.q %rbx, 8(%r12)
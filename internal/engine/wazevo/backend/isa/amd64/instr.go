@@ -258,6 +258,13 @@ func (i *instruction) String() string {
 		return fmt.Sprintf("xmmcmov%s %s, %s", cond(i.u1), i.op1.format(true), i.op2.format(true))
 	case blendvpd:
 		return fmt.Sprintf("blendvpd %s, %s, %%xmm0", i.op1.format(false), i.op2.format(false))
+	case vfmadd231:
+		suffix := "ps"
+		if i.b1 {
+			suffix = "pd"
+		}
+		return fmt.Sprintf("vfmadd231%s %s, %s, %s", suffix, i.op1.format(false),
+			formatVRegSized(regalloc.VReg(i.u1), false), i.op2.format(false))
 	case mfence:
 		return "mfence"
 	case lockcmpxchg:
@@ -410,6 +417,9 @@ func (i *instruction) Uses(regs *[]regalloc.VReg) []regalloc.VReg {
 		}
 		*regs = append(*regs, opReg.reg())
 
+	case useKindVfmadd231:
+		*regs = append(*regs, i.op1.reg(), regalloc.VReg(i.u1), i.op2.reg())
+
 	case useKindRaxOp1RegOp2:
 		opReg, opAny := &i.op1, &i.op2
 		*regs = append(*regs, raxVReg, opReg.reg())
@@ -579,6 +589,18 @@ func (i *instruction) AssignUse(index int, v regalloc.VReg) {
 			}
 		}
 
+	case useKindVfmadd231:
+		switch index {
+		case 0:
+			i.op1.setReg(v)
+		case 1:
+			i.u1 = uint64(v)
+		case 2:
+			i.op2.setReg(v)
+		default:
+			panic("BUG")
+		}
+
 	case useKindRaxOp1RegOp2:
 		switch index {
 		case 0:
@@ -839,6 +861,9 @@ const (
 	// blendvpd is https://www.felixcloutier.com/x86/blendvpd.
 	blendvpd
 
+	// vfmadd231 is the VEX-encoded vfmadd231ps/vfmadd231pd https://www.felixcloutier.com/x86/vfmadd132ps:vfmadd213ps:vfmadd231ps.
+	vfmadd231
+
 	// mfence is https://www.felixcloutier.com/x86/mfence
 	mfence
 
@@ -1091,6 +1116,8 @@ func (k instructionKind) String() string {
 		return "idivRemSequence"
 	case mfence:
 		return "mfence"
+	case vfmadd231:
+		return "vfmadd231"
 	case lockcmpxchg:
 		return "lockcmpxchg"
 	case lockxadd:
@@ -1255,6 +1282,19 @@ func (i *instruction) asBlendvpd(rm operand, rd regalloc.VReg) *instruction {
 	return i
 }
 
+// asVfmadd231 computes rd += rn * rm on f32x4 (or f64x2 if _64 is true) lanes without intermediate rounding.
+func (i *instruction) asVfmadd231(rm operand, rn, rd regalloc.VReg, _64 bool) *instruction {
+	if rm.kind != operandKindReg {
+		panic("BUG")
+	}
+	i.kind = vfmadd231
+	i.op1 = rm
+	i.op2 = newOperandReg(rd)
+	i.u1 = uint64(rn)
+	i.b1 = _64
+	return i
+}
+
 func (i *instruction) asXmmRmR(op sseOpcode, rm operand, rd regalloc.VReg) *instruction {
 	if rm.kind != operandKindReg && rm.kind != operandKindMem {
 		panic("BUG")
@@ -2377,6 +2417,7 @@ var defKinds = [instrMax]defKind{
 	xmmCMov:                defKindOp2,
 	idivRemSequence:        defKindDivRem,
 	blendvpd:               defKindNone,
+	vfmadd231:              defKindNone,
 	mfence:                 defKindNone,
 	xchg:                   defKindNone,
 	lockcmpxchg:            defKindNone,
@@ -2416,6 +2457,8 @@ const (
 	useKindRaxOp1RegOp2
 	useKindDivRem
 	useKindBlendvpd
+	// useKindVfmadd231 is Op1 and Op2 are registers, and the additional source register is stored in u1.
+	useKindVfmadd231
 	useKindCall
 	useKindCallInd
 	useKindTailCallInd
@@ -2463,6 +2506,7 @@ var useKinds = [instrMax]useKind{
 	xmmCMov:                useKindOp1,
 	idivRemSequence:        useKindDivRem,
 	blendvpd:               useKindBlendvpd,
+	vfmadd231:              useKindVfmadd231,
 	mfence:                 useKindNone,
 	xchg:                   useKindOp1RegOp2,
 	lockcmpxchg:            useKindRaxOp1RegOp2,
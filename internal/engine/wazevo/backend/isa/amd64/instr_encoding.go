@@ -1404,6 +1404,25 @@ func (i *instruction) encode(c backend.Compiler) (needsLabelResolution bool) {
 		c.EmitByte(0xae)
 		c.EmitByte(0xf0)
 
+	case vfmadd231:
+		// https://www.felixcloutier.com/x86/vfmadd132ps:vfmadd213ps:vfmadd231ps
+		// VEX.128.66.0F38.W0 B8 /r for ps, and VEX.128.66.0F38.W1 B8 /r for pd.
+		dst := regEncodings[i.op2.reg().RealReg()]
+		src1 := regEncodings[regalloc.VReg(i.u1).RealReg()]
+		src2 := regEncodings[i.op1.reg().RealReg()]
+
+		var w byte
+		if i.b1 {
+			w = 1
+		}
+		c.EmitByte(0xc4)
+		// Inverted R, X and B bits followed by the 0F38 opcode map.
+		c.EmitByte((^dst.rexBit()&1)<<7 | 1<<6 | (^src2.rexBit()&1)<<5 | 0b00010)
+		// W, inverted vvvv, L=0 (128-bit) and the 0x66 implied prefix.
+		c.EmitByte(w<<7 | (^byte(src1)&0xf)<<3 | 0b01)
+		c.EmitByte(0xb8)
+		c.EmitByte(encodeModRM(3, dst.encoding(), src2.encoding()))
+
 	default:
 		panic(fmt.Sprintf("TODO: %v", i.kind))
 	}
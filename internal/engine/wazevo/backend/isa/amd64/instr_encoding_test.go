@@ -4115,6 +4115,16 @@ func TestInstruction_format_encode(t *testing.T) {
 			want:       "66440f3815f9",
 			wantFormat: "blendvpd %xmm1, %xmm15, %xmm0",
 		},
+		{
+			setup:      func(i *instruction) { i.asVfmadd231(newOperandReg(xmm2VReg), xmm1VReg, xmm0VReg, false) },
+			want:       "c4e271b8c2",
+			wantFormat: "vfmadd231ps %xmm2, %xmm1, %xmm0",
+		},
+		{
+			setup:      func(i *instruction) { i.asVfmadd231(newOperandReg(xmm15VReg), xmm9VReg, xmm8VReg, true) },
+			want:       "c442b1b8c7",
+			wantFormat: "vfmadd231pd %xmm15, %xmm9, %xmm8",
+		},
 		{
 			setup:      func(i *instruction) { i.asMFence() },
 			want:       "0faef0",
@@ -1011,6 +1011,13 @@ func (m *machine) LowerInstr(instr *ssa.Instruction) {
 		x, y := instr.Arg2()
 		m.lowerWideningPairwiseDotProductS(x, y, instr.Return())
 
+	case ssa.OpcodeVIdotI8x16I7x16S:
+		x, y := instr.Arg2()
+		m.lowerVIdotI8x16I7x16S(x, y, instr.Return())
+
+	case ssa.OpcodeVFma:
+		m.lowerVFma(instr)
+
 	case ssa.OpcodeVIabs:
 		m.lowerVIabs(instr)
 	case ssa.OpcodeVIpopcnt:
@@ -5,6 +5,7 @@ import (
 
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+	"github.com/tetratelabs/wazero/internal/platform"
 )
 
 var swizzleMask = [16]byte{
@@ -817,6 +818,42 @@ func (m *machine) lowerWideningPairwiseDotProductS(x, y, ret ssa.Value) {
 	m.copyTo(xx, m.c.VRegOf(ret))
 }
 
+func (m *machine) lowerVIdotI8x16I7x16S(x, y, ret ssa.Value) {
+	xx := m.getOperand_Mem_Reg(m.c.ValueDefinition(x))
+	_yy := m.getOperand_Reg(m.c.ValueDefinition(y))
+	// PMADDUBSW treats the destination operand as unsigned bytes, which is fine
+	// for the 7-bit lanes of y, and the source operand as signed bytes.
+	yy := m.copyToTmp(_yy.reg())
+	m.insert(m.allocateInstr().asXmmRmR(sseOpcodePmaddubsw, xx, yy))
+	m.copyTo(yy, m.c.VRegOf(ret))
+}
+
+func (m *machine) lowerVFma(instr *ssa.Instruction) {
+	x, y, z, lane := instr.Arg3WithLane()
+	rn := m.getOperand_Reg(m.c.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.c.ValueDefinition(y))
+	ra := m.getOperand_Reg(m.c.ValueDefinition(z))
+	rd := m.c.VRegOf(instr.Return())
+
+	_64 := lane == ssa.VecLaneF64x2
+	if m.cpuFeatures.Has(platform.CpuFeatureAmd64FMA) {
+		tmp := m.copyToTmp(ra.reg())
+		m.insert(m.allocateInstr().asVfmadd231(rm, rn.reg(), tmp, _64))
+		m.copyTo(tmp, rd)
+		return
+	}
+
+	// Without FMA, fall back to a separately rounded multiply and add, which is permitted by relaxed SIMD.
+	mulOp, addOp := sseOpcodeMulps, sseOpcodeAddps
+	if _64 {
+		mulOp, addOp = sseOpcodeMulpd, sseOpcodeAddpd
+	}
+	tmp := m.copyToTmp(rn.reg())
+	m.insert(m.allocateInstr().asXmmRmR(mulOp, rm, tmp))
+	m.insert(m.allocateInstr().asXmmRmR(addOp, ra, tmp))
+	m.copyTo(tmp, rd)
+}
+
 func (m *machine) lowerVIabs(instr *ssa.Instruction) {
 	x, lane := instr.ArgWithLane()
 	rd := m.c.VRegOf(instr.Return())
@@ -0,0 +1,219 @@
+package amd64
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+// maxExecutableRanges is the maximum number of executables which can be registered at the same time.
+const maxExecutableRanges = 1 << 12
+
+// maxStackRanges is the maximum number of stacks which can be registered at the same time, i.e. the maximum
+// number of calls of the compiled code in progress.
+const maxStackRanges = 1 << 14
+
+// executableRange is the [lo, hi) address range of an executable registered via RegisterExecutable.
+// An entry whose hi is zero is unused.
+type executableRange struct {
+	lo, hi uintptr
+}
+
+// stackRange is the [lo, hi) address range of a stack registered via RegisterStack, on which the compiled code
+// runs with the execution context at execCtx. An entry whose hi is zero is unused.
+type stackRange struct {
+	lo, hi, execCtx uintptr
+}
+
+var (
+	// executableRanges and executableRangesLen are read by sigsegvHandler without any synchronization,
+	// so they must be updated atomically and in the order such that an entry is never seen half-written.
+	executableRanges    [maxExecutableRanges]executableRange
+	executableRangesLen uintptr
+	executableRangesMux sync.Mutex
+
+	// stackRanges and stackRangesLen are read by sigsegvHandler in the same way as executableRanges.
+	stackRanges    [maxStackRanges]stackRange
+	stackRangesLen uintptr
+	stackRangesMux sync.Mutex
+
+	// goSigsegvHandler is the SIGSEGV handler installed before sigsegvHandler, usually the one of the Go runtime,
+	// to which sigsegvHandler forwards the signals not raised by the registered executables.
+	goSigsegvHandler uintptr
+
+	// sigsegvHandlerInstalled is true once sigsegvHandler is installed. This is guarded by sigsegvHandlerMux.
+	sigsegvHandlerInstalled bool
+	sigsegvHandlerMux       sync.Mutex
+)
+
+// The followings are read by sigsegvHandler and memoryFaultTrampoline instead of hard-coding the layout of
+// wazevo.executionContext in the assembly.
+var (
+	executionContextOffsetExitCode                 = uintptr(wazevoapi.ExecutionContextOffsetExitCodeOffset)
+	executionContextOffsetOriginalFramePointer     = uintptr(wazevoapi.ExecutionContextOffsetOriginalFramePointer)
+	executionContextOffsetOriginalStackPointer     = uintptr(wazevoapi.ExecutionContextOffsetOriginalStackPointer)
+	executionContextOffsetGoCallReturnAddress      = uintptr(wazevoapi.ExecutionContextOffsetGoCallReturnAddress)
+	executionContextOffsetStackPointerBeforeGoCall = uintptr(wazevoapi.ExecutionContextOffsetStackPointerBeforeGoCall)
+	executionContextOffsetFramePointerBeforeGoCall = uintptr(wazevoapi.ExecutionContextOffsetFramePointerBeforeGoCall)
+	exitCodeMemoryOutOfBounds                      = uint32(wazevoapi.ExitCodeMemoryOutOfBounds)
+)
+
+// sigsegvHandler is the SIGSEGV handler which redirects the memory access faults raised within the registered
+// executables to memoryFaultTrampoline. It is invoked by the kernel, hence must not be called from Go.
+func sigsegvHandler()
+
+// memoryFaultTrampoline exits the execution with wazevoapi.ExitCodeMemoryOutOfBounds. sigsegvHandler makes it
+// be executed in place of the faulting instruction with the execution context pointer in RAX and the faulting address in RCX.
+func memoryFaultTrampoline()
+
+// sigsegvHandlerAddress returns the address of sigsegvHandler.
+func sigsegvHandlerAddress() uintptr
+
+// saSiginfo is SA_SIGINFO of linux.
+const saSiginfo = 0x4
+
+// sigaction corresponds to the kernel's struct sigaction on linux/amd64.
+type sigaction struct {
+	handler  uintptr
+	flags    uint64
+	restorer uintptr
+	mask     uint64
+}
+
+// RegisterExecutable registers the given executable generated by this backend so that the memory access faults
+// raised within it result in wazevoapi.ExitCodeMemoryOutOfBounds instead of crashing the process.
+// This calls VerifySigsegvHandler first.
+func RegisterExecutable(executable []byte) error {
+	if err := VerifySigsegvHandler(); err != nil {
+		return err
+	}
+
+	lo := uintptr(unsafe.Pointer(&executable[0]))
+	hi := lo + uintptr(len(executable))
+
+	executableRangesMux.Lock()
+	defer executableRangesMux.Unlock()
+	n := atomic.LoadUintptr(&executableRangesLen)
+	i := uintptr(0)
+	for ; i < n; i++ {
+		if executableRanges[i].hi == 0 {
+			break
+		}
+	}
+	if i == maxExecutableRanges {
+		return errors.New("too many executables with memory guard pages")
+	}
+	r := &executableRanges[i]
+	atomic.StoreUintptr(&r.hi, 0)
+	atomic.StoreUintptr(&r.lo, lo)
+	atomic.StoreUintptr(&r.hi, hi)
+	if i == n {
+		atomic.StoreUintptr(&executableRangesLen, n+1)
+	}
+	return nil
+}
+
+// UnregisterExecutable undoes RegisterExecutable. This must be called before the executable is unmapped.
+func UnregisterExecutable(executable []byte) {
+	lo := uintptr(unsafe.Pointer(&executable[0]))
+
+	executableRangesMux.Lock()
+	defer executableRangesMux.Unlock()
+	n := atomic.LoadUintptr(&executableRangesLen)
+	for i := uintptr(0); i < n; i++ {
+		if r := &executableRanges[i]; r.hi != 0 && r.lo == lo {
+			atomic.StoreUintptr(&r.hi, 0)
+			return
+		}
+	}
+}
+
+// RegisterStack registers the stack on which the compiled code runs with the given execution context during a call,
+// so that sigsegvHandler only follows the frame pointers within it. This returns the index of the entry to pass to
+// UpdateStack and UnregisterStack.
+func RegisterStack(stack []byte, execCtx uintptr) (int, error) {
+	stackRangesMux.Lock()
+	defer stackRangesMux.Unlock()
+	n := atomic.LoadUintptr(&stackRangesLen)
+	i := uintptr(0)
+	for ; i < n; i++ {
+		if stackRanges[i].hi == 0 {
+			break
+		}
+	}
+	if i == maxStackRanges {
+		return 0, errors.New("too many calls in progress with memory guard pages")
+	}
+	atomic.StoreUintptr(&stackRanges[i].execCtx, execCtx)
+	setStackRange(&stackRanges[i], stack)
+	if i == n {
+		atomic.StoreUintptr(&stackRangesLen, n+1)
+	}
+	return int(i), nil
+}
+
+// UpdateStack replaces the stack registered at the index i by RegisterStack, e.g. after it is grown.
+// This must be called before the compiled code runs on the new stack.
+func UpdateStack(i int, stack []byte) {
+	stackRangesMux.Lock()
+	defer stackRangesMux.Unlock()
+	setStackRange(&stackRanges[i], stack)
+}
+
+// UnregisterStack undoes RegisterStack.
+func UnregisterStack(i int) {
+	stackRangesMux.Lock()
+	defer stackRangesMux.Unlock()
+	atomic.StoreUintptr(&stackRanges[i].hi, 0)
+}
+
+// setStackRange sets the address range of r to the one of stack. r is unused while being updated.
+func setStackRange(r *stackRange, stack []byte) {
+	lo := uintptr(unsafe.Pointer(&stack[0]))
+	atomic.StoreUintptr(&r.hi, 0)
+	atomic.StoreUintptr(&r.lo, lo)
+	atomic.StoreUintptr(&r.hi, lo+uintptr(len(stack)))
+}
+
+// VerifySigsegvHandler installs sigsegvHandler as the SIGSEGV handler on the first call, and verifies that it is
+// still installed on the subsequent ones. This must succeed before the bounds checks are elided.
+//
+// sigsegvHandler is installed with a raw rt_sigaction on top of the Go runtime's one, so nothing prevents it from
+// being replaced later, e.g. by a cgo library. In that case, this returns an error instead of re-installing it, as the
+// replacing handler might forward the signals back to sigsegvHandler, which would forward them again to it.
+func VerifySigsegvHandler() error {
+	sigsegvHandlerMux.Lock()
+	defer sigsegvHandlerMux.Unlock()
+
+	var cur sigaction
+	if _, _, errno := unix.RawSyscall6(unix.SYS_RT_SIGACTION, uintptr(unix.SIGSEGV),
+		0, uintptr(unsafe.Pointer(&cur)), unsafe.Sizeof(cur.mask), 0, 0); errno != 0 {
+		return errno
+	}
+	if sigsegvHandlerInstalled {
+		if cur.handler != sigsegvHandlerAddress() {
+			return errors.New("SIGSEGV handler for the memory guard pages has been replaced")
+		}
+		return nil
+	}
+	if cur.flags&saSiginfo == 0 {
+		return errors.New("SIGSEGV handler is not installed by the Go runtime")
+	}
+	goSigsegvHandler = cur.handler
+
+	// Keep the flags, the restorer and the mask of the existing one, which is set up by the Go runtime
+	// with SA_ONSTACK and SA_SIGINFO.
+	act := cur
+	act.handler = sigsegvHandlerAddress()
+	if _, _, errno := unix.RawSyscall6(unix.SYS_RT_SIGACTION, uintptr(unix.SIGSEGV),
+		uintptr(unsafe.Pointer(&act)), 0, unsafe.Sizeof(act.mask), 0, 0); errno != 0 {
+		return errno
+	}
+	sigsegvHandlerInstalled = true
+	return nil
+}
@@ -0,0 +1,124 @@
+#include "textflag.h"
+
+// The offsets of the registers in the ucontext_t passed to the signal handler.
+#define UC_RBP 120
+#define UC_RAX 144
+#define UC_RCX 152
+#define UC_RSP 160
+#define UC_RIP 168
+
+// sigsegvHandler(sig uint32, info *siginfo, ctx *ucontext) is called by the kernel with the C calling convention,
+// on the signal stack of the Go runtime. Only the caller-saved registers which are not used for the arguments are clobbered,
+// so that the signal can be forwarded to the Go runtime's handler as-is.
+TEXT ·sigsegvHandler(SB), NOSPLIT|NOFRAME, $0
+	MOVQ UC_RIP(DX), R8 // R8 = the faulting PC.
+
+	// Check if the faulting PC is within one of the registered executables. Otherwise, the fault is not raised by
+	// the compiled code, so the frame pointers are never looked at.
+	LEAQ ·executableRanges(SB), R9
+	MOVQ ·executableRangesLen(SB), R10
+	SHLQ $4, R10                       // 16 == unsafe.Sizeof(executableRange{})
+	ADDQ R9, R10
+
+lookup:
+	CMPQ R9, R10
+	JAE  forward
+	MOVQ 8(R9), R11 // hi
+	CMPQ R8, R11
+	JAE  next
+	MOVQ 0(R9), R11 // lo
+	CMPQ R8, R11
+	JAE  found
+
+next:
+	ADDQ $16, R9
+	JMP  lookup
+
+found:
+	// Look up the registered stack containing the faulting RSP, which is the one of the call in progress on this
+	// thread. The frame pointers are only followed within it.
+	MOVQ  UC_RSP(DX), CX          // CX = the faulting RSP.
+	LEAQ  ·stackRanges(SB), R9
+	MOVQ  ·stackRangesLen(SB), R10
+	IMULQ $24, R10                // 24 == unsafe.Sizeof(stackRange{})
+	ADDQ  R9, R10
+
+stackLookup:
+	CMPQ R9, R10
+	JAE  forward
+	MOVQ 8(R9), R11 // hi
+	CMPQ CX, R11
+	JAE  stackNext
+	MOVQ 0(R9), AX  // lo
+	CMPQ CX, AX
+	JAE  stackFound
+
+stackNext:
+	ADDQ $24, R9
+	JMP  stackLookup
+
+stackFound:
+	// Follow the frame pointers up to the record pushed by the entry preamble at the top of the stack, whose caller RBP
+	// is zero. They must be 8-byte aligned and strictly increasing from the faulting RSP, and each record of the
+	// caller RBP and the return address must be within the stack before being read.
+	MOVQ 16(R9), AX // AX = the execution context of the stack.
+	SUBQ $16, R11   // R11 = the highest address of a record within the stack.
+	MOVQ UC_RBP(DX), R9
+	CMPQ R9, CX
+	JB   forward
+
+walk:
+	CMPQ  R9, R11
+	JA    forward
+	TESTQ $7, R9
+	JNZ   forward
+	MOVQ  0(R9), R10
+	TESTQ R10, R10
+	JZ    record
+	CMPQ  R10, R9
+	JBE   forward
+	MOVQ  R10, R9
+	JMP   walk
+
+record:
+	// The execution context saved in the record must be the one registered with the stack.
+	MOVQ 8(R9), R10
+	CMPQ R10, AX
+	JNE  forward
+
+	// Resume at memoryFaultTrampoline with the execution context pointer in RAX and the faulting PC in RCX.
+	MOVQ R10, UC_RAX(DX)
+	MOVQ R8, UC_RCX(DX)
+	LEAQ ·memoryFaultTrampoline(SB), R10
+	MOVQ R10, UC_RIP(DX)
+	RET
+
+forward:
+	MOVQ ·goSigsegvHandler(SB), R11
+	JMP  R11
+
+// memoryFaultTrampoline does the same as the exit sequence emitted by machine.lowerExitWithCode
+// with wazevoapi.ExitCodeMemoryOutOfBounds, with RAX being the execution context pointer.
+TEXT ·memoryFaultTrampoline(SB), NOSPLIT|NOFRAME, $0
+	MOVQ ·executionContextOffsetStackPointerBeforeGoCall(SB), R8
+	MOVQ SP, (AX)(R8*1)
+	MOVQ ·executionContextOffsetFramePointerBeforeGoCall(SB), R8
+	MOVQ BP, (AX)(R8*1)
+	MOVQ ·executionContextOffsetExitCode(SB), R8
+	MOVL ·exitCodeMemoryOutOfBounds(SB), R9
+	MOVL R9, (AX)(R8*1)
+	MOVQ ·executionContextOffsetGoCallReturnAddress(SB), R8
+	MOVQ CX, (AX)(R8*1)
+
+	// Restore the RBP, RSP, and return to the Go code.
+	MOVQ ·executionContextOffsetOriginalFramePointer(SB), R8
+	MOVQ ·executionContextOffsetOriginalStackPointer(SB), R9
+	MOVQ (AX)(R8*1), BP
+	MOVQ (AX)(R9*1), SP
+	RET
+
+// sigsegvHandlerAddress() uintptr
+TEXT ·sigsegvHandlerAddress(SB), NOSPLIT, $0-8
+	LEAQ ·sigsegvHandler(SB), AX
+	MOVQ AX, ret+0(FP)
+	RET
@@ -0,0 +1,98 @@
+package amd64
+
+import (
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestExecutableRange_size(t *testing.T) {
+	// sigsegvHandler in signal_linux_amd64.s assumes these sizes.
+	require.Equal(t, uintptr(16), unsafe.Sizeof(executableRange{}))
+	require.Equal(t, uintptr(24), unsafe.Sizeof(stackRange{}))
+}
+
+func TestVerifySigsegvHandler(t *testing.T) {
+	require.NoError(t, VerifySigsegvHandler())
+	require.NotEqual(t, uintptr(0), goSigsegvHandler)
+	// Verified again without re-installing it.
+	goHandler := goSigsegvHandler
+	require.NoError(t, VerifySigsegvHandler())
+	require.Equal(t, goHandler, goSigsegvHandler)
+
+	// Once replaced, e.g. by a cgo library, the handler is not re-installed.
+	setSigsegvHandler(t, goHandler)
+	defer setSigsegvHandler(t, sigsegvHandlerAddress())
+	require.EqualError(t, VerifySigsegvHandler(), "SIGSEGV handler for the memory guard pages has been replaced")
+	require.Equal(t, goHandler, goSigsegvHandler)
+}
+
+func setSigsegvHandler(t *testing.T, handler uintptr) {
+	var act sigaction
+	_, _, errno := unix.RawSyscall6(unix.SYS_RT_SIGACTION, uintptr(unix.SIGSEGV),
+		0, uintptr(unsafe.Pointer(&act)), unsafe.Sizeof(act.mask), 0, 0)
+	require.Zero(t, errno)
+	act.handler = handler
+	_, _, errno = unix.RawSyscall6(unix.SYS_RT_SIGACTION, uintptr(unix.SIGSEGV),
+		uintptr(unsafe.Pointer(&act)), 0, unsafe.Sizeof(act.mask), 0, 0)
+	require.Zero(t, errno)
+}
+
+func TestRegisterExecutable(t *testing.T) {
+	a, b := allocSlice(16), allocSlice(16)
+	require.NoError(t, RegisterExecutable(a))
+	require.NoError(t, RegisterExecutable(b))
+	require.NotEqual(t, uintptr(0), goSigsegvHandler)
+
+	n := executableRangesLen
+	UnregisterExecutable(a)
+	// The freed entry is reused.
+	require.NoError(t, RegisterExecutable(a))
+	require.Equal(t, n, executableRangesLen)
+
+	UnregisterExecutable(a)
+	UnregisterExecutable(b)
+	for i := uintptr(0); i < executableRangesLen; i++ {
+		require.Equal(t, uintptr(0), executableRanges[i].hi)
+	}
+}
+
+func TestRegisterStack(t *testing.T) {
+	a, b := allocSlice(16), allocSlice(32)
+	i, err := RegisterStack(a, 1)
+	require.NoError(t, err)
+	j, err := RegisterStack(b, 2)
+	require.NoError(t, err)
+	require.NotEqual(t, i, j)
+	require.Equal(t, stackRange{
+		lo:      uintptr(unsafe.Pointer(&b[0])),
+		hi:      uintptr(unsafe.Pointer(&b[0])) + 32,
+		execCtx: 2,
+	}, stackRanges[j])
+
+	// The grown stack replaces the previous one.
+	grown := allocSlice(64)
+	UpdateStack(i, grown)
+	require.Equal(t, stackRange{
+		lo:      uintptr(unsafe.Pointer(&grown[0])),
+		hi:      uintptr(unsafe.Pointer(&grown[0])) + 64,
+		execCtx: 1,
+	}, stackRanges[i])
+
+	n := stackRangesLen
+	UnregisterStack(i)
+	// The freed entry is reused.
+	k, err := RegisterStack(a, 3)
+	require.NoError(t, err)
+	require.Equal(t, i, k)
+	require.Equal(t, n, stackRangesLen)
+
+	UnregisterStack(k)
+	UnregisterStack(j)
+	for i := uintptr(0); i < stackRangesLen; i++ {
+		require.Equal(t, uintptr(0), stackRanges[i].hi)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build !(linux && amd64)
+
+package amd64
+
+import "errors"
+
+// VerifySigsegvHandler installs the SIGSEGV handler recovering from the memory access faults in the registered
+// executables, or verifies that it is still installed.
+func VerifySigsegvHandler() error {
+	return errors.New("memory guard pages are only supported on linux/amd64")
+}
+
+// RegisterExecutable registers the given executable generated by this backend so that the memory access faults
+// raised within it result in wazevoapi.ExitCodeMemoryOutOfBounds instead of crashing the process.
+func RegisterExecutable([]byte) error {
+	return errors.New("memory guard pages are only supported on linux/amd64")
+}
+
+// UnregisterExecutable undoes RegisterExecutable.
+func UnregisterExecutable([]byte) {}
+
+// RegisterStack registers the stack on which the compiled code runs with the given execution context during a call.
+func RegisterStack([]byte, uintptr) (int, error) {
+	return 0, errors.New("memory guard pages are only supported on linux/amd64")
+}
+
+// UpdateStack replaces the stack registered by RegisterStack.
+func UpdateStack(int, []byte) {}
+
+// UnregisterStack undoes RegisterStack.
+func UnregisterStack(int) {}
@@ -21,6 +21,19 @@ func stackView(rbp, top uintptr) []byte {
 	return stackBuf
 }
 
+// stackEndRecordSize is the size of the record pushed by the entry preamble at the top of the stack, which terminates
+// the chain of the frame pointers:
+//
+//	   (high address)
+//	+-----------------+ <---- top
+//	|  ExecutionCtx   |
+//	|        0        |
+//	+-----------------+ <---- Caller_RBP of the outermost frame
+//	|   arg/ret area  |
+//	|   ...........   |
+//	   (low address)
+const stackEndRecordSize = 16
+
 // UnwindStack implements wazevo.unwindStack.
 func UnwindStack(_, rbp, top uintptr, returnAddresses []uintptr) []uintptr {
 	stackBuf := stackView(rbp, top)
@@ -52,6 +65,11 @@ func UnwindStack(_, rbp, top uintptr, returnAddresses []uintptr) []uintptr {
 		//       (low address)
 
 		callerRBP := binary.LittleEndian.Uint64(stackBuf[i:])
+		if callerRBP == 0 {
+			// The record pushed by the entry preamble at the end of the stack, whose second slot holds the
+			// execution context pointer instead of a return address.
+			break
+		}
 		retAddr := binary.LittleEndian.Uint64(stackBuf[i+8:])
 		returnAddresses = append(returnAddresses, uintptr(retAddr))
 		i = callerRBP - uint64(rbp)
@@ -22,10 +22,14 @@ func TestUnwindStack(t *testing.T) {
 			binary.LittleEndian.PutUint64(stack[0:], uint64(oldRBP1))             // old bp
 			binary.LittleEndian.PutUint64(stack[8:], uint64(0xffffffff_00000000)) // return address
 			oldRBP2 := oldRBP1 + 16
+			stackEnd := oldRBP2 + 64
 			binary.LittleEndian.PutUint64(stack[oldRBP1-bp:], uint64(oldRBP2))               // old bp
 			binary.LittleEndian.PutUint64(stack[oldRBP1-bp+8:], uint64(0xffffffff_00000001)) // return address
-			binary.LittleEndian.PutUint64(stack[oldRBP2-bp:], uint64(0))                     // old bp
+			binary.LittleEndian.PutUint64(stack[oldRBP2-bp:], uint64(stackEnd))              // old bp
 			binary.LittleEndian.PutUint64(stack[oldRBP2-bp+8:], uint64(0xffffffff_00000002)) // return address
+			// The record pushed by the entry preamble.
+			binary.LittleEndian.PutUint64(stack[stackEnd-bp:], uint64(0))            // old bp
+			binary.LittleEndian.PutUint64(stack[stackEnd-bp+8:], uint64(0xdeadbeef)) // execution context
 			return stack, exp
 		}},
 	} {
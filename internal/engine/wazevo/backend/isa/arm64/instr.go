@@ -1996,6 +1996,8 @@ func (b vecOp) String() string {
 		return "urhadd"
 	case vecOpFmul:
 		return "fmul"
+	case vecOpFmla:
+		return "fmla"
 	case vecOpSqrdmulh:
 		return "sqrdmulh"
 	case vecOpMul:
@@ -2066,6 +2068,8 @@ func (b vecOp) String() string {
 		return "smull"
 	case vecOpSmull2:
 		return "smull2"
+	case vecOpSdot:
+		return "sdot"
 	}
 	panic(int(b))
 }
@@ -2110,6 +2114,7 @@ const (
 	vecOpUrhadd
 	vecOpMul
 	vecOpFmul
+	vecOpFmla
 	vecOpSqrdmulh
 	vecOpUmlal
 	vecOpFdiv
@@ -2142,6 +2147,7 @@ const (
 	vecOpZip1
 	vecOpSmull
 	vecOpSmull2
+	vecOpSdot
 )
 
 // bitOp determines the type of bitwise operation. Instructions whose kind is one of
@@ -358,7 +358,7 @@ func (i *instruction) encode(m *machine) {
 			i.u2 == 1,
 		))
 	case vecRRR:
-		if op := vecOp(i.u1); op == vecOpBsl || op == vecOpBit || op == vecOpUmlal {
+		if op := vecOp(i.u1); op == vecOpBsl || op == vecOpBit || op == vecOpUmlal || op == vecOpFmla || op == vecOpSdot {
 			panic(fmt.Sprintf("vecOp %s must use vecRRRRewrite instead of vecRRR", op.String()))
 		}
 		fallthrough
@@ -635,6 +635,19 @@ func encodeVecRRR(op vecOp, rd, rn, rm uint32, arr vecArrangement) uint32 {
 			panic("unsupported arrangement: " + arr.String())
 		}
 		return encodeAdvancedSIMDThreeSame(rd, rn, rm, 0b11011, size, 0b1, q)
+	case vecOpFmla:
+		var size, q uint32
+		switch arr {
+		case vecArrangement4S:
+			size, q = 0b00, 0b1
+		case vecArrangement2S:
+			size, q = 0b00, 0b0
+		case vecArrangement2D:
+			size, q = 0b01, 0b1
+		default:
+			panic("unsupported arrangement: " + arr.String())
+		}
+		return encodeAdvancedSIMDThreeSame(rd, rn, rm, 0b11001, size, 0b0, q)
 	case vecOpSqrdmulh:
 		if arr < vecArrangement4H || arr > vecArrangement4S {
 			panic("unsupported arrangement: " + arr.String())
@@ -690,6 +703,17 @@ func encodeVecRRR(op vecOp, rd, rn, rm uint32, arr vecArrangement) uint32 {
 		}
 		size, q := arrToSizeQEncoded(arr)
 		return encodeAdvancedSIMDThreeDifferent(rd, rn, rm, 0b1000, size, 0b1, q)
+	case vecOpSdot:
+		// SDOT (vector) requires FEAT_DotProd, and the arrangement is the one of the destination.
+		var q uint32
+		switch arr {
+		case vecArrangement4S:
+			q = 0b1
+		case vecArrangement2S:
+		default:
+			panic("unsupported arrangement: " + arr.String())
+		}
+		return encodeAdvancedSIMDThreeSameExtra(rd, rn, rm, 0b0010, 0b10, 0b0, q)
 	case vecOpSshl:
 		if arr == vecArrangement1D {
 			panic("unsupported arrangement: " + arr.String())
@@ -756,6 +780,12 @@ func encodeAdvancedSIMDThreeSame(rd, rn, rm, opcode, size, U, Q uint32) uint32 {
 	return Q<<30 | U<<29 | 0b111<<25 | size<<22 | 0b1<<21 | rm<<16 | opcode<<11 | 0b1<<10 | rn<<5 | rd
 }
 
+// encodeAdvancedSIMDThreeSameExtra encodes as "Advanced SIMD three same (extra)" in
+// https://developer.arm.com/documentation/ddi0596/2020-12/Index-by-Encoding/Data-Processing----Scalar-Floating-Point-and-Advanced-SIMD?lang=en
+func encodeAdvancedSIMDThreeSameExtra(rd, rn, rm, opcode, size, U, Q uint32) uint32 {
+	return Q<<30 | U<<29 | 0b01110<<24 | size<<22 | rm<<16 | 0b1<<15 | opcode<<11 | 0b1<<10 | rn<<5 | rd
+}
+
 // encodeAdvancedSIMDThreeDifferent encodes as "Advanced SIMD three different" in
 // https://developer.arm.com/documentation/ddi0596/2020-12/Index-by-Encoding/Data-Processing----Scalar-Floating-Point-and-Advanced-SIMD?lang=en
 func encodeAdvancedSIMDThreeDifferent(rd, rn, rm, opcode, size, U, Q uint32) uint32 {
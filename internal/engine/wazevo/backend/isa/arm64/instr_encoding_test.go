@@ -653,6 +653,18 @@ func TestInstruction_encode(t *testing.T) {
 		{want: "41dc636e", setup: func(i *instruction) {
 			i.asVecRRR(vecOpFmul, v1VReg, operandNR(v2VReg), operandNR(v3VReg), vecArrangement2D)
 		}},
+		{want: "41cc234e", setup: func(i *instruction) {
+			i.asVecRRRRewrite(vecOpFmla, v1VReg, operandNR(v2VReg), operandNR(v3VReg), vecArrangement4S)
+		}},
+		{want: "41cc634e", setup: func(i *instruction) {
+			i.asVecRRRRewrite(vecOpFmla, v1VReg, operandNR(v2VReg), operandNR(v3VReg), vecArrangement2D)
+		}},
+		{want: "4194834e", setup: func(i *instruction) {
+			i.asVecRRRRewrite(vecOpSdot, v1VReg, operandNR(v2VReg), operandNR(v3VReg), vecArrangement4S)
+		}},
+		{want: "4194830e", setup: func(i *instruction) {
+			i.asVecRRRRewrite(vecOpSdot, v1VReg, operandNR(v2VReg), operandNR(v3VReg), vecArrangement2S)
+		}},
 		{want: "41b4636e", setup: func(i *instruction) {
 			i.asVecRRR(vecOpSqrdmulh, v1VReg, operandNR(v2VReg), operandNR(v3VReg), vecArrangement8H)
 		}},
@@ -13,6 +13,7 @@ import (
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+	"github.com/tetratelabs/wazero/internal/platform"
 )
 
 // LowerSingleBranch implements backend.Machine.
@@ -415,6 +416,9 @@ func (m *machine) LowerInstr(instr *ssa.Instruction) {
 		m.lowerVhighBits(rm, rd, arr)
 	case ssa.OpcodeVIadd:
 		x, y, lane := instr.Arg2WithLane()
+		if lane == ssa.VecLaneI32x4 && m.tryLowerSdot(x, y, instr.Return()) {
+			return
+		}
 		arr := ssaLaneToArrangement(lane)
 		m.lowerVecRRR(vecOpAdd, x, y, instr.Return(), arr)
 	case ssa.OpcodeExtIaddPairwise:
@@ -767,6 +771,36 @@ func (m *machine) LowerInstr(instr *ssa.Instruction) {
 		rd := m.compiler.VRegOf(instr.Return())
 		m.insert(m.allocateInstr().asFpuMov128(rd, tmp.nr()))
 
+	case ssa.OpcodeVIdotI8x16I7x16S:
+		x, y := instr.Arg2()
+		xx, yy := m.getOperand_NR(m.compiler.ValueDefinition(x), extModeNone),
+			m.getOperand_NR(m.compiler.ValueDefinition(y), extModeNone)
+		tmp, tmp2 := operandNR(m.compiler.AllocateVReg(ssa.TypeV128)), operandNR(m.compiler.AllocateVReg(ssa.TypeV128))
+		m.insert(m.allocateInstr().asVecRRR(vecOpSmull, tmp.nr(), xx, yy, vecArrangement16B))
+		m.insert(m.allocateInstr().asVecRRR(vecOpSmull2, tmp2.nr(), xx, yy, vecArrangement16B))
+		m.insert(m.allocateInstr().asVecRRR(vecOpAddp, tmp.nr(), tmp, tmp2, vecArrangement8H))
+
+		rd := m.compiler.VRegOf(instr.Return())
+		m.insert(m.allocateInstr().asFpuMov128(rd, tmp.nr()))
+
+	case ssa.OpcodeVFma:
+		x, y, z, lane := instr.Arg3WithLane()
+		arr := ssaLaneToArrangement(lane)
+		rn := m.getOperand_NR(m.compiler.ValueDefinition(x), extModeNone)
+		rm := m.getOperand_NR(m.compiler.ValueDefinition(y), extModeNone)
+		acc := m.getOperand_NR(m.compiler.ValueDefinition(z), extModeNone)
+		tmp := m.compiler.AllocateVReg(ssa.TypeV128)
+
+		// FMLA accumulates into its destination, so copy the addend into a temporary first
+		// in case it is used somewhere else.
+		m.insert(m.allocateInstr().asFpuMov128(tmp, acc.nr()))
+		fmla := m.allocateInstr()
+		fmla.asVecRRRRewrite(vecOpFmla, tmp, rn, rm, arr)
+		m.insert(fmla)
+
+		rd := m.compiler.VRegOf(instr.Return())
+		m.insert(m.allocateInstr().asFpuMov128(rd, tmp))
+
 	case ssa.OpcodeLoadSplat:
 		ptr, offset, lane := instr.LoadSplatData()
 		m.lowerLoadSplat(ptr, offset, lane, instr.Return())
@@ -1145,6 +1179,60 @@ func (m *machine) lowerVecRRR(op vecOp, x, y, ret ssa.Value, arr vecArrangement)
 	m.insert(ins)
 }
 
+// tryLowerSdot lowers `VIadd.i32x4 (ExtIaddPairwise.i16x8 (VIdotI8x16I7x16S a, b)), acc` into a single SDOT
+// when FEAT_DotProd is available. This is how the frontend lowers i32x4.relaxed_dot_i8x16_i7x16_add_s, and SDOT
+// sums each group of four products into a 32-bit lane directly. x and y are the operands of VIadd in either order.
+func (m *machine) tryLowerSdot(x, y, ret ssa.Value) bool {
+	if !m.cpuFeatures.Has(platform.CpuFeatureArm64DotProd) {
+		return false
+	}
+
+	acc := y
+	dot, sum := m.matchSdot(x)
+	if dot == nil {
+		acc = x
+		dot, sum = m.matchSdot(y)
+		if dot == nil {
+			return false
+		}
+	}
+
+	a, b := dot.Arg2()
+	rn := m.getOperand_NR(m.compiler.ValueDefinition(a), extModeNone)
+	rm := m.getOperand_NR(m.compiler.ValueDefinition(b), extModeNone)
+	ra := m.getOperand_NR(m.compiler.ValueDefinition(acc), extModeNone)
+	tmp := m.compiler.AllocateVReg(ssa.TypeV128)
+
+	// SDOT accumulates into its destination, so copy the addend into a temporary first
+	// in case it is used somewhere else.
+	m.insert(m.allocateInstr().asFpuMov128(tmp, ra.nr()))
+	sdot := m.allocateInstr()
+	sdot.asVecRRRRewrite(vecOpSdot, tmp, rn, rm, vecArrangement4S)
+	m.insert(sdot)
+	m.insert(m.allocateInstr().asFpuMov128(m.compiler.VRegOf(ret), tmp))
+	sum.MarkLowered()
+	dot.MarkLowered()
+	return true
+}
+
+// matchSdot returns the VIdotI8x16I7x16S and the signed ExtIaddPairwise.i16x8 instructions defining v
+// if they can be folded into SDOT, or nil otherwise.
+func (m *machine) matchSdot(v ssa.Value) (dot, sum *ssa.Instruction) {
+	def := m.compiler.ValueDefinition(v)
+	if !m.compiler.MatchInstr(def, ssa.OpcodeExtIaddPairwise) {
+		return nil, nil
+	}
+	src, lane, signed := def.Instr.ExtIaddPairwiseData()
+	if lane != ssa.VecLaneI16x8 || !signed {
+		return nil, nil
+	}
+	srcDef := m.compiler.ValueDefinition(src)
+	if !m.compiler.MatchInstr(srcDef, ssa.OpcodeVIdotI8x16I7x16S) {
+		return nil, nil
+	}
+	return srcDef.Instr, def.Instr
+}
+
 func (m *machine) lowerVIMul(rd regalloc.VReg, rn, rm operand, arr vecArrangement) {
 	if arr != vecArrangement2D {
 		mul := m.allocateInstr()
@@ -10,6 +10,7 @@ import (
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/regalloc"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 )
 
@@ -538,6 +539,89 @@ mul x1.4s, x2.4s, x15.4s
 	}
 }
 
+func TestMachine_lowerVIdotI8x16I7x16S(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cpuFlags platform.CpuFeatureFlags
+		// refCount is the number of uses of the VIdotI8x16I7x16S result.
+		refCount int
+		exp      string
+	}{
+		{
+			name:     "dotprod",
+			cpuFlags: platform.CpuFeatureArm64DotProd,
+			refCount: 1,
+			exp: `
+mov v1?.16b, v3.16b
+sdot v1?.4s, v1.4s, v2.4s
+mov v4.16b, v1?.16b
+`,
+		},
+		{
+			name:     "no dotprod",
+			refCount: 1,
+			exp: `
+smull v3?.16b, v1.16b, v2.16b
+smull2 v4?.16b, v1.16b, v2.16b
+addp v3?.8h, v3?.8h, v4?.8h
+mov v10.16b, v3?.16b
+sshll v1?.4h, v10.4h, #0
+sshll v2?.8h, v10.8h, #0
+addp v11.4s, v1?.4s, v2?.4s
+add v4.4s, v11.4s, v3.4s
+`,
+		},
+		{
+			name:     "dotprod with shared dot product",
+			cpuFlags: platform.CpuFeatureArm64DotProd,
+			refCount: 2,
+			exp: `
+smull v3?.16b, v1.16b, v2.16b
+smull2 v4?.16b, v1.16b, v2.16b
+addp v3?.8h, v3?.8h, v4?.8h
+mov v10.16b, v3?.16b
+sshll v1?.4h, v10.4h, #0
+sshll v2?.8h, v10.8h, #0
+addp v11.4s, v1?.4s, v2?.4s
+add v4.4s, v11.4s, v3.4s
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, b, m := newSetupWithMockContext()
+			m.cpuFeatures = tc.cpuFlags
+
+			// i32x4.relaxed_dot_i8x16_i7x16_add_s as lowered by the frontend.
+			entry := b.CurrentBlock()
+			x := entry.AddParam(b, ssa.TypeV128)
+			y := entry.AddParam(b, ssa.TypeV128)
+			acc := entry.AddParam(b, ssa.TypeV128)
+			dot := b.AllocateInstruction().AsVIdotI8x16I7x16S(x, y).Insert(b)
+			sum := b.AllocateInstruction().AsExtIaddPairwise(dot.Return(), ssa.VecLaneI16x8, true).Insert(b)
+			add := b.AllocateInstruction().AsVIadd(sum.Return(), acc, ssa.VecLaneI32x4).Insert(b)
+
+			for _, v := range []ssa.Value{x, y, acc} {
+				ctx.definitions[v] = backend.SSAValueDefinition{V: v}
+			}
+			ctx.definitions[dot.Return()] = backend.SSAValueDefinition{V: dot.Return(), Instr: dot, RefCount: uint32(tc.refCount)}
+			ctx.definitions[sum.Return()] = backend.SSAValueDefinition{V: sum.Return(), Instr: sum, RefCount: 1}
+			ctx.vRegMap[x], ctx.vRegMap[y], ctx.vRegMap[acc] = v1VReg, v2VReg, v3VReg
+			ctx.vRegMap[add.Return()] = v4VReg
+			ctx.vRegMap[dot.Return()] = v10VReg
+			ctx.vRegMap[sum.Return()] = v11VReg
+
+			// Lower in reverse order as the backend does, skipping the instructions merged into their users.
+			for _, instr := range []*ssa.Instruction{add, sum, dot} {
+				if !instr.Lowered() {
+					m.LowerInstr(instr)
+					m.FlushPendingInstructions()
+				}
+			}
+			require.Equal(t, tc.exp, "\n"+formatEmittedInstructionsInCurrentBlock(m)+"\n")
+		})
+	}
+}
+
 func TestMachine_lowerVcheckTrue(t *testing.T) {
 	for _, tc := range []struct {
 		name          string
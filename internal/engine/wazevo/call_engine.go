@@ -3,6 +3,7 @@ package wazevo
 import (
 	"context"
 	"fmt"
+	"math"
 	"reflect"
 	"runtime"
 	"sync/atomic"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
 	"github.com/tetratelabs/wazero/internal/expctxkeys"
 	"github.com/tetratelabs/wazero/internal/internalapi"
@@ -49,6 +51,18 @@ type (
 		// pendingException holds the most recently caught exception, so handler
 		// code can read its params after re-entry.
 		pendingException *wasm.Exception
+		// fuel is the fuel of the call in progress, or nil if it is not limited.
+		fuel *wasm.Fuel
+		// stackRange is the index of c.stack registered by registerStack during the call in progress, if stackRegistered.
+		stackRange      int
+		stackRegistered bool
+		// fuelLoaded is the fuel copied from fuel to executionContext.fuel by loadFuel, so that putFuel only
+		// consumes what the native code did, as other calls may share the same fuel.
+		fuelLoaded int64
+		// callerValue is passed to the GoFunctionEntryFunc called during the call in progress, and unwound records
+		// the frames unwound by a panic. See SharedStateEngine.CallWithStack.
+		callerValue interface{}
+		unwound     FrameRecorder
 	}
 
 	// tryHandler records the state at a try_table entry for exception handling.
@@ -144,6 +158,9 @@ type (
 		// localsSaveAreaPtr points to the tryHandler's localsSaveArea slice
 		// backing array. Handlers load locals from this slice.
 		localsSaveAreaPtr uintptr
+		// fuel is the fuel left in the call in progress when fuel metering is enabled.
+		// This is synchronized with callEngine.fuel around the calls to Go functions.
+		fuel int64
 	}
 )
 
@@ -198,7 +215,7 @@ func (c *callEngine) Call(ctx context.Context, params ...uint64) ([]uint64, erro
 	return paramResultSlice[:c.numberOfResults], nil
 }
 
-func (c *callEngine) addFrame(builder wasmdebug.ErrorBuilder, addr uintptr) (def api.FunctionDefinition, listener experimental.FunctionListener) {
+func (c *callEngine) addFrame(builder FrameRecorder, addr uintptr) (def api.FunctionDefinition, listener experimental.FunctionListener) {
 	eng := c.parent.parent.parent
 	cm := eng.compiledModuleOfAddr(addr)
 	if cm == nil {
@@ -208,11 +225,23 @@ func (c *callEngine) addFrame(builder wasmdebug.ErrorBuilder, addr uintptr) (def
 		// First, we check itself.
 		if checkAddrInBytes(addr, c.parent.parent.executable) {
 			cm = c.parent.parent
+		} else if c.parent.parent.sharedState {
+			// The functions are compiled one by one with the shared state.
+			for i := range c.parent.sharedStateFunctions {
+				if candidate := c.parent.sharedStateFunctions[i].Load(); candidate != nil && checkAddrInBytes(addr, candidate.executable) {
+					cm = candidate
+					break
+				}
+			}
 		} else {
 			// Otherwise, search all imported modules. TODO: maybe recursive, but not sure it's useful in practice.
 			p := c.parent
 			for i := range p.importedFunctions {
-				candidate := p.importedFunctions[i].me.parent
+				me := p.importedFunctions[i].me
+				if me == nil { // The imported functions are not resolved with the shared state.
+					continue
+				}
+				candidate := me.parent
 				if checkAddrInBytes(addr, candidate.executable) {
 					cm = candidate
 					break
@@ -223,10 +252,15 @@ func (c *callEngine) addFrame(builder wasmdebug.ErrorBuilder, addr uintptr) (def
 
 	if cm != nil {
 		index := cm.functionIndexOf(addr)
+		sourceOffset := cm.getSourceOffset(addr)
+		if callSite, callee, ok := ssa.SourceOffset(sourceOffset).Inlined(); ok {
+			// addr is within the body of a function inlined in the one at index, which is added as the callee.
+			cm.addInlinedFrame(builder, uint64(callee))
+			sourceOffset = uint64(callSite)
+		}
 		def = cm.module.FunctionDefinition(cm.module.ImportFunctionCount + index)
 		var sources []string
 		if dw := cm.module.DWARFLines; dw != nil {
-			sourceOffset := cm.getSourceOffset(addr)
 			sources = dw.Line(sourceOffset)
 		}
 		builder.AddFrame(def.DebugName(), def.ParamTypes(), def.ResultTypes(), sources)
@@ -259,7 +293,8 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 	}
 
 	p := c.parent
-	ensureTermination := p.parent.ensureTermination
+	// With the shared state, the termination is ensured by the interpreter calling this.
+	ensureTermination := p.parent.ensureTermination && !p.parent.sharedState
 	m := p.module
 	if ensureTermination {
 		select {
@@ -274,6 +309,17 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 	// Clear any stale try_table handlers from a previous call.
 	c.tryHandlers = c.tryHandlers[:0]
 
+	c.fuel = nil
+	if p.parent.fuelMetering {
+		ctx, c.fuel = wasm.CallFuel(ctx)
+	}
+	if c.fuel != nil {
+		c.loadFuel()
+		defer c.putFuel()
+	} else {
+		c.execCtx.fuel = math.MaxInt64
+	}
+
 	var paramResultPtr *uint64
 	if len(paramResultStack) > 0 {
 		paramResultPtr = &paramResultStack[0]
@@ -285,6 +331,18 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			// let it propagate up to be handled by the caller.
 			panic(s)
 		}
+		if r != nil && p.parent.sharedState {
+			// The interpreter owning the state recovers it, as the callers are interpreted.
+			c.recordUnwoundFrames()
+			c.execCtx.exitCode = wazevoapi.ExitCodeOK
+			c.tryHandlers = c.tryHandlers[:0]
+			panic(r)
+		}
+		// An exception that escaped all handlers is reported as an uncaught exception error.
+		exn, _ := r.(*wasm.Exception)
+		if exn != nil {
+			r = wasmruntime.ErrRuntimeUncaughtException
+		}
 		if r != nil {
 			type listenerForAbort struct {
 				def api.FunctionDefinition
@@ -314,6 +372,9 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 				}
 			}
 			err = builder.FromRecovered(r)
+			if exn != nil {
+				err = &wasm.ExceptionError{Exception: exn, Err: err}
+			}
 
 			for _, lsn := range listeners {
 				lsn.lsn.Abort(ctx, m, lsn.def, err)
@@ -339,6 +400,13 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 	if c.stackTop&(16-1) != 0 {
 		panic("BUG: stack must be aligned to 16 bytes")
 	}
+	if p.parent.executables.registered {
+		// The memory access faults are recovered from with the frame pointers of the stack of this call.
+		if err = c.registerStack(); err != nil {
+			return err
+		}
+		defer c.unregisterStack()
+	}
 	entrypoint(c.preambleExecutable, c.executable, c.execCtxPtr, c.parent.opaquePtr, paramResultPtr, c.stackTop)
 	for {
 		switch ec := c.execCtx.exitCode; ec & wazevoapi.ExitCodeMask {
@@ -358,14 +426,15 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 				return err
 			}
 			adjustClonedStack(oldsp, oldTop, newsp, newfp, c.stackTop)
+			c.updateStack()
 			// Old stack must be alive until the new stack is adjusted.
 			runtime.KeepAlive(oldStack)
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
 			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr, newsp, newfp)
 		case wazevoapi.ExitCodeGrowMemory:
 			mod := c.callerModuleInstance()
-			mem := mod.MemoryInstance
 			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
+			mem := mod.Memories[uint32(s[1])]
 			argRes := &s[0]
 			if res, ok := mem.Grow(uint32(*argRes)); !ok {
 				*argRes = uint64(0xffffffff) // = -1 in signed 32-bit integer.
@@ -387,9 +456,11 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			index := wazevoapi.GoFunctionIndexFromExitCode(ec)
 			f := hostModuleGoFuncFromOpaque[api.GoFunction](index, c.execCtx.goFunctionCallCalleeModuleContextOpaque)
 			func() {
-				if snapshotEnabled {
-					defer snapshotRecoverFn(c)
+				if snapshotEnabled || len(c.tryHandlers) > 0 {
+					defer goFunctionRecoverFn(c, nil)
 				}
+				c.putFuel()
+				defer c.loadFuel()
 				f.Call(ctx, goCallStackView(c.execCtx.stackPointerBeforeGoCall))
 			}()
 			// Back to the native code.
@@ -408,14 +479,20 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			def := hostModule.FunctionDefinition(wasm.Index(index))
 			listener.Before(ctx, callerModule, def, s, c.stackIterator(true))
 			// Call into the Go function.
-			func() {
-				if snapshotEnabled {
-					defer snapshotRecoverFn(c)
+			caught := func() (caught bool) {
+				if snapshotEnabled || len(c.tryHandlers) > 0 {
+					defer goFunctionRecoverFn(c, &caught)
 				}
+				c.putFuel()
+				defer c.loadFuel()
 				f.Call(ctx, s)
+				return
 			}()
-			// Call Listener.After.
-			listener.After(ctx, callerModule, def, s)
+			// Call Listener.After, unless the Go function threw an exception
+			// which was caught by a handler.
+			if !caught {
+				listener.After(ctx, callerModule, def, s)
+			}
 			// Back to the native code.
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
 			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr,
@@ -425,10 +502,16 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			f := hostModuleGoFuncFromOpaque[api.GoModuleFunction](index, c.execCtx.goFunctionCallCalleeModuleContextOpaque)
 			mod := c.callerModuleInstance()
 			func() {
-				if snapshotEnabled {
-					defer snapshotRecoverFn(c)
+				if snapshotEnabled || len(c.tryHandlers) > 0 {
+					defer goFunctionRecoverFn(c, nil)
+				}
+				c.putFuel()
+				defer c.loadFuel()
+				if ef, ok := f.(GoFunctionEntryFunc); ok {
+					ef(ctx, mod, c.callerValue, goCallStackView(c.execCtx.stackPointerBeforeGoCall))
+				} else {
+					f.Call(ctx, mod, goCallStackView(c.execCtx.stackPointerBeforeGoCall))
 				}
-				f.Call(ctx, mod, goCallStackView(c.execCtx.stackPointerBeforeGoCall))
 			}()
 			// Back to the native code.
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
@@ -446,14 +529,20 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			def := hostModule.FunctionDefinition(wasm.Index(index))
 			listener.Before(ctx, callerModule, def, s, c.stackIterator(true))
 			// Call into the Go function.
-			func() {
-				if snapshotEnabled {
-					defer snapshotRecoverFn(c)
+			caught := func() (caught bool) {
+				if snapshotEnabled || len(c.tryHandlers) > 0 {
+					defer goFunctionRecoverFn(c, &caught)
 				}
+				c.putFuel()
+				defer c.loadFuel()
 				f.Call(ctx, callerModule, s)
+				return
 			}()
-			// Call Listener.After.
-			listener.After(ctx, callerModule, def, s)
+			// Call Listener.After, unless the Go function threw an exception
+			// which was caught by a handler.
+			if !caught {
+				listener.After(ctx, callerModule, def, s)
+			}
 			// Back to the native code.
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
 			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr,
@@ -499,17 +588,17 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.execCtx.framePointerBeforeGoCall)
 		case wazevoapi.ExitCodeMemoryWait32:
 			mod := c.callerModuleInstance()
-			mem := mod.MemoryInstance
+			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
+			mem := mod.Memories[uint32(s[3])]
 			if !mem.Shared {
 				panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
 			}
 
-			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
 			timeout, exp, addr := int64(s[0]), uint32(s[1]), uintptr(s[2])
 			base := uintptr(unsafe.Pointer(&mem.Buffer[0]))
 
-			offset := uint32(addr - base)
-			res := mem.Wait32(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint32) uint32 {
+			offset := uint64(addr - base)
+			res := mem.Wait32(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint64) uint32 {
 				addr := unsafe.Add(unsafe.Pointer(&mem.Buffer[0]), offset)
 				return atomic.LoadUint32((*uint32)(addr))
 			})
@@ -519,17 +608,17 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.execCtx.framePointerBeforeGoCall)
 		case wazevoapi.ExitCodeMemoryWait64:
 			mod := c.callerModuleInstance()
-			mem := mod.MemoryInstance
+			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
+			mem := mod.Memories[uint32(s[3])]
 			if !mem.Shared {
 				panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
 			}
 
-			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
 			timeout, exp, addr := int64(s[0]), uint64(s[1]), uintptr(s[2])
 			base := uintptr(unsafe.Pointer(&mem.Buffer[0]))
 
-			offset := uint32(addr - base)
-			res := mem.Wait64(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint32) uint64 {
+			offset := uint64(addr - base)
+			res := mem.Wait64(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint64) uint64 {
 				addr := unsafe.Add(unsafe.Pointer(&mem.Buffer[0]), offset)
 				return atomic.LoadUint64((*uint64)(addr))
 			})
@@ -539,11 +628,11 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.execCtx.framePointerBeforeGoCall)
 		case wazevoapi.ExitCodeMemoryNotify:
 			mod := c.callerModuleInstance()
-			mem := mod.MemoryInstance
-
 			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
+			mem := mod.Memories[uint32(s[2])]
+
 			count, addr := uint32(s[0]), s[1]
-			offset := uint32(uintptr(addr) - uintptr(unsafe.Pointer(&mem.Buffer[0])))
+			offset := uint64(uintptr(addr) - uintptr(unsafe.Pointer(&mem.Buffer[0])))
 			res := mem.Notify(offset, count)
 			s[0] = uint64(res)
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
@@ -594,7 +683,7 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			// conversion from uintptr into unsafe.Pointer, which triggers checkptr.
 			exn := *(**wasm.Exception)(unsafe.Pointer(&s[0]))
 			if !c.doHandleException(exn) {
-				panic(wasmruntime.ErrRuntimeUncaughtException)
+				panic(exn)
 			}
 			if len(exn.Params) > 0 {
 				c.execCtx.exceptionParamsPtr = uintptr(unsafe.Pointer(&exn.Params[0]))
@@ -605,6 +694,8 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 				uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.execCtx.framePointerBeforeGoCall)
 		case wazevoapi.ExitCodeNullReference:
 			panic(wasmruntime.ErrRuntimeNullReference)
+		case wazevoapi.ExitCodeFuelExhausted:
+			panic(m.CloseOnFuelExhausted(ctx))
 		case wazevoapi.ExitCodeTryTableEnter:
 			// Save current state as a try handler checkpoint using stack cloning
 			// (same approach as experimental.Snapshot).
@@ -695,6 +786,7 @@ func (c *callEngine) doHandleException(exn *wasm.Exception) bool {
 				spp := *(**uint64)(unsafe.Pointer(&h.sp))
 				c.stack = h.stack
 				c.stackTop = h.top
+				c.updateStack()
 				ec := &c.execCtx
 				ec.stackBottomPtr = &c.stack[0]
 				ec.stackPointerBeforeGoCall = spp
@@ -724,6 +816,42 @@ func (c *callEngine) restoreLocalsSaveAreaPtr(from int) {
 	c.execCtx.localsSaveAreaPtr = 0
 }
 
+// registerStack registers c.stack to the SIGSEGV handler of the memory guard pages during the call in progress.
+func (c *callEngine) registerStack() (err error) {
+	c.stackRange, err = registerStack(c.stack, c.execCtxPtr)
+	c.stackRegistered = err == nil
+	return
+}
+
+// updateStack updates the stack registered by registerStack after c.stack is replaced.
+func (c *callEngine) updateStack() {
+	if c.stackRegistered {
+		updateStack(c.stackRange, c.stack)
+	}
+}
+
+// unregisterStack undoes registerStack.
+func (c *callEngine) unregisterStack() {
+	unregisterStack(c.stackRange)
+	c.stackRegistered = false
+}
+
+// putFuel makes the fuel consumed by the native code visible to Go functions, e.g. via experimental.RemainingFuel.
+func (c *callEngine) putFuel() {
+	if c.fuel != nil {
+		c.fuel.Consume(c.fuelLoaded - c.execCtx.fuel)
+		c.fuelLoaded = c.execCtx.fuel
+	}
+}
+
+// loadFuel reloads the fuel after a Go function call, which may have consumed it with nested calls.
+func (c *callEngine) loadFuel() {
+	if c.fuel != nil {
+		c.fuelLoaded = c.fuel.Remaining()
+		c.execCtx.fuel = c.fuelLoaded
+	}
+}
+
 func (c *callEngine) callerModuleInstance() *wasm.ModuleInstance {
 	return moduleInstanceFromOpaquePtr(c.execCtx.callerModuleContextPtr)
 }
@@ -896,6 +1024,7 @@ func (s *snapshot) doRestore() {
 	c := s.c
 	c.stack = s.stack
 	c.stackTop = s.top
+	c.updateStack()
 	ec := &c.execCtx
 	ec.stackBottomPtr = &c.stack[0]
 	ec.stackPointerBeforeGoCall = spp
@@ -910,12 +1039,30 @@ func (s *snapshot) Error() string {
 		"exported function invocation than snapshot"
 }
 
-func snapshotRecoverFn(c *callEngine) {
+// goFunctionRecoverFn is deferred around a Go function call when the snapshotter
+// is enabled or a try_table handler is active. It restores snapshots taken by c,
+// and delivers a *wasm.Exception thrown by the Go function to the matching
+// handler, in which case caught, if non-nil, is set to true.
+func goFunctionRecoverFn(c *callEngine, caught *bool) {
 	if r := recover(); r != nil {
-		if s, ok := r.(*snapshot); ok && s.c == c {
-			s.doRestore()
-		} else {
-			panic(r)
+		switch v := r.(type) {
+		case *snapshot:
+			if v.c == c {
+				v.doRestore()
+				return
+			}
+		case *wasm.Exception:
+			if c.doHandleException(v) {
+				if len(v.Params) > 0 {
+					c.execCtx.exceptionParamsPtr = uintptr(unsafe.Pointer(&v.Params[0]))
+				}
+				c.execCtx.exceptionPtr = uintptr(unsafe.Pointer(v))
+				if caught != nil {
+					*caught = true
+				}
+				return
+			}
 		}
+		panic(r)
 	}
 }
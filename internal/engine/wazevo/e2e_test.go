@@ -437,6 +437,15 @@ func TestE2E(t *testing.T) {
 				{params: []uint64{uint64(wasm.MemoryPageSize) - 3}, expErr: "out of bounds memory access"},
 			},
 		},
+		{
+			name: "memory_load_in_loop",
+			m:    testcases.MemoryLoadInLoop.Module,
+			calls: []callCase{
+				{params: []uint64{0, 1}, expResults: []uint64{2 * 0x03_02_01_00}},
+				{params: []uint64{0, 2}, expResults: []uint64{3 * 0x03_02_01_00}},
+				{params: []uint64{uint64(wasm.MemoryPageSize) - 3, 2}, expErr: "out of bounds memory access"},
+			},
+		},
 		{
 			name: "memory_loads",
 			m:    testcases.MemoryLoads.Module,
@@ -1195,7 +1204,7 @@ func TestE2E_stores(t *testing.T) {
 func TestE2E_reexported_memory(t *testing.T) {
 	m1 := &wasm.Module{
 		ExportSection: []wasm.Export{{Name: "mem", Type: wasm.ExternTypeMemory, Index: 0}},
-		MemorySection: &wasm.Memory{Min: 1},
+		MemorySection: []wasm.Memory{{Min: 1}},
 		NameSection:   &wasm.NameSection{ModuleName: "m1"},
 	}
 	m2 := &wasm.Module{
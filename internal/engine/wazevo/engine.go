@@ -18,6 +18,7 @@ import (
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/frontend"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
 	"github.com/tetratelabs/wazero/internal/filecache"
 	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/version"
@@ -93,6 +94,8 @@ type (
 		parent                    *engine
 		module                    *wasm.Module
 		ensureTermination         bool
+		fuelMetering              bool
+		sharedState               bool
 		listeners                 []experimental.FunctionListener
 		listenerBeforeTrampolines []*byte
 		listenerAfterTrampolines  []*byte
@@ -111,6 +114,8 @@ type (
 		executable         []byte
 		entryPreambles     []byte
 		entryPreamblesPtrs []*byte
+		// registered is true if executable is registered via registerExecutable.
+		registered bool
 	}
 )
 
@@ -142,14 +147,30 @@ func NewEngine(ctx context.Context, _ api.CoreFeatures, fc filecache.Cache) wasm
 	return e
 }
 
+// VerifyMemoryGuardPages returns nil if the compiled code can elide the bounds checks by surrounding the linear
+// memories with guard pages, which requires the SIGSEGV handler recovering from the faults to be installed.
+// This installs it if not yet, and verifies that it has not been replaced otherwise.
+func VerifyMemoryGuardPages() error {
+	return verifyMemoryGuardPages()
+}
+
 // CompileModule implements wasm.Engine.
-func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) (err error) {
+func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination, fuelMetering, memoryGuardPages bool) (err error) {
 	if wazevoapi.PerfMapEnabled {
 		wazevoapi.PerfMap.Lock()
 		defer wazevoapi.PerfMap.Unlock()
 	}
 
-	if _, ok, err := e.getCompiledModule(module, listeners, ensureTermination); ok { // cache hit!
+	if module.UsesGC {
+		return errors.New("GC proposal is not supported by the compiler: use the interpreter instead")
+	}
+	if memoryGuardPages {
+		if err = verifyMemoryGuardPages(); err != nil {
+			return err
+		}
+	}
+
+	if _, ok, err := e.getCompiledModule(module, listeners, ensureTermination, fuelMetering, memoryGuardPages); ok { // cache hit!
 		return nil
 	} else if err != nil {
 		return err
@@ -158,7 +179,7 @@ func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listene
 	if wazevoapi.DeterministicCompilationVerifierEnabled {
 		ctx = wazevoapi.NewDeterministicCompilationVerifierContext(ctx, len(module.CodeSection))
 	}
-	cm, err := e.compileModule(ctx, module, listeners, ensureTermination)
+	cm, err := e.compileModule(ctx, module, listeners, ensureTermination, fuelMetering, memoryGuardPages)
 	if err != nil {
 		return err
 	}
@@ -168,7 +189,7 @@ func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listene
 
 	if wazevoapi.DeterministicCompilationVerifierEnabled {
 		for i := 0; i < wazevoapi.DeterministicCompilationVerifyingIter; i++ {
-			_, err := e.compileModule(ctx, module, listeners, ensureTermination)
+			_, err := e.compileModule(ctx, module, listeners, ensureTermination, fuelMetering, memoryGuardPages)
 			if err != nil {
 				return err
 			}
@@ -225,7 +246,7 @@ func (exec *executables) compileEntryPreambles(m *wasm.Module, machine backend.M
 	}
 }
 
-func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) (*compiledModule, error) {
+func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination, fuelMetering, memoryGuardPages bool) (*compiledModule, error) {
 	if module.IsHostModule {
 		return e.compileHostModule(ctx, module, listeners)
 	}
@@ -234,6 +255,7 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 	cm := &compiledModule{
 		offsets: wazevoapi.NewModuleContextOffsetData(module, withListener), parent: e, module: module,
 		ensureTermination: ensureTermination,
+		fuelMetering:      fuelMetering,
 		executables:       &executables{},
 	}
 
@@ -248,9 +270,12 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 		return nil, err
 	}
 
-	needSourceInfo := module.DWARFLines != nil
+	inliningBudget := experimental.GetInliningBudget(ctx)
+	// The source offsets are also needed to report the traps raised by the inlined functions in their own frames.
+	needSourceInfo := module.DWARFLines != nil || inliningBudget > 0
+	relocator.needSourceInfo = needSourceInfo
 
-	ssaBuilder := ssa.NewBuilder()
+	ssaBuilder := newSSABuilder(ctx)
 	be := backend.NewCompiler(ctx, machine, ssaBuilder)
 	cm.executables.compileEntryPreambles(module, machine, be)
 	cm.functionOffsets = make([]int, localFns)
@@ -263,7 +288,9 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 
 	if workers := experimental.GetCompilationWorkers(ctx); workers <= 1 {
 		// Compile with a single goroutine.
-		fe := frontend.NewFrontendCompiler(module, ssaBuilder, &cm.offsets, ensureTermination, withListener, needSourceInfo)
+		fe := frontend.NewFrontendCompiler(module, ssaBuilder, &cm.offsets, ensureTermination, fuelMetering, withListener, needSourceInfo).
+			WithInliningBudget(inliningBudget).
+			WithMemoryGuardPages(memoryGuardPages)
 
 		for i := range module.CodeSection {
 			if wazevoapi.DeterministicCompilationVerifierEnabled {
@@ -314,11 +341,13 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 
 				// Creates new compiler instances which are reused for each function.
 				machine := newMachine()
-				ssaBuilder := ssa.NewBuilder()
+				ssaBuilder := newSSABuilder(ctx)
 				be := backend.NewCompiler(ctx, machine, ssaBuilder)
 				fe := frontend.NewFrontendCompiler(
-					module, ssaBuilder, &cm.offsets, ensureTermination, withListener, needSourceInfo).
-					WithTryTableMetadata(sharedTTM)
+					module, ssaBuilder, &cm.offsets, ensureTermination, fuelMetering, withListener, needSourceInfo).
+					WithTryTableMetadata(sharedTTM).
+					WithInliningBudget(inliningBudget).
+					WithMemoryGuardPages(memoryGuardPages)
 
 				for {
 					if err := ctx.Err(); err != nil {
@@ -395,11 +424,25 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 	if err = platform.MprotectCodeSegment(executable); err != nil {
 		return nil, err
 	}
+	if memoryGuardPages {
+		if err = cm.executables.register(); err != nil {
+			return nil, err
+		}
+	}
 	cm.sharedFunctions = e.sharedFunctions
 	e.setFinalizer(cm.executables, executablesFinalizer)
 	return cm, nil
 }
 
+// newSSABuilder returns a new ssa.Builder running the optimization passes set in the context, if any.
+func newSSABuilder(ctx context.Context) ssa.Builder {
+	b := ssa.NewBuilder()
+	if passes, ok := ctx.Value(expctxkeys.SSAOptimizationPasses{}).(ssa.OptimizationPasses); ok {
+		b.SetOptimizationPasses(passes)
+	}
+	return b
+}
+
 func functionContext(ctx context.Context, module *wasm.Module, fnum int, fidx wasm.Index) context.Context {
 	if wazevoapi.NeedFunctionNameInContext {
 		def := module.FunctionDefinition(fidx)
@@ -420,6 +463,8 @@ type engineRelocator struct {
 	trampolineInterval          int
 	callTrampolineIslandSize    int
 	callTrampolineIslandOffsets []int // Holds the offsets of trampoline islands.
+	// needSourceInfo is true if the source offsets of the functions are recorded in compiledModule.sourceMap.
+	needSourceInfo bool
 }
 
 func newEngineRelocator(
@@ -453,8 +498,7 @@ func (r *engineRelocator) appendFunction(
 	r.totalSize = (r.totalSize + 15) &^ 15
 	cm.functionOffsets[fnum] = r.totalSize
 
-	needSourceInfo := module.DWARFLines != nil
-	if needSourceInfo {
+	if r.needSourceInfo {
 		// At the beginning of the function, we add the offset of the function body so that
 		// we can resolve the source location of the call site of before listener call.
 		cm.sourceMap.executableOffsets = append(cm.sourceMap.executableOffsets, uintptr(r.totalSize))
@@ -546,7 +590,10 @@ func (e *engine) compileHostModule(ctx context.Context, module *wasm.Module, lis
 	be := backend.NewCompiler(ctx, machine, ssa.NewBuilder())
 
 	num := len(module.CodeSection)
-	cm := &compiledModule{module: module, listeners: listeners, executables: &executables{}}
+	cm := &compiledModule{
+		offsets: newHostModuleOffsetData(module), module: module, listeners: listeners,
+		executables: &executables{},
+	}
 	cm.functionOffsets = make([]int, num)
 	totalSize := 0 // Total binary size of the executable.
 	bodies := make([][]byte, num)
@@ -721,7 +768,7 @@ func (e *engine) compiledModuleOfAddr(addr uintptr) *compiledModule {
 }
 
 func checkAddrInBytes(addr uintptr, b []byte) bool {
-	return uintptr(unsafe.Pointer(&b[0])) <= addr && addr <= uintptr(unsafe.Pointer(&b[len(b)-1]))
+	return len(b) > 0 && uintptr(unsafe.Pointer(&b[0])) <= addr && addr <= uintptr(unsafe.Pointer(&b[len(b)-1]))
 }
 
 // NewModuleEngine implements wasm.Engine.
@@ -740,7 +787,7 @@ func (e *engine) NewModuleEngine(m *wasm.Module, mi *wasm.ModuleInstance) (wasm.
 	me.listeners = compiled.listeners
 
 	if m.IsHostModule {
-		me.opaque = buildHostModuleOpaque(m, compiled.listeners)
+		me.opaque = buildHostModuleOpaque(m, &compiled.offsets, compiled.listeners)
 		me.opaquePtr = &me.opaque[0]
 	} else {
 		if size := compiled.offsets.TotalSize; size != 0 {
@@ -766,7 +813,7 @@ func (e *engine) compileSharedFunctions() {
 	e.be.Init()
 	addTrampoline(0,
 		e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeGrowMemory, &ssa.Signature{
-			Params:  []ssa.Type{ssa.TypeI64 /* exec context */, ssa.TypeI32},
+			Params:  []ssa.Type{ssa.TypeI64 /* exec context */, ssa.TypeI32 /* pages */, ssa.TypeI32 /* memory index */},
 			Results: []ssa.Type{ssa.TypeI32},
 		}, false))
 
@@ -797,8 +844,8 @@ func (e *engine) compileSharedFunctions() {
 	e.be.Init()
 	addTrampoline(5,
 		e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeMemoryWait32, &ssa.Signature{
-			// exec context, timeout, expected, addr
-			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64},
+			// exec context, timeout, expected, addr, memory index
+			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32},
 			// Returns the status.
 			Results: []ssa.Type{ssa.TypeI32},
 		}, false))
@@ -806,8 +853,8 @@ func (e *engine) compileSharedFunctions() {
 	e.be.Init()
 	addTrampoline(6,
 		e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeMemoryWait64, &ssa.Signature{
-			// exec context, timeout, expected, addr
-			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64},
+			// exec context, timeout, expected, addr, memory index
+			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI32},
 			// Returns the status.
 			Results: []ssa.Type{ssa.TypeI32},
 		}, false))
@@ -815,8 +862,8 @@ func (e *engine) compileSharedFunctions() {
 	e.be.Init()
 	addTrampoline(7,
 		e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeMemoryNotify, &ssa.Signature{
-			// exec context, count, addr
-			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64},
+			// exec context, count, addr, memory index
+			Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32},
 			// Returns the number notified.
 			Results: []ssa.Type{ssa.TypeI32},
 		}, false))
@@ -916,7 +963,24 @@ func sharedFunctionsFinalizer(sf *sharedFunctions) {
 	sf.listenerTrampolines = nil
 }
 
+// register makes the memory access faults raised within the executable be reported as
+// wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess, as the bounds checks are elided with the memory guard pages.
+func (exec *executables) register() error {
+	if len(exec.executable) == 0 {
+		return nil
+	}
+	if err := registerExecutable(exec.executable); err != nil {
+		return err
+	}
+	exec.registered = true
+	return nil
+}
+
 func executablesFinalizer(exec *executables) {
+	if exec.registered {
+		unregisterExecutable(exec.executable)
+		exec.registered = false
+	}
 	if len(exec.executable) > 0 {
 		if err := platform.MunmapCodeSegment(exec.executable); err != nil {
 			panic(err)
@@ -1006,3 +1070,18 @@ func (cm *compiledModule) getSourceOffset(pc uintptr) uint64 {
 	}
 	return cm.sourceMap.wasmBinaryOffsets[index]
 }
+
+// addInlinedFrame adds the frame of the function whose body contains the source offset `callee` of an inlined
+// instruction, which is inlined in the function of the frame added next.
+func (cm *compiledModule) addInlinedFrame(builder FrameRecorder, callee uint64) {
+	code := cm.module.CodeSection
+	index := sort.Search(len(code), func(i int) bool {
+		return code[i].BodyOffsetInCodeSection > callee
+	}) - 1
+	def := cm.module.FunctionDefinition(cm.module.ImportFunctionCount + wasm.Index(index))
+	var sources []string
+	if dw := cm.module.DWARFLines; dw != nil {
+		sources = dw.Line(callee)
+	}
+	builder.AddFrame(def.DebugName(), def.ParamTypes(), def.ResultTypes(), sources)
+}
@@ -31,6 +31,13 @@ type Compiler struct {
 	refFuncSig             ssa.Signature
 	memmoveSig             ssa.Signature
 	ensureTermination      bool
+	fuelMetering           bool
+	// memoryGuardPages is true if the memories with 32-bit addresses are surrounded by guard pages. See WithMemoryGuardPages.
+	memoryGuardPages bool
+	// inliningBudget is the maximum size of the function bodies to inline. See WithInliningBudget.
+	inliningBudget int
+	// sharedState is true if the module instance state is owned by another engine. See WithSharedState.
+	sharedState bool
 
 	// Followings are reset by per function.
 
@@ -46,6 +53,8 @@ type Compiler struct {
 	memoryBaseVariable, memoryLenVariable ssa.Variable
 	needMemory                            bool
 	memoryShared                          bool
+	memoriesShared                        []bool // index-correlated with the memory index space.
+	memories64                            []bool // index-correlated with the memory index space.
 	globalVariables                       []ssa.Variable
 	globalVariablesTypes                  []ssa.Type
 	mutableGlobalVariablesIndexes         []wasm.Index // index to ^.
@@ -80,6 +89,12 @@ type Compiler struct {
 	// emit extra stores to the locals save area so handler blocks can read
 	// throw-time values.
 	tryTableDepth int
+	// inlining is true while lowering the body of an inlined function. See lowerInlinedCall.
+	inlining bool
+	// inlinedCallSite is the source offset of the call being inlined while inlining is true.
+	inlinedCallSite ssa.SourceOffset
+	// inlinedLocalToVariable is reused by lowerInlinedCall for the locals of the inlined functions.
+	inlinedLocalToVariable []ssa.Variable
 
 	// Following are reused for the known safe bounds analysis.
 
@@ -106,13 +121,14 @@ type (
 var knownSafeBoundsAtTheEndOfBlockNil = wazevoapi.NewNilVarLength[knownSafeBoundWithID]()
 
 // NewFrontendCompiler returns a frontend Compiler.
-func NewFrontendCompiler(m *wasm.Module, ssaBuilder ssa.Builder, offset *wazevoapi.ModuleContextOffsetData, ensureTermination bool, listenerOn bool, sourceInfo bool) *Compiler {
+func NewFrontendCompiler(m *wasm.Module, ssaBuilder ssa.Builder, offset *wazevoapi.ModuleContextOffsetData, ensureTermination, fuelMetering bool, listenerOn bool, sourceInfo bool) *Compiler {
 	c := &Compiler{
 		m:                                 m,
 		ssaBuilder:                        ssaBuilder,
 		br:                                bytes.NewReader(nil),
 		offset:                            offset,
 		ensureTermination:                 ensureTermination,
+		fuelMetering:                      fuelMetering,
 		needSourceOffsetInfo:              sourceInfo,
 		tryTableMetadata:                  &localTryTableMetadata{},
 		varLengthKnownSafeBoundWithIDPool: wazevoapi.NewVarLengthPool[knownSafeBoundWithID](),
@@ -176,6 +192,28 @@ func (c *Compiler) WithTryTableMetadata(t tryTableMetadata) *Compiler {
 	return c
 }
 
+// WithMemoryGuardPages makes the accesses to the memories with 32-bit addresses elide the bounds checks, as these
+// memories are surrounded by the guard pages and the out of bounds accesses are trapped by the signal handler.
+// Atomic accesses and bulk memory operations still check the bounds explicitly.
+func (c *Compiler) WithMemoryGuardPages(enabled bool) *Compiler {
+	c.memoryGuardPages = enabled
+	return c
+}
+
+// WithSharedState makes the compiled code run on the state of the module instance owned by another engine, i.e. the
+// interpreter tiering up the module. In that case:
+//   - All the globals are accessed via the pointers to wasm.GlobalInstance's Val stored in the module context,
+//     like the imported globals.
+//   - The function references, including the ones stored in the module context for all the functions, point to a
+//     pointer to a pointer to the functionInstance. The functionInstance is loaded at each call, as the owner may
+//     swap it.
+//   - The local functions are called via their function references like the imported ones, instead of directly.
+//     See wazevoapi.NewSharedStateModuleContextOffsetData.
+func (c *Compiler) WithSharedState(enabled bool) *Compiler {
+	c.sharedState = enabled
+	return c
+}
+
 // TryTableMetadata returns the accumulated try_table metadata.
 func (c *Compiler) TryTableMetadata() []wazevoapi.TryTableInfo {
 	return c.tryTableMetadata.Table()
@@ -210,8 +248,8 @@ func (c *Compiler) declareSignatures(listenerOn bool) {
 	}
 	c.memoryGrowSig = ssa.Signature{
 		ID: begin,
-		// Takes execution context and the page size to grow.
-		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32},
+		// Takes execution context, the page size to grow and the memory index.
+		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI32},
 		// Returns the previous page size.
 		Results: []ssa.Type{ssa.TypeI32},
 	}
@@ -250,8 +288,8 @@ func (c *Compiler) declareSignatures(listenerOn bool) {
 
 	c.memoryWait32Sig = ssa.Signature{
 		ID: c.memmoveSig.ID + 1,
-		// exec context, timeout, expected, addr
-		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64},
+		// exec context, timeout, expected, addr, memory index
+		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32},
 		// Returns the status.
 		Results: []ssa.Type{ssa.TypeI32},
 	}
@@ -259,8 +297,8 @@ func (c *Compiler) declareSignatures(listenerOn bool) {
 
 	c.memoryWait64Sig = ssa.Signature{
 		ID: c.memoryWait32Sig.ID + 1,
-		// exec context, timeout, expected, addr
-		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64},
+		// exec context, timeout, expected, addr, memory index
+		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI32},
 		// Returns the status.
 		Results: []ssa.Type{ssa.TypeI32},
 	}
@@ -268,8 +306,8 @@ func (c *Compiler) declareSignatures(listenerOn bool) {
 
 	c.memoryNotifySig = ssa.Signature{
 		ID: c.memoryWait64Sig.ID + 1,
-		// exec context, count, addr
-		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64},
+		// exec context, count, addr, memory index
+		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32},
 		// Returns the number notified.
 		Results: []ssa.Type{ssa.TypeI32},
 	}
@@ -373,6 +411,8 @@ func (c *Compiler) LowerToSSA() {
 	// Note: In Wasmtime or many other runtimes, moduleContextPtr is called "vmContext". Also note that `moduleContextPtr`
 	//  is wazero-specific since other runtimes can naturally use the OS-level signal to do this job thanks to the fact that
 	//  they can use native stack vs wazero cannot use Go-routine stack and have to use Go-runtime allocated []byte as a stack.
+	//  The exception is WithMemoryGuardPages, where the signal handler of the backend finds the execution context
+	//  from the record pushed at the top of the stack by the entry preamble.
 	c.execCtxPtrValue = entryBlock.AddParam(builder, executionContextPtrTyp)
 	c.moduleCtxPtrValue = entryBlock.AddParam(builder, moduleContextPtrTyp)
 	builder.AnnotateValue(c.execCtxPtrValue, "exec_ctx")
@@ -416,16 +456,20 @@ func (c *Compiler) declareWasmLocals() {
 }
 
 func (c *Compiler) declareNecessaryVariables() {
-	if c.needMemory = c.m.MemorySection != nil; c.needMemory {
-		c.memoryShared = c.m.MemorySection.IsShared
-	} else if c.needMemory = c.m.ImportMemoryCount > 0; c.needMemory {
-		for _, imp := range c.m.ImportSection {
-			if imp.Type == wasm.ExternTypeMemory {
-				c.memoryShared = imp.DescMem.IsShared
-				break
-			}
+	c.memoriesShared, c.memories64 = c.memoriesShared[:0], c.memories64[:0]
+	for _, imp := range c.m.ImportSection {
+		if imp.Type == wasm.ExternTypeMemory {
+			c.memoriesShared = append(c.memoriesShared, imp.DescMem.IsShared)
+			c.memories64 = append(c.memories64, imp.DescMem.Is64)
 		}
 	}
+	for i := range c.m.MemorySection {
+		c.memoriesShared = append(c.memoriesShared, c.m.MemorySection[i].IsShared)
+		c.memories64 = append(c.memories64, c.m.MemorySection[i].Is64)
+	}
+	if c.needMemory = len(c.memoriesShared) > 0; c.needMemory {
+		c.memoryShared = c.memoriesShared[0]
+	}
 
 	if c.needMemory {
 		c.memoryBaseVariable = c.ssaBuilder.DeclareVariable(ssa.TypeI64)
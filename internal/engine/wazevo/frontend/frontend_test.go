@@ -20,7 +20,10 @@ func TestCompiler_LowerToSSA(t *testing.T) {
 	for _, tc := range []struct {
 		name              string
 		ensureTermination bool
+		fuelMetering      bool
 		needListener      bool
+		inliningBudget    int
+		memoryGuardPages  bool
 		// m is the *wasm.Module to be compiled in this test.
 		m *wasm.Module
 		// targetIndex is the index of a local function to be compiled in this test.
@@ -277,6 +280,33 @@ blk1: () <-- (blk0,blk1)
 	v2:i64 = Load exec_ctx, 0x58
 	CallIndirect v2:sig2, exec_ctx
 	Jump blk1
+`,
+		},
+		{
+			name: "loop - br / fuel metering", m: testcases.LoopBr.Module,
+			fuelMetering: true,
+			exp: `
+blk0: (exec_ctx:i64, module_ctx:i64)
+	v2:i64 = Load exec_ctx, 0x4e0
+	v3:i64 = Iconst_64 0x1
+	v4:i64 = Isub v2, v3
+	Store v4, exec_ctx, 0x4e0
+	v5:i64 = Iconst_64 0x0
+	v6:i32 = Icmp lt_s, v4, v5
+	ExitIfTrue v6, exec_ctx, fuel_exhausted
+	Jump blk1
+
+blk1: () <-- (blk0,blk1)
+	v7:i64 = Load exec_ctx, 0x4e0
+	v8:i64 = Iconst_64 0x1
+	v9:i64 = Isub v7, v8
+	Store v9, exec_ctx, 0x4e0
+	v10:i64 = Iconst_64 0x0
+	v11:i32 = Icmp lt_s, v9, v10
+	ExitIfTrue v11, exec_ctx, fuel_exhausted
+	Jump blk1
+
+blk2: ()
 `,
 		},
 		{
@@ -554,6 +584,53 @@ blk0: (exec_ctx:i64, module_ctx:i64)
 	v4:i32 = Call f2:sig2, exec_ctx, module_ctx, v2, v3
 	v5:i32, v6:i32 = Call f3:sig3, exec_ctx, module_ctx, v4
 	Jump blk_ret, v5, v6
+`,
+		},
+		{
+			name:           "call / inlining",
+			m:              testcases.Call.Module,
+			inliningBudget: 6,
+			exp: `
+blk0: (exec_ctx:i64, module_ctx:i64)
+	v2:i32 = Iconst_32 0x28
+	v3:i32 = Iconst_32 0x5
+	v4:i32 = Iadd v2, v3
+	Jump blk_ret, v4, v4
+`,
+		},
+		{
+			name:           "call / inlining / budget",
+			m:              testcases.Call.Module,
+			inliningBudget: 3,
+			exp: `
+signatures:
+	sig2: i64i64i32i32_i32
+	sig3: i64i64i32_i32i32
+
+blk0: (exec_ctx:i64, module_ctx:i64)
+	v2:i32 = Iconst_32 0x28
+	v3:i32 = Iconst_32 0x5
+	v4:i32 = Call f2:sig2, exec_ctx, module_ctx, v2, v3
+	v5:i32, v6:i32 = Call f3:sig3, exec_ctx, module_ctx, v4
+	Jump blk_ret, v5, v6
+`,
+		},
+		{
+			name:           "call / inlining / fuel metering",
+			m:              testcases.CallSimple.Module,
+			fuelMetering:   true,
+			inliningBudget: 3,
+			exp: `
+blk0: (exec_ctx:i64, module_ctx:i64)
+	v2:i64 = Load exec_ctx, 0x4e0
+	v3:i64 = Iconst_64 0x4
+	v4:i64 = Isub v2, v3
+	Store v4, exec_ctx, 0x4e0
+	v5:i64 = Iconst_64 0x0
+	v6:i32 = Icmp lt_s, v4, v5
+	ExitIfTrue v6, exec_ctx, fuel_exhausted
+	v7:i32 = Iconst_32 0x28
+	Jump blk_ret, v7
 `,
 		},
 		{
@@ -1040,6 +1117,18 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
 	v9:i64 = Iadd v8, v4
 	v10:i32 = Load v9, 0x0
 	Jump blk_ret, v10
+`,
+		},
+		{
+			name: "memory_load_basic/guard pages", m: testcases.MemoryLoadBasic.Module,
+			memoryGuardPages: true,
+			exp: `
+blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
+	v3:i64 = Load module_ctx, 0x8
+	v4:i64 = UExtend v2, 32->64
+	v5:i64 = Iadd v3, v4
+	v6:i32 = Load v5, 0x0
+	Jump blk_ret, v6
 `,
 		},
 		{
@@ -1117,6 +1206,81 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32)
 	v4:i64 = Load module_ctx, 0x10
 	v5:i32 = CallIndirect v3:sig1, exec_ctx, v4, v2, v2
 	Jump blk_ret, v5
+`,
+		},
+		{
+			name: "memory_load_in_loop", m: testcases.MemoryLoadInLoop.Module,
+			exp: `
+blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32)
+	v4:i32 = Iconst_32 0x0
+	v5:i64 = Iconst_64 0x4
+	v6:i64 = UExtend v2, 32->64
+	v7:i64 = Uload32 module_ctx, 0x10
+	v8:i64 = Iadd v6, v5
+	v9:i32 = Icmp lt_u, v7, v8
+	ExitIfTrue v9, exec_ctx, memory_out_of_bounds
+	v10:i64 = Load module_ctx, 0x8
+	v11:i64 = Iadd v10, v6
+	v12:i32 = Load v11, 0x0
+	Jump blk1, v12, v2, v3
+
+blk1: (v13:i32,v14:i32,v24:i32) <-- (blk0,blk1)
+	v15:i64 = Iconst_64 0x4
+	v16:i64 = UExtend v14, 32->64
+	v17:i64 = Uload32 module_ctx, 0x10
+	v18:i64 = Iadd v16, v15
+	v19:i32 = Icmp lt_u, v17, v18
+	ExitIfTrue v19, exec_ctx, memory_out_of_bounds
+	v20:i64 = Load module_ctx, 0x8
+	v21:i64 = Iadd v20, v16
+	v22:i32 = Load v21, 0x0
+	v23:i32 = Iadd v13, v22
+	v25:i32 = Iconst_32 0x1
+	v26:i32 = Isub v24, v25
+	Brnz v26, blk1, v23, v14, v26
+	Jump blk3
+
+blk2: () <-- (blk3)
+	Jump blk_ret, v23
+
+blk3: () <-- (blk1)
+	Jump blk2
+`,
+			expAfterPasses: `
+blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32)
+	v5:i64 = Iconst_64 0x4
+	v6:i64 = UExtend v2, 32->64
+	v7:i64 = Uload32 module_ctx, 0x10
+	v8:i64 = Iadd v6, v5
+	v9:i32 = Icmp lt_u, v7, v8
+	ExitIfTrue v9, exec_ctx, memory_out_of_bounds
+	v10:i64 = Load module_ctx, 0x8
+	v11:i64 = Iadd v10, v6
+	v12:i32 = Load v11, 0x0
+	v16:i64 = UExtend v2, 32->64
+	v15:i64 = Iconst_64 0x4
+	v18:i64 = Iadd v16, v15
+	v19:i32 = Icmp lt_u, v7, v18
+	ExitIfTrue v19, exec_ctx, memory_out_of_bounds
+	Jump fallthrough, v12, v3
+
+blk1: (v13:i32,v24:i32) <-- (blk0,blk4)
+	v21:i64 = Iadd v10, v16
+	v22:i32 = Load v21, 0x0
+	v23:i32 = Iadd v13, v22
+	v25:i32 = Iconst_32 0x1
+	v26:i32 = Isub v24, v25
+	Brnz v26, blk4
+	Jump blk3
+
+blk4: () <-- (blk1)
+	Jump blk1, v23, v26
+
+blk3: () <-- (blk1)
+	Jump fallthrough
+
+blk2: () <-- (blk3)
+	Jump blk_ret, v23
 `,
 		},
 		{
@@ -1250,7 +1414,7 @@ blk0: (exec_ctx:i64, module_ctx:i64)
 			exp: `
 signatures:
 	sig0: i64i64_i32
-	sig2: i64i32_i32
+	sig2: i64i32i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64)
 	Store module_ctx, exec_ctx, 0x8
@@ -1268,29 +1432,30 @@ blk0: (exec_ctx:i64, module_ctx:i64)
 	v13:i32 = Iconst_32 0xa
 	Store module_ctx, exec_ctx, 0x8
 	v14:i64 = Load exec_ctx, 0x48
-	v15:i32 = CallIndirect v14:sig2, exec_ctx, v13
-	v16:i64 = Load module_ctx, 0x8
-	v17:i64 = Load v16, 0x0
-	v18:i64 = Load module_ctx, 0x8
-	v19:i64 = Load v18, 0x8
+	v15:i32 = Iconst_32 0x0
+	v16:i32 = CallIndirect v14:sig2, exec_ctx, v13, v15
+	v17:i64 = Load module_ctx, 0x8
+	v18:i64 = Load v17, 0x0
+	v19:i64 = Load module_ctx, 0x8
+	v20:i64 = Load v19, 0x8
 	Store module_ctx, exec_ctx, 0x8
-	v20:i64 = Load module_ctx, 0x18
-	v21:i64 = Load module_ctx, 0x20
-	v22:i32 = CallIndirect v20:sig0, exec_ctx, v21
-	v23:i64 = Load module_ctx, 0x8
-	v24:i64 = Load v23, 0x0
-	v25:i64 = Load module_ctx, 0x8
-	v26:i64 = Load v25, 0x8
-	v27:i64 = Load module_ctx, 0x8
-	v28:i32 = Load v27, 0x8
-	v29:i32 = Iconst_32 0x10
-	v30:i32 = Ushr v28, v29
-	Jump blk_ret, v4, v12, v22, v30
+	v21:i64 = Load module_ctx, 0x18
+	v22:i64 = Load module_ctx, 0x20
+	v23:i32 = CallIndirect v21:sig0, exec_ctx, v22
+	v24:i64 = Load module_ctx, 0x8
+	v25:i64 = Load v24, 0x0
+	v26:i64 = Load module_ctx, 0x8
+	v27:i64 = Load v26, 0x8
+	v28:i64 = Load module_ctx, 0x8
+	v29:i32 = Load v28, 0x8
+	v30:i32 = Iconst_32 0x10
+	v31:i32 = Ushr v29, v30
+	Jump blk_ret, v4, v12, v23, v31
 `,
 			expAfterPasses: `
 signatures:
 	sig0: i64i64_i32
-	sig2: i64i32_i32
+	sig2: i64i32i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64)
 	Store module_ctx, exec_ctx, 0x8
@@ -1304,16 +1469,17 @@ blk0: (exec_ctx:i64, module_ctx:i64)
 	v13:i32 = Iconst_32 0xa
 	Store module_ctx, exec_ctx, 0x8
 	v14:i64 = Load exec_ctx, 0x48
-	v15:i32 = CallIndirect v14:sig2, exec_ctx, v13
+	v15:i32 = Iconst_32 0x0
+	v16:i32 = CallIndirect v14:sig2, exec_ctx, v13, v15
 	Store module_ctx, exec_ctx, 0x8
-	v20:i64 = Load module_ctx, 0x18
-	v21:i64 = Load module_ctx, 0x20
-	v22:i32 = CallIndirect v20:sig0, exec_ctx, v21
-	v27:i64 = Load module_ctx, 0x8
-	v28:i32 = Load v27, 0x8
-	v29:i32 = Iconst_32 0x10
-	v30:i32 = Ushr v28, v29
-	Jump blk_ret, v4, v12, v22, v30
+	v21:i64 = Load module_ctx, 0x18
+	v22:i64 = Load module_ctx, 0x20
+	v23:i32 = CallIndirect v21:sig0, exec_ctx, v22
+	v28:i64 = Load module_ctx, 0x8
+	v29:i32 = Load v28, 0x8
+	v30:i32 = Iconst_32 0x10
+	v31:i32 = Ushr v29, v30
+	Jump blk_ret, v4, v12, v23, v31
 `,
 		},
 		{
@@ -1321,43 +1487,47 @@ blk0: (exec_ctx:i64, module_ctx:i64)
 			m:    testcases.MemorySizeGrow.Module,
 			exp: `
 signatures:
-	sig1: i64i32_i32
+	sig1: i64i32i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64)
 	v2:i32 = Iconst_32 0x1
 	Store module_ctx, exec_ctx, 0x8
 	v3:i64 = Load exec_ctx, 0x48
-	v4:i32 = CallIndirect v3:sig1, exec_ctx, v2
-	v5:i64 = Load module_ctx, 0x8
-	v6:i64 = Uload32 module_ctx, 0x10
-	v7:i32 = Load module_ctx, 0x10
-	v8:i32 = Iconst_32 0x10
-	v9:i32 = Ushr v7, v8
-	v10:i32 = Iconst_32 0x1
+	v4:i32 = Iconst_32 0x0
+	v5:i32 = CallIndirect v3:sig1, exec_ctx, v2, v4
+	v6:i64 = Load module_ctx, 0x8
+	v7:i64 = Uload32 module_ctx, 0x10
+	v8:i32 = Load module_ctx, 0x10
+	v9:i32 = Iconst_32 0x10
+	v10:i32 = Ushr v8, v9
+	v11:i32 = Iconst_32 0x1
 	Store module_ctx, exec_ctx, 0x8
-	v11:i64 = Load exec_ctx, 0x48
-	v12:i32 = CallIndirect v11:sig1, exec_ctx, v10
-	v13:i64 = Load module_ctx, 0x8
-	v14:i64 = Uload32 module_ctx, 0x10
-	Jump blk_ret, v4, v9, v12
+	v12:i64 = Load exec_ctx, 0x48
+	v13:i32 = Iconst_32 0x0
+	v14:i32 = CallIndirect v12:sig1, exec_ctx, v11, v13
+	v15:i64 = Load module_ctx, 0x8
+	v16:i64 = Uload32 module_ctx, 0x10
+	Jump blk_ret, v5, v10, v14
 `,
 			expAfterPasses: `
 signatures:
-	sig1: i64i32_i32
+	sig1: i64i32i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64)
 	v2:i32 = Iconst_32 0x1
 	Store module_ctx, exec_ctx, 0x8
 	v3:i64 = Load exec_ctx, 0x48
-	v4:i32 = CallIndirect v3:sig1, exec_ctx, v2
-	v7:i32 = Load module_ctx, 0x10
-	v8:i32 = Iconst_32 0x10
-	v9:i32 = Ushr v7, v8
-	v10:i32 = Iconst_32 0x1
+	v4:i32 = Iconst_32 0x0
+	v5:i32 = CallIndirect v3:sig1, exec_ctx, v2, v4
+	v8:i32 = Load module_ctx, 0x10
+	v9:i32 = Iconst_32 0x10
+	v10:i32 = Ushr v8, v9
+	v11:i32 = Iconst_32 0x1
 	Store module_ctx, exec_ctx, 0x8
-	v11:i64 = Load exec_ctx, 0x48
-	v12:i32 = CallIndirect v11:sig1, exec_ctx, v10
-	Jump blk_ret, v4, v9, v12
+	v12:i64 = Load exec_ctx, 0x48
+	v13:i32 = Iconst_32 0x0
+	v14:i32 = CallIndirect v12:sig1, exec_ctx, v11, v13
+	Jump blk_ret, v5, v10, v14
 `,
 		},
 		{
@@ -1619,7 +1789,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:v128, v3:v128)
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 signatures:
-	sig6: i64i64i32i64_i32
+	sig6: i64i64i32i64i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i64)
 	Store module_ctx, exec_ctx, 0x8
@@ -1641,8 +1811,9 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i64)
 	v19:i32 = Icmp neq, v17, v18
 	ExitIfTrue v19, exec_ctx, unaligned_atomic
 	v20:i64 = Load exec_ctx, 0x488
-	v21:i32 = CallIndirect v20:sig6, exec_ctx, v4, v3, v15
-	Jump blk_ret, v21
+	v21:i32 = Iconst_32 0x0
+	v22:i32 = CallIndirect v20:sig6, exec_ctx, v4, v3, v15, v21
+	Jump blk_ret, v22
 `,
 		},
 		{
@@ -1651,7 +1822,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32, v4:i64)
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 signatures:
-	sig7: i64i64i64i64_i32
+	sig7: i64i64i64i64i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i64, v4:i64)
 	Store module_ctx, exec_ctx, 0x8
@@ -1673,8 +1844,9 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i64, v4:i64)
 	v19:i32 = Icmp neq, v17, v18
 	ExitIfTrue v19, exec_ctx, unaligned_atomic
 	v20:i64 = Load exec_ctx, 0x490
-	v21:i32 = CallIndirect v20:sig7, exec_ctx, v4, v3, v15
-	Jump blk_ret, v21
+	v21:i32 = Iconst_32 0x0
+	v22:i32 = CallIndirect v20:sig7, exec_ctx, v4, v3, v15, v21
+	Jump blk_ret, v22
 `,
 		},
 		{
@@ -1683,7 +1855,7 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i64, v4:i64)
 			features: api.CoreFeaturesV2 | experimental.CoreFeaturesThreads,
 			exp: `
 signatures:
-	sig8: i64i32i64_i32
+	sig8: i64i32i64i32_i32
 
 blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32)
 	Store module_ctx, exec_ctx, 0x8
@@ -1705,8 +1877,9 @@ blk0: (exec_ctx:i64, module_ctx:i64, v2:i32, v3:i32)
 	v18:i32 = Icmp neq, v16, v17
 	ExitIfTrue v18, exec_ctx, unaligned_atomic
 	v19:i64 = Load exec_ctx, 0x498
-	v20:i32 = CallIndirect v19:sig8, exec_ctx, v3, v14
-	Jump blk_ret, v20
+	v20:i32 = Iconst_32 0x0
+	v21:i32 = CallIndirect v19:sig8, exec_ctx, v3, v14, v20
+	Jump blk_ret, v21
 `,
 		},
 		{
@@ -2850,7 +3023,7 @@ blk0: (exec_ctx:i64, module_ctx:i64)
 						wasm.OpcodeEnd,
 					},
 				}},
-				MemorySection: &wasm.Memory{Min: 1},
+				MemorySection: []wasm.Memory{{Min: 1}},
 			},
 			features: api.CoreFeaturesV2,
 			exp: `
@@ -2925,7 +3098,7 @@ blk3: () <-- (blk1,blk2)
 						wasm.OpcodeEnd,
 					},
 				}},
-				MemorySection: &wasm.Memory{Min: 1},
+				MemorySection: []wasm.Memory{{Min: 1}},
 			},
 			features: api.CoreFeaturesV2,
 			exp: `
@@ -3004,7 +3177,9 @@ blk9: () <-- (blk4)
 			b := ssa.NewBuilder()
 
 			offset := wazevoapi.NewModuleContextOffsetData(tc.m, tc.needListener)
-			fc := NewFrontendCompiler(tc.m, b, &offset, tc.ensureTermination, tc.needListener, false)
+			fc := NewFrontendCompiler(tc.m, b, &offset, tc.ensureTermination, tc.fuelMetering, tc.needListener, false).
+				WithInliningBudget(tc.inliningBudget).
+				WithMemoryGuardPages(tc.memoryGuardPages)
 			typeIndex := tc.m.FunctionSection[tc.targetIndex]
 			code := &tc.m.CodeSection[tc.targetIndex]
 			fc.Init(tc.targetIndex, typeIndex, &tc.m.TypeSection[typeIndex], code.LocalTypes, code.Body, tc.needListener, 0)
@@ -3087,14 +3262,14 @@ func TestCompiler_declareSignatures(t *testing.T) {
 			{ID: 1, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI32}},
 			{ID: 2, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeF64, ssa.TypeI32}},
 			{ID: 3, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI64, ssa.TypeI32}},
-			{ID: 4, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 4, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
 			{ID: 5, Params: []ssa.Type{ssa.TypeI64}},
 			{ID: 6, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
 			{ID: 7, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI64}},
 			{ID: 8, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64}},
-			{ID: 9, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
-			{ID: 10, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
-			{ID: 11, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 9, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 10, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 11, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
 			// EH signatures.
 			{ID: 12, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI64}},
 			{ID: 13, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64}},
@@ -3131,14 +3306,14 @@ func TestCompiler_declareSignatures(t *testing.T) {
 			{ID: 10, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32}},
 			{ID: 11, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32}},
 			// Misc.
-			{ID: 12, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 12, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
 			{ID: 13, Params: []ssa.Type{ssa.TypeI64}},
 			{ID: 14, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
 			{ID: 15, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI64}},
 			{ID: 16, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64}},
-			{ID: 17, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
-			{ID: 18, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
-			{ID: 19, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 17, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 18, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
+			{ID: 19, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI32}, Results: []ssa.Type{ssa.TypeI32}},
 			// EH signatures.
 			{ID: 20, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64}, Results: []ssa.Type{ssa.TypeI64}},
 			{ID: 21, Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64}},
@@ -3222,7 +3397,7 @@ func TestKnownSafeBound_valid(t *testing.T) {
 }
 
 func TestCompiler_finalizeKnownSafeBoundsAtTheEndOoBlock(t *testing.T) {
-	c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false)
+	c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false, false)
 	blk := c.ssaBuilder.AllocateBasicBlock()
 	require.True(t, len(c.getKnownSafeBoundsAtTheEndOfBlocks(blk.ID()).View()) == 0)
 	c.ssaBuilder.SetCurrentBlock(blk)
@@ -3243,7 +3418,7 @@ func TestCompiler_finalizeKnownSafeBoundsAtTheEndOoBlock(t *testing.T) {
 
 func TestCompiler_initializeCurrentBlockKnownBounds(t *testing.T) {
 	t.Run("single (sealed)", func(t *testing.T) {
-		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false)
+		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false, false)
 		builder := c.ssaBuilder
 		child := builder.AllocateBasicBlock()
 		{
@@ -3274,7 +3449,7 @@ func TestCompiler_initializeCurrentBlockKnownBounds(t *testing.T) {
 		require.Equal(t, ssa.Value(54321), kb.absoluteAddr)
 	})
 	t.Run("single (unsealed)", func(t *testing.T) {
-		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false)
+		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false, false)
 		builder := c.ssaBuilder
 		child := builder.AllocateBasicBlock()
 		{
@@ -3304,7 +3479,7 @@ func TestCompiler_initializeCurrentBlockKnownBounds(t *testing.T) {
 		require.NotEqual(t, ssa.Value(54321), kb.absoluteAddr)
 	})
 	t.Run("multiple predecessors", func(t *testing.T) {
-		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false)
+		c := NewFrontendCompiler(&wasm.Module{}, ssa.NewBuilder(), nil, false, false, false, false)
 		builder := c.ssaBuilder
 		child := builder.AllocateBasicBlock()
 		{
@@ -0,0 +1,183 @@
+package frontend
+
+import (
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// WithInliningBudget enables inlining of the direct calls to the module-local functions whose body is at most
+// `budget` bytes long. Zero disables inlining, which is the default.
+func (c *Compiler) WithInliningBudget(budget int) *Compiler {
+	c.inliningBudget = budget
+	return c
+}
+
+// inlinable returns true if the direct call to the function `fnIndex` can be replaced by its body.
+//
+// Only the straight-line functions are inlined: their bodies cannot branch, call, nor trap unconditionally, so
+// that they can be lowered in the middle of the current block without touching the control frames of the caller.
+// This is the case of getters, load and store wrappers, or the stack pointer helpers emitted by LLVM.
+//
+// Inlining is disabled when the listeners are enabled, as they must observe every function call.
+func (c *Compiler) inlinable(fnIndex wasm.Index) bool {
+	if c.inliningBudget <= 0 || c.listenerSignatures != nil || fnIndex < c.m.ImportFunctionCount {
+		return false
+	}
+	body := c.m.CodeSection[fnIndex-c.m.ImportFunctionCount].Body
+	if len(body) > c.inliningBudget {
+		return false
+	}
+
+	end := len(body) - 1
+	for pc := 0; pc < end; pc++ {
+		op := body[pc]
+		switch {
+		case op == wasm.OpcodeNop, op == wasm.OpcodeDrop, op == wasm.OpcodeSelect:
+		case op == wasm.OpcodeLocalGet, op == wasm.OpcodeLocalSet, op == wasm.OpcodeLocalTee,
+			op == wasm.OpcodeGlobalGet, op == wasm.OpcodeGlobalSet, op == wasm.OpcodeMemorySize:
+			_, n, err := leb128.LoadUint32(body[pc+1:])
+			if err != nil {
+				return false
+			}
+			pc += int(n)
+		case op == wasm.OpcodeI32Const:
+			_, n, err := leb128.LoadInt32(body[pc+1:])
+			if err != nil {
+				return false
+			}
+			pc += int(n)
+		case op == wasm.OpcodeI64Const:
+			_, n, err := leb128.LoadInt64(body[pc+1:])
+			if err != nil {
+				return false
+			}
+			pc += int(n)
+		case op == wasm.OpcodeF32Const:
+			pc += 4
+		case op == wasm.OpcodeF64Const:
+			pc += 8
+		case op >= wasm.OpcodeI32Load && op <= wasm.OpcodeI64Store32:
+			n, ok := c.memArgLen(body[pc+1:])
+			if !ok {
+				return false
+			}
+			pc += n
+		case op >= wasm.OpcodeI32Eqz && op <= wasm.OpcodeI64Extend32S:
+			// Numeric instructions without immediates.
+		default:
+			return false
+		}
+	}
+	return end >= 0 && body[end] == wasm.OpcodeEnd
+}
+
+// memArgLen returns the length of the memarg immediate at the beginning of `buf`, like readMemArg reads it.
+func (c *Compiler) memArgLen(buf []byte) (int, bool) {
+	align, n, err := leb128.LoadUint32(buf)
+	if err != nil {
+		return 0, false
+	}
+	length := int(n)
+
+	var memIdx uint32
+	if align&wasm.MemArgMemoryIndexFlag != 0 {
+		memIdx, n, err = leb128.LoadUint32(buf[length:])
+		if err != nil {
+			return 0, false
+		}
+		length += int(n)
+	}
+	if int(memIdx) >= len(c.memories64) {
+		return 0, false
+	}
+
+	if c.memories64[memIdx] {
+		_, n, err = leb128.LoadUint64(buf[length:])
+	} else {
+		_, n, err = leb128.LoadUint32(buf[length:])
+	}
+	if err != nil {
+		return 0, false
+	}
+	return length + int(n), true
+}
+
+// lowerInlinedCall lowers the body of the function `fnIndex` in place of the direct call to it at `callPC`.
+// inlinable(fnIndex) must be true.
+//
+// The arguments and the locals of the callee are bound to fresh variables, and its results are left on the stack
+// as if they were returned by the call. The source offsets of the inlined instructions are the ones in the callee
+// along with the one of the call, so that the traps raised by them are reported in both functions.
+func (c *Compiler) lowerInlinedCall(callPC int, fnIndex wasm.Index) {
+	builder := c.ssaBuilder
+	state := c.state()
+	localIndex := fnIndex - c.m.ImportFunctionCount
+	code := &c.m.CodeSection[localIndex]
+	typ := &c.m.TypeSection[c.m.FunctionSection[localIndex]]
+
+	callerLocals, callerBody, callerPC, callerTryTableDepth := c.wasmLocalToVariable, c.wasmFunctionBody, state.pc, c.tryTableDepth
+	callerBodyOffset := c.wasmFunctionBodyOffsetInCodeSection
+	c.wasmLocalToVariable = c.inlinedLocalToVariable[:0]
+
+	tail := len(state.values) - len(typ.Params)
+	for i, v := range state.values[tail:] {
+		variable := builder.DeclareVariable(WasmTypeToSSAType(typ.Params[i]))
+		builder.DefineVariableInCurrentBB(variable, v)
+		c.setWasmLocalVariable(wasm.Index(i), variable)
+	}
+	state.values = state.values[:tail]
+	for i, lt := range code.LocalTypes {
+		st := WasmTypeToSSAType(lt)
+		variable := builder.DeclareVariable(st)
+		builder.DefineVariableInCurrentBB(variable, c.insertZeroConst(st))
+		c.setWasmLocalVariable(wasm.Index(len(typ.Params)+i), variable)
+	}
+
+	// The locals of the callee are never read by the catch handlers of the caller.
+	c.tryTableDepth = 0
+	c.inlining = true
+	c.inlinedCallSite = ssa.SourceOffset(callPC) + ssa.SourceOffset(callerBodyOffset)
+	c.wasmFunctionBody, state.pc = code.Body, 0
+	c.wasmFunctionBodyOffsetInCodeSection = code.BodyOffsetInCodeSection
+	for end := len(code.Body) - 1; state.pc < end; {
+		// The instructions of the callee consume fuel whether it is inlined or not, including its last "end".
+		if c.fuelMetering {
+			c.chargeFuel(1)
+		}
+		c.lowerCurrentOpcode()
+	}
+	if c.fuelMetering {
+		c.chargeFuel(1)
+	}
+	c.inlining = false
+
+	c.inlinedLocalToVariable = c.wasmLocalToVariable
+	c.wasmLocalToVariable, c.wasmFunctionBody, state.pc, c.tryTableDepth = callerLocals, callerBody, callerPC, callerTryTableDepth
+	c.wasmFunctionBodyOffsetInCodeSection = callerBodyOffset
+	if c.needSourceOffsetInfo {
+		// The rest of the call belongs to the caller.
+		builder.SetCurrentSourceOffset(c.inlinedCallSite)
+	}
+}
+
+// insertZeroConst inserts the zero constant of the type `t` in the current block.
+func (c *Compiler) insertZeroConst(t ssa.Type) ssa.Value {
+	builder := c.ssaBuilder
+	zero := builder.AllocateInstruction()
+	switch t {
+	case ssa.TypeI32:
+		zero.AsIconst32(0)
+	case ssa.TypeI64:
+		zero.AsIconst64(0)
+	case ssa.TypeF32:
+		zero.AsF32const(0)
+	case ssa.TypeF64:
+		zero.AsF64const(0)
+	case ssa.TypeV128:
+		zero.AsVconst(0, 0)
+	default:
+		panic("TODO: " + t.String())
+	}
+	return zero.Insert(builder).Return()
+}
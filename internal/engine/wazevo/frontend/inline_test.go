@@ -0,0 +1,58 @@
+package frontend
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func TestCompiler_inlinable(t *testing.T) {
+	m := &wasm.Module{
+		TypeSection:         []wasm.FunctionType{{}, {Params: []wasm.ValueType{wasm.ValueTypeI32}, Results: []wasm.ValueType{wasm.ValueTypeI32}}},
+		ImportSection:       []wasm.Import{{Type: wasm.ExternTypeFunc, DescFunc: 1}},
+		ImportFunctionCount: 1,
+		MemorySection:       []wasm.Memory{{Min: 1}},
+		FunctionSection:     []wasm.Index{1, 1, 1, 1},
+		CodeSection: []wasm.Code{
+			// Load wrapper.
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Load, 0x2, 0x80, 0x1, wasm.OpcodeEnd}},
+			// Constants.
+			{Body: []byte{
+				wasm.OpcodeF64Const, 0, 0, 0, 0, 0, 0, 0, 0, wasm.OpcodeDrop,
+				wasm.OpcodeI64Const, 0x80, 0x1, wasm.OpcodeDrop,
+				wasm.OpcodeI32Const, 0x7f, wasm.OpcodeEnd,
+			}},
+			// Branches.
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeBrIf, 0, wasm.OpcodeEnd}},
+			// Calls.
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+		},
+	}
+
+	for _, tc := range []struct {
+		name       string
+		listenerOn bool
+		budget     int
+		fnIndex    wasm.Index
+		exp        bool
+	}{
+		{name: "load", budget: 100, fnIndex: 1, exp: true},
+		{name: "constants", budget: 100, fnIndex: 2, exp: true},
+		{name: "disabled", budget: 0, fnIndex: 1, exp: false},
+		{name: "over budget", budget: 6, fnIndex: 1, exp: false},
+		{name: "import", budget: 100, fnIndex: 0, exp: false},
+		{name: "branch", budget: 100, fnIndex: 3, exp: false},
+		{name: "call", budget: 100, fnIndex: 4, exp: false},
+		{name: "listener", listenerOn: true, budget: 100, fnIndex: 1, exp: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			offset := wazevoapi.NewModuleContextOffsetData(m, tc.listenerOn)
+			c := NewFrontendCompiler(m, ssa.NewBuilder(), &offset, false, false, tc.listenerOn, false).WithInliningBudget(tc.budget)
+			c.declareNecessaryVariables()
+			require.Equal(t, tc.exp, c.inlinable(tc.fnIndex))
+		})
+	}
+}
@@ -24,6 +24,12 @@ type (
 		unreachableDepth int
 		tmpForBrTable    []uint32
 		pc               int
+		// fuelCost is the constant fuel consumed on entry to the current block when fuel metering is enabled,
+		// which is incremented by chargeFuel as the instructions of the block are lowered.
+		fuelCost *ssa.Instruction
+		// inlinedCall is true if the last direct call was inlined, so that the instructions after it are in the
+		// same straight-line block.
+		inlinedCall bool
 	}
 	controlFrame struct {
 		kind controlFrameKind
@@ -107,6 +113,8 @@ func (l *loweringState) reset() {
 	l.pc = 0
 	l.unreachable = false
 	l.unreachableDepth = 0
+	l.fuelCost = nil
+	l.inlinedCall = false
 }
 
 func (l *loweringState) peek() (ret ssa.Value) {
@@ -161,6 +169,11 @@ func (c *Compiler) lowerBody(entryBlk ssa.BasicBlock) {
 		c.callListenerBefore()
 	}
 
+	// Each block consumes the fuel of its instructions on entry, starting with the one of the function.
+	if c.fuelMetering {
+		c.consumeFuel()
+	}
+
 	// Pushes the empty control frame which corresponds to the function return.
 	c.loweringState.ctrlPush(controlFrame{
 		kind:           controlFrameKindFunction,
@@ -170,6 +183,10 @@ func (c *Compiler) lowerBody(entryBlk ssa.BasicBlock) {
 
 	for c.loweringState.pc < len(c.wasmFunctionBody) {
 		blkBeforeLowering := c.ssaBuilder.CurrentBlock()
+		op := c.wasmFunctionBody[c.loweringState.pc]
+		if c.fuelMetering && !c.loweringState.unreachable {
+			c.chargeFuel(1)
+		}
 		c.lowerCurrentOpcode()
 		blkAfterLowering := c.ssaBuilder.CurrentBlock()
 		if blkBeforeLowering != blkAfterLowering {
@@ -179,6 +196,51 @@ func (c *Compiler) lowerBody(entryBlk ssa.BasicBlock) {
 			// After that, we initialize the known bounds for the new compilation target block.
 			c.initializeCurrentBlockKnownBounds()
 		}
+
+		if c.fuelMetering {
+			switch {
+			case c.loweringState.unreachable:
+				c.loweringState.fuelCost = nil
+			case blkBeforeLowering != blkAfterLowering:
+				c.consumeFuel()
+			case op == wasm.OpcodeCall && !c.loweringState.inlinedCall,
+				op == wasm.OpcodeCallIndirect, op == wasm.OpcodeCallRef:
+				// The fuel of the instructions after the call is consumed after it returns, so that Go functions
+				// see the fuel left by the instructions executed so far, as with the interpreter.
+				c.consumeFuel()
+			}
+		}
+	}
+}
+
+// consumeFuel inserts the instructions to consume the fuel held by the execution context for the instructions of
+// the current block, and to exit with wazevoapi.ExitCodeFuelExhausted if it becomes negative. The amount is zero
+// until chargeFuel is called for the instructions lowered next.
+func (c *Compiler) consumeFuel() {
+	builder := c.ssaBuilder
+	fuel := builder.AllocateInstruction().
+		AsLoad(c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetFuel.U32(), ssa.TypeI64).
+		Insert(builder).Return()
+	cost := builder.AllocateInstruction().AsIconst64(0).Insert(builder)
+	c.loweringState.fuelCost = cost
+	remaining := builder.AllocateInstruction().AsIsub(fuel, cost.Return()).Insert(builder).Return()
+	builder.AllocateInstruction().
+		AsStore(ssa.OpcodeStore, remaining, c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetFuel.U32()).
+		Insert(builder)
+
+	zero := builder.AllocateInstruction().AsIconst64(0).Insert(builder).Return()
+	exhausted := builder.AllocateInstruction().
+		AsIcmp(remaining, zero, ssa.IntegerCmpCondSignedLessThan).
+		Insert(builder).Return()
+	builder.AllocateInstruction().
+		AsExitIfTrueWithCode(c.execCtxPtrValue, exhausted, wazevoapi.ExitCodeFuelExhausted).
+		Insert(builder)
+}
+
+// chargeFuel adds n to the fuel consumed on entry to the current block, which is one per Wasm instruction.
+func (c *Compiler) chargeFuel(n uint64) {
+	if cost := c.loweringState.fuelCost; cost != nil {
+		cost.AsIconst64(cost.ConstantVal() + n)
 	}
 }
 
@@ -190,9 +252,11 @@ func (c *Compiler) lowerCurrentOpcode() {
 	op := c.wasmFunctionBody[c.loweringState.pc]
 
 	if c.needSourceOffsetInfo {
-		c.ssaBuilder.SetCurrentSourceOffset(
-			ssa.SourceOffset(c.loweringState.pc) + ssa.SourceOffset(c.wasmFunctionBodyOffsetInCodeSection),
-		)
+		offset := ssa.SourceOffset(c.loweringState.pc) + ssa.SourceOffset(c.wasmFunctionBodyOffsetInCodeSection)
+		if c.inlining {
+			offset = ssa.NewInlinedSourceOffset(c.inlinedCallSite, offset)
+		}
+		c.ssaBuilder.SetCurrentSourceOffset(offset)
 	}
 
 	builder := c.ssaBuilder
@@ -628,26 +692,29 @@ func (c *Compiler) lowerCurrentOpcode() {
 			c.callMemmove(dstAddr, srcAddr, copySizeInBytes)
 
 		case wasm.OpcodeMiscMemoryCopy:
-			state.pc += 2 // +2 to skip two memory indexes which are fixed to zero.
+			dstMemIdx := c.readI32u()
+			srcMemIdx := c.readI32u()
 			if state.unreachable {
 				break
 			}
 
-			copySize := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
-			srcOffset := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
-			dstOffset := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
+			// The size is i64 only if both memories are 64-bit.
+			copySize := state.pop()
+			if !c.memories64[dstMemIdx] || !c.memories64[srcMemIdx] {
+				copySize = builder.AllocateInstruction().AsUExtend(copySize, 32, 64).Insert(builder).Return()
+			}
+			srcOffset := c.memoryAddressToI64(srcMemIdx, state.pop())
+			dstOffset := c.memoryAddressToI64(dstMemIdx, state.pop())
 
 			// Out of bounds check.
-			memLen := c.getMemoryLenValue(false)
-			c.boundsCheckInMemory(memLen, dstOffset, copySize)
-			c.boundsCheckInMemory(memLen, srcOffset, copySize)
+			dstMemInstPtr, srcMemInstPtr := c.memoryInstancePtrFor(dstMemIdx), c.memoryInstancePtrFor(srcMemIdx)
+			c.boundsCheckInMemory(c.getMemoryLenValueAt(dstMemIdx, dstMemInstPtr), dstOffset, copySize, c.memories64[dstMemIdx])
+			c.boundsCheckInMemory(c.getMemoryLenValueAt(srcMemIdx, srcMemInstPtr), srcOffset, copySize, c.memories64[srcMemIdx])
 
-			memBase := c.getMemoryBaseValue(false)
-			dstAddr := builder.AllocateInstruction().AsIadd(memBase, dstOffset).Insert(builder).Return()
-			srcAddr := builder.AllocateInstruction().AsIadd(memBase, srcOffset).Insert(builder).Return()
+			dstAddr := builder.AllocateInstruction().
+				AsIadd(c.getMemoryBaseValueAt(dstMemIdx, dstMemInstPtr), dstOffset).Insert(builder).Return()
+			srcAddr := builder.AllocateInstruction().
+				AsIadd(c.getMemoryBaseValueAt(srcMemIdx, srcMemInstPtr), srcOffset).Insert(builder).Return()
 
 			c.callMemmove(dstAddr, srcAddr, copySize)
 
@@ -728,22 +795,21 @@ func (c *Compiler) lowerCurrentOpcode() {
 			builder.Seal(followingBlk)
 
 		case wasm.OpcodeMiscMemoryFill:
-			state.pc++ // Skip the memory index which is fixed to zero.
+			memIdx := c.readI32u()
 			if state.unreachable {
 				break
 			}
 
-			fillSize := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
+			fillSize := c.memoryAddressToI64(memIdx, state.pop())
 			value := state.pop()
-			offset := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
+			offset := c.memoryAddressToI64(memIdx, state.pop())
 
 			// Out of bounds check.
-			c.boundsCheckInMemory(c.getMemoryLenValue(false), offset, fillSize)
+			memInstPtr := c.memoryInstancePtrFor(memIdx)
+			c.boundsCheckInMemory(c.getMemoryLenValueAt(memIdx, memInstPtr), offset, fillSize, c.memories64[memIdx])
 
 			// Calculate the base address:
-			addr := builder.AllocateInstruction().AsIadd(c.getMemoryBaseValue(false), offset).Insert(builder).Return()
+			addr := builder.AllocateInstruction().AsIadd(c.getMemoryBaseValueAt(memIdx, memInstPtr), offset).Insert(builder).Return()
 
 			// Uses the copy trick for faster filling buffer, with a maximum chunk size of 8KB.
 			// https://github.com/golang/go/blob/go1.24.0/src/bytes/bytes.go#L664-L673
@@ -808,7 +874,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 
 		case wasm.OpcodeMiscMemoryInit:
 			index := c.readI32u()
-			state.pc++ // Skip the memory index which is fixed to zero.
+			memIdx := c.readI32u()
 			if state.unreachable {
 				break
 			}
@@ -817,19 +883,19 @@ func (c *Compiler) lowerCurrentOpcode() {
 				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
 			offsetInDataInstance := builder.
 				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
-			offsetInMemory := builder.
-				AllocateInstruction().AsUExtend(state.pop(), 32, 64).Insert(builder).Return()
+			offsetInMemory := c.memoryAddressToI64(memIdx, state.pop())
 
 			dataInstPtr := c.dataOrElementInstanceAddr(index, c.offset.DataInstances1stElement)
 
 			// Bounds check.
-			c.boundsCheckInMemory(c.getMemoryLenValue(false), offsetInMemory, copySize)
+			memInstPtr := c.memoryInstancePtrFor(memIdx)
+			c.boundsCheckInMemory(c.getMemoryLenValueAt(memIdx, memInstPtr), offsetInMemory, copySize, c.memories64[memIdx])
 			c.boundsCheckInDataOrElementInstance(dataInstPtr, offsetInDataInstance, copySize, wazevoapi.ExitCodeMemoryOutOfBounds)
 
 			dataInstBaseAddr := builder.AllocateInstruction().AsLoad(dataInstPtr, 0, ssa.TypeI64).Insert(builder).Return()
 			srcAddr := builder.AllocateInstruction().AsIadd(dataInstBaseAddr, offsetInDataInstance).Insert(builder).Return()
 
-			memBase := c.getMemoryBaseValue(false)
+			memBase := c.getMemoryBaseValueAt(memIdx, memInstPtr)
 			dstAddr := builder.AllocateInstruction().AsIadd(memBase, offsetInMemory).Insert(builder).Return()
 
 			c.callMemmove(dstAddr, srcAddr, copySize)
@@ -1139,31 +1205,46 @@ func (c *Compiler) lowerCurrentOpcode() {
 		state.push(sl)
 
 	case wasm.OpcodeMemorySize:
-		state.pc++ // skips the memory index.
+		memIdx := c.readI32u()
 		if state.unreachable {
 			break
 		}
 
+		// The size of 64-bit memories is i64, and the buffer length might not fit in 32 bits.
+		sizeType := ssa.TypeI32
+		if c.memories64[memIdx] {
+			sizeType = ssa.TypeI64
+		}
+
 		var memSizeInBytes ssa.Value
-		if c.offset.LocalMemoryBegin < 0 {
+		if memIdx != 0 {
+			memSizeInBytes = builder.AllocateInstruction().
+				AsLoad(c.getMemoryInstancePtr(memIdx), memoryInstanceBufSizeOffset, sizeType).
+				Insert(builder).
+				Return()
+		} else if c.offset.LocalMemoryBegin < 0 {
 			memInstPtr := builder.AllocateInstruction().
 				AsLoad(c.moduleCtxPtrValue, c.offset.ImportedMemoryBegin.U32(), ssa.TypeI64).
 				Insert(builder).
 				Return()
 
 			memSizeInBytes = builder.AllocateInstruction().
-				AsLoad(memInstPtr, memoryInstanceBufSizeOffset, ssa.TypeI32).
+				AsLoad(memInstPtr, memoryInstanceBufSizeOffset, sizeType).
 				Insert(builder).
 				Return()
 		} else {
 			memSizeInBytes = builder.AllocateInstruction().
-				AsLoad(c.moduleCtxPtrValue, c.offset.LocalMemoryLen().U32(), ssa.TypeI32).
+				AsLoad(c.moduleCtxPtrValue, c.offset.LocalMemoryLen().U32(), sizeType).
 				Insert(builder).
 				Return()
 		}
 
 		amount := builder.AllocateInstruction()
-		amount.AsIconst32(uint32(wasm.MemoryPageSizeInBits))
+		if sizeType == ssa.TypeI64 {
+			amount.AsIconst64(uint64(wasm.MemoryPageSizeInBits))
+		} else {
+			amount.AsIconst32(uint32(wasm.MemoryPageSizeInBits))
+		}
 		builder.InsertInstruction(amount)
 		memSize := builder.AllocateInstruction().
 			AsUshr(memSizeInBytes, amount.Return()).
@@ -1172,7 +1253,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 		state.push(memSize)
 
 	case wasm.OpcodeMemoryGrow:
-		state.pc++ // skips the memory index.
+		memIdx := c.readI32u()
 		if state.unreachable {
 			break
 		}
@@ -1180,17 +1261,33 @@ func (c *Compiler) lowerCurrentOpcode() {
 		c.storeCallerModuleContext()
 
 		pages := state.pop()
+		is64 := c.memories64[memIdx]
+		if is64 {
+			// The trampoline takes i32 pages, so saturate the i64 delta to 0xffffffff, which always fails to grow.
+			thirtyTwo := builder.AllocateInstruction().AsIconst64(32).Insert(builder).Return()
+			hi := builder.AllocateInstruction().AsUshr(pages, thirtyTwo).Insert(builder).Return()
+			zero := builder.AllocateInstruction().AsIconst64(0).Insert(builder).Return()
+			overflow := builder.AllocateInstruction().AsIcmp(hi, zero, ssa.IntegerCmpCondNotEqual).Insert(builder).Return()
+			maxU32 := builder.AllocateInstruction().AsIconst64(math.MaxUint32).Insert(builder).Return()
+			saturated := builder.AllocateInstruction().AsSelect(overflow, maxU32, pages).Insert(builder).Return()
+			pages = builder.AllocateInstruction().AsIreduce(saturated, ssa.TypeI32).Insert(builder).Return()
+		}
 		memoryGrowPtr := builder.AllocateInstruction().
 			AsLoad(c.execCtxPtrValue,
 				wazevoapi.ExecutionContextOffsetMemoryGrowTrampolineAddress.U32(),
 				ssa.TypeI64,
 			).Insert(builder).Return()
 
-		args := c.allocateVarLengthValues(2, c.execCtxPtrValue, pages)
+		memIdxConst := builder.AllocateInstruction().AsIconst32(memIdx).Insert(builder).Return()
+		args := c.allocateVarLengthValues(3, c.execCtxPtrValue, pages, memIdxConst)
 		callGrowRet := builder.
 			AllocateInstruction().
 			AsCallIndirect(memoryGrowPtr, &c.memoryGrowSig, args).
 			Insert(builder).Return()
+		if is64 {
+			// The result is either the previous pages (at most wasm.Memory64LimitPages) or -1 on failure.
+			callGrowRet = builder.AllocateInstruction().AsSExtend(callGrowRet, 32, 64).Insert(builder).Return()
+		}
 		state.push(callGrowRet)
 
 		// After the memory grow, reload the cached memory base and len.
@@ -1206,7 +1303,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 		wasm.OpcodeI64Store16,
 		wasm.OpcodeI64Store32:
 
-		_, offset := c.readMemArg()
+		memIdx, offset := c.readMemArg()
 		if state.unreachable {
 			break
 		}
@@ -1234,9 +1331,9 @@ func (c *Compiler) lowerCurrentOpcode() {
 
 		value := state.pop()
 		baseAddr := state.pop()
-		addr := c.memOpSetup(baseAddr, uint64(offset), opSize)
+		addr := c.memOpSetup(memIdx, baseAddr, offset, opSize)
 		builder.AllocateInstruction().
-			AsStore(opcode, value, addr, offset).
+			AsStore(opcode, value, addr, uint32(offset)).
 			Insert(builder)
 
 	case wasm.OpcodeI32Load,
@@ -1253,7 +1350,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 		wasm.OpcodeI64Load16U,
 		wasm.OpcodeI64Load32S,
 		wasm.OpcodeI64Load32U:
-		_, offset := c.readMemArg()
+		memIdx, offset := c.readMemArg()
 		if state.unreachable {
 			break
 		}
@@ -1279,41 +1376,41 @@ func (c *Compiler) lowerCurrentOpcode() {
 		}
 
 		baseAddr := state.pop()
-		addr := c.memOpSetup(baseAddr, uint64(offset), opSize)
+		addr := c.memOpSetup(memIdx, baseAddr, offset, opSize)
 		load := builder.AllocateInstruction()
 		switch op {
 		case wasm.OpcodeI32Load:
-			load.AsLoad(addr, offset, ssa.TypeI32)
+			load.AsLoad(addr, uint32(offset), ssa.TypeI32)
 		case wasm.OpcodeI64Load:
-			load.AsLoad(addr, offset, ssa.TypeI64)
+			load.AsLoad(addr, uint32(offset), ssa.TypeI64)
 		case wasm.OpcodeF32Load:
-			load.AsLoad(addr, offset, ssa.TypeF32)
+			load.AsLoad(addr, uint32(offset), ssa.TypeF32)
 		case wasm.OpcodeF64Load:
-			load.AsLoad(addr, offset, ssa.TypeF64)
+			load.AsLoad(addr, uint32(offset), ssa.TypeF64)
 		case wasm.OpcodeI32Load8S:
-			load.AsExtLoad(ssa.OpcodeSload8, addr, offset, false)
+			load.AsExtLoad(ssa.OpcodeSload8, addr, uint32(offset), false)
 		case wasm.OpcodeI32Load8U:
-			load.AsExtLoad(ssa.OpcodeUload8, addr, offset, false)
+			load.AsExtLoad(ssa.OpcodeUload8, addr, uint32(offset), false)
 		case wasm.OpcodeI32Load16S:
-			load.AsExtLoad(ssa.OpcodeSload16, addr, offset, false)
+			load.AsExtLoad(ssa.OpcodeSload16, addr, uint32(offset), false)
 		case wasm.OpcodeI32Load16U:
-			load.AsExtLoad(ssa.OpcodeUload16, addr, offset, false)
+			load.AsExtLoad(ssa.OpcodeUload16, addr, uint32(offset), false)
 		case wasm.OpcodeI64Load8S:
-			load.AsExtLoad(ssa.OpcodeSload8, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeSload8, addr, uint32(offset), true)
 		case wasm.OpcodeI64Load8U:
-			load.AsExtLoad(ssa.OpcodeUload8, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeUload8, addr, uint32(offset), true)
 		case wasm.OpcodeI64Load16S:
-			load.AsExtLoad(ssa.OpcodeSload16, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeSload16, addr, uint32(offset), true)
 		case wasm.OpcodeI64Load16U:
-			load.AsExtLoad(ssa.OpcodeUload16, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeUload16, addr, uint32(offset), true)
 		case wasm.OpcodeI64Load32S:
-			load.AsExtLoad(ssa.OpcodeSload32, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeSload32, addr, uint32(offset), true)
 		case wasm.OpcodeI64Load32U:
-			load.AsExtLoad(ssa.OpcodeUload32, addr, offset, true)
+			load.AsExtLoad(ssa.OpcodeUload32, addr, uint32(offset), true)
 		default:
 			panic("BUG")
 		}
-		builder.InsertInstruction(load)
+		builder.InsertInstruction(c.guardedLoad(memIdx, load))
 		state.push(load.Return())
 	case wasm.OpcodeBlock:
 		// Note: we do not need to create a BB for this as that would always have only one predecessor
@@ -1628,11 +1725,16 @@ func (c *Compiler) lowerCurrentOpcode() {
 		c.lowerCallIndirect(typeIndex, tableIndex)
 
 	case wasm.OpcodeCall:
+		callPC := state.pc
 		fnIndex := c.readI32u()
 		if state.unreachable {
 			break
 		}
-		c.lowerCall(fnIndex)
+		if state.inlinedCall = c.inlinable(fnIndex); state.inlinedCall {
+			c.lowerInlinedCall(callPC, fnIndex)
+		} else {
+			c.lowerCall(fnIndex)
+		}
 
 	case wasm.OpcodeDrop:
 		if state.unreachable {
@@ -1678,6 +1780,14 @@ func (c *Compiler) lowerCurrentOpcode() {
 
 	case wasm.OpcodeVecPrefix:
 		state.pc++
+		if relaxedOp, ok := wasm.RelaxedVectorOpcode(c.wasmFunctionBody[state.pc:]); ok {
+			// Relaxed SIMD opcodes are encoded in two bytes.
+			state.pc++
+			if !state.unreachable {
+				c.lowerRelaxedVectorInstruction(relaxedOp)
+			}
+			break
+		}
 		vecOp := c.wasmFunctionBody[state.pc]
 		switch vecOp {
 		case wasm.OpcodeVecV128Const:
@@ -1692,18 +1802,18 @@ func (c *Compiler) lowerCurrentOpcode() {
 			ret := builder.AllocateInstruction().AsVconst(lo, hi).Insert(builder).Return()
 			state.push(ret)
 		case wasm.OpcodeVecV128Load:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			if state.unreachable {
 				break
 			}
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), 16)
+			addr := c.memOpSetup(memIdx, baseAddr, offset, 16)
 			load := builder.AllocateInstruction()
-			load.AsLoad(addr, offset, ssa.TypeV128)
-			builder.InsertInstruction(load)
+			load.AsLoad(addr, uint32(offset), ssa.TypeV128)
+			builder.InsertInstruction(c.guardedLoad(memIdx, load))
 			state.push(load.Return())
 		case wasm.OpcodeVecV128Load8Lane, wasm.OpcodeVecV128Load16Lane, wasm.OpcodeVecV128Load32Lane:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			state.pc++
 			if state.unreachable {
 				break
@@ -1722,16 +1832,16 @@ func (c *Compiler) lowerCurrentOpcode() {
 			laneIndex := c.wasmFunctionBody[state.pc]
 			vector := state.pop()
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), opSize)
-			load := builder.AllocateInstruction().
-				AsExtLoad(loadOp, addr, offset, false).
+			addr := c.memOpSetup(memIdx, baseAddr, offset, opSize)
+			load := c.guardedLoad(memIdx, builder.AllocateInstruction().
+				AsExtLoad(loadOp, addr, uint32(offset), false)).
 				Insert(builder).Return()
 			ret := builder.AllocateInstruction().
 				AsInsertlane(vector, load, laneIndex, lane).
 				Insert(builder).Return()
 			state.push(ret)
 		case wasm.OpcodeVecV128Load64Lane:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			state.pc++
 			if state.unreachable {
 				break
@@ -1739,9 +1849,9 @@ func (c *Compiler) lowerCurrentOpcode() {
 			laneIndex := c.wasmFunctionBody[state.pc]
 			vector := state.pop()
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), 8)
-			load := builder.AllocateInstruction().
-				AsLoad(addr, offset, ssa.TypeI64).
+			addr := c.memOpSetup(memIdx, baseAddr, offset, 8)
+			load := c.guardedLoad(memIdx, builder.AllocateInstruction().
+				AsLoad(addr, uint32(offset), ssa.TypeI64)).
 				Insert(builder).Return()
 			ret := builder.AllocateInstruction().
 				AsInsertlane(vector, load, laneIndex, ssa.VecLaneI64x2).
@@ -1749,7 +1859,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 			state.push(ret)
 
 		case wasm.OpcodeVecV128Load32zero, wasm.OpcodeVecV128Load64zero:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -1763,17 +1873,17 @@ func (c *Compiler) lowerCurrentOpcode() {
 			}
 
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), uint64(scalarType.Size()))
+			addr := c.memOpSetup(memIdx, baseAddr, offset, uint64(scalarType.Size()))
 
-			ret := builder.AllocateInstruction().
-				AsVZeroExtLoad(addr, offset, scalarType).
+			ret := c.guardedLoad(memIdx, builder.AllocateInstruction().
+				AsVZeroExtLoad(addr, uint32(offset), scalarType)).
 				Insert(builder).Return()
 			state.push(ret)
 
 		case wasm.OpcodeVecV128Load8x8u, wasm.OpcodeVecV128Load8x8s,
 			wasm.OpcodeVecV128Load16x4u, wasm.OpcodeVecV128Load16x4s,
 			wasm.OpcodeVecV128Load32x2u, wasm.OpcodeVecV128Load32x2s:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -1797,9 +1907,9 @@ func (c *Compiler) lowerCurrentOpcode() {
 				lane = ssa.VecLaneI32x4
 			}
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), 8)
-			load := builder.AllocateInstruction().
-				AsLoad(addr, offset, ssa.TypeF64).
+			addr := c.memOpSetup(memIdx, baseAddr, offset, 8)
+			load := c.guardedLoad(memIdx, builder.AllocateInstruction().
+				AsLoad(addr, uint32(offset), ssa.TypeF64)).
 				Insert(builder).Return()
 			ret := builder.AllocateInstruction().
 				AsWiden(load, lane, signed, true).
@@ -1807,7 +1917,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 			state.push(ret)
 		case wasm.OpcodeVecV128Load8Splat, wasm.OpcodeVecV128Load16Splat,
 			wasm.OpcodeVecV128Load32Splat, wasm.OpcodeVecV128Load64Splat:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -1824,25 +1934,25 @@ func (c *Compiler) lowerCurrentOpcode() {
 				lane, opSize = ssa.VecLaneI64x2, 8
 			}
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), opSize)
-			ret := builder.AllocateInstruction().
-				AsLoadSplat(addr, offset, lane).
+			addr := c.memOpSetup(memIdx, baseAddr, offset, opSize)
+			ret := c.guardedLoad(memIdx, builder.AllocateInstruction().
+				AsLoadSplat(addr, uint32(offset), lane)).
 				Insert(builder).Return()
 			state.push(ret)
 		case wasm.OpcodeVecV128Store:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			if state.unreachable {
 				break
 			}
 			value := state.pop()
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), 16)
+			addr := c.memOpSetup(memIdx, baseAddr, offset, 16)
 			builder.AllocateInstruction().
-				AsStore(ssa.OpcodeStore, value, addr, offset).
+				AsStore(ssa.OpcodeStore, value, addr, uint32(offset)).
 				Insert(builder)
 		case wasm.OpcodeVecV128Store8Lane, wasm.OpcodeVecV128Store16Lane,
 			wasm.OpcodeVecV128Store32Lane, wasm.OpcodeVecV128Store64Lane:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			state.pc++
 			if state.unreachable {
 				break
@@ -1863,12 +1973,12 @@ func (c *Compiler) lowerCurrentOpcode() {
 			}
 			vector := state.pop()
 			baseAddr := state.pop()
-			addr := c.memOpSetup(baseAddr, uint64(offset), opSize)
+			addr := c.memOpSetup(memIdx, baseAddr, offset, opSize)
 			value := builder.AllocateInstruction().
 				AsExtractlane(vector, laneIndex, lane, false).
 				Insert(builder).Return()
 			builder.AllocateInstruction().
-				AsStore(storeOp, value, addr, offset).
+				AsStore(storeOp, value, addr, uint32(offset)).
 				Insert(builder)
 		case wasm.OpcodeVecV128Not:
 			if state.unreachable {
@@ -3134,7 +3244,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 		atomicOp := c.wasmFunctionBody[state.pc]
 		switch atomicOp {
 		case wasm.OpcodeAtomicMemoryWait32, wasm.OpcodeAtomicMemoryWait64:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3158,7 +3268,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 			timeout := state.pop()
 			exp := state.pop()
 			baseAddr := state.pop()
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), opSize)
+			addr := c.atomicMemOpSetup(memIdx, baseAddr, offset, opSize)
 
 			memoryWaitPtr := builder.AllocateInstruction().
 				AsLoad(c.execCtxPtrValue,
@@ -3166,13 +3276,14 @@ func (c *Compiler) lowerCurrentOpcode() {
 					ssa.TypeI64,
 				).Insert(builder).Return()
 
-			args := c.allocateVarLengthValues(4, c.execCtxPtrValue, timeout, exp, addr)
+			memIdxConst := builder.AllocateInstruction().AsIconst32(memIdx).Insert(builder).Return()
+			args := c.allocateVarLengthValues(5, c.execCtxPtrValue, timeout, exp, addr, memIdxConst)
 			memoryWaitRet := builder.AllocateInstruction().
 				AsCallIndirect(memoryWaitPtr, sig, args).
 				Insert(builder).Return()
 			state.push(memoryWaitRet)
 		case wasm.OpcodeAtomicMemoryNotify:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3180,20 +3291,21 @@ func (c *Compiler) lowerCurrentOpcode() {
 			c.storeCallerModuleContext()
 			count := state.pop()
 			baseAddr := state.pop()
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), 4)
+			addr := c.atomicMemOpSetup(memIdx, baseAddr, offset, 4)
 
 			memoryNotifyPtr := builder.AllocateInstruction().
 				AsLoad(c.execCtxPtrValue,
 					wazevoapi.ExecutionContextOffsetMemoryNotifyTrampolineAddress.U32(),
 					ssa.TypeI64,
 				).Insert(builder).Return()
-			args := c.allocateVarLengthValues(3, c.execCtxPtrValue, count, addr)
+			memIdxConst := builder.AllocateInstruction().AsIconst32(memIdx).Insert(builder).Return()
+			args := c.allocateVarLengthValues(4, c.execCtxPtrValue, count, addr, memIdxConst)
 			memoryNotifyRet := builder.AllocateInstruction().
 				AsCallIndirect(memoryNotifyPtr, &c.memoryNotifySig, args).
 				Insert(builder).Return()
 			state.push(memoryNotifyRet)
 		case wasm.OpcodeAtomicI32Load, wasm.OpcodeAtomicI64Load, wasm.OpcodeAtomicI32Load8U, wasm.OpcodeAtomicI32Load16U, wasm.OpcodeAtomicI64Load8U, wasm.OpcodeAtomicI64Load16U, wasm.OpcodeAtomicI64Load32U:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3220,11 +3332,11 @@ func (c *Compiler) lowerCurrentOpcode() {
 				typ = ssa.TypeI32
 			}
 
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), size)
+			addr := c.atomicMemOpSetup(memIdx, baseAddr, offset, size)
 			res := builder.AllocateInstruction().AsAtomicLoad(addr, size, typ).Insert(builder).Return()
 			state.push(res)
 		case wasm.OpcodeAtomicI32Store, wasm.OpcodeAtomicI64Store, wasm.OpcodeAtomicI32Store8, wasm.OpcodeAtomicI32Store16, wasm.OpcodeAtomicI64Store8, wasm.OpcodeAtomicI64Store16, wasm.OpcodeAtomicI64Store32:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3244,7 +3356,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 				size = 1
 			}
 
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), size)
+			addr := c.atomicMemOpSetup(memIdx, baseAddr, offset, size)
 			builder.AllocateInstruction().AsAtomicStore(addr, val, size).Insert(builder)
 		case wasm.OpcodeAtomicI32RmwAdd, wasm.OpcodeAtomicI64RmwAdd, wasm.OpcodeAtomicI32Rmw8AddU, wasm.OpcodeAtomicI32Rmw16AddU, wasm.OpcodeAtomicI64Rmw8AddU, wasm.OpcodeAtomicI64Rmw16AddU, wasm.OpcodeAtomicI64Rmw32AddU,
 			wasm.OpcodeAtomicI32RmwSub, wasm.OpcodeAtomicI64RmwSub, wasm.OpcodeAtomicI32Rmw8SubU, wasm.OpcodeAtomicI32Rmw16SubU, wasm.OpcodeAtomicI64Rmw8SubU, wasm.OpcodeAtomicI64Rmw16SubU, wasm.OpcodeAtomicI64Rmw32SubU,
@@ -3252,7 +3364,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 			wasm.OpcodeAtomicI32RmwOr, wasm.OpcodeAtomicI64RmwOr, wasm.OpcodeAtomicI32Rmw8OrU, wasm.OpcodeAtomicI32Rmw16OrU, wasm.OpcodeAtomicI64Rmw8OrU, wasm.OpcodeAtomicI64Rmw16OrU, wasm.OpcodeAtomicI64Rmw32OrU,
 			wasm.OpcodeAtomicI32RmwXor, wasm.OpcodeAtomicI64RmwXor, wasm.OpcodeAtomicI32Rmw8XorU, wasm.OpcodeAtomicI32Rmw16XorU, wasm.OpcodeAtomicI64Rmw8XorU, wasm.OpcodeAtomicI64Rmw16XorU, wasm.OpcodeAtomicI64Rmw32XorU,
 			wasm.OpcodeAtomicI32RmwXchg, wasm.OpcodeAtomicI64RmwXchg, wasm.OpcodeAtomicI32Rmw8XchgU, wasm.OpcodeAtomicI32Rmw16XchgU, wasm.OpcodeAtomicI64Rmw8XchgU, wasm.OpcodeAtomicI64Rmw16XchgU, wasm.OpcodeAtomicI64Rmw32XchgU:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3337,11 +3449,11 @@ func (c *Compiler) lowerCurrentOpcode() {
 				}
 			}
 
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), size)
+			addr := c.atomicMemOpSetup(memIdx, baseAddr, offset, size)
 			res := builder.AllocateInstruction().AsAtomicRmw(rmwOp, addr, val, size).Insert(builder).Return()
 			state.push(res)
 		case wasm.OpcodeAtomicI32RmwCmpxchg, wasm.OpcodeAtomicI64RmwCmpxchg, wasm.OpcodeAtomicI32Rmw8CmpxchgU, wasm.OpcodeAtomicI32Rmw16CmpxchgU, wasm.OpcodeAtomicI64Rmw8CmpxchgU, wasm.OpcodeAtomicI64Rmw16CmpxchgU, wasm.OpcodeAtomicI64Rmw32CmpxchgU:
-			_, offset := c.readMemArg()
+			memIdx, offset := c.readMemArg()
 			if state.unreachable {
 				break
 			}
@@ -3361,7 +3473,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 			case wasm.OpcodeAtomicI32Rmw8CmpxchgU, wasm.OpcodeAtomicI64Rmw8CmpxchgU:
 				size = 1
 			}
-			addr := c.atomicMemOpSetup(baseAddr, uint64(offset), size)
+			addr := c.atomicMemOpSetup(memIdx, baseAddr, offset, size)
 			res := builder.AllocateInstruction().AsAtomicCas(addr, exp, repl, size).Insert(builder).Return()
 			state.push(res)
 		case wasm.OpcodeAtomicFence:
@@ -3898,6 +4010,83 @@ func (c *Compiler) lowerExtMul(v1, v2 ssa.Value, from, to ssa.VecLane, signed, l
 	return builder.AllocateInstruction().AsVImul(v1lo, v2lo, to).Insert(builder).Return()
 }
 
+// lowerRelaxedVectorInstruction lowers the relaxed SIMD instruction relaxedOp. Except for madd/nmadd and the dot products,
+// they are lowered to their deterministic counterparts, which are valid relaxed results.
+func (c *Compiler) lowerRelaxedVectorInstruction(relaxedOp wasm.OpcodeVecRelaxed) {
+	builder := c.ssaBuilder
+	state := c.state()
+
+	var ret ssa.Value
+	switch relaxedOp {
+	case wasm.OpcodeVecI8x16RelaxedSwizzle:
+		v2 := state.pop()
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().AsSwizzle(v1, v2, ssa.VecLaneI8x16).Insert(builder).Return()
+	case wasm.OpcodeVecI32x4RelaxedTruncF32x4S, wasm.OpcodeVecI32x4RelaxedTruncF32x4U:
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().
+			AsVFcvtToIntSat(v1, ssa.VecLaneF32x4, relaxedOp == wasm.OpcodeVecI32x4RelaxedTruncF32x4S).Insert(builder).Return()
+	case wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero, wasm.OpcodeVecI32x4RelaxedTruncF64x2UZero:
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().
+			AsVFcvtToIntSat(v1, ssa.VecLaneF64x2, relaxedOp == wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero).Insert(builder).Return()
+	case wasm.OpcodeVecF32x4RelaxedMadd, wasm.OpcodeVecF32x4RelaxedNmadd,
+		wasm.OpcodeVecF64x2RelaxedMadd, wasm.OpcodeVecF64x2RelaxedNmadd:
+		lane := ssa.VecLaneF32x4
+		if relaxedOp == wasm.OpcodeVecF64x2RelaxedMadd || relaxedOp == wasm.OpcodeVecF64x2RelaxedNmadd {
+			lane = ssa.VecLaneF64x2
+		}
+		v3 := state.pop()
+		v2 := state.pop()
+		v1 := state.pop()
+		if relaxedOp == wasm.OpcodeVecF32x4RelaxedNmadd || relaxedOp == wasm.OpcodeVecF64x2RelaxedNmadd {
+			// -(x*y)+z == (-x)*y+z as the negation is exact.
+			v1 = builder.AllocateInstruction().AsVFneg(v1, lane).Insert(builder).Return()
+		}
+		ret = builder.AllocateInstruction().AsVFma(v1, v2, v3, lane).Insert(builder).Return()
+	case wasm.OpcodeVecI8x16RelaxedLaneselect, wasm.OpcodeVecI16x8RelaxedLaneselect,
+		wasm.OpcodeVecI32x4RelaxedLaneselect, wasm.OpcodeVecI64x2RelaxedLaneselect:
+		m := state.pop()
+		v2 := state.pop()
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().AsVbitselect(m, v1, v2).Insert(builder).Return()
+	case wasm.OpcodeVecF32x4RelaxedMin, wasm.OpcodeVecF64x2RelaxedMin:
+		lane := ssa.VecLaneF32x4
+		if relaxedOp == wasm.OpcodeVecF64x2RelaxedMin {
+			lane = ssa.VecLaneF64x2
+		}
+		v2 := state.pop()
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().AsVFmin(v1, v2, lane).Insert(builder).Return()
+	case wasm.OpcodeVecF32x4RelaxedMax, wasm.OpcodeVecF64x2RelaxedMax:
+		lane := ssa.VecLaneF32x4
+		if relaxedOp == wasm.OpcodeVecF64x2RelaxedMax {
+			lane = ssa.VecLaneF64x2
+		}
+		v2 := state.pop()
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().AsVFmax(v1, v2, lane).Insert(builder).Return()
+	case wasm.OpcodeVecI16x8RelaxedQ15mulrS:
+		v2 := state.pop()
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().AsSqmulRoundSat(v1, v2, ssa.VecLaneI16x8).Insert(builder).Return()
+	case wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S:
+		v2 := state.pop()
+		v1 := state.pop()
+		ret = builder.AllocateInstruction().AsVIdotI8x16I7x16S(v1, v2).Insert(builder).Return()
+	case wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS:
+		v3 := state.pop()
+		v2 := state.pop()
+		v1 := state.pop()
+		dot := builder.AllocateInstruction().AsVIdotI8x16I7x16S(v1, v2).Insert(builder).Return()
+		sum := builder.AllocateInstruction().AsExtIaddPairwise(dot, ssa.VecLaneI16x8, true).Insert(builder).Return()
+		ret = builder.AllocateInstruction().AsVIadd(sum, v3, ssa.VecLaneI32x4).Insert(builder).Return()
+	default:
+		panic("TODO: unsupported vector instruction: " + wasm.RelaxedVectorInstructionName(relaxedOp))
+	}
+	state.push(ret)
+}
+
 const (
 	tableInstanceBaseAddressOffset = 0
 	tableInstanceLenOffset         = tableInstanceBaseAddressOffset + 8
@@ -3968,6 +4157,10 @@ func (c *Compiler) prepareCall(fnIndex uint32) (isIndirect bool, sig *ssa.Signat
 			}
 		}
 	} else {
+		if c.sharedState {
+			// The callee might be interpreted, which is called via a Go function.
+			c.storeCallerModuleContext()
+		}
 		typIndex = c.m.FunctionSection[fnIndex-c.m.ImportFunctionCount]
 	}
 	typ := &c.m.TypeSection[typIndex]
@@ -3979,15 +4172,22 @@ func (c *Compiler) prepareCall(fnIndex uint32) (isIndirect bool, sig *ssa.Signat
 	args = c.allocateVarLengthValues(2+len(vs), c.execCtxPtrValue)
 
 	sig = c.signatures[typ]
-	if fnIndex >= c.m.ImportFunctionCount {
+	if fnIndex >= c.m.ImportFunctionCount && !c.sharedState {
 		args = args.Append(builder.VarLengthPool(), c.moduleCtxPtrValue) // This case the callee module is itself.
 		args = args.Append(builder.VarLengthPool(), vs...)
 		return false, sig, args, uint64(FunctionIndexToFuncRef(fnIndex))
 	} else {
 		// This case we have to read the address of the imported function from the module context.
 		moduleCtx := c.moduleCtxPtrValue
-		loadFuncPtr, loadModuleCtxPtr := builder.AllocateInstruction(), builder.AllocateInstruction()
 		funcPtrOffset, moduleCtxPtrOffset, _ := c.offset.ImportedFunctionOffset(fnIndex)
+		if c.sharedState {
+			// The module context holds the function reference instead of the functionInstance itself, which is
+			// also the case of the local functions, as they are compiled and swapped in one by one.
+			ref := builder.AllocateInstruction().AsLoad(moduleCtx, funcPtrOffset.U32(), ssa.TypeI64).Insert(builder).Return()
+			moduleCtx = c.loadSharedStateFunctionInstancePtr(ref)
+			funcPtrOffset, moduleCtxPtrOffset = wazevoapi.FunctionInstanceExecutableOffset, wazevoapi.FunctionInstanceModuleContextOpaquePtrOffset
+		}
+		loadFuncPtr, loadModuleCtxPtr := builder.AllocateInstruction(), builder.AllocateInstruction()
 		loadFuncPtr.AsLoad(moduleCtx, funcPtrOffset.U32(), ssa.TypeI64)
 		loadModuleCtxPtr.AsLoad(moduleCtx, moduleCtxPtrOffset.U32(), ssa.TypeI64)
 		builder.InsertInstruction(loadFuncPtr)
@@ -4045,6 +4245,7 @@ func (c *Compiler) prepareCallIndirect(typeIndex, tableIndex uint32) (ssa.Value,
 	exitIfNull := builder.AllocateInstruction()
 	exitIfNull.AsExitIfTrueWithCode(c.execCtxPtrValue, checkNull.Return(), wazevoapi.ExitCodeIndirectCallNullPointer)
 	builder.InsertInstruction(exitIfNull)
+	functionInstancePtr = c.loadSharedStateFunctionInstancePtr(functionInstancePtr)
 
 	// We need to do the type check. First, load the target function instance's typeID.
 	loadTypeID := builder.AllocateInstruction()
@@ -4095,6 +4296,17 @@ func (c *Compiler) prepareCallIndirect(typeIndex, tableIndex uint32) (ssa.Value,
 	return executablePtr, typ, args
 }
 
+// loadSharedStateFunctionInstancePtr returns the pointer to the functionInstance of the given function reference.
+// With the shared state, the reference points to a pointer to a pointer to the functionInstance. See WithSharedState.
+func (c *Compiler) loadSharedStateFunctionInstancePtr(ref ssa.Value) ssa.Value {
+	if !c.sharedState {
+		return ref
+	}
+	builder := c.ssaBuilder
+	entryPtr := builder.AllocateInstruction().AsLoad(ref, 0, ssa.TypeI64).Insert(builder).Return()
+	return builder.AllocateInstruction().AsLoad(entryPtr, 0, ssa.TypeI64).Insert(builder).Return()
+}
+
 func (c *Compiler) lowerCallIndirect(typeIndex, tableIndex uint32) {
 	builder := c.ssaBuilder
 	state := c.state()
@@ -4187,6 +4399,7 @@ func (c *Compiler) prepareCallRef(typeIndex uint32) (ssa.Value, *wasm.FunctionTy
 	exitIfNull := builder.AllocateInstruction()
 	exitIfNull.AsExitIfTrueWithCode(c.execCtxPtrValue, checkNull.Return(), wazevoapi.ExitCodeNullReference)
 	builder.InsertInstruction(exitIfNull)
+	functionInstancePtr = c.loadSharedStateFunctionInstancePtr(functionInstancePtr)
 
 	// Load the executable and moduleContextOpaquePtr from the function instance.
 	loadExecutablePtr := builder.AllocateInstruction()
@@ -4256,13 +4469,73 @@ func (c *Compiler) lowerTailCallReturnCallRef(typeIndex uint32) {
 	c.lowerReturn(builder)
 }
 
-// memOpSetup inserts the bounds check and calculates the address of the memory operation (loads/stores).
-func (c *Compiler) memOpSetup(baseAddr ssa.Value, constOffset, operationSizeInBytes uint64) (address ssa.Value) {
+// memOpSetup inserts the bounds check if necessary and calculates the address of the memory operation (loads/stores).
+//
+// The returned address doesn't include the lower 32 bits of constOffset, which are expected to be
+// encoded as the immediate offset of the memory operation.
+func (c *Compiler) memOpSetup(memIdx wasm.Index, baseAddr ssa.Value, constOffset, operationSizeInBytes uint64) (address ssa.Value) {
+	if c.memoryGuardPages && !c.memories64[memIdx] {
+		// Any 32-bit address plus any 32-bit constOffset falls within the reserved address space of the memory,
+		// so the out of bounds accesses fault on the guard pages.
+		builder := c.ssaBuilder
+		var memBase ssa.Value
+		if memIdx == 0 {
+			memBase = c.getMemoryBaseValue(false)
+		} else {
+			memBase = c.getMemoryBaseValueAt(memIdx, c.memoryInstancePtrFor(memIdx))
+		}
+		extBaseAddr := c.memoryAddressToI64(memIdx, baseAddr)
+		return builder.AllocateInstruction().AsIadd(memBase, extBaseAddr).Insert(builder).Return()
+	}
+	return c.boundsCheckedMemOpSetup(memIdx, baseAddr, constOffset, operationSizeInBytes)
+}
+
+// guardedLoad marks the load of the memory at memIdx as trapping if memOpSetup elided its bounds check, so that
+// it is not eliminated even if its result is unused. This must be called before the load is inserted.
+func (c *Compiler) guardedLoad(memIdx wasm.Index, load *ssa.Instruction) *ssa.Instruction {
+	if c.memoryGuardPages && !c.memories64[memIdx] {
+		load.MarkMayTrap()
+	}
+	return load
+}
+
+// boundsCheckedMemOpSetup is the same as memOpSetup, but always inserts the bounds check.
+func (c *Compiler) boundsCheckedMemOpSetup(memIdx wasm.Index, baseAddr ssa.Value, constOffset, operationSizeInBytes uint64) (address ssa.Value) {
 	address = ssa.ValueInvalid
 	builder := c.ssaBuilder
 
-	baseAddrID := baseAddr.ID()
+	is64 := c.memories64[memIdx]
+	if memIdx != 0 || constOffset > math.MaxUint32 {
+		// Memories other than the first one are not cached, so the known safe bounds are not used.
+		// The same goes for offsets that only exist for 64-bit memories, which cannot be the load/store immediates.
+		extBaseAddr := c.memoryAddressToI64(memIdx, baseAddr)
+		ceil := constOffset + operationSizeInBytes
+		if constOffset > memory64MaxConstOffset {
+			// This is always out of bounds, but avoid overflowing the ceil.
+			ceil = memory64MaxConstOffset
+		}
+		ceilConst := builder.AllocateInstruction().AsIconst64(ceil).Insert(builder).Return()
+		baseAddrPlusCeil := builder.AllocateInstruction().AsIadd(extBaseAddr, ceilConst).Insert(builder).Return()
+		if is64 {
+			c.memoryAddressOverflowCheck(baseAddrPlusCeil, ceilConst)
+		}
+		memInstPtr := c.memoryInstancePtrFor(memIdx)
+		cmp := builder.AllocateInstruction().
+			AsIcmp(c.getMemoryLenValueAt(memIdx, memInstPtr), baseAddrPlusCeil, ssa.IntegerCmpCondUnsignedLessThan).
+			Insert(builder).
+			Return()
+		builder.AllocateInstruction().AsExitIfTrueWithCode(c.execCtxPtrValue, cmp, wazevoapi.ExitCodeMemoryOutOfBounds).Insert(builder)
+		address = builder.AllocateInstruction().
+			AsIadd(c.getMemoryBaseValueAt(memIdx, memInstPtr), extBaseAddr).Insert(builder).Return()
+		if hi := constOffset &^ math.MaxUint32; hi != 0 {
+			hiConst := builder.AllocateInstruction().AsIconst64(hi).Insert(builder).Return()
+			address = builder.AllocateInstruction().AsIadd(address, hiConst).Insert(builder).Return()
+		}
+		return
+	}
+
 	ceil := constOffset + operationSizeInBytes
+	baseAddrID := baseAddr.ID()
 	if known := c.getKnownSafeBound(baseAddrID); known.valid() {
 		// We reuse the calculated absolute address even if the bound is not known to be safe.
 		address = known.absoluteAddr
@@ -4271,10 +4544,7 @@ func (c *Compiler) memOpSetup(baseAddr ssa.Value, constOffset, operationSizeInBy
 				// This means that, the bound is known to be safe, but the memory base might have changed.
 				// So, we re-calculate the address.
 				memBase := c.getMemoryBaseValue(false)
-				extBaseAddr := builder.AllocateInstruction().
-					AsUExtend(baseAddr, 32, 64).
-					Insert(builder).
-					Return()
+				extBaseAddr := c.memoryAddressToI64(memIdx, baseAddr)
 				address = builder.AllocateInstruction().
 					AsIadd(memBase, extBaseAddr).Insert(builder).Return()
 				known.absoluteAddr = address // Update the absolute address for the subsequent memory access.
@@ -4288,10 +4558,7 @@ func (c *Compiler) memOpSetup(baseAddr ssa.Value, constOffset, operationSizeInBy
 	builder.InsertInstruction(ceilConst)
 
 	// We calculate the offset in 64-bit space.
-	extBaseAddr := builder.AllocateInstruction().
-		AsUExtend(baseAddr, 32, 64).
-		Insert(builder).
-		Return()
+	extBaseAddr := c.memoryAddressToI64(memIdx, baseAddr)
 
 	// Note: memLen is already zero extended to 64-bit space at the load time.
 	memLen := c.getMemoryLenValue(false)
@@ -4301,6 +4568,10 @@ func (c *Compiler) memOpSetup(baseAddr ssa.Value, constOffset, operationSizeInBy
 	baseAddrPlusCeil.AsIadd(extBaseAddr, ceilConst.Return())
 	builder.InsertInstruction(baseAddrPlusCeil)
 
+	if is64 {
+		c.memoryAddressOverflowCheck(baseAddrPlusCeil.Return(), ceilConst.Return())
+	}
+
 	// Check for out of bounds memory access: `memLen >= baseAddrPlusCeil`.
 	cmp := builder.AllocateInstruction()
 	cmp.AsIcmp(memLen, baseAddrPlusCeil.Return(), ssa.IntegerCmpCondUnsignedLessThan)
@@ -4321,17 +4592,45 @@ func (c *Compiler) memOpSetup(baseAddr ssa.Value, constOffset, operationSizeInBy
 	return
 }
 
+// memory64MaxConstOffset is larger than any 64-bit memory can be (see wasm.Memory64LimitPages), so a constant offset
+// above this is always out of bounds.
+const memory64MaxConstOffset = 1 << 48
+
+// memoryAddressToI64 returns the address operand of the memory at memIdx as a 64-bit value. Addresses of 32-bit
+// memories are zero-extended, and those of 64-bit memories are already 64-bit.
+func (c *Compiler) memoryAddressToI64(memIdx wasm.Index, addr ssa.Value) ssa.Value {
+	if c.memories64[memIdx] {
+		return addr
+	}
+	builder := c.ssaBuilder
+	return builder.AllocateInstruction().AsUExtend(addr, 32, 64).Insert(builder).Return()
+}
+
+// memoryAddressOverflowCheck exits with wazevoapi.ExitCodeMemoryOutOfBounds if sum = x + y overflowed, which
+// can only happen for the i64 addresses of 64-bit memories.
+func (c *Compiler) memoryAddressOverflowCheck(sum, y ssa.Value) {
+	builder := c.ssaBuilder
+	overflow := builder.AllocateInstruction().
+		AsIcmp(sum, y, ssa.IntegerCmpCondUnsignedLessThan).
+		Insert(builder).
+		Return()
+	builder.AllocateInstruction().AsExitIfTrueWithCode(c.execCtxPtrValue, overflow, wazevoapi.ExitCodeMemoryOutOfBounds).Insert(builder)
+}
+
 // atomicMemOpSetup inserts the bounds check and calculates the address of the memory operation (loads/stores), including
 // the constant offset and performs an alignment check on the final address.
-func (c *Compiler) atomicMemOpSetup(baseAddr ssa.Value, constOffset, operationSizeInBytes uint64) (address ssa.Value) {
+func (c *Compiler) atomicMemOpSetup(memIdx wasm.Index, baseAddr ssa.Value, constOffset, operationSizeInBytes uint64) (address ssa.Value) {
 	builder := c.ssaBuilder
 
-	addrWithoutOffset := c.memOpSetup(baseAddr, constOffset, operationSizeInBytes)
+	// The bounds are checked explicitly even with the guard pages so that the out of bounds accesses are
+	// reported before the misaligned ones.
+	addrWithoutOffset := c.boundsCheckedMemOpSetup(memIdx, baseAddr, constOffset, operationSizeInBytes)
 	var addr ssa.Value
-	if constOffset == 0 {
+	// memOpSetup already includes the upper 32 bits of constOffset, if any.
+	if lo := constOffset & math.MaxUint32; lo == 0 {
 		addr = addrWithoutOffset
 	} else {
-		offset := builder.AllocateInstruction().AsIconst64(constOffset).Insert(builder).Return()
+		offset := builder.AllocateInstruction().AsIconst64(lo).Insert(builder).Return()
 		addr = builder.AllocateInstruction().AsIadd(addrWithoutOffset, offset).Insert(builder).Return()
 	}
 
@@ -4409,7 +4708,7 @@ func (c *Compiler) setWasmGlobalValue(index wasm.Index, v ssa.Value) {
 	opaqueOffset := c.offset.GlobalInstanceOffset(index)
 
 	builder := c.ssaBuilder
-	if index < c.m.ImportGlobalCount {
+	if index < c.m.ImportGlobalCount || c.sharedState {
 		loadGlobalInstPtr := builder.AllocateInstruction()
 		loadGlobalInstPtr.AsLoad(c.moduleCtxPtrValue, uint32(opaqueOffset), ssa.TypeI64)
 		builder.InsertInstruction(loadGlobalInstPtr)
@@ -4441,7 +4740,7 @@ func (c *Compiler) getWasmGlobalValue(index wasm.Index, forceLoad bool) ssa.Valu
 	}
 
 	var load *ssa.Instruction
-	if index < c.m.ImportGlobalCount {
+	if index < c.m.ImportGlobalCount || c.sharedState {
 		loadGlobalInstPtr := builder.AllocateInstruction()
 		loadGlobalInstPtr.AsLoad(c.moduleCtxPtrValue, uint32(opaqueOffset), ssa.TypeI64)
 		builder.InsertInstruction(loadGlobalInstPtr)
@@ -4474,17 +4773,17 @@ func (c *Compiler) getMemoryBaseValue(forceReload bool) ssa.Value {
 	var ret ssa.Value
 	if c.offset.LocalMemoryBegin < 0 {
 		loadMemInstPtr := builder.AllocateInstruction()
-		loadMemInstPtr.AsLoad(c.moduleCtxPtrValue, c.offset.ImportedMemoryBegin.U32(), ssa.TypeI64)
+		loadMemInstPtr.AsLoad(c.moduleCtxPtrValue, c.offset.ImportedMemoryBegin.U32(), ssa.TypeI64).MarkInvariantUntilCall()
 		builder.InsertInstruction(loadMemInstPtr)
 		memInstPtr := loadMemInstPtr.Return()
 
 		loadBufPtr := builder.AllocateInstruction()
-		loadBufPtr.AsLoad(memInstPtr, memoryInstanceBufOffset, ssa.TypeI64)
+		loadBufPtr.AsLoad(memInstPtr, memoryInstanceBufOffset, ssa.TypeI64).MarkInvariantUntilCall()
 		builder.InsertInstruction(loadBufPtr)
 		ret = loadBufPtr.Return()
 	} else {
 		load := builder.AllocateInstruction()
-		load.AsLoad(c.moduleCtxPtrValue, c.offset.LocalMemoryBase().U32(), ssa.TypeI64)
+		load.AsLoad(c.moduleCtxPtrValue, c.offset.LocalMemoryBase().U32(), ssa.TypeI64).MarkInvariantUntilCall()
 		builder.InsertInstruction(load)
 		ret = load.Return()
 	}
@@ -4505,7 +4804,7 @@ func (c *Compiler) getMemoryLenValue(forceReload bool) ssa.Value {
 	var ret ssa.Value
 	if c.offset.LocalMemoryBegin < 0 {
 		loadMemInstPtr := builder.AllocateInstruction()
-		loadMemInstPtr.AsLoad(c.moduleCtxPtrValue, c.offset.ImportedMemoryBegin.U32(), ssa.TypeI64)
+		loadMemInstPtr.AsLoad(c.moduleCtxPtrValue, c.offset.ImportedMemoryBegin.U32(), ssa.TypeI64).MarkInvariantUntilCall()
 		builder.InsertInstruction(loadMemInstPtr)
 		memInstPtr := loadMemInstPtr.Return()
 
@@ -4515,7 +4814,7 @@ func (c *Compiler) getMemoryLenValue(forceReload bool) ssa.Value {
 			addr := builder.AllocateInstruction().AsIadd(memInstPtr, sizeOffset).Insert(builder).Return()
 			loadBufSizePtr.AsAtomicLoad(addr, 8, ssa.TypeI64)
 		} else {
-			loadBufSizePtr.AsLoad(memInstPtr, memoryInstanceBufSizeOffset, ssa.TypeI64)
+			loadBufSizePtr.AsLoad(memInstPtr, memoryInstanceBufSizeOffset, ssa.TypeI64).MarkInvariantUntilCall()
 		}
 		builder.InsertInstruction(loadBufSizePtr)
 
@@ -4526,8 +4825,11 @@ func (c *Compiler) getMemoryLenValue(forceReload bool) ssa.Value {
 			lenOffset := builder.AllocateInstruction().AsIconst64(c.offset.LocalMemoryLen().U64()).Insert(builder).Return()
 			addr := builder.AllocateInstruction().AsIadd(c.moduleCtxPtrValue, lenOffset).Insert(builder).Return()
 			load.AsAtomicLoad(addr, 8, ssa.TypeI64)
+		} else if c.memories64[0] {
+			// 64-bit memories can be larger than 4GiB.
+			load.AsLoad(c.moduleCtxPtrValue, c.offset.LocalMemoryLen().U32(), ssa.TypeI64).MarkInvariantUntilCall()
 		} else {
-			load.AsExtLoad(ssa.OpcodeUload32, c.moduleCtxPtrValue, c.offset.LocalMemoryLen().U32(), true)
+			load.AsExtLoad(ssa.OpcodeUload32, c.moduleCtxPtrValue, c.offset.LocalMemoryLen().U32(), true).MarkInvariantUntilCall()
 		}
 		builder.InsertInstruction(load)
 		ret = load.Return()
@@ -4537,6 +4839,57 @@ func (c *Compiler) getMemoryLenValue(forceReload bool) ssa.Value {
 	return ret
 }
 
+// getMemoryInstancePtr returns the pointer to the *wasm.MemoryInstance of the given memory index.
+func (c *Compiler) getMemoryInstancePtr(memIdx wasm.Index) ssa.Value {
+	builder := c.ssaBuilder
+	return builder.AllocateInstruction().
+		AsLoad(c.moduleCtxPtrValue, c.offset.MemoryInstanceOffset(int(memIdx)).U32(), ssa.TypeI64).
+		MarkInvariantUntilCall().
+		Insert(builder).
+		Return()
+}
+
+// memoryInstancePtrFor returns getMemoryInstancePtr for a non-zero memIdx, or ssa.ValueInvalid as the first memory
+// is accessed via the cached variables.
+func (c *Compiler) memoryInstancePtrFor(memIdx wasm.Index) ssa.Value {
+	if memIdx == 0 {
+		return ssa.ValueInvalid
+	}
+	return c.getMemoryInstancePtr(memIdx)
+}
+
+// getMemoryBaseValueAt returns the buffer base of the memory at memIdx. memInstPtr must be the result of
+// memoryInstancePtrFor(memIdx).
+func (c *Compiler) getMemoryBaseValueAt(memIdx wasm.Index, memInstPtr ssa.Value) ssa.Value {
+	if memIdx == 0 {
+		return c.getMemoryBaseValue(false)
+	}
+	builder := c.ssaBuilder
+	return builder.AllocateInstruction().
+		AsLoad(memInstPtr, memoryInstanceBufOffset, ssa.TypeI64).
+		MarkInvariantUntilCall().
+		Insert(builder).
+		Return()
+}
+
+// getMemoryLenValueAt returns the buffer length of the memory at memIdx. memInstPtr must be the result of
+// memoryInstancePtrFor(memIdx).
+func (c *Compiler) getMemoryLenValueAt(memIdx wasm.Index, memInstPtr ssa.Value) ssa.Value {
+	if memIdx == 0 {
+		return c.getMemoryLenValue(false)
+	}
+	builder := c.ssaBuilder
+	load := builder.AllocateInstruction()
+	if c.memoriesShared[memIdx] {
+		sizeOffset := builder.AllocateInstruction().AsIconst64(memoryInstanceBufSizeOffset).Insert(builder).Return()
+		addr := builder.AllocateInstruction().AsIadd(memInstPtr, sizeOffset).Insert(builder).Return()
+		load.AsAtomicLoad(addr, 8, ssa.TypeI64)
+	} else {
+		load.AsLoad(memInstPtr, memoryInstanceBufSizeOffset, ssa.TypeI64).MarkInvariantUntilCall()
+	}
+	return load.Insert(builder).Return()
+}
+
 func (c *Compiler) insertIcmp(cond ssa.IntegerCmpCond) {
 	state, builder := c.state(), c.ssaBuilder
 	y, x := state.pop(), state.pop()
@@ -4877,22 +5230,38 @@ func (c *Compiler) readBlockType() *wasm.FunctionType {
 	return bt
 }
 
-func (c *Compiler) readMemArg() (align, offset uint32) {
+// readMemArg reads the memarg immediate and returns the memory index and the constant offset. The alignment is
+// discarded as it is only a hint. The offset is 64-bit for 64-bit memories.
+func (c *Compiler) readMemArg() (memIdx wasm.Index, offset uint64) {
 	state := c.state()
 
 	align, num, err := leb128.LoadUint32(c.wasmFunctionBody[state.pc+1:])
 	if err != nil {
 		panic(fmt.Errorf("read memory align: %v", err))
 	}
-
 	state.pc += int(num)
-	offset, num, err = leb128.LoadUint32(c.wasmFunctionBody[state.pc+1:])
+
+	if align&wasm.MemArgMemoryIndexFlag != 0 {
+		memIdx, num, err = leb128.LoadUint32(c.wasmFunctionBody[state.pc+1:])
+		if err != nil {
+			panic(fmt.Errorf("read memory index: %v", err))
+		}
+		state.pc += int(num)
+	}
+
+	if c.memories64[memIdx] {
+		offset, num, err = leb128.LoadUint64(c.wasmFunctionBody[state.pc+1:])
+	} else {
+		var offset32 uint32
+		offset32, num, err = leb128.LoadUint32(c.wasmFunctionBody[state.pc+1:])
+		offset = uint64(offset32)
+	}
 	if err != nil {
 		panic(fmt.Errorf("read memory offset: %v", err))
 	}
 
 	state.pc += int(num)
-	return align, offset
+	return memIdx, offset
 }
 
 // insertJumpToBlock inserts a jump instruction to the given block in the current block.
@@ -5140,9 +5509,14 @@ func (c *Compiler) loadTableBaseAddr(tableInstancePtr ssa.Value) ssa.Value {
 	return loadTableBaseAddress.Return()
 }
 
-func (c *Compiler) boundsCheckInMemory(memLen, offset, size ssa.Value) {
+// boundsCheckInMemory exits with wazevoapi.ExitCodeMemoryOutOfBounds unless [offset, offset+size) is within memLen.
+// is64 must be true for 64-bit memories, where offset+size may overflow.
+func (c *Compiler) boundsCheckInMemory(memLen, offset, size ssa.Value, is64 bool) {
 	builder := c.ssaBuilder
 	ceil := builder.AllocateInstruction().AsIadd(offset, size).Insert(builder).Return()
+	if is64 {
+		c.memoryAddressOverflowCheck(ceil, size)
+	}
 	cmp := builder.AllocateInstruction().
 		AsIcmp(memLen, ceil, ssa.IntegerCmpCondUnsignedLessThan).
 		Insert(builder).
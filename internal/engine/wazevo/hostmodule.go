@@ -6,12 +6,41 @@ import (
 	"unsafe"
 
 	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
-func buildHostModuleOpaque(m *wasm.Module, listeners []experimental.FunctionListener) moduleContextOpaque {
+// newHostModuleOffsetData returns the layout of the opaque context of a host module:
+// the *wasm.Module and the listeners in the first 32 bytes, followed by the Go functions,
+// and the globals owned by this module engine if any. Other fields don't exist.
+func newHostModuleOffsetData(m *wasm.Module) wazevoapi.ModuleContextOffsetData {
+	ret := wazevoapi.ModuleContextOffsetData{
+		LocalMemoryBegin:                    -1,
+		ImportedMemoryBegin:                 -1,
+		ImportedFunctionsBegin:              -1,
+		GlobalsBegin:                        -1,
+		TypeIDs1stElement:                   -1,
+		TablesBegin:                         -1,
+		TagsBegin:                           -1,
+		MemoriesBegin:                       -1,
+		BeforeListenerTrampolines1stElement: -1,
+		AfterListenerTrampolines1stElement:  -1,
+		DataInstances1stElement:             -1,
+		ElementInstances1stElement:          -1,
+	}
 	size := len(m.CodeSection)*16 + 32
-	ret := newAlignedOpaque(size)
+	if globals := len(m.GlobalSection); globals > 0 {
+		// Align to 16 bytes for globals, the same as the regular modules.
+		size = (size + 15) &^ 15
+		ret.GlobalsBegin = wazevoapi.Offset(size)
+		size += globals * 16
+	}
+	ret.TotalSize = size
+	return ret
+}
+
+func buildHostModuleOpaque(m *wasm.Module, offsets *wazevoapi.ModuleContextOffsetData, listeners []experimental.FunctionListener) moduleContextOpaque {
+	ret := newAlignedOpaque(offsets.TotalSize)
 
 	binary.LittleEndian.PutUint64(ret[0:], uint64(uintptr(unsafe.Pointer(m))))
 
@@ -32,6 +61,19 @@ func buildHostModuleOpaque(m *wasm.Module, listeners []experimental.FunctionList
 	return ret
 }
 
+// putHostModuleGlobals writes the initial values of the globals defined by the host module to the opaque.
+func (m *moduleEngine) putHostModuleGlobals() {
+	globalOffset := m.parent.offsets.GlobalsBegin
+	if globalOffset < 0 {
+		return
+	}
+	for _, g := range m.module.Globals {
+		binary.LittleEndian.PutUint64(m.opaque[globalOffset:], g.Val)
+		binary.LittleEndian.PutUint64(m.opaque[globalOffset+8:], g.ValHi)
+		globalOffset += 16
+	}
+}
+
 func hostModuleFromOpaque(opaqueBegin uintptr) *wasm.Module {
 	var opaqueViewOverSlice []byte
 	//nolint:staticcheck
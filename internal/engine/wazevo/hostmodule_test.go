@@ -7,6 +7,7 @@ import (
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -51,10 +52,21 @@ func Test_buildHostModuleOpaque(t *testing.T) {
 			},
 			listeners: make([]experimental.FunctionListener, 50),
 		},
+		{
+			name: "globals",
+			m: &wasm.Module{
+				CodeSection: []wasm.Code{
+					{GoFunc: api.GoFunc(func(context.Context, []uint64) {})},
+				},
+				GlobalSection: []wasm.Global{{Type: wasm.GlobalType{ValType: wasm.ValueTypeI32}}},
+			},
+		},
 	} {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			got := buildHostModuleOpaque(tc.m, tc.listeners)
+			offsets := newHostModuleOffsetData(tc.m)
+			got := buildHostModuleOpaque(tc.m, &offsets, tc.listeners)
+			require.Equal(t, offsets.TotalSize, len(got))
 			opaque := uintptr(unsafe.Pointer(&got[0]))
 			require.Equal(t, tc.m, hostModuleFromOpaque(opaque))
 			if len(tc.listeners) > 0 {
@@ -63,6 +75,11 @@ func Test_buildHostModuleOpaque(t *testing.T) {
 			for i, c := range tc.m.CodeSection {
 				require.Equal(t, c.GoFunc, hostModuleGoFuncFromOpaque[api.GoFunction](i, opaque))
 			}
+			if len(tc.m.GlobalSection) > 0 {
+				require.Equal(t, wazevoapi.Offset(48), offsets.GlobalsBegin)
+			} else {
+				require.Equal(t, wazevoapi.Offset(-1), offsets.GlobalsBegin)
+			}
 		})
 	}
 }
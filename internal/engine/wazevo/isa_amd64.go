@@ -28,3 +28,36 @@ func goCallStackView(stackPointerBeforeGoCall *uint64) []uint64 {
 func adjustClonedStack(oldsp, oldTop, sp, fp, top uintptr) {
 	amd64.AdjustClonedStack(oldsp, oldTop, sp, fp, top)
 }
+
+// verifyMemoryGuardPages returns nil if the bounds checks of the memory accesses can be elided
+// by surrounding the linear memories with guard pages.
+func verifyMemoryGuardPages() error {
+	return amd64.VerifySigsegvHandler()
+}
+
+// registerExecutable makes the memory access faults raised within the given executable be
+// reported as wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess.
+func registerExecutable(executable []byte) error {
+	return amd64.RegisterExecutable(executable)
+}
+
+// unregisterExecutable undoes registerExecutable.
+func unregisterExecutable(executable []byte) {
+	amd64.UnregisterExecutable(executable)
+}
+
+// registerStack registers the stack on which the compiled code runs during a call, so that the memory access
+// faults are only recovered from with the frame pointers within it.
+func registerStack(stack []byte, execCtxPtr uintptr) (int, error) {
+	return amd64.RegisterStack(stack, execCtxPtr)
+}
+
+// updateStack replaces the stack registered by registerStack.
+func updateStack(i int, stack []byte) {
+	amd64.UpdateStack(i, stack)
+}
+
+// unregisterStack undoes registerStack.
+func unregisterStack(i int) {
+	amd64.UnregisterStack(i)
+}
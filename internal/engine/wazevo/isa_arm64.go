@@ -3,6 +3,8 @@
 package wazevo
 
 import (
+	"errors"
+
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend/isa/arm64"
 )
@@ -30,3 +32,30 @@ func adjustClonedStack(oldsp, oldTop, sp, fp, top uintptr) {
 	//  so no need to adjustment on arm64. However, when we make it absolute, which in my opinion is better perf-wise
 	//  at the expense of slightly costly stack growth, we need to adjust the pushed frame pointers.
 }
+
+// verifyMemoryGuardPages returns nil if the bounds checks of the memory accesses can be elided
+// by surrounding the linear memories with guard pages.
+func verifyMemoryGuardPages() error {
+	return errors.New("memory guard pages are only supported on linux/amd64")
+}
+
+// registerExecutable makes the memory access faults raised within the given executable be
+// reported as wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess.
+func registerExecutable([]byte) error {
+	return errors.New("memory guard pages are only supported on linux/amd64")
+}
+
+// unregisterExecutable undoes registerExecutable.
+func unregisterExecutable([]byte) {}
+
+// registerStack registers the stack on which the compiled code runs during a call, so that the memory access
+// faults are only recovered from with the frame pointers within it.
+func registerStack([]byte, uintptr) (int, error) {
+	return 0, errors.New("memory guard pages are only supported on linux/amd64")
+}
+
+// updateStack replaces the stack registered by registerStack.
+func updateStack(int, []byte) {}
+
+// unregisterStack undoes registerStack.
+func unregisterStack(int) {}
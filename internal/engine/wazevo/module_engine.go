@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/tetratelabs/wazero/api"
@@ -26,6 +27,9 @@ type (
 		localFunctionInstances []*functionInstance
 		importedFunctions      []importedFunction
 		listeners              []experimental.FunctionListener
+		// sharedStateFunctions holds the compiledModule of each local function instantiated by
+		// SharedStateEngine.NewFunctionEntry, if the parent is compiled with the shared state.
+		sharedStateFunctions []atomic.Pointer[compiledModule]
 	}
 
 	functionInstance struct {
@@ -57,6 +61,8 @@ type (
 	//      localGlobals                              []Global               (optional)
 	//      typeIDsBegin                              &wasm.ModuleInstance.TypeIDs[0]  (optional)
 	//      tables                                    []*wasm.TableInstance  (optional)
+	//      tags                                      []*wasm.TagInstance    (optional)
+	//      memories                                  []*wasm.MemoryInstance (optional, only with multiple memories)
 	// 	    beforeListenerTrampolines1stElement       **byte                 (optional)
 	// 	    afterListenerTrampolines1stElement        **byte                 (optional)
 	//      dataInstances1stElement                   []wasm.DataInstance    (optional)
@@ -74,7 +80,7 @@ type (
 	//
 	// See wazevoapi.NewModuleContextOffsetData for the details of the offsets.
 	//
-	// Note that for host modules, the structure is entirely different. See buildHostModuleOpaque.
+	// Note that for host modules, the structure is entirely different. See newHostModuleOffsetData.
 	moduleContextOpaque []byte
 )
 
@@ -109,7 +115,10 @@ func (m *moduleEngine) setupOpaque() {
 
 	if globalOffset := offsets.GlobalsBegin; globalOffset >= 0 {
 		for i, g := range inst.Globals {
-			if i < int(inst.Source.ImportGlobalCount) {
+			if m.parent.sharedState {
+				// The globals are owned by the interpreter, so they are accessed the same way as the imported ones.
+				binary.LittleEndian.PutUint64(opaque[globalOffset:], uint64(uintptr(unsafe.Pointer(&g.Val))))
+			} else if i < int(inst.Source.ImportGlobalCount) {
 				importedME := g.Me.(*moduleEngine)
 				offset := importedME.parent.offsets.GlobalInstanceOffset(g.Index)
 				importedMEOpaque := importedME.opaque
@@ -144,6 +153,14 @@ func (m *moduleEngine) setupOpaque() {
 		}
 	}
 
+	if memoryOffset := offsets.MemoriesBegin; memoryOffset >= 0 {
+		for _, mem := range inst.Memories {
+			binary.LittleEndian.PutUint64(opaque[memoryOffset:],
+				uint64(uintptr(unsafe.Pointer(mem))))
+			memoryOffset += 8
+		}
+	}
+
 	if beforeListenerOffset := offsets.BeforeListenerTrampolines1stElement; beforeListenerOffset >= 0 {
 		binary.LittleEndian.PutUint64(opaque[beforeListenerOffset:], uint64(uintptr(unsafe.Pointer(&m.parent.listenerBeforeTrampolines[0]))))
 	}
@@ -195,11 +212,15 @@ func (m *moduleEngine) NewFunction(index wasm.Index) api.Function {
 		sizeOfParamResultSlice = ps
 	}
 	p := m.parent
-	offset := p.functionOffsets[localIndex]
+	code := p
+	if p.sharedState {
+		code = m.sharedStateFunctions[localIndex].Load()
+	}
+	offset := code.functionOffsets[localIndex]
 
 	ce := &callEngine{
 		indexInModule:          index,
-		executable:             &p.executable[offset],
+		executable:             &code.executable[offset],
 		parent:                 m,
 		preambleExecutable:     p.entryPreamblesPtrs[typIndex],
 		sizeOfParamResultSlice: sizeOfParamResultSlice,
@@ -251,7 +272,10 @@ func (m *moduleEngine) OwnsGlobals() bool { return true }
 
 // MemoryGrown implements wasm.ModuleEngine.
 func (m *moduleEngine) MemoryGrown() {
-	m.putLocalMemory()
+	// Only the first memory is cached in the opaque buffer. Other memories are loaded via their *wasm.MemoryInstance.
+	if m.parent.offsets.LocalMemoryBegin >= 0 {
+		m.putLocalMemory()
+	}
 }
 
 // putLocalMemory writes the local memory buffer pointer and length to the opaque buffer.
@@ -260,10 +284,8 @@ func (m *moduleEngine) putLocalMemory() {
 	offset := m.parent.offsets.LocalMemoryBegin
 
 	s := uint64(len(mem.Buffer))
-	var b uint64
-	if len(mem.Buffer) > 0 {
-		b = uint64(uintptr(unsafe.Pointer(&mem.Buffer[0])))
-	}
+	// Use the base even if the buffer is empty, as the memory with the guard pages is accessed without bounds checks.
+	b := uint64(uintptr(unsafe.Pointer(unsafe.SliceData(mem.Buffer))))
 	binary.LittleEndian.PutUint64(m.opaque[offset:], b)
 	binary.LittleEndian.PutUint64(m.opaque[offset+8:], s)
 }
@@ -292,13 +314,19 @@ func (m *moduleEngine) ResolveImportedFunction(index, descFunc, indexInImportedM
 }
 
 // ResolveImportedMemory implements wasm.ModuleEngine.
-func (m *moduleEngine) ResolveImportedMemory(importedModuleEngine wasm.ModuleEngine) {
+func (m *moduleEngine) ResolveImportedMemory(index, indexInImportedModule wasm.Index, importedModuleEngine wasm.ModuleEngine) {
+	if index != 0 {
+		return // Only the first memory is cached in the opaque buffer. Others are written by setupOpaque.
+	}
 	importedME := importedModuleEngine.(*moduleEngine)
 	inst := importedME.module
 
 	var memInstPtr uint64
 	var memOwnerOpaquePtr uint64
-	if offs := importedME.parent.offsets; offs.ImportedMemoryBegin >= 0 {
+	if indexInImportedModule != 0 {
+		memInstPtr = uint64(uintptr(unsafe.Pointer(inst.Memories[indexInImportedModule])))
+		memOwnerOpaquePtr = uint64(uintptr(unsafe.Pointer(importedME.opaquePtr)))
+	} else if offs := importedME.parent.offsets; offs.ImportedMemoryBegin >= 0 {
 		offset := offs.ImportedMemoryBegin
 		memInstPtr = binary.LittleEndian.Uint64(importedME.opaque[offset:])
 		memOwnerOpaquePtr = binary.LittleEndian.Uint64(importedME.opaque[offset+8:])
@@ -313,7 +341,9 @@ func (m *moduleEngine) ResolveImportedMemory(importedModuleEngine wasm.ModuleEng
 
 // DoneInstantiation implements wasm.ModuleEngine.
 func (m *moduleEngine) DoneInstantiation() {
-	if !m.module.Source.IsHostModule {
+	if m.module.Source.IsHostModule {
+		m.putHostModuleGlobals()
+	} else {
 		m.setupOpaque()
 	}
 }
@@ -101,7 +101,7 @@ func TestModuleEngine_setupOpaque(t *testing.T) {
 					parent: &compiledModule{offsets: wazevoapi.ModuleContextOffsetData{ImportedMemoryBegin: -1}},
 				}
 				imported.opaquePtr = &imported.opaque[0]
-				m.ResolveImportedMemory(imported)
+				m.ResolveImportedMemory(0, 0, imported)
 
 				actualPtr := uintptr(binary.LittleEndian.Uint64(m.opaque[tc.offset.ImportedMemoryBegin:]))
 				expPtr := uintptr(unsafe.Pointer(tc.m.MemoryInstance))
@@ -377,7 +377,7 @@ func TestModuleEngine_ResolveImportedMemory_reexported(t *testing.T) {
 	binary.LittleEndian.PutUint64(importedME.opaque[1000:], 0x1234567890abcdef)
 	binary.LittleEndian.PutUint64(importedME.opaque[1000+8:], 0xabcdef1234567890)
 
-	m.ResolveImportedMemory(importedME)
+	m.ResolveImportedMemory(0, 0, importedME)
 	require.Equal(t, uint64(0x1234567890abcdef), binary.LittleEndian.Uint64(m.opaque[50:]))
 	require.Equal(t, uint64(0xabcdef1234567890), binary.LittleEndian.Uint64(m.opaque[50+8:]))
 }
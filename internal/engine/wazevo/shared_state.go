@@ -0,0 +1,364 @@
+package wazevo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/backend"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/frontend"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+type (
+	// SharedStateEngine compiles the functions to run on the state of the module instances owned by the interpreter,
+	// which tiers up the hot functions with it one by one. See frontend.Compiler WithSharedState for how the compiled
+	// code differs.
+	//
+	// The function references of the interpreter, which the compiled code calls all the functions via, must point to
+	// a pointer to a *FunctionEntry, i.e. the first field of the function and the first field of what it points to.
+	// The entry is either the compiled code of the function, or the one calling back the interpreter.
+	// See NewGoFunctionEntry.
+	SharedStateEngine struct {
+		e *engine
+		// goFunctionTrampolines are the trampolines of the entries created by NewGoFunctionEntry, per function type.
+		goFunctionTrampolines *goFunctionTrampolines
+		// functions are the functions compiled by CompileFunction, each as a compiledModule only having it.
+		// This is guarded by e.mux.
+		functions map[sharedStateFunctionKey]*compiledModuleWithCount
+	}
+
+	sharedStateFunctionKey struct {
+		module wasm.ModuleID
+		index  wasm.Index
+	}
+
+	// FunctionEntry is the functionInstance called by the code compiled by SharedStateEngine.
+	FunctionEntry struct {
+		functionInstance
+	}
+
+	goFunctionTrampolines struct {
+		// executables are keyed by wasm.FunctionType String.
+		executables map[string][]byte
+	}
+
+	// FrameRecorder records the frames of the compiled code unwound by a panic, from the innermost one.
+	FrameRecorder interface {
+		AddFrame(funcName string, paramTypes, resultTypes []api.ValueType, sources []string)
+	}
+
+	// GoFunctionEntryFunc is the Go function called by the entry of NewGoFunctionEntry with the caller module
+	// instance and the callerValue given to SharedStateEngine.CallWithStack.
+	GoFunctionEntryFunc func(ctx context.Context, caller *wasm.ModuleInstance, callerValue interface{}, stack []uint64)
+
+	// goFunctionEntry is the FunctionEntry calling the Go function. fn is held to keep it alive, as opaque
+	// holds the interface in bytes.
+	goFunctionEntry struct {
+		FunctionEntry
+		opaque moduleContextOpaque
+		fn     GoFunctionEntryFunc
+	}
+)
+
+// Call implements api.GoModuleFunction. This is never called, as the callEngine calls the GoFunctionEntryFunc
+// directly with the caller value.
+func (f GoFunctionEntryFunc) Call(context.Context, api.Module, []uint64) {
+	panic("BUG: GoFunctionEntryFunc must be called with the caller value")
+}
+
+// NewSharedStateEngine returns a SharedStateEngine. This must be called only if platform.CompilerSupported.
+func NewSharedStateEngine(ctx context.Context, enabledFeatures api.CoreFeatures) *SharedStateEngine {
+	e := NewEngine(ctx, enabledFeatures, nil).(*engine)
+	trampolines := &goFunctionTrampolines{executables: map[string][]byte{}}
+	e.setFinalizer(trampolines, goFunctionTrampolinesFinalizer)
+	return &SharedStateEngine{
+		e:                     e,
+		goFunctionTrampolines: trampolines,
+		functions:             map[sharedStateFunctionKey]*compiledModuleWithCount{},
+	}
+}
+
+func goFunctionTrampolinesFinalizer(t *goFunctionTrampolines) {
+	for _, executable := range t.executables {
+		if err := platform.MunmapCodeSegment(executable); err != nil {
+			panic(err)
+		}
+	}
+	t.executables = nil
+}
+
+// CompileModule compiles what the functions of the module compiled by CompileFunction share, i.e. the entries of the
+// calls from Go per function type, so that the module can be instantiated by NewModuleEngine.
+func (s *SharedStateEngine) CompileModule(ctx context.Context, module *wasm.Module, ensureTermination bool) error {
+	e := s.e
+	if _, ok := e.getCompiledModuleFromMemory(module, true); ok { // cache hit!
+		return nil
+	}
+	cm := &compiledModule{
+		offsets: wazevoapi.NewSharedStateModuleContextOffsetData(module), parent: e, module: module,
+		ensureTermination: ensureTermination,
+		sharedState:       true,
+		sharedFunctions:   e.sharedFunctions,
+		executables:       &executables{},
+	}
+	machine := newMachine()
+	cm.executables.compileEntryPreambles(module, machine, backend.NewCompiler(ctx, machine, ssa.NewBuilder()))
+	e.setFinalizer(cm.executables, executablesFinalizer)
+	e.addCompiledModuleToMemory(module, cm)
+	return nil
+}
+
+// DeleteCompiledModule implements the same method as documented on wasm.Engine.
+func (s *SharedStateEngine) DeleteCompiledModule(module *wasm.Module) {
+	s.e.DeleteCompiledModule(module)
+}
+
+// CompileFunction compiles the local function at the index of the module, which is then instantiated by
+// NewFunctionEntry. This returns the error of the context if it is done before the compilation starts.
+func (s *SharedStateEngine) CompileFunction(ctx context.Context, module *wasm.Module, index wasm.Index, ensureTermination bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e := s.e
+	key := sharedStateFunctionKey{module: module.ID, index: index}
+	if s.addCompiledFunction(key, nil) { // cache hit!
+		return nil
+	}
+	cm, err := e.compileSharedStateFunction(ctx, module, index, ensureTermination)
+	if err != nil {
+		return err
+	}
+	s.addCompiledFunction(key, cm)
+	return nil
+}
+
+// addCompiledFunction increments the reference count of the function compiled at the key and returns true if any,
+// or adds cm at the key unless it is nil.
+func (s *SharedStateEngine) addCompiledFunction(key sharedStateFunctionKey, cm *compiledModule) bool {
+	e := s.e
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if f, ok := s.functions[key]; ok {
+		f.refCount++
+		return true
+	}
+	if cm != nil {
+		s.functions[key] = &compiledModuleWithCount{compiledModule: cm, refCount: 1}
+		e.addCompiledModuleToSortedList(cm)
+	}
+	return false
+}
+
+// DeleteCompiledFunction releases the function compiled by CompileFunction.
+func (s *SharedStateEngine) DeleteCompiledFunction(module *wasm.Module, index wasm.Index) {
+	e := s.e
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	key := sharedStateFunctionKey{module: module.ID, index: index}
+	f, ok := s.functions[key]
+	if !ok {
+		return
+	}
+	f.refCount--
+	if f.refCount > 0 {
+		return
+	}
+	e.deleteCompiledModuleFromSortedList(f.compiledModule)
+	delete(s.functions, key)
+}
+
+// Close releases the compiled modules and functions. Unlike wasm.Engine Close, this can be followed by CompileModule
+// and CompileFunction.
+func (s *SharedStateEngine) Close() error {
+	e := s.e
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	clear(e.compiledModules)
+	clear(s.functions)
+	e.sortedCompiledModules = nil
+	return nil
+}
+
+// compileSharedStateFunction returns the compiledModule only having the local function at the index of the module,
+// compiled with the shared state. Its function offsets are laid out so that functionIndexOf finds the function.
+func (e *engine) compileSharedStateFunction(ctx context.Context, module *wasm.Module, index wasm.Index, ensureTermination bool) (*compiledModule, error) {
+	cm := &compiledModule{
+		offsets: wazevoapi.NewSharedStateModuleContextOffsetData(module), parent: e, module: module,
+		ensureTermination: ensureTermination,
+		sharedState:       true,
+		sharedFunctions:   e.sharedFunctions,
+		executables:       &executables{},
+	}
+
+	importedFns, localFns := int(module.ImportFunctionCount), len(module.FunctionSection)
+	machine := newMachine()
+	relocator, err := newEngineRelocator(machine, importedFns, localFns)
+	if err != nil {
+		return nil, err
+	}
+
+	inliningBudget := experimental.GetInliningBudget(ctx)
+	needSourceInfo := module.DWARFLines != nil || inliningBudget > 0
+	relocator.needSourceInfo = needSourceInfo
+
+	ssaBuilder := newSSABuilder(ctx)
+	be := backend.NewCompiler(ctx, machine, ssaBuilder)
+	fe := frontend.NewFrontendCompiler(module, ssaBuilder, &cm.offsets, ensureTermination, false, false, needSourceInfo).
+		WithInliningBudget(inliningBudget).
+		WithSharedState(true)
+
+	fidx := module.ImportFunctionCount + index
+	fctx := functionContext(ctx, module, int(index), fidx)
+	body, rels, err := e.compileLocalWasmFunction(fctx, module, index, fe, ssaBuilder, be, false)
+	if err != nil {
+		return nil, fmt.Errorf("compile function %d/%d: %v", index, localFns-1, err)
+	}
+
+	cm.functionOffsets = make([]int, localFns)
+	relocator.appendFunction(fctx, module, cm, int(index), fidx, body, rels, be.SourceOffsetInfo())
+	for i := int(index) + 1; i < localFns; i++ {
+		cm.functionOffsets[i] = relocator.totalSize
+	}
+
+	executable, err := platform.MmapCodeSegment(relocator.totalSize)
+	if err != nil {
+		panic(err)
+	}
+	cm.executable = executable
+	copy(executable, body)
+
+	if needSourceInfo {
+		for i := range cm.sourceMap.executableOffsets {
+			cm.sourceMap.executableOffsets[i] += uintptr(unsafe.Pointer(&cm.executable[0]))
+		}
+	}
+
+	// There is no relocation to resolve, as the other functions are called via their function references.
+	if err = platform.MprotectCodeSegment(executable); err != nil {
+		return nil, err
+	}
+	e.setFinalizer(cm.executables, executablesFinalizer)
+	return cm, nil
+}
+
+// NewModuleEngine returns the wasm.ModuleEngine running the code compiled by CompileFunction on the state of the
+// instance. functions are the function references of all the functions of the module, via which the compiled code
+// calls them.
+//
+// The returned wasm.ModuleEngine is only used to call the local functions instantiated by NewFunctionEntry, and to
+// notify the memory growth.
+func (s *SharedStateEngine) NewModuleEngine(m *wasm.Module, inst *wasm.ModuleInstance, functions []wasm.Reference) (wasm.ModuleEngine, error) {
+	me, err := s.e.NewModuleEngine(m, inst)
+	if err != nil {
+		return nil, err
+	}
+	ret := me.(*moduleEngine)
+	offsets := &ret.parent.offsets
+	for i, ref := range functions {
+		executableOffset, _, _ := offsets.ImportedFunctionOffset(wasm.Index(i))
+		binary.LittleEndian.PutUint64(ret.opaque[executableOffset:], uint64(ref))
+	}
+	if offset := offsets.ImportedMemoryBegin; offset >= 0 {
+		binary.LittleEndian.PutUint64(ret.opaque[offset:], uint64(uintptr(unsafe.Pointer(inst.MemoryInstance))))
+	}
+	ret.DoneInstantiation()
+	ret.sharedStateFunctions = make([]atomic.Pointer[compiledModule], len(m.FunctionSection))
+	return ret, nil
+}
+
+// NewFunctionEntry returns the FunctionEntry of the local function at the index compiled by CompileFunction, running
+// on the wasm.ModuleEngine returned by NewModuleEngine, whose NewFunction calls it from then on. This must be called
+// at most once per function of the wasm.ModuleEngine.
+func (s *SharedStateEngine) NewFunctionEntry(me wasm.ModuleEngine, index wasm.Index) (*FunctionEntry, error) {
+	ret := me.(*moduleEngine)
+	m := ret.parent.module
+	e := s.e
+	e.mux.RLock()
+	f, ok := s.functions[sharedStateFunctionKey{module: m.ID, index: index}]
+	e.mux.RUnlock()
+	if !ok {
+		return nil, errors.New("source function must be compiled before instantiation")
+	}
+	cm := f.compiledModule
+	ret.sharedStateFunctions[index].Store(cm)
+
+	entry := &FunctionEntry{}
+	entry.executable = &cm.executable[cm.functionOffsets[index]]
+	entry.moduleContextOpaquePtr = ret.opaquePtr
+	entry.typeID = ret.module.TypeIDs[m.FunctionSection[index]]
+	entry.indexInModule = m.ImportFunctionCount + index
+	return entry, nil
+}
+
+// CallWithStack calls the api.Function returned by NewFunction of the wasm.ModuleEngine of NewModuleEngine with
+// callerValue, which is passed to the GoFunctionEntryFunc called by the compiled code. Unlike the context.Context
+// values, callerValue isn't seen by the host functions.
+//
+// The panics during the call are propagated to the caller as is, after the frames of the compiled code unwound by
+// them are recorded to unwound.
+func (s *SharedStateEngine) CallWithStack(ctx context.Context, f api.Function, callerValue interface{}, unwound FrameRecorder, stack []uint64) error {
+	c := f.(*callEngine)
+	c.callerValue, c.unwound = callerValue, unwound
+	err := c.CallWithStack(ctx, stack)
+	c.callerValue, c.unwound = nil, nil
+	return err
+}
+
+// recordUnwoundFrames records the frames unwound by the panic in the same way as the stack trace of the error.
+func (c *callEngine) recordUnwoundFrames() {
+	unwound := c.unwound
+	c.callerValue, c.unwound = nil, nil
+	if unwound == nil || c.execCtx.stackPointerBeforeGoCall == nil {
+		return
+	}
+	c.addFrame(unwound, uintptr(unsafe.Pointer(c.execCtx.goCallReturnAddress)))
+	returnAddrs := unwindStack(
+		uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)),
+		c.execCtx.framePointerBeforeGoCall,
+		c.stackTop,
+		nil,
+	)
+	if len(returnAddrs) > 1 {
+		for _, retAddr := range returnAddrs[:len(returnAddrs)-1] { // the last return addr is the trampoline, so we skip it.
+			c.addFrame(unwound, retAddr)
+		}
+	}
+}
+
+// NewGoFunctionEntry returns the FunctionEntry calling fn, which must be of the given function type.
+func (s *SharedStateEngine) NewGoFunctionEntry(typ *wasm.FunctionType, typeID wasm.FunctionTypeID, fn GoFunctionEntryFunc) *FunctionEntry {
+	ret := &goFunctionEntry{fn: fn}
+	// The opaque is laid out as the one of a host module which only has fn. See buildHostModuleOpaque.
+	ret.opaque = newAlignedOpaque(48)
+	writeIface(fn, ret.opaque[32:])
+	ret.executable = s.goFunctionTrampoline(typ)
+	ret.moduleContextOpaquePtr = &ret.opaque[0]
+	ret.typeID = typeID
+	return &ret.FunctionEntry
+}
+
+func (s *SharedStateEngine) goFunctionTrampoline(typ *wasm.FunctionType) *byte {
+	e := s.e
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	key := typ.String()
+	executable, ok := s.goFunctionTrampolines.executables[key]
+	if !ok {
+		sig := frontend.SignatureForWasmFunctionType(typ)
+		e.be.Init()
+		buf := e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeCallGoModuleFunctionWithIndex(0, false), &sig, true)
+		executable = mmapExecutable(buf)
+		s.goFunctionTrampolines.executables[key] = executable
+	}
+	return &executable[0]
+}
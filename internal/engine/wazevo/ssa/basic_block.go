@@ -228,6 +228,49 @@ func (bb *basicBlock) insertInstruction(b *builder, next *Instruction) {
 	}
 }
 
+// removeInstruction unlinks the given instruction from this block.
+// This must not be used for the branching instructions as the predecessors are not updated.
+func (bb *basicBlock) removeInstruction(instr *Instruction) {
+	if prev := instr.prev; prev != nil {
+		prev.next = instr.next
+	} else {
+		bb.rootInstr = instr.next
+	}
+	if next := instr.next; next != nil {
+		next.prev = instr.prev
+	} else {
+		bb.currentInstr = instr.prev
+	}
+	instr.prev, instr.next = nil, nil
+}
+
+// insertInstructionBeforeBranches inserts the given non-branching instruction at the end of this block,
+// but before its branching instructions.
+func (bb *basicBlock) insertInstructionBeforeBranches(instr *Instruction) {
+	var at *Instruction
+	for cur := bb.currentInstr; cur != nil && cur.IsBranching(); cur = cur.prev {
+		at = cur
+	}
+	if at == nil {
+		if tail := bb.currentInstr; tail != nil {
+			tail.next = instr
+			instr.prev = tail
+		} else {
+			bb.rootInstr = instr
+		}
+		bb.currentInstr = instr
+		return
+	}
+	if prev := at.prev; prev != nil {
+		prev.next = instr
+		instr.prev = prev
+	} else {
+		bb.rootInstr = instr
+	}
+	instr.next = at
+	at.prev = instr
+}
+
 // NumPreds implements BasicBlock.NumPreds.
 func (bb *basicBlock) NumPreds() int {
 	return len(bb.preds)
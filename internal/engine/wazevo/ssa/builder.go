@@ -77,6 +77,10 @@ type Builder interface {
 	// RunPasses runs various passes on the constructed SSA function.
 	RunPasses()
 
+	// SetOptimizationPasses sets the optional optimization passes run by RunPasses.
+	// Defaults to OptimizationPassesDefault, and is kept across Init.
+	SetOptimizationPasses(OptimizationPasses)
+
 	// Format returns the debugging string of the SSA function.
 	Format() string
 
@@ -147,6 +151,10 @@ func NewBuilder() Builder {
 		valueAnnotations:        make(map[ValueID]string),
 		signatures:              make(map[SignatureID]*Signature),
 		returnBlk:               &basicBlock{id: basicBlockIDReturnBlock},
+		optimizationPasses:      OptimizationPassesDefault,
+		licmConstants:           make(map[ValueID]*basicBlock),
+		licmInvariantLoads:      make(map[invariantLoadKey]Value),
+		boundsChecks:            make(map[boundsCheckKey]boundsCheckEntry),
 	}
 }
 
@@ -204,6 +212,18 @@ type builder struct {
 
 	// zeros are the zero value constants for each type.
 	zeros [typeEnd]Value
+
+	// optimizationPasses are the optional optimization passes run by RunPasses.
+	optimizationPasses OptimizationPasses
+	// cseInstructions is used by passCommonSubexpressionEliminationOpt.
+	cseInstructions map[cseKey]cseEntry
+	// licmInLoop, licmDefinedInLoop, licmConstants and licmInvariantLoads are used by passLoopInvariantCodeMotionOpt.
+	licmInLoop         []bool
+	licmDefinedInLoop  []bool
+	licmConstants      map[ValueID]*basicBlock
+	licmInvariantLoads map[invariantLoadKey]Value
+	// boundsChecks is used by passRedundantBoundsCheckEliminationOpt.
+	boundsChecks map[boundsCheckKey]boundsCheckEntry
 }
 
 // ValueInfo contains the data per Value used to lower the SSA in backend.
@@ -300,6 +320,11 @@ func (b *builder) Init(s *Signature) {
 	b.currentSourceOffset = sourceOffsetUnknown
 }
 
+// SetOptimizationPasses implements Builder.SetOptimizationPasses.
+func (b *builder) SetOptimizationPasses(p OptimizationPasses) {
+	b.optimizationPasses = p
+}
+
 // Signature implements Builder.Signature.
 func (b *builder) Signature() *Signature {
 	return b.currentSignature
@@ -378,6 +403,13 @@ func (b *builder) Idom(blk BasicBlock) BasicBlock {
 func (b *builder) InsertInstruction(instr *Instruction) {
 	b.currentBB.insertInstruction(b, instr)
 
+	if instr.opcode == OpcodeExitIfTrueWithCode {
+		// Backends require the condition to be the Icmp right before this.
+		if cond := b.InstructionOfValue(instr.v2); cond != nil {
+			cond.pinned = true
+		}
+	}
+
 	if l := b.currentSourceOffset; l.Valid() {
 		// Emit the source offset info only when the instruction has side effect because
 		// these are the only instructions that are accessed by stack unwinding.
@@ -37,6 +37,13 @@ type Instruction struct {
 	sourceOffset   SourceOffset
 	live           bool
 	alreadyLowered bool
+	// pinned is true if the optimization passes must keep this instruction as is, e.g. the Icmp which
+	// backends merge into the OpcodeExitIfTrueWithCode using it.
+	pinned bool
+	// invariantUntilCall is true if this is a load which only changes across function calls. See MarkInvariantUntilCall.
+	invariantUntilCall bool
+	// mayTrap is true if this is a load which can trap. See MarkMayTrap.
+	mayTrap bool
 }
 
 // SourceOffset represents the offset of the source of an instruction.
@@ -49,6 +56,22 @@ func (l SourceOffset) Valid() bool {
 	return l != sourceOffsetUnknown
 }
 
+// NewInlinedSourceOffset returns the SourceOffset of the instruction at the offset `callee` in the body of a function
+// inlined at the offset `callSite`. Both must be non-negative and fit in 31 bits, which is the case of the offsets in
+// a Wasm binary.
+func NewInlinedSourceOffset(callSite, callee SourceOffset) SourceOffset {
+	return (callSite+1)<<32 | callee
+}
+
+// Inlined returns the offsets given to NewInlinedSourceOffset if l was returned by it.
+func (l SourceOffset) Inlined() (callSite, callee SourceOffset, ok bool) {
+	u := uint64(l)
+	if !l.Valid() || u>>32 == 0 {
+		return 0, 0, false
+	}
+	return SourceOffset(u>>32 - 1), SourceOffset(u & 0xffffffff), true
+}
+
 func (i *Instruction) annotateSourceOffset(line SourceOffset) {
 	i.sourceOffset = line
 }
@@ -158,6 +181,11 @@ func (i *Instruction) Arg3() (Value, Value, Value) {
 	return i.v, i.v2, i.v3
 }
 
+// Arg3WithLane returns the first three arguments to this instruction, and the lane type.
+func (i *Instruction) Arg3WithLane() (Value, Value, Value, VecLane) {
+	return i.v, i.v2, i.v3, VecLane(i.u1)
+}
+
 // Next returns the next instruction laid out next to itself.
 func (i *Instruction) Next() *Instruction {
 	return i.next
@@ -580,6 +608,15 @@ const (
 	// Currently, the only lane is i16, and the result is i32.
 	OpcodeWideningPairwiseDotProductS
 
+	// OpcodeVIdotI8x16I7x16S is a lane-wise pairwise dot product of the signed 8-bit lanes of x and the 7-bit lanes of y,
+	// producing 16-bit lanes: `v = VIdotI8x16I7x16S x, y` on vector.
+	// The result is target-dependent when a lane of y is out of the 7-bit range.
+	OpcodeVIdotI8x16I7x16S
+
+	// OpcodeVFma performs a lane-wise multiply-add: `v = VFma.lane x, y, z` computing x*y+z on vector.
+	// Whether the multiplication and the addition are fused depends on the target.
+	OpcodeVFma
+
 	// OpcodeUExtend zero-extends the given integer: `v = UExtend x, from->to`.
 	OpcodeUExtend
 
@@ -889,12 +926,16 @@ var instructionSideEffects = [opcodeEnd]sideEffect{
 	OpcodeTailCallReturnCall:          sideEffectStrict,
 	OpcodeTailCallReturnCallIndirect:  sideEffectStrict,
 	OpcodeWideningPairwiseDotProductS: sideEffectNone,
+	OpcodeVIdotI8x16I7x16S:            sideEffectNone,
+	OpcodeVFma:                        sideEffectNone,
 }
 
 // sideEffect returns true if this instruction has side effects.
 func (i *Instruction) sideEffect() sideEffect {
 	if e := instructionSideEffects[i.opcode]; e == sideEffectUnknown {
 		panic("BUG: side effect info not registered for " + i.opcode.String())
+	} else if e == sideEffectNone && i.mayTrap {
+		return sideEffectTraps
 	} else {
 		return e
 	}
@@ -1047,6 +1088,8 @@ var instructionReturnTypes = [opcodeEnd]returnTypesFn{
 	OpcodeTailCallReturnCallIndirect:  returnTypesFnCallIndirect,
 	OpcodeTailCallReturnCall:          returnTypesFnCall,
 	OpcodeWideningPairwiseDotProductS: returnTypesFnV128,
+	OpcodeVIdotI8x16I7x16S:            returnTypesFnV128,
+	OpcodeVFma:                        returnTypesFnV128,
 }
 
 // AsLoad initializes this instruction as a store instruction with OpcodeLoad.
@@ -1058,6 +1101,21 @@ func (i *Instruction) AsLoad(ptr Value, offset uint32, typ Type) *Instruction {
 	return i
 }
 
+// MarkInvariantUntilCall marks this load as reading the memory which is only modified during function calls,
+// e.g. the buffer base and length of the linear memory, so that it can be hoisted out of the loops without calls.
+func (i *Instruction) MarkInvariantUntilCall() *Instruction {
+	i.invariantUntilCall = true
+	return i
+}
+
+// MarkMayTrap marks this load as trapping on the out of bounds access without any explicit bounds check,
+// e.g. the one of the linear memory with guard pages, so that it is kept alive even if its result is unused.
+// This must be called before the instruction is inserted.
+func (i *Instruction) MarkMayTrap() *Instruction {
+	i.mayTrap = true
+	return i
+}
+
 // AsExtLoad initializes this instruction as a store instruction with OpcodeLoad.
 func (i *Instruction) AsExtLoad(op Opcode, ptr Value, offset uint32, dst64bit bool) *Instruction {
 	i.opcode = op
@@ -1179,6 +1237,27 @@ func (i *Instruction) AsWideningPairwiseDotProductS(x, y Value) *Instruction {
 	return i
 }
 
+// AsVIdotI8x16I7x16S initializes this instruction as a lane-wise pairwise dot product instruction
+// with OpcodeVIdotI8x16I7x16S on a vector.
+func (i *Instruction) AsVIdotI8x16I7x16S(x, y Value) *Instruction {
+	i.opcode = OpcodeVIdotI8x16I7x16S
+	i.v = x
+	i.v2 = y
+	i.typ = TypeV128
+	return i
+}
+
+// AsVFma initializes this instruction as a multiply-add instruction with OpcodeVFma on a vector.
+func (i *Instruction) AsVFma(x, y, z Value, lane VecLane) *Instruction {
+	i.opcode = OpcodeVFma
+	i.v = x
+	i.v2 = y
+	i.v3 = z
+	i.u1 = uint64(lane)
+	i.typ = TypeV128
+	return i
+}
+
 // AsExtIaddPairwise initializes this instruction as a lane-wise integer extended pairwise addition instruction
 // with OpcodeIaddPairwise on a vector.
 func (i *Instruction) AsExtIaddPairwise(x Value, srcLane VecLane, signed bool) *Instruction {
@@ -2664,8 +2743,10 @@ func (i *Instruction) Format(b Builder) string {
 		} else {
 			instSuffix = fmt.Sprintf(" %s:%s, %s", FuncRef(i.u1), SignatureID(i.u2), strings.Join(vs, ", "))
 		}
-	case OpcodeWideningPairwiseDotProductS:
+	case OpcodeWideningPairwiseDotProductS, OpcodeVIdotI8x16I7x16S:
 		instSuffix = fmt.Sprintf(" %s, %s", i.v.Format(b), i.v2.Format(b))
+	case OpcodeVFma:
+		instSuffix = fmt.Sprintf(".%s %s, %s, %s", VecLane(i.u1), i.v.Format(b), i.v2.Format(b), i.v3.Format(b))
 	default:
 		panic(fmt.Sprintf("TODO: format for %s", i.opcode))
 	}
@@ -2891,6 +2972,10 @@ func (o Opcode) String() (ret string) {
 		return "IaddPairwise"
 	case OpcodeWideningPairwiseDotProductS:
 		return "WideningPairwiseDotProductS"
+	case OpcodeVIdotI8x16I7x16S:
+		return "VIdotI8x16I7x16S"
+	case OpcodeVFma:
+		return "VFma"
 	case OpcodeUExtend:
 		return "UExtend"
 	case OpcodeSExtend:
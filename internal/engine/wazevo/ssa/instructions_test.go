@@ -13,3 +13,23 @@ func TestInstruction_InvertConditionalBrx(t *testing.T) {
 	i.InvertBrx()
 	require.Equal(t, OpcodeBrnz, i.opcode)
 }
+
+func TestSourceOffset_Inlined(t *testing.T) {
+	for _, l := range []SourceOffset{0, 100, sourceOffsetUnknown} {
+		_, _, ok := l.Inlined()
+		require.False(t, ok)
+	}
+
+	for _, tc := range []struct{ callSite, callee SourceOffset }{
+		{callSite: 0, callee: 0},
+		{callSite: 10, callee: 200},
+		{callSite: 1<<31 - 1, callee: 1<<31 - 1},
+	} {
+		l := NewInlinedSourceOffset(tc.callSite, tc.callee)
+		require.True(t, l.Valid())
+		callSite, callee, ok := l.Inlined()
+		require.True(t, ok)
+		require.Equal(t, tc.callSite, callSite)
+		require.Equal(t, tc.callee, callee)
+	}
+}
@@ -6,6 +6,40 @@ import (
 	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
 )
 
+// OptimizationPasses is a set of optional optimization passes run by Builder.RunPasses.
+// Disabling them is useful to measure their effect, e.g. in benchmarks.
+type OptimizationPasses uint32
+
+const (
+	// OptimizationPassConstantFolding evaluates the integer instructions whose arguments are all constants.
+	OptimizationPassConstantFolding OptimizationPasses = 1 << iota
+	// OptimizationPassArithmeticSimplification rewrites the integer instructions with identities,
+	// e.g. `x + 0` into `x`, `x - x` into `0`.
+	OptimizationPassArithmeticSimplification
+	// OptimizationPassCopyPropagation replaces the uses of copied values with their sources.
+	OptimizationPassCopyPropagation
+	// OptimizationPassCommonSubexpressionElimination reuses the results of the pure instructions computed by a dominator.
+	OptimizationPassCommonSubexpressionElimination
+	// OptimizationPassLoopInvariantCodeMotion hoists the pure instructions, and the memory base and length loads
+	// of the loops without calls, out of the loops.
+	OptimizationPassLoopInvariantCodeMotion
+	// OptimizationPassBoundsCheckElimination removes the memory bounds checks implied by a dominating one.
+	OptimizationPassBoundsCheckElimination
+
+	// OptimizationPassesDefault are the optional optimization passes run unless set otherwise. The others are opt-in,
+	// as they haven't shown any improvement beyond the noise in BenchmarkSSAOptimizationPasses.
+	OptimizationPassesDefault = OptimizationPassLoopInvariantCodeMotion |
+		OptimizationPassBoundsCheckElimination
+
+	// OptimizationPassesAll are all the optional optimization passes.
+	OptimizationPassesAll = OptimizationPassConstantFolding |
+		OptimizationPassArithmeticSimplification |
+		OptimizationPassCopyPropagation |
+		OptimizationPassCommonSubexpressionElimination |
+		OptimizationPassLoopInvariantCodeMotion |
+		OptimizationPassBoundsCheckElimination
+)
+
 // RunPasses implements Builder.RunPasses.
 //
 // The order here matters; some pass depends on the previous ones.
@@ -26,6 +60,21 @@ func (b *builder) runPreBlockLayoutPasses() {
 	passCalculateImmediateDominators(b)
 	passRedundantPhiEliminationOpt(b)
 	passNopInstElimination(b)
+	if b.optimizationPasses&(OptimizationPassConstantFolding|OptimizationPassArithmeticSimplification) != 0 {
+		passSimplificationOpt(b)
+	}
+	if b.optimizationPasses&OptimizationPassCopyPropagation != 0 {
+		passCopyPropagationOpt(b)
+	}
+	if b.optimizationPasses&OptimizationPassLoopInvariantCodeMotion != 0 {
+		passLoopInvariantCodeMotionOpt(b)
+	}
+	if b.optimizationPasses&OptimizationPassCommonSubexpressionElimination != 0 {
+		passCommonSubexpressionEliminationOpt(b)
+	}
+	if b.optimizationPasses&OptimizationPassBoundsCheckElimination != 0 {
+		passRedundantBoundsCheckEliminationOpt(b)
+	}
 
 	// TODO: implement either conversion of irreducible CFG into reducible one, or irreducible CFG detection where we panic.
 	// 	WebAssembly program shouldn't result in irreducible CFG, but we should handle it properly in just in case.
@@ -33,10 +82,6 @@ func (b *builder) runPreBlockLayoutPasses() {
 
 	// TODO: implement more optimization passes like:
 	// 	block coalescing.
-	// 	Copy-propagation.
-	// 	Constant folding.
-	// 	Common subexpression elimination.
-	// 	Arithmetic simplifications.
 	// 	and more!
 
 	// passDeadCodeEliminationOpt could be more accurate if we do this after other optimizations.
@@ -0,0 +1,64 @@
+package ssa
+
+// cseKey identifies the pure instructions computing the same value.
+type cseKey struct {
+	opcode    Opcode
+	typ       Type
+	u1, u2    uint64
+	v, v2, v3 Value
+}
+
+// cseEntry is the first instruction seen for a cseKey, and the block it belongs to.
+type cseEntry struct {
+	instr *Instruction
+	blk   *basicBlock
+}
+
+// passCommonSubexpressionEliminationOpt aliases the result of a pure instruction to the one of an equivalent
+// instruction in a dominating position, and leaves it for passDeadCodeEliminationOpt to remove.
+//
+// The blocks are visited in the reverse post-order, so that dominators are visited first.
+// Hence, passCalculateImmediateDominators must be called before this.
+func passCommonSubexpressionEliminationOpt(b *builder) {
+	if b.cseInstructions == nil {
+		b.cseInstructions = make(map[cseKey]cseEntry)
+	}
+	seen := b.cseInstructions
+	defer clear(seen)
+
+	for blk := b.blockIteratorReversePostOrderBegin(); blk != nil; blk = b.blockIteratorReversePostOrderNext() {
+		for cur := blk.rootInstr; cur != nil; cur = cur.next {
+			if cur.pinned || !cseCandidate(cur) {
+				continue
+			}
+			b.resolveArgumentAlias(cur)
+			key := cseKey{opcode: cur.opcode, typ: cur.typ, u1: cur.u1, u2: cur.u2, v: cur.v, v2: cur.v2, v3: cur.v3}
+			if e, ok := seen[key]; ok && b.isDominatedBy(blk, e.blk) {
+				b.alias(cur.Return(), e.instr.Return())
+				continue
+			}
+			// Either the first one, or the previous one doesn't dominate this. In the latter case, this is more likely
+			// to dominate the rest of the blocks as they are visited in the reverse post-order.
+			seen[key] = cseEntry{instr: cur, blk: blk}
+		}
+	}
+}
+
+// cseCandidate returns true if the instruction is pure and has a single return value, so its result only depends
+// on its opcode, its immediates and its arguments.
+//
+// Notably, loads are not candidates as memory can be modified in between, nor are constants as backends
+// materialize them at their uses anyway.
+func cseCandidate(i *Instruction) bool {
+	switch i.opcode {
+	case OpcodeIadd, OpcodeIsub, OpcodeImul, OpcodeBand, OpcodeBor, OpcodeBxor, OpcodeBnot,
+		OpcodeIshl, OpcodeUshr, OpcodeSshr, OpcodeRotl, OpcodeRotr, OpcodeClz, OpcodeCtz, OpcodePopcnt,
+		OpcodeIcmp, OpcodeSelect, OpcodeUExtend, OpcodeSExtend, OpcodeIreduce, OpcodeBitcast,
+		OpcodeFcmp, OpcodeFadd, OpcodeFsub, OpcodeFmul, OpcodeFdiv, OpcodeSqrt, OpcodeFneg, OpcodeFabs,
+		OpcodeFcopysign, OpcodeFmin, OpcodeFmax, OpcodeCeil, OpcodeFloor, OpcodeTrunc, OpcodeNearest,
+		OpcodeFpromote, OpcodeFdemote:
+		return len(i.rValues.View()) == 0 && len(i.vs.View()) == 0
+	default:
+		return false
+	}
+}
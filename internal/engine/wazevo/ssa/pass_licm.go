@@ -0,0 +1,278 @@
+package ssa
+
+import "github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
+
+// invariantLoadKey identifies the loads marked with Instruction.MarkInvariantUntilCall reading the same location.
+type invariantLoadKey struct {
+	opcode Opcode
+	typ    Type
+	ptr    Value
+	offset uint64
+}
+
+// passLoopInvariantCodeMotionOpt hoists the following out of the loops without calls, into the preheader of the loop,
+// which is its only predecessor outside the loop:
+//   - the loads marked with Instruction.MarkInvariantUntilCall, such as the ones of the memory base and length.
+//     The identical ones are merged.
+//   - the memory bounds checks of the addresses which don't change across the iterations, if they are at the
+//     beginning of the loop header, so that they run before anything else in each iteration anyway.
+//     The pure instructions computing the checked address are hoisted along with them.
+//
+// The loops with calls are left as they are, since the callee may grow the memory. The frontend reloads the memory
+// base and length after each call, so the checks after them are against the current length.
+//
+// The other pure instructions are left in the loops. Hoisting them lengthens their live ranges, which made the
+// register allocation slower without making the loops of the bench suite faster.
+//
+// The loop headers are detected by passCalculateImmediateDominators, which must be called before this.
+// They are visited in the reverse post-order, so that the outer loops are processed before the inner ones,
+// and the instructions can move out of several loops at once.
+func passLoopInvariantCodeMotionOpt(b *builder) {
+	for _, header := range b.reversePostOrderedBasicBlocks {
+		if header.loopHeader {
+			b.hoistLoopInvariants(header)
+		}
+	}
+}
+
+// hoistLoopInvariants implements passLoopInvariantCodeMotionOpt for the loop whose header is `header`.
+func (b *builder) hoistLoopInvariants(header *basicBlock) {
+	var preheader *basicBlock
+	inLoop := resetBools(b.licmInLoop, b.basicBlocksPool.Allocated())
+	b.licmInLoop = inLoop
+
+	// The loop consists of the blocks from which the back edges are reachable without passing through the header.
+	stack := b.blkStack[:0]
+	inLoop[header.id] = true
+	for i := range header.preds {
+		pred := header.preds[i].blk
+		if b.isDominatedBy(pred, header) {
+			stack = append(stack, pred)
+		} else if preheader == nil {
+			preheader = pred
+		} else {
+			// Multiple entries.
+			b.blkStack = stack
+			return
+		}
+	}
+	for len(stack) > 0 {
+		tail := len(stack) - 1
+		blk := stack[tail]
+		stack = stack[:tail]
+		if inLoop[blk.id] {
+			continue
+		}
+		inLoop[blk.id] = true
+		for i := range blk.preds {
+			stack = append(stack, blk.preds[i].blk)
+		}
+	}
+	b.blkStack = stack
+	if preheader == nil {
+		return
+	}
+
+	// Mark the values defined in the loop, and check if it makes any call.
+	definedInLoop := resetBools(b.licmDefinedInLoop, int(b.nextValueID))
+	b.licmDefinedInLoop = definedInLoop
+	constants := b.licmConstants
+	defer clear(constants)
+	hasCall := false
+	for _, blk := range b.reversePostOrderedBasicBlocks {
+		if !inLoop[blk.id] {
+			continue
+		}
+		for _, p := range blk.params.View() {
+			definedInLoop[p.ID()] = true
+		}
+		for cur := blk.rootInstr; cur != nil; cur = cur.next {
+			switch cur.opcode {
+			case OpcodeCall, OpcodeCallIndirect:
+				hasCall = true
+			case OpcodeIconst, OpcodeF32const, OpcodeF64const, OpcodeVconst:
+				// Constants are considered as invariant, and hoisted along with their users.
+				constants[cur.Return().ID()] = blk
+				continue
+			}
+			r, rs := cur.Returns()
+			if r.Valid() {
+				definedInLoop[r.ID()] = true
+			}
+			for _, v := range rs {
+				definedInLoop[v.ID()] = true
+			}
+		}
+	}
+
+	if hasCall {
+		return
+	}
+
+	// The loads after the last call in the preheader read the same values as the ones in the loop.
+	loads := b.licmInvariantLoads
+	defer clear(loads)
+	for cur := preheader.currentInstr; cur != nil; cur = cur.prev {
+		if cur.opcode == OpcodeCall || cur.opcode == OpcodeCallIndirect {
+			break
+		}
+		if cur.invariantUntilCall {
+			loads[invariantLoadKey{opcode: cur.opcode, typ: cur.typ, ptr: b.resolveAlias(cur.v), offset: cur.u1}] = cur.Return()
+		}
+	}
+
+	for _, blk := range b.reversePostOrderedBasicBlocks {
+		if !inLoop[blk.id] {
+			continue
+		}
+		for cur := blk.rootInstr; cur != nil; {
+			next := cur.next
+			b.resolveArgumentAlias(cur)
+			if cur.invariantUntilCall && !definedInLoop[cur.v.ID()] {
+				key := invariantLoadKey{opcode: cur.opcode, typ: cur.typ, ptr: cur.v, offset: cur.u1}
+				if v, ok := loads[key]; ok {
+					b.alias(cur.Return(), v)
+				} else {
+					loads[key] = cur.Return()
+					b.hoistInstruction(blk, preheader, cur)
+				}
+				definedInLoop[cur.Return().ID()] = false
+			}
+			cur = next
+		}
+	}
+
+	// The checks hoisted into the preheader must not run when the loop is not entered.
+	if len(preheader.success) != 1 {
+		return
+	}
+	for cur := header.rootInstr; cur != nil; {
+		next := cur.next
+		if _, _, ok := b.memoryBoundsCheck(cur); ok && b.isLoopInvariant(cur.v2, definedInLoop) {
+			b.hoistLoopInvariant(cur.v2, header, preheader, definedInLoop, constants)
+			b.hoistInstruction(header, preheader, cur)
+		} else if cur.sideEffect() != sideEffectNone {
+			// The checks after this may not be reached in the first iteration.
+			break
+		}
+		cur = next
+	}
+}
+
+// isLoopInvariant returns true if the value is defined outside the loop, or computed from such values by pure instructions.
+// The constants are not marked in definedInLoop, so they are loop invariant.
+func (b *builder) isLoopInvariant(v Value, definedInLoop []bool) bool {
+	if !definedInLoop[v.ID()] {
+		return true
+	}
+	instr := b.InstructionOfValue(v)
+	if instr == nil || !cseCandidate(instr) {
+		return false
+	}
+	v1, v2, v3, _ := instr.Args()
+	for _, arg := range [...]Value{v1, v2, v3} {
+		if arg.Valid() && !b.isLoopInvariant(arg, definedInLoop) {
+			return false
+		}
+	}
+	return true
+}
+
+// hoistLoopInvariant moves the instructions computing the value, which isLoopInvariant accepts, from the loop header
+// into the preheader. The constants defined in the loop are moved from their blocks.
+func (b *builder) hoistLoopInvariant(v Value, header, preheader *basicBlock, definedInLoop []bool, constants map[ValueID]*basicBlock) {
+	if blk, ok := constants[v.ID()]; ok {
+		b.hoistInstruction(blk, preheader, b.InstructionOfValue(v))
+		delete(constants, v.ID())
+		return
+	}
+	if !definedInLoop[v.ID()] {
+		return
+	}
+	instr := b.InstructionOfValue(v)
+	v1, v2, v3, _ := instr.Args()
+	for _, arg := range [...]Value{v1, v2, v3} {
+		if arg.Valid() {
+			b.hoistLoopInvariant(arg, header, preheader, definedInLoop, constants)
+		}
+	}
+	b.hoistInstruction(header, preheader, instr)
+	definedInLoop[v.ID()] = false
+}
+
+// resetBools returns the slice of `n` false values, reusing the given one if possible.
+func resetBools(s []bool, n int) []bool {
+	if cap(s) < n {
+		return make([]bool, n)
+	}
+	s = s[:n]
+	clear(s)
+	return s
+}
+
+// hoistInstruction moves the instruction from the block `from` to the end of `to`, before its branches.
+func (b *builder) hoistInstruction(from, to *basicBlock, instr *Instruction) {
+	from.removeInstruction(instr)
+	to.insertInstructionBeforeBranches(instr)
+}
+
+// boundsCheckKey identifies the memory bounds checks of the same address against the same memory length.
+type boundsCheckKey struct {
+	memLen, addr Value
+}
+
+// boundsCheckEntry is the bounds check of the widest access seen for a boundsCheckKey, and its block.
+type boundsCheckEntry struct {
+	ceil uint64
+	blk  *basicBlock
+}
+
+// passRedundantBoundsCheckEliminationOpt removes the memory bounds checks which are implied by a check in a dominating
+// position, i.e. `ExitIfTrue (Icmp lt_u, memLen, (Iadd addr, ceil))` with the same memLen and addr, and a higher or
+// equal ceil. The frontend already does this in the linear paths of the blocks. This removes the remaining
+// checks, for example the ones in loops once passLoopInvariantCodeMotionOpt hoists the memory length out of them.
+//
+// The blocks are visited in the reverse post-order, so passCalculateImmediateDominators must be called before this.
+func passRedundantBoundsCheckEliminationOpt(b *builder) {
+	seen := b.boundsChecks
+	defer clear(seen)
+
+	for blk := b.blockIteratorReversePostOrderBegin(); blk != nil; blk = b.blockIteratorReversePostOrderNext() {
+		for cur := blk.rootInstr; cur != nil; {
+			next := cur.next
+			if key, ceil, ok := b.memoryBoundsCheck(cur); ok {
+				if e, found := seen[key]; found && b.isDominatedBy(blk, e.blk) {
+					if ceil <= e.ceil {
+						blk.removeInstruction(cur)
+					} else {
+						seen[key] = boundsCheckEntry{ceil: ceil, blk: blk}
+					}
+				} else {
+					seen[key] = boundsCheckEntry{ceil: ceil, blk: blk}
+				}
+			}
+			cur = next
+		}
+	}
+}
+
+// memoryBoundsCheck returns the key and the ceil of the bounds check, if the instruction is one.
+func (b *builder) memoryBoundsCheck(instr *Instruction) (key boundsCheckKey, ceil uint64, ok bool) {
+	if instr.opcode != OpcodeExitIfTrueWithCode || wazevoapi.ExitCode(instr.u1) != wazevoapi.ExitCodeMemoryOutOfBounds {
+		return
+	}
+	cmp := b.InstructionOfValue(b.resolveAlias(instr.v2))
+	if cmp == nil || cmp.opcode != OpcodeIcmp || IntegerCmpCond(cmp.u1) != IntegerCmpCondUnsignedLessThan {
+		return
+	}
+	sum := b.InstructionOfValue(b.resolveAlias(cmp.v2))
+	if sum == nil || sum.opcode != OpcodeIadd {
+		return
+	}
+	ceil, ok = b.iconstOf(sum.v2)
+	if !ok {
+		return
+	}
+	key = boundsCheckKey{memLen: b.resolveAlias(cmp.v), addr: b.resolveAlias(sum.v)}
+	return
+}
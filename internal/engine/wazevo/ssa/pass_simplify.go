@@ -0,0 +1,351 @@
+package ssa
+
+import "math/bits"
+
+// passSimplificationOpt runs the constant folding and the arithmetic simplification on the integer instructions,
+// depending on which of them are enabled in builder.optimizationPasses.
+//
+// The blocks are visited in the reverse post-order, so that the arguments are simplified before their uses,
+// except for the block parameters. Hence, passCalculateImmediateDominators must be called before this.
+func passSimplificationOpt(b *builder) {
+	fold := b.optimizationPasses&OptimizationPassConstantFolding != 0
+	simplify := b.optimizationPasses&OptimizationPassArithmeticSimplification != 0
+	for blk := b.blockIteratorReversePostOrderBegin(); blk != nil; blk = b.blockIteratorReversePostOrderNext() {
+		for cur := blk.rootInstr; cur != nil; cur = cur.next {
+			if cur.pinned {
+				continue
+			}
+			if fold && b.foldConstant(cur) {
+				continue
+			}
+			if simplify {
+				b.simplifyArithmetic(cur)
+			}
+		}
+	}
+}
+
+// iconstOf returns the value of the integer constant `v`, zero-extended to 64-bit, if it is one.
+func (b *builder) iconstOf(v Value) (uint64, bool) {
+	if !v.Valid() {
+		return 0, false
+	}
+	if instr := b.InstructionOfValue(b.resolveAlias(v)); instr != nil && instr.opcode == OpcodeIconst {
+		return truncateIconst(instr.typ, instr.u1), true
+	}
+	return 0, false
+}
+
+// truncateIconst truncates `v` to the width of the integer type `t`.
+func truncateIconst(t Type, v uint64) uint64 {
+	if t == TypeI32 {
+		return uint64(uint32(v))
+	}
+	return v
+}
+
+// isScalarInt returns true if the given type is an integer type.
+func isScalarInt(t Type) bool {
+	return t == TypeI32 || t == TypeI64
+}
+
+// replaceWithIconst rewrites this instruction in place into an integer constant of its type,
+// keeping its return value so that its uses don't need to be updated.
+func (i *Instruction) replaceWithIconst(v uint64) {
+	i.v, i.v2, i.v3, i.vs = ValueInvalid, ValueInvalid, ValueInvalid, ValuesNil
+	i.u2 = 0
+	if i.typ == TypeI32 {
+		i.AsIconst32(uint32(v))
+	} else {
+		i.AsIconst64(v)
+	}
+}
+
+// foldConstant evaluates the instruction if all of its arguments are constants, and returns true if it did.
+// The trapping instructions like division are never folded as they are not pure.
+func (b *builder) foldConstant(i *Instruction) bool {
+	switch i.opcode {
+	case OpcodeIadd, OpcodeIsub, OpcodeImul, OpcodeBand, OpcodeBor, OpcodeBxor,
+		OpcodeIshl, OpcodeUshr, OpcodeSshr, OpcodeRotl, OpcodeRotr:
+		if !isScalarInt(i.typ) {
+			return false
+		}
+		x, ok := b.iconstOf(i.v)
+		if !ok {
+			return false
+		}
+		y, ok := b.iconstOf(i.v2)
+		if !ok {
+			return false
+		}
+		i.replaceWithIconst(foldBinary(i.opcode, i.typ, x, y))
+	case OpcodeIcmp:
+		x, y, c := i.IcmpData()
+		xc, ok := b.iconstOf(x)
+		if !ok {
+			return false
+		}
+		yc, ok := b.iconstOf(y)
+		if !ok {
+			return false
+		}
+		var result uint64
+		if foldIcmp(c, x.Type(), xc, yc) {
+			result = 1
+		}
+		i.replaceWithIconst(result)
+	case OpcodeClz, OpcodeCtz, OpcodePopcnt, OpcodeBnot:
+		if !isScalarInt(i.typ) {
+			return false
+		}
+		x, ok := b.iconstOf(i.v)
+		if !ok {
+			return false
+		}
+		i.replaceWithIconst(foldUnary(i.opcode, i.typ, x))
+	case OpcodeUExtend, OpcodeSExtend:
+		x, ok := b.iconstOf(i.v)
+		if !ok {
+			return false
+		}
+		from, _, signed := i.ExtendData()
+		shift := 64 - uint64(from)
+		if signed {
+			x = uint64(int64(x<<shift) >> shift)
+		} else {
+			x = x << shift >> shift
+		}
+		i.replaceWithIconst(truncateIconst(i.typ, x))
+	case OpcodeIreduce:
+		x, ok := b.iconstOf(i.v)
+		if !ok {
+			return false
+		}
+		i.replaceWithIconst(truncateIconst(i.typ, x))
+	case OpcodeSelect:
+		c, x, y := i.SelectData()
+		cc, ok := b.iconstOf(c)
+		if !ok {
+			return false
+		}
+		if cc != 0 {
+			b.alias(i.Return(), x)
+		} else {
+			b.alias(i.Return(), y)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// foldBinary evaluates the binary integer operation `op` on the constants of the type `t`.
+func foldBinary(op Opcode, t Type, x, y uint64) uint64 {
+	width := uint64(t.Bits())
+	amount := y % width
+	var ret uint64
+	switch op {
+	case OpcodeIadd:
+		ret = x + y
+	case OpcodeIsub:
+		ret = x - y
+	case OpcodeImul:
+		ret = x * y
+	case OpcodeBand:
+		ret = x & y
+	case OpcodeBor:
+		ret = x | y
+	case OpcodeBxor:
+		ret = x ^ y
+	case OpcodeIshl:
+		ret = x << amount
+	case OpcodeUshr:
+		ret = x >> amount
+	case OpcodeSshr:
+		if t == TypeI32 {
+			ret = uint64(int32(x) >> amount)
+		} else {
+			ret = uint64(int64(x) >> amount)
+		}
+	case OpcodeRotl, OpcodeRotr:
+		k := int(amount)
+		if op == OpcodeRotr {
+			k = -k
+		}
+		if t == TypeI32 {
+			ret = uint64(bits.RotateLeft32(uint32(x), k))
+		} else {
+			ret = bits.RotateLeft64(x, k)
+		}
+	default:
+		panic("BUG: unsupported opcode " + op.String())
+	}
+	return truncateIconst(t, ret)
+}
+
+// foldUnary evaluates the unary integer operation `op` on the constant of the type `t`.
+func foldUnary(op Opcode, t Type, x uint64) uint64 {
+	is32 := t == TypeI32
+	var ret uint64
+	switch op {
+	case OpcodeClz:
+		if is32 {
+			ret = uint64(bits.LeadingZeros32(uint32(x)))
+		} else {
+			ret = uint64(bits.LeadingZeros64(x))
+		}
+	case OpcodeCtz:
+		if is32 {
+			ret = uint64(bits.TrailingZeros32(uint32(x)))
+		} else {
+			ret = uint64(bits.TrailingZeros64(x))
+		}
+	case OpcodePopcnt:
+		ret = uint64(bits.OnesCount64(x)) // x is already zero-extended.
+	case OpcodeBnot:
+		ret = ^x
+	default:
+		panic("BUG: unsupported opcode " + op.String())
+	}
+	return truncateIconst(t, ret)
+}
+
+// foldIcmp evaluates the integer comparison on the constants of the type `t`.
+func foldIcmp(c IntegerCmpCond, t Type, x, y uint64) bool {
+	sx, sy := int64(x), int64(y)
+	if t == TypeI32 {
+		sx, sy = int64(int32(x)), int64(int32(y))
+	}
+	switch c {
+	case IntegerCmpCondEqual:
+		return x == y
+	case IntegerCmpCondNotEqual:
+		return x != y
+	case IntegerCmpCondSignedLessThan:
+		return sx < sy
+	case IntegerCmpCondSignedGreaterThanOrEqual:
+		return sx >= sy
+	case IntegerCmpCondSignedGreaterThan:
+		return sx > sy
+	case IntegerCmpCondSignedLessThanOrEqual:
+		return sx <= sy
+	case IntegerCmpCondUnsignedLessThan:
+		return x < y
+	case IntegerCmpCondUnsignedGreaterThanOrEqual:
+		return x >= y
+	case IntegerCmpCondUnsignedGreaterThan:
+		return x > y
+	case IntegerCmpCondUnsignedLessThanOrEqual:
+		return x <= y
+	default:
+		panic("BUG: invalid integer comparison condition")
+	}
+}
+
+// simplifyArithmetic rewrites the integer instruction with the algebraic identities, e.g. `x + 0` into `x`,
+// `x * 0` into `0` and `x - x` into `0`. The instructions which become copies of their arguments are aliased,
+// and left for passDeadCodeEliminationOpt to remove.
+func (b *builder) simplifyArithmetic(i *Instruction) {
+	switch i.opcode {
+	case OpcodeIadd, OpcodeImul, OpcodeBand, OpcodeBor, OpcodeBxor:
+		if !isScalarInt(i.typ) {
+			return
+		}
+		// Canonicalize the constant operand of the commutative operations to the right, so that
+		// the rules below and passCommonSubexpressionEliminationOpt apply regardless of the order.
+		if _, ok := b.iconstOf(i.v); ok {
+			if _, ok = b.iconstOf(i.v2); !ok {
+				i.v, i.v2 = i.v2, i.v
+			}
+		}
+	case OpcodeIsub, OpcodeIshl, OpcodeUshr, OpcodeSshr, OpcodeRotl, OpcodeRotr:
+		if !isScalarInt(i.typ) {
+			return
+		}
+	case OpcodeIcmp:
+		x, y, _ := i.IcmpData()
+		if b.resolveAlias(x) == b.resolveAlias(y) {
+			var result uint64
+			if foldIcmp(IntegerCmpCond(i.u1), x.Type(), 0, 0) { // Same as comparing any equal values.
+				result = 1
+			}
+			i.replaceWithIconst(result)
+		}
+		return
+	default:
+		return
+	}
+
+	x, y := b.resolveAlias(i.v), b.resolveAlias(i.v2)
+	c, isConst := b.iconstOf(y)
+	ones := truncateIconst(i.typ, ^uint64(0))
+	switch i.opcode {
+	case OpcodeIadd:
+		if isConst && c == 0 {
+			b.alias(i.Return(), x)
+		}
+	case OpcodeIsub:
+		if isConst && c == 0 {
+			b.alias(i.Return(), x)
+		} else if x == y {
+			i.replaceWithIconst(0)
+		}
+	case OpcodeImul:
+		if isConst && c == 1 {
+			b.alias(i.Return(), x)
+		} else if isConst && c == 0 {
+			i.replaceWithIconst(0)
+		}
+	case OpcodeBand:
+		if isConst && c == 0 {
+			i.replaceWithIconst(0)
+		} else if (isConst && c == ones) || x == y {
+			b.alias(i.Return(), x)
+		}
+	case OpcodeBor:
+		if isConst && c == ones {
+			i.replaceWithIconst(ones)
+		} else if (isConst && c == 0) || x == y {
+			b.alias(i.Return(), x)
+		}
+	case OpcodeBxor:
+		if isConst && c == 0 {
+			b.alias(i.Return(), x)
+		} else if x == y {
+			i.replaceWithIconst(0)
+		}
+	case OpcodeIshl, OpcodeUshr, OpcodeSshr, OpcodeRotl, OpcodeRotr:
+		if isConst && c%uint64(i.typ.Bits()) == 0 {
+			b.alias(i.Return(), x)
+		}
+	}
+}
+
+// passCopyPropagationOpt replaces the uses of copied values with their sources, so that the later passes
+// and backends see through the copies. The copies are the aliases, e.g. made by passRedundantPhiEliminationOpt,
+// and the instructions which return one of their arguments as is:
+//   - `select c, x, x` is `x`.
+//   - `ireduce (uextend x)` and `ireduce (sextend x)` are `x` when `x` is 32-bit.
+//
+// The blocks are visited in the reverse post-order, so passCalculateImmediateDominators must be called before this.
+func passCopyPropagationOpt(b *builder) {
+	for blk := b.blockIteratorReversePostOrderBegin(); blk != nil; blk = b.blockIteratorReversePostOrderNext() {
+		for cur := blk.rootInstr; cur != nil; cur = cur.next {
+			b.resolveArgumentAlias(cur)
+			switch cur.opcode {
+			case OpcodeSelect:
+				if _, x, y := cur.SelectData(); x == y {
+					b.alias(cur.Return(), x)
+				}
+			case OpcodeIreduce:
+				def := b.InstructionOfValue(cur.v)
+				if def == nil || (def.opcode != OpcodeUExtend && def.opcode != OpcodeSExtend) {
+					continue
+				}
+				if from, _, _ := def.ExtendData(); from == 32 && cur.typ == TypeI32 {
+					b.alias(cur.Return(), b.resolveAlias(def.v))
+				}
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package ssa
 import (
 	"testing"
 
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/wazevoapi"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 )
 
@@ -348,6 +349,529 @@ blk0: (v0:i32, v1:i64)
 	v8:i64 = Iconst_64 0x3d41
 	v9:i64 = Sshr v1, v8
 	Return v0, v1, v7, v9
+`,
+		},
+		{
+			name: "constant folding",
+			pass: func(b *builder) {
+				b.SetOptimizationPasses(OptimizationPassesAll)
+				passCalculateImmediateDominators(b)
+				passSimplificationOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry := b.AllocateBasicBlock()
+				b.SetCurrentBlock(entry)
+				i32Param := entry.AddParam(b, TypeI32)
+
+				c1 := b.AllocateInstruction().AsIconst32(0xffff_fffe).Insert(b).Return()
+				c2 := b.AllocateInstruction().AsIconst32(3).Insert(b).Return()
+				add := b.AllocateInstruction().AsIadd(c1, c2).Insert(b).Return()
+				mul := b.AllocateInstruction().AsImul(add, c2).Insert(b).Return()
+				cmp := b.AllocateInstruction().AsIcmp(c1, c2, IntegerCmpCondSignedLessThan).Insert(b).Return()
+				sel := b.AllocateInstruction().AsSelect(cmp, mul, i32Param).Insert(b).Return()
+				// Not foldable as one of the arguments is not a constant.
+				notConst := b.AllocateInstruction().AsIsub(i32Param, c2).Insert(b).Return()
+
+				ret := b.AllocateInstruction()
+				args := b.varLengthPool.Allocate(2)
+				args = args.Append(&b.varLengthPool, sel)
+				args = args.Append(&b.varLengthPool, notConst)
+				ret.AsReturn(args)
+				b.InsertInstruction(ret)
+				return nil
+			},
+			before: `
+blk0: (v0:i32)
+	v1:i32 = Iconst_32 0xfffffffe
+	v2:i32 = Iconst_32 0x3
+	v3:i32 = Iadd v1, v2
+	v4:i32 = Imul v3, v2
+	v5:i32 = Icmp lt_s, v1, v2
+	v6:i32 = Select v5, v4, v0
+	v7:i32 = Isub v0, v2
+	Return v6, v7
+`,
+			after: `
+blk0: (v0:i32)
+	v2:i32 = Iconst_32 0x3
+	v4:i32 = Iconst_32 0x3
+	v7:i32 = Isub v0, v2
+	Return v4, v7
+`,
+		},
+		{
+			name: "arithmetic simplification",
+			pass: func(b *builder) {
+				b.SetOptimizationPasses(OptimizationPassesAll)
+				passCalculateImmediateDominators(b)
+				passSimplificationOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry := b.AllocateBasicBlock()
+				b.SetCurrentBlock(entry)
+				i64Param := entry.AddParam(b, TypeI64)
+
+				zero := b.AllocateInstruction().AsIconst64(0).Insert(b).Return()
+				one := b.AllocateInstruction().AsIconst64(1).Insert(b).Return()
+				add := b.AllocateInstruction().AsIadd(zero, i64Param).Insert(b).Return()
+				mul := b.AllocateInstruction().AsImul(add, one).Insert(b).Return()
+				sub := b.AllocateInstruction().AsIsub(mul, i64Param).Insert(b).Return()
+				band := b.AllocateInstruction().AsBand(i64Param, zero).Insert(b).Return()
+
+				ret := b.AllocateInstruction()
+				args := b.varLengthPool.Allocate(3)
+				args = args.Append(&b.varLengthPool, mul)
+				args = args.Append(&b.varLengthPool, sub)
+				args = args.Append(&b.varLengthPool, band)
+				ret.AsReturn(args)
+				b.InsertInstruction(ret)
+				return nil
+			},
+			before: `
+blk0: (v0:i64)
+	v1:i64 = Iconst_64 0x0
+	v2:i64 = Iconst_64 0x1
+	v3:i64 = Iadd v1, v0
+	v4:i64 = Imul v3, v2
+	v5:i64 = Isub v4, v0
+	v6:i64 = Band v0, v1
+	Return v4, v5, v6
+`,
+			after: `
+blk0: (v0:i64)
+	v5:i64 = Iconst_64 0x0
+	v6:i64 = Iconst_64 0x0
+	Return v0, v5, v6
+`,
+		},
+		{
+			name: "pinned icmp",
+			pass: func(b *builder) {
+				b.SetOptimizationPasses(OptimizationPassesAll)
+				passCalculateImmediateDominators(b)
+				passSimplificationOpt(b)
+				passCommonSubexpressionEliminationOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry := b.AllocateBasicBlock()
+				b.SetCurrentBlock(entry)
+				ctx := entry.AddParam(b, TypeI64)
+				i32Param := entry.AddParam(b, TypeI32)
+
+				// The Icmp of ExitIfTrueWithCode is neither folded, simplified nor merged.
+				cmp1 := b.AllocateInstruction().AsIcmp(i32Param, i32Param, IntegerCmpCondEqual).Insert(b).Return()
+				b.AllocateInstruction().AsExitIfTrueWithCode(ctx, cmp1, 0).Insert(b)
+				cmp2 := b.AllocateInstruction().AsIcmp(i32Param, i32Param, IntegerCmpCondEqual).Insert(b).Return()
+				b.AllocateInstruction().AsExitIfTrueWithCode(ctx, cmp2, 0).Insert(b)
+
+				b.AllocateInstruction().AsReturn(ValuesNil).Insert(b)
+				return nil
+			},
+			before: `
+blk0: (v0:i64, v1:i32)
+	v2:i32 = Icmp eq, v1, v1
+	ExitIfTrue v2, v0, ok
+	v3:i32 = Icmp eq, v1, v1
+	ExitIfTrue v3, v0, ok
+	Return
+`,
+			after: `
+blk0: (v0:i64, v1:i32)
+	v2:i32 = Icmp eq, v1, v1
+	ExitIfTrue v2, v0, ok
+	v3:i32 = Icmp eq, v1, v1
+	ExitIfTrue v3, v0, ok
+	Return
+`,
+		},
+		{
+			name: "copy propagation",
+			pass: func(b *builder) {
+				passCalculateImmediateDominators(b)
+				passCopyPropagationOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry := b.AllocateBasicBlock()
+				b.SetCurrentBlock(entry)
+				i32Param := entry.AddParam(b, TypeI32)
+				cond := entry.AddParam(b, TypeI32)
+
+				sel := b.AllocateInstruction().AsSelect(cond, i32Param, i32Param).Insert(b).Return()
+				ext := b.AllocateInstruction().AsUExtend(sel, 32, 64).Insert(b).Return()
+				reduce := b.AllocateInstruction().AsIreduce(ext, TypeI32).Insert(b).Return()
+				add := b.AllocateInstruction().AsIadd(reduce, sel).Insert(b).Return()
+
+				ret := b.AllocateInstruction()
+				args := b.varLengthPool.Allocate(1)
+				args = args.Append(&b.varLengthPool, add)
+				ret.AsReturn(args)
+				b.InsertInstruction(ret)
+				return nil
+			},
+			before: `
+blk0: (v0:i32, v1:i32)
+	v2:i32 = Select v1, v0, v0
+	v3:i64 = UExtend v2, 32->64
+	v4:i32 = Ireduce v3
+	v5:i32 = Iadd v4, v2
+	Return v5
+`,
+			after: `
+blk0: (v0:i32, v1:i32)
+	v5:i32 = Iadd v0, v0
+	Return v5
+`,
+		},
+		{
+			name: "common subexpression elimination",
+			pass: func(b *builder) {
+				passCalculateImmediateDominators(b)
+				passCommonSubexpressionEliminationOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry, then, els, end := b.AllocateBasicBlock(), b.AllocateBasicBlock(), b.AllocateBasicBlock(), b.AllocateBasicBlock()
+
+				b.SetCurrentBlock(entry)
+				x := entry.AddParam(b, TypeI32)
+				y := entry.AddParam(b, TypeI32)
+				add := b.AllocateInstruction().AsIadd(x, y).Insert(b).Return()
+				brz := b.AllocateInstruction()
+				brz.AsBrz(add, ValuesNil, els)
+				b.InsertInstruction(brz)
+				b.AllocateInstruction().AsJump(ValuesNil, then).Insert(b)
+
+				// Dominated by entry, so the addition is reused.
+				b.SetCurrentBlock(then)
+				addThen := b.AllocateInstruction().AsIadd(x, y).Insert(b).Return()
+				mulThen := b.AllocateInstruction().AsImul(x, y).Insert(b).Return()
+				b.AllocateInstruction().AsJump(b.varLengthPool.Allocate(2).Append(&b.varLengthPool, addThen, mulThen), end).Insert(b)
+
+				// Not dominated by then, so the multiplication is kept.
+				b.SetCurrentBlock(els)
+				mulEls := b.AllocateInstruction().AsImul(x, y).Insert(b).Return()
+				b.AllocateInstruction().AsJump(b.varLengthPool.Allocate(2).Append(&b.varLengthPool, add, mulEls), end).Insert(b)
+
+				b.SetCurrentBlock(end)
+				p1, p2 := end.AddParam(b, TypeI32), end.AddParam(b, TypeI32)
+				ret := b.AllocateInstruction()
+				args := b.varLengthPool.Allocate(2)
+				args = args.Append(&b.varLengthPool, p1)
+				args = args.Append(&b.varLengthPool, p2)
+				ret.AsReturn(args)
+				b.InsertInstruction(ret)
+
+				b.Seal(entry)
+				b.Seal(then)
+				b.Seal(els)
+				b.Seal(end)
+				return nil
+			},
+			before: `
+blk0: (v0:i32, v1:i32)
+	v2:i32 = Iadd v0, v1
+	Brz v2, blk2
+	Jump blk1
+
+blk1: () <-- (blk0)
+	v3:i32 = Iadd v0, v1
+	v4:i32 = Imul v0, v1
+	Jump blk3, v3, v4
+
+blk2: () <-- (blk0)
+	v5:i32 = Imul v0, v1
+	Jump blk3, v2, v5
+
+blk3: (v6:i32,v7:i32) <-- (blk1,blk2)
+	Return v6, v7
+`,
+			after: `
+blk0: (v0:i32, v1:i32)
+	v2:i32 = Iadd v0, v1
+	Brz v2, blk2
+	Jump blk1
+
+blk1: () <-- (blk0)
+	v4:i32 = Imul v0, v1
+	Jump blk3, v2, v4
+
+blk2: () <-- (blk0)
+	v5:i32 = Imul v0, v1
+	Jump blk3, v2, v5
+
+blk3: (v6:i32,v7:i32) <-- (blk1,blk2)
+	Return v6, v7
+`,
+		},
+		{
+			name: "loop invariant code motion",
+			pass: func(b *builder) {
+				passCalculateImmediateDominators(b)
+				passLoopInvariantCodeMotionOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry, header, exit := b.AllocateBasicBlock(), b.AllocateBasicBlock(), b.AllocateBasicBlock()
+
+				b.SetCurrentBlock(entry)
+				ctx := entry.AddParam(b, TypeI64)
+				x := entry.AddParam(b, TypeI32)
+				y := entry.AddParam(b, TypeI32)
+				n := entry.AddParam(b, TypeI32)
+				b.AllocateInstruction().AsJump(b.varLengthPool.Allocate(1).Append(&b.varLengthPool, n), header).Insert(b)
+
+				b.SetCurrentBlock(header)
+				i := header.AddParam(b, TypeI32)
+				boundsCheck := func(addr Value, ceil uint64) Value {
+					memLen := b.AllocateInstruction().AsExtLoad(OpcodeUload32, ctx, 0x10, true).MarkInvariantUntilCall().Insert(b).Return()
+					ext := b.AllocateInstruction().AsUExtend(addr, 32, 64).Insert(b).Return()
+					c := b.AllocateInstruction().AsIconst64(ceil).Insert(b).Return()
+					sum := b.AllocateInstruction().AsIadd(ext, c).Insert(b).Return()
+					cmp := b.AllocateInstruction().AsIcmp(memLen, sum, IntegerCmpCondUnsignedLessThan).Insert(b).Return()
+					b.AllocateInstruction().AsExitIfTrueWithCode(ctx, cmp, wazevoapi.ExitCodeMemoryOutOfBounds).Insert(b)
+					return ext
+				}
+				// The bounds check at the beginning of the header is hoisted along with the computation of the address.
+				// All the loads of the memory base and length are hoisted and merged.
+				ext := boundsCheck(x, 4)
+				base := b.AllocateInstruction().AsLoad(ctx, 0x8, TypeI64).MarkInvariantUntilCall().Insert(b).Return()
+				addr := b.AllocateInstruction().AsIadd(base, ext).Insert(b).Return()
+				b.AllocateInstruction().AsStore(OpcodeStore, i, addr, 0).Insert(b)
+				// The bounds check after the store stays, as the store must be done before it traps.
+				ext2 := boundsCheck(y, 4)
+				base2 := b.AllocateInstruction().AsLoad(ctx, 0x8, TypeI64).MarkInvariantUntilCall().Insert(b).Return()
+				addr2 := b.AllocateInstruction().AsIadd(base2, ext2).Insert(b).Return()
+				// The other pure instructions stay in the loop, even if their arguments are defined out of the loop.
+				sum := b.AllocateInstruction().AsIadd(x, y).Insert(b).Return()
+				b.AllocateInstruction().AsStore(OpcodeStore, sum, addr2, 0).Insert(b)
+				one := b.AllocateInstruction().AsIconst32(1).Insert(b).Return()
+				dec := b.AllocateInstruction().AsIsub(i, one).Insert(b).Return()
+				b.AllocateInstruction().AsBrnz(dec, b.varLengthPool.Allocate(1).Append(&b.varLengthPool, dec), header).Insert(b)
+				b.AllocateInstruction().AsJump(ValuesNil, exit).Insert(b)
+
+				b.SetCurrentBlock(exit)
+				b.AllocateInstruction().AsReturn(ValuesNil).Insert(b)
+
+				b.Seal(entry)
+				b.Seal(header)
+				b.Seal(exit)
+				return nil
+			},
+			before: `
+blk0: (v0:i64, v1:i32, v2:i32, v3:i32)
+	Jump blk1, v3
+
+blk1: (v4:i32) <-- (blk0,blk1)
+	v5:i64 = Uload32 v0, 0x10
+	v6:i64 = UExtend v1, 32->64
+	v7:i64 = Iconst_64 0x4
+	v8:i64 = Iadd v6, v7
+	v9:i32 = Icmp lt_u, v5, v8
+	ExitIfTrue v9, v0, memory_out_of_bounds
+	v10:i64 = Load v0, 0x8
+	v11:i64 = Iadd v10, v6
+	Store v4, v11, 0x0
+	v12:i64 = Uload32 v0, 0x10
+	v13:i64 = UExtend v2, 32->64
+	v14:i64 = Iconst_64 0x4
+	v15:i64 = Iadd v13, v14
+	v16:i32 = Icmp lt_u, v12, v15
+	ExitIfTrue v16, v0, memory_out_of_bounds
+	v17:i64 = Load v0, 0x8
+	v18:i64 = Iadd v17, v13
+	v19:i32 = Iadd v1, v2
+	Store v19, v18, 0x0
+	v20:i32 = Iconst_32 0x1
+	v21:i32 = Isub v4, v20
+	Brnz v21, blk1, v21
+	Jump blk2
+
+blk2: () <-- (blk1)
+	Return
+`,
+			after: `
+blk0: (v0:i64, v1:i32, v2:i32, v3:i32)
+	v5:i64 = Uload32 v0, 0x10
+	v10:i64 = Load v0, 0x8
+	v6:i64 = UExtend v1, 32->64
+	v7:i64 = Iconst_64 0x4
+	v8:i64 = Iadd v6, v7
+	v9:i32 = Icmp lt_u, v5, v8
+	ExitIfTrue v9, v0, memory_out_of_bounds
+	Jump blk1, v3
+
+blk1: (v4:i32) <-- (blk0,blk1)
+	v11:i64 = Iadd v10, v6
+	Store v4, v11, 0x0
+	v13:i64 = UExtend v2, 32->64
+	v14:i64 = Iconst_64 0x4
+	v15:i64 = Iadd v13, v14
+	v16:i32 = Icmp lt_u, v5, v15
+	ExitIfTrue v16, v0, memory_out_of_bounds
+	v18:i64 = Iadd v10, v13
+	v19:i32 = Iadd v1, v2
+	Store v19, v18, 0x0
+	v20:i32 = Iconst_32 0x1
+	v21:i32 = Isub v4, v20
+	Brnz v21, blk1, v21
+	Jump blk2
+
+blk2: () <-- (blk1)
+	Return
+`,
+		},
+		{
+			name: "loop invariant code motion / call",
+			pass: func(b *builder) {
+				passCalculateImmediateDominators(b)
+				passLoopInvariantCodeMotionOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				sig := &Signature{ID: 0}
+				b.DeclareSignature(sig)
+				entry, header, exit := b.AllocateBasicBlock(), b.AllocateBasicBlock(), b.AllocateBasicBlock()
+
+				b.SetCurrentBlock(entry)
+				ctx := entry.AddParam(b, TypeI64)
+				x := entry.AddParam(b, TypeI32)
+				y := entry.AddParam(b, TypeI32)
+				n := entry.AddParam(b, TypeI32)
+				b.AllocateInstruction().AsJump(b.varLengthPool.Allocate(1).Append(&b.varLengthPool, n), header).Insert(b)
+
+				b.SetCurrentBlock(header)
+				i := header.AddParam(b, TypeI32)
+				// The call may change the loaded value, e.g. by growing the memory, so the load stays in the loop.
+				call := b.AllocateInstruction()
+				call.AsCall(0, sig, ValuesNil)
+				b.InsertInstruction(call)
+				base := b.AllocateInstruction().AsLoad(ctx, 0x8, TypeI64).MarkInvariantUntilCall().Insert(b).Return()
+				sum := b.AllocateInstruction().AsIadd(x, y).Insert(b).Return()
+				b.AllocateInstruction().AsStore(OpcodeStore, sum, base, 0).Insert(b)
+				one := b.AllocateInstruction().AsIconst32(1).Insert(b).Return()
+				dec := b.AllocateInstruction().AsIsub(i, one).Insert(b).Return()
+				b.AllocateInstruction().AsBrnz(dec, b.varLengthPool.Allocate(1).Append(&b.varLengthPool, dec), header).Insert(b)
+				b.AllocateInstruction().AsJump(ValuesNil, exit).Insert(b)
+
+				b.SetCurrentBlock(exit)
+				b.AllocateInstruction().AsReturn(ValuesNil).Insert(b)
+
+				b.Seal(entry)
+				b.Seal(header)
+				b.Seal(exit)
+				return nil
+			},
+			before: `
+signatures:
+	sig0: v_v
+
+blk0: (v0:i64, v1:i32, v2:i32, v3:i32)
+	Jump blk1, v3
+
+blk1: (v4:i32) <-- (blk0,blk1)
+	Call f0:sig0, 
+	v5:i64 = Load v0, 0x8
+	v6:i32 = Iadd v1, v2
+	Store v6, v5, 0x0
+	v7:i32 = Iconst_32 0x1
+	v8:i32 = Isub v4, v7
+	Brnz v8, blk1, v8
+	Jump blk2
+
+blk2: () <-- (blk1)
+	Return
+`,
+			after: `
+signatures:
+	sig0: v_v
+
+blk0: (v0:i64, v1:i32, v2:i32, v3:i32)
+	Jump blk1, v3
+
+blk1: (v4:i32) <-- (blk0,blk1)
+	Call f0:sig0, 
+	v5:i64 = Load v0, 0x8
+	v6:i32 = Iadd v1, v2
+	Store v6, v5, 0x0
+	v7:i32 = Iconst_32 0x1
+	v8:i32 = Isub v4, v7
+	Brnz v8, blk1, v8
+	Jump blk2
+
+blk2: () <-- (blk1)
+	Return
+`,
+		},
+		{
+			name: "redundant bounds check elimination",
+			pass: func(b *builder) {
+				passCalculateImmediateDominators(b)
+				passRedundantBoundsCheckEliminationOpt(b)
+			},
+			postPass: passDeadCodeEliminationOpt,
+			setup: func(b *builder) (verifier func(t *testing.T)) {
+				entry, then := b.AllocateBasicBlock(), b.AllocateBasicBlock()
+
+				b.SetCurrentBlock(entry)
+				ctx := entry.AddParam(b, TypeI64)
+				memLen := entry.AddParam(b, TypeI64)
+				addr := entry.AddParam(b, TypeI64)
+				boundsCheck := func(ceil uint64) {
+					c := b.AllocateInstruction().AsIconst64(ceil).Insert(b).Return()
+					sum := b.AllocateInstruction().AsIadd(addr, c).Insert(b).Return()
+					cmp := b.AllocateInstruction().AsIcmp(memLen, sum, IntegerCmpCondUnsignedLessThan).Insert(b).Return()
+					b.AllocateInstruction().AsExitIfTrueWithCode(ctx, cmp, wazevoapi.ExitCodeMemoryOutOfBounds).Insert(b)
+				}
+				boundsCheck(8)
+				b.AllocateInstruction().AsJump(ValuesNil, then).Insert(b)
+
+				// Dominated by the check of 8 bytes, so only the check of 16 bytes is kept.
+				b.SetCurrentBlock(then)
+				boundsCheck(4)
+				boundsCheck(16)
+				b.AllocateInstruction().AsReturn(ValuesNil).Insert(b)
+
+				b.Seal(entry)
+				b.Seal(then)
+				return nil
+			},
+			before: `
+blk0: (v0:i64, v1:i64, v2:i64)
+	v3:i64 = Iconst_64 0x8
+	v4:i64 = Iadd v2, v3
+	v5:i32 = Icmp lt_u, v1, v4
+	ExitIfTrue v5, v0, memory_out_of_bounds
+	Jump blk1
+
+blk1: () <-- (blk0)
+	v6:i64 = Iconst_64 0x4
+	v7:i64 = Iadd v2, v6
+	v8:i32 = Icmp lt_u, v1, v7
+	ExitIfTrue v8, v0, memory_out_of_bounds
+	v9:i64 = Iconst_64 0x10
+	v10:i64 = Iadd v2, v9
+	v11:i32 = Icmp lt_u, v1, v10
+	ExitIfTrue v11, v0, memory_out_of_bounds
+	Return
+`,
+			after: `
+blk0: (v0:i64, v1:i64, v2:i64)
+	v3:i64 = Iconst_64 0x8
+	v4:i64 = Iadd v2, v3
+	v5:i32 = Icmp lt_u, v1, v4
+	ExitIfTrue v5, v0, memory_out_of_bounds
+	Jump blk1
+
+blk1: () <-- (blk0)
+	v9:i64 = Iconst_64 0x10
+	v10:i64 = Iadd v2, v9
+	v11:i32 = Icmp lt_u, v1, v10
+	ExitIfTrue v11, v0, memory_out_of_bounds
+	Return
 `,
 		},
 	} {
@@ -1373,7 +1373,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0, // offset
@@ -1392,7 +1392,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i64, f32, f64}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0, // offset
@@ -1444,7 +1444,7 @@ var (
 				Results: []wasm.ValueType{i32},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0,
@@ -1460,7 +1460,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Results: []wasm.ValueType{i32, i32, i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 2, IsMaxEncoded: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 2, IsMaxEncoded: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 1,
@@ -1478,7 +1478,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{i32_i32, {}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0, 1},
 			CodeSection: []wasm.Code{
 				{Body: []byte{
@@ -1502,6 +1502,43 @@ var (
 		},
 	}
 
+	MemoryLoadInLoop = TestCase{
+		Name: "memory_load_in_loop",
+		Module: &wasm.Module{
+			TypeSection: []wasm.FunctionType{{
+				Params:  []wasm.ValueType{i32, i32},
+				Results: []wasm.ValueType{i32},
+			}},
+			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
+			MemorySection:   []wasm.Memory{{Min: 1}},
+			FunctionSection: []wasm.Index{0},
+			CodeSection: []wasm.Code{{
+				LocalTypes: []wasm.ValueType{i32},
+				Body: []byte{
+					// The first load is checked before the loop.
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeI32Load, 0x2, 0x0,
+					wasm.OpcodeLocalSet, 2,
+					wasm.OpcodeLoop, blockSignature_vv,
+					// The same load is repeated n times in the loop.
+					wasm.OpcodeLocalGet, 2,
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeI32Load, 0x2, 0x0,
+					wasm.OpcodeI32Add,
+					wasm.OpcodeLocalSet, 2,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeI32Const, 1,
+					wasm.OpcodeI32Sub,
+					wasm.OpcodeLocalTee, 1,
+					wasm.OpcodeBrIf, 0,
+					wasm.OpcodeEnd,
+					wasm.OpcodeLocalGet, 2,
+					wasm.OpcodeEnd,
+				},
+			}},
+			DataSection: []wasm.DataSegment{{OffsetExpression: constExprI32(0), Init: maskedBuf(int(wasm.MemoryPageSize))}},
+		},
+	}
 	ImportedMemoryGrow = TestCase{
 		Name: "imported_memory_grow",
 		Imported: &wasm.Module{
@@ -1509,7 +1546,7 @@ var (
 				{Name: "mem", Type: wasm.ExternTypeMemory, Index: 0},
 				{Name: "size", Type: wasm.ExternTypeFunc, Index: 0},
 			},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			TypeSection:     []wasm.FunctionType{v_i32},
 			FunctionSection: []wasm.Index{0},
 			CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeMemorySize, 0, wasm.OpcodeEnd}}},
@@ -1701,7 +1738,7 @@ var (
 				},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				// Basic loads (without extensions).
@@ -1895,7 +1932,7 @@ var (
 			},
 
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 4554},
+			MemorySection:   []wasm.Memory{{Min: 4554}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeBlock, 1, // Signature v_i64,
@@ -1946,7 +1983,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32, i64}, Results: []wasm.ValueType{i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0,
@@ -1963,7 +2000,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i64, i64}, Results: []wasm.ValueType{i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0,
@@ -1980,7 +2017,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0,
@@ -1999,7 +2036,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2036,7 +2073,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2073,7 +2110,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2110,7 +2147,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2147,7 +2184,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2184,7 +2221,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2221,7 +2258,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2272,7 +2309,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2316,7 +2353,7 @@ var (
 				Results: []wasm.ValueType{i32, i32, i32, i64, i64, i64, i64},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0,
@@ -2360,7 +2397,7 @@ var (
 				Results: []wasm.ValueType{},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicFence, 0,
@@ -3096,7 +3133,7 @@ var LargeMethodBodyWithManyArgs = TestCase{
 	Module: &wasm.Module{
 		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32, i32, i32, i32, i32, i32, i32, i32}, Results: []wasm.ValueType{i32}}},
 		ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-		MemorySection:   &wasm.Memory{Min: 1},
+		MemorySection:   []wasm.Memory{{Min: 1}},
 		FunctionSection: []wasm.Index{0},
 		CodeSection: []wasm.Code{{
 			Body: func() []byte {
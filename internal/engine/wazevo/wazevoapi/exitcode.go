@@ -49,6 +49,8 @@ const (
 	// ExitCodeTryTableLeave is an exit code for leaving a try_table block.
 	// The dispatch loop pops the most recent try handler.
 	ExitCodeTryTableLeave
+	// ExitCodeFuelExhausted is an exit code for the exhaustion of the fuel when fuel metering is enabled.
+	ExitCodeFuelExhausted
 	exitCodeMax
 )
 
@@ -115,6 +117,8 @@ func (e ExitCode) String() string {
 		return "try_table_enter"
 	case ExitCodeTryTableLeave:
 		return "try_table_leave"
+	case ExitCodeFuelExhausted:
+		return "fuel_exhausted"
 	}
 	panic("TODO")
 }
@@ -74,6 +74,8 @@ const (
 	// where locals are mirrored inside try_table bodies, so that handler blocks
 	// can read throw-time local values after stack-clone restore.
 	ExecutionContextOffsetLocalsSaveAreaPtr Offset = 1240
+	// ExecutionContextOffsetFuel holds the fuel left when fuel metering is enabled.
+	ExecutionContextOffsetFuel Offset = 1248
 )
 
 // ModuleContextOffsetData allows the compilers to get the information about offsets to the fields of wazevo.moduleContextOpaque,
@@ -88,6 +90,7 @@ type ModuleContextOffsetData struct {
 	TypeIDs1stElement,
 	TablesBegin,
 	TagsBegin,
+	MemoriesBegin,
 	BeforeListenerTrampolines1stElement,
 	AfterListenerTrampolines1stElement,
 	DataInstances1stElement,
@@ -96,6 +99,9 @@ type ModuleContextOffsetData struct {
 
 // ImportedFunctionOffset returns an offset of the i-th imported function.
 // Each item is stored as wazevo.functionInstance whose size matches FunctionInstanceSize.
+//
+// With NewSharedStateModuleContextOffsetData, this is valid for any function, and only the executableOffset is used,
+// which holds the function reference.
 func (m *ModuleContextOffsetData) ImportedFunctionOffset(i wasm.Index) (
 	executableOffset, moduleCtxOffset, typeIDOffset Offset,
 ) {
@@ -144,6 +150,12 @@ func (m *ModuleContextOffsetData) TableOffset(tableIndex int) Offset {
 	return m.TablesBegin + Offset(tableIndex)*8
 }
 
+// MemoryInstanceOffset returns an offset of the i-th memory instance pointer.
+// This is only valid when the module has more than one memory.
+func (m *ModuleContextOffsetData) MemoryInstanceOffset(memoryIndex int) Offset {
+	return m.MemoriesBegin + Offset(memoryIndex)*8
+}
+
 // TagOffset returns an offset of the i-th tag instance pointer.
 func (m *ModuleContextOffsetData) TagOffset(tagIndex int) Offset {
 	return m.TagsBegin + Offset(tagIndex)*8
@@ -152,13 +164,24 @@ func (m *ModuleContextOffsetData) TagOffset(tagIndex int) Offset {
 // NewModuleContextOffsetData creates a ModuleContextOffsetData determining the structure of moduleContextOpaque for the given Module.
 // The structure is described in the comment of wazevo.moduleContextOpaque.
 func NewModuleContextOffsetData(m *wasm.Module, withListener bool) ModuleContextOffsetData {
+	return newModuleContextOffsetData(m, withListener, int(m.ImportFunctionCount))
+}
+
+// NewSharedStateModuleContextOffsetData is the same as NewModuleContextOffsetData, except that all the functions of
+// the module, including the local ones, are laid out as the imported ones, as the code compiled with the shared state
+// calls all of them via their function references. See ImportedFunctionOffset.
+func NewSharedStateModuleContextOffsetData(m *wasm.Module) ModuleContextOffsetData {
+	return newModuleContextOffsetData(m, false, int(m.ImportFunctionCount)+len(m.FunctionSection))
+}
+
+func newModuleContextOffsetData(m *wasm.Module, withListener bool, functions int) ModuleContextOffsetData {
 	ret := ModuleContextOffsetData{}
 	var offset Offset
 
 	ret.ModuleInstanceOffset = 0
 	offset += 8
 
-	if m.MemorySection != nil {
+	if m.ImportMemoryCount == 0 && len(m.MemorySection) > 0 {
 		ret.LocalMemoryBegin = offset
 		// buffer base + memory size.
 		const localMemorySizeInOpaqueModuleContext = 16
@@ -179,11 +202,11 @@ func NewModuleContextOffsetData(m *wasm.Module, withListener bool) ModuleContext
 		ret.ImportedMemoryBegin = -1
 	}
 
-	if m.ImportFunctionCount > 0 {
+	if functions > 0 {
 		offset = align8(offset)
 		ret.ImportedFunctionsBegin = offset
 		// Each function is stored wazevo.functionInstance.
-		size := int(m.ImportFunctionCount) * FunctionInstanceSize
+		size := functions * FunctionInstanceSize
 		offset += Offset(size)
 	} else {
 		ret.ImportedFunctionsBegin = -1
@@ -221,6 +244,15 @@ func NewModuleContextOffsetData(m *wasm.Module, withListener bool) ModuleContext
 		ret.TagsBegin = -1
 	}
 
+	if memories := int(m.ImportMemoryCount) + len(m.MemorySection); memories > 1 {
+		offset = align8(offset)
+		ret.MemoriesBegin = offset
+		// Pointers to *wasm.MemoryInstance.
+		offset += Offset(memories) * 8
+	} else {
+		ret.MemoriesBegin = -1
+	}
+
 	if withListener {
 		offset = align8(offset)
 		ret.BeforeListenerTrampolines1stElement = offset
@@ -25,6 +25,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				TypeIDs1stElement:                   -1,
 				TablesBegin:                         -1,
 				TagsBegin:                           -1,
+				MemoriesBegin:                       -1,
 				BeforeListenerTrampolines1stElement: -1,
 				AfterListenerTrampolines1stElement:  -1,
 				DataInstances1stElement:             8,
@@ -34,7 +35,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 		},
 		{
 			name: "local mem",
-			m:    &wasm.Module{MemorySection: &wasm.Memory{}},
+			m:    &wasm.Module{MemorySection: []wasm.Memory{{}}},
 			exp: ModuleContextOffsetData{
 				LocalMemoryBegin:                    8,
 				ImportedMemoryBegin:                 -1,
@@ -43,6 +44,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				TypeIDs1stElement:                   -1,
 				TablesBegin:                         -1,
 				TagsBegin:                           -1,
+				MemoriesBegin:                       -1,
 				BeforeListenerTrampolines1stElement: -1,
 				AfterListenerTrampolines1stElement:  -1,
 				DataInstances1stElement:             24,
@@ -61,6 +63,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				TypeIDs1stElement:                   -1,
 				TablesBegin:                         -1,
 				TagsBegin:                           -1,
+				MemoriesBegin:                       -1,
 				BeforeListenerTrampolines1stElement: -1,
 				AfterListenerTrampolines1stElement:  -1,
 				DataInstances1stElement:             24,
@@ -79,6 +82,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				TypeIDs1stElement:                   -1,
 				TablesBegin:                         -1,
 				TagsBegin:                           -1,
+				MemoriesBegin:                       -1,
 				BeforeListenerTrampolines1stElement: -1,
 				AfterListenerTrampolines1stElement:  -1,
 				DataInstances1stElement:             10*FunctionInstanceSize + 8,
@@ -97,6 +101,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				TypeIDs1stElement:                   -1,
 				TablesBegin:                         -1,
 				TagsBegin:                           -1,
+				MemoriesBegin:                       -1,
 				BeforeListenerTrampolines1stElement: -1,
 				AfterListenerTrampolines1stElement:  -1,
 				DataInstances1stElement:             10*FunctionInstanceSize + 24,
@@ -111,7 +116,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				ImportFunctionCount: 10,
 				ImportTableCount:    5,
 				TableSection:        make([]wasm.Table, 10),
-				MemorySection:       &wasm.Memory{},
+				MemorySection:       []wasm.Memory{{}},
 				GlobalSection:       make([]wasm.Global, 20),
 			},
 			exp: ModuleContextOffsetData{
@@ -123,6 +128,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				TypeIDs1stElement:                   32 + 10*FunctionInstanceSize + 16*30,
 				TablesBegin:                         32 + 10*FunctionInstanceSize + 16*30 + 8,
 				TagsBegin:                           -1,
+				MemoriesBegin:                       -1,
 				BeforeListenerTrampolines1stElement: -1,
 				AfterListenerTrampolines1stElement:  -1,
 				DataInstances1stElement:             32 + 10*FunctionInstanceSize + 16*30 + 8 + 8*15,
@@ -137,7 +143,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				ImportFunctionCount: 10,
 				ImportTableCount:    5,
 				TableSection:        make([]wasm.Table, 10),
-				MemorySection:       &wasm.Memory{},
+				MemorySection:       []wasm.Memory{{}},
 				GlobalSection:       make([]wasm.Global, 20),
 			},
 			withListener: true,
@@ -150,6 +156,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				TypeIDs1stElement:                   32 + 10*FunctionInstanceSize + 16*30,
 				TablesBegin:                         32 + 10*FunctionInstanceSize + 16*30 + 8,
 				TagsBegin:                           -1,
+				MemoriesBegin:                       -1,
 				BeforeListenerTrampolines1stElement: 32 + 10*FunctionInstanceSize + 16*30 + 8 + 8*15,
 				AfterListenerTrampolines1stElement:  32 + 10*FunctionInstanceSize + 16*30 + 8 + 8*15 + 8,
 				DataInstances1stElement:             32 + 10*FunctionInstanceSize + 16*30 + 8 + 8*15 + 16,
@@ -157,6 +164,28 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				TotalSize:                           32 + 10*FunctionInstanceSize + 16*30 + 8 + 8*15 + 32,
 			},
 		},
+		{
+			name: "imported mem / multiple local mems",
+			m: &wasm.Module{
+				ImportMemoryCount: 1,
+				MemorySection:     []wasm.Memory{{}, {}},
+			},
+			exp: ModuleContextOffsetData{
+				LocalMemoryBegin:                    -1,
+				ImportedMemoryBegin:                 8,
+				ImportedFunctionsBegin:              -1,
+				GlobalsBegin:                        -1,
+				TypeIDs1stElement:                   -1,
+				TablesBegin:                         -1,
+				TagsBegin:                           -1,
+				MemoriesBegin:                       24,
+				BeforeListenerTrampolines1stElement: -1,
+				AfterListenerTrampolines1stElement:  -1,
+				DataInstances1stElement:             24 + 8*3,
+				ElementInstances1stElement:          24 + 8*3 + 8,
+				TotalSize:                           int(align16(24 + 8*3 + 16)),
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			got := NewModuleContextOffsetData(tc.m, tc.withListener)
@@ -164,3 +193,31 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSharedStateModuleContextOffsetData(t *testing.T) {
+	m := &wasm.Module{
+		ImportFunctionCount: 2,
+		FunctionSection:     []wasm.Index{0, 0, 0},
+		GlobalSection:       []wasm.Global{{}},
+	}
+	got := NewSharedStateModuleContextOffsetData(m)
+	require.Equal(t, ModuleContextOffsetData{
+		LocalMemoryBegin:                    -1,
+		ImportedMemoryBegin:                 -1,
+		ImportedFunctionsBegin:              8,
+		GlobalsBegin:                        8 + FunctionInstanceSize*5,
+		TypeIDs1stElement:                   -1,
+		TablesBegin:                         -1,
+		TagsBegin:                           -1,
+		MemoriesBegin:                       -1,
+		BeforeListenerTrampolines1stElement: -1,
+		AfterListenerTrampolines1stElement:  -1,
+		DataInstances1stElement:             8 + FunctionInstanceSize*5 + 16,
+		ElementInstances1stElement:          8 + FunctionInstanceSize*5 + 16 + 8,
+		TotalSize:                           160,
+	}, got)
+
+	// All the functions, including the local ones, are laid out as the imported ones.
+	executableOffset, _, _ := got.ImportedFunctionOffset(4)
+	require.Equal(t, Offset(8+FunctionInstanceSize*4), executableOffset)
+}
@@ -0,0 +1,5 @@
+package expctxkeys
+
+// ExternResolverKey is a context.Context Value key.
+// Its associated value should be a wasm.ExternResolver.
+type ExternResolverKey struct{}
@@ -0,0 +1,10 @@
+package expctxkeys
+
+// FuelKey is a context.Context Value key. Its associated value should be the
+// uint64 fuel budget of each function invocation made with the context.
+type FuelKey struct{}
+
+// FuelMeterKey is a context key to access the fuel of the function invocation
+// in progress from a host function. It is only present if fuel metering is
+// enabled and FuelKey was set in the function invocation context.
+type FuelMeterKey struct{}
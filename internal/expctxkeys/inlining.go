@@ -0,0 +1,6 @@
+package expctxkeys
+
+// InliningBudget is a context.Context Value key.
+// Its associated value should be an int representing the maximum size in
+// bytes of the function bodies inlined by the compiler.
+type InliningBudget struct{}
@@ -0,0 +1,6 @@
+package expctxkeys
+
+// SSAOptimizationPasses is a context.Context Value key.
+// Its associated value should be a ssa.OptimizationPasses representing the
+// optional optimization passes run by the compiler, e.g. to measure their effect.
+type SSAOptimizationPasses struct{}
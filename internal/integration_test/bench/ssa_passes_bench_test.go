@@ -0,0 +1,58 @@
+package bench
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/engine/wazevo/ssa"
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+	"github.com/tetratelabs/wazero/internal/platform"
+)
+
+// ssaOptimizationPassesCases compare the default SSA optimization passes to all and none of the optional ones, and to
+// the default with one more or one less.
+var ssaOptimizationPassesCases = []struct {
+	name   string
+	passes ssa.OptimizationPasses
+}{
+	{name: "default", passes: ssa.OptimizationPassesDefault},
+	{name: "all", passes: ssa.OptimizationPassesAll},
+	{name: "none", passes: 0},
+	{name: "with constant folding", passes: ssa.OptimizationPassesDefault | ssa.OptimizationPassConstantFolding},
+	{name: "with arithmetic simplification", passes: ssa.OptimizationPassesDefault | ssa.OptimizationPassArithmeticSimplification},
+	{name: "with copy propagation", passes: ssa.OptimizationPassesDefault | ssa.OptimizationPassCopyPropagation},
+	{name: "with cse", passes: ssa.OptimizationPassesDefault | ssa.OptimizationPassCommonSubexpressionElimination},
+	{name: "without licm", passes: ssa.OptimizationPassesDefault &^ ssa.OptimizationPassLoopInvariantCodeMotion},
+	{name: "without bounds check elimination", passes: ssa.OptimizationPassesDefault &^ ssa.OptimizationPassBoundsCheckElimination},
+}
+
+func BenchmarkSSAOptimizationPasses(b *testing.B) {
+	if !platform.CompilerSupported() {
+		b.Skip()
+	}
+
+	for _, tc := range ssaOptimizationPassesCases {
+		ctx := context.WithValue(testCtx, expctxkeys.SSAOptimizationPasses{}, tc.passes)
+		b.Run(tc.name, func(b *testing.B) {
+			b.Run("compilation", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigCompiler())
+					if _, err := r.CompileModule(ctx, caseWasm); err != nil {
+						b.Fatal(err)
+					}
+					r.Close(ctx)
+				}
+			})
+
+			r := createRuntime(b, wazero.NewRuntimeConfigCompiler())
+			defer r.Close(ctx)
+			// Instantiate runs the "_start" function which is what TinyGo compiles "main" to.
+			m, err := r.Instantiate(ctx, caseWasm)
+			if err != nil {
+				b.Fatal(err)
+			}
+			runAllInvocationBenches(b, m)
+		})
+	}
+}
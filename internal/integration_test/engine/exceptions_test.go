@@ -28,7 +28,9 @@ import (
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
 	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
 	"github.com/tetratelabs/wazero/internal/wasmruntime"
 )
 
@@ -116,6 +118,9 @@ func runEHTests(t *testing.T, cfg wazero.RuntimeConfig) {
 	t.Run("catch_outside", func(t *testing.T) {
 		testEHCatchOutside(t, cfg)
 	})
+	t.Run("host_boundary", func(t *testing.T) {
+		testEHHostBoundary(t, cfg)
+	})
 }
 
 // testEHCrossFrameCatch is the core reproducer for the interpreter bug:
@@ -327,6 +332,66 @@ func testEHCatchOutside(t *testing.T, cfg wazero.RuntimeConfig) {
 	require.Equal(t, int32(1), api.DecodeI32(res[0]))
 }
 
+// testEHHostBoundary verifies that an exception never crosses a host function:
+// a wasm function called by the host through api.Function fails with an uncaught
+// exception even if a try_table of the wasm caller of the host function would
+// catch it, and the host function decides whether the wasm caller traps.
+func testEHHostBoundary(t *testing.T, cfg wazero.RuntimeConfig) {
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, cfg)
+	defer r.Close(ctx)
+
+	var hostErr error
+	repanic := false
+	_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module) {
+			_, hostErr = mod.ExportedFunction("throw").Call(ctx)
+			if repanic {
+				panic(hostErr)
+			}
+		}).Export("call_throw").Instantiate(ctx)
+	require.NoError(t, err)
+
+	mod, err := r.Instantiate(ctx, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{},
+			{Params: []wasm.ValueType{i32}},
+			{Results: []wasm.ValueType{i32}},
+		},
+		ImportFunctionCount: 1,
+		ImportSection:       []wasm.Import{{Module: "env", Name: "call_throw", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+		FunctionSection:     []wasm.Index{0, 2},
+		TagSection:          []wasm.Tag{{Type: 1}},
+		CodeSection: []wasm.Code{
+			{Body: []byte{wasm.OpcodeI32Const, 7, wasm.OpcodeThrow, 0, wasm.OpcodeEnd}},
+			{Body: []byte{ // Returns the payload of a caught exception, or -1.
+				wasm.OpcodeBlock, i32.Kind(),
+				wasm.OpcodeTryTable, 0x40, 1, 0x00, 0, 0, // (catch 0 0)
+				wasm.OpcodeCall, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeI32Const, 0x7f, // -1
+				wasm.OpcodeReturn,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "throw", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "catch_host", Type: wasm.ExternTypeFunc, Index: 2},
+		},
+	}))
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("catch_host").Call(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int32(-1), api.DecodeI32(res[0]))
+	require.ErrorIs(t, hostErr, wasmruntime.ErrRuntimeUncaughtException)
+
+	repanic = true
+	_, err = mod.ExportedFunction("catch_host").Call(ctx)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeUncaughtException)
+}
+
 // TestExceptionHandlingCompilationCache verifies that
 // the compilation cache round-trips the catchClauseTable correctly.
 func TestExceptionHandlingCompilationCache(t *testing.T) {
@@ -0,0 +1,180 @@
+package adhoc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// fuelWasm imports "env.remaining" which returns the fuel left, and "env.nested" which calls
+// "loop" with 5 iterations from the host. Each executed instruction consumes one unit of fuel,
+// except the "end"s closing unreachable code, such as the ones after "br". It exports:
+//   - "loop" which consumes 8n+6 fuel: 8 per iteration, 3 to exit and 3 for the blocks.
+//   - "recurse" which consumes 8n+3 fuel: 8 per recursive call, and 3 for the last one.
+//   - "remaining" which returns the fuel left after its "call", so one less than the budget.
+//   - "nested" which returns the fuel left after its two calls and the 46 of "loop", and needs 49.
+var fuelWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{i32}},
+		{Results: []wasm.ValueType{i64}},
+		{},
+	},
+	ImportSection: []wasm.Import{
+		{Module: "env", Name: "remaining", Type: wasm.ExternTypeFunc, DescFunc: 1},
+		{Module: "env", Name: "nested", Type: wasm.ExternTypeFunc, DescFunc: 2},
+	},
+	ImportFunctionCount: 2,
+	FunctionSection:     []wasm.Index{0, 0, 1, 1},
+	CodeSection: []wasm.Code{
+		{Body: []byte{
+			wasm.OpcodeBlock, 0x40,
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Eqz,
+			wasm.OpcodeBrIf, 1,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Const, 1,
+			wasm.OpcodeI32Sub,
+			wasm.OpcodeLocalSet, 0,
+			wasm.OpcodeBr, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeIf, 0x40,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Const, 1,
+			wasm.OpcodeI32Sub,
+			wasm.OpcodeCall, 3,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeCall, 1, wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "loop", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "recurse", Type: wasm.ExternTypeFunc, Index: 3},
+		{Name: "remaining", Type: wasm.ExternTypeFunc, Index: 4},
+		{Name: "nested", Type: wasm.ExternTypeFunc, Index: 5},
+	},
+})
+
+func TestFuelMetering(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  wazero.RuntimeConfig
+	}{
+		{"interpreter", wazero.NewRuntimeConfigInterpreter()},
+		{"default", wazero.NewRuntimeConfig()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := wazero.NewRuntimeWithConfig(ctx, tc.cfg.WithFuelMetering(true))
+			defer r.Close(ctx)
+
+			_, err := r.NewHostModuleBuilder("env").
+				NewFunctionBuilder().
+				WithFunc(func(ctx context.Context) uint64 {
+					fuel, ok := experimental.RemainingFuel(ctx)
+					if !ok {
+						return 0xffffffffffffffff
+					}
+					return fuel
+				}).Export("remaining").
+				NewFunctionBuilder().
+				WithFunc(func(ctx context.Context, mod api.Module) {
+					if _, err := mod.ExportedFunction("loop").Call(ctx, 5); err != nil {
+						panic(err)
+					}
+				}).Export("nested").
+				Instantiate(ctx)
+			require.NoError(t, err)
+
+			compiled, err := r.CompileModule(ctx, fuelWasm)
+			require.NoError(t, err)
+			instantiate := func(t *testing.T) api.Module {
+				mod, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(t.Name()))
+				require.NoError(t, err)
+				return mod
+			}
+
+			t.Run("exact budget", func(t *testing.T) {
+				mod := instantiate(t)
+				fuelCtx := experimental.WithFuel(ctx, 86)
+				// Each call has its own budget.
+				for i := 0; i < 2; i++ {
+					_, err := mod.ExportedFunction("loop").Call(fuelCtx, 10)
+					require.NoError(t, err)
+				}
+				_, err := mod.ExportedFunction("recurse").Call(experimental.WithFuel(ctx, 83), 10)
+				require.NoError(t, err)
+			})
+
+			for fn, budget := range map[string]uint64{"loop": 85, "recurse": 82} {
+				t.Run(fn+" exhausted", func(t *testing.T) {
+					mod := instantiate(t)
+					_, err := mod.ExportedFunction(fn).Call(experimental.WithFuel(ctx, budget), 10)
+					var exitErr *sys.ExitError
+					require.True(t, errors.As(err, &exitErr), err)
+					require.Equal(t, sys.ExitCodeFuelExhausted, exitErr.ExitCode())
+					require.True(t, mod.IsClosed())
+				})
+			}
+
+			t.Run("unlimited", func(t *testing.T) {
+				mod := instantiate(t)
+				_, err := mod.ExportedFunction("loop").Call(ctx, 100000)
+				require.NoError(t, err)
+
+				res, err := mod.ExportedFunction("remaining").Call(ctx)
+				require.NoError(t, err)
+				require.Equal(t, uint64(0xffffffffffffffff), res[0])
+			})
+
+			t.Run("remaining", func(t *testing.T) {
+				mod := instantiate(t)
+				res, err := mod.ExportedFunction("remaining").Call(experimental.WithFuel(ctx, 100))
+				require.NoError(t, err)
+				require.Equal(t, uint64(99), res[0])
+			})
+
+			t.Run("nested calls share the budget", func(t *testing.T) {
+				mod := instantiate(t)
+				res, err := mod.ExportedFunction("nested").Call(experimental.WithFuel(ctx, 100))
+				require.NoError(t, err)
+				require.Equal(t, uint64(100-2-46), res[0])
+
+				_, err = mod.ExportedFunction("nested").Call(experimental.WithFuel(ctx, 48))
+				require.ErrorIs(t, err, sys.NewExitError(sys.ExitCodeFuelExhausted))
+			})
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		ctx := context.Background()
+		r := wazero.NewRuntime(ctx)
+		defer r.Close(ctx)
+
+		_, err := r.NewHostModuleBuilder("env").
+			NewFunctionBuilder().WithFunc(func(context.Context) uint64 { return 0 }).Export("remaining").
+			NewFunctionBuilder().WithFunc(func() {}).Export("nested").
+			Instantiate(ctx)
+		require.NoError(t, err)
+		mod, err := r.Instantiate(ctx, fuelWasm)
+		require.NoError(t, err)
+
+		_, err = mod.ExportedFunction("loop").Call(experimental.WithFuel(ctx, 1), 10)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,337 @@
+package adhoc
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// gcTestModule is a module exercising the instructions of the GC proposal, with the following types:
+//
+//	(type $point (struct (field (mut i32)) (field i64) (field (mut i8))))
+//	(type $bytes (array (mut i8)))
+//	(rec (type $node (struct (field i32) (field (ref null $node)))))
+var gcTestModule = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Kind: wasm.CompositeKindStruct, Fields: []wasm.FieldType{
+			{Type: wasm.ValueTypeI32, Mutable: true}, {Type: wasm.ValueTypeI64}, {Type: wasm.ValueTypeI8, Mutable: true},
+		}},
+		{Kind: wasm.CompositeKindArray, Fields: []wasm.FieldType{{Type: wasm.ValueTypeI8, Mutable: true}}},
+		{Kind: wasm.CompositeKindStruct, RecGroupSize: 1, Fields: []wasm.FieldType{
+			{Type: wasm.ValueTypeI32}, {Type: wasm.ValueTypeConcreteRef(2, true)},
+		}},
+		{Params: []wasm.ValueType{wasm.ValueTypeAnyref}, Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32, i64, i32}, Results: []wasm.ValueType{i32, i64, i32, i32}},
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32, i32, i32, i32, i32}},
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32, i32}},
+	},
+	FunctionSection: []wasm.Index{3, 4, 4, 4, 5, 6, 6, 7, 7},
+	CodeSection: []wasm.Code{
+		{ // classify returns the x field of a $point, 2 for an i31, 3 for a $bytes and 4 for null.
+			Body: slices.Concat(
+				[]byte{wasm.OpcodeBlock, wasm.RefPrefixNonNullable, 0}, // (result (ref $point))
+				[]byte{wasm.OpcodeLocalGet, 0},
+				gcOp(wasm.OpcodeGCBrOnCast, 0b01, 0, 0x6e, 0), // br_on_cast 0 anyref (ref $point)
+				[]byte{wasm.OpcodeDrop, wasm.OpcodeLocalGet, 0},
+				gcOp(wasm.OpcodeGCRefTest, 0x6c), // ref.test (ref i31)
+				[]byte{wasm.OpcodeIf, i32.Kind(), wasm.OpcodeI32Const, 2, wasm.OpcodeElse, wasm.OpcodeLocalGet, 0},
+				gcOp(wasm.OpcodeGCRefTest, 1), // ref.test (ref $bytes)
+				[]byte{wasm.OpcodeIf, i32.Kind(), wasm.OpcodeI32Const, 3, wasm.OpcodeElse, wasm.OpcodeLocalGet, 0},
+				gcOp(wasm.OpcodeGCRefTestNull, 0x71), // ref.test (ref null none)
+				[]byte{wasm.OpcodeI32Const, 4, wasm.OpcodeI32Mul},
+				[]byte{wasm.OpcodeEnd, wasm.OpcodeEnd, wasm.OpcodeReturn, wasm.OpcodeEnd},
+				gcOp(wasm.OpcodeGCStructGet, 0, 0),
+				[]byte{wasm.OpcodeEnd},
+			),
+		},
+		{ // classify_driver calls classify with null, an i31, a $point or a $bytes depending on the param.
+			Body: slices.Concat(
+				[]byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Eqz, wasm.OpcodeIf, 0x6e},
+				[]byte{wasm.OpcodeRefNull, 0x6e},
+				[]byte{wasm.OpcodeElse, wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Eq, wasm.OpcodeIf, 0x6e},
+				[]byte{wasm.OpcodeI32Const, 7},
+				gcOp(wasm.OpcodeGCRefI31),
+				[]byte{wasm.OpcodeElse, wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 2, wasm.OpcodeI32Eq, wasm.OpcodeIf, 0x6e},
+				i32Const(100), []byte{wasm.OpcodeI64Const, 0, wasm.OpcodeI32Const, 0},
+				gcOp(wasm.OpcodeGCStructNew, 0),
+				[]byte{wasm.OpcodeElse, wasm.OpcodeI32Const, 1},
+				gcOp(wasm.OpcodeGCArrayNewDefault, 1),
+				[]byte{wasm.OpcodeEnd, wasm.OpcodeEnd, wasm.OpcodeEnd},
+				[]byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd},
+			),
+		},
+		{ // cast_point casts an i31 to $point, which always fails.
+			Body: slices.Concat(
+				[]byte{wasm.OpcodeLocalGet, 0},
+				gcOp(wasm.OpcodeGCRefI31),
+				gcOp(wasm.OpcodeGCRefCast, 0),
+				gcOp(wasm.OpcodeGCStructGet, 0, 0),
+				[]byte{wasm.OpcodeEnd},
+			),
+		},
+		{ // list builds a list of $node with values 0 to n-1 while allocating garbage, and returns their sum.
+			LocalTypes: []wasm.ValueType{wasm.ValueTypeConcreteRef(2, true), i32, i32},
+			Body: slices.Concat(
+				[]byte{wasm.OpcodeBlock, 0x40, wasm.OpcodeLoop, 0x40},
+				[]byte{wasm.OpcodeLocalGet, 2, wasm.OpcodeLocalGet, 0, wasm.OpcodeI32GeU, wasm.OpcodeBrIf, 1},
+				[]byte{wasm.OpcodeLocalGet, 2, wasm.OpcodeLocalGet, 1},
+				gcOp(wasm.OpcodeGCStructNew, 2),
+				[]byte{wasm.OpcodeLocalSet, 1},
+				gcOp(wasm.OpcodeGCStructNewDefault, 0),
+				[]byte{wasm.OpcodeDrop},
+				[]byte{wasm.OpcodeLocalGet, 2, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, wasm.OpcodeLocalSet, 2},
+				[]byte{wasm.OpcodeBr, 0, wasm.OpcodeEnd, wasm.OpcodeEnd},
+				[]byte{wasm.OpcodeBlock, 0x40, wasm.OpcodeLoop, 0x40},
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeRefIsNull, wasm.OpcodeBrIf, 1},
+				[]byte{wasm.OpcodeLocalGet, 3, wasm.OpcodeLocalGet, 1},
+				gcOp(wasm.OpcodeGCStructGet, 2, 0),
+				[]byte{wasm.OpcodeI32Add, wasm.OpcodeLocalSet, 3, wasm.OpcodeLocalGet, 1},
+				gcOp(wasm.OpcodeGCStructGet, 2, 1),
+				[]byte{wasm.OpcodeLocalSet, 1},
+				[]byte{wasm.OpcodeBr, 0, wasm.OpcodeEnd, wasm.OpcodeEnd},
+				[]byte{wasm.OpcodeLocalGet, 3, wasm.OpcodeEnd},
+			),
+		},
+		{ // point creates a $point, increments its x field and returns all fields.
+			LocalTypes: []wasm.ValueType{wasm.ValueTypeConcreteRef(0, true)},
+			Body: slices.Concat(
+				[]byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2},
+				gcOp(wasm.OpcodeGCStructNew, 0),
+				[]byte{wasm.OpcodeLocalSet, 3},
+				[]byte{wasm.OpcodeLocalGet, 3, wasm.OpcodeLocalGet, 3},
+				gcOp(wasm.OpcodeGCStructGet, 0, 0),
+				[]byte{wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add},
+				gcOp(wasm.OpcodeGCStructSet, 0, 0),
+				[]byte{wasm.OpcodeLocalGet, 3},
+				gcOp(wasm.OpcodeGCStructGet, 0, 0),
+				[]byte{wasm.OpcodeLocalGet, 3},
+				gcOp(wasm.OpcodeGCStructGet, 0, 1),
+				[]byte{wasm.OpcodeLocalGet, 3},
+				gcOp(wasm.OpcodeGCStructGetS, 0, 2),
+				[]byte{wasm.OpcodeLocalGet, 3},
+				gcOp(wasm.OpcodeGCStructGetU, 0, 2),
+				[]byte{wasm.OpcodeEnd},
+			),
+		},
+		{ // bytes creates a $bytes of length n filled with 200, then sets and fills elements.
+			LocalTypes: []wasm.ValueType{wasm.ValueTypeConcreteRef(1, true)},
+			Body: slices.Concat(
+				i32Const(200), []byte{wasm.OpcodeLocalGet, 0},
+				gcOp(wasm.OpcodeGCArrayNew, 1),
+				[]byte{wasm.OpcodeLocalSet, 1},
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 7},
+				gcOp(wasm.OpcodeGCArraySet, 1),
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 2, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 2},
+				gcOp(wasm.OpcodeGCArrayFill, 1),
+				[]byte{wasm.OpcodeLocalGet, 1},
+				gcOp(wasm.OpcodeGCArrayLen),
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 0},
+				gcOp(wasm.OpcodeGCArrayGetU, 1),
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 1},
+				gcOp(wasm.OpcodeGCArrayGetU, 1),
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 2},
+				gcOp(wasm.OpcodeGCArrayGetU, 1),
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub},
+				gcOp(wasm.OpcodeGCArrayGetS, 1),
+				[]byte{wasm.OpcodeEnd},
+			),
+		},
+		{ // copy copies (1 2 3) into a zeroed $bytes of length 5 at offset n, and returns its elements.
+			LocalTypes: []wasm.ValueType{wasm.ValueTypeConcreteRef(1, true)},
+			Body: slices.Concat(
+				[]byte{wasm.OpcodeI32Const, 5},
+				gcOp(wasm.OpcodeGCArrayNewDefault, 1),
+				[]byte{wasm.OpcodeLocalTee, 1, wasm.OpcodeLocalGet, 0},
+				[]byte{wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 2, wasm.OpcodeI32Const, 3},
+				gcOp(wasm.OpcodeGCArrayNewFixed, 1, 3),
+				[]byte{wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 3},
+				gcOp(wasm.OpcodeGCArrayCopy, 1, 1),
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 0},
+				gcOp(wasm.OpcodeGCArrayGetU, 1),
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 1},
+				gcOp(wasm.OpcodeGCArrayGetU, 1),
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 2},
+				gcOp(wasm.OpcodeGCArrayGetU, 1),
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 3},
+				gcOp(wasm.OpcodeGCArrayGetU, 1),
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 4},
+				gcOp(wasm.OpcodeGCArrayGetU, 1),
+				[]byte{wasm.OpcodeEnd},
+			),
+		},
+		{ // i31 returns the param converted to an i31 and back, signed and unsigned.
+			Body: slices.Concat(
+				[]byte{wasm.OpcodeLocalGet, 0},
+				gcOp(wasm.OpcodeGCRefI31),
+				gcOp(wasm.OpcodeGCI31GetS),
+				[]byte{wasm.OpcodeLocalGet, 0},
+				gcOp(wasm.OpcodeGCRefI31),
+				gcOp(wasm.OpcodeGCI31GetU),
+				[]byte{wasm.OpcodeEnd},
+			),
+		},
+		{ // eq compares a $point with itself and with another $point.
+			LocalTypes: []wasm.ValueType{wasm.ValueTypeConcreteRef(0, true)},
+			Body: slices.Concat(
+				gcOp(wasm.OpcodeGCStructNewDefault, 0),
+				[]byte{wasm.OpcodeLocalSet, 1},
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 1, wasm.OpcodeRefEq},
+				[]byte{wasm.OpcodeLocalGet, 1},
+				gcOp(wasm.OpcodeGCStructNewDefault, 0),
+				[]byte{wasm.OpcodeRefEq, wasm.OpcodeEnd},
+			),
+		},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "classify", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "cast_point", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "list", Type: wasm.ExternTypeFunc, Index: 3},
+		{Name: "point", Type: wasm.ExternTypeFunc, Index: 4},
+		{Name: "bytes", Type: wasm.ExternTypeFunc, Index: 5},
+		{Name: "copy", Type: wasm.ExternTypeFunc, Index: 6},
+		{Name: "i31", Type: wasm.ExternTypeFunc, Index: 7},
+		{Name: "eq", Type: wasm.ExternTypeFunc, Index: 8},
+	},
+})
+
+func TestE2E_gc(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter().
+		WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesTypedFunctionReferences|experimental.CoreFeaturesGC))
+	defer r.Close(ctx)
+
+	inst, err := r.Instantiate(ctx, gcTestModule)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name   string
+		params []uint64
+		exp    []uint64
+		expErr string
+	}{
+		{name: "point", params: []uint64{10, api.EncodeI64(-5), 0xff}, exp: []uint64{11, api.EncodeI64(-5), 0xffffffff, 0xff}},
+		{name: "bytes", params: []uint64{5}, exp: []uint64{5, 200, 7, 1, api.EncodeI32(-56)}},
+		{name: "bytes", params: []uint64{2}, expErr: "out of bounds array access"},
+		{name: "copy", params: []uint64{1}, exp: []uint64{0, 1, 2, 3, 0}},
+		{name: "copy", params: []uint64{3}, expErr: "out of bounds array access"},
+		{name: "i31", params: []uint64{0x7fffffff}, exp: []uint64{0xffffffff, 0x7fffffff}},
+		{name: "i31", params: []uint64{0x80000005}, exp: []uint64{5, 5}},
+		{name: "eq", params: []uint64{0}, exp: []uint64{1, 0}},
+		{name: "classify", params: []uint64{0}, exp: []uint64{4}},
+		{name: "classify", params: []uint64{1}, exp: []uint64{2}},
+		{name: "classify", params: []uint64{2}, exp: []uint64{100}},
+		{name: "classify", params: []uint64{3}, exp: []uint64{3}},
+		{name: "cast_point", params: []uint64{1}, expErr: "cast failure"},
+		{name: "list", params: []uint64{5000}, exp: []uint64{5000 * 4999 / 2}},
+	} {
+		res, err := inst.ExportedFunction(tc.name).Call(ctx, tc.params...)
+		if tc.expErr != "" {
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expErr)
+		} else {
+			require.NoError(t, err)
+			require.Equal(t, tc.exp, res)
+		}
+	}
+}
+
+func TestE2E_gc_exnref(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(api.CoreFeaturesV2|
+		experimental.CoreFeaturesTypedFunctionReferences|experimental.CoreFeaturesGC|experimental.CoreFeaturesExceptionHandling))
+	defer r.Close(ctx)
+
+	// rethrow throws a $point holding n as the payload of an exception, keeps only the exnref, allocates n
+	// objects so that collections happen, and returns the field of the $point caught from the rethrown exception.
+	inst, err := r.Instantiate(ctx, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Kind: wasm.CompositeKindStruct, Fields: []wasm.FieldType{{Type: i32, Mutable: true}}},
+			{Params: []wasm.ValueType{wasm.ValueTypeAnyref}},
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+		},
+		FunctionSection: []wasm.Index{2},
+		TagSection:      []wasm.Tag{{Type: 1}},
+		CodeSection: []wasm.Code{{
+			LocalTypes: []wasm.ValueType{wasm.ValueTypeExnref},
+			Body: slices.Concat(
+				[]byte{wasm.OpcodeBlock, wasm.ValueTypeExnref.Kind()},
+				[]byte{wasm.OpcodeTryTable, 0x40, 1, wasm.CatchKindCatchAllRef, 0}, // (catch_all_ref 0)
+				[]byte{wasm.OpcodeLocalGet, 0},
+				gcOp(wasm.OpcodeGCStructNew, 0),
+				[]byte{wasm.OpcodeThrow, 0, wasm.OpcodeEnd, wasm.OpcodeUnreachable, wasm.OpcodeEnd},
+				[]byte{wasm.OpcodeLocalSet, 1},
+				[]byte{wasm.OpcodeBlock, 0x40, wasm.OpcodeLoop, 0x40},
+				[]byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Eqz, wasm.OpcodeBrIf, 1},
+				[]byte{wasm.OpcodeI32Const, 0},
+				gcOp(wasm.OpcodeGCStructNew, 0),
+				[]byte{wasm.OpcodeDrop},
+				[]byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub, wasm.OpcodeLocalSet, 0},
+				[]byte{wasm.OpcodeBr, 0, wasm.OpcodeEnd, wasm.OpcodeEnd},
+				[]byte{wasm.OpcodeBlock, wasm.ValueTypeAnyref.Kind()},
+				[]byte{wasm.OpcodeTryTable, 0x40, 1, wasm.CatchKindCatch, 0, 0}, // (catch 0 0)
+				[]byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeThrowRef, wasm.OpcodeEnd, wasm.OpcodeUnreachable, wasm.OpcodeEnd},
+				gcOp(wasm.OpcodeGCRefCast, 0),
+				gcOp(wasm.OpcodeGCStructGet, 0, 0),
+				[]byte{wasm.OpcodeEnd},
+			),
+		}},
+		ExportSection: []wasm.Export{{Name: "rethrow", Type: wasm.ExternTypeFunc, Index: 0}},
+	}))
+	require.NoError(t, err)
+
+	const n = 10 * 1024
+	res, err := inst.ExportedFunction("rethrow").Call(ctx, n)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{n}, res)
+}
+
+func TestE2E_gc_unsupported(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+		defer r.Close(ctx)
+		_, err := r.CompileModule(ctx, gcTestModule)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `feature "gc" is disabled`)
+	})
+
+	t.Run("compiler", func(t *testing.T) {
+		if !platform.CompilerSupported() {
+			t.Skip()
+		}
+		r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigCompiler().
+			WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesTypedFunctionReferences|experimental.CoreFeaturesGC))
+		defer r.Close(ctx)
+		_, err := r.CompileModule(ctx, gcTestModule)
+		require.EqualError(t, err, "GC proposal is not supported by the compiler: use the interpreter instead")
+	})
+}
+
+// gcOp encodes the GC instruction op with the given immediates encoded as LEB128.
+func gcOp(op wasm.OpcodeGC, immediates ...int32) []byte {
+	ret := append([]byte{wasm.OpcodeGCPrefix}, leb128.EncodeUint32(op)...)
+	for _, imm := range immediates {
+		if imm >= 0x40 {
+			// Abstract heap types are single bytes.
+			ret = append(ret, byte(imm))
+		} else {
+			ret = append(ret, leb128.EncodeInt32(imm)...)
+		}
+	}
+	return ret
+}
+
+func i32Const(v int32) []byte {
+	return append([]byte{wasm.OpcodeI32Const}, leb128.EncodeInt32(v)...)
+}
@@ -0,0 +1,126 @@
+package adhoc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// inliningWasm exports "run" which allocates n bytes on the stack with "alloc", stores 42 there, and reads it
+// back with "load". "alloc" and "load" are the kind of small functions emitted by LLVM which are inlined.
+// It also exports "get" which only calls "load".
+var inliningWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+	FunctionSection: []wasm.Index{0, 0, 0, 0},
+	MemorySection:   []wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
+	GlobalSection: []wasm.Global{{
+		Type: wasm.GlobalType{ValType: wasm.ValueTypeI32, Mutable: true},
+		Init: wasm.NewConstantExpressionFromI32(1024),
+	}},
+	CodeSection: []wasm.Code{
+		// alloc: the stack pointer is decremented by n, and returned.
+		{LocalTypes: []wasm.ValueType{i32}, Body: []byte{
+			wasm.OpcodeGlobalGet, 0,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Sub,
+			wasm.OpcodeLocalTee, 1,
+			wasm.OpcodeGlobalSet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeEnd,
+		}},
+		// load.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Load, 0x2, 0x0,
+			wasm.OpcodeEnd,
+		}},
+		// run.
+		{LocalTypes: []wasm.ValueType{i32}, Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 0,
+			wasm.OpcodeLocalTee, 1,
+			wasm.OpcodeI32Const, 42,
+			wasm.OpcodeI32Store, 0x2, 0x0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeCall, 1,
+			wasm.OpcodeEnd,
+		}},
+		// get.
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 1,
+			wasm.OpcodeEnd,
+		}},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "run", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "load", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "get", Type: wasm.ExternTypeFunc, Index: 3},
+	},
+	NameSection: &wasm.NameSection{
+		ModuleName:    "inlining",
+		FunctionNames: wasm.NameMap{{Index: 0, Name: "alloc"}, {Index: 1, Name: "load"}, {Index: 2, Name: "run"}, {Index: 3, Name: "get"}},
+	},
+})
+
+func TestInlining(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+
+	for _, tc := range []struct {
+		name   string
+		budget int
+	}{
+		{name: "disabled", budget: 0},
+		{name: "enabled", budget: 100},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigCompiler().WithFuelMetering(true))
+			defer r.Close(ctx)
+
+			compiled, err := r.CompileModule(experimental.WithInliningBudget(ctx, tc.budget), inliningWasm)
+			require.NoError(t, err)
+			mod, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+			require.NoError(t, err)
+
+			// The stack pointer is updated by each call.
+			for i := 0; i < 2; i++ {
+				res, err := mod.ExportedFunction("run").Call(ctx, 16)
+				require.NoError(t, err)
+				require.Equal(t, uint64(42), res[0])
+			}
+			res, err := mod.ExportedFunction("load").Call(ctx, 1024-16)
+			require.NoError(t, err)
+			require.Equal(t, uint64(42), res[0])
+
+			_, err = mod.ExportedFunction("load").Call(ctx, 65536)
+			require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+
+			// The trap raised in the inlined function is reported in its own frame.
+			_, err = mod.ExportedFunction("get").Call(ctx, 65536)
+			require.EqualError(t, err, `wasm error: out of bounds memory access
+wasm stack trace:
+	inlining.load(i32) i32
+	inlining.get(i32) i32`)
+
+			// The instructions of inlined functions consume fuel as well: 8 for "run", 7 for "alloc" and 3 for "load".
+			_, err = mod.ExportedFunction("run").Call(experimental.WithFuel(ctx, 18), 16)
+			require.NoError(t, err)
+			_, err = mod.ExportedFunction("run").Call(experimental.WithFuel(ctx, 17), 16)
+			var exitErr *sys.ExitError
+			require.True(t, errors.As(err, &exitErr), err)
+			require.Equal(t, sys.ExitCodeFuelExhausted, exitErr.ExitCode())
+		})
+	}
+}
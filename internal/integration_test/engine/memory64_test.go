@@ -0,0 +1,188 @@
+package adhoc
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// TestE2E_memory64 exercises a 64-bit memory with i64 addresses in loads, stores,
+// bulk memory operations, memory.size and memory.grow.
+func TestE2E_memory64(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name string
+		cfg  wazero.RuntimeConfig
+	}{
+		{"interpreter", wazero.NewRuntimeConfigInterpreter()},
+		{"default", wazero.NewRuntimeConfig()},
+	} {
+		config := tc.cfg.WithCoreFeatures(api.CoreFeaturesV2 | experimental.CoreFeaturesMemory64)
+
+		t.Run(tc.name, func(t *testing.T) {
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+			defer func() {
+				require.NoError(t, r.Close(ctx))
+			}()
+
+			m := &wasm.Module{
+				TypeSection: []wasm.FunctionType{
+					{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i64}}, // type 0: (i64) -> i64
+					{Params: []wasm.ValueType{i64, i64}},                            // type 1: (i64, i64) -> ()
+					{Results: []wasm.ValueType{i64}},                                // type 2: () -> i64
+					{Params: []wasm.ValueType{i64, i32, i64}},                       // type 3: (i64, i32, i64) -> ()
+					{Params: []wasm.ValueType{i64, i64, i64}},                       // type 4: (i64, i64, i64) -> ()
+				},
+				MemorySection:   []wasm.Memory{{Min: 1, Cap: 1, Max: 3, IsMaxEncoded: true, Is64: true}},
+				FunctionSection: []wasm.Index{0, 1, 0, 2, 0, 3, 4},
+				CodeSection: []wasm.Code{
+					{Body: []byte{ // load(addr) -> i64.load
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI64Load, 0x3, 0,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // store(addr, v) -> i64.store
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeLocalGet, 1,
+						wasm.OpcodeI64Store, 0x3, 0,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // loadFar(addr) -> i64.load8_u offset=1<<32
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI64Load8U, 0x0, 0x80, 0x80, 0x80, 0x80, 0x10,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // size() -> memory.size
+						wasm.OpcodeMemorySize, 0,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // grow(n) -> memory.grow
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeMemoryGrow, 0,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // fill(dst, v, n) -> memory.fill
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeLocalGet, 1,
+						wasm.OpcodeLocalGet, 2,
+						wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryFill, 0,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // copy(dst, src, n) -> memory.copy
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeLocalGet, 1,
+						wasm.OpcodeLocalGet, 2,
+						wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 0, 0,
+						wasm.OpcodeEnd,
+					}},
+				},
+				DataSection: []wasm.DataSegment{
+					{OffsetExpression: wasm.NewConstantExpressionFromI64(16), Init: []byte("hello")},
+				},
+				ExportSection: []wasm.Export{
+					{Name: "load", Type: wasm.ExternTypeFunc, Index: 0},
+					{Name: "store", Type: wasm.ExternTypeFunc, Index: 1},
+					{Name: "loadFar", Type: wasm.ExternTypeFunc, Index: 2},
+					{Name: "size", Type: wasm.ExternTypeFunc, Index: 3},
+					{Name: "grow", Type: wasm.ExternTypeFunc, Index: 4},
+					{Name: "fill", Type: wasm.ExternTypeFunc, Index: 5},
+					{Name: "copy", Type: wasm.ExternTypeFunc, Index: 6},
+					{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+				},
+			}
+
+			inst, err := r.Instantiate(ctx, binaryencoding.EncodeModule(m))
+			require.NoError(t, err)
+			mem := inst.Memory()
+
+			call := func(name string, params ...uint64) ([]uint64, error) {
+				f := inst.ExportedFunction(name)
+				require.NotNil(t, f)
+				return f.Call(ctx, params...)
+			}
+
+			// The active data segment used an i64 offset.
+			buf, ok := mem.Read64(16, 5)
+			require.True(t, ok)
+			require.Equal(t, "hello", string(buf))
+
+			// Loads and stores take i64 addresses.
+			_, err = call("store", 8, 0x0102030405060708)
+			require.NoError(t, err)
+			res, err := call("load", 8)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{0x0102030405060708}, res)
+			v, ok := mem.ReadUint64Le64(8)
+			require.True(t, ok)
+			require.Equal(t, uint64(0x0102030405060708), v)
+			require.True(t, mem.WriteUint64Le64(24, 42))
+			res, err = call("load", 24)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{42}, res)
+
+			// Addresses beyond 32 bits are out of bounds rather than wrapped.
+			_, err = call("load", 1<<32)
+			require.Error(t, err)
+			_, err = call("load", math.MaxUint64-3)
+			require.Error(t, err)
+			_, err = call("loadFar", 0)
+			require.Error(t, err)
+			_, err = call("store", uint64(wasm.MemoryPageSize-4), 1)
+			require.Error(t, err)
+
+			// Bulk memory operations.
+			_, err = call("fill", 32, 'x', 3)
+			require.NoError(t, err)
+			_, err = call("copy", 40, 16, 5)
+			require.NoError(t, err)
+			buf, ok = mem.Read64(32, 13)
+			require.True(t, ok)
+			require.Equal(t, "xxx\x00\x00\x00\x00\x00hello", string(buf))
+			_, err = call("fill", 1<<32, 0, 1)
+			require.Error(t, err)
+			_, err = call("copy", 0, math.MaxUint64, 2)
+			require.Error(t, err)
+
+			// memory.size and memory.grow use i64 page counts.
+			res, err = call("size")
+			require.NoError(t, err)
+			require.Equal(t, []uint64{1}, res)
+			res, err = call("grow", 1)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{1}, res)
+			res, err = call("grow", 1<<32)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{math.MaxUint64}, res)
+			res, err = call("grow", 2)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{math.MaxUint64}, res)
+			res, err = call("size")
+			require.NoError(t, err)
+			require.Equal(t, []uint64{2}, res)
+			require.Equal(t, uint64(2*wasm.MemoryPageSize), mem.Size64())
+			_, err = call("store", uint64(wasm.MemoryPageSize), 7)
+			require.NoError(t, err)
+			v, ok = mem.ReadUint64Le64(uint64(wasm.MemoryPageSize))
+			require.True(t, ok)
+			require.Equal(t, uint64(7), v)
+		})
+	}
+}
+
+func TestE2E_memory64_disabled(t *testing.T) {
+	r := wazero.NewRuntime(context.Background())
+	defer r.Close(context.Background())
+
+	_, err := r.CompileModule(context.Background(), binaryencoding.EncodeModule(&wasm.Module{
+		MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Is64: true}},
+	}))
+	require.EqualError(t, err, "section memory: 64-bit memory requested but memory64 feature not enabled")
+}
@@ -0,0 +1,147 @@
+package adhoc
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+// memoryGuardPagesWasm imports "env.nested" which calls "load" from the host. It exports:
+//   - "load" and "store" which access the memory at the given address.
+//   - "load_far" which loads with the largest static offset.
+//   - "grow" which grows the memory by the given pages.
+//   - "nested" which calls env.nested with the given address, then loads at the address zero.
+//   - "recurse" which loads at the given address after n recursive calls, growing the stack.
+var memoryGuardPagesWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32, i32}},
+		{Params: []wasm.ValueType{i32}},
+		{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+	},
+	ImportSection: []wasm.Import{
+		{Module: "env", Name: "nested", Type: wasm.ExternTypeFunc, DescFunc: 2},
+	},
+	ImportFunctionCount: 1,
+	FunctionSection:     []wasm.Index{0, 1, 0, 0, 0, 3},
+	MemorySection:       []wasm.Memory{{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true}},
+	CodeSection: []wasm.Code{
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Load, 0x2, 0x0, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Store, 0x2, 0x0, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Load, 0x2, 0xf0, 0xff, 0xff, 0xff, 0x0f, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeEnd}},
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeCall, 0,
+			wasm.OpcodeI32Const, 0,
+			wasm.OpcodeI32Load, 0x2, 0x0,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Eqz,
+			wasm.OpcodeIf, 0x7f,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeI32Load, 0x2, 0x0,
+			wasm.OpcodeElse,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Const, 1,
+			wasm.OpcodeI32Sub,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeCall, 6,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "load", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "store", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "load_far", Type: wasm.ExternTypeFunc, Index: 3},
+		{Name: "grow", Type: wasm.ExternTypeFunc, Index: 4},
+		{Name: "nested", Type: wasm.ExternTypeFunc, Index: 5},
+		{Name: "recurse", Type: wasm.ExternTypeFunc, Index: 6},
+	},
+})
+
+// TestMemoryGuardPages runs wherever the compiler is supported, as the bounds checks are kept on the other
+// platforms than linux/amd64 with the same results.
+func TestMemoryGuardPages(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigCompiler().WithMemoryGuardPages(true))
+	defer r.Close(ctx)
+
+	var nestedErr error
+	_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, m api.Module, addr uint32) {
+			_, nestedErr = m.ExportedFunction("load").Call(ctx, uint64(addr))
+		}).Export("nested").Instantiate(ctx)
+	require.NoError(t, err)
+
+	mod, err := r.Instantiate(ctx, memoryGuardPagesWasm)
+	require.NoError(t, err)
+	load, store := mod.ExportedFunction("load"), mod.ExportedFunction("store")
+
+	_, err = store.Call(ctx, 65532, 42)
+	require.NoError(t, err)
+	res, err := load.Call(ctx, 65532)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+
+	for _, addr := range []uint64{65533, 65536, 0xffffffff} {
+		_, err = load.Call(ctx, addr)
+		require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+		require.Contains(t, err.Error(), "wasm stack trace:")
+		_, err = store.Call(ctx, addr, 1)
+		require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	_, err = mod.ExportedFunction("load_far").Call(ctx, 0xffffffff)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+
+	// The out of bounds access of the nested call doesn't affect the caller.
+	res, err = mod.ExportedFunction("nested").Call(ctx, 65536)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+	require.ErrorIs(t, nestedErr, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+
+	// The faults are recovered from on the grown stack as well.
+	res, err = mod.ExportedFunction("recurse").Call(ctx, 10000, 65532)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+	_, err = mod.ExportedFunction("recurse").Call(ctx, 10000, 65536)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+
+	// The grown pages become accessible.
+	_, err = mod.ExportedFunction("grow").Call(ctx, 1)
+	require.NoError(t, err)
+	_, err = store.Call(ctx, 65536, 43)
+	require.NoError(t, err)
+	res, err = load.Call(ctx, 65536)
+	require.NoError(t, err)
+	require.Equal(t, uint64(43), res[0])
+	_, err = load.Call(ctx, 2*65536)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+
+	// The faults outside the compiled code are still handled by the Go runtime.
+	require.True(t, nilDereferencePanics())
+}
+
+func nilDereferencePanics() (panicked bool) {
+	defer func() {
+		_, panicked = recover().(runtime.Error)
+	}()
+	var p *int
+	_ = *p
+	return
+}
@@ -0,0 +1,314 @@
+package adhoc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// TestE2E_multi_memory exercises a module with an imported memory and two local memories,
+// accessing each of them with loads, stores, bulk memory operations and memory.grow.
+func TestE2E_multi_memory(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name string
+		cfg  wazero.RuntimeConfig
+	}{
+		{"interpreter", wazero.NewRuntimeConfigInterpreter()},
+		{"default", wazero.NewRuntimeConfig()},
+	} {
+		config := tc.cfg.WithCoreFeatures(api.CoreFeaturesV2 | experimental.CoreFeaturesMultiMemory)
+
+		t.Run(tc.name, func(t *testing.T) {
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+			defer func() {
+				require.NoError(t, r.Close(ctx))
+			}()
+
+			// The exporter provides the memory at index zero of the importing module.
+			exporter, err := r.InstantiateWithConfig(ctx, binaryencoding.EncodeModule(&wasm.Module{
+				MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
+				ExportSection: []wasm.Export{{Name: "mem", Type: wasm.ExternTypeMemory, Index: 0}},
+			}), wazero.NewModuleConfig().WithName("env"))
+			require.NoError(t, err)
+			require.True(t, exporter.Memory().WriteUint32Le(0, 0xdeadbeef))
+
+			dataCount := uint32(2)
+			m := &wasm.Module{
+				TypeSection: []wasm.FunctionType{
+					{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}, // type 0: (i32) -> i32
+					{Params: []wasm.ValueType{i32, i32}},                            // type 1: (i32, i32) -> ()
+					{Params: []wasm.ValueType{i32, i32, i32}},                       // type 2: (i32, i32, i32) -> ()
+					{Results: []wasm.ValueType{i32}},                                // type 3: () -> i32
+				},
+				ImportMemoryCount: 1,
+				ImportSection: []wasm.Import{{
+					Module:  "env",
+					Name:    "mem",
+					Type:    wasm.ExternTypeMemory,
+					DescMem: &wasm.Memory{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+				}},
+				MemorySection: []wasm.Memory{
+					{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true}, // memory 1
+					{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}, // memory 2
+				},
+				FunctionSection: []wasm.Index{0, 0, 1, 0, 2, 3, 0, 2, 2},
+				CodeSection: []wasm.Code{
+					{Body: []byte{ // load0(addr) -> i32.load memory 0
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI32Load, 0x2, 0,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // load1(addr) -> i32.load8_u memory 1
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI32Load8U, wasm.MemArgMemoryIndexFlag, 1, 0,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // store2(addr, v) -> i32.store memory 2
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeLocalGet, 1,
+						wasm.OpcodeI32Store, wasm.MemArgMemoryIndexFlag | 0x2, 2, 0,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // load2(addr) -> i32.load memory 2
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI32Load, wasm.MemArgMemoryIndexFlag | 0x2, 2, 0,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // copy(dst, src, n) -> memory.copy from memory 1 to memory 2
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeLocalGet, 1,
+						wasm.OpcodeLocalGet, 2,
+						wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 2, 1,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // size1() -> memory.size memory 1
+						wasm.OpcodeMemorySize, 1,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // grow1(n) -> memory.grow memory 1
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeMemoryGrow, 1,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // fill2(dst, v, n) -> memory.fill memory 2
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeLocalGet, 1,
+						wasm.OpcodeLocalGet, 2,
+						wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryFill, 2,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // init2(dst, src, n) -> memory.init of data 1 into memory 2
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeLocalGet, 1,
+						wasm.OpcodeLocalGet, 2,
+						wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryInit, 1, 2,
+						wasm.OpcodeEnd,
+					}},
+				},
+				DataCountSection: &dataCount,
+				DataSection: []wasm.DataSegment{
+					{OffsetExpression: wasm.NewConstantExpressionFromI32(0), Init: []byte("hello"), MemoryIndex: 1},
+					{Passive: true, Init: []byte("world")},
+				},
+				ExportSection: []wasm.Export{
+					{Name: "load0", Type: wasm.ExternTypeFunc, Index: 0},
+					{Name: "load1", Type: wasm.ExternTypeFunc, Index: 1},
+					{Name: "store2", Type: wasm.ExternTypeFunc, Index: 2},
+					{Name: "load2", Type: wasm.ExternTypeFunc, Index: 3},
+					{Name: "copy", Type: wasm.ExternTypeFunc, Index: 4},
+					{Name: "size1", Type: wasm.ExternTypeFunc, Index: 5},
+					{Name: "grow1", Type: wasm.ExternTypeFunc, Index: 6},
+					{Name: "fill2", Type: wasm.ExternTypeFunc, Index: 7},
+					{Name: "init2", Type: wasm.ExternTypeFunc, Index: 8},
+					{Name: "mem1", Type: wasm.ExternTypeMemory, Index: 1},
+					{Name: "mem2", Type: wasm.ExternTypeMemory, Index: 2},
+				},
+			}
+
+			inst, err := r.Instantiate(ctx, binaryencoding.EncodeModule(m))
+			require.NoError(t, err)
+
+			call := func(name string, params ...uint64) ([]uint64, error) {
+				f := inst.ExportedFunction(name)
+				require.NotNil(t, f)
+				return f.Call(ctx, params...)
+			}
+
+			// The default memory is the imported one.
+			require.Equal(t, exporter.Memory(), inst.Memory())
+			res, err := call("load0", 0)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{0xdeadbeef}, res)
+
+			// The active data segment initialized memory 1.
+			mem1, mem2 := inst.ExportedMemory("mem1"), inst.ExportedMemory("mem2")
+			require.NotNil(t, mem1)
+			require.NotNil(t, mem2)
+			buf, ok := mem1.Read(0, 5)
+			require.True(t, ok)
+			require.Equal(t, "hello", string(buf))
+			res, err = call("load1", 1)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{'e'}, res)
+
+			// Stores to memory 2 are visible only there.
+			_, err = call("store2", 8, 0x01020304)
+			require.NoError(t, err)
+			res, err = call("load2", 8)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{0x01020304}, res)
+			v, ok := mem2.ReadUint32Le(8)
+			require.True(t, ok)
+			require.Equal(t, uint32(0x01020304), v)
+			v, ok = mem1.ReadUint32Le(8)
+			require.True(t, ok)
+			require.Equal(t, uint32(0), v)
+
+			// Bulk memory operations.
+			_, err = call("copy", 16, 0, 5)
+			require.NoError(t, err)
+			buf, ok = mem2.Read(16, 5)
+			require.True(t, ok)
+			require.Equal(t, "hello", string(buf))
+			_, err = call("fill2", 32, 'x', 3)
+			require.NoError(t, err)
+			buf, ok = mem2.Read(32, 3)
+			require.True(t, ok)
+			require.Equal(t, "xxx", string(buf))
+			_, err = call("init2", 40, 0, 5)
+			require.NoError(t, err)
+			buf, ok = mem2.Read(40, 5)
+			require.True(t, ok)
+			require.Equal(t, "world", string(buf))
+
+			// Out of bounds accesses are checked against the right memory.
+			_, err = call("load2", uint64(wasm.MemoryPageSize-2))
+			require.Error(t, err)
+			_, err = call("load1", uint64(wasm.MemoryPageSize))
+			require.Error(t, err)
+
+			// Growing memory 1 does not affect the other memories.
+			res, err = call("size1")
+			require.NoError(t, err)
+			require.Equal(t, []uint64{1}, res)
+			res, err = call("grow1", 1)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{1}, res)
+			res, err = call("grow1", 1)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{0xffffffff}, res)
+			res, err = call("size1")
+			require.NoError(t, err)
+			require.Equal(t, []uint64{2}, res)
+			require.Equal(t, uint32(2*wasm.MemoryPageSize), mem1.Size())
+			require.Equal(t, uint32(wasm.MemoryPageSize), mem2.Size())
+			require.Equal(t, uint32(wasm.MemoryPageSize), inst.Memory().Size())
+			require.True(t, mem1.WriteByte(wasm.MemoryPageSize, 42))
+			res, err = call("load1", uint64(wasm.MemoryPageSize))
+			require.NoError(t, err)
+			require.Equal(t, []uint64{42}, res)
+		})
+	}
+}
+
+func TestE2E_multi_memory_disabled(t *testing.T) {
+	r := wazero.NewRuntime(context.Background())
+	defer r.Close(context.Background())
+
+	_, err := r.CompileModule(context.Background(), binaryencoding.EncodeModule(&wasm.Module{
+		MemorySection: []wasm.Memory{{Min: 1, Cap: 1}, {Min: 1, Cap: 1}},
+	}))
+	require.Error(t, err)
+}
+
+// TestE2E_multi_memory_imported imports the same memory twice, and verifies that
+// accesses through either memory index observe the same buffer, including after growth.
+func TestE2E_multi_memory_imported(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name string
+		cfg  wazero.RuntimeConfig
+	}{
+		{"interpreter", wazero.NewRuntimeConfigInterpreter()},
+		{"default", wazero.NewRuntimeConfig()},
+	} {
+		config := tc.cfg.WithCoreFeatures(api.CoreFeaturesV2 | experimental.CoreFeaturesMultiMemory)
+
+		t.Run(tc.name, func(t *testing.T) {
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+			defer func() {
+				require.NoError(t, r.Close(ctx))
+			}()
+
+			exporter, err := r.InstantiateWithConfig(ctx, binaryencoding.EncodeModule(&wasm.Module{
+				MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true}},
+				ExportSection: []wasm.Export{{Name: "mem", Type: wasm.ExternTypeMemory, Index: 0}},
+			}), wazero.NewModuleConfig().WithName("env"))
+			require.NoError(t, err)
+
+			desc := &wasm.Memory{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true}
+			inst, err := r.Instantiate(ctx, binaryencoding.EncodeModule(&wasm.Module{
+				TypeSection: []wasm.FunctionType{
+					{Params: []wasm.ValueType{i32, i32}},                            // type 0: (i32, i32) -> ()
+					{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}, // type 1: (i32) -> i32
+				},
+				ImportMemoryCount: 2,
+				ImportSection: []wasm.Import{
+					{Module: "env", Name: "mem", Type: wasm.ExternTypeMemory, DescMem: desc},
+					{Module: "env", Name: "mem", Type: wasm.ExternTypeMemory, DescMem: desc},
+				},
+				FunctionSection: []wasm.Index{0, 1, 1},
+				CodeSection: []wasm.Code{
+					{Body: []byte{ // store1(addr, v) -> i32.store memory 1
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeLocalGet, 1,
+						wasm.OpcodeI32Store, wasm.MemArgMemoryIndexFlag | 0x2, 1, 0,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // load0(addr) -> i32.load memory 0
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeI32Load, 0x2, 0,
+						wasm.OpcodeEnd,
+					}},
+					{Body: []byte{ // grow1(n) -> memory.grow memory 1
+						wasm.OpcodeLocalGet, 0,
+						wasm.OpcodeMemoryGrow, 1,
+						wasm.OpcodeEnd,
+					}},
+				},
+				ExportSection: []wasm.Export{
+					{Name: "store1", Type: wasm.ExternTypeFunc, Index: 0},
+					{Name: "load0", Type: wasm.ExternTypeFunc, Index: 1},
+					{Name: "grow1", Type: wasm.ExternTypeFunc, Index: 2},
+				},
+			}))
+			require.NoError(t, err)
+
+			_, err = inst.ExportedFunction("store1").Call(ctx, 4, 0xcafe)
+			require.NoError(t, err)
+			res, err := inst.ExportedFunction("load0").Call(ctx, 4)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{0xcafe}, res)
+
+			// Growing through memory 1 is visible through memory 0.
+			res, err = inst.ExportedFunction("grow1").Call(ctx, 1)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{1}, res)
+			require.Equal(t, uint32(2*wasm.MemoryPageSize), exporter.Memory().Size())
+			_, err = inst.ExportedFunction("store1").Call(ctx, uint64(wasm.MemoryPageSize), 0xbeef)
+			require.NoError(t, err)
+			res, err = inst.ExportedFunction("load0").Call(ctx, uint64(wasm.MemoryPageSize))
+			require.NoError(t, err)
+			require.Equal(t, []uint64{0xbeef}, res)
+		})
+	}
+}
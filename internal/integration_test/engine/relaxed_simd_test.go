@@ -0,0 +1,239 @@
+package adhoc
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// TestE2E_relaxedSIMD exercises each relaxed SIMD instruction with inputs whose results are
+// deterministic, so that every engine and architecture must agree on them.
+func TestE2E_relaxedSIMD(t *testing.T) {
+	ctx := context.Background()
+
+	dotA := [16]int8{1, -2, 3, -4, 5, -6, 7, -8, 127, -128, 100, -100, 0, 1, -1, 64}
+	dotB := [16]int8{1, 2, 3, 4, 5, 6, 7, 8, 127, 127, 2, 3, 0, 127, 127, 127}
+	var dot [8]int16
+	for i := range dot {
+		dot[i] = int16(dotA[2*i])*int16(dotB[2*i]) + int16(dotA[2*i+1])*int16(dotB[2*i+1])
+	}
+	dotAddC := [4]int32{1, -1, 1000, math.MaxInt32 - 100}
+	var dotAdd [4]int32
+	for i := range dotAdd {
+		dotAdd[i] = int32(dot[2*i]) + int32(dot[2*i+1]) + dotAddC[i]
+	}
+
+	for _, tc := range []struct {
+		op     wasm.OpcodeVecRelaxed
+		params [][2]uint64
+		exp    [2]uint64
+	}{
+		{
+			op: wasm.OpcodeVecI8x16RelaxedSwizzle,
+			params: [][2]uint64{
+				i8x16(0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15),
+				i8x16(15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0),
+			},
+			exp: i8x16(15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0),
+		},
+		{
+			op:     wasm.OpcodeVecI32x4RelaxedTruncF32x4S,
+			params: [][2]uint64{f32x4(1.5, -2.7, 100, 0)},
+			exp:    i32x4(1, -2, 100, 0),
+		},
+		{
+			op:     wasm.OpcodeVecI32x4RelaxedTruncF32x4U,
+			params: [][2]uint64{f32x4(1.5, 2.7, 100, 3e9)},
+			exp:    i32x4(1, 2, 100, -1294967296),
+		},
+		{
+			op:     wasm.OpcodeVecI32x4RelaxedTruncF64x2SZero,
+			params: [][2]uint64{f64x2(1.9, -3.2)},
+			exp:    i32x4(1, -3, 0, 0),
+		},
+		{
+			op:     wasm.OpcodeVecI32x4RelaxedTruncF64x2UZero,
+			params: [][2]uint64{f64x2(1.9, 3e9)},
+			exp:    i32x4(1, -1294967296, 0, 0),
+		},
+		{
+			op:     wasm.OpcodeVecF32x4RelaxedMadd,
+			params: [][2]uint64{f32x4(2, 3, 0.5, -1), f32x4(4, 5, 2, 8), f32x4(1, 1, 1, 1)},
+			exp:    f32x4(9, 16, 2, -7),
+		},
+		{
+			op:     wasm.OpcodeVecF32x4RelaxedNmadd,
+			params: [][2]uint64{f32x4(2, 3, 0.5, -1), f32x4(4, 5, 2, 8), f32x4(1, 1, 1, 1)},
+			exp:    f32x4(-7, -14, 0, 9),
+		},
+		{
+			op:     wasm.OpcodeVecF64x2RelaxedMadd,
+			params: [][2]uint64{f64x2(1.5, -2), f64x2(2, 3), f64x2(0.25, 1)},
+			exp:    f64x2(3.25, -5),
+		},
+		{
+			op:     wasm.OpcodeVecF64x2RelaxedNmadd,
+			params: [][2]uint64{f64x2(1.5, -2), f64x2(2, 3), f64x2(0.25, 1)},
+			exp:    f64x2(-2.75, 7),
+		},
+		{
+			op:     wasm.OpcodeVecI8x16RelaxedLaneselect,
+			params: [][2]uint64{{0x1111111111111111, 0x1111111111111111}, {0x2222222222222222, 0x2222222222222222}, {0xff00ff00ff00ff00, 0x00ff00ff00ff00ff}},
+			exp:    [2]uint64{0x1122112211221122, 0x2211221122112211},
+		},
+		{
+			op:     wasm.OpcodeVecI16x8RelaxedLaneselect,
+			params: [][2]uint64{{0x1111111111111111, 0x1111111111111111}, {0x2222222222222222, 0x2222222222222222}, {0xffff0000ffff0000, 0x0000ffff0000ffff}},
+			exp:    [2]uint64{0x1111222211112222, 0x2222111122221111},
+		},
+		{
+			op:     wasm.OpcodeVecI32x4RelaxedLaneselect,
+			params: [][2]uint64{{0x1111111111111111, 0x1111111111111111}, {0x2222222222222222, 0x2222222222222222}, {0xffffffff00000000, 0x00000000ffffffff}},
+			exp:    [2]uint64{0x1111111122222222, 0x2222222211111111},
+		},
+		{
+			op:     wasm.OpcodeVecI64x2RelaxedLaneselect,
+			params: [][2]uint64{{0x1111111111111111, 0x1111111111111111}, {0x2222222222222222, 0x2222222222222222}, {0xffffffffffffffff, 0}},
+			exp:    [2]uint64{0x1111111111111111, 0x2222222222222222},
+		},
+		{
+			op:     wasm.OpcodeVecF32x4RelaxedMin,
+			params: [][2]uint64{f32x4(1, -2, 3, 4), f32x4(2, -3, 3, 0.5)},
+			exp:    f32x4(1, -3, 3, 0.5),
+		},
+		{
+			op:     wasm.OpcodeVecF32x4RelaxedMax,
+			params: [][2]uint64{f32x4(1, -2, 3, 4), f32x4(2, -3, 3, 0.5)},
+			exp:    f32x4(2, -2, 3, 4),
+		},
+		{
+			op:     wasm.OpcodeVecF64x2RelaxedMin,
+			params: [][2]uint64{f64x2(1, -2), f64x2(2, -3)},
+			exp:    f64x2(1, -3),
+		},
+		{
+			op:     wasm.OpcodeVecF64x2RelaxedMax,
+			params: [][2]uint64{f64x2(1, -2), f64x2(2, -3)},
+			exp:    f64x2(2, -2),
+		},
+		{
+			op:     wasm.OpcodeVecI16x8RelaxedQ15mulrS,
+			params: [][2]uint64{i16x8(16384, -16384, 32767, 100, 0, 1, -1, -32768), i16x8(16384, 16384, 32767, 200, 5, 1, 32767, 16384)},
+			exp:    i16x8(8192, -8192, 32766, 1, 0, 0, -1, -16384),
+		},
+		{
+			op:     wasm.OpcodeVecI16x8RelaxedDotI8x16I7x16S,
+			params: [][2]uint64{i8x16(dotA[:]...), i8x16(dotB[:]...)},
+			exp:    i16x8(dot[:]...),
+		},
+		{
+			op:     wasm.OpcodeVecI32x4RelaxedDotI8x16I7x16AddS,
+			params: [][2]uint64{i8x16(dotA[:]...), i8x16(dotB[:]...), i32x4(dotAddC[:]...)},
+			exp:    i32x4(dotAdd[:]...),
+		},
+	} {
+		tc := tc
+		name := wasm.RelaxedVectorInstructionName(tc.op)
+		t.Run(name, func(t *testing.T) {
+			var ft wasm.FunctionType
+			var body []byte
+			var params []uint64
+			for i, p := range tc.params {
+				ft.Params = append(ft.Params, v128)
+				body = append(body, wasm.OpcodeLocalGet, byte(i))
+				params = append(params, p[0], p[1])
+			}
+			ft.Results = []wasm.ValueType{v128}
+			body = append(body, wasm.OpcodeVecPrefix, byte(tc.op&0x7f|0x80), byte(tc.op>>7), wasm.OpcodeEnd)
+
+			bin := binaryencoding.EncodeModule(&wasm.Module{
+				TypeSection:     []wasm.FunctionType{ft},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []wasm.Code{{Body: body}},
+				ExportSection:   []wasm.Export{{Name: "f", Type: wasm.ExternTypeFunc, Index: 0}},
+			})
+
+			for _, cfg := range []struct {
+				name string
+				cfg  wazero.RuntimeConfig
+			}{
+				{"interpreter", wazero.NewRuntimeConfigInterpreter()},
+				{"default", wazero.NewRuntimeConfig()},
+			} {
+				t.Run(cfg.name, func(t *testing.T) {
+					r := wazero.NewRuntimeWithConfig(ctx, cfg.cfg.
+						WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesRelaxedSIMD))
+					defer r.Close(ctx)
+
+					inst, err := r.Instantiate(ctx, bin)
+					require.NoError(t, err)
+
+					res, err := inst.ExportedFunction("f").Call(ctx, params...)
+					require.NoError(t, err)
+					require.Equal(t, tc.exp[:], res)
+				})
+			}
+		})
+	}
+}
+
+func TestE2E_relaxedSIMD_disabled(t *testing.T) {
+	r := wazero.NewRuntime(context.Background())
+	defer r.Close(context.Background())
+
+	_, err := r.CompileModule(context.Background(), binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{v128, v128}, Results: []wasm.ValueType{v128}}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeVecPrefix, 0x8d, 0x02, // f32x4.relaxed_min
+			wasm.OpcodeEnd,
+		}}},
+	}))
+	require.EqualError(t, err, "invalid function[0]: f32x4.relaxed_min invalid as feature \"relaxed-simd\" is disabled")
+}
+
+func i8x16[T int8 | int](lanes ...T) (ret [2]uint64) {
+	var b [16]byte
+	for i, l := range lanes {
+		b[i] = byte(l)
+	}
+	return [2]uint64{binary.LittleEndian.Uint64(b[:8]), binary.LittleEndian.Uint64(b[8:])}
+}
+
+func i16x8[T int16 | int](lanes ...T) (ret [2]uint64) {
+	var b [16]byte
+	for i, l := range lanes {
+		binary.LittleEndian.PutUint16(b[2*i:], uint16(l))
+	}
+	return [2]uint64{binary.LittleEndian.Uint64(b[:8]), binary.LittleEndian.Uint64(b[8:])}
+}
+
+func i32x4[T int32 | int](lanes ...T) (ret [2]uint64) {
+	var b [16]byte
+	for i, l := range lanes {
+		binary.LittleEndian.PutUint32(b[4*i:], uint32(l))
+	}
+	return [2]uint64{binary.LittleEndian.Uint64(b[:8]), binary.LittleEndian.Uint64(b[8:])}
+}
+
+func f32x4(lanes ...float32) (ret [2]uint64) {
+	var b [16]byte
+	for i, l := range lanes {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(l))
+	}
+	return [2]uint64{binary.LittleEndian.Uint64(b[:8]), binary.LittleEndian.Uint64(b[8:])}
+}
+
+func f64x2(lanes ...float64) (ret [2]uint64) {
+	return [2]uint64{math.Float64bits(lanes[0]), math.Float64bits(lanes[1])}
+}
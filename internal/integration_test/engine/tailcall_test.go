@@ -2,6 +2,7 @@ package adhoc
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/tetratelabs/wazero"
@@ -11,6 +12,7 @@ import (
 	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/sys"
 )
 
 // TestE2E_tail_call_import implements a test case similar to testcases.TailCallManyParams,
@@ -240,3 +242,138 @@ func TestE2E_tail_call_import_indirect(t *testing.T) {
 		})
 	}
 }
+
+// TestE2E_tail_call_cross_module ensures tail calls between functions of different modules,
+// both to an imported function and through a shared table, do not grow the call stack.
+func TestE2E_tail_call_cross_module(t *testing.T) {
+	ctx := context.Background()
+
+	// ping(n) returns 42 if n is zero, or tail calls table[0](n-1) otherwise.
+	ping := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0},
+		TableSection:    []wasm.Table{{Type: wasm.RefTypeFuncref, Min: 1}},
+		CodeSection: []wasm.Code{{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Eqz,
+			wasm.OpcodeIf, i32.Kind(),
+			wasm.OpcodeI32Const, 42,
+			wasm.OpcodeElse,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Const, 1,
+			wasm.OpcodeI32Sub,
+			wasm.OpcodeI32Const, 0, // table index 0
+			wasm.OpcodeTailCallReturnCallIndirect, 0, 0, // tail call indirect type 0, table 0
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []wasm.Export{
+			{Name: "ping", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "table", Type: wasm.ExternTypeTable, Index: 0},
+		},
+	})
+
+	// pong(n) tail calls the imported ping(n), and is set to table[0] of the ping module.
+	pong := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:         []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+		ImportFunctionCount: 1,
+		ImportTableCount:    1,
+		ImportSection: []wasm.Import{
+			{Module: "ping", Name: "ping", Type: wasm.ExternTypeFunc, DescFunc: 0},
+			{Module: "ping", Name: "table", Type: wasm.ExternTypeTable, DescTable: wasm.Table{Type: wasm.RefTypeFuncref, Min: 1}},
+		},
+		FunctionSection: []wasm.Index{0},
+		ElementSection: []wasm.ElementSegment{{
+			OffsetExpr: wasm.NewConstantExpressionFromI32(0),
+			Init:       []wasm.ConstantExpression{wasm.NewConstantExpressionFromOpcode(wasm.OpcodeRefFunc, []byte{1})},
+			Mode:       wasm.ElementModeActive,
+			Type:       wasm.RefTypeFuncref,
+		}},
+		CodeSection: []wasm.Code{{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeTailCallReturnCall, 0, // tail call the imported ping
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []wasm.Export{{Name: "pong", Type: wasm.ExternTypeFunc, Index: 1}},
+	})
+
+	for _, tc := range []struct {
+		name string
+		cfg  wazero.RuntimeConfig
+	}{
+		{"interpreter", wazero.NewRuntimeConfigInterpreter()},
+		{"default", wazero.NewRuntimeConfig()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := wazero.NewRuntimeWithConfig(ctx, tc.cfg.WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesTailCall))
+			defer func() {
+				require.NoError(t, r.Close(ctx))
+			}()
+
+			_, err := r.InstantiateWithConfig(ctx, ping, wazero.NewModuleConfig().WithName("ping"))
+			require.NoError(t, err)
+			inst, err := r.Instantiate(ctx, pong)
+			require.NoError(t, err)
+
+			// The depth is far beyond the limit of the call stack, so this only succeeds with
+			// constant stack usage.
+			res, err := inst.ExportedFunction("pong").Call(ctx, 1_000_000)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{42}, res)
+		})
+	}
+}
+
+// TestE2E_tail_call_cross_module_closed ensures a tail call into a function of a closed module fails,
+// as the exit code is checked against the module of the callee.
+func TestE2E_tail_call_cross_module_closed(t *testing.T) {
+	ctx := context.Background()
+
+	// loop() loops once, and returns 42.
+	callee := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Results: []wasm.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{{Body: []byte{
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Const, 42,
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []wasm.Export{{Name: "loop", Type: wasm.ExternTypeFunc, Index: 0}},
+	})
+
+	// call() tail calls the imported loop.
+	caller := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:         []wasm.FunctionType{{Results: []wasm.ValueType{i32}}},
+		ImportFunctionCount: 1,
+		ImportSection:       []wasm.Import{{Module: "callee", Name: "loop", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+		FunctionSection:     []wasm.Index{0},
+		CodeSection: []wasm.Code{{Body: []byte{
+			wasm.OpcodeTailCallReturnCall, 0,
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []wasm.Export{{Name: "call", Type: wasm.ExternTypeFunc, Index: 1}},
+	})
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter().
+		WithCoreFeatures(api.CoreFeaturesV2|experimental.CoreFeaturesTailCall).
+		WithCloseOnContextDone(true))
+	defer func() {
+		require.NoError(t, r.Close(ctx))
+	}()
+
+	calleeInst, err := r.InstantiateWithConfig(ctx, callee, wazero.NewModuleConfig().WithName("callee"))
+	require.NoError(t, err)
+	inst, err := r.Instantiate(ctx, caller)
+	require.NoError(t, err)
+
+	res, err := inst.ExportedFunction("call").Call(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{42}, res)
+
+	require.NoError(t, calleeInst.CloseWithExitCode(ctx, 3))
+	_, err = inst.ExportedFunction("call").Call(ctx)
+	var exitErr *sys.ExitError
+	require.True(t, errors.As(err, &exitErr), "%v", err)
+	require.Equal(t, uint32(3), exitErr.ExitCode())
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/engine/interpreter"
 	"github.com/tetratelabs/wazero/internal/integration_test/spectest"
 	"github.com/tetratelabs/wazero/internal/platform"
 )
@@ -19,6 +20,20 @@ func TestCompiler(t *testing.T) {
 	spectest.Run(t, Testcases, context.Background(), wazero.NewRuntimeConfigCompiler().WithCoreFeatures(enabledFeatures))
 }
 
+func TestCompiler_memoryGuardPages(t *testing.T) {
+	if !platform.MemoryGuardPagesSupported() {
+		t.Skip()
+	}
+	spectest.Run(t, Testcases, context.Background(), wazero.NewRuntimeConfigCompiler().WithCoreFeatures(enabledFeatures).
+		WithMemoryGuardPages(true))
+}
+
 func TestInterpreter(t *testing.T) {
 	spectest.Run(t, Testcases, context.Background(), wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(enabledFeatures))
 }
+
+func TestTiered(t *testing.T) {
+	defer func(threshold uint32) { interpreter.TierUpThreshold = threshold }(interpreter.TierUpThreshold)
+	interpreter.TierUpThreshold = 1
+	spectest.Run(t, Testcases, context.Background(), wazero.NewRuntimeConfigTiered().WithCoreFeatures(enabledFeatures))
+}
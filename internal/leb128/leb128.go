@@ -130,19 +130,30 @@ func decodeUint32(next nextByte) (ret uint32, bytesRead uint64, err error) {
 	return 0, 0, errOverflow32
 }
 
+func DecodeUint64(r io.ByteReader) (ret uint64, bytesRead uint64, err error) {
+	return decodeUint64(func(_ int) (byte, error) { return r.ReadByte() })
+}
+
 func LoadUint64(buf []byte) (ret uint64, bytesRead uint64, err error) {
-	bufLen := len(buf)
-	if bufLen == 0 {
+	if len(buf) == 0 {
 		return 0, 0, io.EOF
 	}
+	return decodeUint64(func(i int) (byte, error) {
+		if i >= len(buf) {
+			return 0, io.EOF
+		}
+		return buf[i], nil
+	})
+}
 
+func decodeUint64(next nextByte) (ret uint64, bytesRead uint64, err error) {
 	// Derived from https://github.com/golang/go/blob/go1.24.0/src/encoding/binary/varint.go
 	var s uint64
 	for i := 0; i < maxVarintLen64; i++ {
-		if i >= bufLen {
-			return 0, 0, io.EOF
+		b, err := next(i)
+		if err != nil {
+			return 0, 0, err
 		}
-		b := buf[i]
 		if b < 0x80 {
 			// Unused bits (non first bit) must all be zero.
 			if i == maxVarintLen64-1 && b > 1 {
@@ -10,11 +10,15 @@ const (
 	CpuFeatureAmd64BMI1
 	// CpuExtraFeatureABM is the flag to query CpuFeatureFlags.Has for Advanced Bit Manipulation capabilities (e.g. LZCNT) on amd64
 	CpuFeatureAmd64ABM
+	// CpuFeatureAmd64FMA is the flag to query CpuFeatureFlags.Has for VEX-encoded fused multiply-add (e.g. VFMADD231PS) on amd64
+	CpuFeatureAmd64FMA
 )
 
 const (
 	// CpuFeatureArm64Atomic is the flag to query CpuFeatureFlags.Has for Large System Extensions capabilities on arm64
 	CpuFeatureArm64Atomic CpuFeatureFlags = 1 << iota
+	// CpuFeatureArm64DotProd is the flag to query CpuFeatureFlags.Has for the dot product instructions (e.g. SDOT) on arm64
+	CpuFeatureArm64DotProd
 )
 
 func (c CpuFeatureFlags) Has(f CpuFeatureFlags) bool {
@@ -18,5 +18,9 @@ func loadCpuFeatureFlags() (flags CpuFeatureFlags) {
 	if cpu.X86.HasBMI1 && cpu.X86.HasBMI2 && cpu.X86.HasPOPCNT {
 		flags |= CpuFeatureAmd64ABM
 	}
+	// The FMA instructions are VEX-encoded, so they also need the OS to save the AVX state.
+	if cpu.X86.HasFMA && cpu.X86.HasAVX {
+		flags |= CpuFeatureAmd64FMA
+	}
 	return
 }
@@ -9,5 +9,9 @@ func loadCpuFeatureFlags() (flags CpuFeatureFlags) {
 	if cpu.ARM64.HasATOMICS {
 		flags |= CpuFeatureArm64Atomic
 	}
+	// HasASIMDDP reports the ASIMDDP hwcap, which is FEAT_DotProd.
+	if cpu.ARM64.HasASIMDDP {
+		flags |= CpuFeatureArm64DotProd
+	}
 	return
 }
@@ -0,0 +1,70 @@
+package platform
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// guardedMemoryReservationSize is the size of the address space reserved for a linear memory by NewGuardedMemory.
+//
+// Any 32-bit address plus any 32-bit static offset plus the size of the widest access (16 bytes) falls within it,
+// so the compiled code can access the memory without bounds checks: out of bounds accesses fault on the pages
+// which are not yet made accessible by Reallocate.
+const guardedMemoryReservationSize = 8<<30 + 64<<10
+
+// guardedMemoryMaxSize is the maximum size of a linear memory with 32-bit addresses.
+const guardedMemoryMaxSize = 4 << 30
+
+// MemoryGuardPagesSupported returns true if NewGuardedMemory is supported on this platform.
+func MemoryGuardPagesSupported() bool {
+	return CompilerSupported()
+}
+
+// NewGuardedMemory reserves the address space for a linear memory with 32-bit addresses, of which only the first
+// bytes up to the size given to Reallocate are accessible. The returned LinearMemory never moves, so it can back
+// a shared memory.
+func NewGuardedMemory() (experimental.LinearMemory, error) {
+	buf, err := unix.Mmap(-1, 0, guardedMemoryReservationSize,
+		unix.PROT_NONE, unix.MAP_PRIVATE|unix.MAP_ANON|unix.MAP_NORESERVE)
+	if err != nil {
+		return nil, err
+	}
+	m := &guardedMemory{buf: buf}
+	runtime.SetFinalizer(m, (*guardedMemory).Free)
+	return m, nil
+}
+
+type guardedMemory struct {
+	buf []byte
+	// accessible is the size of the prefix of buf which is made readable and writable.
+	accessible uint64
+}
+
+// Reallocate implements experimental.LinearMemory.
+func (m *guardedMemory) Reallocate(size uint64) []byte {
+	if size > guardedMemoryMaxSize {
+		return nil
+	}
+	if size > m.accessible {
+		if err := unix.Mprotect(m.buf[m.accessible:size], unix.PROT_READ|unix.PROT_WRITE); err != nil {
+			return nil
+		}
+		m.accessible = size
+	}
+	return m.buf[:size:size]
+}
+
+// Free implements experimental.LinearMemory.
+func (m *guardedMemory) Free() {
+	if m.buf == nil {
+		return
+	}
+	runtime.SetFinalizer(m, nil)
+	if err := unix.Munmap(m.buf); err != nil {
+		panic(err)
+	}
+	m.buf = nil
+}
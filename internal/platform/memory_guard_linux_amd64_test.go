@@ -0,0 +1,37 @@
+package platform
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestNewGuardedMemory(t *testing.T) {
+	mem, err := NewGuardedMemory()
+	require.NoError(t, err)
+	defer mem.Free()
+
+	buf := mem.Reallocate(0)
+	require.Equal(t, 0, len(buf))
+	base := unsafe.SliceData(buf)
+	require.NotNil(t, base)
+
+	buf = mem.Reallocate(65536)
+	require.Equal(t, 65536, len(buf))
+	require.Equal(t, 65536, cap(buf))
+	require.Equal(t, base, unsafe.SliceData(buf))
+	buf[0], buf[65535] = 1, 2
+
+	// Growing doesn't move the memory nor clear its contents.
+	buf = mem.Reallocate(2 * 65536)
+	require.Equal(t, base, unsafe.SliceData(buf))
+	require.Equal(t, byte(1), buf[0])
+	require.Equal(t, byte(2), buf[65535])
+	buf[2*65536-1] = 3
+
+	require.Nil(t, mem.Reallocate(guardedMemoryMaxSize+1))
+
+	mem.Free()
+	mem.Free() // Free is idempotent.
+}
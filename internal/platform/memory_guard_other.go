@@ -0,0 +1,20 @@
+//go:build !(linux && amd64)
+
+package platform
+
+import (
+	"errors"
+
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// MemoryGuardPagesSupported returns true if NewGuardedMemory is supported on this platform.
+func MemoryGuardPagesSupported() bool {
+	return false
+}
+
+// NewGuardedMemory reserves the address space for a linear memory with 32-bit addresses, of which only the first
+// bytes up to the size given to Reallocate are accessible.
+func NewGuardedMemory() (experimental.LinearMemory, error) {
+	return nil, errors.New("memory guard pages are only supported on linux/amd64")
+}
@@ -1,8 +1,11 @@
 package sock
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"net/netip"
+	"strings"
 
 	"github.com/tetratelabs/wazero/experimental/sys"
 )
@@ -26,6 +29,57 @@ type TCPConn interface {
 	Shutdown(how int) sys.Errno
 }
 
+// TCPSocket is a pseudo-file representing a TCP socket opened by the guest,
+// which isn't connected yet.
+type TCPSocket interface {
+	sys.File
+
+	// Connect connects to the given address, returning the connection which
+	// replaces this socket. This blocks until connected, or until ctx is done.
+	//
+	// # Errors
+	//
+	// A zero sys.Errno is success. The below are expected otherwise:
+	//   - sys.EAFNOSUPPORT: the address isn't of the family of the socket.
+	//   - sys.ECONNREFUSED: nothing listens on the address.
+	//   - sys.EINTR: ctx was done before connecting.
+	Connect(ctx context.Context, addr netip.AddrPort) (TCPConn, sys.Errno)
+}
+
+// UDPConn is a pseudo-file representing a UDP socket.
+type UDPConn interface {
+	sys.File
+
+	// SendTo sends p as a single datagram to the given address.
+	SendTo(p []byte, addr netip.AddrPort) (n int, errno sys.Errno)
+
+	// RecvFrom receives a single datagram into p, returning the address it
+	// was sent from. The rest of a datagram larger than p is discarded.
+	//
+	// # Errors
+	//
+	// A zero sys.Errno is success. The below are expected otherwise:
+	//   - sys.EAGAIN: the socket is non-blocking and no datagram is
+	//     available.
+	RecvFrom(p []byte) (n int, addr netip.AddrPort, errno sys.Errno)
+}
+
+// Addresser is implemented by sockets which expose their addresses, like
+// getsockname and getpeername in POSIX.
+type Addresser interface {
+	// LocalAddr returns the address the socket is bound to, which is
+	// unspecified if it isn't bound yet.
+	LocalAddr() netip.AddrPort
+
+	// RemoteAddr returns the address the socket is connected to.
+	//
+	// # Errors
+	//
+	// A zero sys.Errno is success. The below are expected otherwise:
+	//   - sys.ENOTCONN: the socket isn't connected.
+	RemoteAddr() (netip.AddrPort, sys.Errno)
+}
+
 // ConfigKey is a context.Context Value key. Its associated value should be a Config.
 type ConfigKey struct{}
 
@@ -34,6 +88,20 @@ type ConfigKey struct{}
 type Config struct {
 	// TCPAddresses is a slice of the configured host:port pairs.
 	TCPAddresses []TCPAddress
+	// UDPAddresses is a slice of the configured host:port pairs to bind UDP
+	// sockets to.
+	UDPAddresses []TCPAddress
+	// Destinations is the allow-list of addresses guests may connect or send
+	// datagrams to.
+	Destinations []Destination
+}
+
+// Destination is an address guests may connect or send datagrams to.
+type Destination struct {
+	// Host is an IP address, or a CIDR prefix matching several.
+	Host string
+	// Port is the port number, or zero to allow any.
+	Port int
 }
 
 // TCPAddress is a host:port pair to pre-open.
@@ -54,14 +122,65 @@ func (c *Config) WithTCPListener(host string, port int) *Config {
 	return &ret
 }
 
+// WithUDPSocket implements the method of the same name in experimental/sock/Config.
+func (c *Config) WithUDPSocket(host string, port int) *Config {
+	ret := c.clone()
+	ret.UDPAddresses = append(ret.UDPAddresses, TCPAddress{host, port})
+	return &ret
+}
+
+// WithAllowedDestination implements the method of the same name in experimental/sock/Config.
+func (c *Config) WithAllowedDestination(host string, port int) *Config {
+	ret := c.clone()
+	ret.Destinations = append(ret.Destinations, Destination{host, port})
+	return &ret
+}
+
+// IsEmpty returns true if nothing is configured.
+func (c *Config) IsEmpty() bool {
+	return len(c.TCPAddresses) == 0 && len(c.UDPAddresses) == 0 && len(c.Destinations) == 0
+}
+
 // Makes a deep copy of this sockConfig.
 func (c *Config) clone() Config {
 	ret := *c
 	ret.TCPAddresses = make([]TCPAddress, 0, len(c.TCPAddresses))
 	ret.TCPAddresses = append(ret.TCPAddresses, c.TCPAddresses...)
+	ret.UDPAddresses = make([]TCPAddress, 0, len(c.UDPAddresses))
+	ret.UDPAddresses = append(ret.UDPAddresses, c.UDPAddresses...)
+	ret.Destinations = make([]Destination, 0, len(c.Destinations))
+	ret.Destinations = append(ret.Destinations, c.Destinations...)
 	return ret
 }
 
+// Sockets are the sockets built from a Config: the ones to pre-open, and the
+// destinations of the ones guests open.
+type Sockets struct {
+	TCPListeners []*net.TCPListener
+	UDPConns     []*net.UDPConn
+	AllowList    AllowList
+}
+
+// BuildSockets builds sockets from the current configuration.
+func (c *Config) BuildSockets() (*Sockets, error) {
+	allowList, err := c.BuildAllowList()
+	if err != nil {
+		return nil, err
+	}
+	tcpListeners, err := c.BuildTCPListeners()
+	if err != nil {
+		return nil, err
+	}
+	udpConns, err := c.BuildUDPConns()
+	if err != nil {
+		for _, l := range tcpListeners {
+			_ = l.Close() // Ignore errors, we are already cleaning.
+		}
+		return nil, err
+	}
+	return &Sockets{TCPListeners: tcpListeners, UDPConns: udpConns, AllowList: allowList}, nil
+}
+
 // BuildTCPListeners build listeners from the current configuration.
 func (c *Config) BuildTCPListeners() (tcpListeners []*net.TCPListener, err error) {
 	for _, tcpAddr := range c.TCPAddresses {
@@ -84,6 +203,76 @@ func (c *Config) BuildTCPListeners() (tcpListeners []*net.TCPListener, err error
 	return
 }
 
+// BuildUDPConns build UDP sockets from the current configuration.
+func (c *Config) BuildUDPConns() (udpConns []*net.UDPConn, err error) {
+	for _, udpAddr := range c.UDPAddresses {
+		var addr *net.UDPAddr
+		if addr, err = net.ResolveUDPAddr("udp", udpAddr.String()); err != nil {
+			break
+		}
+		var uc *net.UDPConn
+		if uc, err = net.ListenUDP("udp", addr); err != nil {
+			break
+		}
+		udpConns = append(udpConns, uc)
+	}
+	if err != nil {
+		// An error occurred, cleanup.
+		for _, uc := range udpConns {
+			_ = uc.Close() // Ignore errors, we are already cleaning.
+		}
+		udpConns = nil
+	}
+	return
+}
+
+// BuildAllowList parses the destinations of the current configuration.
+func (c *Config) BuildAllowList() (AllowList, error) {
+	allowList := make(AllowList, 0, len(c.Destinations))
+	for _, d := range c.Destinations {
+		var prefix netip.Prefix
+		var err error
+		if strings.Contains(d.Host, "/") {
+			prefix, err = netip.ParsePrefix(d.Host)
+		} else {
+			var addr netip.Addr
+			if addr, err = netip.ParseAddr(d.Host); err == nil {
+				addr = addr.Unmap()
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid destination %q: %w", d.Host, err)
+		}
+		if d.Port < 0 || d.Port > 0xffff {
+			return nil, fmt.Errorf("invalid destination port %d", d.Port)
+		}
+		allowList = append(allowList, allowed{prefix: prefix.Masked(), port: uint16(d.Port)})
+	}
+	return allowList, nil
+}
+
+// AllowList is the allow-list of addresses guests may connect or send
+// datagrams to. The zero value allows none.
+type AllowList []allowed
+
+type allowed struct {
+	prefix netip.Prefix
+	port   uint16
+}
+
+// Allows returns true if addr is in the allow-list. IPv4-mapped IPv6
+// addresses are matched as IPv4 addresses.
+func (l AllowList) Allows(addr netip.AddrPort) bool {
+	ip := addr.Addr().Unmap()
+	for _, a := range l {
+		if a.prefix.Contains(ip) && (a.port == 0 || a.port == addr.Port()) {
+			return true
+		}
+	}
+	return false
+}
+
 func (t TCPAddress) String() string {
 	return fmt.Sprintf("%s:%d", t.Host, t.Port)
 }
@@ -1,9 +1,11 @@
 package sys
 
 import (
+	"context"
 	"io"
 	"io/fs"
 	"net"
+	"net/netip"
 
 	"github.com/tetratelabs/wazero/experimental/sys"
 	"github.com/tetratelabs/wazero/internal/descriptor"
@@ -52,6 +54,10 @@ type FileEntry struct {
 	// File is always non-nil.
 	File sys.File
 
+	// Rights are nil unless the capabilities of this file descriptor are
+	// restricted, e.g. by a WASI policy.
+	Rights *FileRights
+
 	// direntCache is nil until DirentCache was called.
 	direntCache *DirentCache
 }
@@ -249,11 +255,38 @@ func (d *DirentCache) cachedDirents(n uint32) []sys.Dirent {
 	return d.dirents
 }
 
+// FileRights are the capabilities of a file descriptor, as a bitmask defined
+// by WASI. Base are the rights of the file descriptor itself, and Inheriting
+// the maximum rights of those opened from it.
+type FileRights struct {
+	Base, Inheriting uint64
+
+	// Path is the guest path of the file when opened from a pre-opened
+	// directory, e.g. "/tmp/data", or empty if unknown.
+	Path string
+}
+
 type FSContext struct {
 	// openedFiles is a map of file descriptor numbers (>=FdPreopen) to open files
 	// (or directories) and defaults to empty.
 	// TODO: This is unguarded, so not goroutine-safe!
 	openedFiles FileTable
+
+	// allowList is the allow-list of addresses sockets may connect or send
+	// datagrams to. Sockets cannot be opened when it is empty.
+	allowList socketapi.AllowList
+
+	// maxOpenFiles is the maximum count of open file descriptors, including
+	// stdio and pre-opens, or zero for no limit.
+	maxOpenFiles uint32
+}
+
+// canOpen returns sys.EMFILE if no more file descriptors can be opened.
+func (c *FSContext) canOpen() sys.Errno {
+	if c.maxOpenFiles != 0 && c.openedFiles.Len() >= int(c.maxOpenFiles) {
+		return sys.EMFILE
+	}
+	return 0
 }
 
 // FileTable is a specialization of the descriptor.Table type used to map file
@@ -268,6 +301,9 @@ func (c *FSContext) LookupFile(fd int32) (*FileEntry, bool) {
 // OpenFile opens the file into the table and returns its file descriptor.
 // The result must be closed by CloseFile or Close.
 func (c *FSContext) OpenFile(fs sys.FS, path string, flag sys.Oflag, perm fs.FileMode) (int32, sys.Errno) {
+	if errno := c.canOpen(); errno != 0 {
+		return 0, errno
+	}
 	if f, errno := fs.OpenFile(path, flag, perm); errno != 0 {
 		return 0, errno
 	} else {
@@ -321,6 +357,8 @@ func (c *FSContext) SockAccept(sockFD int32, nonblock bool) (int32, sys.Errno) {
 		return 0, sys.EBADF // Not a preopen
 	} else if sock, ok = e.File.(socketapi.TCPSock); !ok {
 		return 0, sys.EBADF // Not a sock
+	} else if errno := c.canOpen(); errno != 0 {
+		return 0, errno
 	}
 
 	conn, errno := sock.Accept()
@@ -346,6 +384,132 @@ func (c *FSContext) SockAccept(sockFD int32, nonblock bool) (int32, sys.Errno) {
 	}
 }
 
+// SockOpen opens a socket which isn't connected yet into the file table, and
+// returns its file descriptor. It is a socketapi.UDPConn if dgram is true, or
+// a socketapi.TCPSocket otherwise.
+//
+// This returns sys.EACCES unless destinations are allowed, as the socket
+// would be useless.
+func (c *FSContext) SockOpen(ipv6, dgram bool) (int32, sys.Errno) {
+	if len(c.allowList) == 0 {
+		return 0, sys.EACCES
+	} else if errno := c.canOpen(); errno != 0 {
+		return 0, errno
+	}
+
+	var file sys.File
+	if dgram {
+		network := "udp4"
+		if ipv6 {
+			network = "udp6"
+		}
+		uc, err := net.ListenUDP(network, nil)
+		if err != nil {
+			return 0, sys.UnwrapOSError(err)
+		}
+		file = sysfs.NewUDPConnFile(uc)
+	} else {
+		file = sysfs.NewTCPSocketFile(ipv6)
+	}
+
+	if newFD, ok := c.openedFiles.Insert(&FileEntry{File: file}); !ok {
+		_ = file.Close()
+		return 0, sys.EBADF
+	} else {
+		return newFD, 0
+	}
+}
+
+// SockConnect connects the socketapi.TCPSocket of the given file descriptor
+// to addr, replacing it with the resulting socketapi.TCPConn. This blocks
+// until connected, or until ctx is done.
+//
+// This returns sys.EACCES if addr isn't allowed.
+func (c *FSContext) SockConnect(ctx context.Context, fd int32, addr netip.AddrPort) sys.Errno {
+	var sock socketapi.TCPSocket
+	e, ok := c.LookupFile(fd)
+	if !ok {
+		return sys.EBADF // Not open
+	} else if sock, ok = e.File.(socketapi.TCPSocket); !ok {
+		return sys.EBADF // Not a socket which isn't connected yet.
+	} else if !c.allowList.Allows(addr) {
+		return sys.EACCES
+	}
+
+	conn, errno := sock.Connect(ctx, addr)
+	if errno != 0 {
+		return errno
+	}
+	e.File = conn
+	return 0
+}
+
+// SockAllowed returns true if sockets may connect or send datagrams to addr.
+func (c *FSContext) SockAllowed(addr netip.AddrPort) bool {
+	return c.allowList.Allows(addr)
+}
+
+// maxDatagramLen is the maximum length of a UDP datagram.
+const maxDatagramLen = 0xffff
+
+// SockSendTo sends bufs as a single datagram to addr, from the
+// socketapi.UDPConn of the given file descriptor, and returns the count of
+// bytes sent.
+//
+// This returns sys.EACCES if addr isn't allowed.
+func (c *FSContext) SockSendTo(fd int32, bufs [][]byte, addr netip.AddrPort) (int, sys.Errno) {
+	conn, errno := c.udpConn(fd)
+	if errno != 0 {
+		return 0, errno
+	} else if !c.allowList.Allows(addr) {
+		return 0, sys.EACCES
+	}
+
+	var buf []byte
+	for _, b := range bufs {
+		buf = append(buf, b...)
+	}
+	return conn.SendTo(buf, addr)
+}
+
+// SockRecvFrom receives a datagram into bufs, from the socketapi.UDPConn of
+// the given file descriptor, and returns the count of bytes received and the
+// address it was sent from. The rest of a datagram larger than bufs is
+// discarded.
+func (c *FSContext) SockRecvFrom(fd int32, bufs [][]byte) (int, netip.AddrPort, sys.Errno) {
+	conn, errno := c.udpConn(fd)
+	if errno != 0 {
+		return 0, netip.AddrPort{}, errno
+	}
+
+	// Receive the datagram at once, then scatter it into bufs.
+	var bufLen int
+	for _, b := range bufs {
+		bufLen += len(b)
+	}
+	buf := make([]byte, min(bufLen, maxDatagramLen))
+	n, addr, errno := conn.RecvFrom(buf)
+	if errno != 0 {
+		return 0, netip.AddrPort{}, errno
+	}
+	rest := buf[:n]
+	for _, b := range bufs {
+		rest = rest[copy(b, rest):]
+	}
+	return n, addr, 0
+}
+
+// udpConn returns the socketapi.UDPConn of the given file descriptor.
+func (c *FSContext) udpConn(fd int32) (socketapi.UDPConn, sys.Errno) {
+	if e, ok := c.LookupFile(fd); !ok {
+		return nil, sys.EBADF // Not open
+	} else if conn, ok := e.File.(socketapi.UDPConn); !ok {
+		return nil, sys.EBADF // Not a datagram socket
+	} else {
+		return conn, 0
+	}
+}
+
 // CloseFile returns any error closing the existing file.
 func (c *FSContext) CloseFile(fd int32) (errno sys.Errno) {
 	f, ok := c.openedFiles.Lookup(fd)
@@ -374,12 +538,13 @@ func (c *FSContext) Close() (err error) {
 }
 
 // InitFSContext initializes a FSContext with stdio streams and optional
-// pre-opened filesystems and TCP listeners.
+// pre-opened filesystems and sockets.
 func (c *Context) InitFSContext(
 	stdin io.Reader,
 	stdout, stderr io.Writer,
 	fs []sys.FS, guestPaths []string,
-	tcpListeners []*net.TCPListener,
+	maxOpenFiles uint32,
+	socks *socketapi.Sockets,
 ) (err error) {
 	inFile, err := stdinFileEntry(stdin)
 	if err != nil {
@@ -396,6 +561,7 @@ func (c *Context) InitFSContext(
 		return err
 	}
 	c.fsc.openedFiles.Insert(errWriter)
+	c.fsc.maxOpenFiles = maxOpenFiles
 
 	for i, f := range fs {
 		guestPath := guestPaths[i]
@@ -412,9 +578,16 @@ func (c *Context) InitFSContext(
 		})
 	}
 
-	for _, tl := range tcpListeners {
+	if socks == nil {
+		return nil
+	}
+	for _, tl := range socks.TCPListeners {
 		c.fsc.openedFiles.Insert(&FileEntry{IsPreopen: true, File: sysfs.NewTCPListenerFile(tl)})
 	}
+	for _, uc := range socks.UDPConns {
+		c.fsc.openedFiles.Insert(&FileEntry{IsPreopen: true, File: sysfs.NewUDPConnFile(uc)})
+	}
+	c.fsc.allowList = socks.AllowList
 	return nil
 }
 
@@ -57,7 +57,7 @@ func TestNewFSContext(t *testing.T) {
 			for _, root := range []string{"/", ""} {
 				t.Run(fmt.Sprintf("root = '%s'", root), func(t *testing.T) {
 					c := Context{}
-					err := c.InitFSContext(nil, nil, nil, []sys.FS{tc.fs}, []string{root}, nil)
+					err := c.InitFSContext(nil, nil, nil, []sys.FS{tc.fs}, []string{root}, 0, nil)
 					require.NoError(t, err)
 					fsc := c.fsc
 					defer fsc.Close()
@@ -99,7 +99,7 @@ func TestFSContext_CloseFile(t *testing.T) {
 	testFS := &sysfs.AdaptFS{FS: embedFS}
 
 	c := Context{}
-	err = c.InitFSContext(nil, nil, nil, []sys.FS{testFS}, []string{"/"}, nil)
+	err = c.InitFSContext(nil, nil, nil, []sys.FS{testFS}, []string{"/"}, 0, nil)
 	require.NoError(t, err)
 	fsc := c.fsc
 	defer fsc.Close()
@@ -129,9 +129,33 @@ func TestFSContext_CloseFile(t *testing.T) {
 	})
 }
 
+func TestFSContext_maxOpenFiles(t *testing.T) {
+	embedFS, err := fs.Sub(testdata, "testdata")
+	require.NoError(t, err)
+	testFS := &sysfs.AdaptFS{FS: embedFS}
+
+	// Leave room for one file after stdio and the pre-open.
+	c := Context{}
+	err = c.InitFSContext(nil, nil, nil, []sys.FS{testFS}, []string{"/"}, uint32(FdPreopen+2), nil)
+	require.NoError(t, err)
+	fsc := c.fsc
+	defer fsc.Close()
+
+	fd, errno := fsc.OpenFile(testFS, "empty.txt", sys.O_RDONLY, 0)
+	require.EqualErrno(t, 0, errno)
+
+	_, errno = fsc.OpenFile(testFS, "test.txt", sys.O_RDONLY, 0)
+	require.EqualErrno(t, sys.EMFILE, errno)
+
+	// Closing a file allows opening another.
+	require.EqualErrno(t, 0, fsc.CloseFile(fd))
+	_, errno = fsc.OpenFile(testFS, "test.txt", sys.O_RDONLY, 0)
+	require.EqualErrno(t, 0, errno)
+}
+
 func TestFSContext_noPreopens(t *testing.T) {
 	c := Context{}
-	err := c.InitFSContext(nil, nil, nil, nil, nil, nil)
+	err := c.InitFSContext(nil, nil, nil, nil, nil, 0, nil)
 	require.NoError(t, err)
 	testFS := &c.fsc
 	require.NoError(t, err)
@@ -157,7 +181,7 @@ func TestContext_Close(t *testing.T) {
 	testFS := &sysfs.AdaptFS{FS: testfs.FS{"foo": &testfs.File{}}}
 
 	c := Context{}
-	err := c.InitFSContext(nil, nil, nil, []sys.FS{testFS}, []string{"/"}, nil)
+	err := c.InitFSContext(nil, nil, nil, []sys.FS{testFS}, []string{"/"}, 0, nil)
 	require.NoError(t, err)
 	fsc := c.fsc
 
@@ -184,7 +208,7 @@ func TestContext_Close_Error(t *testing.T) {
 	testFS := &sysfs.AdaptFS{FS: testfs.FS{"foo": file}}
 
 	c := Context{}
-	err := c.InitFSContext(nil, nil, nil, []sys.FS{testFS}, []string{"/"}, nil)
+	err := c.InitFSContext(nil, nil, nil, []sys.FS{testFS}, []string{"/"}, 0, nil)
 	require.NoError(t, err)
 	fsc := c.fsc
 
@@ -208,7 +232,7 @@ func TestFSContext_Renumber(t *testing.T) {
 	require.EqualErrno(t, 0, errno)
 
 	c := Context{}
-	err := c.InitFSContext(nil, nil, nil, []sys.FS{dirFS}, []string{"/"}, nil)
+	err := c.InitFSContext(nil, nil, nil, []sys.FS{dirFS}, []string{"/"}, 0, nil)
 	require.NoError(t, err)
 	fsc := c.fsc
 
@@ -256,7 +280,7 @@ func TestDirentCache_ReadNewFile(t *testing.T) {
 
 	c := Context{}
 	root := sysfs.DirFS(tmpDir)
-	err := c.InitFSContext(nil, nil, nil, []sys.FS{root}, []string{"/"}, nil)
+	err := c.InitFSContext(nil, nil, nil, []sys.FS{root}, []string{"/"}, 0, nil)
 	require.NoError(t, err)
 	fsc := c.fsc
 	defer fsc.Close()
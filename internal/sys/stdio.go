@@ -48,6 +48,14 @@ func (f *writerFile) Write(buf []byte) (int, experimentalsys.Errno) {
 	return n, experimentalsys.UnwrapOSError(err)
 }
 
+// Poll implements the same method as documented on experimentalsys.Pollable
+func (f *writerFile) Poll(flag experimentalsys.Pflag, timeoutMillis int32) (ready bool, errno experimentalsys.Errno) {
+	if p, ok := f.w.(experimentalsys.Pollable); ok {
+		return p.Poll(flag, timeoutMillis)
+	}
+	return f.noopStdoutFile.Poll(flag, timeoutMillis)
+}
+
 // noopStdinFile is a fs.ModeDevice file for use implementing FdStdin. This is
 // safer than reading from os.DevNull as it can never overrun operating system
 // file descriptors.
@@ -79,6 +87,14 @@ func (noopStdoutFile) Write(buf []byte) (int, experimentalsys.Errno) {
 	return len(buf), 0 // same as io.Discard
 }
 
+// Poll implements the same method as documented on experimentalsys.Pollable
+func (noopStdoutFile) Poll(flag experimentalsys.Pflag, timeoutMillis int32) (ready bool, errno experimentalsys.Errno) {
+	if flag != experimentalsys.POLLOUT {
+		return false, experimentalsys.ENOTSUP
+	}
+	return true, 0 // always ready to discard
+}
+
 type noopStdioFile struct {
 	experimentalsys.UnimplementedFile
 }
@@ -65,6 +65,37 @@ func TestStdinFilePoll_NonPollable(t *testing.T) {
 	require.False(t, ready)
 }
 
+// pollableWriter is a mock io.Writer that implements experimentalsys.Pollable.
+type pollableWriter struct {
+	strings.Builder
+	pollReady bool
+}
+
+func (w *pollableWriter) Poll(flag experimentalsys.Pflag, timeoutMillis int32) (bool, experimentalsys.Errno) {
+	return w.pollReady, 0
+}
+
+func TestWriterFilePoll(t *testing.T) {
+	timeout := int32(0) // return immediately
+
+	// When the writer implements Pollable, Poll delegates to it.
+	pw := &pollableWriter{}
+	ready, errno := (&writerFile{w: pw}).Poll(experimentalsys.POLLOUT, timeout)
+	require.EqualErrno(t, 0, errno)
+	require.False(t, ready)
+
+	// Otherwise, POLLOUT is always ready, as writes don't block.
+	f := &writerFile{w: &strings.Builder{}}
+	ready, errno = f.Poll(experimentalsys.POLLOUT, timeout)
+	require.EqualErrno(t, 0, errno)
+	require.True(t, ready)
+
+	// POLLIN is not supported on an output.
+	ready, errno = f.Poll(experimentalsys.POLLIN, timeout)
+	require.EqualErrno(t, experimentalsys.ENOTSUP, errno)
+	require.False(t, ready)
+}
+
 func TestStdio(t *testing.T) {
 	// simulate regular file attached to stdin
 	f, err := os.CreateTemp(t.TempDir(), "somefile")
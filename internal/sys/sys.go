@@ -4,11 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net"
 	"time"
 
 	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
 	"github.com/tetratelabs/wazero/internal/platform"
+	socketapi "github.com/tetratelabs/wazero/internal/sock"
 	"github.com/tetratelabs/wazero/sys"
 )
 
@@ -114,7 +114,7 @@ func (c *Context) RandSource() io.Reader {
 //
 // Note: This is only used for testing.
 func DefaultContext(fs experimentalsys.FS) *Context {
-	if sysCtx, err := NewContext(0, nil, nil, nil, nil, nil, nil, nil, 0, nil, 0, nil, nil, []experimentalsys.FS{fs}, []string{""}, nil); err != nil {
+	if sysCtx, err := NewContext(0, nil, nil, nil, nil, nil, nil, nil, 0, nil, 0, nil, nil, []experimentalsys.FS{fs}, []string{""}, 0, nil); err != nil {
 		panic(fmt.Errorf("BUG: DefaultContext should never error: %w", err))
 	} else {
 		return sysCtx
@@ -136,7 +136,8 @@ func NewContext(
 	nanosleep sys.Nanosleep,
 	osyield sys.Osyield,
 	fs []experimentalsys.FS, guestPaths []string,
-	tcpListeners []*net.TCPListener,
+	maxOpenFiles uint32,
+	socks *socketapi.Sockets,
 ) (sysCtx *Context, err error) {
 	sysCtx = &Context{args: args, environ: environ}
 
@@ -188,7 +189,7 @@ func NewContext(
 		sysCtx.osyield = platform.FakeOsyield
 	}
 
-	err = sysCtx.InitFSContext(stdin, stdout, stderr, fs, guestPaths, tcpListeners)
+	err = sysCtx.InitFSContext(stdin, stdout, stderr, fs, guestPaths, maxOpenFiles, socks)
 
 	return
 }
@@ -0,0 +1,218 @@
+package sysfs
+
+import (
+	"io/fs"
+	"path"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// AuditOp is the sys.FS function of an AuditEvent.
+type AuditOp uint8
+
+const (
+	AuditOpenFile AuditOp = iota + 1
+	AuditLstat
+	AuditStat
+	AuditMkdir
+	AuditChmod
+	AuditRename
+	AuditRmdir
+	AuditUnlink
+	AuditLink
+	AuditSymlink
+	AuditReadlink
+	AuditUtimens
+)
+
+// String implements fmt.Stringer
+func (o AuditOp) String() string {
+	switch o {
+	case AuditOpenFile:
+		return "OpenFile"
+	case AuditLstat:
+		return "Lstat"
+	case AuditStat:
+		return "Stat"
+	case AuditMkdir:
+		return "Mkdir"
+	case AuditChmod:
+		return "Chmod"
+	case AuditRename:
+		return "Rename"
+	case AuditRmdir:
+		return "Rmdir"
+	case AuditUnlink:
+		return "Unlink"
+	case AuditLink:
+		return "Link"
+	case AuditSymlink:
+		return "Symlink"
+	case AuditReadlink:
+		return "Readlink"
+	case AuditUtimens:
+		return "Utimens"
+	}
+	return "unknown"
+}
+
+// AuditEvent is a call to a function of an AuditFS.
+type AuditEvent struct {
+	Op AuditOp
+
+	// Mount is the guest path of the file system, e.g. "/" or "/tmp".
+	Mount string
+
+	// Path is the first path parameter, relative to the mount. For example,
+	// the `from` parameter of Rename.
+	//
+	// Note: For Symlink, this is the target, which is not resolved.
+	Path string
+
+	// NewPath is the second path parameter of Rename, Link or Symlink.
+	NewPath string
+
+	// Flag is the parameter of OpenFile.
+	Flag experimentalsys.Oflag
+
+	// Perm is the parameter of OpenFile, Mkdir or Chmod.
+	Perm fs.FileMode
+
+	// Errno is the result, passed to AuditHook.After.
+	Errno experimentalsys.Errno
+}
+
+// GuestPath returns Path as seen by the guest, e.g. "/tmp/file.txt".
+func (e AuditEvent) GuestPath() string {
+	return path.Join(e.Mount, e.Path)
+}
+
+// NewGuestPath returns NewPath as seen by the guest, or "" if there is none.
+func (e AuditEvent) NewGuestPath() string {
+	if e.NewPath == "" {
+		return ""
+	}
+	return path.Join(e.Mount, e.NewPath)
+}
+
+type AuditHook interface {
+	// Before is called before the function, which is denied unless the
+	// result is zero. The result is returned to the guest instead.
+	Before(e AuditEvent) experimentalsys.Errno
+
+	// After is called after the function, or when Before denied it, with the
+	// result in AuditEvent.Errno.
+	After(e AuditEvent)
+}
+
+type AuditFS struct {
+	experimentalsys.FS
+
+	// Mount is the guest path of FS, used for AuditEvent.Mount.
+	Mount string
+
+	Hook AuditHook
+}
+
+// audit calls fn unless denied by the hook, and returns its result.
+func (a *AuditFS) audit(e AuditEvent, fn func() experimentalsys.Errno) experimentalsys.Errno {
+	e.Mount = a.Mount
+	if e.Errno = a.Hook.Before(e); e.Errno == 0 {
+		e.Errno = fn()
+	}
+	a.Hook.After(e)
+	return e.Errno
+}
+
+// OpenFile implements the same method as documented on sys.FS
+func (a *AuditFS) OpenFile(path string, flag experimentalsys.Oflag, perm fs.FileMode) (f experimentalsys.File, errno experimentalsys.Errno) {
+	errno = a.audit(AuditEvent{Op: AuditOpenFile, Path: path, Flag: flag, Perm: perm}, func() (errno experimentalsys.Errno) {
+		f, errno = a.FS.OpenFile(path, flag, perm)
+		return
+	})
+	return
+}
+
+// Lstat implements the same method as documented on sys.FS
+func (a *AuditFS) Lstat(path string) (st sys.Stat_t, errno experimentalsys.Errno) {
+	errno = a.audit(AuditEvent{Op: AuditLstat, Path: path}, func() (errno experimentalsys.Errno) {
+		st, errno = a.FS.Lstat(path)
+		return
+	})
+	return
+}
+
+// Stat implements the same method as documented on sys.FS
+func (a *AuditFS) Stat(path string) (st sys.Stat_t, errno experimentalsys.Errno) {
+	errno = a.audit(AuditEvent{Op: AuditStat, Path: path}, func() (errno experimentalsys.Errno) {
+		st, errno = a.FS.Stat(path)
+		return
+	})
+	return
+}
+
+// Mkdir implements the same method as documented on sys.FS
+func (a *AuditFS) Mkdir(path string, perm fs.FileMode) experimentalsys.Errno {
+	return a.audit(AuditEvent{Op: AuditMkdir, Path: path, Perm: perm}, func() experimentalsys.Errno {
+		return a.FS.Mkdir(path, perm)
+	})
+}
+
+// Chmod implements the same method as documented on sys.FS
+func (a *AuditFS) Chmod(path string, perm fs.FileMode) experimentalsys.Errno {
+	return a.audit(AuditEvent{Op: AuditChmod, Path: path, Perm: perm}, func() experimentalsys.Errno {
+		return a.FS.Chmod(path, perm)
+	})
+}
+
+// Rename implements the same method as documented on sys.FS
+func (a *AuditFS) Rename(from, to string) experimentalsys.Errno {
+	return a.audit(AuditEvent{Op: AuditRename, Path: from, NewPath: to}, func() experimentalsys.Errno {
+		return a.FS.Rename(from, to)
+	})
+}
+
+// Rmdir implements the same method as documented on sys.FS
+func (a *AuditFS) Rmdir(path string) experimentalsys.Errno {
+	return a.audit(AuditEvent{Op: AuditRmdir, Path: path}, func() experimentalsys.Errno {
+		return a.FS.Rmdir(path)
+	})
+}
+
+// Unlink implements the same method as documented on sys.FS
+func (a *AuditFS) Unlink(path string) experimentalsys.Errno {
+	return a.audit(AuditEvent{Op: AuditUnlink, Path: path}, func() experimentalsys.Errno {
+		return a.FS.Unlink(path)
+	})
+}
+
+// Link implements the same method as documented on sys.FS
+func (a *AuditFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	return a.audit(AuditEvent{Op: AuditLink, Path: oldPath, NewPath: newPath}, func() experimentalsys.Errno {
+		return a.FS.Link(oldPath, newPath)
+	})
+}
+
+// Symlink implements the same method as documented on sys.FS
+func (a *AuditFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	return a.audit(AuditEvent{Op: AuditSymlink, Path: oldPath, NewPath: linkName}, func() experimentalsys.Errno {
+		return a.FS.Symlink(oldPath, linkName)
+	})
+}
+
+// Readlink implements the same method as documented on sys.FS
+func (a *AuditFS) Readlink(path string) (dst string, errno experimentalsys.Errno) {
+	errno = a.audit(AuditEvent{Op: AuditReadlink, Path: path}, func() (errno experimentalsys.Errno) {
+		dst, errno = a.FS.Readlink(path)
+		return
+	})
+	return
+}
+
+// Utimens implements the same method as documented on sys.FS
+func (a *AuditFS) Utimens(path string, atim, mtim int64) experimentalsys.Errno {
+	return a.audit(AuditEvent{Op: AuditUtimens, Path: path}, func() experimentalsys.Errno {
+		return a.FS.Utimens(path, atim, mtim)
+	})
+}
@@ -0,0 +1,129 @@
+package sysfs
+
+import (
+	"testing"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// recordingHook records events, and denies paths in deny with EACCES.
+type recordingHook struct {
+	before, after []AuditEvent
+	deny          map[string]struct{}
+}
+
+// Before implements AuditHook.Before
+func (h *recordingHook) Before(e AuditEvent) experimentalsys.Errno {
+	h.before = append(h.before, e)
+	if _, ok := h.deny[e.GuestPath()]; ok {
+		return experimentalsys.EACCES
+	}
+	return 0
+}
+
+// After implements AuditHook.After
+func (h *recordingHook) After(e AuditEvent) {
+	h.after = append(h.after, e)
+}
+
+func TestAuditFS(t *testing.T) {
+	hook := &recordingHook{}
+	testFS := &AuditFS{FS: newTestMemFS(t), Mount: "/tmp", Hook: hook}
+
+	f, errno := testFS.OpenFile("new.txt", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o600)
+	require.EqualErrno(t, 0, errno)
+	require.EqualErrno(t, 0, f.Close())
+	_, errno = testFS.Lstat("new.txt")
+	require.EqualErrno(t, 0, errno)
+	_, errno = testFS.Stat("missing")
+	require.EqualErrno(t, experimentalsys.ENOENT, errno)
+	require.EqualErrno(t, 0, testFS.Mkdir("dir2", 0o700))
+	require.EqualErrno(t, 0, testFS.Chmod("new.txt", 0o400))
+	require.EqualErrno(t, 0, testFS.Rename("new.txt", "dir2/new.txt"))
+	require.EqualErrno(t, 0, testFS.Link("dir2/new.txt", "link.txt"))
+	require.EqualErrno(t, 0, testFS.Symlink("link.txt", "symlink.txt"))
+	_, errno = testFS.Readlink("symlink.txt")
+	require.EqualErrno(t, 0, errno)
+	require.EqualErrno(t, 0, testFS.Utimens("link.txt", 1, 1))
+	require.EqualErrno(t, 0, testFS.Unlink("link.txt"))
+	require.EqualErrno(t, experimentalsys.ENOTEMPTY, testFS.Rmdir("dir2"))
+
+	expected := []AuditEvent{
+		{Op: AuditOpenFile, Mount: "/tmp", Path: "new.txt", Flag: experimentalsys.O_RDWR | experimentalsys.O_CREAT, Perm: 0o600},
+		{Op: AuditLstat, Mount: "/tmp", Path: "new.txt"},
+		{Op: AuditStat, Mount: "/tmp", Path: "missing", Errno: experimentalsys.ENOENT},
+		{Op: AuditMkdir, Mount: "/tmp", Path: "dir2", Perm: 0o700},
+		{Op: AuditChmod, Mount: "/tmp", Path: "new.txt", Perm: 0o400},
+		{Op: AuditRename, Mount: "/tmp", Path: "new.txt", NewPath: "dir2/new.txt"},
+		{Op: AuditLink, Mount: "/tmp", Path: "dir2/new.txt", NewPath: "link.txt"},
+		{Op: AuditSymlink, Mount: "/tmp", Path: "link.txt", NewPath: "symlink.txt"},
+		{Op: AuditReadlink, Mount: "/tmp", Path: "symlink.txt"},
+		{Op: AuditUtimens, Mount: "/tmp", Path: "link.txt"},
+		{Op: AuditUnlink, Mount: "/tmp", Path: "link.txt"},
+		{Op: AuditRmdir, Mount: "/tmp", Path: "dir2", Errno: experimentalsys.ENOTEMPTY},
+	}
+	require.Equal(t, expected, hook.after)
+
+	// Before is called the same, except the result isn't known yet.
+	for i := range expected {
+		expected[i].Errno = 0
+	}
+	require.Equal(t, expected, hook.before)
+}
+
+func TestAuditFS_deny(t *testing.T) {
+	hook := &recordingHook{deny: map[string]struct{}{"/animals.txt": {}}}
+	testFS := &AuditFS{FS: newTestMemFS(t), Mount: "/", Hook: hook}
+
+	_, errno := testFS.OpenFile("animals.txt", experimentalsys.O_RDONLY, 0)
+	require.EqualErrno(t, experimentalsys.EACCES, errno)
+	require.EqualErrno(t, experimentalsys.EACCES, testFS.Unlink("animals.txt"))
+
+	// The file wasn't deleted, and the denials were recorded.
+	_, errno = testFS.FS.Stat("animals.txt")
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, []AuditEvent{
+		{Op: AuditOpenFile, Mount: "/", Path: "animals.txt", Errno: experimentalsys.EACCES},
+		{Op: AuditUnlink, Mount: "/", Path: "animals.txt", Errno: experimentalsys.EACCES},
+	}, hook.after)
+}
+
+func TestAuditEvent_GuestPath(t *testing.T) {
+	tests := []struct {
+		name                          string
+		event                         AuditEvent
+		expectedPath, expectedNewPath string
+	}{
+		{
+			name:         "root mount",
+			event:        AuditEvent{Mount: "/", Path: "a/b.txt"},
+			expectedPath: "/a/b.txt",
+		},
+		{
+			name:         "root of mount",
+			event:        AuditEvent{Mount: "/tmp", Path: "."},
+			expectedPath: "/tmp",
+		},
+		{
+			name:            "new path",
+			event:           AuditEvent{Mount: "/tmp", Path: "a", NewPath: "b"},
+			expectedPath:    "/tmp/a",
+			expectedNewPath: "/tmp/b",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedPath, tc.event.GuestPath())
+			require.Equal(t, tc.expectedNewPath, tc.event.NewGuestPath())
+		})
+	}
+}
+
+func TestAuditOp_String(t *testing.T) {
+	require.Equal(t, "OpenFile", AuditOpenFile.String())
+	require.Equal(t, "Utimens", AuditUtimens.String())
+	require.Equal(t, "unknown", AuditOp(0).String())
+}
@@ -401,8 +401,13 @@ func TestFilePoll_POLLOUT(t *testing.T) {
 	require.NoError(t, err)
 	timeout := int32(0) // return immediately
 
-	// We don't yet implement write blocking.
+	// An empty pipe has capacity, so it is ready for writing.
 	ready, errno := wF.(experimentalsys.Pollable).Poll(pflag, timeout)
+	require.EqualErrno(t, 0, errno)
+	require.True(t, ready)
+
+	// Combining flags isn't supported.
+	ready, errno = wF.(experimentalsys.Pollable).Poll(experimentalsys.POLLIN|pflag, timeout)
 	require.EqualErrno(t, experimentalsys.ENOTSUP, errno)
 	require.False(t, ready)
 }
@@ -0,0 +1,823 @@
+package sysfs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// maxSymlinks is the count of symbolic links followed when resolving a path
+// before failing with ELOOP. This is the same as MAXSYMLINKS on Linux.
+const maxSymlinks = 40
+
+// memFSDev is incremented for each memFS, so that the device ID and inode of
+// a file are unique across all of them.
+var memFSDev atomic.Uint64
+
+// MemFS returns a new sys.FS which only exists in memory, containing an empty
+// root directory.
+func MemFS() experimentalsys.FS {
+	m := &memFS{dev: memFSDev.Add(1)}
+	m.root = m.newNode(fs.ModeDir|0o755, memNow())
+	m.root.parent = m.root
+	m.root.nlink = 2
+	return m
+}
+
+// memNow returns the current time in epoch nanoseconds.
+func memNow() int64 {
+	return time.Now().UnixNano()
+}
+
+// memFS is not exported because its fields must be initialized together.
+//
+// Note: Permissions are recorded, but not enforced, as if the guest was the
+// owner of all files with elevated privileges.
+type memFS struct {
+	experimentalsys.UnimplementedFS
+
+	dev     uint64
+	nextIno sys.Inode
+
+	// mux guards all nodes, including those of open files.
+	mux  sync.Mutex
+	root *memNode
+}
+
+// memNode is a file, directory or symbolic link of a memFS.
+type memNode struct {
+	ino              sys.Inode
+	mode             fs.FileMode
+	nlink            uint64
+	atim, mtim, ctim int64
+
+	// data is the contents of a regular file.
+	data []byte
+
+	// target is the path a symbolic link refers to.
+	target string
+
+	// entries are the children of a directory, which is the only type of node
+	// with a parent. The root directory is its own parent.
+	entries map[string]*memNode
+	parent  *memNode
+}
+
+// newNode returns a node with a new inode and all timestamps set to now.
+func (m *memFS) newNode(mode fs.FileMode, now int64) *memNode {
+	m.nextIno++
+	n := &memNode{ino: m.nextIno, mode: mode, nlink: 1, atim: now, mtim: now, ctim: now}
+	if mode.IsDir() {
+		n.entries = map[string]*memNode{}
+	}
+	return n
+}
+
+// stat returns the sys.Stat_t of the node.
+func (m *memFS) stat(n *memNode) sys.Stat_t {
+	st := sys.Stat_t{
+		Dev:   m.dev,
+		Ino:   n.ino,
+		Mode:  n.mode,
+		Nlink: n.nlink,
+		Atim:  n.atim,
+		Mtim:  n.mtim,
+		Ctim:  n.ctim,
+	}
+	switch n.mode.Type() {
+	case 0:
+		st.Size = int64(len(n.data))
+	case fs.ModeSymlink:
+		st.Size = int64(len(n.target))
+	}
+	return st
+}
+
+// String implements fmt.Stringer
+func (m *memFS) String() string {
+	return "memfs"
+}
+
+// resolve returns the node at the path. When followLast is true, a symbolic
+// link at the last path component is followed, like Stat. Symbolic links are
+// always followed otherwise.
+func (m *memFS) resolve(p string, followLast bool) (*memNode, experimentalsys.Errno) {
+	n := m.root
+	names := splitPath(p)
+	for links := 0; len(names) > 0; {
+		name := names[0]
+		names = names[1:]
+		if !n.mode.IsDir() {
+			return nil, experimentalsys.ENOTDIR
+		}
+		switch name {
+		case ".":
+			continue
+		case "..":
+			n = n.parent
+			continue
+		}
+		child, ok := n.entries[name]
+		if !ok {
+			return nil, experimentalsys.ENOENT
+		}
+		if child.mode.Type() == fs.ModeSymlink && (len(names) > 0 || followLast) {
+			if links++; links > maxSymlinks {
+				return nil, experimentalsys.ELOOP
+			}
+			// The target is relative to the directory containing the link.
+			names = append(splitPath(child.target), names...)
+			continue
+		}
+		n = child
+	}
+	return n, 0
+}
+
+// resolveParent returns the directory containing the last component of the
+// path, and the name of that component. This returns EEXIST when the path
+// has no last component, such as ".", as it must be an existing directory.
+func (m *memFS) resolveParent(p string) (dir *memNode, name string, errno experimentalsys.Errno) {
+	dirName, name := path.Split(cleanPath(p))
+	switch name {
+	case "", ".", "..":
+		return nil, "", experimentalsys.EEXIST
+	}
+	if dir, errno = m.resolve(dirName, true); errno != 0 {
+		return
+	} else if !dir.mode.IsDir() {
+		return nil, "", experimentalsys.ENOTDIR
+	}
+	return
+}
+
+// splitPath returns the non-empty components of the path.
+func splitPath(p string) []string {
+	names := strings.Split(p, "/")
+	for i := 0; i < len(names); {
+		if names[i] == "" {
+			names = append(names[:i], names[i+1:]...)
+		} else {
+			i++
+		}
+	}
+	return names
+}
+
+// link adds the node to the directory with the given name, which must not
+// exist yet.
+func (m *memFS) link(dir *memNode, name string, n *memNode, now int64) {
+	dir.entries[name] = n
+	dir.mtim, dir.ctim = now, now
+	if n.mode.IsDir() {
+		n.parent = dir
+		dir.nlink++ // for ".." in the new directory
+	}
+}
+
+// unlink removes the node with the given name from the directory.
+func (m *memFS) unlink(dir *memNode, name string, now int64) {
+	n := dir.entries[name]
+	delete(dir.entries, name)
+	dir.mtim, dir.ctim = now, now
+	n.ctim = now
+	if n.mode.IsDir() {
+		dir.nlink--
+		n.nlink = 0 // "." and the entry in dir
+	} else {
+		n.nlink--
+	}
+}
+
+// OpenFile implements the same method as documented on sys.FS
+func (m *memFS) OpenFile(path string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	now := memNow()
+	n, errno := m.resolve(path, flag&experimentalsys.O_NOFOLLOW == 0)
+	switch {
+	case errno == experimentalsys.ENOENT && flag&experimentalsys.O_CREAT != 0:
+		if flag&experimentalsys.O_DIRECTORY != 0 {
+			return nil, experimentalsys.ENOENT // only files are created.
+		}
+		dir, name, errno := m.resolveParent(path)
+		if errno != 0 {
+			return nil, errno
+		} else if _, ok := dir.entries[name]; ok {
+			return nil, experimentalsys.ENOENT // dangling symbolic link
+		}
+		n = m.newNode(perm.Perm(), now)
+		m.link(dir, name, n, now)
+	case errno != 0:
+		return nil, errno
+	case flag&(experimentalsys.O_CREAT|experimentalsys.O_EXCL) == experimentalsys.O_CREAT|experimentalsys.O_EXCL:
+		return nil, experimentalsys.EEXIST
+	}
+
+	isDir := n.mode.IsDir()
+	writable := flag&(experimentalsys.O_RDWR|experimentalsys.O_WRONLY) != 0
+	switch {
+	case n.mode.Type() == fs.ModeSymlink: // only when O_NOFOLLOW
+		return nil, experimentalsys.ELOOP
+	case flag&experimentalsys.O_DIRECTORY != 0 && !isDir:
+		return nil, experimentalsys.ENOTDIR
+	case isDir && writable:
+		return nil, experimentalsys.EISDIR
+	}
+
+	if flag&experimentalsys.O_TRUNC != 0 && writable && len(n.data) > 0 {
+		n.data = nil
+		n.mtim, n.ctim = now, now
+	}
+	return &memFile{fs: m, node: n, flag: flag}, 0
+}
+
+// Lstat implements the same method as documented on sys.FS
+func (m *memFS) Lstat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	n, errno := m.resolve(path, false)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return m.stat(n), 0
+}
+
+// Stat implements the same method as documented on sys.FS
+func (m *memFS) Stat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	n, errno := m.resolve(path, true)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	return m.stat(n), 0
+}
+
+// Mkdir implements the same method as documented on sys.FS
+func (m *memFS) Mkdir(path string, perm fs.FileMode) experimentalsys.Errno {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	dir, name, errno := m.resolveParent(path)
+	if errno == experimentalsys.ENOTDIR {
+		return experimentalsys.ENOENT // consistent with DirFS
+	} else if errno != 0 {
+		return errno
+	} else if _, ok := dir.entries[name]; ok {
+		return experimentalsys.EEXIST
+	}
+
+	now := memNow()
+	n := m.newNode(fs.ModeDir|perm.Perm(), now)
+	n.nlink = 2 // the entry in dir and "."
+	m.link(dir, name, n, now)
+	return 0
+}
+
+// Chmod implements the same method as documented on sys.FS
+func (m *memFS) Chmod(path string, perm fs.FileMode) experimentalsys.Errno {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	n, errno := m.resolve(path, true)
+	if errno != 0 {
+		return errno
+	}
+	n.mode = n.mode.Type() | perm.Perm()
+	n.ctim = memNow()
+	return 0
+}
+
+// Rename implements the same method as documented on sys.FS
+func (m *memFS) Rename(from, to string) experimentalsys.Errno {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	fromDir, fromName, errno := m.resolveParent(from)
+	if errno == experimentalsys.EEXIST {
+		return experimentalsys.EINVAL // e.g. "."
+	} else if errno != 0 {
+		return errno
+	}
+	n, ok := fromDir.entries[fromName]
+	if !ok {
+		return experimentalsys.ENOENT
+	}
+
+	toDir, toName, errno := m.resolveParent(to)
+	if errno == experimentalsys.EEXIST {
+		return experimentalsys.EINVAL
+	} else if errno != 0 {
+		return errno
+	}
+
+	// A directory can't be moved into itself.
+	if n.mode.IsDir() {
+		for d := toDir; ; d = d.parent {
+			if d == n {
+				return experimentalsys.EINVAL
+			} else if d == m.root {
+				break
+			}
+		}
+	}
+
+	now := memNow()
+	if existing, ok := toDir.entries[toName]; ok {
+		switch {
+		case existing == n:
+			return 0 // same node, so nothing to do.
+		case n.mode.IsDir() && !existing.mode.IsDir():
+			return experimentalsys.ENOTDIR
+		case !n.mode.IsDir() && existing.mode.IsDir():
+			return experimentalsys.EISDIR
+		case existing.mode.IsDir() && len(existing.entries) > 0:
+			return experimentalsys.ENOTEMPTY
+		}
+		m.unlink(toDir, toName, now)
+	}
+
+	delete(fromDir.entries, fromName)
+	fromDir.mtim, fromDir.ctim = now, now
+	if n.mode.IsDir() {
+		fromDir.nlink--
+	}
+	m.link(toDir, toName, n, now)
+	n.ctim = now
+	return 0
+}
+
+// Rmdir implements the same method as documented on sys.FS
+func (m *memFS) Rmdir(path string) experimentalsys.Errno {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	dir, name, errno := m.resolveParent(path)
+	if errno == experimentalsys.EEXIST {
+		return experimentalsys.EINVAL // e.g. "."
+	} else if errno != 0 {
+		return errno
+	}
+
+	n, ok := dir.entries[name]
+	switch {
+	case !ok:
+		return experimentalsys.ENOENT
+	case !n.mode.IsDir():
+		return experimentalsys.ENOTDIR
+	case len(n.entries) > 0:
+		return experimentalsys.ENOTEMPTY
+	}
+	m.unlink(dir, name, memNow())
+	return 0
+}
+
+// Unlink implements the same method as documented on sys.FS
+func (m *memFS) Unlink(path string) experimentalsys.Errno {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	dir, name, errno := m.resolveParent(path)
+	if errno == experimentalsys.EEXIST {
+		return experimentalsys.EISDIR // e.g. "."
+	} else if errno != 0 {
+		return errno
+	}
+
+	n, ok := dir.entries[name]
+	if !ok {
+		return experimentalsys.ENOENT
+	} else if n.mode.IsDir() {
+		return experimentalsys.EISDIR
+	}
+	m.unlink(dir, name, memNow())
+	return 0
+}
+
+// Link implements the same method as documented on sys.FS
+func (m *memFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	// Like Linux, a symbolic link at oldPath is not followed.
+	n, errno := m.resolve(oldPath, false)
+	if errno != 0 {
+		return errno
+	} else if n.mode.IsDir() {
+		return experimentalsys.EPERM
+	}
+
+	dir, name, errno := m.resolveParent(newPath)
+	if errno != 0 {
+		return errno
+	} else if _, ok := dir.entries[name]; ok {
+		return experimentalsys.EEXIST
+	}
+
+	now := memNow()
+	m.link(dir, name, n, now)
+	n.nlink++
+	n.ctim = now
+	return 0
+}
+
+// Symlink implements the same method as documented on sys.FS
+func (m *memFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	// Like DirFS, a symbolic link must be relative to stay in this file system.
+	if path.IsAbs(oldPath) {
+		return experimentalsys.EPERM
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	dir, name, errno := m.resolveParent(linkName)
+	if errno != 0 {
+		return errno
+	} else if _, ok := dir.entries[name]; ok {
+		return experimentalsys.EEXIST
+	}
+
+	now := memNow()
+	n := m.newNode(fs.ModeSymlink|0o777, now)
+	n.target = oldPath
+	m.link(dir, name, n, now)
+	return 0
+}
+
+// Readlink implements the same method as documented on sys.FS
+func (m *memFS) Readlink(path string) (string, experimentalsys.Errno) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	n, errno := m.resolve(path, false)
+	if errno != 0 {
+		return "", errno
+	} else if n.mode.Type() != fs.ModeSymlink {
+		return "", experimentalsys.EINVAL
+	}
+	return n.target, 0
+}
+
+// Utimens implements the same method as documented on sys.FS
+func (m *memFS) Utimens(path string, atim, mtim int64) experimentalsys.Errno {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	n, errno := m.resolve(path, true)
+	if errno != 0 {
+		return errno
+	}
+	n.utimens(atim, mtim)
+	return 0
+}
+
+// utimens sets the timestamps of the node, except those set to UTIME_OMIT.
+func (n *memNode) utimens(atim, mtim int64) {
+	if atim == experimentalsys.UTIME_OMIT && mtim == experimentalsys.UTIME_OMIT {
+		return // there is nothing to change.
+	}
+	if atim != experimentalsys.UTIME_OMIT {
+		n.atim = atim
+	}
+	if mtim != experimentalsys.UTIME_OMIT {
+		n.mtim = mtim
+	}
+	n.ctim = memNow()
+}
+
+var _ experimentalsys.PollableFile = (*memFile)(nil)
+
+// memFile is a file opened by a memFS. This remains usable after its node is
+// unlinked, like any open file in POSIX.
+type memFile struct {
+	fs     *memFS
+	node   *memNode
+	flag   experimentalsys.Oflag
+	offset int64
+	closed bool
+
+	// dirCursor is the name of the last directory entry read by Readdir.
+	// Entries are read in name order, so the next are after this one.
+	dirCursor string
+}
+
+// Dev implements the same method as documented on sys.File
+func (f *memFile) Dev() (uint64, experimentalsys.Errno) {
+	return f.fs.dev, 0
+}
+
+// Ino implements the same method as documented on sys.File
+func (f *memFile) Ino() (sys.Inode, experimentalsys.Errno) {
+	return f.node.ino, 0
+}
+
+// IsDir implements the same method as documented on sys.File
+func (f *memFile) IsDir() (bool, experimentalsys.Errno) {
+	return f.node.mode.IsDir(), 0
+}
+
+// IsAppend implements the same method as documented on sys.File
+func (f *memFile) IsAppend() bool {
+	return f.flag&experimentalsys.O_APPEND != 0
+}
+
+// SetAppend implements the same method as documented on sys.File
+func (f *memFile) SetAppend(enable bool) experimentalsys.Errno {
+	if f.node.mode.IsDir() {
+		return experimentalsys.EISDIR
+	}
+	if enable {
+		f.flag |= experimentalsys.O_APPEND
+	} else {
+		f.flag &= ^experimentalsys.O_APPEND
+	}
+	return 0
+}
+
+// IsNonblock implements the same method as documented on
+// sys.PollableFile
+func (f *memFile) IsNonblock() bool {
+	return f.flag&experimentalsys.O_NONBLOCK != 0
+}
+
+// SetNonblock implements the same method as documented on
+// sys.PollableFile
+func (f *memFile) SetNonblock(enable bool) experimentalsys.Errno {
+	if enable {
+		f.flag |= experimentalsys.O_NONBLOCK
+	} else {
+		f.flag &= ^experimentalsys.O_NONBLOCK
+	}
+	return 0
+}
+
+// Poll implements the same method as documented on sys.Pollable
+//
+// Note: This is always ready, as reads and writes never block.
+func (f *memFile) Poll(experimentalsys.Pflag, int32) (ready bool, errno experimentalsys.Errno) {
+	if f.closed {
+		return false, experimentalsys.EBADF
+	}
+	return true, 0
+}
+
+// Stat implements the same method as documented on sys.File
+func (f *memFile) Stat() (sys.Stat_t, experimentalsys.Errno) {
+	if f.closed {
+		return sys.Stat_t{}, experimentalsys.EBADF
+	}
+
+	f.fs.mux.Lock()
+	defer f.fs.mux.Unlock()
+	return f.fs.stat(f.node), 0
+}
+
+// checkRead returns the errno reading the file, or zero if it can be read.
+func (f *memFile) checkRead() experimentalsys.Errno {
+	switch {
+	case f.closed:
+		return experimentalsys.EBADF
+	case f.node.mode.IsDir():
+		return experimentalsys.EISDIR
+	case f.flag&experimentalsys.O_WRONLY != 0:
+		return experimentalsys.EBADF
+	}
+	return 0
+}
+
+// Read implements the same method as documented on sys.File
+func (f *memFile) Read(buf []byte) (n int, errno experimentalsys.Errno) {
+	if errno = f.checkRead(); errno != 0 || len(buf) == 0 {
+		return
+	}
+
+	f.fs.mux.Lock()
+	defer f.fs.mux.Unlock()
+	n = f.pread(buf, f.offset)
+	f.offset += int64(n)
+	return
+}
+
+// Pread implements the same method as documented on sys.File
+func (f *memFile) Pread(buf []byte, off int64) (n int, errno experimentalsys.Errno) {
+	if errno = f.checkRead(); errno != 0 || len(buf) == 0 {
+		return
+	} else if off < 0 {
+		return 0, experimentalsys.EINVAL
+	}
+
+	f.fs.mux.Lock()
+	defer f.fs.mux.Unlock()
+	return f.pread(buf, off), 0
+}
+
+// pread reads the data at the offset, updating the access time.
+func (f *memFile) pread(buf []byte, off int64) (n int) {
+	if data := f.node.data; off < int64(len(data)) {
+		n = copy(buf, data[off:])
+	}
+	f.node.atim = memNow()
+	return
+}
+
+// Seek implements the same method as documented on sys.File
+func (f *memFile) Seek(offset int64, whence int) (newOffset int64, errno experimentalsys.Errno) {
+	if f.closed {
+		return 0, experimentalsys.EBADF
+	}
+
+	// A directory can only be rewound, which resets Readdir.
+	if f.node.mode.IsDir() {
+		if offset != 0 || whence != io.SeekStart {
+			return 0, experimentalsys.EISDIR
+		}
+		f.dirCursor = ""
+		return 0, 0
+	}
+
+	f.fs.mux.Lock()
+	defer f.fs.mux.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+		newOffset = offset
+	case io.SeekCurrent:
+		newOffset = f.offset + offset
+	case io.SeekEnd:
+		newOffset = int64(len(f.node.data)) + offset
+	default:
+		return 0, experimentalsys.EINVAL
+	}
+	if newOffset < 0 {
+		return 0, experimentalsys.EINVAL
+	}
+	f.offset = newOffset
+	return
+}
+
+// Readdir implements the same method as documented on sys.File
+//
+// Note: Entries are returned in name order. Entries added or removed after
+// the last call are visible, unless ordered before the last entry returned.
+func (f *memFile) Readdir(n int) (dirents []experimentalsys.Dirent, errno experimentalsys.Errno) {
+	if f.closed || !f.node.mode.IsDir() {
+		return nil, experimentalsys.EBADF
+	}
+
+	f.fs.mux.Lock()
+	defer f.fs.mux.Unlock()
+
+	entries := f.node.entries
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		if name > f.dirCursor {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if n > 0 && n < len(names) {
+		names = names[:n]
+	}
+
+	dirents = make([]experimentalsys.Dirent, 0, len(names))
+	for _, name := range names {
+		e := entries[name]
+		dirents = append(dirents, experimentalsys.Dirent{Name: name, Ino: e.ino, Type: e.mode.Type()})
+	}
+	if len(names) > 0 {
+		f.dirCursor = names[len(names)-1]
+	}
+	f.node.atim = memNow()
+	return
+}
+
+// checkWrite returns the errno writing the file, or zero if it can be
+// written.
+func (f *memFile) checkWrite() experimentalsys.Errno {
+	switch {
+	case f.closed:
+		return experimentalsys.EBADF
+	case f.node.mode.IsDir():
+		return experimentalsys.EISDIR
+	case f.flag&(experimentalsys.O_RDWR|experimentalsys.O_WRONLY) == 0:
+		return experimentalsys.EBADF
+	}
+	return 0
+}
+
+// Write implements the same method as documented on sys.File
+func (f *memFile) Write(buf []byte) (n int, errno experimentalsys.Errno) {
+	if errno = f.checkWrite(); errno != 0 || len(buf) == 0 {
+		return
+	}
+
+	f.fs.mux.Lock()
+	defer f.fs.mux.Unlock()
+	if f.IsAppend() {
+		f.offset = int64(len(f.node.data))
+	}
+	n = f.pwrite(buf, f.offset)
+	f.offset += int64(n)
+	return
+}
+
+// Pwrite implements the same method as documented on sys.File
+func (f *memFile) Pwrite(buf []byte, off int64) (n int, errno experimentalsys.Errno) {
+	if errno = f.checkWrite(); errno != 0 || len(buf) == 0 {
+		return
+	} else if off < 0 {
+		return 0, experimentalsys.EINVAL
+	}
+
+	f.fs.mux.Lock()
+	defer f.fs.mux.Unlock()
+	return f.pwrite(buf, off), 0
+}
+
+// pwrite writes the data at the offset, growing the file as needed.
+func (f *memFile) pwrite(buf []byte, off int64) int {
+	node := f.node
+	if end := off + int64(len(buf)); end > int64(len(node.data)) {
+		node.data = resize(node.data, end)
+	}
+	node.mtim = memNow()
+	node.ctim = node.mtim
+	return copy(node.data[off:], buf)
+}
+
+// resize returns the data with the given size, zero-filling any new bytes.
+func resize(data []byte, size int64) []byte {
+	if size <= int64(len(data)) {
+		return data[:size]
+	}
+	if size <= int64(cap(data)) {
+		oldLen := len(data)
+		data = data[:size]
+		clear(data[oldLen:])
+		return data
+	}
+	grown := make([]byte, size, max(size, 2*int64(cap(data))))
+	copy(grown, data)
+	return grown
+}
+
+// Truncate implements the same method as documented on sys.File
+func (f *memFile) Truncate(size int64) experimentalsys.Errno {
+	if errno := f.checkWrite(); errno != 0 {
+		return errno
+	} else if size < 0 {
+		return experimentalsys.EINVAL
+	}
+
+	f.fs.mux.Lock()
+	defer f.fs.mux.Unlock()
+	node := f.node
+	node.data = resize(node.data, size)
+	node.mtim = memNow()
+	node.ctim = node.mtim
+	return 0
+}
+
+// Sync implements the same method as documented on sys.File
+func (f *memFile) Sync() experimentalsys.Errno {
+	if f.closed {
+		return experimentalsys.EBADF
+	}
+	return 0 // nothing to persist.
+}
+
+// Datasync implements the same method as documented on sys.File
+func (f *memFile) Datasync() experimentalsys.Errno {
+	return f.Sync()
+}
+
+// Utimens implements the same method as documented on sys.File
+func (f *memFile) Utimens(atim, mtim int64) experimentalsys.Errno {
+	if f.closed {
+		return experimentalsys.EBADF
+	}
+
+	f.fs.mux.Lock()
+	defer f.fs.mux.Unlock()
+	f.node.utimens(atim, mtim)
+	return 0
+}
+
+// Close implements the same method as documented on sys.File
+func (f *memFile) Close() experimentalsys.Errno {
+	f.closed = true
+	return 0
+}
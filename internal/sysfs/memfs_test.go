@@ -0,0 +1,637 @@
+package sysfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"testing"
+	"time"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/fstest"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// newTestMemFS returns a MemFS including the files in fstest.FS.
+func newTestMemFS(t *testing.T) experimentalsys.FS {
+	testFS := MemFS()
+
+	// Sort names so that directories are made before their files.
+	names := make([]string, 0, len(fstest.FS))
+	for name := range fstest.FS {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		file := fstest.FS[name]
+		if file.Mode.IsDir() {
+			if name != "." {
+				require.EqualErrno(t, 0, testFS.Mkdir(name, file.Mode))
+			}
+		} else {
+			f, errno := testFS.OpenFile(name, experimentalsys.O_WRONLY|experimentalsys.O_CREAT, file.Mode)
+			require.EqualErrno(t, 0, errno)
+			_, errno = f.Write(file.Data)
+			require.EqualErrno(t, 0, errno)
+			require.EqualErrno(t, 0, f.Close())
+		}
+		if !file.ModTime.IsZero() {
+			mtim := file.ModTime.UnixNano()
+			require.EqualErrno(t, 0, testFS.Utimens(name, mtim, mtim))
+		}
+	}
+	return testFS
+}
+
+func TestMemFS_String(t *testing.T) {
+	require.Equal(t, "memfs", MemFS().(fmt.Stringer).String())
+}
+
+func TestMemFS_OpenFile(t *testing.T) {
+	testFS := newTestMemFS(t)
+
+	testOpen_Read(t, testFS, true, true)
+
+	t.Run("O_RDWR", func(t *testing.T) {
+		f, errno := testFS.OpenFile("file", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o600)
+		require.EqualErrno(t, 0, errno)
+		defer f.Close()
+
+		requireWrite(t, f, []byte("wazero"))
+		require.Equal(t, int64(0), requireSeek(t, f, 0, io.SeekStart))
+		requireRead(t, f, make([]byte, 6))
+
+		st, errno := testFS.Stat("file")
+		require.EqualErrno(t, 0, errno)
+		require.Equal(t, fs.FileMode(0o600), st.Mode)
+		require.Equal(t, int64(6), st.Size)
+	})
+
+	t.Run("O_WRONLY can't read", func(t *testing.T) {
+		f, errno := testFS.OpenFile("animals.txt", experimentalsys.O_WRONLY, 0)
+		require.EqualErrno(t, 0, errno)
+		defer f.Close()
+
+		_, errno = f.Read(make([]byte, 1))
+		require.EqualErrno(t, experimentalsys.EBADF, errno)
+	})
+
+	t.Run("O_EXCL", func(t *testing.T) {
+		_, errno := testFS.OpenFile("animals.txt", experimentalsys.O_RDWR|experimentalsys.O_CREAT|experimentalsys.O_EXCL, 0o600)
+		require.EqualErrno(t, experimentalsys.EEXIST, errno)
+	})
+
+	t.Run("O_TRUNC", func(t *testing.T) {
+		f, errno := testFS.OpenFile("truncate", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o600)
+		require.EqualErrno(t, 0, errno)
+		requireWrite(t, f, []byte("123456"))
+		require.EqualErrno(t, 0, f.Close())
+
+		f, errno = testFS.OpenFile("truncate", experimentalsys.O_RDWR|experimentalsys.O_TRUNC, 0)
+		require.EqualErrno(t, 0, errno)
+		require.EqualErrno(t, 0, f.Close())
+
+		st, errno := testFS.Stat("truncate")
+		require.EqualErrno(t, 0, errno)
+		require.Zero(t, st.Size)
+	})
+
+	t.Run("O_APPEND", func(t *testing.T) {
+		f, errno := testFS.OpenFile("append", experimentalsys.O_WRONLY|experimentalsys.O_CREAT|experimentalsys.O_APPEND, 0o600)
+		require.EqualErrno(t, 0, errno)
+		defer f.Close()
+		require.True(t, f.IsAppend())
+
+		requireWrite(t, f, []byte("wa"))
+		requirePwrite(t, f, []byte("?"), 0) // doesn't change the offset
+		requireSeek(t, f, 0, io.SeekStart)
+		requireWrite(t, f, []byte("zero")) // appends regardless of the offset
+
+		require.EqualErrno(t, 0, f.SetAppend(false))
+		requireSeek(t, f, 0, io.SeekStart)
+		requireWrite(t, f, []byte("W"))
+
+		requireContents(t, testFS, "append", "Wazero")
+	})
+
+	t.Run("O_NONBLOCK", func(t *testing.T) {
+		f, errno := testFS.OpenFile("animals.txt", experimentalsys.O_RDONLY|experimentalsys.O_NONBLOCK, 0)
+		require.EqualErrno(t, 0, errno)
+		defer f.Close()
+
+		pf := f.(experimentalsys.PollableFile)
+		require.True(t, pf.IsNonblock())
+		require.EqualErrno(t, 0, pf.SetNonblock(false))
+		require.False(t, pf.IsNonblock())
+
+		ready, errno := pf.Poll(experimentalsys.POLLIN, 0)
+		require.EqualErrno(t, 0, errno)
+		require.True(t, ready)
+	})
+
+	t.Run("O_NOFOLLOW", func(t *testing.T) {
+		require.EqualErrno(t, 0, testFS.Symlink("animals.txt", "nofollow"))
+
+		_, errno := testFS.OpenFile("nofollow", experimentalsys.O_RDONLY|experimentalsys.O_NOFOLLOW, 0)
+		require.EqualErrno(t, experimentalsys.ELOOP, errno)
+	})
+
+	t.Run("O_DIRECTORY on file", func(t *testing.T) {
+		_, errno := testFS.OpenFile("animals.txt", experimentalsys.O_DIRECTORY, 0)
+		require.EqualErrno(t, experimentalsys.ENOTDIR, errno)
+	})
+
+	t.Run("O_CREAT in missing dir", func(t *testing.T) {
+		_, errno := testFS.OpenFile("nope/file", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o600)
+		require.EqualErrno(t, experimentalsys.ENOENT, errno)
+		_, errno = testFS.OpenFile("animals.txt/file", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o600)
+		require.EqualErrno(t, experimentalsys.ENOTDIR, errno)
+	})
+
+	t.Run("path outside root is the root", func(t *testing.T) {
+		f, errno := testFS.OpenFile("../animals.txt", experimentalsys.O_RDONLY, 0)
+		require.EqualErrno(t, 0, errno)
+		require.EqualErrno(t, 0, f.Close())
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		f, errno := testFS.OpenFile("animals.txt", experimentalsys.O_RDWR, 0)
+		require.EqualErrno(t, 0, errno)
+		require.EqualErrno(t, 0, f.Close())
+		require.EqualErrno(t, 0, f.Close()) // idempotent
+
+		_, errno = f.Read(make([]byte, 1))
+		require.EqualErrno(t, experimentalsys.EBADF, errno)
+		_, errno = f.Write([]byte{1})
+		require.EqualErrno(t, experimentalsys.EBADF, errno)
+		_, errno = f.Stat()
+		require.EqualErrno(t, experimentalsys.EBADF, errno)
+	})
+}
+
+func TestMemFS_Lstat(t *testing.T) {
+	testFS := newTestMemFS(t)
+	for _, path := range []string{"animals.txt", "sub", "sub-link"} {
+		require.EqualErrno(t, 0, testFS.Symlink(path, path+"-link"))
+	}
+
+	testLstat(t, testFS)
+}
+
+func TestMemFS_Stat(t *testing.T) {
+	testFS := newTestMemFS(t)
+	testStat(t, testFS)
+
+	t.Run("follows symbolic links", func(t *testing.T) {
+		require.EqualErrno(t, 0, testFS.Symlink("../animals.txt", "sub/animals-link"))
+		require.EqualErrno(t, 0, testFS.Symlink("sub", "sub-link"))
+
+		st, errno := testFS.Stat("animals.txt")
+		require.EqualErrno(t, 0, errno)
+		linkSt, errno := testFS.Stat("sub-link/animals-link")
+		require.EqualErrno(t, 0, errno)
+		require.Equal(t, st, linkSt)
+	})
+
+	t.Run("symbolic link loop", func(t *testing.T) {
+		require.EqualErrno(t, 0, testFS.Symlink("loop2", "loop1"))
+		require.EqualErrno(t, 0, testFS.Symlink("loop1", "loop2"))
+
+		_, errno := testFS.Stat("loop1")
+		require.EqualErrno(t, experimentalsys.ELOOP, errno)
+	})
+
+	t.Run("device differs per file system", func(t *testing.T) {
+		st1, errno := MemFS().Stat(".")
+		require.EqualErrno(t, 0, errno)
+		st2, errno := MemFS().Stat(".")
+		require.EqualErrno(t, 0, errno)
+		require.NotEqual(t, st1.Dev, st2.Dev)
+	})
+}
+
+func TestMemFS_Mkdir(t *testing.T) {
+	testFS := MemFS()
+
+	require.EqualErrno(t, 0, testFS.Mkdir("dir", 0o700))
+	st, errno := testFS.Stat("dir")
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, fs.ModeDir|0o700, st.Mode)
+	require.Equal(t, uint64(2), st.Nlink)
+
+	// The parent links to the new directory with "..".
+	st, errno = testFS.Stat(".")
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, uint64(3), st.Nlink)
+
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Mkdir("dir", 0o700))
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Mkdir(".", 0o700))
+	require.EqualErrno(t, experimentalsys.ENOENT, testFS.Mkdir("nope/dir", 0o700))
+
+	require.EqualErrno(t, 0, testFS.Mkdir("dir2", 0o444))
+	testChmod(t, testFS, "dir2")
+}
+
+func TestMemFS_Chmod(t *testing.T) {
+	testFS := newTestMemFS(t)
+
+	testChmod(t, testFS, "sub/test.txt")
+	require.EqualErrno(t, experimentalsys.ENOENT, testFS.Chmod("nope", 0o600))
+}
+
+func TestMemFS_Rename(t *testing.T) {
+	tests := []struct {
+		name          string
+		from, to      string
+		expectedErrno experimentalsys.Errno
+	}{
+		{name: "from doesn't exist", from: "nope", to: "file1", expectedErrno: experimentalsys.ENOENT},
+		{name: "to dir doesn't exist", from: "file1", to: "nope/file1", expectedErrno: experimentalsys.ENOENT},
+		{name: "file to non-exist", from: "file1", to: "file3"},
+		{name: "file to file", from: "file1", to: "file2"},
+		{name: "file to itself", from: "file1", to: "file1"},
+		{name: "file to hard link", from: "file1", to: "file1-link"},
+		{name: "file to dir", from: "file1", to: "dir2", expectedErrno: experimentalsys.EISDIR},
+		{name: "dir to non-exist", from: "dir1", to: "dir3"},
+		{name: "dir to file", from: "dir1", to: "file1", expectedErrno: experimentalsys.ENOTDIR},
+		{name: "dir to empty dir", from: "dir1", to: "dir2"},
+		{name: "dir to non-empty dir", from: "dir2", to: "dir1", expectedErrno: experimentalsys.ENOTEMPTY},
+		{name: "dir to itself", from: "dir1", to: "dir1"},
+		{name: "dir into itself", from: "dir1", to: "dir1/dir", expectedErrno: experimentalsys.EINVAL},
+		{name: "root", from: ".", to: "dir3", expectedErrno: experimentalsys.EINVAL},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			testFS := MemFS()
+			requireWriteFile(t, testFS, "file1", "1")
+			requireWriteFile(t, testFS, "file2", "2")
+			require.EqualErrno(t, 0, testFS.Link("file1", "file1-link"))
+			require.EqualErrno(t, 0, testFS.Mkdir("dir1", 0o700))
+			requireWriteFile(t, testFS, "dir1/file", "dir1")
+			require.EqualErrno(t, 0, testFS.Mkdir("dir2", 0o700))
+
+			fromSt, _ := testFS.Lstat(tc.from)
+
+			errno := testFS.Rename(tc.from, tc.to)
+			require.EqualErrno(t, tc.expectedErrno, errno)
+			if errno != 0 {
+				return
+			}
+
+			toSt, errno := testFS.Lstat(tc.to)
+			require.EqualErrno(t, 0, errno)
+			require.Equal(t, fromSt.Ino, toSt.Ino)
+			if tc.from != tc.to && fromSt.Nlink == 1 {
+				_, errno = testFS.Lstat(tc.from)
+				require.EqualErrno(t, experimentalsys.ENOENT, errno)
+			}
+		})
+	}
+
+	t.Run("dir moves its files", func(t *testing.T) {
+		testFS := MemFS()
+		require.EqualErrno(t, 0, testFS.Mkdir("dir1", 0o700))
+		require.EqualErrno(t, 0, testFS.Mkdir("dir2", 0o700))
+		requireWriteFile(t, testFS, "dir1/file", "wazero")
+
+		require.EqualErrno(t, 0, testFS.Rename("dir1", "dir2/dir"))
+		requireContents(t, testFS, "dir2/dir/file", "wazero")
+		requireContents(t, testFS, "dir2/dir/../dir/file", "wazero")
+
+		st, errno := testFS.Stat(".")
+		require.EqualErrno(t, 0, errno)
+		require.Equal(t, uint64(3), st.Nlink) // dir2 only
+		st, errno = testFS.Stat("dir2")
+		require.EqualErrno(t, 0, errno)
+		require.Equal(t, uint64(3), st.Nlink)
+	})
+}
+
+func TestMemFS_Rmdir(t *testing.T) {
+	testFS := MemFS()
+	require.EqualErrno(t, 0, testFS.Mkdir("dir", 0o700))
+	requireWriteFile(t, testFS, "dir/file", "wazero")
+
+	require.EqualErrno(t, experimentalsys.ENOENT, testFS.Rmdir("nope"))
+	require.EqualErrno(t, experimentalsys.ENOTEMPTY, testFS.Rmdir("dir"))
+	require.EqualErrno(t, experimentalsys.ENOTDIR, testFS.Rmdir("dir/file"))
+	require.EqualErrno(t, experimentalsys.EINVAL, testFS.Rmdir("."))
+
+	// Remove the directory while it is open.
+	d, errno := testFS.OpenFile("dir", experimentalsys.O_DIRECTORY, 0)
+	require.EqualErrno(t, 0, errno)
+	defer d.Close()
+
+	require.EqualErrno(t, 0, testFS.Unlink("dir/file"))
+	require.EqualErrno(t, 0, testFS.Rmdir("dir"))
+	_, errno = testFS.Stat("dir")
+	require.EqualErrno(t, experimentalsys.ENOENT, errno)
+
+	dirents, errno := d.Readdir(-1)
+	require.EqualErrno(t, 0, errno)
+	require.Zero(t, len(dirents))
+	st, errno := d.Stat()
+	require.EqualErrno(t, 0, errno)
+	require.Zero(t, st.Nlink)
+}
+
+func TestMemFS_Unlink(t *testing.T) {
+	testFS := MemFS()
+	require.EqualErrno(t, 0, testFS.Mkdir("dir", 0o700))
+	require.EqualErrno(t, 0, testFS.Symlink("dir", "dir-link"))
+	requireWriteFile(t, testFS, "file", "wazero")
+
+	require.EqualErrno(t, experimentalsys.ENOENT, testFS.Unlink("nope"))
+	require.EqualErrno(t, experimentalsys.EISDIR, testFS.Unlink("dir"))
+	require.EqualErrno(t, 0, testFS.Unlink("dir-link"))
+	_, errno := testFS.Stat("dir")
+	require.EqualErrno(t, 0, errno)
+
+	// An open file remains readable after it is unlinked.
+	f, errno := testFS.OpenFile("file", experimentalsys.O_RDONLY, 0)
+	require.EqualErrno(t, 0, errno)
+	defer f.Close()
+
+	require.EqualErrno(t, 0, testFS.Unlink("file"))
+	_, errno = testFS.Stat("file")
+	require.EqualErrno(t, experimentalsys.ENOENT, errno)
+
+	buf := make([]byte, 6)
+	requireRead(t, f, buf)
+	require.Equal(t, "wazero", string(buf))
+	st, errno := f.Stat()
+	require.EqualErrno(t, 0, errno)
+	require.Zero(t, st.Nlink)
+}
+
+func TestMemFS_Link(t *testing.T) {
+	testFS := newTestMemFS(t)
+
+	require.EqualErrno(t, experimentalsys.ENOENT, testFS.Link("cat", ""))
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Link("sub/test.txt", "sub/test.txt"))
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Link("sub/test.txt", "."))
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Link("sub/test.txt", ""))
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Link("sub/test.txt", "/"))
+	require.EqualErrno(t, experimentalsys.EPERM, testFS.Link("sub", "sub2"))
+	require.EqualErrno(t, 0, testFS.Link("sub/test.txt", "foo"))
+
+	st, errno := testFS.Stat("sub/test.txt")
+	require.EqualErrno(t, 0, errno)
+	linkSt, errno := testFS.Stat("foo")
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, st, linkSt)
+	require.Equal(t, uint64(2), st.Nlink)
+
+	// Writes are visible through both links.
+	requireWriteFile(t, testFS, "foo", "wazero")
+	requireContents(t, testFS, "sub/test.txt", "wazero")
+
+	require.EqualErrno(t, 0, testFS.Unlink("sub/test.txt"))
+	st, errno = testFS.Stat("foo")
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, uint64(1), st.Nlink)
+}
+
+func TestMemFS_Symlink(t *testing.T) {
+	testFS := newTestMemFS(t)
+
+	require.EqualErrno(t, experimentalsys.EPERM, testFS.Symlink("/test.txt", "sub/test.txt"))
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Symlink("sub/test.txt", "sub/test.txt"))
+	// Non-existing old name is allowed.
+	require.EqualErrno(t, 0, testFS.Symlink("non-existing", "aa"))
+	require.EqualErrno(t, 0, testFS.Symlink("sub/", "symlinked-subdir"))
+
+	st, errno := testFS.Lstat("aa")
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, fs.ModeSymlink, st.Mode.Type())
+	_, errno = testFS.Stat("aa")
+	require.EqualErrno(t, experimentalsys.ENOENT, errno)
+
+	st, errno = testFS.Stat("symlinked-subdir")
+	require.EqualErrno(t, 0, errno)
+	require.True(t, st.Mode.IsDir())
+	requireContents(t, testFS, "symlinked-subdir/test.txt", "greet sub dir\n")
+}
+
+func TestMemFS_Readlink(t *testing.T) {
+	testFS := newTestMemFS(t)
+	testReadlink(t, testFS, testFS)
+}
+
+func TestMemFS_Utimens(t *testing.T) {
+	tests := []struct {
+		name       string
+		atim, mtim int64
+	}{
+		{name: "nil"},
+		{name: "a=omit,m=omit", atim: experimentalsys.UTIME_OMIT, mtim: experimentalsys.UTIME_OMIT},
+		{name: "a=set,m=omit", atim: int64(123*time.Second + 4), mtim: experimentalsys.UTIME_OMIT},
+		{name: "a=omit,m=set", atim: experimentalsys.UTIME_OMIT, mtim: int64(123*time.Second + 4)},
+		{name: "a=set,m=set", atim: int64(123*time.Second + 4), mtim: int64(223*time.Second + 5)},
+	}
+
+	for _, fileType := range []string{"dir", "file", "link", "open file"} {
+		for _, tt := range tests {
+			tc := tt
+			fileType := fileType
+			t.Run(fileType+" "+tc.name, func(t *testing.T) {
+				testFS := MemFS()
+				requireWriteFile(t, testFS, "file", "")
+				require.EqualErrno(t, 0, testFS.Symlink("file", "file-link"))
+				require.EqualErrno(t, 0, testFS.Mkdir("dir", 0o700))
+
+				statPath := fileType
+				if fileType != "dir" {
+					statPath = "file"
+				}
+				oldSt, errno := testFS.Lstat(statPath)
+				require.EqualErrno(t, 0, errno)
+
+				switch fileType {
+				case "dir", "file":
+					errno = testFS.Utimens(fileType, tc.atim, tc.mtim)
+				case "link":
+					errno = testFS.Utimens("file-link", tc.atim, tc.mtim)
+				case "open file":
+					f, errno := testFS.OpenFile("file", experimentalsys.O_RDONLY, 0)
+					require.EqualErrno(t, 0, errno)
+					defer f.Close()
+					errno = f.Utimens(tc.atim, tc.mtim)
+				}
+				require.EqualErrno(t, 0, errno)
+
+				newSt, errno := testFS.Lstat(statPath)
+				require.EqualErrno(t, 0, errno)
+
+				if tc.atim == experimentalsys.UTIME_OMIT {
+					require.Equal(t, oldSt.Atim, newSt.Atim)
+				} else {
+					require.Equal(t, tc.atim, newSt.Atim)
+				}
+				if tc.mtim == experimentalsys.UTIME_OMIT {
+					require.Equal(t, oldSt.Mtim, newSt.Mtim)
+				} else {
+					require.Equal(t, tc.mtim, newSt.Mtim)
+				}
+			})
+		}
+	}
+
+	require.EqualErrno(t, experimentalsys.ENOENT, MemFS().Utimens("nope", 0, 0))
+}
+
+func TestMemFS_times(t *testing.T) {
+	testFS := MemFS()
+	require.EqualErrno(t, 0, testFS.Mkdir("dir", 0o700))
+
+	// requireTimes sets the times of the path to zero, calls fn, then
+	// requires which times it changed.
+	requireTimes := func(t *testing.T, path string, fn func(), atim, mtim, ctim bool) {
+		require.EqualErrno(t, 0, testFS.Utimens(path, 0, 0))
+		st, errno := testFS.Stat(path)
+		require.EqualErrno(t, 0, errno)
+		ctim0 := st.Ctim
+
+		time.Sleep(time.Millisecond) // avoid a coarse clock returning the same time.
+		fn()
+
+		st, errno = testFS.Stat(path)
+		require.EqualErrno(t, 0, errno)
+		require.Equal(t, atim, st.Atim != 0, "atim")
+		require.Equal(t, mtim, st.Mtim != 0, "mtim")
+		require.Equal(t, ctim, st.Ctim != ctim0, "ctim")
+	}
+
+	t.Run("create changes dir", func(t *testing.T) {
+		requireTimes(t, "dir", func() { requireWriteFile(t, testFS, "dir/file", "") }, false, true, true)
+	})
+
+	f, errno := testFS.OpenFile("dir/file", experimentalsys.O_RDWR, 0)
+	require.EqualErrno(t, 0, errno)
+	defer f.Close()
+
+	t.Run("write", func(t *testing.T) {
+		requireTimes(t, "dir/file", func() { requireWrite(t, f, []byte("wazero")) }, false, true, true)
+	})
+	t.Run("read", func(t *testing.T) {
+		requireTimes(t, "dir/file", func() { requirePread(t, f, make([]byte, 1), 0) }, true, false, false)
+	})
+	t.Run("truncate", func(t *testing.T) {
+		requireTimes(t, "dir/file", func() { require.EqualErrno(t, 0, f.Truncate(2)) }, false, true, true)
+	})
+	t.Run("chmod", func(t *testing.T) {
+		requireTimes(t, "dir/file", func() { require.EqualErrno(t, 0, testFS.Chmod("dir/file", 0o400)) }, false, false, true)
+	})
+	t.Run("link", func(t *testing.T) {
+		requireTimes(t, "dir/file", func() { require.EqualErrno(t, 0, testFS.Link("dir/file", "dir/link")) }, false, false, true)
+	})
+	t.Run("readdir", func(t *testing.T) {
+		d, errno := testFS.OpenFile("dir", experimentalsys.O_RDONLY, 0)
+		require.EqualErrno(t, 0, errno)
+		defer d.Close()
+		requireTimes(t, "dir", func() { requireReaddir(t, d, -1, true) }, true, false, false)
+	})
+}
+
+func TestMemFS_Readdir(t *testing.T) {
+	testFS := MemFS()
+	require.EqualErrno(t, 0, testFS.Mkdir("dir", 0o700))
+	requireWriteFile(t, testFS, "dir/b", "")
+
+	d, errno := testFS.OpenFile("dir", experimentalsys.O_RDONLY, 0)
+	require.EqualErrno(t, 0, errno)
+	defer d.Close()
+
+	// Files written after open are visible.
+	requireWriteFile(t, testFS, "dir/a", "")
+	requireWriteFile(t, testFS, "dir/c", "")
+
+	dirents, errno := d.Readdir(2)
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, 2, len(dirents))
+	require.Equal(t, "a", dirents[0].Name)
+	require.Equal(t, "b", dirents[1].Name)
+
+	// Files removed before they are read are not.
+	require.EqualErrno(t, 0, testFS.Unlink("dir/c"))
+	dirents, errno = d.Readdir(-1)
+	require.EqualErrno(t, 0, errno)
+	require.Zero(t, len(dirents))
+
+	// Rewinding reads from the start.
+	requireSeek(t, d, 0, io.SeekStart)
+	dirents = requireReaddir(t, d, -1, true)
+	require.Equal(t, 2, len(dirents))
+
+	_, errno = d.Seek(1, io.SeekStart)
+	require.EqualErrno(t, experimentalsys.EISDIR, errno)
+
+	f, errno := testFS.OpenFile("dir/a", experimentalsys.O_RDONLY, 0)
+	require.EqualErrno(t, 0, errno)
+	defer f.Close()
+	_, errno = f.Readdir(-1)
+	require.EqualErrno(t, experimentalsys.EBADF, errno)
+}
+
+func TestMemFile_Truncate(t *testing.T) {
+	testFS := MemFS()
+	requireWriteFile(t, testFS, "file", "wazero")
+
+	f, errno := testFS.OpenFile("file", experimentalsys.O_RDWR, 0)
+	require.EqualErrno(t, 0, errno)
+	defer f.Close()
+
+	require.EqualErrno(t, 0, f.Truncate(2))
+	requireContents(t, testFS, "file", "wa")
+
+	// Growing fills with zeros, even if the data was truncated before.
+	require.EqualErrno(t, 0, f.Truncate(4))
+	requireContents(t, testFS, "file", "wa\x00\x00")
+
+	// Writing past the end also fills with zeros.
+	requirePwrite(t, f, []byte("!"), 6)
+	requireContents(t, testFS, "file", "wa\x00\x00\x00\x00!")
+
+	require.EqualErrno(t, experimentalsys.EINVAL, f.Truncate(-1))
+
+	d, errno := testFS.OpenFile(".", experimentalsys.O_RDONLY, 0)
+	require.EqualErrno(t, 0, errno)
+	defer d.Close()
+	require.EqualErrno(t, experimentalsys.EISDIR, d.Truncate(0))
+
+	r, errno := testFS.OpenFile("file", experimentalsys.O_RDONLY, 0)
+	require.EqualErrno(t, 0, errno)
+	defer r.Close()
+	require.EqualErrno(t, experimentalsys.EBADF, r.Truncate(0))
+}
+
+// requireWriteFile creates or truncates the file at the path and writes the
+// contents to it.
+func requireWriteFile(t *testing.T, testFS experimentalsys.FS, path, contents string) {
+	f, errno := testFS.OpenFile(path, experimentalsys.O_WRONLY|experimentalsys.O_CREAT|experimentalsys.O_TRUNC, 0o600)
+	require.EqualErrno(t, 0, errno)
+	defer f.Close()
+	if contents != "" {
+		requireWrite(t, f, []byte(contents))
+	}
+}
+
+// requireContents requires the file at the path has the contents.
+func requireContents(t *testing.T, testFS experimentalsys.FS, path, contents string) {
+	f, errno := testFS.OpenFile(path, experimentalsys.O_RDONLY, 0)
+	require.EqualErrno(t, 0, errno)
+	defer f.Close()
+
+	var st sys.Stat_t
+	st, errno = f.Stat()
+	require.EqualErrno(t, 0, errno)
+	buf := make([]byte, st.Size)
+	_, errno = f.Pread(buf, 0)
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, contents, string(buf))
+}
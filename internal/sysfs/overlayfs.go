@@ -0,0 +1,781 @@
+package sysfs
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// OverlayFS is a copy-on-write sys.FS, which merges a read-only Lower layer
+// with a writable Upper one, such as MemFS.
+//
+// Reads prefer paths in Upper. Files are copied from Lower to Upper before
+// they are written, and deleted paths are hidden in Lower with whiteouts kept
+// by this overlay. Lower is never written, so Changes can be compared to it.
+//
+// Stat_t.Dev and Stat_t.Ino are those of the layer a path is read from, as
+// inodes are only unique per file system.
+//
+// The zero value is ready to use once Lower and Upper are set, and neither
+// must be changed by anything else while in use.
+type OverlayFS struct {
+	Lower, Upper experimentalsys.FS
+
+	// mux guards the fields below, and serializes operations, as most of
+	// them read both layers before changing the upper one.
+	mux sync.Mutex
+
+	// whiteouts are paths which were deleted, hiding them in Lower.
+	whiteouts map[string]struct{}
+
+	// opaque are directories re-created in Upper, hiding any entries in
+	// Lower.
+	opaque map[string]struct{}
+}
+
+// ChangeKind is the kind of Change.
+type ChangeKind uint8
+
+const (
+	// ChangeAdded is a path which doesn't exist in the lower layer.
+	ChangeAdded ChangeKind = iota + 1
+	// ChangeModified is a path which exists in both layers.
+	ChangeModified
+	// ChangeDeleted is a path which exists in the lower layer, but was
+	// deleted.
+	ChangeDeleted
+)
+
+// String implements fmt.Stringer
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeModified:
+		return "modified"
+	case ChangeDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// Change is a path changed by writes to an OverlayFS.
+type Change struct {
+	// Path is relative to the root of the OverlayFS, e.g. "sub/file.txt".
+	Path string
+	Kind ChangeKind
+}
+
+// lock locks the overlay, initializing it if needed.
+func (o *OverlayFS) lock() {
+	o.mux.Lock()
+	if o.whiteouts == nil {
+		o.whiteouts = map[string]struct{}{}
+		o.opaque = map[string]struct{}{}
+	}
+}
+
+// Changes returns the paths changed in the upper layer, sorted by path.
+//
+// Note: When a directory is added or deleted, its files are also reported
+// as added, but not as deleted. Parent directories of a changed file are
+// reported as modified, as they are copied to the upper layer.
+func (o *OverlayFS) Changes() ([]Change, experimentalsys.Errno) {
+	o.lock()
+	defer o.mux.Unlock()
+
+	var changes []Change
+	if errno := o.upperChanges(".", &changes); errno != 0 {
+		return nil, errno
+	}
+	for p := range o.whiteouts {
+		changes = append(changes, Change{Path: p, Kind: ChangeDeleted})
+	}
+	for p := range o.opaque {
+		if _, hidden := o.hidden(p); hidden {
+			continue // the directory was deleted since.
+		}
+		lower, errno := readdirAll(o.Lower, p)
+		if errno != 0 {
+			continue // nothing was hidden.
+		}
+		for _, e := range lower {
+			child := path.Join(p, e.Name)
+			if _, errno = o.Upper.Lstat(child); errno == experimentalsys.ENOENT {
+				changes = append(changes, Change{Path: child, Kind: ChangeDeleted})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, 0
+}
+
+// upperChanges appends the entries under the upper directory as changes.
+func (o *OverlayFS) upperChanges(dir string, changes *[]Change) experimentalsys.Errno {
+	dirents, errno := readdirAll(o.Upper, dir)
+	if errno != 0 {
+		return errno
+	}
+	for _, e := range dirents {
+		p := path.Join(dir, e.Name)
+		kind := ChangeAdded
+		// Lower is never written, so this tells if the path existed before.
+		if _, errno = o.Lower.Lstat(p); errno == 0 {
+			kind = ChangeModified
+		}
+		*changes = append(*changes, Change{Path: p, Kind: kind})
+		if e.IsDir() {
+			if errno = o.upperChanges(p, changes); errno != 0 {
+				return errno
+			}
+		}
+	}
+	return 0
+}
+
+// hidden returns true if the path in Lower is hidden by a whiteout or opaque
+// directory. The returned path is that of the whiteout or directory.
+func (o *OverlayFS) hidden(p string) (string, bool) {
+	for q := p; q != "."; q = path.Dir(q) {
+		if _, ok := o.whiteouts[q]; ok {
+			return q, true
+		}
+		if _, ok := o.opaque[q]; ok && q != p {
+			return q, true
+		}
+	}
+	return "", false
+}
+
+// lstat returns the status of the path in the upper layer, or the lower one
+// if it is only there.
+func (o *OverlayFS) lstat(p string) (st sys.Stat_t, inUpper bool, errno experimentalsys.Errno) {
+	st, errno = o.Upper.Lstat(p)
+	switch errno {
+	case 0:
+		return st, true, 0
+	case experimentalsys.ENOENT, experimentalsys.ENOTDIR:
+	default:
+		return
+	}
+	if _, hidden := o.hidden(p); hidden {
+		return sys.Stat_t{}, false, experimentalsys.ENOENT
+	}
+	st, errno = o.Lower.Lstat(p)
+	return
+}
+
+// layer returns the upper layer if inUpper, or the lower one otherwise.
+func (o *OverlayFS) layer(inUpper bool) experimentalsys.FS {
+	if inUpper {
+		return o.Upper
+	}
+	return o.Lower
+}
+
+// realPath returns the path without any symbolic links in its directories,
+// following those in both layers. The last path component is only followed
+// when followLast is true.
+//
+// When only the last path component doesn't exist, this returns the path and
+// ENOENT, so that the caller can create it.
+func (o *OverlayFS) realPath(p string, followLast bool) (string, experimentalsys.Errno) {
+	resolved := "."
+	names := splitPath(p)
+	for links := 0; len(names) > 0; {
+		name := names[0]
+		names = names[1:]
+		switch name {
+		case ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved) // the root is its own parent
+			continue
+		}
+
+		next := path.Join(resolved, name)
+		st, inUpper, errno := o.lstat(next)
+		if errno == experimentalsys.ENOENT && len(names) == 0 {
+			return next, errno
+		} else if errno != 0 {
+			return "", errno
+		}
+
+		if st.Mode.Type() == fs.ModeSymlink && (len(names) > 0 || followLast) {
+			if links++; links > maxSymlinks {
+				return "", experimentalsys.ELOOP
+			}
+			target, errno := o.layer(inUpper).Readlink(next)
+			if errno != 0 {
+				return "", errno
+			}
+			// The target is relative to the directory containing the link,
+			// and an absolute one is relative to the root of this overlay.
+			if path.IsAbs(target) {
+				resolved = "."
+			}
+			names = append(splitPath(target), names...)
+			continue
+		}
+		if len(names) > 0 && !st.Mode.IsDir() {
+			return "", experimentalsys.ENOTDIR
+		}
+		resolved = next
+	}
+	return resolved, 0
+}
+
+// realNewPath is like realPath, except the path must not exist. The parent
+// directory of the result is copied to the upper layer.
+func (o *OverlayFS) realNewPath(p string) (string, experimentalsys.Errno) {
+	rp, errno := o.realPath(p, false)
+	switch errno {
+	case 0:
+		return "", experimentalsys.EEXIST
+	case experimentalsys.ENOENT:
+		if rp == "" {
+			return "", errno // a parent doesn't exist.
+		}
+	default:
+		return "", errno
+	}
+	if errno = o.copyUp(path.Dir(rp)); errno != 0 {
+		return "", errno
+	}
+	return rp, 0
+}
+
+// readdir returns the entries of the directory in both layers, except those
+// hidden, sorted by name.
+func (o *OverlayFS) readdir(p string) ([]experimentalsys.Dirent, experimentalsys.Errno) {
+	_, inUpper, errno := o.lstat(p)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	var dirents []experimentalsys.Dirent
+	names := map[string]struct{}{}
+	if inUpper {
+		if dirents, errno = readdirAll(o.Upper, p); errno != 0 {
+			return nil, errno
+		}
+		for _, e := range dirents {
+			names[e.Name] = struct{}{}
+		}
+	}
+
+	_, opaque := o.opaque[p]
+	if _, hidden := o.hidden(p); !opaque && !hidden {
+		if st, errno := o.Lower.Lstat(p); errno == 0 && st.Mode.IsDir() {
+			lower, errno := readdirAll(o.Lower, p)
+			if errno != 0 {
+				return nil, errno
+			}
+			for _, e := range lower {
+				if _, ok := names[e.Name]; ok {
+					continue
+				}
+				if _, ok := o.whiteouts[path.Join(p, e.Name)]; ok {
+					continue
+				}
+				dirents = append(dirents, e)
+			}
+		}
+	}
+	sort.Slice(dirents, func(i, j int) bool { return dirents[i].Name < dirents[j].Name })
+	return dirents, 0
+}
+
+// readdirAll returns all entries of the directory in the file system.
+func readdirAll(fsys experimentalsys.FS, p string) ([]experimentalsys.Dirent, experimentalsys.Errno) {
+	f, errno := fsys.OpenFile(p, experimentalsys.O_RDONLY|experimentalsys.O_DIRECTORY, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	defer f.Close()
+	return f.Readdir(-1)
+}
+
+// copyUp copies the path and its parent directories from the lower layer to
+// the upper one, unless already there.
+func (o *OverlayFS) copyUp(p string) experimentalsys.Errno {
+	st, inUpper, errno := o.lstat(p)
+	if errno != 0 || inUpper {
+		return errno
+	}
+	if errno = o.copyUp(path.Dir(p)); errno != 0 {
+		return errno
+	}
+
+	switch st.Mode.Type() {
+	case fs.ModeDir:
+		errno = o.Upper.Mkdir(p, st.Mode.Perm())
+	case fs.ModeSymlink:
+		var target string
+		if target, errno = o.Lower.Readlink(p); errno == 0 {
+			return o.Upper.Symlink(target, p)
+		}
+	case 0:
+		errno = o.copyUpFile(p, st.Mode.Perm())
+	default:
+		return experimentalsys.ENOTSUP // e.g. a device
+	}
+	if errno == 0 {
+		errno = o.Upper.Utimens(p, st.Atim, st.Mtim)
+	}
+	return errno
+}
+
+// copyUpFile copies the contents of the regular file from the lower layer to
+// the upper one.
+func (o *OverlayFS) copyUpFile(p string, perm fs.FileMode) experimentalsys.Errno {
+	src, errno := o.Lower.OpenFile(p, experimentalsys.O_RDONLY, 0)
+	if errno != 0 {
+		return errno
+	}
+	defer src.Close()
+
+	dst, errno := o.Upper.OpenFile(p, experimentalsys.O_WRONLY|experimentalsys.O_CREAT|experimentalsys.O_EXCL, perm)
+	if errno != 0 {
+		return errno
+	}
+	defer dst.Close()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, errno := src.Read(buf)
+		if errno != 0 {
+			return errno
+		} else if n == 0 {
+			return 0
+		}
+		if _, errno = dst.Write(buf[:n]); errno != 0 {
+			return errno
+		}
+	}
+}
+
+// copyUpTree is like copyUp, except directories are copied recursively.
+func (o *OverlayFS) copyUpTree(p string) experimentalsys.Errno {
+	st, _, errno := o.lstat(p)
+	if errno != 0 {
+		return errno
+	} else if errno = o.copyUp(p); errno != 0 || !st.Mode.IsDir() {
+		return errno
+	}
+	dirents, errno := o.readdir(p)
+	if errno != 0 {
+		return errno
+	}
+	for _, e := range dirents {
+		if errno = o.copyUpTree(path.Join(p, e.Name)); errno != 0 {
+			return errno
+		}
+	}
+	return 0
+}
+
+// created is called after the path is created in the upper layer, which
+// replaces any path deleted in the lower one.
+func (o *OverlayFS) created(p string, isDir bool) {
+	if _, ok := o.whiteouts[p]; ok {
+		delete(o.whiteouts, p)
+		if isDir {
+			o.opaque[p] = struct{}{}
+		}
+	}
+}
+
+// removed is called after the path is removed from the upper layer, so that
+// it is also hidden in the lower one.
+func (o *OverlayFS) removed(p string) {
+	prefix := p + "/"
+	for q := range o.whiteouts {
+		if strings.HasPrefix(q, prefix) {
+			delete(o.whiteouts, q)
+		}
+	}
+	for q := range o.opaque {
+		if q == p || strings.HasPrefix(q, prefix) {
+			delete(o.opaque, q)
+		}
+	}
+	if _, hidden := o.hidden(p); !hidden {
+		if _, errno := o.Lower.Lstat(p); errno == 0 {
+			o.whiteouts[p] = struct{}{}
+		}
+	}
+}
+
+// OpenFile implements the same method as documented on sys.FS
+func (o *OverlayFS) OpenFile(path string, flag experimentalsys.Oflag, perm fs.FileMode) (experimentalsys.File, experimentalsys.Errno) {
+	o.lock()
+	defer o.mux.Unlock()
+
+	rp, errno := o.realPath(path, flag&experimentalsys.O_NOFOLLOW == 0)
+	if errno == experimentalsys.ENOENT && rp != "" && flag&experimentalsys.O_CREAT != 0 {
+		if errno = o.copyUp(pathDir(rp)); errno != 0 {
+			return nil, errno
+		}
+		f, errno := o.Upper.OpenFile(rp, flag, perm)
+		if errno == 0 {
+			o.created(rp, false)
+		}
+		return f, errno
+	} else if errno != 0 {
+		return nil, errno
+	} else if flag&(experimentalsys.O_CREAT|experimentalsys.O_EXCL) == experimentalsys.O_CREAT|experimentalsys.O_EXCL {
+		return nil, experimentalsys.EEXIST
+	}
+
+	st, inUpper, errno := o.lstat(rp)
+	if errno != 0 {
+		return nil, errno
+	}
+	if st.Mode.IsDir() {
+		f, errno := o.layer(inUpper).OpenFile(rp, flag, perm)
+		if errno != 0 {
+			return nil, errno
+		}
+		return &overlayDir{File: f, fs: o, path: rp}, 0
+	}
+
+	writable := flag&(experimentalsys.O_RDWR|experimentalsys.O_WRONLY) != 0
+	if !inUpper && st.Mode.Type() == 0 && (writable || flag&experimentalsys.O_TRUNC != 0) {
+		if errno = o.copyUp(rp); errno != 0 {
+			return nil, errno
+		}
+		inUpper = true
+	}
+	return o.layer(inUpper).OpenFile(rp, flag, perm)
+}
+
+// pathDir is path.Dir, for use where a parameter shadows the path package.
+func pathDir(p string) string {
+	return path.Dir(p)
+}
+
+// Lstat implements the same method as documented on sys.FS
+func (o *OverlayFS) Lstat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	o.lock()
+	defer o.mux.Unlock()
+
+	rp, errno := o.realPath(path, false)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	st, _, errno := o.lstat(rp)
+	return st, errno
+}
+
+// Stat implements the same method as documented on sys.FS
+func (o *OverlayFS) Stat(path string) (sys.Stat_t, experimentalsys.Errno) {
+	o.lock()
+	defer o.mux.Unlock()
+
+	rp, errno := o.realPath(path, true)
+	if errno != 0 {
+		return sys.Stat_t{}, errno
+	}
+	st, _, errno := o.lstat(rp)
+	return st, errno
+}
+
+// Mkdir implements the same method as documented on sys.FS
+func (o *OverlayFS) Mkdir(path string, perm fs.FileMode) experimentalsys.Errno {
+	o.lock()
+	defer o.mux.Unlock()
+
+	rp, errno := o.realNewPath(path)
+	if errno == experimentalsys.ENOTDIR {
+		return experimentalsys.ENOENT // consistent with DirFS
+	} else if errno != 0 {
+		return errno
+	}
+	if errno = o.Upper.Mkdir(rp, perm); errno == 0 {
+		o.created(rp, true)
+	}
+	return errno
+}
+
+// Chmod implements the same method as documented on sys.FS
+func (o *OverlayFS) Chmod(path string, perm fs.FileMode) experimentalsys.Errno {
+	o.lock()
+	defer o.mux.Unlock()
+
+	rp, errno := o.realPath(path, true)
+	if errno != 0 {
+		return errno
+	} else if errno = o.copyUp(rp); errno != 0 {
+		return errno
+	}
+	return o.Upper.Chmod(rp, perm)
+}
+
+// Rename implements the same method as documented on sys.FS
+func (o *OverlayFS) Rename(from, to string) experimentalsys.Errno {
+	o.lock()
+	defer o.mux.Unlock()
+
+	rf, errno := o.realPath(from, false)
+	if errno != 0 {
+		return errno
+	}
+	rt, errno := o.realPath(to, false)
+	exists := errno == 0
+	if errno != 0 && (errno != experimentalsys.ENOENT || rt == "") {
+		return errno
+	}
+	switch {
+	case rf == "." || rt == ".":
+		return experimentalsys.EINVAL
+	case rf == rt:
+		return 0 // same file, so nothing to do.
+	}
+
+	fromSt, _, errno := o.lstat(rf)
+	if errno != 0 {
+		return errno
+	}
+	isDir := fromSt.Mode.IsDir()
+	if isDir && strings.HasPrefix(rt, rf+"/") {
+		return experimentalsys.EINVAL // can't move a directory into itself.
+	}
+
+	if exists {
+		toSt, _, errno := o.lstat(rt)
+		if errno != 0 {
+			return errno
+		}
+		switch {
+		case isDir && !toSt.Mode.IsDir():
+			return experimentalsys.ENOTDIR
+		case !isDir && toSt.Mode.IsDir():
+			return experimentalsys.EISDIR
+		case isDir:
+			if dirents, errno := o.readdir(rt); errno != 0 {
+				return errno
+			} else if len(dirents) > 0 {
+				return experimentalsys.ENOTEMPTY
+			}
+		}
+	}
+
+	// Copy everything to be moved, so that nothing is left in the lower
+	// layer when it is hidden below.
+	if errno = o.copyUpTree(rf); errno != 0 {
+		return errno
+	} else if errno = o.copyUp(path.Dir(rt)); errno != 0 {
+		return errno
+	}
+	if errno = o.Upper.Rename(rf, rt); errno != 0 {
+		return errno
+	}
+
+	o.removed(rf)
+	delete(o.whiteouts, rt)
+	if isDir {
+		// Anything in the lower directory was deleted before.
+		o.removed(rt)
+		delete(o.whiteouts, rt)
+		o.opaque[rt] = struct{}{}
+	}
+	return 0
+}
+
+// Rmdir implements the same method as documented on sys.FS
+func (o *OverlayFS) Rmdir(path string) experimentalsys.Errno {
+	o.lock()
+	defer o.mux.Unlock()
+
+	rp, errno := o.realPath(path, false)
+	if errno != 0 {
+		return errno
+	} else if rp == "." {
+		return experimentalsys.EINVAL
+	}
+
+	st, inUpper, errno := o.lstat(rp)
+	if errno != 0 {
+		return errno
+	} else if !st.Mode.IsDir() {
+		return experimentalsys.ENOTDIR
+	}
+	if dirents, errno := o.readdir(rp); errno != 0 {
+		return errno
+	} else if len(dirents) > 0 {
+		return experimentalsys.ENOTEMPTY
+	}
+	if inUpper {
+		if errno = o.Upper.Rmdir(rp); errno != 0 {
+			return errno
+		}
+	}
+	o.removed(rp)
+	return 0
+}
+
+// Unlink implements the same method as documented on sys.FS
+func (o *OverlayFS) Unlink(path string) experimentalsys.Errno {
+	o.lock()
+	defer o.mux.Unlock()
+
+	rp, errno := o.realPath(path, false)
+	if errno != 0 {
+		return errno
+	}
+
+	st, inUpper, errno := o.lstat(rp)
+	if errno != 0 {
+		return errno
+	} else if st.Mode.IsDir() {
+		return experimentalsys.EISDIR
+	}
+	if inUpper {
+		if errno = o.Upper.Unlink(rp); errno != 0 {
+			return errno
+		}
+	}
+	o.removed(rp)
+	return 0
+}
+
+// Link implements the same method as documented on sys.FS
+func (o *OverlayFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	o.lock()
+	defer o.mux.Unlock()
+
+	ro, errno := o.realPath(oldPath, false)
+	if errno != 0 {
+		return errno
+	}
+	if st, _, errno := o.lstat(ro); errno != 0 {
+		return errno
+	} else if st.Mode.IsDir() {
+		return experimentalsys.EPERM
+	}
+
+	rn, errno := o.realNewPath(newPath)
+	if errno != 0 {
+		return errno
+	} else if errno = o.copyUp(ro); errno != 0 {
+		return errno
+	}
+	if errno = o.Upper.Link(ro, rn); errno == 0 {
+		o.created(rn, false)
+	}
+	return errno
+}
+
+// Symlink implements the same method as documented on sys.FS
+func (o *OverlayFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	// Like DirFS, a symbolic link must be relative to stay in this file system.
+	if path.IsAbs(oldPath) {
+		return experimentalsys.EPERM
+	}
+
+	o.lock()
+	defer o.mux.Unlock()
+
+	rl, errno := o.realNewPath(linkName)
+	if errno != 0 {
+		return errno
+	}
+	if errno = o.Upper.Symlink(oldPath, rl); errno == 0 {
+		o.created(rl, false)
+	}
+	return errno
+}
+
+// Readlink implements the same method as documented on sys.FS
+func (o *OverlayFS) Readlink(path string) (string, experimentalsys.Errno) {
+	o.lock()
+	defer o.mux.Unlock()
+
+	rp, errno := o.realPath(path, false)
+	if errno != 0 {
+		return "", errno
+	}
+	_, inUpper, errno := o.lstat(rp)
+	if errno != 0 {
+		return "", errno
+	}
+	return o.layer(inUpper).Readlink(rp)
+}
+
+// Utimens implements the same method as documented on sys.FS
+func (o *OverlayFS) Utimens(path string, atim, mtim int64) experimentalsys.Errno {
+	o.lock()
+	defer o.mux.Unlock()
+
+	rp, errno := o.realPath(path, true)
+	if errno != 0 {
+		return errno
+	} else if errno = o.copyUp(rp); errno != 0 {
+		return errno
+	}
+	return o.Upper.Utimens(rp, atim, mtim)
+}
+
+// compile-time check to ensure overlayDir implements sys.File.
+var _ experimentalsys.File = (*overlayDir)(nil)
+
+// overlayDir is a directory opened by an OverlayFS. Its status is that of
+// the layer it was opened from, but Readdir includes entries of both.
+type overlayDir struct {
+	experimentalsys.File
+
+	fs   *OverlayFS
+	path string
+
+	// dirents are the entries not read yet, or nil if none were read since
+	// the directory was opened or rewound.
+	dirents []experimentalsys.Dirent
+}
+
+// Seek implements the same method as documented on sys.File
+func (d *overlayDir) Seek(offset int64, whence int) (int64, experimentalsys.Errno) {
+	newOffset, errno := d.File.Seek(offset, whence)
+	if errno == 0 {
+		d.dirents = nil
+	}
+	return newOffset, errno
+}
+
+// Readdir implements the same method as documented on sys.File
+func (d *overlayDir) Readdir(n int) (dirents []experimentalsys.Dirent, errno experimentalsys.Errno) {
+	if d.dirents == nil {
+		o := d.fs
+		o.lock()
+		d.dirents, errno = o.readdir(d.path)
+		o.mux.Unlock()
+		if errno == experimentalsys.ENOENT {
+			d.dirents, errno = []experimentalsys.Dirent{}, 0 // deleted while reading.
+		} else if errno != 0 {
+			return nil, errno
+		}
+	}
+
+	if n <= 0 || n > len(d.dirents) {
+		n = len(d.dirents)
+	}
+	dirents, d.dirents = d.dirents[:n], d.dirents[n:]
+	return dirents, 0
+}
+
+// Utimens implements the same method as documented on sys.File
+func (d *overlayDir) Utimens(atim, mtim int64) experimentalsys.Errno {
+	o := d.fs
+	o.lock()
+	defer o.mux.Unlock()
+
+	if errno := o.copyUp(d.path); errno != 0 {
+		return errno
+	}
+	return o.Upper.Utimens(d.path, atim, mtim)
+}
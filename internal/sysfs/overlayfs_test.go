@@ -0,0 +1,329 @@
+package sysfs
+
+import (
+	"io/fs"
+	"testing"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// newTestOverlayFS returns an OverlayFS whose read-only lower layer includes
+// the files in fstest.FS, and the lower layer to inspect it.
+func newTestOverlayFS(t *testing.T) (*OverlayFS, experimentalsys.FS) {
+	lower := newTestMemFS(t)
+	return &OverlayFS{Lower: &ReadFS{FS: lower}, Upper: MemFS()}, lower
+}
+
+func TestOverlayFS_OpenFile(t *testing.T) {
+	testFS, lower := newTestOverlayFS(t)
+
+	testOpen_Read(t, testFS, true, true)
+
+	t.Run("copies up on write", func(t *testing.T) {
+		f, errno := testFS.OpenFile("sub/test.txt", experimentalsys.O_WRONLY|experimentalsys.O_APPEND, 0)
+		require.EqualErrno(t, 0, errno)
+		requireWrite(t, f, []byte("!"))
+		require.EqualErrno(t, 0, f.Close())
+
+		requireContents(t, testFS, "sub/test.txt", "greet sub dir\n!")
+		requireContents(t, lower, "sub/test.txt", "greet sub dir\n")
+	})
+
+	t.Run("copies up on truncate", func(t *testing.T) {
+		requireWriteFile(t, testFS, "animals.txt", "")
+
+		requireContents(t, testFS, "animals.txt", "")
+		st, errno := lower.Stat("animals.txt")
+		require.EqualErrno(t, 0, errno)
+		require.NotEqual(t, int64(0), st.Size)
+	})
+
+	t.Run("keeps permissions and times", func(t *testing.T) {
+		lowerSt, errno := lower.Stat("empty.txt")
+		require.EqualErrno(t, 0, errno)
+
+		f, errno := testFS.OpenFile("empty.txt", experimentalsys.O_RDWR, 0)
+		require.EqualErrno(t, 0, errno)
+		require.EqualErrno(t, 0, f.Close())
+
+		st, errno := testFS.Upper.Stat("empty.txt")
+		require.EqualErrno(t, 0, errno)
+		require.Equal(t, lowerSt.Mode, st.Mode)
+		require.Equal(t, lowerSt.Mtim, st.Mtim)
+	})
+
+	t.Run("creates in upper", func(t *testing.T) {
+		requireWriteFile(t, testFS, "dir/new.txt", "new")
+
+		requireContents(t, testFS, "dir/new.txt", "new")
+		_, errno := lower.Stat("dir/new.txt")
+		require.EqualErrno(t, experimentalsys.ENOENT, errno)
+	})
+
+	t.Run("O_EXCL", func(t *testing.T) {
+		_, errno := testFS.OpenFile("empty.txt", experimentalsys.O_RDWR|experimentalsys.O_CREAT|experimentalsys.O_EXCL, 0o600)
+		require.EqualErrno(t, experimentalsys.EEXIST, errno)
+	})
+
+	t.Run("missing parent", func(t *testing.T) {
+		_, errno := testFS.OpenFile("missing/file", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o600)
+		require.EqualErrno(t, experimentalsys.ENOENT, errno)
+	})
+}
+
+func TestOverlayFS_Lstat(t *testing.T) {
+	testFS, lower := newTestOverlayFS(t)
+	// Make links in the lower layer, as inodes are only unique per layer.
+	for _, path := range []string{"animals.txt", "sub", "sub-link"} {
+		require.EqualErrno(t, 0, lower.Symlink(path, path+"-link"))
+	}
+
+	testLstat(t, testFS)
+}
+
+func TestOverlayFS_Stat(t *testing.T) {
+	testFS, lower := newTestOverlayFS(t)
+	testStat(t, testFS)
+
+	t.Run("follows symbolic links across layers", func(t *testing.T) {
+		require.EqualErrno(t, 0, lower.Symlink("sub", "sub-link"))
+		require.EqualErrno(t, 0, testFS.Symlink("../animals.txt", "sub-link/animals-link"))
+
+		st, errno := testFS.Stat("animals.txt")
+		require.EqualErrno(t, 0, errno)
+		linkSt, errno := testFS.Stat("sub-link/animals-link")
+		require.EqualErrno(t, 0, errno)
+		require.Equal(t, st, linkSt)
+
+		// The link was made in the directory it resolves to.
+		_, errno = testFS.Upper.Lstat("sub/animals-link")
+		require.EqualErrno(t, 0, errno)
+	})
+
+	t.Run("symbolic link loop", func(t *testing.T) {
+		require.EqualErrno(t, 0, testFS.Symlink("loop2", "loop1"))
+		require.EqualErrno(t, 0, testFS.Symlink("loop1", "loop2"))
+
+		_, errno := testFS.Stat("loop1")
+		require.EqualErrno(t, experimentalsys.ELOOP, errno)
+	})
+}
+
+func TestOverlayFS_Readdir(t *testing.T) {
+	testFS, _ := newTestOverlayFS(t)
+
+	requireWriteFile(t, testFS, "sub/new.txt", "new")
+	requireWriteFile(t, testFS, "sub/test.txt", "changed")
+
+	f, errno := testFS.OpenFile("sub", experimentalsys.O_RDONLY|experimentalsys.O_DIRECTORY, 0)
+	require.EqualErrno(t, 0, errno)
+	defer f.Close()
+
+	// Entries of both layers are merged by name, without duplicates.
+	dirents := requireReaddir(t, f, 1, true)
+	require.Equal(t, "new.txt", dirents[0].Name)
+	dirents = requireReaddir(t, f, -1, true)
+	require.Equal(t, 1, len(dirents))
+	require.Equal(t, "test.txt", dirents[0].Name)
+	dirents = requireReaddir(t, f, -1, true)
+	require.Equal(t, 0, len(dirents))
+
+	t.Run("rewind", func(t *testing.T) {
+		require.EqualErrno(t, 0, testFS.Unlink("sub/new.txt"))
+
+		_, errno := f.Seek(0, 0)
+		require.EqualErrno(t, 0, errno)
+		dirents := requireReaddir(t, f, -1, true)
+		require.Equal(t, 1, len(dirents))
+		require.Equal(t, "test.txt", dirents[0].Name)
+	})
+}
+
+func TestOverlayFS_Mkdir(t *testing.T) {
+	testFS, lower := newTestOverlayFS(t)
+
+	require.EqualErrno(t, 0, testFS.Mkdir("sub/dir", 0o700))
+	st, errno := testFS.Stat("sub/dir")
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, fs.ModeDir|0o700, st.Mode)
+	_, errno = lower.Stat("sub/dir")
+	require.EqualErrno(t, experimentalsys.ENOENT, errno)
+
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Mkdir("sub", 0o700))
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Mkdir("animals.txt", 0o700))
+	require.EqualErrno(t, experimentalsys.ENOENT, testFS.Mkdir("animals.txt/dir", 0o700))
+	require.EqualErrno(t, experimentalsys.ENOENT, testFS.Mkdir("missing/dir", 0o700))
+}
+
+func TestOverlayFS_Chmod(t *testing.T) {
+	testFS, lower := newTestOverlayFS(t)
+
+	require.EqualErrno(t, 0, testFS.Chmod("sub/test.txt", 0o400))
+
+	st, errno := testFS.Stat("sub/test.txt")
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, fs.FileMode(0o400), st.Mode)
+	lowerSt, errno := lower.Stat("sub/test.txt")
+	require.EqualErrno(t, 0, errno)
+	require.NotEqual(t, st.Mode, lowerSt.Mode)
+	requireContents(t, testFS, "sub/test.txt", "greet sub dir\n")
+}
+
+func TestOverlayFS_Unlink(t *testing.T) {
+	testFS, lower := newTestOverlayFS(t)
+
+	require.EqualErrno(t, 0, testFS.Unlink("animals.txt"))
+	_, errno := testFS.Stat("animals.txt")
+	require.EqualErrno(t, experimentalsys.ENOENT, errno)
+	_, errno = lower.Stat("animals.txt")
+	require.EqualErrno(t, 0, errno)
+
+	require.EqualErrno(t, experimentalsys.ENOENT, testFS.Unlink("animals.txt"))
+	require.EqualErrno(t, experimentalsys.EISDIR, testFS.Unlink("sub"))
+
+	t.Run("recreate", func(t *testing.T) {
+		requireWriteFile(t, testFS, "animals.txt", "cat")
+		requireContents(t, testFS, "animals.txt", "cat")
+	})
+}
+
+func TestOverlayFS_Rmdir(t *testing.T) {
+	testFS, _ := newTestOverlayFS(t)
+
+	require.EqualErrno(t, experimentalsys.ENOTEMPTY, testFS.Rmdir("sub"))
+	require.EqualErrno(t, experimentalsys.ENOTDIR, testFS.Rmdir("animals.txt"))
+	require.EqualErrno(t, experimentalsys.EINVAL, testFS.Rmdir("."))
+
+	require.EqualErrno(t, 0, testFS.Unlink("sub/test.txt"))
+	require.EqualErrno(t, 0, testFS.Rmdir("sub"))
+	_, errno := testFS.Stat("sub")
+	require.EqualErrno(t, experimentalsys.ENOENT, errno)
+	_, errno = testFS.Stat("sub/test.txt")
+	require.EqualErrno(t, experimentalsys.ENOENT, errno)
+
+	t.Run("recreated directory hides lower entries", func(t *testing.T) {
+		require.EqualErrno(t, 0, testFS.Mkdir("sub", 0o755))
+
+		_, errno := testFS.Stat("sub/test.txt")
+		require.EqualErrno(t, experimentalsys.ENOENT, errno)
+
+		f, errno := testFS.OpenFile("sub", experimentalsys.O_RDONLY|experimentalsys.O_DIRECTORY, 0)
+		require.EqualErrno(t, 0, errno)
+		defer f.Close()
+		require.Equal(t, 0, len(requireReaddir(t, f, -1, true)))
+	})
+}
+
+func TestOverlayFS_Rename(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		testFS, _ := newTestOverlayFS(t)
+
+		require.EqualErrno(t, 0, testFS.Rename("animals.txt", "sub/animals.txt"))
+		_, errno := testFS.Stat("animals.txt")
+		require.EqualErrno(t, experimentalsys.ENOENT, errno)
+		st, errno := testFS.Stat("sub/animals.txt")
+		require.EqualErrno(t, 0, errno)
+		require.NotEqual(t, int64(0), st.Size)
+	})
+
+	t.Run("dir", func(t *testing.T) {
+		testFS, _ := newTestOverlayFS(t)
+
+		require.EqualErrno(t, 0, testFS.Rename("sub", "dir/sub"))
+		_, errno := testFS.Stat("sub")
+		require.EqualErrno(t, experimentalsys.ENOENT, errno)
+		requireContents(t, testFS, "dir/sub/test.txt", "greet sub dir\n")
+
+		// Recreating the source doesn't show what was moved.
+		require.EqualErrno(t, 0, testFS.Mkdir("sub", 0o755))
+		_, errno = testFS.Stat("sub/test.txt")
+		require.EqualErrno(t, experimentalsys.ENOENT, errno)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		testFS, _ := newTestOverlayFS(t)
+
+		require.EqualErrno(t, experimentalsys.ENOENT, testFS.Rename("missing", "dir2"))
+		require.EqualErrno(t, experimentalsys.EINVAL, testFS.Rename("dir", "dir/sub"))
+		require.EqualErrno(t, experimentalsys.ENOTDIR, testFS.Rename("dir", "animals.txt"))
+		require.EqualErrno(t, experimentalsys.EISDIR, testFS.Rename("animals.txt", "dir"))
+		require.EqualErrno(t, experimentalsys.ENOTEMPTY, testFS.Rename("dir", "sub"))
+	})
+}
+
+func TestOverlayFS_Link(t *testing.T) {
+	testFS, lower := newTestOverlayFS(t)
+
+	require.EqualErrno(t, 0, testFS.Link("animals.txt", "sub/animals.txt"))
+	st1, errno := testFS.Stat("animals.txt")
+	require.EqualErrno(t, 0, errno)
+	st2, errno := testFS.Stat("sub/animals.txt")
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, st1.Ino, st2.Ino)
+	_, errno = lower.Stat("sub/animals.txt")
+	require.EqualErrno(t, experimentalsys.ENOENT, errno)
+
+	require.EqualErrno(t, experimentalsys.EPERM, testFS.Link("sub", "sub2"))
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Link("animals.txt", "empty.txt"))
+}
+
+func TestOverlayFS_Symlink(t *testing.T) {
+	testFS, _ := newTestOverlayFS(t)
+
+	require.EqualErrno(t, experimentalsys.EPERM, testFS.Symlink("/animals.txt", "link"))
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Symlink("animals.txt", "sub"))
+}
+
+func TestOverlayFS_Readlink(t *testing.T) {
+	testFS, _ := newTestOverlayFS(t)
+	testReadlink(t, testFS, testFS)
+}
+
+func TestOverlayFS_Utimens(t *testing.T) {
+	testFS, lower := newTestOverlayFS(t)
+
+	lowerSt, errno := lower.Stat("animals.txt")
+	require.EqualErrno(t, 0, errno)
+
+	require.EqualErrno(t, 0, testFS.Utimens("animals.txt", 1, 2))
+	st, errno := testFS.Stat("animals.txt")
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, int64(1), st.Atim)
+	require.Equal(t, int64(2), st.Mtim)
+
+	st, errno = lower.Stat("animals.txt")
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, lowerSt.Mtim, st.Mtim)
+}
+
+func TestOverlayFS_Changes(t *testing.T) {
+	testFS, _ := newTestOverlayFS(t)
+
+	changes, errno := testFS.Changes()
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, 0, len(changes))
+
+	requireWriteFile(t, testFS, "sub/test.txt", "changed")
+	requireWriteFile(t, testFS, "new.txt", "new")
+	require.EqualErrno(t, 0, testFS.Unlink("animals.txt"))
+	require.EqualErrno(t, 0, testFS.Rmdir("emptydir"))
+	require.EqualErrno(t, 0, testFS.Mkdir("emptydir", 0o755))
+
+	changes, errno = testFS.Changes()
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, []Change{
+		{Path: "animals.txt", Kind: ChangeDeleted},
+		{Path: "emptydir", Kind: ChangeModified},
+		{Path: "new.txt", Kind: ChangeAdded},
+		{Path: "sub", Kind: ChangeModified},
+		{Path: "sub/test.txt", Kind: ChangeModified},
+	}, changes)
+}
+
+func TestChangeKind_String(t *testing.T) {
+	require.Equal(t, "added", ChangeAdded.String())
+	require.Equal(t, "modified", ChangeModified.String())
+	require.Equal(t, "deleted", ChangeDeleted.String())
+	require.Equal(t, "unknown", ChangeKind(0).String())
+}
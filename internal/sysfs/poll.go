@@ -8,10 +8,16 @@ import (
 
 // poll implements `Poll` as documented on sys.File via a file descriptor.
 func poll(fd uintptr, flag sys.Pflag, timeoutMillis int32) (ready bool, errno sys.Errno) {
-	if flag != sys.POLLIN {
+	var events int16
+	switch flag {
+	case sys.POLLIN:
+		events = _POLLIN
+	case sys.POLLOUT:
+		events = _POLLOUT
+	default:
 		return false, sys.ENOTSUP
 	}
-	fds := []pollFd{newPollFd(fd, _POLLIN, 0)}
+	fds := []pollFd{newPollFd(fd, events, 0)}
 	count, errno := _poll(fds, timeoutMillis)
 	return count > 0, errno
 }
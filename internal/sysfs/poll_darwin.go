@@ -24,6 +24,9 @@ func newPollFd(fd uintptr, events, revents int16) pollFd {
 // _POLLIN subscribes a notification when any readable data is available.
 const _POLLIN = 0x0001
 
+// _POLLOUT subscribes a notification when data can be written without blocking.
+const _POLLOUT = 0x0004
+
 // _poll implements poll on Darwin via the corresponding libc function.
 func _poll(fds []pollFd, timeoutMillis int32) (n int, errno sys.Errno) {
 	var fdptr *pollFd
@@ -26,12 +26,15 @@ func newPollFd(fd uintptr, events, revents int16) pollFd {
 // _POLLIN subscribes a notification when any readable data is available.
 const _POLLIN = 0x0001
 
+// _POLLOUT subscribes a notification when data can be written without blocking.
+const _POLLOUT = 0x0004
+
 // _poll implements poll on Linux via ppoll.
 func _poll(fds []pollFd, timeoutMillis int32) (n int, errno sys.Errno) {
-	var ts syscall.Timespec
-	if timeoutMillis >= 0 {
-		ts = syscall.NsecToTimespec(int64(time.Duration(timeoutMillis) * time.Millisecond))
+	if timeoutMillis < 0 {
+		return ppoll(fds, nil) // block indefinitely
 	}
+	ts := syscall.NsecToTimespec(int64(time.Duration(timeoutMillis) * time.Millisecond))
 	return ppoll(fds, &ts)
 }
 
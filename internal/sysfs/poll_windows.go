@@ -20,6 +20,10 @@ const (
 	_POLLRDBAND = 0x0200
 	// _POLLIN subscribes a notification when any readable data is available.
 	_POLLIN = (_POLLRDNORM | _POLLRDBAND)
+	// _POLLWRNORM subscribes to normal data for write.
+	_POLLWRNORM = 0x0010
+	// _POLLOUT subscribes a notification when data can be written without blocking.
+	_POLLOUT = _POLLWRNORM
 )
 
 // pollFd is the struct to query for file descriptor events using poll.
@@ -42,8 +46,8 @@ const pollInterval = 100 * time.Millisecond
 
 // _poll implements poll on Windows, for a subset of cases.
 //
-// fds may contain any number of file handles, but regular files and pipes are only processed for _POLLIN.
-// Stdin is a pipe, thus it is checked for readiness when present. Pipes are checked using PeekNamedPipe.
+// fds may contain any number of file handles. Stdin is a pipe, thus it is checked for readiness when
+// present. Pipes are checked for _POLLIN using PeekNamedPipe, and are always ready for _POLLOUT.
 // Regular files always immediately reported as ready, regardless their actual state and timeouts.
 //
 // If n==0 it will wait for the given timeout duration, but it will return sys.ENOSYS if timeout is nil,
@@ -130,6 +134,10 @@ func peekAll(pipes, sockets []pollFd) (npipes, nsockets int, errno sys.Errno) {
 
 func peekPipes(fds []pollFd) (n int, errno sys.Errno) {
 	for _, fd := range fds {
+		if fd.events&_POLLIN == 0 {
+			n++ // writes to a pipe are not polled
+			continue
+		}
 		bytes, errno := peekNamedPipe(syscall.Handle(fd.fd))
 		if errno != 0 {
 			return -1, sys.UnwrapOSError(errno)
@@ -0,0 +1,226 @@
+package sysfs
+
+import (
+	"io"
+	"io/fs"
+	"sync"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+)
+
+type QuotaFS struct {
+	experimentalsys.FS
+
+	// MaxBytesWritten is the maximum count of bytes written to all files, or
+	// zero for no limit. Exceeding it returns sys.ENOSPC.
+	MaxBytesWritten int64
+
+	// MaxFileSize is the maximum size of any file written, or zero for no
+	// limit. Exceeding it returns sys.EFBIG.
+	MaxFileSize int64
+
+	// MaxFilesCreated is the maximum count of files, directories and links
+	// created, or zero for no limit. Exceeding it returns sys.EDQUOT.
+	MaxFilesCreated int64
+
+	// mux guards the usage below, which is shared by all files opened.
+	mux          sync.Mutex
+	bytesWritten int64
+	filesCreated int64
+}
+
+// reserveBytes returns the count of the n bytes which can be written, or
+// sys.ENOSPC if none can. The caller must call releaseBytes with the count
+// not actually written.
+func (q *QuotaFS) reserveBytes(n int64) (int64, experimentalsys.Errno) {
+	if q.MaxBytesWritten <= 0 || n == 0 {
+		return n, 0
+	}
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	if remaining := q.MaxBytesWritten - q.bytesWritten; remaining <= 0 {
+		return 0, experimentalsys.ENOSPC
+	} else if n > remaining {
+		n = remaining
+	}
+	q.bytesWritten += n
+	return n, 0
+}
+
+// releaseBytes gives back bytes reserved by reserveBytes, but not written.
+func (q *QuotaFS) releaseBytes(n int64) {
+	if q.MaxBytesWritten <= 0 || n == 0 {
+		return
+	}
+	q.mux.Lock()
+	q.bytesWritten -= n
+	q.mux.Unlock()
+}
+
+// create calls fn if another file can be created, or returns sys.EDQUOT.
+func (q *QuotaFS) create(fn func() experimentalsys.Errno) experimentalsys.Errno {
+	if q.MaxFilesCreated <= 0 {
+		return fn()
+	}
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	if q.filesCreated >= q.MaxFilesCreated {
+		return experimentalsys.EDQUOT
+	}
+	errno := fn()
+	if errno == 0 {
+		q.filesCreated++
+	}
+	return errno
+}
+
+// OpenFile implements the same method as documented on sys.FS
+func (q *QuotaFS) OpenFile(path string, flag experimentalsys.Oflag, perm fs.FileMode) (f experimentalsys.File, errno experimentalsys.Errno) {
+	if flag&experimentalsys.O_CREAT == 0 || q.MaxFilesCreated <= 0 {
+		f, errno = q.FS.OpenFile(path, flag, perm)
+	} else if _, errno = q.FS.Stat(path); errno == 0 {
+		f, errno = q.FS.OpenFile(path, flag, perm) // already exists
+	} else if errno == experimentalsys.ENOENT {
+		errno = q.create(func() (errno experimentalsys.Errno) {
+			f, errno = q.FS.OpenFile(path, flag, perm)
+			return
+		})
+	}
+	if errno != 0 {
+		return nil, errno
+	}
+	return &quotaFile{File: f, fs: q, append: flag&experimentalsys.O_APPEND != 0}, 0
+}
+
+// Mkdir implements the same method as documented on sys.FS
+func (q *QuotaFS) Mkdir(path string, perm fs.FileMode) experimentalsys.Errno {
+	return q.create(func() experimentalsys.Errno {
+		return q.FS.Mkdir(path, perm)
+	})
+}
+
+// Link implements the same method as documented on sys.FS
+func (q *QuotaFS) Link(oldPath, newPath string) experimentalsys.Errno {
+	return q.create(func() experimentalsys.Errno {
+		return q.FS.Link(oldPath, newPath)
+	})
+}
+
+// Symlink implements the same method as documented on sys.FS
+func (q *QuotaFS) Symlink(oldPath, linkName string) experimentalsys.Errno {
+	return q.create(func() experimentalsys.Errno {
+		return q.FS.Symlink(oldPath, linkName)
+	})
+}
+
+// compile-time check to ensure quotaFile implements api.File.
+var _ experimentalsys.File = (*quotaFile)(nil)
+
+// quotaFile enforces the limits of a QuotaFS on writes to a file it opened.
+type quotaFile struct {
+	experimentalsys.File
+
+	fs     *QuotaFS
+	append bool
+}
+
+// Write implements the same method as documented on sys.File
+func (f *quotaFile) Write(buf []byte) (int, experimentalsys.Errno) {
+	if f.fs.MaxFileSize <= 0 {
+		return f.write(buf, func(buf []byte) (int, experimentalsys.Errno) {
+			return f.File.Write(buf)
+		})
+	}
+
+	// Find the offset to write at, to limit the size of the file.
+	var offset int64
+	var errno experimentalsys.Errno
+	if f.append {
+		offset, errno = f.size()
+	} else {
+		offset, errno = f.File.Seek(0, io.SeekCurrent)
+	}
+	if errno != 0 {
+		return 0, errno
+	} else if buf, errno = f.limitSize(buf, offset); errno != 0 {
+		return 0, errno
+	}
+	return f.write(buf, func(buf []byte) (int, experimentalsys.Errno) {
+		return f.File.Write(buf)
+	})
+}
+
+// Pwrite implements the same method as documented on sys.File
+func (f *quotaFile) Pwrite(buf []byte, off int64) (int, experimentalsys.Errno) {
+	buf, errno := f.limitSize(buf, off)
+	if errno != 0 {
+		return 0, errno
+	}
+	return f.write(buf, func(buf []byte) (int, experimentalsys.Errno) {
+		return f.File.Pwrite(buf, off)
+	})
+}
+
+// Truncate implements the same method as documented on sys.File
+//
+// Note: Growing a file counts as writing the zeros it is filled with.
+func (f *quotaFile) Truncate(size int64) experimentalsys.Errno {
+	if f.fs.MaxFileSize > 0 && size > f.fs.MaxFileSize {
+		return experimentalsys.EFBIG
+	} else if f.fs.MaxBytesWritten <= 0 {
+		return f.File.Truncate(size)
+	}
+
+	oldSize, errno := f.size()
+	if errno != 0 {
+		return errno
+	} else if size <= oldSize {
+		return f.File.Truncate(size)
+	}
+	grow := size - oldSize
+	if n, errno := f.fs.reserveBytes(grow); errno != 0 {
+		return errno
+	} else if n < grow {
+		f.fs.releaseBytes(n)
+		return experimentalsys.ENOSPC
+	}
+	if errno = f.File.Truncate(size); errno != 0 {
+		f.fs.releaseBytes(grow)
+	}
+	return errno
+}
+
+// size returns the current size of the file.
+func (f *quotaFile) size() (int64, experimentalsys.Errno) {
+	st, errno := f.File.Stat()
+	return st.Size, errno
+}
+
+// limitSize returns the part of buf which can be written at the offset
+// without exceeding QuotaFS.MaxFileSize, or sys.EFBIG if none can.
+func (f *quotaFile) limitSize(buf []byte, offset int64) ([]byte, experimentalsys.Errno) {
+	if f.fs.MaxFileSize <= 0 || len(buf) == 0 {
+		return buf, 0
+	}
+	if remaining := f.fs.MaxFileSize - offset; remaining <= 0 {
+		return nil, experimentalsys.EFBIG
+	} else if int64(len(buf)) > remaining {
+		buf = buf[:remaining]
+	}
+	return buf, 0
+}
+
+// write calls fn with the part of buf which can be written without
+// exceeding QuotaFS.MaxBytesWritten. Like POSIX, the write is short when
+// only part of buf can be written.
+func (f *quotaFile) write(buf []byte, fn func([]byte) (int, experimentalsys.Errno)) (int, experimentalsys.Errno) {
+	reserved, errno := f.fs.reserveBytes(int64(len(buf)))
+	if errno != 0 {
+		return 0, errno
+	}
+	n, errno := fn(buf[:reserved])
+	f.fs.releaseBytes(reserved - int64(n))
+	return n, errno
+}
@@ -0,0 +1,118 @@
+package sysfs
+
+import (
+	"io"
+	"testing"
+
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestQuotaFS_MaxBytesWritten(t *testing.T) {
+	testFS := &QuotaFS{FS: MemFS(), MaxBytesWritten: 10}
+
+	f, errno := testFS.OpenFile("file", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o600)
+	require.EqualErrno(t, 0, errno)
+	defer f.Close()
+
+	requireWrite(t, f, []byte("wazero"))
+
+	// Only part of the write fits, so it is short.
+	n, errno := f.Pwrite([]byte("wazero"), 6)
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, 4, n)
+
+	_, errno = f.Write([]byte("!"))
+	require.EqualErrno(t, experimentalsys.ENOSPC, errno)
+
+	// The quota is shared by all files, and isn't given back on delete.
+	require.EqualErrno(t, 0, testFS.Unlink("file"))
+	f2, errno := testFS.OpenFile("file2", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o600)
+	require.EqualErrno(t, 0, errno)
+	defer f2.Close()
+	_, errno = f2.Write([]byte("!"))
+	require.EqualErrno(t, experimentalsys.ENOSPC, errno)
+
+	t.Run("empty write", func(t *testing.T) {
+		n, errno := f2.Write(nil)
+		require.EqualErrno(t, 0, errno)
+		require.Zero(t, n)
+	})
+
+	t.Run("truncate grows", func(t *testing.T) {
+		require.EqualErrno(t, experimentalsys.ENOSPC, f2.Truncate(1))
+	})
+
+	t.Run("truncate shrinks", func(t *testing.T) {
+		require.EqualErrno(t, 0, f.Truncate(0))
+	})
+}
+
+func TestQuotaFS_MaxFileSize(t *testing.T) {
+	testFS := &QuotaFS{FS: MemFS(), MaxFileSize: 10}
+
+	f, errno := testFS.OpenFile("file", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o600)
+	require.EqualErrno(t, 0, errno)
+	defer f.Close()
+
+	requireWrite(t, f, []byte("wazero"))
+	n, errno := f.Write([]byte("wazero"))
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, 4, n)
+	_, errno = f.Write([]byte("!"))
+	require.EqualErrno(t, experimentalsys.EFBIG, errno)
+
+	// Overwriting within the limit is allowed.
+	require.Equal(t, int64(0), requireSeek(t, f, 0, io.SeekStart))
+	requireWrite(t, f, []byte("WAZERO"))
+	requirePwrite(t, f, []byte("!"), 9)
+
+	_, errno = f.Pwrite([]byte("!"), 10)
+	require.EqualErrno(t, experimentalsys.EFBIG, errno)
+	require.EqualErrno(t, experimentalsys.EFBIG, f.Truncate(11))
+	require.EqualErrno(t, 0, f.Truncate(5))
+
+	t.Run("O_APPEND", func(t *testing.T) {
+		f, errno := testFS.OpenFile("file", experimentalsys.O_WRONLY|experimentalsys.O_APPEND, 0)
+		require.EqualErrno(t, 0, errno)
+		defer f.Close()
+
+		n, errno := f.Write([]byte("wazero"))
+		require.EqualErrno(t, 0, errno)
+		require.Equal(t, 5, n)
+		_, errno = f.Write([]byte("!"))
+		require.EqualErrno(t, experimentalsys.EFBIG, errno)
+	})
+
+	requireContents(t, testFS, "file", "WAZERwazer")
+}
+
+func TestQuotaFS_MaxFilesCreated(t *testing.T) {
+	testFS := &QuotaFS{FS: MemFS(), MaxFilesCreated: 4}
+
+	requireWriteFile(t, testFS, "file", "")
+	require.EqualErrno(t, 0, testFS.Mkdir("dir", 0o755))
+	require.EqualErrno(t, 0, testFS.Symlink("file", "symlink"))
+
+	// Opening existing files is allowed, even with O_CREAT.
+	requireWriteFile(t, testFS, "file", "wazero")
+
+	// Failures don't count.
+	require.EqualErrno(t, experimentalsys.EEXIST, testFS.Mkdir("dir", 0o755))
+	require.EqualErrno(t, 0, testFS.Link("file", "link"))
+
+	_, errno := testFS.OpenFile("file2", experimentalsys.O_RDWR|experimentalsys.O_CREAT, 0o600)
+	require.EqualErrno(t, experimentalsys.EDQUOT, errno)
+	require.EqualErrno(t, experimentalsys.EDQUOT, testFS.Mkdir("dir2", 0o755))
+	require.EqualErrno(t, experimentalsys.EDQUOT, testFS.Symlink("file", "symlink2"))
+	require.EqualErrno(t, experimentalsys.EDQUOT, testFS.Link("file", "link2"))
+}
+
+func TestQuotaFS_noLimits(t *testing.T) {
+	testFS := &QuotaFS{FS: newTestMemFS(t)}
+
+	testOpen_Read(t, testFS, true, true)
+
+	requireWriteFile(t, testFS, "file", "wazero")
+	requireContents(t, testFS, "file", "wazero")
+}
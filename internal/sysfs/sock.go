@@ -1,7 +1,9 @@
 package sysfs
 
 import (
+	"context"
 	"net"
+	"net/netip"
 	"os"
 
 	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
@@ -14,6 +16,17 @@ func NewTCPListenerFile(tl *net.TCPListener) socketapi.TCPSock {
 	return newTCPListenerFile(tl)
 }
 
+// NewTCPSocketFile creates a socketapi.TCPSocket of the IPv4 address family,
+// or IPv6 if ipv6 is true.
+func NewTCPSocketFile(ipv6 bool) socketapi.TCPSocket {
+	return &tcpSocketFile{ipv6: ipv6}
+}
+
+// NewUDPConnFile creates a socketapi.UDPConn for a given *net.UDPConn.
+func NewUDPConnFile(uc *net.UDPConn) socketapi.UDPConn {
+	return &udpConnFile{uc: uc}
+}
+
 // baseSockFile implements base behavior for all TCPSock, TCPConn files,
 // regardless the platform.
 type baseSockFile struct {
@@ -36,7 +49,10 @@ func (f *baseSockFile) Stat() (fs sys.Stat_t, errno experimentalsys.Errno) {
 	return
 }
 
-var _ socketapi.TCPSock = (*tcpListenerFile)(nil)
+var (
+	_ socketapi.TCPSock   = (*tcpListenerFile)(nil)
+	_ socketapi.Addresser = (*tcpListenerFile)(nil)
+)
 
 type tcpListenerFile struct {
 	baseSockFile
@@ -70,6 +86,16 @@ func (f *tcpListenerFile) Addr() *net.TCPAddr {
 	return f.tl.Addr().(*net.TCPAddr)
 }
 
+// LocalAddr implements the same method as documented on socketapi.Addresser
+func (f *tcpListenerFile) LocalAddr() netip.AddrPort {
+	return f.Addr().AddrPort()
+}
+
+// RemoteAddr implements the same method as documented on socketapi.Addresser
+func (f *tcpListenerFile) RemoteAddr() (netip.AddrPort, experimentalsys.Errno) {
+	return netip.AddrPort{}, experimentalsys.ENOTCONN
+}
+
 // IsNonblock implements the same method as documented on experimentalsys.PollableFile
 func (f *tcpListenerFile) IsNonblock() bool {
 	return f.nonblock
@@ -77,10 +103,13 @@ func (f *tcpListenerFile) IsNonblock() bool {
 
 // Poll implements the same method as documented on experimentalsys.Pollable
 func (f *tcpListenerFile) Poll(flag experimentalsys.Pflag, timeoutMillis int32) (ready bool, errno experimentalsys.Errno) {
-	return false, experimentalsys.ENOSYS
+	return _pollSock(f.tl, flag, timeoutMillis)
 }
 
-var _ socketapi.TCPConn = (*tcpConnFile)(nil)
+var (
+	_ socketapi.TCPConn   = (*tcpConnFile)(nil)
+	_ socketapi.Addresser = (*tcpConnFile)(nil)
+)
 
 type tcpConnFile struct {
 	baseSockFile
@@ -98,6 +127,16 @@ func newTcpConn(tc *net.TCPConn) socketapi.TCPConn {
 	return &tcpConnFile{tc: tc}
 }
 
+// LocalAddr implements the same method as documented on socketapi.Addresser
+func (f *tcpConnFile) LocalAddr() netip.AddrPort {
+	return f.tc.LocalAddr().(*net.TCPAddr).AddrPort()
+}
+
+// RemoteAddr implements the same method as documented on socketapi.Addresser
+func (f *tcpConnFile) RemoteAddr() (netip.AddrPort, experimentalsys.Errno) {
+	return f.tc.RemoteAddr().(*net.TCPAddr).AddrPort(), 0
+}
+
 // Read implements the same method as documented on experimentalsys.File
 func (f *tcpConnFile) Read(buf []byte) (n int, errno experimentalsys.Errno) {
 	if len(buf) == 0 {
@@ -182,5 +221,188 @@ func (f *tcpConnFile) IsNonblock() bool {
 
 // Poll implements the same method as documented on experimentalsys.Pollable
 func (f *tcpConnFile) Poll(flag experimentalsys.Pflag, timeoutMillis int32) (ready bool, errno experimentalsys.Errno) {
-	return false, experimentalsys.ENOSYS
+	return _pollSock(f.tc, flag, timeoutMillis)
+}
+
+var (
+	_ socketapi.TCPSocket = (*tcpSocketFile)(nil)
+	_ socketapi.Addresser = (*tcpSocketFile)(nil)
+)
+
+// tcpSocketFile is a TCP socket which isn't connected yet, so it has no
+// underlying file descriptor.
+type tcpSocketFile struct {
+	baseSockFile
+
+	ipv6     bool
+	nonblock bool
+}
+
+// Connect implements the same method as documented on socketapi.TCPSocket
+//
+// Note: This blocks until connected or ctx is done, even if the socket is
+// non-blocking.
+func (f *tcpSocketFile) Connect(ctx context.Context, addr netip.AddrPort) (socketapi.TCPConn, experimentalsys.Errno) {
+	network := "tcp4"
+	if f.ipv6 {
+		network = "tcp6"
+		if addr.Addr().Is4() {
+			return nil, experimentalsys.EAFNOSUPPORT
+		}
+	} else if !addr.Addr().Unmap().Is4() {
+		return nil, experimentalsys.EAFNOSUPPORT
+	}
+
+	var d net.Dialer
+	c, err := d.DialContext(ctx, network, addr.String())
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, experimentalsys.EINTR // canceled or timed out by the caller.
+		}
+		return nil, unwrapNetError(err)
+	}
+	tc := c.(*net.TCPConn)
+	conn := newTcpConn(tc)
+	if f.nonblock {
+		if errno := conn.(experimentalsys.PollableFile).SetNonblock(true); errno != 0 {
+			_ = tc.Close()
+			return nil, errno
+		}
+	}
+	return conn, 0
+}
+
+// SetNonblock implements the same method as documented on experimentalsys.PollableFile
+func (f *tcpSocketFile) SetNonblock(enabled bool) experimentalsys.Errno {
+	f.nonblock = enabled
+	return 0
+}
+
+// IsNonblock implements the same method as documented on experimentalsys.PollableFile
+func (f *tcpSocketFile) IsNonblock() bool {
+	return f.nonblock
+}
+
+// LocalAddr implements the same method as documented on socketapi.Addresser
+func (f *tcpSocketFile) LocalAddr() netip.AddrPort {
+	if f.ipv6 {
+		return netip.AddrPortFrom(netip.IPv6Unspecified(), 0)
+	}
+	return netip.AddrPortFrom(netip.IPv4Unspecified(), 0)
+}
+
+// RemoteAddr implements the same method as documented on socketapi.Addresser
+func (f *tcpSocketFile) RemoteAddr() (netip.AddrPort, experimentalsys.Errno) {
+	return netip.AddrPort{}, experimentalsys.ENOTCONN
+}
+
+// Poll implements the same method as documented on experimentalsys.Pollable
+//
+// Note: There is nothing to poll until the socket is connected.
+func (f *tcpSocketFile) Poll(experimentalsys.Pflag, int32) (ready bool, errno experimentalsys.Errno) {
+	return false, experimentalsys.ENOTSUP
+}
+
+// Close implements the same method as documented on experimentalsys.File
+func (f *tcpSocketFile) Close() experimentalsys.Errno {
+	return 0
+}
+
+var (
+	_ socketapi.UDPConn   = (*udpConnFile)(nil)
+	_ socketapi.Addresser = (*udpConnFile)(nil)
+)
+
+type udpConnFile struct {
+	baseSockFile
+
+	uc *net.UDPConn
+
+	// nonblock is true when the socket is flagged as non-blocking. This
+	// ensures that RecvFrom returns experimentalsys.EAGAIN without blocking
+	// the caller.
+	nonblock bool
+	// closed is true when closed was called. This ensures proper experimentalsys.EBADF
+	closed bool
+}
+
+// Addr is exposed for testing.
+func (f *udpConnFile) Addr() *net.UDPAddr {
+	return f.uc.LocalAddr().(*net.UDPAddr)
+}
+
+// LocalAddr implements the same method as documented on socketapi.Addresser
+func (f *udpConnFile) LocalAddr() netip.AddrPort {
+	return f.Addr().AddrPort()
+}
+
+// RemoteAddr implements the same method as documented on socketapi.Addresser
+//
+// Note: Datagram sockets are never connected, as there is no sock_connect
+// for them.
+func (f *udpConnFile) RemoteAddr() (netip.AddrPort, experimentalsys.Errno) {
+	return netip.AddrPort{}, experimentalsys.ENOTCONN
+}
+
+// SendTo implements the same method as documented on socketapi.UDPConn
+func (f *udpConnFile) SendTo(p []byte, addr netip.AddrPort) (n int, errno experimentalsys.Errno) {
+	if f.closed {
+		return 0, experimentalsys.EBADF
+	}
+	n, err := f.uc.WriteToUDPAddrPort(p, addr)
+	return n, unwrapNetError(err)
+}
+
+// RecvFrom implements the same method as documented on socketapi.UDPConn
+func (f *udpConnFile) RecvFrom(p []byte) (n int, addr netip.AddrPort, errno experimentalsys.Errno) {
+	if f.closed {
+		return 0, addr, experimentalsys.EBADF
+	}
+	// Ensure we have an incoming datagram, otherwise return immediately.
+	if f.nonblock {
+		if ready, errno := _pollSock(f.uc, experimentalsys.POLLIN, 0); errno != 0 {
+			return 0, addr, errno
+		} else if !ready {
+			return 0, addr, experimentalsys.EAGAIN
+		}
+	}
+	n, addr, err := f.uc.ReadFromUDPAddrPort(p)
+	return n, addr, unwrapNetError(err)
+}
+
+// Poll implements the same method as documented on experimentalsys.Pollable
+func (f *udpConnFile) Poll(flag experimentalsys.Pflag, timeoutMillis int32) (ready bool, errno experimentalsys.Errno) {
+	return _pollSock(f.uc, flag, timeoutMillis)
+}
+
+// SetNonblock implements the same method as documented on experimentalsys.PollableFile
+//
+// Note: The underlying socket is always non-blocking, as it is managed by the
+// Go runtime, so this only affects RecvFrom.
+func (f *udpConnFile) SetNonblock(enabled bool) experimentalsys.Errno {
+	f.nonblock = enabled
+	return 0
+}
+
+// IsNonblock implements the same method as documented on experimentalsys.PollableFile
+func (f *udpConnFile) IsNonblock() bool {
+	return f.nonblock
+}
+
+// Close implements the same method as documented on experimentalsys.File
+func (f *udpConnFile) Close() experimentalsys.Errno {
+	if f.closed {
+		return 0
+	}
+	f.closed = true
+	return experimentalsys.UnwrapOSError(f.uc.Close())
+}
+
+// unwrapNetError is like experimentalsys.UnwrapOSError, except it also
+// unwraps a *net.OpError.
+func unwrapNetError(err error) experimentalsys.Errno {
+	if oe, ok := err.(*net.OpError); ok {
+		err = oe.Err
+	}
+	return experimentalsys.UnwrapOSError(err)
 }
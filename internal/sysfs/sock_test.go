@@ -1,6 +1,7 @@
 package sysfs
 
 import (
+	"context"
 	"net"
 	"testing"
 	"time"
@@ -178,3 +179,126 @@ func TestTcpConnFile_SetNonblock(t *testing.T) {
 	require.EqualErrno(t, 0, errno)
 	require.True(t, file.IsNonblock())
 }
+
+func TestTcpFile_Poll(t *testing.T) {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listen.Close()
+
+	lf := newTCPListenerFile(listen.(*net.TCPListener))
+	lp := lf.(sys.Pollable)
+	timeout := int32(0) // return immediately
+
+	// Nothing to accept yet.
+	ready, errno := lp.Poll(sys.POLLIN, timeout)
+	require.EqualErrno(t, 0, errno)
+	require.False(t, ready)
+
+	tcpAddr, err := net.ResolveTCPAddr("tcp", listen.Addr().String())
+	require.NoError(t, err)
+	tcp, err := net.DialTCP("tcp", nil, tcpAddr)
+	require.NoError(t, err)
+	defer tcp.Close() //nolint
+
+	// Block until the connection can be accepted.
+	ready, errno = lp.Poll(sys.POLLIN, -1)
+	require.EqualErrno(t, 0, errno)
+	require.True(t, ready)
+
+	conn, errno := lf.Accept()
+	require.EqualErrno(t, 0, errno)
+	defer conn.Close()
+
+	file := newTcpConn(tcp).(sys.Pollable)
+
+	// Nothing to read yet, but the send buffer is empty.
+	ready, errno = file.Poll(sys.POLLIN, timeout)
+	require.EqualErrno(t, 0, errno)
+	require.False(t, ready)
+	ready, errno = file.Poll(sys.POLLOUT, timeout)
+	require.EqualErrno(t, 0, errno)
+	require.True(t, ready)
+
+	_, errno = conn.Write([]byte("wazero"))
+	require.EqualErrno(t, 0, errno)
+
+	// Block until the data arrives.
+	ready, errno = file.Poll(sys.POLLIN, 1000)
+	require.EqualErrno(t, 0, errno)
+	require.True(t, ready)
+}
+
+func TestTcpSocketFile_Connect(t *testing.T) {
+	listen, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer listen.Close()
+	addr := listen.Addr().(*net.TCPAddr).AddrPort()
+
+	ctx := context.Background()
+
+	// The address must be of the family of the socket.
+	_, errno := NewTCPSocketFile(true).Connect(ctx, addr)
+	require.EqualErrno(t, sys.EAFNOSUPPORT, errno)
+
+	sock := NewTCPSocketFile(false)
+	errno = sock.(sys.PollableFile).SetNonblock(true)
+	require.EqualErrno(t, 0, errno)
+
+	conn, errno := sock.Connect(ctx, addr)
+	require.EqualErrno(t, 0, errno)
+	defer conn.Close()
+	require.True(t, conn.(sys.PollableFile).IsNonblock())
+
+	// Connecting stops when the context is done.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, errno = NewTCPSocketFile(false).Connect(canceled, addr)
+	require.EqualErrno(t, sys.EINTR, errno)
+
+	// Nothing listens anymore.
+	require.NoError(t, listen.Close())
+	_, errno = NewTCPSocketFile(false).Connect(ctx, addr)
+	require.EqualErrno(t, sys.ECONNREFUSED, errno)
+}
+
+func TestUdpConnFile(t *testing.T) {
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+
+	uc, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	file := NewUDPConnFile(uc)
+	defer file.Close()
+
+	// Nothing to receive yet.
+	errno := file.(sys.PollableFile).SetNonblock(true)
+	require.EqualErrno(t, 0, errno)
+	buf := make([]byte, 10)
+	_, _, errno = file.RecvFrom(buf)
+	require.EqualErrno(t, sys.EAGAIN, errno)
+
+	n, errno := file.SendTo([]byte("wazero"), peer.LocalAddr().(*net.UDPAddr).AddrPort())
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, 6, n)
+
+	n, from, err := peer.ReadFromUDPAddrPort(buf)
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(buf[:n]))
+
+	_, err = peer.WriteToUDPAddrPort([]byte("hello"), from)
+	require.NoError(t, err)
+
+	ready, errno := file.(sys.Pollable).Poll(sys.POLLIN, 1000)
+	require.EqualErrno(t, 0, errno)
+	require.True(t, ready)
+
+	n, addr, errno := file.RecvFrom(buf)
+	require.EqualErrno(t, 0, errno)
+	require.Equal(t, "hello", string(buf[:n]))
+	require.Equal(t, peer.LocalAddr().(*net.UDPAddr).AddrPort(), addr)
+
+	require.EqualErrno(t, 0, file.Close())
+	_, _, errno = file.RecvFrom(buf)
+	require.EqualErrno(t, sys.EBADF, errno)
+}
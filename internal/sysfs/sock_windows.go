@@ -70,11 +70,12 @@ func setNonblockSocket(fd uintptr, enabled bool) sys.Errno {
 }
 
 func _pollSock(conn syscall.Conn, flag sys.Pflag, timeoutMillis int32) (bool, sys.Errno) {
-	if flag != sys.POLLIN {
-		return false, sys.ENOTSUP
-	}
 	n, errno := syscallConnControl(conn, func(fd uintptr) (int, sys.Errno) {
-		return _poll([]pollFd{newPollFd(fd, _POLLIN, 0)}, timeoutMillis)
+		if ready, errno := poll(fd, flag, timeoutMillis); !ready || errno != 0 {
+			return -1, errno
+		} else {
+			return 0, errno
+		}
 	})
-	return n > 0, errno
+	return n >= 0, errno
 }
@@ -31,7 +31,7 @@ func encodeCode(c *wasm.Code) []byte {
 					localBlocks = append(leb128.EncodeUint32(runCount), localBlocks...)
 				}
 				lastValueType = vt
-				localBlocks = append(leb128.EncodeUint32(uint32(vt)), localBlocks...) // reuse the EncodeUint32 cache
+				localBlocks = append(EncodeValType(vt), localBlocks...)
 				localBlockCount++
 				runCount = 1
 			} else {
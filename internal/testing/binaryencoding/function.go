@@ -1,6 +1,7 @@
 package binaryencoding
 
 import (
+	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
@@ -9,7 +10,45 @@ import (
 // Note: Function types are encoded by the byte 0x60 followed by the respective vectors of parameter and result types.
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#function-types%E2%91%A4
 func EncodeFunctionType(t *wasm.FunctionType) []byte {
+	switch t.Kind {
+	case wasm.CompositeKindStruct:
+		data := append([]byte{0x5f}, leb128.EncodeUint32(uint32(len(t.Fields)))...)
+		for _, f := range t.Fields {
+			data = append(data, encodeFieldType(f)...)
+		}
+		return data
+	case wasm.CompositeKindArray:
+		return append([]byte{0x5e}, encodeFieldType(t.Fields[0])...)
+	}
 	// Only reached when "multi-value" is enabled because WebAssembly 1.0 (20191205) supports at most 1 result.
 	data := append([]byte{0x60}, EncodeValTypes(t.Params)...)
 	return append(data, EncodeValTypes(t.Results)...)
 }
+
+// encodeSubType encodes the type with its supertype and finality as defined by the GC proposal,
+// or as EncodeFunctionType if it has neither.
+func encodeSubType(t *wasm.FunctionType) []byte {
+	if !t.HasSuperType && !t.NonFinal {
+		return EncodeFunctionType(t)
+	}
+	data := []byte{0x4f} // sub final
+	if t.NonFinal {
+		data[0] = 0x50 // sub
+	}
+	if t.HasSuperType {
+		data = append(data, 1)
+		data = append(data, leb128.EncodeUint32(t.SuperType)...)
+	} else {
+		data = append(data, 0)
+	}
+	return append(data, EncodeFunctionType(t)...)
+}
+
+// encodeFieldType encodes the storage type and mutability of a struct field or an array element.
+func encodeFieldType(f wasm.FieldType) []byte {
+	var mutable byte
+	if f.Mutable {
+		mutable = 1
+	}
+	return append(EncodeValType(f.Type), mutable)
+}
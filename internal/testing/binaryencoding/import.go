@@ -19,20 +19,20 @@ func EncodeImport(i *wasm.Import) []byte {
 		data = append(data, leb128.EncodeUint32(i.DescFunc)...)
 	case wasm.ExternTypeTable:
 		data = append(data, wasm.RefTypeFuncref.Kind())
-		data = append(data, EncodeLimitsType(i.DescTable.Min, i.DescTable.Max, false)...)
+		data = append(data, EncodeLimitsType(i.DescTable.Min, i.DescTable.Max, false, false)...)
 	case wasm.ExternTypeMemory:
 		maxPtr := &i.DescMem.Max
 		if !i.DescMem.IsMaxEncoded {
 			maxPtr = nil
 		}
-		data = append(data, EncodeLimitsType(i.DescMem.Min, maxPtr, i.DescMem.IsShared)...)
+		data = append(data, EncodeLimitsType(i.DescMem.Min, maxPtr, i.DescMem.IsShared, i.DescMem.Is64)...)
 	case wasm.ExternTypeGlobal:
 		g := i.DescGlobal
 		var mutable byte
 		if g.Mutable {
 			mutable = 1
 		}
-		data = append(data, g.ValType.Kind(), mutable)
+		data = append(append(data, EncodeValType(g.ValType)...), mutable)
 	case wasm.ExternTypeTag:
 		data = append(data, 0x00) // attribute byte
 		data = append(data, leb128.EncodeUint32(i.DescTag)...)
@@ -17,12 +17,23 @@ func encodeSection(sectionID wasm.SectionID, contents []byte) []byte {
 // See EncodeFunctionType
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#type-section%E2%91%A0
 func encodeTypeSection(types []wasm.FunctionType) []byte {
-	contents := leb128.EncodeUint32(uint32(len(types)))
+	// The types of a rec group are counted as a single entry.
+	var count uint32
+	var contents []byte
 	for i := range types {
 		t := &types[i]
-		contents = append(contents, EncodeFunctionType(t)...)
+		if t.RecGroupSize > 0 {
+			if t.RecGroupPosition == 0 {
+				count++
+				contents = append(contents, 0x4e)
+				contents = append(contents, leb128.EncodeUint32(uint32(t.RecGroupSize))...)
+			}
+		} else {
+			count++
+		}
+		contents = append(contents, encodeSubType(t)...)
 	}
-	return encodeSection(wasm.SectionIDType, contents)
+	return encodeSection(wasm.SectionIDType, append(leb128.EncodeUint32(count), contents...))
 }
 
 // encodeImportSection encodes a wasm.SectionIDImport for the given imports in WebAssembly 1.0 (20191205) Binary
@@ -84,8 +95,11 @@ func encodeTableSection(tables []wasm.Table) []byte {
 //
 // See EncodeMemory
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-section%E2%91%A0
-func encodeMemorySection(memory *wasm.Memory) []byte {
-	contents := append([]byte{1}, EncodeMemory(memory)...)
+func encodeMemorySection(memories []wasm.Memory) []byte {
+	contents := leb128.EncodeUint32(uint32(len(memories)))
+	for i := range memories {
+		contents = append(contents, EncodeMemory(&memories[i])...)
+	}
 	return encodeSection(wasm.SectionIDMemory, contents)
 }
 
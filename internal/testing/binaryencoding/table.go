@@ -8,5 +8,5 @@ import (
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-table
 func EncodeTable(i *wasm.Table) []byte {
-	return append([]byte{i.Type.Kind()}, EncodeLimitsType(i.Min, i.Max, false)...)
+	return append(EncodeValType(i.Type), EncodeLimitsType(i.Min, i.Max, false, false)...)
 }
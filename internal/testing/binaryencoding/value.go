@@ -29,10 +29,26 @@ func EncodeValTypes(vt []wasm.ValueType) []byte {
 			return encoded
 		}
 	}
-	// Encode value types as their Kind bytes
 	count := leb128.EncodeUint32(uint32(len(vt)))
 	for _, v := range vt {
-		count = append(count, v.Kind())
+		count = append(count, EncodeValType(v)...)
 	}
 	return count
 }
+
+// EncodeValType encodes a value type, which is its Kind byte unless it is a non-nullable or a
+// concrete reference type.
+func EncodeValType(v wasm.ValueType) []byte {
+	switch {
+	case v.IsConcreteRef():
+		prefix := wasm.RefPrefixNonNullable
+		if v.IsNullable() {
+			prefix = wasm.RefPrefixNullable
+		}
+		return append([]byte{prefix}, leb128.EncodeInt64(int64(v.TypeIndex()))...)
+	case v.IsRef() && !v.IsNullable():
+		return []byte{wasm.RefPrefixNonNullable, v.Kind()}
+	default:
+		return []byte{v.Kind()}
+	}
+}
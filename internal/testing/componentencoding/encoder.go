@@ -0,0 +1,286 @@
+// Package componentencoding encodes components in the binary format, which
+// tests use to build components without external tools.
+package componentencoding
+
+import (
+	"fmt"
+
+	"github.com/tetratelabs/wazero/internal/component"
+	"github.com/tetratelabs/wazero/internal/leb128"
+)
+
+// preamble is the version and layer of a component.
+var preamble = []byte{0x0d, 0x00, 0x01, 0x00}
+
+// Encode encodes a component in the binary format, with each definition in
+// its own section.
+//
+// See https://github.com/WebAssembly/component-model/blob/main/design/mvp/Binary.md
+func Encode(c *component.Component) (bytes []byte) {
+	bytes = append(append([]byte{}, component.Magic...), preamble...)
+	for _, d := range c.Definitions {
+		switch d := d.(type) {
+		case *component.CoreModule:
+			bytes = append(bytes, encodeSection(component.SectionIDCoreModule, d.Binary)...)
+		case *component.Nested:
+			bytes = append(bytes, encodeSection(component.SectionIDComponent, Encode(d.Component))...)
+		case *component.Start:
+			data := leb128.EncodeUint32(d.Func)
+			data = append(data, encodeVec(len(d.Args), func(i int) []byte { return leb128.EncodeUint32(d.Args[i]) })...)
+			data = append(data, leb128.EncodeUint32(d.Results)...)
+			bytes = append(bytes, encodeSection(component.SectionIDStart, data)...)
+		default:
+			sectionID, item := encodeItem(d)
+			data := append(leb128.EncodeUint32(1), item...)
+			bytes = append(bytes, encodeSection(sectionID, data)...)
+		}
+	}
+	return
+}
+
+func encodeItem(d component.Definition) (component.SectionID, []byte) {
+	switch d := d.(type) {
+	case *component.CoreInstance:
+		if d.IsExports {
+			data := []byte{0x01}
+			return component.SectionIDCoreInstance, append(data, encodeVec(len(d.Exports), func(i int) []byte {
+				e := d.Exports[i]
+				ret := append(encodeName(e.Name), byte(e.Sort))
+				return append(ret, leb128.EncodeUint32(e.Index)...)
+			})...)
+		}
+		data := append([]byte{0x00}, leb128.EncodeUint32(d.Module)...)
+		return component.SectionIDCoreInstance, append(data, encodeVec(len(d.Args), func(i int) []byte {
+			a := d.Args[i]
+			ret := append(encodeName(a.Name), byte(component.SortCoreInstance))
+			return append(ret, leb128.EncodeUint32(a.Instance)...)
+		})...)
+	case *component.CoreType:
+		return component.SectionIDCoreType, d.Binary
+	case *component.Instance:
+		if d.IsExports {
+			return component.SectionIDInstance, append([]byte{0x01}, encodeVec(len(d.Exports), func(i int) []byte {
+				e := d.Exports[i]
+				return append(encodeExternName(e.Name), encodeSortIndex(e.Sort, e.Index)...)
+			})...)
+		}
+		data := append([]byte{0x00}, leb128.EncodeUint32(d.Component)...)
+		return component.SectionIDInstance, append(data, encodeVec(len(d.Args), func(i int) []byte {
+			a := d.Args[i]
+			return append(encodeName(a.Name), encodeSortIndex(a.Sort, a.Index)...)
+		})...)
+	case *component.Alias:
+		return component.SectionIDAlias, encodeAlias(d)
+	case *component.Type:
+		return component.SectionIDType, encodeTypeDef(d.Def)
+	case *component.Canon:
+		data := []byte{byte(d.Kind)}
+		switch d.Kind {
+		case component.CanonLift, component.CanonLower:
+			data = append(data, 0x00)
+			data = append(data, leb128.EncodeUint32(d.Func)...)
+			data = append(data, encodeCanonOptions(&d.Options)...)
+			if d.Kind == component.CanonLift {
+				data = append(data, leb128.EncodeUint32(d.Type)...)
+			}
+		default:
+			data = append(data, leb128.EncodeUint32(d.Type)...)
+		}
+		return component.SectionIDCanon, data
+	case *component.Import:
+		return component.SectionIDImport, append(encodeExternName(d.Name), encodeExternDesc(&d.Desc)...)
+	case *component.Export:
+		data := append(encodeExternName(d.Name), encodeSortIndex(d.Sort, d.Index)...)
+		if d.Desc == nil {
+			return component.SectionIDExport, append(data, 0x00)
+		}
+		data = append(data, 0x01)
+		return component.SectionIDExport, append(data, encodeExternDesc(d.Desc)...)
+	}
+	panic(fmt.Sprintf("unsupported definition: %T", d))
+}
+
+func encodeAlias(a *component.Alias) []byte {
+	data := append(encodeSort(a.Sort), byte(a.Kind))
+	if a.Kind == component.AliasKindOuter {
+		data = append(data, leb128.EncodeUint32(a.Count)...)
+		return append(data, leb128.EncodeUint32(a.Index)...)
+	}
+	data = append(data, leb128.EncodeUint32(a.Instance)...)
+	return append(data, encodeName(a.Name)...)
+}
+
+func encodeCanonOptions(o *component.CanonOptions) []byte {
+	var opts [][]byte
+	if o.StringEncoding != component.StringEncodingUTF8 {
+		opts = append(opts, []byte{byte(o.StringEncoding)})
+	}
+	if o.HasMemory {
+		opts = append(opts, append([]byte{0x03}, leb128.EncodeUint32(o.Memory)...))
+	}
+	if o.HasRealloc {
+		opts = append(opts, append([]byte{0x04}, leb128.EncodeUint32(o.Realloc)...))
+	}
+	if o.HasPostReturn {
+		opts = append(opts, append([]byte{0x05}, leb128.EncodeUint32(o.PostReturn)...))
+	}
+	return encodeVec(len(opts), func(i int) []byte { return opts[i] })
+}
+
+// primitiveValTypes maps the kind of primitive value types to their encoding.
+var primitiveValTypes = map[component.ValTypeKind]byte{
+	component.ValTypeKindBool:   0x7f,
+	component.ValTypeKindS8:     0x7e,
+	component.ValTypeKindU8:     0x7d,
+	component.ValTypeKindS16:    0x7c,
+	component.ValTypeKindU16:    0x7b,
+	component.ValTypeKindS32:    0x7a,
+	component.ValTypeKindU32:    0x79,
+	component.ValTypeKindS64:    0x78,
+	component.ValTypeKindU64:    0x77,
+	component.ValTypeKindF32:    0x76,
+	component.ValTypeKindF64:    0x75,
+	component.ValTypeKindChar:   0x74,
+	component.ValTypeKindString: 0x73,
+}
+
+func encodeTypeDef(def component.TypeDef) []byte {
+	switch def := def.(type) {
+	case *component.ValTypeDef:
+		if b, ok := primitiveValTypes[def.Kind]; ok {
+			return []byte{b}
+		}
+		switch def.Kind {
+		case component.ValTypeKindRecord:
+			return append([]byte{0x72}, encodeLabeledValTypes(def.Fields)...)
+		case component.ValTypeKindVariant:
+			return append([]byte{0x71}, encodeVec(len(def.Cases), func(i int) []byte {
+				c := def.Cases[i]
+				ret := append(encodeName(c.Name), encodeOptionalValType(c.Type)...)
+				return append(ret, 0x00)
+			})...)
+		case component.ValTypeKindList:
+			return append([]byte{0x70}, encodeValType(def.Elem)...)
+		case component.ValTypeKindTuple:
+			return append([]byte{0x6f}, encodeVec(len(def.Types), func(i int) []byte {
+				return encodeValType(def.Types[i])
+			})...)
+		case component.ValTypeKindFlags, component.ValTypeKindEnum:
+			b := byte(0x6e)
+			if def.Kind == component.ValTypeKindEnum {
+				b = 0x6d
+			}
+			return append([]byte{b}, encodeVec(len(def.Labels), func(i int) []byte {
+				return encodeName(def.Labels[i])
+			})...)
+		case component.ValTypeKindOption:
+			return append([]byte{0x6b}, encodeValType(def.Elem)...)
+		case component.ValTypeKindResult:
+			data := append([]byte{0x6a}, encodeOptionalValType(def.Ok)...)
+			return append(data, encodeOptionalValType(def.Err)...)
+		case component.ValTypeKindOwn:
+			return append([]byte{0x69}, leb128.EncodeUint32(def.Resource)...)
+		case component.ValTypeKindBorrow:
+			return append([]byte{0x68}, leb128.EncodeUint32(def.Resource)...)
+		}
+	case *component.FuncTypeDef:
+		data := append([]byte{0x40}, encodeLabeledValTypes(def.Params)...)
+		if len(def.Results) == 1 && def.Results[0].Name == "" {
+			data = append(data, 0x00)
+			return append(data, encodeValType(def.Results[0].Type)...)
+		}
+		data = append(data, 0x01)
+		return append(data, encodeLabeledValTypes(def.Results)...)
+	case *component.ComponentTypeDef:
+		return append([]byte{0x41}, encodeDecls(def.Decls)...)
+	case *component.InstanceTypeDef:
+		return append([]byte{0x42}, encodeDecls(def.Decls)...)
+	case *component.ResourceTypeDef:
+		if def.HasDtor {
+			return append([]byte{0x3f, 0x7f, 0x01}, leb128.EncodeUint32(def.Dtor)...)
+		}
+		return []byte{0x3f, 0x7f, 0x00}
+	}
+	panic(fmt.Sprintf("unsupported type definition: %#v", def))
+}
+
+func encodeDecls(decls []component.Decl) []byte {
+	return encodeVec(len(decls), func(i int) []byte {
+		d := &decls[i]
+		data := []byte{byte(d.Kind)}
+		switch d.Kind {
+		case component.DeclKindCoreType:
+			return append(data, d.CoreType.Binary...)
+		case component.DeclKindType:
+			return append(data, encodeTypeDef(d.Type)...)
+		case component.DeclKindAlias:
+			return append(data, encodeAlias(d.Alias)...)
+		}
+		data = append(data, encodeExternName(d.Name)...)
+		return append(data, encodeExternDesc(&d.Desc)...)
+	})
+}
+
+func encodeLabeledValTypes(ts []component.LabeledValType) []byte {
+	return encodeVec(len(ts), func(i int) []byte {
+		return append(encodeName(ts[i].Name), encodeValType(ts[i].Type)...)
+	})
+}
+
+func encodeValType(t component.ValTypeRef) []byte {
+	if t.Primitive != 0 {
+		return []byte{primitiveValTypes[t.Primitive]}
+	}
+	return leb128.EncodeInt64(int64(t.Index))
+}
+
+func encodeOptionalValType(t *component.ValTypeRef) []byte {
+	if t == nil {
+		return []byte{0x00}
+	}
+	return append([]byte{0x01}, encodeValType(*t)...)
+}
+
+func encodeExternDesc(d *component.ExternDesc) []byte {
+	switch d.Sort {
+	case component.SortCoreModule:
+		return append([]byte{0x00, byte(component.SortCoreModule)}, leb128.EncodeUint32(d.Index)...)
+	case component.SortType:
+		if d.SubResource {
+			return []byte{0x03, 0x01}
+		}
+		return append([]byte{0x03, 0x00}, leb128.EncodeUint32(d.Index)...)
+	}
+	return append([]byte{byte(d.Sort &^ component.SortComponentBase)}, leb128.EncodeUint32(d.Index)...)
+}
+
+func encodeSort(s component.Sort) []byte {
+	if s.IsCore() {
+		return []byte{0x00, byte(s)}
+	}
+	return []byte{byte(s &^ component.SortComponentBase)}
+}
+
+func encodeSortIndex(s component.Sort, index uint32) []byte {
+	return append(encodeSort(s), leb128.EncodeUint32(index)...)
+}
+
+func encodeExternName(name string) []byte {
+	return append([]byte{0x00}, encodeName(name)...)
+}
+
+func encodeName(name string) []byte {
+	return append(leb128.EncodeUint32(uint32(len(name))), name...)
+}
+
+func encodeVec(n int, encodeElem func(i int) []byte) []byte {
+	data := leb128.EncodeUint32(uint32(n))
+	for i := 0; i < n; i++ {
+		data = append(data, encodeElem(i)...)
+	}
+	return data
+}
+
+func encodeSection(sectionID component.SectionID, data []byte) []byte {
+	return append(append([]byte{sectionID}, leb128.EncodeUint32(uint32(len(data)))...), data...)
+}
@@ -0,0 +1,282 @@
+// Package threads spawns threads of a module instance, for host modules such
+// as wasi-threads and WASIX which share the same semantics: each thread runs
+// on its own goroutine, in a new instance of the module sharing its memory,
+// starting with the function named StartName.
+package threads
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tetratelabs/wazero/experimental"
+	experimentalsys "github.com/tetratelabs/wazero/experimental/sys"
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// StartName is the name of the function the guest module exports for the
+// Spawner to call on each spawned thread, with its thread ID and start
+// argument.
+const StartName = "wasi_thread_start"
+
+// maxThreadID is the maximum thread ID, as the upper bits are reserved.
+//
+// See https://github.com/WebAssembly/wasi-threads#design-choice-thread-ids
+const maxThreadID = 0x1fffffff
+
+// Spawner tracks the threadGroup of each module which spawned threads, or is
+// a spawned thread.
+type Spawner struct {
+	mux    sync.Mutex
+	groups map[*wasm.ModuleInstance]*threadGroup
+}
+
+// NewSpawner returns a new Spawner.
+func NewSpawner() *Spawner {
+	return &Spawner{groups: map[*wasm.ModuleInstance]*threadGroup{}}
+}
+
+// threadGroup is a module and the threads it spawned, directly or not.
+type threadGroup struct {
+	mux     sync.Mutex
+	main    *wasm.ModuleInstance
+	threads map[*wasm.ModuleInstance]*thread
+	ids     map[uint32]*thread
+	// closed is true once any member closed the others.
+	closed bool
+	// lastID is the last thread ID assigned.
+	lastID uint32
+}
+
+// thread is a spawned thread.
+type thread struct {
+	id uint32
+	// done is closed when the thread leaves its group.
+	done chan struct{}
+}
+
+// Spawn spawns a thread of the caller, running StartName with startArg, and
+// returns its thread ID, which is positive.
+//
+// # Errors
+//
+// A zero sys.Errno is success. The below are expected otherwise:
+//   - sys.ENOTSUP: the caller doesn't import its memory or doesn't export
+//     StartName.
+//   - sys.EAGAIN: the caller is closed, or no thread ID is left.
+func (s *Spawner) Spawn(ctx context.Context, caller *wasm.ModuleInstance, startArg uint32) (uint32, experimentalsys.Errno) {
+	// The thread must share the memory with the caller, so it must be imported.
+	if caller.Source.ImportMemoryCount == 0 {
+		return 0, experimentalsys.ENOTSUP
+	}
+	if exp, ok := caller.Exports[StartName]; !ok || exp.Type != wasm.ExternTypeFunc {
+		return 0, experimentalsys.ENOTSUP
+	}
+
+	g := s.group(caller)
+	tid, ok := g.nextID()
+	if !ok {
+		return 0, experimentalsys.EAGAIN
+	}
+
+	// The thread outlives this call, so it must not be canceled with it, nor
+	// share its fuel meter, if any.
+	threadCtx := context.WithValue(context.WithoutCancel(ctx), expctxkeys.FuelMeterKey{}, nil)
+	mod, err := caller.InstantiateThread(threadCtx)
+	if err != nil {
+		return 0, experimentalsys.EAGAIN
+	}
+	mod.CloseNotifier = experimental.CloseNotifyFunc(func(ctx context.Context, exitCode uint32) {
+		s.remove(mod)
+		if g.remove(mod) { // closed by proc_exit or the host.
+			g.close(ctx, exitCode)
+		}
+	})
+	s.mux.Lock()
+	s.groups[mod] = g
+	s.mux.Unlock()
+	if !g.add(mod, tid) {
+		_ = mod.Close(threadCtx)
+		return 0, experimentalsys.EAGAIN
+	}
+
+	go func() {
+		_, err := mod.ExportedFunction(StartName).Call(threadCtx, uint64(tid), uint64(startArg))
+		if err == nil {
+			// The thread returned, so it leaves the group before closing.
+			g.remove(mod)
+			_ = mod.Close(threadCtx)
+		} else if _, ok := err.(*sys.ExitError); !ok { // trap
+			_ = mod.CloseWithExitCode(threadCtx, 1)
+		}
+	}()
+	return tid, 0
+}
+
+// ThreadID returns the thread ID of the module, or zero if it isn't a
+// spawned thread.
+func (s *Spawner) ThreadID(mod *wasm.ModuleInstance) uint32 {
+	s.mux.Lock()
+	g, ok := s.groups[mod]
+	s.mux.Unlock()
+	if !ok {
+		return 0
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if t, ok := g.threads[mod]; ok {
+		return t.id
+	}
+	return 0
+}
+
+// Join waits until the thread with the given ID, spawned by the caller or
+// any thread in its group, leaves. This returns immediately if there is no
+// such thread, e.g. as it already left.
+//
+// # Errors
+//
+// A zero sys.Errno is success. The below are expected otherwise:
+//   - sys.EINVAL: tid is the caller's own thread ID.
+//   - sys.EINTR: ctx is done before the thread left.
+func (s *Spawner) Join(ctx context.Context, caller *wasm.ModuleInstance, tid uint32) experimentalsys.Errno {
+	s.mux.Lock()
+	g, ok := s.groups[caller]
+	s.mux.Unlock()
+	if !ok {
+		return 0 // never spawned a thread
+	}
+
+	g.mux.Lock()
+	t, ok := g.ids[tid]
+	self := ok && g.threads[caller] == t
+	g.mux.Unlock()
+	if self {
+		return experimentalsys.EINVAL
+	} else if !ok {
+		return 0
+	}
+
+	select {
+	case <-t.done:
+		return 0
+	case <-ctx.Done():
+		return experimentalsys.EINTR
+	}
+}
+
+// Exit closes the caller with the exit code. When the caller is a spawned
+// thread, only it is closed, otherwise its group is, like proc_exit.
+//
+// Note: This panics with sys.ExitError to prevent any code from executing
+// after it.
+func (s *Spawner) Exit(ctx context.Context, caller *wasm.ModuleInstance, exitCode uint32) {
+	s.mux.Lock()
+	g, ok := s.groups[caller]
+	s.mux.Unlock()
+
+	// The thread leaves before closing, so it doesn't close its group.
+	if ok && caller != g.main {
+		g.remove(caller)
+	}
+	_ = caller.CloseWithExitCode(ctx, exitCode)
+	panic(sys.NewExitError(exitCode))
+}
+
+// group returns the threadGroup of the caller, creating one with the caller
+// as its main module if it has never spawned a thread.
+func (s *Spawner) group(caller *wasm.ModuleInstance) *threadGroup {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	g, ok := s.groups[caller]
+	if ok {
+		return g
+	}
+	g = &threadGroup{
+		main:    caller,
+		threads: map[*wasm.ModuleInstance]*thread{},
+		ids:     map[uint32]*thread{},
+	}
+	s.groups[caller] = g
+
+	// Chain any notifier configured with experimental.WithCloseNotifier.
+	prev := caller.CloseNotifier
+	caller.CloseNotifier = experimental.CloseNotifyFunc(func(ctx context.Context, exitCode uint32) {
+		if prev != nil {
+			prev.CloseNotify(ctx, exitCode)
+		}
+		s.remove(caller)
+		g.close(ctx, exitCode)
+	})
+	return g
+}
+
+func (s *Spawner) remove(m *wasm.ModuleInstance) {
+	s.mux.Lock()
+	delete(s.groups, m)
+	s.mux.Unlock()
+}
+
+func (g *threadGroup) nextID() (uint32, bool) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if g.closed || g.lastID == maxThreadID {
+		return 0, false
+	}
+	g.lastID++
+	return g.lastID, true
+}
+
+// add adds the thread to this group unless it is already closed.
+func (g *threadGroup) add(mod *wasm.ModuleInstance, tid uint32) bool {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if g.closed {
+		return false
+	}
+	t := &thread{id: tid, done: make(chan struct{})}
+	g.threads[mod] = t
+	g.ids[tid] = t
+	return true
+}
+
+// remove removes the thread from this group, and returns false if it was not
+// a member, e.g. as the group is already closed.
+func (g *threadGroup) remove(mod *wasm.ModuleInstance) bool {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	t, ok := g.threads[mod]
+	if !ok {
+		return false
+	}
+	delete(g.threads, mod)
+	delete(g.ids, t.id)
+	close(t.done)
+	return true
+}
+
+// close closes the main module and all threads with the exit code. This is
+// idempotent as closing a module which is already closed has no effect.
+func (g *threadGroup) close(ctx context.Context, exitCode uint32) {
+	g.mux.Lock()
+	if g.closed {
+		g.mux.Unlock()
+		return
+	}
+	g.closed = true
+	threads := g.threads
+	g.threads, g.ids = nil, nil
+	g.mux.Unlock()
+
+	for mod, t := range threads {
+		close(t.done)
+		_ = mod.CloseWithExitCode(ctx, exitCode)
+	}
+	_ = g.main.CloseWithExitCode(ctx, exitCode)
+}
@@ -174,9 +174,12 @@ const (
 	ErrnoTxtbsy
 	// ErrnoXdev Cross-device link.
 	ErrnoXdev
-
-	// Note: ErrnoNotcapable was removed by WASI maintainers.
+	// ErrnoNotcapable Extension: Capabilities insufficient.
+	//
+	// Note: This was removed from wasi-libc, which converts it to other
+	// errors, so it is only returned when rights are enforced by a policy.
 	// See https://github.com/WebAssembly/wasi-libc/pull/294
+	ErrnoNotcapable
 )
 
 var errnoToString = [...]string{
@@ -270,14 +273,24 @@ func ToErrno(errno sys.Errno) Errno {
 		return ErrnoSuccess
 	case sys.EACCES:
 		return ErrnoAcces
+	case sys.EAFNOSUPPORT:
+		return ErrnoAfnosupport
 	case sys.EAGAIN:
 		return ErrnoAgain
 	case sys.EBADF:
 		return ErrnoBadf
+	case sys.ECONNREFUSED:
+		return ErrnoConnrefused
+	case sys.ENOTCONN:
+		return ErrnoNotconn
+	case sys.EDQUOT:
+		return ErrnoDquot
 	case sys.EEXIST:
 		return ErrnoExist
 	case sys.EFAULT:
 		return ErrnoFault
+	case sys.EFBIG:
+		return ErrnoFbig
 	case sys.EINTR:
 		return ErrnoIntr
 	case sys.EINVAL:
@@ -288,10 +301,14 @@ func ToErrno(errno sys.Errno) Errno {
 		return ErrnoIsdir
 	case sys.ELOOP:
 		return ErrnoLoop
+	case sys.EMFILE:
+		return ErrnoMfile
 	case sys.ENAMETOOLONG:
 		return ErrnoNametoolong
 	case sys.ENOENT:
 		return ErrnoNoent
+	case sys.ENOSPC:
+		return ErrnoNospc
 	case sys.ENOSYS:
 		return ErrnoNosys
 	case sys.ENOTDIR:
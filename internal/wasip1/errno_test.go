@@ -21,6 +21,11 @@ func TestToErrno(t *testing.T) {
 			input:    sys.EACCES,
 			expected: ErrnoAcces,
 		},
+		{
+			name:     "sys.EAFNOSUPPORT",
+			input:    sys.EAFNOSUPPORT,
+			expected: ErrnoAfnosupport,
+		},
 		{
 			name:     "sys.EAGAIN",
 			input:    sys.EAGAIN,
@@ -31,6 +36,36 @@ func TestToErrno(t *testing.T) {
 			input:    sys.EBADF,
 			expected: ErrnoBadf,
 		},
+		{
+			name:     "sys.ECONNREFUSED",
+			input:    sys.ECONNREFUSED,
+			expected: ErrnoConnrefused,
+		},
+		{
+			name:     "sys.ENOTCONN",
+			input:    sys.ENOTCONN,
+			expected: ErrnoNotconn,
+		},
+		{
+			name:     "sys.EDQUOT",
+			input:    sys.EDQUOT,
+			expected: ErrnoDquot,
+		},
+		{
+			name:     "sys.EFBIG",
+			input:    sys.EFBIG,
+			expected: ErrnoFbig,
+		},
+		{
+			name:     "sys.EMFILE",
+			input:    sys.EMFILE,
+			expected: ErrnoMfile,
+		},
+		{
+			name:     "sys.ENOSPC",
+			input:    sys.ENOSPC,
+			expected: ErrnoNospc,
+		},
 		{
 			name:     "sys.EEXIST",
 			input:    sys.EEXIST,
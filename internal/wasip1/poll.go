@@ -10,6 +10,14 @@ const (
 	EventTypeFdWrite
 )
 
+// https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-subclockflags-flagsu16
+const (
+	// SubclockflagsAbstime is the flag named "subscription_clock_abstime",
+	// which interprets the timeout of a clock subscription as an absolute
+	// time instead of one relative to the current time.
+	SubclockflagsAbstime = 1 << iota
+)
+
 const (
 	PollOneoffName = "poll_oneoff"
 )
@@ -9,6 +9,31 @@ const (
 	SockShutdownName = "sock_shutdown"
 )
 
+// The below functions are not defined in WASI preview 1, rather WasmEdge,
+// and are compatible with the WASI socket API of WASIX.
+// See https://wasmedge.org/docs/develop/rust/socket_networking/
+const (
+	SockOpenName     = "sock_open"
+	SockConnectName  = "sock_connect"
+	SockSendToName   = "sock_send_to"
+	SockRecvFromName = "sock_recv_from"
+)
+
+// Address families of sock_open and of the addresses of sock_connect,
+// sock_send_to and sock_recv_from.
+const (
+	AddressFamilyUnspec uint8 = iota
+	AddressFamilyInet4
+	AddressFamilyInet6
+)
+
+// Socket types of sock_open.
+const (
+	SockTypeAny uint8 = iota
+	SockTypeDgram
+	SockTypeStream
+)
+
 // SD Flags indicate which channels on a socket to shut down.
 // https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-sdflags-flagsu8
 const (
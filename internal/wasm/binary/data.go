@@ -6,6 +6,7 @@ import (
 	"io"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -47,9 +48,10 @@ func decodeDataSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret *w
 			d, _, err := leb128.DecodeUint32(r)
 			if err != nil {
 				return fmt.Errorf("read memory index: %v", err)
-			} else if d != 0 {
+			} else if d != 0 && !enabledFeatures.IsEnabled(experimental.CoreFeaturesMultiMemory) {
 				return fmt.Errorf("memory index must be zero but was %d", d)
 			}
+			ret.MemoryIndex = d
 		}
 
 		err = decodeConstantExpression(r, enabledFeatures, &ret.OffsetExpression)
@@ -206,30 +206,45 @@ func checkSectionOrder(current, previous wasm.SectionID) (byte, bool) {
 	return current, current > previous
 }
 
+// SizeMemory derives min, capacity and max pages of a 32-bit memory defined
+// outside a binary, e.g. by a host module, the same way as DecodeModule.
+func SizeMemory(minPages uint32, maxPages *uint32, memoryLimitPages uint32, memoryCapacityFromMax bool) (min, capacity, max uint32) {
+	return newMemorySizer(memoryLimitPages, memoryCapacityFromMax)(minPages, maxPages, false)
+}
+
 // memorySizer derives min, capacity and max pages from decoded wasm.
-type memorySizer func(minPages uint32, maxPages *uint32) (min uint32, capacity uint32, max uint32)
+type memorySizer func(minPages uint32, maxPages *uint32, is64 bool) (min uint32, capacity uint32, max uint32)
 
 // newMemorySizer sets capacity to minPages unless max is defined and
 // memoryCapacityFromMax is true.
+//
+// Note: memoryLimitPages only exceeds wasm.MemoryLimitPages for 64-bit memories.
 func newMemorySizer(memoryLimitPages uint32, memoryCapacityFromMax bool) memorySizer {
-	return func(minPages uint32, maxPages *uint32) (min, capacity, max uint32) {
+	return func(minPages uint32, maxPages *uint32, is64 bool) (min, capacity, max uint32) {
+		limitPages, validLimitPages := memoryLimitPages, wasm.Memory64LimitPages
+		if !is64 {
+			validLimitPages = wasm.MemoryLimitPages
+			if limitPages > wasm.MemoryLimitPages {
+				limitPages = wasm.MemoryLimitPages
+			}
+		}
 		if maxPages != nil {
 			if memoryCapacityFromMax {
 				return minPages, *maxPages, *maxPages
 			}
 			// This is an invalid value: let it propagate, we will fail later.
-			if *maxPages > wasm.MemoryLimitPages {
+			if *maxPages > validLimitPages {
 				return minPages, minPages, *maxPages
 			}
 			// This is a valid value, but it goes over the run-time limit: return the limit.
-			if *maxPages > memoryLimitPages {
-				return minPages, minPages, memoryLimitPages
+			if *maxPages > limitPages {
+				return minPages, minPages, limitPages
 			}
 			return minPages, minPages, *maxPages
 		}
 		if memoryCapacityFromMax {
-			return minPages, memoryLimitPages, memoryLimitPages
+			return minPages, limitPages, limitPages
 		}
-		return minPages, minPages, memoryLimitPages
+		return minPages, minPages, limitPages
 	}
 }
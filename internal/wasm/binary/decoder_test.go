@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/testing/binaryencoding"
 	"github.com/tetratelabs/wazero/internal/testing/dwarftestdata"
 	"github.com/tetratelabs/wazero/internal/testing/require"
@@ -99,7 +100,7 @@ func TestDecodeModule(t *testing.T) {
 			name: "table and memory section",
 			input: &wasm.Module{
 				TableSection:  []wasm.Table{{Min: 3, Type: wasm.RefTypeFuncref}},
-				MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+				MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
 			},
 		},
 		{
@@ -140,6 +141,41 @@ func TestDecodeModule(t *testing.T) {
 		})
 	}
 
+	t.Run("GC types", func(t *testing.T) {
+		input := &wasm.Module{
+			TypeSection: []wasm.FunctionType{
+				{
+					Kind: wasm.CompositeKindStruct, NonFinal: true,
+					Fields: []wasm.FieldType{{Type: wasm.ValueTypeI8, Mutable: true}, {Type: wasm.ValueTypeF64}},
+				},
+				{
+					Kind: wasm.CompositeKindStruct, HasSuperType: true, SuperType: 0,
+					Fields: []wasm.FieldType{
+						{Type: wasm.ValueTypeI8, Mutable: true}, {Type: wasm.ValueTypeF64},
+						{Type: wasm.ValueTypeConcreteRef(2, true)},
+					},
+					RecGroupSize: 2,
+				},
+				{
+					Kind:             wasm.CompositeKindArray,
+					Fields:           []wasm.FieldType{{Type: wasm.ValueTypeConcreteRef(1, false), Mutable: true}},
+					RecGroupSize:     2,
+					RecGroupPosition: 1,
+				},
+				{Params: []wasm.ValueType{wasm.ValueTypeI31ref.AsNonNullable()}, Results: []wasm.ValueType{wasm.ValueTypeAnyref}},
+			},
+		}
+		m, e := DecodeModule(binaryencoding.EncodeModule(input), api.CoreFeaturesV2|experimental.CoreFeaturesGC, wasm.MemoryLimitPages, false, false, false)
+		require.NoError(t, e)
+		for i := range input.TypeSection {
+			_ = input.TypeSection[i].String()
+		}
+		require.Equal(t, input, m)
+
+		_, e = DecodeModule(binaryencoding.EncodeModule(input), api.CoreFeaturesV2, wasm.MemoryLimitPages, false, false, false)
+		require.Error(t, e)
+	})
+
 	t.Run("skips custom section", func(t *testing.T) {
 		input := append(append(Magic, version...),
 			wasm.SectionIDCustom, 0xf, // 15 bytes in this section
@@ -68,7 +68,7 @@ func decodeElementRefType(r *bytes.Reader) (wasm.RefType, error) {
 		return decodeRefType(r, b == wasm.RefPrefixNullable)
 	default:
 		ret := wasm.ValueType(b)
-		if ret != wasm.RefTypeFuncref && ret != wasm.RefTypeExternref {
+		if ret != wasm.RefTypeFuncref && ret != wasm.RefTypeExternref && !wasm.IsGCRefType(ret) {
 			return 0, fmt.Errorf("invalid ref type for element: 0x%x", b)
 		}
 		return ret, nil
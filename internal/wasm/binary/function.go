@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -54,3 +55,115 @@ func decodeFunctionType(enabledFeatures api.CoreFeatures, r *bytes.Reader, ret *
 
 	return nil
 }
+
+// decodeSubType decodes a type in the type section, which is either a function type or, when
+// experimental.CoreFeaturesGC is enabled, a struct or array type optionally declared with a supertype.
+//
+// See https://webassembly.github.io/gc/core/binary/types.html#composite-types
+func decodeSubType(enabledFeatures api.CoreFeatures, r *bytes.Reader, ret *wasm.FunctionType) (err error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("read leading byte: %w", err)
+	}
+
+	switch b {
+	case 0x50, 0x4f: // sub, sub final
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+			return fmt.Errorf("sub type invalid as %v", err)
+		}
+		ret.NonFinal = b == 0x50
+		count, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return fmt.Errorf("read supertype count: %w", err)
+		}
+		switch count {
+		case 0:
+		case 1:
+			if ret.SuperType, _, err = leb128.DecodeUint32(r); err != nil {
+				return fmt.Errorf("read supertype: %w", err)
+			}
+			ret.HasSuperType = true
+		default:
+			return fmt.Errorf("at most one supertype is allowed, but got %d", count)
+		}
+	default:
+		if err = r.UnreadByte(); err != nil {
+			return err
+		}
+	}
+	return decodeCompositeType(enabledFeatures, r, ret)
+}
+
+func decodeCompositeType(enabledFeatures api.CoreFeatures, r *bytes.Reader, ret *wasm.FunctionType) (err error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("read leading byte: %w", err)
+	}
+
+	switch b {
+	case 0x5f: // struct
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+			return fmt.Errorf("struct type invalid as %v", err)
+		}
+		count, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return fmt.Errorf("could not read field count: %w", err)
+		}
+		ret.Kind = wasm.CompositeKindStruct
+		if count > 0 {
+			ret.Fields = make([]wasm.FieldType, count)
+		}
+		for i := range ret.Fields {
+			if ret.Fields[i], err = decodeFieldType(r); err != nil {
+				return fmt.Errorf("could not read field[%d]: %w", i, err)
+			}
+		}
+	case 0x5e: // array
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+			return fmt.Errorf("array type invalid as %v", err)
+		}
+		ret.Kind = wasm.CompositeKindArray
+		ret.Fields = make([]wasm.FieldType, 1)
+		if ret.Fields[0], err = decodeFieldType(r); err != nil {
+			return fmt.Errorf("could not read array element: %w", err)
+		}
+	default:
+		if err = r.UnreadByte(); err != nil {
+			return err
+		}
+		return decodeFunctionType(enabledFeatures, r, ret)
+	}
+
+	// cache the key for the type
+	_ = ret.String()
+	return nil
+}
+
+// decodeFieldType decodes the storage type and mutability of a struct field or array element.
+func decodeFieldType(r *bytes.Reader) (ret wasm.FieldType, err error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return ret, fmt.Errorf("read storage type: %w", err)
+	}
+	switch b {
+	case wasm.ValueTypeI8.Kind(), wasm.ValueTypeI16.Kind():
+		ret.Type = wasm.ValueType(b)
+	default:
+		if ret.Type, err = decodeValueType(r, b); err != nil {
+			return ret, err
+		}
+	}
+
+	mut, err := r.ReadByte()
+	if err != nil {
+		return ret, fmt.Errorf("read mutability: %w", err)
+	}
+	switch mut {
+	case 0x00:
+	case 0x01:
+		ret.Mutable = true
+	default:
+		return ret, fmt.Errorf("%w for mutability: %#x != 0x00 or 0x01", ErrInvalidByte, mut)
+	}
+	return
+}
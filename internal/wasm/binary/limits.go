@@ -3,6 +3,7 @@ package binary
 import (
 	"bytes"
 	"fmt"
+	"math"
 
 	"github.com/tetratelabs/wazero/internal/leb128"
 )
@@ -12,36 +13,50 @@ import (
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#limits%E2%91%A6
 //
 // Extended in threads proposal: https://webassembly.github.io/threads/core/binary/types.html#limits
-func decodeLimitsType(r *bytes.Reader) (min uint32, max *uint32, shared bool, err error) {
+//
+// Extended in memory64 proposal: https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md
+// When is64 is true, min and max are encoded as u64 and values above math.MaxUint32 are saturated, so that they fail
+// validation against the page limits later.
+func decodeLimitsType(r *bytes.Reader) (min uint32, max *uint32, shared, is64 bool, err error) {
 	var flag byte
 	if flag, err = r.ReadByte(); err != nil {
 		err = fmt.Errorf("read leading byte: %v", err)
 		return
 	}
 
-	switch flag {
-	case 0x00, 0x02:
-		min, _, err = leb128.DecodeUint32(r)
-		if err != nil {
-			err = fmt.Errorf("read min of limit: %v", err)
-		}
-	case 0x01, 0x03:
-		min, _, err = leb128.DecodeUint32(r)
-		if err != nil {
-			err = fmt.Errorf("read min of limit: %v", err)
-			return
-		}
+	if flag > 0x07 {
+		err = fmt.Errorf("%v for limits: %#x not in (0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07)", ErrInvalidByte, flag)
+		return
+	}
+
+	hasMax := flag&0x01 != 0
+	shared = flag&0x02 != 0
+	is64 = flag&0x04 != 0
+
+	if min, err = decodeLimit(r, is64); err != nil {
+		err = fmt.Errorf("read min of limit: %v", err)
+		return
+	}
+	if hasMax {
 		var m uint32
-		if m, _, err = leb128.DecodeUint32(r); err != nil {
+		if m, err = decodeLimit(r, is64); err != nil {
 			err = fmt.Errorf("read max of limit: %v", err)
-		} else {
-			max = &m
+			return
 		}
-	default:
-		err = fmt.Errorf("%v for limits: %#x not in (0x00, 0x01, 0x02, 0x03)", ErrInvalidByte, flag)
+		max = &m
 	}
-
-	shared = flag == 0x02 || flag == 0x03
-
 	return
 }
+
+// decodeLimit reads a single limit value, as u64 when is64 is true.
+func decodeLimit(r *bytes.Reader, is64 bool) (uint32, error) {
+	if !is64 {
+		v, _, err := leb128.DecodeUint32(r)
+		return v, err
+	}
+	v, _, err := leb128.DecodeUint64(r)
+	if v > math.MaxUint32 {
+		v = math.MaxUint32
+	}
+	return uint32(v), err
+}
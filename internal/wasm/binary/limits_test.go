@@ -19,6 +19,7 @@ func TestLimitsType(t *testing.T) {
 		min      uint32
 		max      *uint32
 		shared   bool
+		is64     bool
 		expected []byte
 	}{
 		{
@@ -76,22 +77,47 @@ func TestLimitsType(t *testing.T) {
 			shared:   true,
 			expected: []byte{0x3, 0xff, 0xff, 0xff, 0xff, 0xf, 0xff, 0xff, 0xff, 0xff, 0xf},
 		},
+		{
+			name:     "min 0, is64",
+			is64:     true,
+			expected: []byte{0x4, 0},
+		},
+		{
+			name:     "min 0, max largest, is64",
+			max:      &largest,
+			is64:     true,
+			expected: []byte{0x5, 0, 0xff, 0xff, 0xff, 0xff, 0xf},
+		},
+		{
+			name:     "min 0, shared, is64",
+			shared:   true,
+			is64:     true,
+			expected: []byte{0x6, 0},
+		},
+		{
+			name:     "min 0, max 0, shared, is64",
+			max:      &zero,
+			shared:   true,
+			is64:     true,
+			expected: []byte{0x7, 0, 0},
+		},
 	}
 
 	for _, tt := range tests {
 		tc := tt
 
-		b := binaryencoding.EncodeLimitsType(tc.min, tc.max, tc.shared)
+		b := binaryencoding.EncodeLimitsType(tc.min, tc.max, tc.shared, tc.is64)
 		t.Run(fmt.Sprintf("encode - %s", tc.name), func(t *testing.T) {
 			require.Equal(t, tc.expected, b)
 		})
 
 		t.Run(fmt.Sprintf("decode - %s", tc.name), func(t *testing.T) {
-			min, max, shared, err := decodeLimitsType(bytes.NewReader(b))
+			min, max, shared, is64, err := decodeLimitsType(bytes.NewReader(b))
 			require.NoError(t, err)
 			require.Equal(t, min, tc.min)
 			require.Equal(t, max, tc.max)
 			require.Equal(t, shared, tc.shared)
+			require.Equal(t, is64, tc.is64)
 		})
 	}
 }
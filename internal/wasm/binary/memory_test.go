@@ -111,7 +111,7 @@ func Test_newMemorySizer(t *testing.T) {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
 			sizer := newMemorySizer(tc.limit, tc.memoryCapacityFromMax)
-			min, capacity, max := sizer(tc.min, tc.max)
+			min, capacity, max := sizer(tc.min, tc.max, false)
 			require.Equal(t, tc.expectedMin, min)
 			require.Equal(t, tc.expectedCapacity, capacity)
 			require.Equal(t, tc.expectedMax, max)
@@ -170,6 +170,11 @@ func TestMemoryType(t *testing.T) {
 			input:    &wasm.Memory{Max: 1, IsMaxEncoded: true, IsShared: true},
 			expected: []byte{0x3, 0, 1},
 		},
+		{
+			name:     "min 1, max 1, 64-bit",
+			input:    &wasm.Memory{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true, Is64: true},
+			expected: []byte{0x5, 1, 1},
+		},
 	}
 
 	for _, tt := range tests {
@@ -193,6 +198,9 @@ func TestMemoryType(t *testing.T) {
 			if tc.input.IsShared {
 				features = features.SetEnabled(experimental.CoreFeaturesThreads, true)
 			}
+			if tc.input.Is64 {
+				features = features.SetEnabled(experimental.CoreFeaturesMemory64, true)
+			}
 			binary, err := decodeMemory(bytes.NewReader(b), features, newMemorySizer(tmax, false), tmax)
 			require.NoError(t, err)
 			require.Equal(t, binary, expectedDecoded)
@@ -235,6 +243,11 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 			threadsEnabled: true,
 			expectedErr:    "shared memory requires a maximum size to be specified",
 		},
+		{
+			name:        "64-bit but no memory64",
+			input:       []byte{0x5, 0, 1},
+			expectedErr: "64-bit memory requested but memory64 feature not enabled",
+		},
 	}
 
 	for _, tt := range tests {
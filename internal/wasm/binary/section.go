@@ -6,6 +6,7 @@ import (
 	"io"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -31,18 +32,19 @@ func decodeTypeSection(enabledFeatures api.CoreFeatures, r *bytes.Reader) ([]was
 			}
 			startIdx := uint32(len(result))
 			for j := uint32(0); j < recCount; j++ {
-				var ft wasm.FunctionType
-				if err = decodeFunctionType(enabledFeatures, r, &ft); err != nil {
+				ft := wasm.FunctionType{RecGroupSize: int(recCount), RecGroupPosition: int(j)}
+				if err = decodeSubType(enabledFeatures, r, &ft); err != nil {
 					return nil, fmt.Errorf("read %d-th type in rec group: %v", j, err)
 				}
-				ft.RecGroupSize = int(recCount)
-				ft.RecGroupPosition = int(j)
 				result = append(result, ft)
 			}
 			for j := uint32(0); j < recCount; j++ {
 				if err := validateTypeForwardRefs(&result[startIdx+j], startIdx+recCount); err != nil {
 					return nil, err
 				}
+				if err := validateSuperTypeIndex(&result[startIdx+j], startIdx+j); err != nil {
+					return nil, err
+				}
 			}
 		} else {
 			// Put back the byte and decode as a regular function type.
@@ -50,12 +52,15 @@ func decodeTypeSection(enabledFeatures api.CoreFeatures, r *bytes.Reader) ([]was
 				return nil, err
 			}
 			var ft wasm.FunctionType
-			if err = decodeFunctionType(enabledFeatures, r, &ft); err != nil {
+			if err = decodeSubType(enabledFeatures, r, &ft); err != nil {
 				return nil, fmt.Errorf("read %d-th type: %v", i, err)
 			}
 			if err := validateTypeForwardRefs(&ft, uint32(len(result))); err != nil {
 				return nil, err
 			}
+			if err := validateSuperTypeIndex(&ft, uint32(len(result))); err != nil {
+				return nil, err
+			}
 			result = append(result, ft)
 		}
 	}
@@ -67,6 +72,11 @@ func decodeTypeSection(enabledFeatures api.CoreFeatures, r *bytes.Reader) ([]was
 // types decoded so far; for rec groups, it is the index after the last member,
 // allowing mutual references within the group.
 func validateTypeForwardRefs(ft *wasm.FunctionType, maxTypeIndex uint32) error {
+	for i, f := range ft.Fields {
+		if vt := f.Type; vt.IsConcreteRef() && vt.TypeIndex() >= maxTypeIndex {
+			return fmt.Errorf("unknown type index %d in field[%d]", vt.TypeIndex(), i)
+		}
+	}
 	for i, vt := range ft.Params {
 		if vt.IsConcreteRef() && vt.TypeIndex() >= maxTypeIndex {
 			return fmt.Errorf("unknown type index %d in param[%d]", vt.TypeIndex(), i)
@@ -80,6 +90,15 @@ func validateTypeForwardRefs(ft *wasm.FunctionType, maxTypeIndex uint32) error {
 	return nil
 }
 
+// validateSuperTypeIndex rejects a declared supertype which is not defined before
+// the type at index typeIndex.
+func validateSuperTypeIndex(ft *wasm.FunctionType, typeIndex uint32) error {
+	if ft.HasSuperType && ft.SuperType >= typeIndex {
+		return fmt.Errorf("supertype index %d of type %d is not defined before it", ft.SuperType, typeIndex)
+	}
+	return nil
+}
+
 // decodeImportSection decodes the decoded import segments plus the count per wasm.ExternType.
 func decodeImportSection(
 	r *bytes.Reader,
@@ -166,19 +185,29 @@ func decodeMemorySection(
 	enabledFeatures api.CoreFeatures,
 	memorySizer memorySizer,
 	memoryLimitPages uint32,
-) (*wasm.Memory, error) {
+) ([]wasm.Memory, error) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
 		return nil, fmt.Errorf("error reading size")
 	}
 	if vs > 1 {
-		return nil, fmt.Errorf("at most one memory allowed in module, but read %d", vs)
+		if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesMultiMemory); err != nil {
+			return nil, fmt.Errorf("at most one memory allowed in module, but read %d", vs)
+		}
 	} else if vs == 0 {
 		// memory count can be zero.
 		return nil, nil
 	}
 
-	return decodeMemory(r, enabledFeatures, memorySizer, memoryLimitPages)
+	ret := make([]wasm.Memory, vs)
+	for i := range ret {
+		mem, err := decodeMemory(r, enabledFeatures, memorySizer, memoryLimitPages)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = *mem
+	}
+	return ret, nil
 }
 
 func decodeGlobalSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]wasm.Global, error) {
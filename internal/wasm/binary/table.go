@@ -51,8 +51,8 @@ func decodeTable(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret *wasm.Ta
 		}
 	}
 
-	var shared bool
-	ret.Min, ret.Max, shared, err = decodeLimitsType(r)
+	var shared, is64 bool
+	ret.Min, ret.Max, shared, is64, err = decodeLimitsType(r)
 	if err != nil {
 		return fmt.Errorf("read limits: %v", err)
 	}
@@ -67,6 +67,9 @@ func decodeTable(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret *wasm.Ta
 	if shared {
 		return fmt.Errorf("tables cannot be marked as shared")
 	}
+	if is64 {
+		return fmt.Errorf("64-bit tables are not supported")
+	}
 
 	if hasInitExpr {
 		var initExpr wasm.ConstantExpression
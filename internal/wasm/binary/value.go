@@ -22,44 +22,46 @@ func decodeValueTypes(r *bytes.Reader, num uint32) ([]wasm.ValueType, error) {
 		if err != nil {
 			return nil, err
 		}
-		switch b {
-		case wasm.ValueTypeI32.Kind(), wasm.ValueTypeF32.Kind(), wasm.ValueTypeI64.Kind(), wasm.ValueTypeF64.Kind(),
-			wasm.ValueTypeExternref.Kind(), wasm.ValueTypeFuncref.Kind(), wasm.ValueTypeV128.Kind(),
-			wasm.ValueTypeExnref.Kind():
-			ret = append(ret, wasm.ValueType(b))
-		case wasm.RefPrefixNullable, wasm.RefPrefixNonNullable:
-			vt, err := decodeRefType(r, b == wasm.RefPrefixNullable)
-			if err != nil {
-				return nil, err
-			}
-			ret = append(ret, vt)
-		default:
-			return nil, fmt.Errorf("invalid value type: %d", b)
+		vt, err := decodeValueType(r, b)
+		if err != nil {
+			return nil, err
 		}
+		ret = append(ret, vt)
 	}
 	return ret, nil
 }
 
+// decodeValueType decodes the value type whose leading byte b was already read from r.
+func decodeValueType(r *bytes.Reader, b byte) (wasm.ValueType, error) {
+	switch b {
+	case wasm.ValueTypeI32.Kind(), wasm.ValueTypeF32.Kind(), wasm.ValueTypeI64.Kind(), wasm.ValueTypeF64.Kind(),
+		wasm.ValueTypeExternref.Kind(), wasm.ValueTypeFuncref.Kind(), wasm.ValueTypeV128.Kind(),
+		wasm.ValueTypeExnref.Kind():
+		return wasm.ValueType(b), nil
+	case wasm.RefPrefixNullable, wasm.RefPrefixNonNullable:
+		return decodeRefType(r, b == wasm.RefPrefixNullable)
+	default:
+		if vt := wasm.ValueType(b); wasm.IsGCRefType(vt) {
+			return vt, nil
+		}
+		return 0, fmt.Errorf("invalid value type: %d", b)
+	}
+}
+
 // decodeRefType decodes a heap type from r and returns the corresponding
 // ValueType with the given nullability. Abstract nullable refs are desugared
 // to their short forms:
 //   - (ref null func)   -> funcref
 //   - (ref null extern) -> externref
 //   - (ref null exn)    -> exnref
+//   - (ref null any)    -> anyref, and likewise for the other heap types of the GC proposal
 func decodeRefType(r *bytes.Reader, nullable bool) (wasm.ValueType, error) {
 	ht, _, err := leb128.DecodeInt33AsInt64(r)
 	if err != nil {
 		return 0, fmt.Errorf("read ref heap type: %w", err)
 	}
-	var vt wasm.ValueType
-	switch ht {
-	case wasm.HeapTypeFunc:
-		vt = wasm.ValueTypeFuncref
-	case wasm.HeapTypeExtern:
-		vt = wasm.ValueTypeExternref
-	case wasm.HeapTypeExn:
-		vt = wasm.ValueTypeExnref
-	default:
+	vt, ok := wasm.AbstractRefType(ht)
+	if !ok {
 		if ht < 0 {
 			return 0, fmt.Errorf("unknown abstract heap type: %d", ht)
 		}
@@ -13,7 +13,31 @@ type ConstantExpression struct {
 	Data []byte
 }
 
+// constExprGC is the context to evaluate the instructions of the GC proposal in a constant expression.
+type constExprGC struct {
+	// types is the type section of the module.
+	types []FunctionType
+	// funcTypeIndex optionally resolves the type of ref.func, which is otherwise typed as funcref.
+	funcTypeIndex func(funcIndex Index) (Index, bool)
+	// m allocates the objects, and is nil when the expression is only validated.
+	m *ModuleInstance
+	// validated is the module being validated, which is marked as Module.UsesGC when its constant
+	// expressions use GC instructions.
+	validated *Module
+}
+
+// validationConstExprGC returns the constExprGC to validate the constant expressions of this module.
+func (m *Module) validationConstExprGC() *constExprGC {
+	return &constExprGC{types: m.TypeSection, funcTypeIndex: m.typeIndexOfFunction, validated: m}
+}
+
 func evaluateConstExpr(e *ConstantExpression, globalResolver func(globalIndex Index) (ValueType, uint64, uint64, error), funcRefResolver func(funcIndex Index) (Reference, error)) ([]uint64, ValueType, error) {
+	return evaluateConstExprGC(e, nil, globalResolver, funcRefResolver)
+}
+
+// evaluateConstExprGC is the same as evaluateConstExpr, but also evaluates the instructions of the GC
+// proposal when gc is non-nil.
+func evaluateConstExprGC(e *ConstantExpression, gc *constExprGC, globalResolver func(globalIndex Index) (ValueType, uint64, uint64, error), funcRefResolver func(funcIndex Index) (Reference, error)) ([]uint64, ValueType, error) {
 	var stack []uint64
 	var typeStack []ValueType
 	var pc uint64
@@ -92,6 +116,14 @@ func evaluateConstExpr(e *ConstantExpression, globalResolver func(globalIndex In
 				valType = ValueTypeExnref
 				pc++
 			default:
+				if b&0xc0 == 0x40 { // A single byte negative s33 is an abstract heap type.
+					var ok bool
+					if valType, ok = AbstractRefType(int64(int8(b<<1) >> 1)); !ok {
+						return nil, 0, fmt.Errorf("invalid type for ref.null: 0x%x", b)
+					}
+					pc++
+					break
+				}
 				// Concrete type index encoded as LEB128.
 				typeIdx, n, err := leb128.LoadUint32(data[pc:])
 				if err != nil {
@@ -113,7 +145,21 @@ func evaluateConstExpr(e *ConstantExpression, globalResolver func(globalIndex In
 				return nil, 0, err
 			}
 			stack = append(stack, uint64(ref))
-			typeStack = append(typeStack, ValueTypeFuncref)
+			refType := ValueTypeFuncref
+			if gc != nil && gc.funcTypeIndex != nil {
+				if typeIndex, ok := gc.funcTypeIndex(Index(v)); ok {
+					refType = ValueTypeConcreteRef(typeIndex, false)
+				}
+			}
+			typeStack = append(typeStack, refType)
+		case OpcodeGCPrefix:
+			if gc == nil {
+				return nil, 0, fmt.Errorf("invalid opcode for const expression: 0x%x", opCode)
+			}
+			var err error
+			if pc, err = gc.evaluate(data, pc, &stack, &typeStack); err != nil {
+				return nil, 0, err
+			}
 		case OpcodeVecPrefix:
 			if data[pc] != OpcodeVecV128Const {
 				return nil, 0, fmt.Errorf("invalid vector opcode for const expression: %#x", data[pc-1])
@@ -223,9 +269,237 @@ func evaluateConstExpr(e *ConstantExpression, globalResolver func(globalIndex In
 	}
 }
 
+// evaluate evaluates the GC instruction at data[pc:], which follows the prefix, and returns the pc
+// of the next instruction.
+func (gc *constExprGC) evaluate(data []byte, pc uint64, stack *[]uint64, typeStack *[]ValueType) (uint64, error) {
+	op, n, err := leb128.LoadUint32(data[pc:])
+	if err != nil {
+		return 0, fmt.Errorf("read GC opcode: %w", err)
+	}
+	pc += uint64(n)
+	name := GCInstructionName(op)
+	if gc.validated != nil {
+		gc.validated.UsesGC = true
+	}
+
+	// pop pops a value of the expected type, and returns its slots.
+	pop := func(expected ValueType) ([]uint64, error) {
+		ts := *typeStack
+		if len(ts) == 0 {
+			return nil, fmt.Errorf("stack underflow on %s", name)
+		}
+		actual := ts[len(ts)-1]
+		if !isRefSubtypeOf(gc.types, actual, expected) {
+			return nil, fmt.Errorf("type mismatch on %s: expected %s but was %s", name, ValueTypeName(expected), ValueTypeName(actual))
+		}
+		*typeStack = ts[:len(ts)-1]
+		vs := *stack
+		l := len(vs) - actual.slots()
+		*stack = vs[:l]
+		return vs[l:len(vs):len(vs)], nil
+	}
+	push := func(v uint64, vt ValueType) {
+		*stack = append(*stack, v)
+		*typeStack = append(*typeStack, vt)
+	}
+	// readType reads the type index immediate of a struct or an array type.
+	readType := func(kind CompositeKind) (Index, *FunctionType, error) {
+		typeIndex, n, err := leb128.LoadUint32(data[pc:])
+		if err != nil {
+			return 0, nil, fmt.Errorf("read type index of %s: %w", name, err)
+		}
+		pc += uint64(n)
+		if typeIndex >= uint32(len(gc.types)) || gc.types[typeIndex].Kind != kind {
+			return 0, nil, fmt.Errorf("invalid type index %d for %s", typeIndex, name)
+		}
+		return typeIndex, &gc.types[typeIndex], nil
+	}
+	// alloc allocates an object with the given slots, and pushes its reference. This happens before
+	// popping the values stored in the object, so they are roots in case of a collection.
+	alloc := func(typeIndex Index, slots uint64) *GCObject {
+		if gc.m == nil {
+			push(0, ValueTypeConcreteRef(typeIndex, false))
+			return nil
+		}
+		ref, obj := gc.m.GCNewObject(typeIndex, slots, *stack)
+		push(ref, ValueTypeConcreteRef(typeIndex, false))
+		return obj
+	}
+	// withOperands calls f with the reference pushed by alloc temporarily removed, so that f can pop
+	// the operands below it.
+	withOperands := func(f func() error) error {
+		ts, vs := *typeStack, *stack
+		top, topValue := ts[len(ts)-1], vs[len(vs)-1]
+		*typeStack, *stack = ts[:len(ts)-1], vs[:len(vs)-1]
+		if err := f(); err != nil {
+			return err
+		}
+		push(topValue, top)
+		return nil
+	}
+
+	switch op {
+	case OpcodeGCStructNew, OpcodeGCStructNewDefault:
+		typeIndex, t, err := readType(CompositeKindStruct)
+		if err != nil {
+			return 0, err
+		}
+		obj := alloc(typeIndex, uint64(t.StructSlots()))
+		if op == OpcodeGCStructNewDefault {
+			for _, f := range t.Fields {
+				if !isDefaultable(f.Type) {
+					return 0, fmt.Errorf("%s requires defaultable fields but has %s", name, ValueTypeName(f.Type))
+				}
+			}
+			break
+		}
+		err = withOperands(func() error {
+			for i := len(t.Fields) - 1; i >= 0; i-- {
+				f := t.Fields[i]
+				vs, err := pop(f.Unpacked())
+				if err != nil {
+					return err
+				}
+				if obj != nil {
+					copy(obj.Fields[t.StructFieldSlot(Index(i)):], vs)
+					packField(obj.Fields, t.StructFieldSlot(Index(i)), f.Type)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	case OpcodeGCArrayNew, OpcodeGCArrayNewDefault:
+		typeIndex, t, err := readType(CompositeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		elem := t.Fields[0]
+		lengths, err := pop(ValueTypeI32)
+		if err != nil {
+			return 0, err
+		}
+		if op == OpcodeGCArrayNewDefault && !isDefaultable(elem.Type) {
+			return 0, fmt.Errorf("%s requires a defaultable element but has %s", name, ValueTypeName(elem.Type))
+		}
+		length := uint64(uint32(lengths[0]))
+		obj := alloc(typeIndex, length*uint64(elem.Type.slots()))
+		if op == OpcodeGCArrayNew {
+			err = withOperands(func() error {
+				init, err := pop(elem.Unpacked())
+				if err != nil {
+					return err
+				}
+				if obj != nil {
+					for i := 0; i < len(obj.Fields); i += len(init) {
+						copy(obj.Fields[i:], init)
+						packField(obj.Fields, i, elem.Type)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+	case OpcodeGCArrayNewFixed:
+		typeIndex, t, err := readType(CompositeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		length, n, err := leb128.LoadUint32(data[pc:])
+		if err != nil {
+			return 0, fmt.Errorf("read length of %s: %w", name, err)
+		}
+		pc += uint64(n)
+		elem := t.Fields[0]
+		slots := elem.Type.slots()
+		obj := alloc(typeIndex, uint64(length)*uint64(slots))
+		err = withOperands(func() error {
+			for i := int(length) - 1; i >= 0; i-- {
+				vs, err := pop(elem.Unpacked())
+				if err != nil {
+					return err
+				}
+				if obj != nil {
+					copy(obj.Fields[i*slots:], vs)
+					packField(obj.Fields, i*slots, elem.Type)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	case OpcodeGCRefI31:
+		vs, err := pop(ValueTypeI32)
+		if err != nil {
+			return 0, err
+		}
+		push(GCRefI31(uint32(vs[0])), ValueTypeI31ref.AsNonNullable())
+	case OpcodeGCAnyConvertExtern:
+		ts := *typeStack
+		if len(ts) == 0 {
+			return 0, fmt.Errorf("stack underflow on %s", name)
+		}
+		nullable := ts[len(ts)-1].IsNullable()
+		vs, err := pop(ValueTypeExternref)
+		if err != nil {
+			return 0, err
+		}
+		v := vs[0]
+		if gc.m != nil {
+			v = gc.m.GCAnyConvertExtern(v, *stack)
+		}
+		result := ValueTypeAnyref
+		if !nullable {
+			result = result.AsNonNullable()
+		}
+		push(v, result)
+	case OpcodeGCExternConvertAny:
+		ts := *typeStack
+		if len(ts) == 0 {
+			return 0, fmt.Errorf("stack underflow on %s", name)
+		}
+		nullable := ts[len(ts)-1].IsNullable()
+		vs, err := pop(ValueTypeAnyref)
+		if err != nil {
+			return 0, err
+		}
+		v := vs[0]
+		if gc.m != nil {
+			v = gc.m.GCExternConvertAny(v)
+		}
+		result := ValueTypeExternref
+		if !nullable {
+			result = result.AsNonNullable()
+		}
+		push(v, result)
+	default:
+		return 0, fmt.Errorf("invalid GC opcode for const expression: %s", name)
+	}
+	return pc, nil
+}
+
+// packField truncates the value at fields[slot] to the storage type t if it is a packed type.
+func packField(fields []uint64, slot int, t ValueType) {
+	switch t {
+	case ValueTypeI8:
+		fields[slot] &= 0xff
+	case ValueTypeI16:
+		fields[slot] &= 0xffff
+	}
+}
+
 func evaluateConstExprInModuleInstance(e *ConstantExpression, m *ModuleInstance) []uint64 {
-	v, _, _ := evaluateConstExpr(
+	var gc *constExprGC
+	if m.usesGC() {
+		gc = &constExprGC{types: m.Source.TypeSection, m: m}
+	}
+	v, _, _ := evaluateConstExprGC(
 		e,
+		gc,
 		func(globalIndex Index) (ValueType, uint64, uint64, error) {
 			g := m.Globals[globalIndex]
 			return g.Type.ValType, g.Val, g.ValHi, nil
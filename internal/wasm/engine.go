@@ -14,7 +14,7 @@ type Engine interface {
 	Close() (err error)
 
 	// CompileModule implements the same method as documented on wasm.Engine.
-	CompileModule(ctx context.Context, module *Module, listeners []experimental.FunctionListener, ensureTermination bool) error
+	CompileModule(ctx context.Context, module *Module, listeners []experimental.FunctionListener, ensureTermination, fuelMetering, memoryGuardPages bool) error
 
 	// CompiledModuleCount is exported for testing, to track the size of the compilation cache.
 	CompiledModuleCount() uint32
@@ -50,7 +50,11 @@ type ModuleEngine interface {
 	ResolveImportedFunction(index, descFunc, indexInImportedModule Index, importedModuleEngine ModuleEngine)
 
 	// ResolveImportedMemory is called when this module imports a memory from another module.
-	ResolveImportedMemory(importedModuleEngine ModuleEngine)
+	//
+	//	- `index` is the index of the imported memory in this module's memory index space.
+	//	- `indexInImportedModule` is the index of the memory in the imported module's memory index space.
+	//	- `importedModuleEngine` is the ModuleEngine for the imported ModuleInstance.
+	ResolveImportedMemory(index, indexInImportedModule Index, importedModuleEngine ModuleEngine)
 
 	// LookupFunction returns the FunctionModule and the Index of the function in the returned ModuleInstance at the given offset in the table.
 	LookupFunction(t *TableInstance, typeId FunctionTypeID, tableOffset Index) (*ModuleInstance, Index)
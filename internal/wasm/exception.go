@@ -1,9 +1,31 @@
 package wasm
 
 // Exception represents a thrown WebAssembly exception.
+//
+// A host function may panic with an *Exception to throw it into the calling
+// Wasm code, where it can be caught by a matching try_table handler.
 type Exception struct {
 	// Tag is the tag instance that was thrown.
 	Tag *TagInstance
 	// Params holds the argument values matching the tag's function type params.
 	Params []uint64
 }
+
+// ExceptionError is returned by api.Function Call when an Exception escapes
+// all handlers. Err is the error built by the engine, which wraps
+// wasmruntime.ErrRuntimeUncaughtException.
+type ExceptionError struct {
+	Exception *Exception
+	Err       error
+}
+
+// Error implements error.
+func (e *ExceptionError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error, so that errors.Is matches
+// wasmruntime.ErrRuntimeUncaughtException.
+func (e *ExceptionError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,56 @@
+package wasm
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Fuel is the fuel of an api.Function call when fuel metering is enabled.
+// Nested calls made by host functions with the context they are passed share
+// the Fuel of the outermost call, even from other goroutines, so it is
+// updated atomically. Threads spawned by the call have a Fuel of their own.
+type Fuel struct {
+	// remaining is the fuel left, which becomes negative once exhausted.
+	remaining atomic.Int64
+}
+
+// Remaining returns the fuel left, which is negative once exhausted.
+func (f *Fuel) Remaining() int64 {
+	return f.remaining.Load()
+}
+
+// Consume subtracts n from the fuel left, and returns the result.
+func (f *Fuel) Consume(n int64) int64 {
+	return f.remaining.Add(-n)
+}
+
+// RemainingFuel implements the interface used by experimental.RemainingFuel.
+func (f *Fuel) RemainingFuel() uint64 {
+	return uint64(max(f.Remaining(), 0))
+}
+
+// CallFuel returns the Fuel of an api.Function call made with ctx as well as
+// the context to pass to host functions, or nil if the call is not limited.
+func CallFuel(ctx context.Context) (context.Context, *Fuel) {
+	if f, ok := ctx.Value(expctxkeys.FuelMeterKey{}).(*Fuel); ok {
+		return ctx, f // Nested call.
+	}
+	budget, ok := ctx.Value(expctxkeys.FuelKey{}).(uint64)
+	if !ok {
+		return ctx, nil
+	}
+	f := &Fuel{}
+	f.remaining.Store(int64(min(budget, math.MaxInt64)))
+	return context.WithValue(ctx, expctxkeys.FuelMeterKey{}, f), f
+}
+
+// CloseOnFuelExhausted closes the module as the fuel of the call in progress
+// is exhausted, and returns the error to raise to the caller.
+func (m *ModuleInstance) CloseOnFuelExhausted(ctx context.Context) error {
+	_ = m.CloseWithExitCode(ctx, sys.ExitCodeFuelExhausted)
+	return m.FailIfClosed()
+}
@@ -0,0 +1,56 @@
+package wasm
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestCallFuel(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("not limited", func(t *testing.T) {
+		_, f := CallFuel(ctx)
+		require.Nil(t, f)
+	})
+
+	t.Run("nested calls share the fuel", func(t *testing.T) {
+		fuelCtx, f := CallFuel(context.WithValue(ctx, expctxkeys.FuelKey{}, uint64(10)))
+		require.Equal(t, int64(10), f.Remaining())
+
+		_, nested := CallFuel(fuelCtx)
+		require.Equal(t, f, nested)
+	})
+
+	t.Run("budget above math.MaxInt64", func(t *testing.T) {
+		_, f := CallFuel(context.WithValue(ctx, expctxkeys.FuelKey{}, uint64(math.MaxUint64)))
+		require.Equal(t, int64(math.MaxInt64), f.Remaining())
+	})
+}
+
+func TestFuel_Consume(t *testing.T) {
+	f := &Fuel{}
+	f.remaining.Store(1000)
+
+	// Nested calls can be made from other goroutines by host functions.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				f.Consume(2)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int64(0), f.Remaining())
+	require.Equal(t, uint64(0), f.RemainingFuel())
+
+	require.Equal(t, int64(-1), f.Consume(1))
+	require.Equal(t, uint64(0), f.RemainingFuel())
+}
@@ -23,39 +23,72 @@ const maximumValuesOnStack = 1 << 27
 // * idx is the index in the FunctionSection
 // * functions are the function index, which is prefixed by imports. The value is the TypeSection index.
 // * globals are the global index, which is prefixed by imports.
-// * memory is the potentially imported memory and can be nil.
+// * memories are the memory index, which is prefixed by imports.
 // * table is the potentially imported table and can be nil.
 // * declaredFunctionIndexes is the set of function indexes declared by declarative element segments which can be acceed by OpcodeRefFunc instruction.
 //
 // Returns an error if the instruction sequence is not valid,
 // or potentially it can exceed the maximum number of values on the stack.
 func (m *Module) validateFunction(sts *stacks, enabledFeatures api.CoreFeatures, idx Index, functions []Index,
-	globals []GlobalType, memory *Memory, tables []Table, tags []Index, declaredFunctionIndexes map[Index]struct{}, br *bytes.Reader,
+	globals []GlobalType, memories []*Memory, tables []Table, tags []Index, declaredFunctionIndexes map[Index]struct{}, br *bytes.Reader,
 ) error {
-	return m.validateFunctionWithMaxStackValues(sts, enabledFeatures, idx, functions, globals, memory, tables, tags, maximumValuesOnStack, declaredFunctionIndexes, br)
+	return m.validateFunctionWithMaxStackValues(sts, enabledFeatures, idx, functions, globals, memories, tables, tags, maximumValuesOnStack, declaredFunctionIndexes, br)
 }
 
-func readMemArg(pc uint64, body []byte) (align, offset uint32, read uint64, err error) {
+// readMemArg reads the memarg immediate at pc. When multiMemory is true and the bit 6 of the alignment is set,
+// the alignment is followed by an explicit memory index which must be within memories.
+//
+// addrType is the type of the address operand: ValueTypeI64 if the memory is 64-bit, ValueTypeI32 otherwise.
+func readMemArg(pc uint64, body []byte, memories []*Memory, multiMemory bool) (align uint32, offset uint64, addrType ValueType, read uint64, err error) {
 	align, num, err := leb128.LoadUint32(body[pc:])
 	if err != nil {
 		err = fmt.Errorf("read memory align: %v", err)
 		return
 	}
+	read += num
+	var memIdx uint32
+	if multiMemory && align&MemArgMemoryIndexFlag != 0 {
+		align &^= MemArgMemoryIndexFlag
+		memIdx, num, err = leb128.LoadUint32(body[pc+read:])
+		if err != nil {
+			err = fmt.Errorf("read memory index: %v", err)
+			return
+		}
+		if memIdx >= uint32(len(memories)) {
+			err = fmt.Errorf("unknown memory %d", memIdx)
+			return
+		}
+		read += num
+	}
 	if align >= 32 {
 		// Prevent 1<<align uint32 overflow.
 		err = fmt.Errorf("invalid memory alignment")
 		return
 	}
-	read += num
 
-	offset, num, err = leb128.LoadUint32(body[pc+num:])
+	addrType = memoryAddressType(memories[memIdx])
+	if addrType == ValueTypeI64 {
+		offset, num, err = leb128.LoadUint64(body[pc+read:])
+	} else {
+		var offset32 uint32
+		offset32, num, err = leb128.LoadUint32(body[pc+read:])
+		offset = uint64(offset32)
+	}
 	if err != nil {
 		err = fmt.Errorf("read memory offset: %v", err)
 		return
 	}
 
 	read += num
-	return align, offset, read, nil
+	return align, offset, addrType, read, nil
+}
+
+// memoryAddressType returns the value type of addresses into the memory m.
+func memoryAddressType(m *Memory) ValueType {
+	if m.Is64 {
+		return ValueTypeI64
+	}
+	return ValueTypeI32
 }
 
 // validateFunctionWithMaxStackValues is like validateFunction, but allows overriding maxStackValues for testing.
@@ -68,7 +101,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 	idx Index,
 	functions []Index,
 	globals []GlobalType,
-	memory *Memory,
+	memories []*Memory,
 	tables []Table,
 	tags []Index,
 	maxStackValues int,
@@ -79,8 +112,9 @@ func (m *Module) validateFunctionWithMaxStackValues(
 	code := &m.CodeSection[idx]
 	body := code.Body
 	localTypes := code.LocalTypes
+	multiMemory := enabledFeatures.IsEnabled(experimental.CoreFeaturesMultiMemory)
 
-	sts.reset(functionType)
+	sts.reset(m.TypeSection, functionType)
 	valueTypeStack := &sts.vs
 	// We start with the outermost control block which is for function return if the code branches into it.
 	controlBlockStack := &sts.cs
@@ -108,11 +142,11 @@ func (m *Module) validateFunctionWithMaxStackValues(
 		}
 
 		if OpcodeI32Load <= op && op <= OpcodeI64Store32 {
-			if memory == nil {
+			if len(memories) == 0 {
 				return fmt.Errorf("memory must exist for %s", InstructionName(op))
 			}
 			pc++
-			align, _, read, err := readMemArg(pc, body)
+			align, _, addrType, read, err := readMemArg(pc, body, memories, multiMemory)
 			if err != nil {
 				return err
 			}
@@ -122,7 +156,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -130,7 +164,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeF32)
@@ -141,7 +175,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeF32Store:
@@ -151,14 +185,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeF32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeI64Load:
 				if 1<<align > 64/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -166,7 +200,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 64/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeF64)
@@ -177,7 +211,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeF64Store:
@@ -187,14 +221,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeF64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeI32Load8S:
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -202,7 +236,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -210,7 +244,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -221,7 +255,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeI64Store8:
@@ -231,14 +265,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeI32Load16S, OpcodeI32Load16U:
 				if 1<<align > 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -246,7 +280,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -257,7 +291,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeI64Store16:
@@ -267,14 +301,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeI64Load32S, OpcodeI64Load32U:
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -285,12 +319,12 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			}
 		} else if OpcodeMemorySize <= op && op <= OpcodeMemoryGrow {
-			if memory == nil {
+			if len(memories) == 0 {
 				return fmt.Errorf("memory must exist for %s", InstructionName(op))
 			}
 			pc++
@@ -298,17 +332,22 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			if err != nil {
 				return fmt.Errorf("read immediate: %v", err)
 			}
-			if val != 0 || num != 1 {
+			if multiMemory {
+				if val >= uint32(len(memories)) {
+					return fmt.Errorf("unknown memory %d for %s", val, InstructionName(op))
+				}
+			} else if val != 0 || num != 1 {
 				return fmt.Errorf("memory instruction reserved bytes not zero with 1 byte")
 			}
+			addrType := memoryAddressType(memories[val])
 			switch Opcode(op) {
 			case OpcodeMemoryGrow:
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
-				valueTypeStack.push(ValueTypeI32)
+				valueTypeStack.push(addrType)
 			case OpcodeMemorySize:
-				valueTypeStack.push(ValueTypeI32)
+				valueTypeStack.push(addrType)
 			}
 			pc += num - 1
 		} else if OpcodeI32Const <= op && op <= OpcodeF64Const {
@@ -511,7 +550,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					}
 					if actual == valueTypeUnknown {
 						defaultLabelType[index] = valueTypeUnknown
-					} else if !isRefSubtypeOf(actual, exp) {
+					} else if !isRefSubtypeOf(m.TypeSection, actual, exp) {
 						return typeMismatchError(true, OpcodeBrTableName, actual, exp, i)
 					}
 				}
@@ -536,7 +575,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					return fmt.Errorf("inconsistent block type length for %s at %d; %v (ln=%d) != %v (l=%d)", OpcodeBrTableName, l, defaultLabelType, ln, tableLabelType, l)
 				}
 				for i := range defaultLabelType {
-					if defaultLabelType[i] != valueTypeUnknown && !areRefTypesCompatible(defaultLabelType[i], tableLabelType[i]) {
+					if defaultLabelType[i] != valueTypeUnknown && !areRefTypesCompatible(m.TypeSection, defaultLabelType[i], tableLabelType[i]) {
 						return fmt.Errorf("inconsistent block type for %s at %d", OpcodeBrTableName, l)
 					}
 				}
@@ -635,7 +674,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 						return fmt.Errorf("catch clause type mismatch: catch delivers %d values but label expects %d", len(catchTypes), len(expectedTypes))
 					}
 					for j := range catchTypes {
-						if !isRefSubtypeOf(catchTypes[j], expectedTypes[j]) {
+						if !isRefSubtypeOf(m.TypeSection, catchTypes[j], expectedTypes[j]) {
 							return fmt.Errorf("catch clause type mismatch at index %d: %v is not a subtype of %v", j, catchTypes[j], expectedTypes[j])
 						}
 					}
@@ -664,7 +703,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 						return fmt.Errorf("catch_all clause type mismatch: catch delivers %d values but label expects %d", len(catchTypes), len(expectedTypes))
 					}
 					for j := range catchTypes {
-						if !isRefSubtypeOf(catchTypes[j], expectedTypes[j]) {
+						if !isRefSubtypeOf(m.TypeSection, catchTypes[j], expectedTypes[j]) {
 							return fmt.Errorf("catch_all clause type mismatch at index %d", j)
 						}
 					}
@@ -1018,6 +1057,12 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					valueTypeStack.push(ValueTypeFuncref)
 				case ValueTypeExnref:
 					valueTypeStack.push(ValueTypeExnref)
+				case ValueTypeAnyref, ValueTypeEqref, ValueTypeI31ref, ValueTypeStructref, ValueTypeArrayref,
+					ValueTypeNullref, ValueTypeNullfuncref, ValueTypeNullexternref, ValueTypeNullexnref:
+					if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+						return fmt.Errorf("ref.null with %s invalid as %v", ValueTypeName(ValueType(reftype)), err)
+					}
+					valueTypeStack.push(ValueType(reftype))
 				default:
 					// Concrete type index encoded as LEB128 u32.
 					if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesTypedFunctionReferences); err != nil {
@@ -1151,7 +1196,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				nonNullTp = tp.AsNonNullable()
 			}
 			lastTarget := targetResultType[len(targetResultType)-1]
-			if nonNullTp != valueTypeUnknown && !isRefSubtypeOf(nonNullTp, lastTarget) {
+			if nonNullTp != valueTypeUnknown && !isRefSubtypeOf(m.TypeSection, nonNullTp, lastTarget) {
 				return fmt.Errorf("type mismatch on %s: ref type %s is not a subtype of label's last result %s",
 					OpcodeBrOnNonNullName, ValueTypeName(nonNullTp), ValueTypeName(lastTarget))
 			}
@@ -1193,6 +1238,23 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				}
 			}
 			pc += num - 1
+		} else if op == OpcodeRefEq {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeRefEqName, err)
+			}
+			m.UsesGC = true
+			for i := 0; i < 2; i++ {
+				if err := valueTypeStack.popAndVerifyType(ValueTypeEqref); err != nil {
+					return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeRefEqName, err)
+				}
+			}
+			valueTypeStack.push(ValueTypeI32)
+		} else if op == OpcodeGCPrefix {
+			pc++
+			var err error
+			if pc, err = m.validateGCInstruction(sts, enabledFeatures, body, pc, tables, br); err != nil {
+				return err
+			}
 		} else if op == OpcodeMiscPrefix {
 			pc++
 			// A misc opcode is encoded as an unsigned variable 32-bit integer.
@@ -1250,7 +1312,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					}
 					pc += num - 1
 				case OpcodeMiscMemoryInit, OpcodeMiscMemoryCopy, OpcodeMiscMemoryFill:
-					if memory == nil {
+					if len(memories) == 0 {
 						return fmt.Errorf("memory must exist for %s", MiscInstructionName(miscOpcode))
 					}
 					params = []ValueType{ValueTypeI32, ValueTypeI32, ValueTypeI32}
@@ -1272,24 +1334,41 @@ func (m *Module) validateFunctionWithMaxStackValues(
 						pc += num - 1
 					}
 
-					pc++
-					val, num, err := leb128.LoadUint32(body[pc:])
-					if err != nil {
-						return fmt.Errorf("failed to read memory index for %s: %v", MiscInstructionName(miscOpcode), err)
-					}
-					if val != 0 || num != 1 {
-						return fmt.Errorf("%s reserved byte must be zero encoded with 1 byte", MiscInstructionName(miscOpcode))
-					}
+					memIdxCount := 1
 					if miscOpcode == OpcodeMiscMemoryCopy {
+						// memory.copy needs two memory indexes: destination and source.
+						memIdxCount = 2
+					}
+					var addrTypes [2]ValueType
+					for j := 0; j < memIdxCount; j++ {
 						pc++
-						// memory.copy needs two memory index which are reserved as zero.
 						val, num, err := leb128.LoadUint32(body[pc:])
 						if err != nil {
 							return fmt.Errorf("failed to read memory index for %s: %v", MiscInstructionName(miscOpcode), err)
 						}
-						if val != 0 || num != 1 {
+						if multiMemory {
+							if val >= uint32(len(memories)) {
+								return fmt.Errorf("unknown memory %d for %s", val, MiscInstructionName(miscOpcode))
+							}
+							pc += num - 1
+						} else if val != 0 || num != 1 {
 							return fmt.Errorf("%s reserved byte must be zero encoded with 1 byte", MiscInstructionName(miscOpcode))
 						}
+						addrTypes[j] = memoryAddressType(memories[val])
+					}
+
+					// With memory64, the address operands (and the length if all the memories are 64-bit) are i64.
+					// Note that params are popped in order, so the last one is the first operand.
+					switch miscOpcode {
+					case OpcodeMiscMemoryInit:
+						params[2] = addrTypes[0]
+					case OpcodeMiscMemoryCopy:
+						params[2], params[1] = addrTypes[0], addrTypes[1]
+						if addrTypes[0] == ValueTypeI64 && addrTypes[1] == ValueTypeI64 {
+							params[0] = ValueTypeI64
+						}
+					case OpcodeMiscMemoryFill:
+						params[2], params[0] = addrTypes[0], addrTypes[0]
 					}
 
 				case OpcodeMiscTableInit:
@@ -1317,7 +1396,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 						return fmt.Errorf("table of index %d not found", tableIndex)
 					}
 
-					if !isRefSubtypeOf(m.ElementSection[elementIndex].Type, tables[tableIndex].Type) {
+					if !isRefSubtypeOf(m.TypeSection, m.ElementSection[elementIndex].Type, tables[tableIndex].Type) {
 						return fmt.Errorf("type mismatch for table.init: element type %s does not match table type %s",
 							RefTypeName(m.ElementSection[elementIndex].Type),
 							RefTypeName(tables[tableIndex].Type),
@@ -1364,7 +1443,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 						return fmt.Errorf("table of index %d not found", srcTableIndex)
 					}
 
-					if !isRefSubtypeOf(tables[srcTableIndex].Type, tables[dstTableIndex].Type) {
+					if !isRefSubtypeOf(m.TypeSection, tables[srcTableIndex].Type, tables[dstTableIndex].Type) {
 						return fmt.Errorf("table type mismatch for table.copy: %s (src) != %s (dst)",
 							RefTypeName(tables[srcTableIndex].Type), RefTypeName(tables[dstTableIndex].Type))
 					}
@@ -1417,6 +1496,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			pc++
 			// Vector instructions come with two bytes where the first byte is always OpcodeVecPrefix,
 			// and the second byte determines the actual instruction.
+			if relaxedOpcode, ok := RelaxedVectorOpcode(body[pc:]); ok {
+				// Relaxed SIMD opcodes are encoded in two bytes.
+				pc++
+				if err := validateRelaxedVectorInstruction(relaxedOpcode, valueTypeStack, enabledFeatures); err != nil {
+					return err
+				}
+				continue
+			}
 			vecOpcode := body[pc]
 			if err := enabledFeatures.RequireEnabled(api.CoreFeatureSIMD); err != nil {
 				return fmt.Errorf("%s invalid as %v", vectorInstructionName[vecOpcode], err)
@@ -1440,11 +1527,11 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				OpcodeVecV128Load32x2s, OpcodeVecV128Load32x2u, OpcodeVecV128Load8Splat, OpcodeVecV128Load16Splat,
 				OpcodeVecV128Load32Splat, OpcodeVecV128Load64Splat,
 				OpcodeVecV128Load32zero, OpcodeVecV128Load64zero:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, _, addrType, read, err := readMemArg(pc, body, memories, multiMemory)
 				if err != nil {
 					return err
 				}
@@ -1473,16 +1560,16 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > maxAlign {
 					return fmt.Errorf("invalid memory alignment %d for %s", align, VectorInstructionName(vecOpcode))
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", VectorInstructionName(vecOpcode), err)
 				}
 				valueTypeStack.push(ValueTypeV128)
 			case OpcodeVecV128Store:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, _, addrType, read, err := readMemArg(pc, body, memories, multiMemory)
 				if err != nil {
 					return err
 				}
@@ -1493,16 +1580,16 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeVecV128StoreName, err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeVecV128StoreName, err)
 				}
 			case OpcodeVecV128Load8Lane, OpcodeVecV128Load16Lane, OpcodeVecV128Load32Lane, OpcodeVecV128Load64Lane:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				attr := vecLoadLanes[vecOpcode]
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, _, addrType, read, err := readMemArg(pc, body, memories, multiMemory)
 				if err != nil {
 					return err
 				}
@@ -1520,17 +1607,17 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
 				valueTypeStack.push(ValueTypeV128)
 			case OpcodeVecV128Store8Lane, OpcodeVecV128Store16Lane, OpcodeVecV128Store32Lane, OpcodeVecV128Store64Lane:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				attr := vecStoreLanes[vecOpcode]
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				align, _, addrType, read, err := readMemArg(pc, body, memories, multiMemory)
 				if err != nil {
 					return err
 				}
@@ -1548,7 +1635,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
 			case OpcodeVecI8x16ExtractLaneS,
@@ -1754,10 +1841,10 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			}
 
 			// All atomic operations except fence (checked above) require memory
-			if memory == nil {
+			if len(memories) == 0 {
 				return fmt.Errorf("memory must exist for %s", AtomicInstructionName(atomicOpcode))
 			}
-			align, _, read, err := readMemArg(pc, body)
+			align, _, addrType, read, err := readMemArg(pc, body, memories, multiMemory)
 			if err != nil {
 				return err
 			}
@@ -1770,7 +1857,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1784,7 +1871,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1798,7 +1885,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1806,7 +1893,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1814,7 +1901,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 64/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1822,7 +1909,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align != 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1830,7 +1917,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align != 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1838,7 +1925,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align != 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1846,7 +1933,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1854,7 +1941,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1865,7 +1952,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI64Store:
@@ -1875,7 +1962,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI32Store8:
@@ -1885,7 +1972,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI32Store16:
@@ -1895,7 +1982,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI64Store8:
@@ -1905,7 +1992,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI64Store16:
@@ -1915,7 +2002,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI64Store32:
@@ -1925,7 +2012,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 			case OpcodeAtomicI32RmwAdd, OpcodeAtomicI32RmwSub, OpcodeAtomicI32RmwAnd, OpcodeAtomicI32RmwOr, OpcodeAtomicI32RmwXor, OpcodeAtomicI32RmwXchg:
@@ -1935,7 +2022,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1946,7 +2033,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1957,7 +2044,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -1968,7 +2055,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1979,7 +2066,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -1990,7 +2077,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -2001,7 +2088,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -2015,7 +2102,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -2029,7 +2116,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -2043,7 +2130,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -2057,7 +2144,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -2071,7 +2158,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -2085,7 +2172,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -2099,7 +2186,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addrType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -2230,6 +2317,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				return fmt.Errorf("invalid select: %v", err)
 			}
 
+			var selectType ValueType
 			if op == OpcodeTypedSelect {
 				if err := enabledFeatures.RequireEnabled(api.CoreFeatureReferenceTypes); err != nil {
 					return fmt.Errorf("%s is invalid as %w", InstructionName(op), err)
@@ -2256,6 +2344,16 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					case HeapTypeExn:
 						// ok
 					default:
+						if vt, ok := AbstractRefType(ht); ok {
+							if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+								return fmt.Errorf("%s for %s invalid as %v", ValueTypeName(vt), OpcodeTypedSelectName, err)
+							}
+							if b == RefPrefixNonNullable {
+								vt = vt.AsNonNullable()
+							}
+							selectType = vt
+							break
+						}
 						if ht < 0 {
 							return fmt.Errorf("invalid heap type for %s: %d", OpcodeTypedSelectName, ht)
 						}
@@ -2265,7 +2363,12 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					}
 				default:
 					tp := ValueType(b)
-					if tp != ValueTypeI32 && tp != ValueTypeI64 && tp != ValueTypeF32 && tp != ValueTypeF64 &&
+					if IsGCRefType(tp) {
+						if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+							return fmt.Errorf("%s for %s invalid as %v", ValueTypeName(tp), OpcodeTypedSelectName, err)
+						}
+						selectType = tp
+					} else if tp != ValueTypeI32 && tp != ValueTypeI64 && tp != ValueTypeF32 && tp != ValueTypeF64 &&
 						tp != ValueTypeExternref && tp != ValueTypeFuncref && tp != ValueTypeV128 {
 						return fmt.Errorf("invalid type %s for %s", ValueTypeName(tp), OpcodeTypedSelectName)
 					}
@@ -2274,14 +2377,23 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				return fmt.Errorf("reference types cannot be used for non typed select instruction")
 			}
 
-			if v1 != valueTypeUnknown && v2 != valueTypeUnknown && !areRefTypesCompatible(v1, v2) {
+			if selectType != 0 {
+				// Operands of a GC reference type may have no subtyping relation with each other,
+				// so they are checked against the declared type instead.
+				for _, v := range [...]ValueType{v1, v2} {
+					if v != valueTypeUnknown && !isRefSubtypeOf(m.TypeSection, v, selectType) {
+						return fmt.Errorf("type mismatch on %s operand: expected %s, but was %s",
+							OpcodeTypedSelectName, ValueTypeName(selectType), ValueTypeName(v))
+					}
+				}
+				valueTypeStack.push(selectType)
+			} else if v1 != valueTypeUnknown && v2 != valueTypeUnknown && !areRefTypesCompatible(m.TypeSection, v1, v2) {
 				return fmt.Errorf("type mismatch on 1st and 2nd select operands")
-			}
-			if v1 == valueTypeUnknown {
+			} else if v1 == valueTypeUnknown {
 				valueTypeStack.push(v2)
 			} else if v2 == valueTypeUnknown {
 				valueTypeStack.push(v1)
-			} else if isRefSubtypeOf(v1, v2) {
+			} else if isRefSubtypeOf(m.TypeSection, v1, v2) {
 				valueTypeStack.push(v2)
 			} else {
 				valueTypeStack.push(v1)
@@ -2372,8 +2484,9 @@ type stacks struct {
 	initLocals map[uint32]struct{}
 }
 
-func (sts *stacks) reset(functionType *FunctionType) {
+func (sts *stacks) reset(types []FunctionType, functionType *FunctionType) {
 	// Reset valueStack for reuse.
+	sts.vs.types = types
 	sts.vs.stack = sts.vs.stack[:0]
 	sts.vs.stackLimits = sts.vs.stackLimits[:0]
 	sts.vs.maximumStackPointer = 0
@@ -2413,6 +2526,442 @@ func (sts *stacks) validateReturnCall(
 	return sts.vs.requireStackValues(false, "", callerFuncType.Results, false)
 }
 
+// validateGCInstruction validates the GC instruction whose opcode starts at body[pc], and returns the
+// position of the last byte of the instruction.
+func (m *Module) validateGCInstruction(sts *stacks, enabledFeatures api.CoreFeatures, body []byte, pc uint64, tables []Table, br *bytes.Reader) (uint64, error) {
+	vs := &sts.vs
+	gcOp, num, err := leb128.LoadUint32(body[pc:])
+	if err != nil {
+		return 0, fmt.Errorf("failed to read gc opcode: %v", err)
+	}
+	pc += num - 1
+	name := GCInstructionName(gcOp)
+	if name == "" {
+		return 0, fmt.Errorf("invalid gc opcode: %#x", gcOp)
+	}
+	if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+		return 0, fmt.Errorf("%s invalid as %v", name, err)
+	}
+	m.UsesGC = true
+
+	readIndex := func(what string) (uint32, error) {
+		pc++
+		v, num, err := leb128.LoadUint32(body[pc:])
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s for %s: %v", what, name, err)
+		}
+		pc += num - 1
+		return v, nil
+	}
+	readType := func(kind CompositeKind) (Index, *FunctionType, error) {
+		typeIndex, err := readIndex("type index")
+		if err != nil {
+			return 0, nil, err
+		}
+		if typeIndex >= uint32(len(m.TypeSection)) {
+			return 0, nil, fmt.Errorf("unknown type %d for %s", typeIndex, name)
+		}
+		tp := &m.TypeSection[typeIndex]
+		if tp.Kind != kind {
+			return 0, nil, fmt.Errorf("type %d is not a %s type for %s", typeIndex, compositeKindName(kind), name)
+		}
+		return typeIndex, tp, nil
+	}
+	readHeapType := func(nullable bool) (ValueType, error) {
+		br.Reset(body[pc+1:])
+		ht, num, err := leb128.DecodeInt33AsInt64(br)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read heap type for %s: %v", name, err)
+		}
+		pc += num
+		vt, ok := AbstractRefType(ht)
+		if !ok {
+			if ht < 0 || ht >= int64(len(m.TypeSection)) {
+				return 0, fmt.Errorf("unknown heap type %d for %s", ht, name)
+			}
+			return ValueTypeConcreteRef(uint32(ht), nullable), nil
+		}
+		if !nullable {
+			vt = vt.AsNonNullable()
+		}
+		return vt, nil
+	}
+	popOperand := func(vt ValueType) error {
+		if err := vs.popAndVerifyType(vt); err != nil {
+			return fmt.Errorf("cannot pop the operand for %s: %v", name, err)
+		}
+		return nil
+	}
+	popI32s := func(n int) error {
+		for i := 0; i < n; i++ {
+			if err := popOperand(ValueTypeI32); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch gcOp {
+	case OpcodeGCStructNew, OpcodeGCStructNewDefault:
+		typeIndex, tp, err := readType(CompositeKindStruct)
+		if err != nil {
+			return 0, err
+		}
+		for i := len(tp.Fields) - 1; i >= 0; i-- {
+			if gcOp == OpcodeGCStructNewDefault {
+				if !isDefaultable(tp.Fields[i].Type) {
+					return 0, fmt.Errorf("field %d of type %d is not defaultable for %s", i, typeIndex, name)
+				}
+			} else if err = popOperand(tp.Fields[i].Unpacked()); err != nil {
+				return 0, err
+			}
+		}
+		vs.push(ValueTypeConcreteRef(typeIndex, false))
+	case OpcodeGCStructGet, OpcodeGCStructGetS, OpcodeGCStructGetU, OpcodeGCStructSet:
+		typeIndex, tp, err := readType(CompositeKindStruct)
+		if err != nil {
+			return 0, err
+		}
+		fieldIndex, err := readIndex("field index")
+		if err != nil {
+			return 0, err
+		}
+		if fieldIndex >= uint32(len(tp.Fields)) {
+			return 0, fmt.Errorf("unknown field %d of type %d for %s", fieldIndex, typeIndex, name)
+		}
+		field := tp.Fields[fieldIndex]
+		if gcOp == OpcodeGCStructSet {
+			if !field.Mutable {
+				return 0, fmt.Errorf("field %d of type %d is immutable for %s", fieldIndex, typeIndex, name)
+			}
+			if err = popOperand(field.Unpacked()); err != nil {
+				return 0, err
+			}
+		} else if (gcOp == OpcodeGCStructGet) == field.IsPacked() {
+			return 0, fmt.Errorf("field %d of type %d is %s for %s", fieldIndex, typeIndex, packedness(field), name)
+		}
+		if err = popOperand(ValueTypeConcreteRef(typeIndex, true)); err != nil {
+			return 0, err
+		}
+		if gcOp != OpcodeGCStructSet {
+			vs.push(field.Unpacked())
+		}
+	case OpcodeGCArrayNew, OpcodeGCArrayNewDefault, OpcodeGCArrayNewFixed:
+		typeIndex, tp, err := readType(CompositeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		elem := tp.Fields[0]
+		switch gcOp {
+		case OpcodeGCArrayNew:
+			if err = popI32s(1); err != nil {
+				return 0, err
+			}
+			if err = popOperand(elem.Unpacked()); err != nil {
+				return 0, err
+			}
+		case OpcodeGCArrayNewDefault:
+			if !isDefaultable(elem.Type) {
+				return 0, fmt.Errorf("element of type %d is not defaultable for %s", typeIndex, name)
+			}
+			if err = popI32s(1); err != nil {
+				return 0, err
+			}
+		case OpcodeGCArrayNewFixed:
+			n, err := readIndex("size")
+			if err != nil {
+				return 0, err
+			}
+			if n > maximumValuesOnStack {
+				return 0, fmt.Errorf("size %d too large for %s", n, name)
+			}
+			for i := uint32(0); i < n; i++ {
+				if err = popOperand(elem.Unpacked()); err != nil {
+					return 0, err
+				}
+			}
+		}
+		vs.push(ValueTypeConcreteRef(typeIndex, false))
+	case OpcodeGCArrayNewData, OpcodeGCArrayInitData:
+		typeIndex, tp, err := readType(CompositeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		dataIndex, err := readIndex("data index")
+		if err != nil {
+			return 0, err
+		}
+		if m.DataCountSection == nil {
+			return 0, fmt.Errorf("%s requires data count section", name)
+		} else if dataIndex >= uint32(len(m.DataSection)) {
+			return 0, fmt.Errorf("index %d out of range of data section(len=%d)", dataIndex, len(m.DataSection))
+		}
+		elem := tp.Fields[0]
+		if elem.Type.IsRef() {
+			return 0, fmt.Errorf("element of type %d is a reference for %s", typeIndex, name)
+		}
+		if gcOp == OpcodeGCArrayNewData {
+			if err = popI32s(2); err != nil {
+				return 0, err
+			}
+			vs.push(ValueTypeConcreteRef(typeIndex, false))
+			break
+		}
+		if !elem.Mutable {
+			return 0, fmt.Errorf("array type %d is immutable for %s", typeIndex, name)
+		}
+		if err = popI32s(3); err != nil {
+			return 0, err
+		}
+		if err = popOperand(ValueTypeConcreteRef(typeIndex, true)); err != nil {
+			return 0, err
+		}
+	case OpcodeGCArrayNewElem, OpcodeGCArrayInitElem:
+		typeIndex, tp, err := readType(CompositeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		elemIndex, err := readIndex("element index")
+		if err != nil {
+			return 0, err
+		}
+		if elemIndex >= uint32(len(m.ElementSection)) {
+			return 0, fmt.Errorf("index %d out of range of element section(len=%d)", elemIndex, len(m.ElementSection))
+		}
+		elem := tp.Fields[0]
+		if segType := m.ElementSection[elemIndex].Type; !isRefSubtypeOf(m.TypeSection, segType, elem.Type) {
+			return 0, fmt.Errorf("element segment %d of %s does not match %s for %s",
+				elemIndex, ValueTypeName(segType), ValueTypeName(elem.Type), name)
+		}
+		if gcOp == OpcodeGCArrayNewElem {
+			if err = popI32s(2); err != nil {
+				return 0, err
+			}
+			vs.push(ValueTypeConcreteRef(typeIndex, false))
+			break
+		}
+		if !elem.Mutable {
+			return 0, fmt.Errorf("array type %d is immutable for %s", typeIndex, name)
+		}
+		if err = popI32s(3); err != nil {
+			return 0, err
+		}
+		if err = popOperand(ValueTypeConcreteRef(typeIndex, true)); err != nil {
+			return 0, err
+		}
+	case OpcodeGCArrayGet, OpcodeGCArrayGetS, OpcodeGCArrayGetU, OpcodeGCArraySet, OpcodeGCArrayFill:
+		typeIndex, tp, err := readType(CompositeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		elem := tp.Fields[0]
+		switch gcOp {
+		case OpcodeGCArraySet, OpcodeGCArrayFill:
+			if !elem.Mutable {
+				return 0, fmt.Errorf("array type %d is immutable for %s", typeIndex, name)
+			}
+			if gcOp == OpcodeGCArrayFill {
+				if err = popI32s(1); err != nil {
+					return 0, err
+				}
+			}
+			if err = popOperand(elem.Unpacked()); err != nil {
+				return 0, err
+			}
+		default:
+			if (gcOp == OpcodeGCArrayGet) == elem.IsPacked() {
+				return 0, fmt.Errorf("element of type %d is %s for %s", typeIndex, packedness(elem), name)
+			}
+		}
+		if err = popI32s(1); err != nil {
+			return 0, err
+		}
+		if err = popOperand(ValueTypeConcreteRef(typeIndex, true)); err != nil {
+			return 0, err
+		}
+		if gcOp != OpcodeGCArraySet && gcOp != OpcodeGCArrayFill {
+			vs.push(elem.Unpacked())
+		}
+	case OpcodeGCArrayLen:
+		if err = popOperand(ValueTypeArrayref); err != nil {
+			return 0, err
+		}
+		vs.push(ValueTypeI32)
+	case OpcodeGCArrayCopy:
+		dstIndex, dst, err := readType(CompositeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		srcIndex, src, err := readType(CompositeKindArray)
+		if err != nil {
+			return 0, err
+		}
+		if !dst.Fields[0].Mutable {
+			return 0, fmt.Errorf("array type %d is immutable for %s", dstIndex, name)
+		}
+		if !isRefSubtypeOf(m.TypeSection, src.Fields[0].Type, dst.Fields[0].Type) {
+			return 0, fmt.Errorf("element of type %d does not match the one of type %d for %s", srcIndex, dstIndex, name)
+		}
+		if err = popI32s(2); err != nil {
+			return 0, err
+		}
+		if err = popOperand(ValueTypeConcreteRef(srcIndex, true)); err != nil {
+			return 0, err
+		}
+		if err = popI32s(1); err != nil {
+			return 0, err
+		}
+		if err = popOperand(ValueTypeConcreteRef(dstIndex, true)); err != nil {
+			return 0, err
+		}
+	case OpcodeGCRefTest, OpcodeGCRefTestNull, OpcodeGCRefCast, OpcodeGCRefCastNull:
+		target, err := readHeapType(gcOp == OpcodeGCRefTestNull || gcOp == OpcodeGCRefCastNull)
+		if err != nil {
+			return 0, err
+		}
+		tp, err := vs.pop()
+		if err != nil {
+			return 0, fmt.Errorf("cannot pop the operand for %s: %v", name, err)
+		}
+		if tp != valueTypeUnknown && (!tp.IsRef() || topRefType(m.TypeSection, tp) != topRefType(m.TypeSection, target)) {
+			return 0, fmt.Errorf("type mismatch: cannot cast %s to %s for %s", ValueTypeName(tp), ValueTypeName(target), name)
+		}
+		if gcOp == OpcodeGCRefTest || gcOp == OpcodeGCRefTestNull {
+			vs.push(ValueTypeI32)
+		} else {
+			vs.push(target)
+		}
+	case OpcodeGCBrOnCast, OpcodeGCBrOnCastFail:
+		pc++
+		flags := body[pc]
+		if flags > 3 {
+			return 0, fmt.Errorf("invalid flags %#x for %s", flags, name)
+		}
+		labelIndex, err := readIndex("label index")
+		if err != nil {
+			return 0, err
+		} else if int(labelIndex) >= len(sts.cs.stack) {
+			return 0, fmt.Errorf("invalid %s operation: index out of range", name)
+		}
+		from, err := readHeapType(flags&1 != 0)
+		if err != nil {
+			return 0, err
+		}
+		to, err := readHeapType(flags&2 != 0)
+		if err != nil {
+			return 0, err
+		}
+		if !isRefSubtypeOf(m.TypeSection, to, from) {
+			return 0, fmt.Errorf("type mismatch: %s is not a subtype of %s for %s", ValueTypeName(to), ValueTypeName(from), name)
+		}
+		// The value which failed the cast to the non-nullable type is never null.
+		diff := from
+		if to.IsNullable() {
+			diff = from.AsNonNullable()
+		}
+		branched, unbranched := to, diff
+		if gcOp == OpcodeGCBrOnCastFail {
+			branched, unbranched = diff, to
+		}
+
+		if err = popOperand(from); err != nil {
+			return 0, err
+		}
+		target := &sts.cs.stack[len(sts.cs.stack)-int(labelIndex)-1]
+		labelTypes := target.blockType.Results
+		if target.op == OpcodeLoop {
+			labelTypes = target.blockType.Params
+		}
+		if len(labelTypes) == 0 {
+			return 0, fmt.Errorf("type mismatch on %s: label has no results but needs a ref type", name)
+		}
+		last := labelTypes[len(labelTypes)-1]
+		if !isRefSubtypeOf(m.TypeSection, branched, last) {
+			return 0, fmt.Errorf("type mismatch on %s: ref type %s is not a subtype of label's last result %s",
+				name, ValueTypeName(branched), ValueTypeName(last))
+		}
+		remaining := labelTypes[:len(labelTypes)-1]
+		if err = vs.requireStackValues(false, name, remaining, false); err != nil {
+			return 0, err
+		}
+		for _, t := range remaining {
+			vs.push(t)
+		}
+		vs.push(unbranched)
+	case OpcodeGCAnyConvertExtern, OpcodeGCExternConvertAny:
+		from, to := ValueTypeExternref, ValueTypeAnyref
+		if gcOp == OpcodeGCExternConvertAny {
+			from, to = to, from
+		}
+		tp, err := vs.pop()
+		if err != nil {
+			return 0, fmt.Errorf("cannot pop the operand for %s: %v", name, err)
+		}
+		if tp != valueTypeUnknown && !isRefSubtypeOf(m.TypeSection, tp, from) {
+			return 0, fmt.Errorf("type mismatch: expected %s, but was %s for %s", ValueTypeName(from), ValueTypeName(tp), name)
+		}
+		if tp != valueTypeUnknown && !tp.IsNullable() {
+			to = to.AsNonNullable()
+		}
+		vs.push(to)
+	case OpcodeGCRefI31:
+		if err = popI32s(1); err != nil {
+			return 0, err
+		}
+		vs.push(ValueTypeI31ref.AsNonNullable())
+	case OpcodeGCI31GetS, OpcodeGCI31GetU:
+		if err = popOperand(ValueTypeI31ref); err != nil {
+			return 0, err
+		}
+		vs.push(ValueTypeI32)
+	}
+	return pc, nil
+}
+
+// isDefaultable returns true if values of the storage type t have a default value, which is
+// the case for numeric, vector and nullable reference types.
+func isDefaultable(t ValueType) bool {
+	return !t.IsRef() || t.IsNullable()
+}
+
+// topRefType returns the top type of the type hierarchy the reference type t belongs to, i.e.
+// anyref, funcref, externref or exnref.
+func topRefType(types []FunctionType, t ValueType) ValueType {
+	if t.IsConcreteRef() {
+		if concreteKind(types, t.TypeIndex()) == CompositeKindFunc {
+			return ValueTypeFuncref
+		}
+		return ValueTypeAnyref
+	}
+	switch ValueType(t.Kind()) {
+	case ValueTypeFuncref, ValueTypeNullfuncref:
+		return ValueTypeFuncref
+	case ValueTypeExternref, ValueTypeNullexternref:
+		return ValueTypeExternref
+	case ValueTypeExnref, ValueTypeNullexnref:
+		return ValueTypeExnref
+	default:
+		return ValueTypeAnyref
+	}
+}
+
+func compositeKindName(k CompositeKind) string {
+	switch k {
+	case CompositeKindStruct:
+		return "struct"
+	case CompositeKindArray:
+		return "array"
+	}
+	return "func"
+}
+
+func packedness(f FieldType) string {
+	if f.IsPacked() {
+		return "packed"
+	}
+	return "not packed"
+}
+
 type controlBlockStack struct {
 	stack []controlBlock
 }
@@ -2456,12 +3005,49 @@ func (s *controlBlockStack) push(startAt, elseAt, endAt uint64, blockType *Funct
 	})
 }
 
+// validateRelaxedVectorInstruction validates the relaxed SIMD instruction relaxedOpcode against valueTypeStack.
+func validateRelaxedVectorInstruction(relaxedOpcode OpcodeVecRelaxed, valueTypeStack *valueTypeStack, enabledFeatures api.CoreFeatures) error {
+	name, ok := relaxedVectorInstructionName[relaxedOpcode]
+	if !ok {
+		return fmt.Errorf("unknown SIMD instruction %#x", relaxedOpcode)
+	}
+	if err := enabledFeatures.RequireEnabled(api.CoreFeatureSIMD); err != nil {
+		return fmt.Errorf("%s invalid as %v", name, err)
+	}
+	if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesRelaxedSIMD); err != nil {
+		return fmt.Errorf("%s invalid as %v", name, err)
+	}
+
+	var operands int
+	switch relaxedOpcode {
+	case OpcodeVecI32x4RelaxedTruncF32x4S, OpcodeVecI32x4RelaxedTruncF32x4U,
+		OpcodeVecI32x4RelaxedTruncF64x2SZero, OpcodeVecI32x4RelaxedTruncF64x2UZero:
+		operands = 1
+	case OpcodeVecI8x16RelaxedSwizzle,
+		OpcodeVecF32x4RelaxedMin, OpcodeVecF32x4RelaxedMax, OpcodeVecF64x2RelaxedMin, OpcodeVecF64x2RelaxedMax,
+		OpcodeVecI16x8RelaxedQ15mulrS, OpcodeVecI16x8RelaxedDotI8x16I7x16S:
+		operands = 2
+	default:
+		// madd, nmadd, laneselect and dot_add take three operands.
+		operands = 3
+	}
+	for i := 0; i < operands; i++ {
+		if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
+			return fmt.Errorf("cannot pop the operand for %s: %v", name, err)
+		}
+	}
+	valueTypeStack.push(ValueTypeV128)
+	return nil
+}
+
 type valueTypeStack struct {
 	stack               []ValueType
 	stackLimits         []int
 	maximumStackPointer int
 	// requireStackValuesTmp is used in requireStackValues function to reduce the allocation.
 	requireStackValuesTmp []ValueType
+	// types are the types of the module, which resolve concrete reference types on subtyping.
+	types []FunctionType
 }
 
 // Only used in the analyzeFunction below.
@@ -2500,7 +3086,7 @@ func (s *valueTypeStack) popAndVerifyType(expected ValueType) error {
 	if !ok {
 		return fmt.Errorf("%s missing", ValueTypeName(expected))
 	}
-	if have != valueTypeUnknown && expected != valueTypeUnknown && !isRefSubtypeOf(have, expected) {
+	if have != valueTypeUnknown && expected != valueTypeUnknown && !isRefSubtypeOf(s.types, have, expected) {
 		return fmt.Errorf("type mismatch: expected %s, but was %s", ValueTypeName(expected), ValueTypeName(have))
 	}
 	return nil
@@ -2582,7 +3168,7 @@ func (s *valueTypeStack) requireStackValues(
 	// Finally, check the types of the values:
 	for i, v := range s.requireStackValuesTmp {
 		nextWant := want[countWanted-i-1] // have is in reverse order (stack)
-		if v != valueTypeUnknown && nextWant != valueTypeUnknown && !isRefSubtypeOf(v, nextWant) {
+		if v != valueTypeUnknown && nextWant != valueTypeUnknown && !isRefSubtypeOf(s.types, v, nextWant) {
 			return typeMismatchError(isParam, context, v, nextWant, i)
 		}
 	}
@@ -2729,6 +3315,10 @@ func DecodeBlockType(types []FunctionType, r *bytes.Reader, enabledFeatures api.
 		case HeapTypeExtern:
 			ret = blockType_v_externref
 		default:
+			if vt, ok := AbstractRefType(ht); ok {
+				ret = &FunctionType{Results: []ValueType{vt}, ResultNumInUint64: 1}
+				break
+			}
 			if ht < 0 {
 				return nil, 0, fmt.Errorf("unknown abstract heap type in block: %d", ht)
 			}
@@ -2752,6 +3342,10 @@ func DecodeBlockType(types []FunctionType, r *bytes.Reader, enabledFeatures api.
 		case HeapTypeExtern:
 			ret = &FunctionType{Results: []ValueType{ValueTypeExternref.AsNonNullable()}, ResultNumInUint64: 1}
 		default:
+			if vt, ok := AbstractRefType(ht); ok {
+				ret = &FunctionType{Results: []ValueType{vt.AsNonNullable()}, ResultNumInUint64: 1}
+				break
+			}
 			if ht < 0 {
 				return nil, 0, fmt.Errorf("unknown abstract heap type in block: %d", ht)
 			}
@@ -2762,6 +3356,11 @@ func DecodeBlockType(types []FunctionType, r *bytes.Reader, enabledFeatures api.
 			ret = &FunctionType{Results: []ValueType{vt}, ResultNumInUint64: 1}
 		}
 	default:
+		if vt, ok := AbstractRefType(raw); ok {
+			// Short forms of the abstract reference types of the GC proposal, e.g. 0x6e = anyref.
+			ret = &FunctionType{Results: []ValueType{vt}, ResultNumInUint64: 1}
+			break
+		}
 		if err = enabledFeatures.RequireEnabled(api.CoreFeatureMultiValue); err != nil {
 			return nil, num, fmt.Errorf("block with function type return invalid as %v", err)
 		}
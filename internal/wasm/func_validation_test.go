@@ -271,6 +271,186 @@ func TestModule_ValidateFunction_MultiValue(t *testing.T) {
 	}
 }
 
+func TestModule_ValidateFunction_MultiMemory(t *testing.T) {
+	const features = api.CoreFeaturesV2 | experimental.CoreFeaturesMultiMemory
+	memories := []*Memory{{}, {}}
+	tests := []struct {
+		name        string
+		body        []byte
+		expectedErr string
+	}{
+		{
+			name: "load with memory index",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, MemArgMemoryIndexFlag | 0x2, 1, 0,
+				OpcodeDrop, OpcodeEnd,
+			},
+		},
+		{
+			name: "load with unknown memory index",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, MemArgMemoryIndexFlag | 0x2, 2, 0,
+				OpcodeDrop, OpcodeEnd,
+			},
+			expectedErr: "unknown memory 2",
+		},
+		{
+			name: "load with invalid alignment",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, MemArgMemoryIndexFlag | 0x3, 1, 0,
+				OpcodeDrop, OpcodeEnd,
+			},
+			expectedErr: "invalid memory alignment",
+		},
+		{
+			name: "memory.size",
+			body: []byte{OpcodeMemorySize, 1, OpcodeDrop, OpcodeEnd},
+		},
+		{
+			name: "memory.size with multi-byte memory index",
+			body: []byte{OpcodeMemorySize, 0x81, 0x00, OpcodeDrop, OpcodeEnd},
+		},
+		{
+			name:        "memory.grow with unknown memory index",
+			body:        []byte{OpcodeI32Const, 1, OpcodeMemoryGrow, 2, OpcodeDrop, OpcodeEnd},
+			expectedErr: "unknown memory 2 for memory.grow",
+		},
+		{
+			name: "memory.copy",
+			body: []byte{
+				OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 1, 0,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "memory.copy with unknown memory index",
+			body: []byte{
+				OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 2,
+				OpcodeEnd,
+			},
+			expectedErr: "unknown memory 2 for memory.copy",
+		},
+		{
+			name: "memory.fill",
+			body: []byte{
+				OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryFill, 1,
+				OpcodeEnd,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []FunctionType{v_v},
+				FunctionSection: []Index{0},
+				CodeSection:     []Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(&stacks{}, features,
+				0, []Index{0}, nil, memories, nil, nil, nil, bytes.NewReader(nil))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModule_ValidateFunction_Memory64(t *testing.T) {
+	const features = api.CoreFeaturesV2 | experimental.CoreFeaturesMultiMemory | experimental.CoreFeaturesMemory64
+	memories := []*Memory{{Is64: true}, {}}
+	tests := []struct {
+		name        string
+		body        []byte
+		expectedErr string
+	}{
+		{
+			name: "load with i64 address",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI32Load, 0x2, 0,
+				OpcodeDrop, OpcodeEnd,
+			},
+		},
+		{
+			name: "load with i32 address",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, 0x2, 0,
+				OpcodeDrop, OpcodeEnd,
+			},
+			expectedErr: "type mismatch: expected i64, but was i32",
+		},
+		{
+			name: "load with 64-bit offset",
+			body: []byte{
+				OpcodeI64Const, 0,
+				OpcodeI32Load, 0x2, 0x80, 0x80, 0x80, 0x80, 0x10,
+				OpcodeDrop, OpcodeEnd,
+			},
+		},
+		{
+			name: "load with 64-bit offset on 32-bit memory",
+			body: []byte{
+				OpcodeI32Const, 0,
+				OpcodeI32Load, MemArgMemoryIndexFlag | 0x2, 1, 0x80, 0x80, 0x80, 0x80, 0x10,
+				OpcodeDrop, OpcodeEnd,
+			},
+			expectedErr: "read memory offset: overflows a 32-bit integer",
+		},
+		{
+			name: "memory.size",
+			body: []byte{OpcodeMemorySize, 0, OpcodeI64Eqz, OpcodeDrop, OpcodeEnd},
+		},
+		{
+			name: "memory.grow",
+			body: []byte{OpcodeI64Const, 1, OpcodeMemoryGrow, 0, OpcodeI64Eqz, OpcodeDrop, OpcodeEnd},
+		},
+		{
+			name: "memory.copy between 32-bit and 64-bit memories",
+			body: []byte{
+				OpcodeI64Const, 0, OpcodeI32Const, 0, OpcodeI32Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1,
+				OpcodeEnd,
+			},
+		},
+		{
+			name: "memory.fill",
+			body: []byte{
+				OpcodeI64Const, 0, OpcodeI32Const, 0, OpcodeI64Const, 0,
+				OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0,
+				OpcodeEnd,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     []FunctionType{v_v},
+				FunctionSection: []Index{0},
+				CodeSection:     []Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(&stacks{}, features,
+				0, []Index{0}, nil, memories, nil, nil, nil, bytes.NewReader(nil))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
 		for _, op := range []OpcodeMisc{
@@ -302,7 +482,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 					DataCountSection: &c,
 				}
 				err := m.validateFunction(&stacks{}, api.CoreFeatureBulkMemoryOperations,
-					0, []Index{0}, nil, &Memory{}, []Table{{}, {}}, nil, nil, bytes.NewReader(nil))
+					0, []Index{0}, nil, []*Memory{{}}, []Table{{}, {}}, nil, nil, bytes.NewReader(nil))
 				require.NoError(t, err)
 			})
 		}
@@ -313,7 +493,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			dataSection         []DataSegment
 			elementSection      []ElementSegment
 			dataCountSectionNil bool
-			memory              *Memory
+			memories            []*Memory
 			tables              []Table
 			flag                api.CoreFeatures
 			expectedErr         string
@@ -322,7 +502,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      nil,
+				memories:    nil,
 				expectedErr: "memory must exist for memory.init",
 			},
 			{
@@ -339,48 +519,48 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "failed to read data segment index for memory.init: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 100 /* data section out of range */},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "index 100 out of range of data section(len=1)",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "failed to read memory index for memory.init: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "memory.init reserved byte must be zero encoded with 1 byte",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
@@ -393,20 +573,20 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:                []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop},
 				dataCountSectionNil: true,
-				memory:              &Memory{},
+				memories:            []*Memory{{}},
 				flag:                api.CoreFeatureBulkMemoryOperations,
 				expectedErr:         `data.drop requires data count section`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "failed to read data segment index for data.drop: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop, 100 /* data section out of range */},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "index 100 out of range of data section(len=1)",
 			},
@@ -414,7 +594,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      nil,
+				memories:    nil,
 				expectedErr: "memory must exist for memory.copy",
 			},
 			{
@@ -425,44 +605,44 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: `failed to read memory index for memory.copy: EOF`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "failed to read memory index for memory.copy: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "memory.copy reserved byte must be zero encoded with 1 byte",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			// memory.fill
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      nil,
+				memories:    nil,
 				expectedErr: "memory must exist for memory.fill",
 			},
 			{
@@ -473,31 +653,31 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: `failed to read memory index for memory.fill: EOF`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: `memory.fill reserved byte must be zero encoded with 1 byte`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memories:    []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			// table.init
@@ -666,7 +846,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 					c := uint32(0)
 					m.DataCountSection = &c
 				}
-				err := m.validateFunction(&stacks{}, tc.flag, 0, []Index{0}, nil, tc.memory, tc.tables, nil, nil, bytes.NewReader(nil))
+				err := m.validateFunction(&stacks{}, tc.flag, 0, []Index{0}, nil, tc.memories, tc.tables, nil, nil, bytes.NewReader(nil))
 				require.EqualError(t, err, tc.expectedErr)
 			})
 		}
@@ -2221,7 +2401,7 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 			}}},
 		}
 		err := m.validateFunction(&stacks{}, api.CoreFeatureReferenceTypes,
-			0, []Index{0}, nil, &Memory{}, []Table{{Type: RefTypeFuncref}}, nil, nil, bytes.NewReader(nil))
+			0, []Index{0}, nil, []*Memory{{}}, []Table{{Type: RefTypeFuncref}}, nil, nil, bytes.NewReader(nil))
 		require.NoError(t, err)
 	})
 	t.Run("non zero table index", func(t *testing.T) {
@@ -2236,12 +2416,12 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 		}
 		t.Run("disabled", func(t *testing.T) {
 			err := m.validateFunction(&stacks{}, api.CoreFeaturesV1,
-				0, []Index{0}, nil, &Memory{}, []Table{{}, {}}, nil, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, []Table{{}, {}}, nil, nil, bytes.NewReader(nil))
 			require.EqualError(t, err, "table index must be zero but was 100: feature \"reference-types\" is disabled")
 		})
 		t.Run("enabled but out of range", func(t *testing.T) {
 			err := m.validateFunction(&stacks{}, api.CoreFeatureReferenceTypes,
-				0, []Index{0}, nil, &Memory{}, []Table{{}, {}}, nil, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, []Table{{}, {}}, nil, nil, bytes.NewReader(nil))
 			require.EqualError(t, err, "unknown table index: 100")
 		})
 	})
@@ -2256,7 +2436,7 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 			}}},
 		}
 		err := m.validateFunction(&stacks{}, api.CoreFeatureReferenceTypes,
-			0, []Index{0}, nil, &Memory{}, []Table{{Type: RefTypeExternref}}, nil, nil, bytes.NewReader(nil))
+			0, []Index{0}, nil, []*Memory{{}}, []Table{{Type: RefTypeExternref}}, nil, nil, bytes.NewReader(nil))
 		require.EqualError(t, err, "table is not funcref type but was externref for call_indirect")
 	})
 }
@@ -3195,7 +3375,7 @@ func TestModule_funcValidation_SIMD(t *testing.T) {
 				CodeSection:     []Code{{Body: tc.body}},
 			}
 			err := m.validateFunction(&stacks{}, api.CoreFeatureSIMD,
-				0, []Index{0}, nil, &Memory{}, nil, nil, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, nil, nil, nil, bytes.NewReader(nil))
 			require.NoError(t, err)
 		})
 	}
@@ -3341,12 +3521,169 @@ func TestModule_funcValidation_SIMD_error(t *testing.T) {
 				CodeSection:     []Code{{Body: tc.body}},
 			}
 			err := m.validateFunction(&stacks{}, tc.flag,
-				0, []Index{0}, nil, &Memory{}, nil, nil, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, nil, nil, nil, bytes.NewReader(nil))
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
 }
 
+func TestModule_funcValidation_RelaxedSIMD(t *testing.T) {
+	relaxedSIMD := api.CoreFeatureSIMD | experimental.CoreFeaturesRelaxedSIMD
+	body := func(op OpcodeVecRelaxed, operands int) (ret []byte) {
+		for i := 0; i < operands; i++ {
+			ret = append(ret, OpcodeVecPrefix,
+				OpcodeVecV128Const,
+				1, 1, 1, 1, 1, 1, 1, 1,
+				1, 1, 1, 1, 1, 1, 1, 1)
+		}
+		// Relaxed SIMD opcodes are LEB128 encoded in two bytes.
+		return append(ret, OpcodeVecPrefix, byte(op&0x7f|0x80), byte(op>>7), OpcodeDrop, OpcodeEnd)
+	}
+
+	for _, tc := range []struct {
+		op       OpcodeVecRelaxed
+		operands int
+	}{
+		{op: OpcodeVecI8x16RelaxedSwizzle, operands: 2},
+		{op: OpcodeVecI32x4RelaxedTruncF32x4S, operands: 1},
+		{op: OpcodeVecI32x4RelaxedTruncF32x4U, operands: 1},
+		{op: OpcodeVecI32x4RelaxedTruncF64x2SZero, operands: 1},
+		{op: OpcodeVecI32x4RelaxedTruncF64x2UZero, operands: 1},
+		{op: OpcodeVecF32x4RelaxedMadd, operands: 3},
+		{op: OpcodeVecF32x4RelaxedNmadd, operands: 3},
+		{op: OpcodeVecF64x2RelaxedMadd, operands: 3},
+		{op: OpcodeVecF64x2RelaxedNmadd, operands: 3},
+		{op: OpcodeVecI8x16RelaxedLaneselect, operands: 3},
+		{op: OpcodeVecI16x8RelaxedLaneselect, operands: 3},
+		{op: OpcodeVecI32x4RelaxedLaneselect, operands: 3},
+		{op: OpcodeVecI64x2RelaxedLaneselect, operands: 3},
+		{op: OpcodeVecF32x4RelaxedMin, operands: 2},
+		{op: OpcodeVecF32x4RelaxedMax, operands: 2},
+		{op: OpcodeVecF64x2RelaxedMin, operands: 2},
+		{op: OpcodeVecF64x2RelaxedMax, operands: 2},
+		{op: OpcodeVecI16x8RelaxedQ15mulrS, operands: 2},
+		{op: OpcodeVecI16x8RelaxedDotI8x16I7x16S, operands: 2},
+		{op: OpcodeVecI32x4RelaxedDotI8x16I7x16AddS, operands: 3},
+	} {
+		tc := tc
+		name := RelaxedVectorInstructionName(tc.op)
+		t.Run(name, func(t *testing.T) {
+			validate := func(body []byte, flag api.CoreFeatures) error {
+				m := &Module{
+					TypeSection:     []FunctionType{v_v},
+					FunctionSection: []Index{0},
+					CodeSection:     []Code{{Body: body}},
+				}
+				return m.validateFunction(&stacks{}, flag,
+					0, []Index{0}, nil, nil, nil, nil, nil, bytes.NewReader(nil))
+			}
+
+			require.NoError(t, validate(body(tc.op, tc.operands), relaxedSIMD))
+
+			err := validate(body(tc.op, tc.operands), api.CoreFeatureSIMD)
+			require.EqualError(t, err, name+" invalid as feature \"relaxed-simd\" is disabled")
+
+			err = validate(body(tc.op, tc.operands-1), relaxedSIMD)
+			require.Contains(t, err.Error(), "cannot pop the operand for "+name)
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		m := &Module{
+			TypeSection:     []FunctionType{v_v},
+			FunctionSection: []Index{0},
+			CodeSection:     []Code{{Body: []byte{OpcodeVecPrefix, 0xff, 0x02, OpcodeEnd}}},
+		}
+		err := m.validateFunction(&stacks{}, relaxedSIMD,
+			0, []Index{0}, nil, nil, nil, nil, nil, bytes.NewReader(nil))
+		require.EqualError(t, err, "unknown SIMD instruction 0x17f")
+	})
+}
+
+func TestModule_funcValidation_GC(t *testing.T) {
+	gc := api.CoreFeaturesV2 | experimental.CoreFeaturesGC
+	types := []FunctionType{
+		v_v,
+		{Kind: CompositeKindStruct, Fields: []FieldType{{Type: ValueTypeI32}, {Type: ValueTypeI8, Mutable: true}}},
+		{Kind: CompositeKindArray, Fields: []FieldType{{Type: ValueTypeI64, Mutable: true}}},
+		{Kind: CompositeKindArray, Fields: []FieldType{{Type: ValueTypeConcreteRef(1, false)}}},
+	}
+
+	for _, tc := range []struct {
+		name        string
+		body        []byte
+		expectedErr string
+	}{
+		{
+			name: "struct.new_default and struct.get_s",
+			body: []byte{OpcodeGCPrefix, byte(OpcodeGCStructNewDefault), 1, OpcodeGCPrefix, byte(OpcodeGCStructGetS), 1, 1, OpcodeDrop, OpcodeEnd},
+		},
+		{
+			name: "array.new_fixed and array.len",
+			body: []byte{
+				OpcodeI64Const, 1, OpcodeI64Const, 2, OpcodeGCPrefix, byte(OpcodeGCArrayNewFixed), 2, 2,
+				OpcodeGCPrefix, byte(OpcodeGCArrayLen), OpcodeDrop, OpcodeEnd,
+			},
+		},
+		{
+			name: "ref.i31 and ref.eq",
+			body: []byte{
+				OpcodeI32Const, 1, OpcodeGCPrefix, byte(OpcodeGCRefI31),
+				OpcodeRefNull, 0x6d, OpcodeRefEq, OpcodeDrop, OpcodeEnd,
+			},
+		},
+		{
+			name:        "struct.new with a func type",
+			body:        []byte{OpcodeGCPrefix, byte(OpcodeGCStructNewDefault), 0, OpcodeDrop, OpcodeEnd},
+			expectedErr: "type 0 is not a struct type for struct.new_default",
+		},
+		{
+			name:        "struct.get on a packed field",
+			body:        []byte{OpcodeGCPrefix, byte(OpcodeGCStructNewDefault), 1, OpcodeGCPrefix, byte(OpcodeGCStructGet), 1, 1, OpcodeDrop, OpcodeEnd},
+			expectedErr: "field 1 of type 1 is packed for struct.get",
+		},
+		{
+			name:        "struct.set on an immutable field",
+			body:        []byte{OpcodeGCPrefix, byte(OpcodeGCStructNewDefault), 1, OpcodeI32Const, 1, OpcodeGCPrefix, byte(OpcodeGCStructSet), 1, 0, OpcodeEnd},
+			expectedErr: "field 0 of type 1 is immutable for struct.set",
+		},
+		{
+			name:        "array.new_default with a non-defaultable element",
+			body:        []byte{OpcodeI32Const, 1, OpcodeGCPrefix, byte(OpcodeGCArrayNewDefault), 3, OpcodeDrop, OpcodeEnd},
+			expectedErr: "element of type 3 is not defaultable for array.new_default",
+		},
+		{
+			name:        "unknown type",
+			body:        []byte{OpcodeI32Const, 1, OpcodeGCPrefix, byte(OpcodeGCArrayNewDefault), 4, OpcodeDrop, OpcodeEnd},
+			expectedErr: "unknown type 4 for array.new_default",
+		},
+		{
+			name:        "ref.cast across hierarchies",
+			body:        []byte{OpcodeRefNull, 0x70, OpcodeGCPrefix, byte(OpcodeGCRefCastNull), 0x6e, OpcodeDrop, OpcodeEnd},
+			expectedErr: "type mismatch: cannot cast funcref to anyref for ref.cast null",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			m := &Module{
+				TypeSection:     types,
+				FunctionSection: []Index{0},
+				CodeSection:     []Code{{Body: tc.body}},
+			}
+			err := m.validateFunction(&stacks{}, gc, 0, []Index{0}, nil, nil, nil, nil, nil, bytes.NewReader(nil))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, m.UsesGC)
+
+			err = m.validateFunction(&stacks{}, api.CoreFeaturesV2, 0, []Index{0}, nil, nil, nil, nil, nil, bytes.NewReader(nil))
+			require.Contains(t, err.Error(), `invalid as feature "gc" is disabled`)
+		})
+	}
+}
+
 func TestDecodeBlockType(t *testing.T) {
 	t.Run("primitive", func(t *testing.T) {
 		for _, tc := range []struct {
@@ -4289,7 +4626,7 @@ func TestModule_funcValidation_Atomic(t *testing.T) {
 
 				t.Run("with memory", func(t *testing.T) {
 					err := m.validateFunction(&stacks{}, experimental.CoreFeaturesThreads,
-						0, []Index{0}, nil, &Memory{}, []Table{}, nil, nil, bytes.NewReader(nil))
+						0, []Index{0}, nil, []*Memory{{}}, []Table{}, nil, nil, bytes.NewReader(nil))
 					require.NoError(t, err)
 				})
 
@@ -4318,7 +4655,7 @@ func TestModule_funcValidation_Atomic(t *testing.T) {
 			CodeSection:     []Code{{Body: body}},
 		}
 		err := m.validateFunction(&stacks{}, experimental.CoreFeaturesThreads,
-			0, []Index{0}, nil, &Memory{}, []Table{}, nil, nil, bytes.NewReader(nil))
+			0, []Index{0}, nil, []*Memory{{}}, []Table{}, nil, nil, bytes.NewReader(nil))
 		require.Error(t, err, "invalid immediate value for atomic.fence")
 	})
 
@@ -4881,7 +5218,7 @@ func TestModule_funcValidation_Atomic(t *testing.T) {
 					CodeSection:     []Code{{Body: body}},
 				}
 				err := m.validateFunction(&stacks{}, experimental.CoreFeaturesThreads,
-					0, []Index{0}, nil, &Memory{}, []Table{}, nil, nil, bytes.NewReader(nil))
+					0, []Index{0}, nil, []*Memory{{}}, []Table{}, nil, nil, bytes.NewReader(nil))
 				require.Error(t, err, "invalid memory alignment")
 			})
 		}
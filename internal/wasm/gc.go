@@ -0,0 +1,540 @@
+package wasm
+
+import (
+	"maps"
+	"sync"
+	"unsafe"
+
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+// The references of the any hierarchy defined by the GC proposal are opaque uint64 values at runtime:
+//
+//   - 0 is the null reference.
+//   - An i31 scalar has the lowest bit set and holds the value in bits 1-31.
+//   - An object allocated in a GCHeap is a handle with the lowest bit clear, which holds the heap ID in
+//     bits 48-62, the generation of the heap slot in bits 33-47 and the slot index in bits 1-32.
+//
+// A reference converted with extern.convert_any additionally has the highest bit set. Handles never
+// collide with function references, as those are pointers into the Go heap whose bits 48-62 are zero.
+const (
+	gcRefExternalized   = uint64(1) << 63
+	gcHandleHeapIDShift = 48
+	gcHandleGenShift    = 33
+	gcHandleGenMask     = 1<<15 - 1
+	gcHandleSlotMask    = 1<<32 - 1
+
+	// gcMaxHeaps is the maximum number of heaps in a Store, limited by the bits of the heap ID in a handle.
+	gcMaxHeaps = 1<<15 - 1
+
+	// GCMaxObjectSlots is the maximum number of value slots of an object, which bounds the length of arrays.
+	GCMaxObjectSlots = 1 << 27
+
+	// gcInitialThreshold is the number of live objects which triggers the first collection of a heap.
+	gcInitialThreshold = 1024
+)
+
+// GCRefI31 returns the reference created by ref.i31 from the low 31 bits of v.
+func GCRefI31(v uint32) uint64 {
+	return uint64(v&0x7fffffff)<<1 | 1
+}
+
+// GCRefIsI31 returns true if ref, a non-null reference of the any hierarchy, is an i31 scalar.
+func GCRefIsI31(ref uint64) bool {
+	return ref&1 == 1
+}
+
+// GCRefI31Value returns the value of the i31 reference ref as i31.get_s or i31.get_u depending on signed.
+func GCRefI31Value(ref uint64, signed bool) uint32 {
+	v := uint32(ref>>1) & 0x7fffffff
+	if signed {
+		return uint32(int32(v<<1) >> 1)
+	}
+	return v
+}
+
+// GCObject is a struct or an array allocated in a GCHeap.
+type GCObject struct {
+	// TypeID is the FunctionTypeID of Type, used by runtime casts.
+	TypeID FunctionTypeID
+	// Type is the struct or array type of this object, or nil when this wraps a host value
+	// converted with any.convert_extern.
+	Type *FunctionType
+	// Fields holds the values of struct fields or array elements, where a v128 value takes two
+	// slots, and packed values are stored zero-extended.
+	Fields []uint64
+	// Extern is the host value wrapped by this object when Type is nil.
+	Extern uint64
+
+	marked bool
+}
+
+// ArrayLen returns the number of elements of this object, which must be an array.
+func (o *GCObject) ArrayLen() uint32 {
+	return uint32(len(o.Fields) / o.Type.Fields[0].Type.slots())
+}
+
+// forEachRef calls fn with the value of each reference field or element of this object.
+func (o *GCObject) forEachRef(fn func(ref uint64)) {
+	if o.Type == nil {
+		return
+	}
+	if o.Type.Kind == CompositeKindArray {
+		if o.Type.Fields[0].Type.IsRef() {
+			for _, v := range o.Fields {
+				fn(v)
+			}
+		}
+		return
+	}
+	slot := 0
+	for _, f := range o.Type.Fields {
+		if f.Type.IsRef() {
+			fn(o.Fields[slot])
+		}
+		slot += f.Type.slots()
+	}
+}
+
+// slots returns the number of uint64 slots taken by a value of this type in a GCObject.
+func (v ValueType) slots() int {
+	if v == ValueTypeV128 {
+		return 2
+	}
+	return 1
+}
+
+// StructFieldSlot returns the index of the first slot of the field fieldIndex in GCObject.Fields
+// of a struct of this type.
+func (f *FunctionType) StructFieldSlot(fieldIndex Index) int {
+	slot := 0
+	for _, field := range f.Fields[:fieldIndex] {
+		slot += field.Type.slots()
+	}
+	return slot
+}
+
+// StructSlots returns the number of slots of GCObject.Fields of a struct of this type.
+func (f *FunctionType) StructSlots() int {
+	return f.StructFieldSlot(Index(len(f.Fields)))
+}
+
+// ArrayElementSlots returns the number of slots taken by each element of an array of this type.
+func (f *FunctionType) ArrayElementSlots() int {
+	return f.Fields[0].Type.slots()
+}
+
+// GCHeap holds the objects allocated by a ModuleInstance.
+//
+// Objects are freed by a mark and sweep collection when the number of live objects reaches a
+// threshold. The roots are the globals, tables and element instances of all modules in the Store,
+// the objects of the other heaps, and the stack of the calling function, which is scanned
+// conservatively. The payloads of the exceptions kept with GCKeepException are traced when
+// their exnref values are reached from these roots.
+//
+// References held by the host are not roots, so the host must not keep them after the call
+// which returned them, unless they are also stored in a global or a table of the Store.
+type GCHeap struct {
+	// id is the index of this heap in Store.gcHeaps plus one, so that handles are never zero.
+	id uint64
+	// owner is the module allocating in this heap, or nil if the heap is released and can be
+	// reused by another module. See releaseGCHeap.
+	owner *ModuleInstance
+
+	mux       sync.RWMutex
+	slots     []gcSlot
+	free      []uint32
+	live      int
+	threshold int
+}
+
+type gcSlot struct {
+	obj *GCObject
+	gen uint64
+}
+
+// GCEnabled returns true if the GC proposal is enabled on the Store of this module.
+func (m *ModuleInstance) GCEnabled() bool {
+	return m.s != nil && m.s.EnabledFeatures.IsEnabled(experimental.CoreFeaturesGC)
+}
+
+// usesGC returns true if the source module of this instance uses the GC proposal.
+func (m *ModuleInstance) usesGC() bool {
+	return m.Source != nil && m.Source.UsesGC
+}
+
+// GCCallEnter is called by engines when a function call starts, if GCEnabled, and must be
+// paired with GCCallExit. Collection only happens while a single call is in progress on the
+// Store, as the stacks of the other calls are not visible to the collector.
+func (m *ModuleInstance) GCCallEnter() {
+	m.s.gcActiveCalls.Add(1)
+}
+
+// GCCallExit is called by engines when a function call started with GCCallEnter returns.
+func (m *ModuleInstance) GCCallExit() {
+	m.s.gcActiveCalls.Add(-1)
+}
+
+// GCKeepException is called by engines when exn is pushed as an exnref value by catch_ref or
+// catch_all_ref, if GCEnabled. The references in its payload are then traced by the collector for as
+// long as the exnref value is reachable, as they are not on the stack anymore once the guest drops them.
+func (m *ModuleInstance) GCKeepException(exn *Exception) {
+	hasRefs := false
+	for _, t := range exn.Tag.Type.Params {
+		hasRefs = hasRefs || t.IsRef()
+	}
+	if !hasRefs {
+		return
+	}
+	s := m.s
+	s.gcMux.Lock()
+	defer s.gcMux.Unlock()
+	if s.gcExceptions == nil {
+		s.gcExceptions = make(map[uint64]*Exception)
+	}
+	s.gcExceptions[uint64(uintptr(unsafe.Pointer(exn)))] = exn
+}
+
+// GCNewObject allocates an object of the struct or array type at typeIndex with the given number
+// of zeroed slots, which are the default values of any field type. stack is the value stack of
+// the calling function, which is used as a root if a collection happens.
+//
+// This panics with wasmruntime.ErrRuntimeAllocationFailure if the object is too large.
+func (m *ModuleInstance) GCNewObject(typeIndex Index, slots uint64, stack []uint64) (uint64, *GCObject) {
+	if slots > GCMaxObjectSlots {
+		panic(wasmruntime.ErrRuntimeAllocationFailure)
+	}
+	obj := &GCObject{
+		TypeID: m.TypeIDs[typeIndex],
+		Type:   &m.Source.TypeSection[typeIndex],
+		Fields: make([]uint64, slots),
+	}
+	return m.gcHeapOrCreate().allocate(obj, stack), obj
+}
+
+// GCObject returns the object referenced by ref, and panics with wasmruntime.ErrRuntimeNullReference
+// if ref is null or does not reference a live object.
+func (m *ModuleInstance) GCObject(ref uint64) *GCObject {
+	if obj := m.s.gcObject(ref); obj != nil && obj.Type != nil {
+		return obj
+	}
+	panic(wasmruntime.ErrRuntimeNullReference)
+}
+
+// GCRefTest returns true if ref, a reference of the any or extern hierarchy, is a value of the
+// reference type target. Concrete function types are checked by engines with GCIsSubtypeID.
+func (m *ModuleInstance) GCRefTest(ref uint64, target ValueType) bool {
+	if ref == 0 {
+		return target.IsNullable()
+	}
+	heapType := target.AsNullable()
+	switch heapType {
+	case ValueTypeAnyref, ValueTypeExternref, ValueTypeFuncref, ValueTypeExnref:
+		return true
+	case ValueTypeNullref, ValueTypeNullfuncref, ValueTypeNullexternref, ValueTypeNullexnref:
+		return false
+	case ValueTypeI31ref:
+		return GCRefIsI31(ref)
+	}
+	if GCRefIsI31(ref) {
+		return heapType == ValueTypeEqref
+	}
+	obj := m.s.gcObject(ref)
+	if obj == nil || obj.Type == nil {
+		return false
+	}
+	switch heapType {
+	case ValueTypeEqref:
+		return true
+	case ValueTypeStructref:
+		return obj.Type.Kind == CompositeKindStruct
+	case ValueTypeArrayref:
+		return obj.Type.Kind == CompositeKindArray
+	}
+	return m.GCIsSubtypeID(obj.TypeID, heapType.TypeIndex())
+}
+
+// GCIsSubtypeID returns true if the type of actual matches the type at typeIndex of this module.
+func (m *ModuleInstance) GCIsSubtypeID(actual FunctionTypeID, typeIndex Index) bool {
+	return m.s.isSubtypeID(actual, m.TypeIDs[typeIndex])
+}
+
+// GCAnyConvertExtern implements any.convert_extern. A host value is wrapped in a new object, so
+// stack is used as a root as in GCNewObject.
+func (m *ModuleInstance) GCAnyConvertExtern(ref uint64, stack []uint64) uint64 {
+	if ref == 0 {
+		return 0
+	}
+	if ref&gcRefExternalized != 0 {
+		return ref &^ gcRefExternalized
+	}
+	return m.gcHeapOrCreate().allocate(&GCObject{Extern: ref}, stack)
+}
+
+// GCExternConvertAny implements extern.convert_any.
+func (m *ModuleInstance) GCExternConvertAny(ref uint64) uint64 {
+	if ref == 0 {
+		return 0
+	}
+	if !GCRefIsI31(ref) {
+		if obj := m.s.gcObject(ref); obj != nil && obj.Type == nil {
+			return obj.Extern
+		}
+	}
+	return ref | gcRefExternalized
+}
+
+// gcHeapOrCreate returns the heap of this module, creating it on the first allocation.
+func (m *ModuleInstance) gcHeapOrCreate() *GCHeap {
+	s := m.s
+	s.gcMux.RLock()
+	h := m.gcHeap
+	s.gcMux.RUnlock()
+	if h != nil {
+		return h
+	}
+
+	s.gcMux.Lock()
+	defer s.gcMux.Unlock()
+	if m.gcHeap != nil { // Check again in case another goroutine has already created it.
+		return m.gcHeap
+	}
+	for _, released := range s.gcHeaps {
+		if released.owner == nil {
+			// The released heap is reused rather than its ID only, so that the generations of its
+			// slots keep the stale handles invalid.
+			released.owner = m
+			m.gcHeap = released
+			return released
+		}
+	}
+	if len(s.gcHeaps) >= gcMaxHeaps {
+		panic(wasmruntime.ErrRuntimeAllocationFailure)
+	}
+	h = &GCHeap{id: uint64(len(s.gcHeaps) + 1), owner: m, threshold: gcInitialThreshold}
+	s.gcHeaps = append(s.gcHeaps, h)
+	m.gcHeap = h
+	return h
+}
+
+// releaseGCHeap releases the heap of this module on close if it holds no objects, so that another
+// module can reuse it. Otherwise, the objects might still be referenced by other modules, so the heap
+// is retained until the Store closes.
+func (m *ModuleInstance) releaseGCHeap() {
+	s := m.s
+	if s == nil {
+		return
+	}
+	s.gcMux.Lock()
+	defer s.gcMux.Unlock()
+	h := m.gcHeap
+	if h == nil {
+		return
+	}
+	h.mux.RLock()
+	empty := h.live == 0
+	h.mux.RUnlock()
+	if empty {
+		h.owner = nil
+		m.gcHeap = nil
+	}
+}
+
+// gcObject returns the object referenced by the handle ref, or nil if ref is not a handle of a live object.
+func (s *Store) gcObject(ref uint64) *GCObject {
+	if ref&1 != 0 || ref&gcRefExternalized != 0 {
+		return nil
+	}
+	id := ref >> gcHandleHeapIDShift
+	if id == 0 {
+		return nil
+	}
+	var h *GCHeap
+	s.gcMux.RLock()
+	if id <= uint64(len(s.gcHeaps)) {
+		h = s.gcHeaps[id-1]
+	}
+	s.gcMux.RUnlock()
+	if h == nil {
+		return nil
+	}
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	if slot := h.slot(ref); slot != nil {
+		return slot.obj
+	}
+	return nil
+}
+
+// slot returns the slot referenced by the handle ref, or nil if the handle is stale. h.mux must be held.
+func (h *GCHeap) slot(ref uint64) *gcSlot {
+	if ref>>gcHandleHeapIDShift != h.id {
+		return nil
+	}
+	i := (ref >> 1) & gcHandleSlotMask
+	if i >= uint64(len(h.slots)) {
+		return nil
+	}
+	slot := &h.slots[i]
+	if slot.obj == nil || slot.gen != (ref>>gcHandleGenShift)&gcHandleGenMask {
+		return nil
+	}
+	return slot
+}
+
+// allocate adds obj to this heap and returns its handle, possibly after a collection.
+func (h *GCHeap) allocate(obj *GCObject, stack []uint64) uint64 {
+	h.mux.RLock()
+	full := h.live >= h.threshold
+	h.mux.RUnlock()
+	if full && h.owner.s.gcActiveCalls.Load() <= 1 {
+		h.collect(stack)
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	var i uint32
+	if l := len(h.free); l > 0 {
+		i = h.free[l-1]
+		h.free = h.free[:l-1]
+	} else {
+		if uint64(len(h.slots)) > gcHandleSlotMask {
+			panic(wasmruntime.ErrRuntimeAllocationFailure)
+		}
+		i = uint32(len(h.slots))
+		h.slots = append(h.slots, gcSlot{})
+	}
+	slot := &h.slots[i]
+	slot.obj = obj
+	h.live++
+	return h.id<<gcHandleHeapIDShift | slot.gen<<gcHandleGenShift | uint64(i)<<1
+}
+
+// collect frees the objects of this heap which are unreachable from the roots of the Store and
+// the given stack.
+func (h *GCHeap) collect(stack []uint64) {
+	roots, exceptions := h.storeRoots()
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	var work []*GCObject
+	var mark func(ref uint64)
+	mark = func(ref uint64) {
+		ref &^= gcRefExternalized
+		if ref == 0 || GCRefIsI31(ref) {
+			return
+		}
+		if ref>>gcHandleHeapIDShift == 0 {
+			// Not a handle, but possibly an exnref. Its payload is traced once.
+			if exn, ok := exceptions[ref]; ok {
+				delete(exceptions, ref)
+				for _, v := range exn.Params {
+					mark(v)
+				}
+			}
+			return
+		}
+		if slot := h.slot(ref); slot != nil && !slot.obj.marked {
+			slot.obj.marked = true
+			work = append(work, slot.obj)
+		}
+	}
+	for _, v := range stack {
+		mark(v)
+	}
+	for _, v := range roots {
+		mark(v)
+	}
+	for len(work) > 0 {
+		obj := work[len(work)-1]
+		work = work[:len(work)-1]
+		obj.forEachRef(mark)
+	}
+
+	for i := range h.slots {
+		slot := &h.slots[i]
+		if slot.obj == nil {
+			continue
+		}
+		if slot.obj.marked {
+			slot.obj.marked = false
+			continue
+		}
+		slot.obj = nil
+		h.live--
+		if slot.gen == gcHandleGenMask {
+			// The next generation would wrap around and revive the stale handles of the first one,
+			// so the slot is retired instead of being reused.
+			continue
+		}
+		slot.gen++
+		h.free = append(h.free, uint32(i))
+	}
+	h.threshold = max(gcInitialThreshold, 2*h.live)
+
+	// The exceptions left are unreachable, so their payloads are not traced anymore.
+	s := h.owner.s
+	s.gcMux.Lock()
+	for ref := range exceptions {
+		delete(s.gcExceptions, ref)
+	}
+	s.gcMux.Unlock()
+}
+
+// storeRoots returns the reference values held outside this heap: in the globals, tables and
+// element instances of the modules in the Store, and in the objects of the other heaps. This also
+// returns a copy of the exceptions kept with GCKeepException.
+func (h *GCHeap) storeRoots() (roots []uint64, exceptions map[uint64]*Exception) {
+	s := h.owner.s
+	addModule := func(m *ModuleInstance) {
+		for _, g := range m.Globals {
+			if g != nil && g.Type.ValType.IsRef() {
+				v, _ := g.Value()
+				roots = append(roots, v)
+			}
+		}
+		for _, t := range m.Tables {
+			if t == nil {
+				continue
+			}
+			for _, r := range t.References {
+				roots = append(roots, uint64(r))
+			}
+		}
+		for _, elem := range m.ElementInstances {
+			for _, r := range elem {
+				roots = append(roots, uint64(r))
+			}
+		}
+	}
+
+	// The owner is not yet in the module list during instantiation.
+	addModule(h.owner)
+	s.mux.RLock()
+	for m := s.moduleList; m != nil; m = m.next {
+		if m != h.owner {
+			addModule(m)
+		}
+	}
+	s.mux.RUnlock()
+
+	s.gcMux.RLock()
+	heaps := append([]*GCHeap(nil), s.gcHeaps...)
+	exceptions = maps.Clone(s.gcExceptions)
+	s.gcMux.RUnlock()
+	for _, other := range heaps {
+		if other == h {
+			continue
+		}
+		other.mux.RLock()
+		for i := range other.slots {
+			if obj := other.slots[i].obj; obj != nil {
+				obj.forEachRef(func(ref uint64) { roots = append(roots, ref) })
+			}
+		}
+		other.mux.RUnlock()
+	}
+	return
+}
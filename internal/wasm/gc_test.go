@@ -0,0 +1,325 @@
+package wasm
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+func TestGCRefI31(t *testing.T) {
+	for _, tc := range []struct {
+		input            uint32
+		signed, unsigned uint32
+	}{
+		{input: 0, signed: 0, unsigned: 0},
+		{input: 1, signed: 1, unsigned: 1},
+		{input: 0x3fffffff, signed: 0x3fffffff, unsigned: 0x3fffffff},
+		{input: 0x40000000, signed: 0xc0000000, unsigned: 0x40000000},
+		{input: 0x7fffffff, signed: 0xffffffff, unsigned: 0x7fffffff},
+		{input: 0xffffffff, signed: 0xffffffff, unsigned: 0x7fffffff},
+		{input: 0x80000001, signed: 1, unsigned: 1},
+	} {
+		ref := GCRefI31(tc.input)
+		require.NotEqual(t, uint64(0), ref)
+		require.True(t, GCRefIsI31(ref))
+		require.Equal(t, tc.signed, GCRefI31Value(ref, true))
+		require.Equal(t, tc.unsigned, GCRefI31Value(ref, false))
+	}
+}
+
+// newGCTestModuleInstance returns a module instance with the following types:
+//
+//	(type $base (sub (struct (field anyref))))
+//	(type $derived (sub $base (struct (field anyref) (field i32))))
+//	(type $bytes (array (mut i8)))
+func newGCTestModuleInstance(t *testing.T) *ModuleInstance {
+	s := NewStore(api.CoreFeaturesV2|experimental.CoreFeaturesGC, &mockEngine{callFailIndex: -1})
+	m := &ModuleInstance{s: s, Source: &Module{
+		UsesGC: true,
+		TypeSection: []FunctionType{
+			{Kind: CompositeKindStruct, NonFinal: true, Fields: []FieldType{{Type: ValueTypeAnyref}}},
+			{
+				Kind: CompositeKindStruct, HasSuperType: true, SuperType: 0,
+				Fields: []FieldType{{Type: ValueTypeAnyref}, {Type: ValueTypeI32}},
+			},
+			{Kind: CompositeKindArray, Fields: []FieldType{{Type: ValueTypeI8, Mutable: true}}},
+		},
+	}}
+	var err error
+	m.TypeIDs, err = s.GetFunctionTypeIDs(m.Source.TypeSection)
+	require.NoError(t, err)
+	return m
+}
+
+func TestModuleInstance_GCRefTest(t *testing.T) {
+	m := newGCTestModuleInstance(t)
+	base, _ := m.GCNewObject(0, 1, nil)
+	derived, _ := m.GCNewObject(1, 2, nil)
+	bytes, _ := m.GCNewObject(2, 4, nil)
+	i31 := GCRefI31(5)
+	extern := m.GCAnyConvertExtern(0x1234, nil)
+
+	for _, tc := range []struct {
+		name     string
+		ref      uint64
+		target   ValueType
+		expected bool
+	}{
+		{name: "null to nullable", ref: 0, target: ValueTypeStructref, expected: true},
+		{name: "null to non-nullable", ref: 0, target: ValueTypeStructref.AsNonNullable(), expected: false},
+		{name: "i31 to any", ref: i31, target: ValueTypeAnyref.AsNonNullable(), expected: true},
+		{name: "i31 to eq", ref: i31, target: ValueTypeEqref, expected: true},
+		{name: "i31 to i31", ref: i31, target: ValueTypeI31ref, expected: true},
+		{name: "i31 to struct", ref: i31, target: ValueTypeStructref, expected: false},
+		{name: "struct to i31", ref: base, target: ValueTypeI31ref, expected: false},
+		{name: "struct to eq", ref: base, target: ValueTypeEqref, expected: true},
+		{name: "struct to struct", ref: base, target: ValueTypeStructref, expected: true},
+		{name: "struct to array", ref: base, target: ValueTypeArrayref, expected: false},
+		{name: "struct to none", ref: base, target: ValueTypeNullref, expected: false},
+		{name: "array to array", ref: bytes, target: ValueTypeArrayref, expected: true},
+		{name: "base to base", ref: base, target: ValueTypeConcreteRef(0, false), expected: true},
+		{name: "base to derived", ref: base, target: ValueTypeConcreteRef(1, false), expected: false},
+		{name: "derived to base", ref: derived, target: ValueTypeConcreteRef(0, false), expected: true},
+		{name: "array to base", ref: bytes, target: ValueTypeConcreteRef(0, true), expected: false},
+		{name: "host value to any", ref: extern, target: ValueTypeAnyref, expected: true},
+		{name: "host value to eq", ref: extern, target: ValueTypeEqref, expected: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, m.GCRefTest(tc.ref, tc.target))
+		})
+	}
+}
+
+func TestModuleInstance_GCConvert(t *testing.T) {
+	m := newGCTestModuleInstance(t)
+	obj, _ := m.GCNewObject(0, 1, nil)
+
+	// Internal references round-trip through the extern hierarchy.
+	for _, ref := range []uint64{0, obj, GCRefI31(42)} {
+		extern := m.GCExternConvertAny(ref)
+		require.Equal(t, ref, m.GCAnyConvertExtern(extern, nil))
+	}
+
+	// Host values are wrapped into an object, which is unwrapped on the way back.
+	wrapped := m.GCAnyConvertExtern(0xabcd, nil)
+	require.NotEqual(t, uint64(0xabcd), wrapped)
+	require.Equal(t, uint64(0xabcd), m.GCExternConvertAny(wrapped))
+	err := require.CapturePanic(func() { m.GCObject(wrapped) })
+	require.Equal(t, wasmruntime.ErrRuntimeNullReference, err)
+}
+
+func TestModuleInstance_GCNewObject(t *testing.T) {
+	m := newGCTestModuleInstance(t)
+
+	ref, obj := m.GCNewObject(2, 3, nil)
+	require.Equal(t, obj, m.GCObject(ref))
+	require.Equal(t, uint32(3), obj.ArrayLen())
+	require.Equal(t, &m.Source.TypeSection[2], obj.Type)
+	require.Equal(t, m.TypeIDs[2], obj.TypeID)
+
+	err := require.CapturePanic(func() { m.GCObject(0) })
+	require.Equal(t, wasmruntime.ErrRuntimeNullReference, err)
+
+	err = require.CapturePanic(func() { m.GCNewObject(2, GCMaxObjectSlots+1, nil) })
+	require.Equal(t, wasmruntime.ErrRuntimeAllocationFailure, err)
+}
+
+func TestGCHeap_collect(t *testing.T) {
+	m := newGCTestModuleInstance(t)
+
+	parent, parentObj := m.GCNewObject(0, 1, nil)
+	child, _ := m.GCNewObject(0, 1, nil)
+	parentObj.Fields[0] = child
+	garbage, garbageObj := m.GCNewObject(0, 1, nil)
+	garbageObj.Fields[0] = garbage // Cycles are collected.
+	global, _ := m.GCNewObject(2, 1, nil)
+	m.Globals = []*GlobalInstance{{Type: GlobalType{ValType: ValueTypeArrayref}, Val: global}}
+
+	h := m.gcHeap
+	h.collect([]uint64{parent, GCRefI31(1)})
+	require.Equal(t, 3, h.live)
+	require.Equal(t, gcInitialThreshold, h.threshold)
+	require.NotNil(t, m.s.gcObject(parent))
+	require.NotNil(t, m.s.gcObject(child))
+	require.NotNil(t, m.s.gcObject(global))
+	require.Nil(t, m.s.gcObject(garbage))
+
+	// The freed slot is reused with a new generation, so the stale handle stays invalid.
+	reused, _ := m.GCNewObject(0, 1, nil)
+	require.Equal(t, garbage&^(gcHandleGenMask<<gcHandleGenShift), reused&^(gcHandleGenMask<<gcHandleGenShift))
+	require.NotEqual(t, garbage, reused)
+	require.Nil(t, m.s.gcObject(garbage))
+
+	// Objects of another heap are roots.
+	other := &ModuleInstance{s: m.s, Source: m.Source, TypeIDs: m.TypeIDs}
+	_, otherObj := other.GCNewObject(0, 1, nil)
+	otherObj.Fields[0] = reused
+	h.collect(nil)
+	require.NotNil(t, m.s.gcObject(reused))
+	require.Nil(t, m.s.gcObject(parent))
+	require.Equal(t, 2, h.live)
+}
+
+func TestGCHeap_allocate_collects(t *testing.T) {
+	m := newGCTestModuleInstance(t)
+
+	kept, _ := m.GCNewObject(0, 1, nil)
+	stack := []uint64{kept}
+	for i := 0; i < 10*gcInitialThreshold; i++ {
+		m.GCNewObject(0, 1, stack)
+	}
+	h := m.gcHeap
+	require.True(t, h.live <= gcInitialThreshold)
+	require.True(t, len(h.slots) <= gcInitialThreshold+1)
+	require.NotNil(t, m.s.gcObject(kept))
+
+	// No collection happens while another call is active on the Store.
+	m.GCCallEnter()
+	m.GCCallEnter()
+	live := h.live
+	for i := 0; i < gcInitialThreshold; i++ {
+		m.GCNewObject(0, 1, stack)
+	}
+	require.Equal(t, live+gcInitialThreshold, h.live)
+	m.GCCallExit()
+	m.GCCallExit()
+}
+
+func TestGCHeap_collect_exceptions(t *testing.T) {
+	m := newGCTestModuleInstance(t)
+	tag := &TagInstance{Type: &FunctionType{Params: []ValueType{ValueTypeAnyref, ValueTypeExnref}}}
+	exnref := func(exn *Exception) uint64 { return uint64(uintptr(unsafe.Pointer(exn))) }
+
+	innerPayload, _ := m.GCNewObject(0, 1, nil)
+	inner := &Exception{Tag: tag, Params: []uint64{innerPayload, 0}}
+	m.GCKeepException(inner)
+	outerPayload, _ := m.GCNewObject(0, 1, nil)
+	outer := &Exception{Tag: tag, Params: []uint64{outerPayload, exnref(inner)}}
+	m.GCKeepException(outer)
+
+	// The exceptions without references in their payload are not kept.
+	m.GCKeepException(&Exception{Tag: &TagInstance{Type: &FunctionType{Params: []ValueType{ValueTypeI32}}}, Params: []uint64{1}})
+	require.Equal(t, 2, len(m.s.gcExceptions))
+
+	// The payloads are traced through the exnref on the stack, including the nested exception.
+	h := m.gcHeap
+	h.collect([]uint64{exnref(outer)})
+	require.NotNil(t, m.s.gcObject(innerPayload))
+	require.NotNil(t, m.s.gcObject(outerPayload))
+	require.Equal(t, 2, len(m.s.gcExceptions))
+
+	// Once the exnref is unreachable, the payloads are freed, and the exceptions are not kept anymore.
+	h.collect(nil)
+	require.Nil(t, m.s.gcObject(innerPayload))
+	require.Nil(t, m.s.gcObject(outerPayload))
+	require.Equal(t, 0, len(m.s.gcExceptions))
+}
+
+func TestGCHeap_collect_retiresSlot(t *testing.T) {
+	m := newGCTestModuleInstance(t)
+	ref, _ := m.GCNewObject(0, 1, nil)
+	h := m.gcHeap
+
+	// The slot is at its last generation, so freeing it must not make the first generation valid again.
+	h.slots[0].gen = gcHandleGenMask
+	last := ref | gcHandleGenMask<<gcHandleGenShift
+	require.NotNil(t, m.s.gcObject(last))
+	h.collect(nil)
+	require.Nil(t, m.s.gcObject(last))
+	require.Equal(t, 0, len(h.free))
+
+	next, _ := m.GCNewObject(0, 1, nil)
+	require.Equal(t, uint64(1), (next>>1)&gcHandleSlotMask)
+	require.Nil(t, m.s.gcObject(ref))
+}
+
+func TestModuleInstance_releaseGCHeap(t *testing.T) {
+	m := newGCTestModuleInstance(t)
+	stale, _ := m.GCNewObject(0, 1, nil)
+	h := m.gcHeap
+	h.collect(nil)
+	m.releaseGCHeap()
+	require.Nil(t, m.gcHeap)
+	require.Nil(t, h.owner)
+
+	// The released heap is reused along with the generations of its slots, so the stale handle stays invalid.
+	other := &ModuleInstance{s: m.s, Source: m.Source, TypeIDs: m.TypeIDs}
+	ref, _ := other.GCNewObject(0, 1, nil)
+	require.Equal(t, h, other.gcHeap)
+	require.Equal(t, 1, len(m.s.gcHeaps))
+	require.NotEqual(t, stale, ref)
+	require.Nil(t, m.s.gcObject(stale))
+
+	// A non-empty heap is retained as other modules might reference its objects.
+	other.releaseGCHeap()
+	require.NotNil(t, other.gcHeap)
+	require.NotNil(t, m.s.gcObject(ref))
+}
+
+func TestEvaluateConstExprInModuleInstance_GC(t *testing.T) {
+	m := newGCTestModuleInstance(t)
+
+	// (struct.new $derived (ref.i31 (i32.const 5)) (i32.const 0x1234))
+	expr := &ConstantExpression{Data: []byte{
+		OpcodeI32Const, 5, OpcodeGCPrefix, byte(OpcodeGCRefI31),
+		OpcodeI32Const, 0xb4, 0x24, OpcodeGCPrefix, byte(OpcodeGCStructNew), 1, OpcodeEnd,
+	}}
+	v := evaluateConstExprInModuleInstance(expr, m)
+	require.Equal(t, 1, len(v))
+	obj := m.GCObject(v[0])
+	require.Equal(t, m.TypeIDs[1], obj.TypeID)
+	require.Equal(t, []uint64{GCRefI31(5), 0x1234}, obj.Fields)
+
+	// (array.new $bytes (i32.const 0x1ff) (i32.const 2)) stores the packed value.
+	expr = &ConstantExpression{Data: []byte{
+		OpcodeI32Const, 0xff, 0x03, OpcodeI32Const, 2, OpcodeGCPrefix, byte(OpcodeGCArrayNew), 2, OpcodeEnd,
+	}}
+	v = evaluateConstExprInModuleInstance(expr, m)
+	require.Equal(t, []uint64{0xff, 0xff}, m.GCObject(v[0]).Fields)
+
+	// Validation marks the module as using GC without allocating.
+	m.Source.UsesGC = false
+	_, vt, err := evaluateConstExprGC(expr, m.Source.validationConstExprGC(),
+		func(Index) (ValueType, uint64, uint64, error) { return 0, 0, 0, nil },
+		func(Index) (Reference, error) { return 0, nil })
+	require.NoError(t, err)
+	require.Equal(t, ValueTypeConcreteRef(2, false), vt)
+	require.True(t, m.Source.UsesGC)
+}
+
+func TestTypesEquivalent(t *testing.T) {
+	f32 := ValueTypeF32
+	types := []FunctionType{
+		{Params: []ValueType{ValueTypeI32}, Results: []ValueType{f32}},
+		{Params: []ValueType{ValueTypeI32, ValueTypeConcreteRef(0, false)}},
+		{Params: []ValueType{ValueTypeI32, ValueTypeConcreteRef(0, false)}},
+		{Params: []ValueType{ValueTypeConcreteRef(1, false)}},
+		{Params: []ValueType{ValueTypeConcreteRef(2, false)}},
+		// Two identical rec groups of two mutually recursive types.
+		{Params: []ValueType{ValueTypeConcreteRef(6, true)}, RecGroupSize: 2},
+		{Results: []ValueType{ValueTypeConcreteRef(5, true)}, RecGroupSize: 2, RecGroupPosition: 1},
+		{Params: []ValueType{ValueTypeConcreteRef(8, true)}, RecGroupSize: 2},
+		{Results: []ValueType{ValueTypeConcreteRef(7, true)}, RecGroupSize: 2, RecGroupPosition: 1},
+		// A type outside a rec group is in an implicit rec group of its own.
+		{Params: []ValueType{ValueTypeI32}, Results: []ValueType{f32}, RecGroupSize: 1},
+	}
+	for _, tc := range []struct {
+		a, b     Index
+		expected bool
+	}{
+		{a: 1, b: 2, expected: true},
+		{a: 3, b: 4, expected: true},
+		{a: 0, b: 1, expected: false},
+		{a: 5, b: 7, expected: true},
+		{a: 6, b: 8, expected: true},
+		{a: 5, b: 8, expected: false},
+		{a: 0, b: 9, expected: true},
+		{a: 0, b: 10, expected: false}, // out of range
+	} {
+		require.Equal(t, tc.expected, typesEquivalent(types, tc.a, tc.b), "%d, %d", tc.a, tc.b)
+	}
+}
@@ -1,10 +1,12 @@
 package wasm
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/wasmdebug"
 )
 
@@ -12,6 +14,64 @@ type HostFuncExporter interface {
 	ExportHostFunc(*HostFunc)
 }
 
+type HostTagExporter interface {
+	ExportHostTag(*HostTag)
+}
+
+// HostFuncWrapper is implemented by policies restricting host functions, such
+// as wasi_snapshot_preview1.Policy, so that other host modules can enforce
+// them on their functions, too.
+type HostFuncWrapper interface {
+	WrapHostFunc(*HostFunc) *HostFunc
+}
+
+// HostTable is a table defined by the host, used for AddHostTables.
+type HostTable struct {
+	// ExportName is the name the table is exported as.
+	ExportName string
+
+	// Type is the type of the table elements.
+	Type RefType
+
+	// Min is the initial count of elements.
+	Min uint32
+
+	// Max is the maximum count of elements, or nil if unbounded.
+	Max *uint32
+}
+
+// HostMemory is a memory defined by the host, used for AddHostMemories.
+type HostMemory struct {
+	// ExportName is the name the memory is exported as.
+	ExportName string
+
+	// Memory are the limits of the memory, with the capacity already derived
+	// from the runtime configuration.
+	Memory *Memory
+}
+
+// HostGlobal is a global defined by the host, used for AddHostGlobals.
+type HostGlobal struct {
+	// ExportName is the name the global is exported as.
+	ExportName string
+
+	// Type is the type of the global.
+	Type GlobalType
+
+	// Value is the initial value of the global, encoded as for api.Global.
+	Value uint64
+}
+
+// HostTag is an exception tag with an inlined type, used for AddHostTags.
+// Any corresponding FunctionType will be reused or added to the Module.
+type HostTag struct {
+	// ExportName is the name the tag is exported as.
+	ExportName string
+
+	// ParamTypes are the types of the exception payload.
+	ParamTypes []ValueType
+}
+
 // HostFunc is a function with an inlined type, used for NewHostModule.
 // Any corresponding FunctionType will be reused or added to the Module.
 type HostFunc struct {
@@ -72,7 +132,7 @@ func NewHostModule(
 	// compilation of host modules is not costly as it's merely small trampolines vs the real-world native Wasm binary.
 	// TODO: refactor engines so that we can properly cache compiled machine codes for host modules.
 	m.AssignModuleID([]byte(fmt.Sprintf("@@@@@@@@%p", m)), // @@@@@@@@ = any 8 bytes different from Wasm header.
-		nil, false)
+		nil, false, false, false, 0)
 	return
 }
 
@@ -159,6 +219,122 @@ func addFuncs(
 	return nil
 }
 
+// AddHostTags defines and exports the given tags in the host module m, which
+// was created by NewHostModule.
+func (m *Module) AddHostTags(tags []*HostTag, enabledFeatures api.CoreFeatures) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesExceptionHandling); err != nil {
+		return fmt.Errorf("tag[%s.%s] %v", m.NameSection.ModuleName, tags[0].ExportName, err)
+	}
+	for _, ht := range tags {
+		typeIdx, err := m.maybeAddType(ht.ParamTypes, nil, enabledFeatures)
+		if err != nil {
+			return fmt.Errorf("tag[%s.%s] %v", m.NameSection.ModuleName, ht.ExportName, err)
+		}
+		if err = m.addHostExport("tag", ExternTypeTag, ht.ExportName, Index(len(m.TagSection))); err != nil {
+			return err
+		}
+		m.TagSection = append(m.TagSection, Tag{Type: typeIdx})
+	}
+	return nil
+}
+
+// AddHostTables defines and exports the given tables in the host module m,
+// which was created by NewHostModule.
+func (m *Module) AddHostTables(tables []*HostTable) error {
+	for _, ht := range tables {
+		if err := m.addHostExport("table", ExternTypeTable, ht.ExportName, Index(len(m.TableSection))); err != nil {
+			return err
+		}
+		m.TableSection = append(m.TableSection, Table{Min: ht.Min, Max: ht.Max, Type: ht.Type})
+	}
+	return nil
+}
+
+// AddHostMemories defines and exports the given memories in the host module
+// m, which was created by NewHostModule.
+func (m *Module) AddHostMemories(memories []*HostMemory, enabledFeatures api.CoreFeatures, memoryLimitPages uint32) error {
+	for _, hm := range memories {
+		mem := hm.Memory
+		if mem.IsShared {
+			if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesThreads); err != nil {
+				return fmt.Errorf("memory[%s.%s] shared memory requested but %v", m.NameSection.ModuleName, hm.ExportName, err)
+			} else if !mem.IsMaxEncoded {
+				return fmt.Errorf("memory[%s.%s] shared memory requires a maximum size to be specified", m.NameSection.ModuleName, hm.ExportName)
+			}
+		}
+		if err := mem.Validate(memoryLimitPages); err != nil {
+			return fmt.Errorf("memory[%s.%s] %v", m.NameSection.ModuleName, hm.ExportName, err)
+		}
+		if err := m.addHostExport("memory", ExternTypeMemory, hm.ExportName, Index(len(m.MemorySection))); err != nil {
+			return err
+		}
+		m.MemorySection = append(m.MemorySection, *mem)
+	}
+	return nil
+}
+
+// AddHostGlobals defines and exports the given globals in the host module m,
+// which was created by NewHostModule.
+func (m *Module) AddHostGlobals(globals []*HostGlobal) error {
+	for _, hg := range globals {
+		init, err := hostGlobalInit(hg.Type.ValType, hg.Value)
+		if err != nil {
+			return fmt.Errorf("global[%s.%s] %v", m.NameSection.ModuleName, hg.ExportName, err)
+		}
+		if err = m.addHostExport("global", ExternTypeGlobal, hg.ExportName, Index(len(m.GlobalSection))); err != nil {
+			return err
+		}
+		m.GlobalSection = append(m.GlobalSection, Global{Type: hg.Type, Init: init})
+	}
+	return nil
+}
+
+// hostGlobalInit returns the constant expression which initializes a global
+// of the given type to v.
+func hostGlobalInit(t ValueType, v uint64) (ConstantExpression, error) {
+	switch t {
+	case ValueTypeI32:
+		return NewConstantExpressionFromI32(int32(v)), nil
+	case ValueTypeI64:
+		return NewConstantExpressionFromI64(int64(v)), nil
+	case ValueTypeF32:
+		return NewConstantExpressionFromOpcode(OpcodeF32Const, binary.LittleEndian.AppendUint32(nil, uint32(v))), nil
+	case ValueTypeF64:
+		return NewConstantExpressionFromOpcode(OpcodeF64Const, binary.LittleEndian.AppendUint64(nil, v)), nil
+	case ValueTypeExternref, ValueTypeFuncref:
+		// There is no constant expression for a non-null reference from the host.
+		if v != 0 {
+			return ConstantExpression{}, fmt.Errorf("%s must be initialized to null", ValueTypeName(t))
+		}
+		return NewConstantExpressionFromOpcode(OpcodeRefNull, []byte{t.Kind()}), nil
+	case ValueTypeV128:
+		// Values are encoded as for api.Global, whose uint64 can't hold 128 bits.
+		return ConstantExpression{}, fmt.Errorf("%s is not supported as its value doesn't fit in a uint64", ValueTypeName(t))
+	default:
+		return ConstantExpression{}, fmt.Errorf("unsupported type %s", ValueTypeName(t))
+	}
+}
+
+// addHostExport exports the entity of the given type and index under the
+// given name, unless the name is already exported.
+func (m *Module) addHostExport(kind string, typ ExternType, name string, idx Index) error {
+	if _, ok := m.Exports[name]; ok {
+		return fmt.Errorf("%s[%s.%s] export name conflicts with another export", kind, m.NameSection.ModuleName, name)
+	}
+	if m.Exports == nil {
+		m.Exports = map[string]*Export{}
+	}
+	m.ExportSection = append(m.ExportSection, Export{Type: typ, Name: name, Index: idx})
+	// Appending may have moved ExportSection, so re-point all exports.
+	for i := range m.ExportSection {
+		m.Exports[m.ExportSection[i].Name] = &m.ExportSection[i]
+	}
+	return nil
+}
+
 func (m *Module) maybeAddType(params, results []ValueType, enabledFeatures api.CoreFeatures) (Index, error) {
 	if len(results) > 1 {
 		// Guard >1.0 feature multi-value
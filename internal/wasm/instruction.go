@@ -3,6 +3,12 @@ package wasm
 // Opcode is the binary Opcode of an instruction. See also InstructionName
 type Opcode = byte
 
+// MemArgMemoryIndexFlag is set in the alignment of a memarg immediate when an explicit memory index follows it.
+// This is only valid when experimental.CoreFeaturesMultiMemory is enabled.
+//
+// See https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md
+const MemArgMemoryIndexFlag = 1 << 6
+
 const (
 	// OpcodeUnreachable causes an unconditional trap.
 	OpcodeUnreachable Opcode = 0x00
@@ -286,6 +292,10 @@ const (
 	// otherwise falls through.
 	OpcodeBrOnNonNull Opcode = 0xd6
 
+	// OpcodeRefEq pops two eqref values and pushes 1 if they refer to the same object, 0 otherwise.
+	// This is toggled with CoreFeaturesGC.
+	OpcodeRefEq Opcode = 0xd3
+
 	// Below are toggled with CoreFeatureSignExtensionOps
 
 	// OpcodeI32Extend8S extends a signed 8-bit integer to a 32-bit integer.
@@ -308,6 +318,10 @@ const (
 	// Note: This is dependent on the flag CoreFeatureSignExtensionOps
 	OpcodeI64Extend32S Opcode = 0xc4
 
+	// OpcodeGCPrefix is the prefix of all GC instructions introduced in
+	// CoreFeaturesGC.
+	OpcodeGCPrefix Opcode = 0xfb
+
 	// OpcodeMiscPrefix is the prefix of various multi-byte opcodes.
 	// Introduced in CoreFeatureNonTrappingFloatToIntConversion, but used in other
 	// features, such as CoreFeatureBulkMemoryOperations.
@@ -662,6 +676,46 @@ const (
 	OpcodeVecF64x2PromoteLowF32x4Zero OpcodeVec = 0x5f
 )
 
+// OpcodeVecRelaxed represents an opcode of a relaxed SIMD instruction which is prefixed by OpcodeVecPrefix.
+// Unlike OpcodeVec, these opcodes don't fit in a byte, so they are always encoded as two-byte LEB128.
+// Use RelaxedVectorOpcode to decode them.
+//
+// These opcodes are toggled with experimental.CoreFeaturesRelaxedSIMD.
+type OpcodeVecRelaxed = uint32
+
+const (
+	OpcodeVecI8x16RelaxedSwizzle           OpcodeVecRelaxed = 0x100
+	OpcodeVecI32x4RelaxedTruncF32x4S       OpcodeVecRelaxed = 0x101
+	OpcodeVecI32x4RelaxedTruncF32x4U       OpcodeVecRelaxed = 0x102
+	OpcodeVecI32x4RelaxedTruncF64x2SZero   OpcodeVecRelaxed = 0x103
+	OpcodeVecI32x4RelaxedTruncF64x2UZero   OpcodeVecRelaxed = 0x104
+	OpcodeVecF32x4RelaxedMadd              OpcodeVecRelaxed = 0x105
+	OpcodeVecF32x4RelaxedNmadd             OpcodeVecRelaxed = 0x106
+	OpcodeVecF64x2RelaxedMadd              OpcodeVecRelaxed = 0x107
+	OpcodeVecF64x2RelaxedNmadd             OpcodeVecRelaxed = 0x108
+	OpcodeVecI8x16RelaxedLaneselect        OpcodeVecRelaxed = 0x109
+	OpcodeVecI16x8RelaxedLaneselect        OpcodeVecRelaxed = 0x10a
+	OpcodeVecI32x4RelaxedLaneselect        OpcodeVecRelaxed = 0x10b
+	OpcodeVecI64x2RelaxedLaneselect        OpcodeVecRelaxed = 0x10c
+	OpcodeVecF32x4RelaxedMin               OpcodeVecRelaxed = 0x10d
+	OpcodeVecF32x4RelaxedMax               OpcodeVecRelaxed = 0x10e
+	OpcodeVecF64x2RelaxedMin               OpcodeVecRelaxed = 0x10f
+	OpcodeVecF64x2RelaxedMax               OpcodeVecRelaxed = 0x110
+	OpcodeVecI16x8RelaxedQ15mulrS          OpcodeVecRelaxed = 0x111
+	OpcodeVecI16x8RelaxedDotI8x16I7x16S    OpcodeVecRelaxed = 0x112
+	OpcodeVecI32x4RelaxedDotI8x16I7x16AddS OpcodeVecRelaxed = 0x113
+)
+
+// RelaxedVectorOpcode decodes the opcode of a relaxed SIMD instruction from b, which must start right after
+// OpcodeVecPrefix. ok is false if b doesn't start with a two-byte opcode in the relaxed SIMD range, in which
+// case b holds a single-byte OpcodeVec instead.
+func RelaxedVectorOpcode(b []byte) (op OpcodeVecRelaxed, ok bool) {
+	if len(b) < 2 || b[0] < 0x80 || b[1] != 0x02 {
+		return 0, false
+	}
+	return OpcodeVecRelaxed(b[0]&0x7f) | 0x100, true
+}
+
 // OpcodeAtomic represents an opcode of atomic instructions which has
 // multi-byte encoding and is prefixed by OpcodeAtomicPrefix.
 //
@@ -1012,6 +1066,7 @@ const (
 	OpcodeRefAsNonNullName  = "ref.as_non_null"
 	OpcodeBrOnNullName      = "br_on_null"
 	OpcodeBrOnNonNullName   = "br_on_non_null"
+	OpcodeRefEqName         = "ref.eq"
 	OpcodeCallRefName       = "call_ref"
 	OpcodeReturnCallRefName = "return_call_ref"
 
@@ -1026,6 +1081,7 @@ const (
 	OpcodeI64Extend16SName = "i64.extend16_s"
 	OpcodeI64Extend32SName = "i64.extend32_s"
 
+	OpcodeGCPrefixName     = "gc_prefix"
 	OpcodeMiscPrefixName   = "misc_prefix"
 	OpcodeVecPrefixName    = "vector_prefix"
 	OpcodeAtomicPrefixName = "atomic_prefix"
@@ -1215,6 +1271,7 @@ var instructionNames = [256]string{
 	OpcodeRefAsNonNull:  OpcodeRefAsNonNullName,
 	OpcodeBrOnNull:      OpcodeBrOnNullName,
 	OpcodeBrOnNonNull:   OpcodeBrOnNonNullName,
+	OpcodeRefEq:         OpcodeRefEqName,
 	OpcodeCallRef:       OpcodeCallRefName,
 	OpcodeReturnCallRef: OpcodeReturnCallRefName,
 
@@ -1229,6 +1286,7 @@ var instructionNames = [256]string{
 	OpcodeI64Extend16S: OpcodeI64Extend16SName,
 	OpcodeI64Extend32S: OpcodeI64Extend32SName,
 
+	OpcodeGCPrefix:   OpcodeGCPrefixName,
 	OpcodeMiscPrefix: OpcodeMiscPrefixName,
 	OpcodeVecPrefix:  OpcodeVecPrefixName,
 }
@@ -1527,6 +1585,52 @@ const (
 	OpcodeVecF64x2PromoteLowF32x4ZeroName  = "f64x2.promote_low_f32x4"
 )
 
+const (
+	OpcodeVecI8x16RelaxedSwizzleName           = "i8x16.relaxed_swizzle"
+	OpcodeVecI32x4RelaxedTruncF32x4SName       = "i32x4.relaxed_trunc_f32x4_s"
+	OpcodeVecI32x4RelaxedTruncF32x4UName       = "i32x4.relaxed_trunc_f32x4_u"
+	OpcodeVecI32x4RelaxedTruncF64x2SZeroName   = "i32x4.relaxed_trunc_f64x2_s_zero"
+	OpcodeVecI32x4RelaxedTruncF64x2UZeroName   = "i32x4.relaxed_trunc_f64x2_u_zero"
+	OpcodeVecF32x4RelaxedMaddName              = "f32x4.relaxed_madd"
+	OpcodeVecF32x4RelaxedNmaddName             = "f32x4.relaxed_nmadd"
+	OpcodeVecF64x2RelaxedMaddName              = "f64x2.relaxed_madd"
+	OpcodeVecF64x2RelaxedNmaddName             = "f64x2.relaxed_nmadd"
+	OpcodeVecI8x16RelaxedLaneselectName        = "i8x16.relaxed_laneselect"
+	OpcodeVecI16x8RelaxedLaneselectName        = "i16x8.relaxed_laneselect"
+	OpcodeVecI32x4RelaxedLaneselectName        = "i32x4.relaxed_laneselect"
+	OpcodeVecI64x2RelaxedLaneselectName        = "i64x2.relaxed_laneselect"
+	OpcodeVecF32x4RelaxedMinName               = "f32x4.relaxed_min"
+	OpcodeVecF32x4RelaxedMaxName               = "f32x4.relaxed_max"
+	OpcodeVecF64x2RelaxedMinName               = "f64x2.relaxed_min"
+	OpcodeVecF64x2RelaxedMaxName               = "f64x2.relaxed_max"
+	OpcodeVecI16x8RelaxedQ15mulrSName          = "i16x8.relaxed_q15mulr_s"
+	OpcodeVecI16x8RelaxedDotI8x16I7x16SName    = "i16x8.relaxed_dot_i8x16_i7x16_s"
+	OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName = "i32x4.relaxed_dot_i8x16_i7x16_add_s"
+)
+
+var relaxedVectorInstructionName = map[OpcodeVecRelaxed]string{
+	OpcodeVecI8x16RelaxedSwizzle:           OpcodeVecI8x16RelaxedSwizzleName,
+	OpcodeVecI32x4RelaxedTruncF32x4S:       OpcodeVecI32x4RelaxedTruncF32x4SName,
+	OpcodeVecI32x4RelaxedTruncF32x4U:       OpcodeVecI32x4RelaxedTruncF32x4UName,
+	OpcodeVecI32x4RelaxedTruncF64x2SZero:   OpcodeVecI32x4RelaxedTruncF64x2SZeroName,
+	OpcodeVecI32x4RelaxedTruncF64x2UZero:   OpcodeVecI32x4RelaxedTruncF64x2UZeroName,
+	OpcodeVecF32x4RelaxedMadd:              OpcodeVecF32x4RelaxedMaddName,
+	OpcodeVecF32x4RelaxedNmadd:             OpcodeVecF32x4RelaxedNmaddName,
+	OpcodeVecF64x2RelaxedMadd:              OpcodeVecF64x2RelaxedMaddName,
+	OpcodeVecF64x2RelaxedNmadd:             OpcodeVecF64x2RelaxedNmaddName,
+	OpcodeVecI8x16RelaxedLaneselect:        OpcodeVecI8x16RelaxedLaneselectName,
+	OpcodeVecI16x8RelaxedLaneselect:        OpcodeVecI16x8RelaxedLaneselectName,
+	OpcodeVecI32x4RelaxedLaneselect:        OpcodeVecI32x4RelaxedLaneselectName,
+	OpcodeVecI64x2RelaxedLaneselect:        OpcodeVecI64x2RelaxedLaneselectName,
+	OpcodeVecF32x4RelaxedMin:               OpcodeVecF32x4RelaxedMinName,
+	OpcodeVecF32x4RelaxedMax:               OpcodeVecF32x4RelaxedMaxName,
+	OpcodeVecF64x2RelaxedMin:               OpcodeVecF64x2RelaxedMinName,
+	OpcodeVecF64x2RelaxedMax:               OpcodeVecF64x2RelaxedMaxName,
+	OpcodeVecI16x8RelaxedQ15mulrS:          OpcodeVecI16x8RelaxedQ15mulrSName,
+	OpcodeVecI16x8RelaxedDotI8x16I7x16S:    OpcodeVecI16x8RelaxedDotI8x16I7x16SName,
+	OpcodeVecI32x4RelaxedDotI8x16I7x16AddS: OpcodeVecI32x4RelaxedDotI8x16I7x16AddSName,
+}
+
 var vectorInstructionName = map[OpcodeVec]string{
 	OpcodeVecV128Load:                  OpcodeVecV128LoadName,
 	OpcodeVecV128Load8x8s:              OpcodeVecV128Load8x8SName,
@@ -1771,6 +1875,11 @@ func VectorInstructionName(oc OpcodeVec) (ret string) {
 	return vectorInstructionName[oc]
 }
 
+// RelaxedVectorInstructionName returns the instruction name corresponding to the relaxed SIMD Opcode.
+func RelaxedVectorInstructionName(oc OpcodeVecRelaxed) (ret string) {
+	return relaxedVectorInstructionName[oc]
+}
+
 const (
 	OpcodeAtomicMemoryNotifyName = "memory.atomic.notify"
 	OpcodeAtomicMemoryWait32Name = "memory.atomic.wait32"
@@ -1960,3 +2069,150 @@ const (
 	OpcodeThrowRefName = "throw_ref"
 	OpcodeTryTableName = "try_table"
 )
+
+// OpcodeGC represents an opcode of GC instructions which has
+// multi-byte encoding and is prefixed by OpcodeGCPrefix.
+//
+// These opcodes are toggled with CoreFeaturesGC.
+type OpcodeGC = uint32
+
+const (
+	// OpcodeGCStructNew represents the instruction struct.new.
+	OpcodeGCStructNew OpcodeGC = 0x00
+	// OpcodeGCStructNewDefault represents the instruction struct.new_default.
+	OpcodeGCStructNewDefault OpcodeGC = 0x01
+	// OpcodeGCStructGet represents the instruction struct.get.
+	OpcodeGCStructGet OpcodeGC = 0x02
+	// OpcodeGCStructGetS represents the instruction struct.get_s.
+	OpcodeGCStructGetS OpcodeGC = 0x03
+	// OpcodeGCStructGetU represents the instruction struct.get_u.
+	OpcodeGCStructGetU OpcodeGC = 0x04
+	// OpcodeGCStructSet represents the instruction struct.set.
+	OpcodeGCStructSet OpcodeGC = 0x05
+	// OpcodeGCArrayNew represents the instruction array.new.
+	OpcodeGCArrayNew OpcodeGC = 0x06
+	// OpcodeGCArrayNewDefault represents the instruction array.new_default.
+	OpcodeGCArrayNewDefault OpcodeGC = 0x07
+	// OpcodeGCArrayNewFixed represents the instruction array.new_fixed.
+	OpcodeGCArrayNewFixed OpcodeGC = 0x08
+	// OpcodeGCArrayNewData represents the instruction array.new_data.
+	OpcodeGCArrayNewData OpcodeGC = 0x09
+	// OpcodeGCArrayNewElem represents the instruction array.new_elem.
+	OpcodeGCArrayNewElem OpcodeGC = 0x0a
+	// OpcodeGCArrayGet represents the instruction array.get.
+	OpcodeGCArrayGet OpcodeGC = 0x0b
+	// OpcodeGCArrayGetS represents the instruction array.get_s.
+	OpcodeGCArrayGetS OpcodeGC = 0x0c
+	// OpcodeGCArrayGetU represents the instruction array.get_u.
+	OpcodeGCArrayGetU OpcodeGC = 0x0d
+	// OpcodeGCArraySet represents the instruction array.set.
+	OpcodeGCArraySet OpcodeGC = 0x0e
+	// OpcodeGCArrayLen represents the instruction array.len.
+	OpcodeGCArrayLen OpcodeGC = 0x0f
+	// OpcodeGCArrayFill represents the instruction array.fill.
+	OpcodeGCArrayFill OpcodeGC = 0x10
+	// OpcodeGCArrayCopy represents the instruction array.copy.
+	OpcodeGCArrayCopy OpcodeGC = 0x11
+	// OpcodeGCArrayInitData represents the instruction array.init_data.
+	OpcodeGCArrayInitData OpcodeGC = 0x12
+	// OpcodeGCArrayInitElem represents the instruction array.init_elem.
+	OpcodeGCArrayInitElem OpcodeGC = 0x13
+	// OpcodeGCRefTest represents the instruction ref.test.
+	OpcodeGCRefTest OpcodeGC = 0x14
+	// OpcodeGCRefTestNull represents the instruction ref.test null.
+	OpcodeGCRefTestNull OpcodeGC = 0x15
+	// OpcodeGCRefCast represents the instruction ref.cast.
+	OpcodeGCRefCast OpcodeGC = 0x16
+	// OpcodeGCRefCastNull represents the instruction ref.cast null.
+	OpcodeGCRefCastNull OpcodeGC = 0x17
+	// OpcodeGCBrOnCast represents the instruction br_on_cast.
+	OpcodeGCBrOnCast OpcodeGC = 0x18
+	// OpcodeGCBrOnCastFail represents the instruction br_on_cast_fail.
+	OpcodeGCBrOnCastFail OpcodeGC = 0x19
+	// OpcodeGCAnyConvertExtern represents the instruction any.convert_extern.
+	OpcodeGCAnyConvertExtern OpcodeGC = 0x1a
+	// OpcodeGCExternConvertAny represents the instruction extern.convert_any.
+	OpcodeGCExternConvertAny OpcodeGC = 0x1b
+	// OpcodeGCRefI31 represents the instruction ref.i31.
+	OpcodeGCRefI31 OpcodeGC = 0x1c
+	// OpcodeGCI31GetS represents the instruction i31.get_s.
+	OpcodeGCI31GetS OpcodeGC = 0x1d
+	// OpcodeGCI31GetU represents the instruction i31.get_u.
+	OpcodeGCI31GetU OpcodeGC = 0x1e
+)
+
+const (
+	OpcodeGCStructNewName        = "struct.new"
+	OpcodeGCStructNewDefaultName = "struct.new_default"
+	OpcodeGCStructGetName        = "struct.get"
+	OpcodeGCStructGetSName       = "struct.get_s"
+	OpcodeGCStructGetUName       = "struct.get_u"
+	OpcodeGCStructSetName        = "struct.set"
+	OpcodeGCArrayNewName         = "array.new"
+	OpcodeGCArrayNewDefaultName  = "array.new_default"
+	OpcodeGCArrayNewFixedName    = "array.new_fixed"
+	OpcodeGCArrayNewDataName     = "array.new_data"
+	OpcodeGCArrayNewElemName     = "array.new_elem"
+	OpcodeGCArrayGetName         = "array.get"
+	OpcodeGCArrayGetSName        = "array.get_s"
+	OpcodeGCArrayGetUName        = "array.get_u"
+	OpcodeGCArraySetName         = "array.set"
+	OpcodeGCArrayLenName         = "array.len"
+	OpcodeGCArrayFillName        = "array.fill"
+	OpcodeGCArrayCopyName        = "array.copy"
+	OpcodeGCArrayInitDataName    = "array.init_data"
+	OpcodeGCArrayInitElemName    = "array.init_elem"
+	OpcodeGCRefTestName          = "ref.test"
+	OpcodeGCRefTestNullName      = "ref.test null"
+	OpcodeGCRefCastName          = "ref.cast"
+	OpcodeGCRefCastNullName      = "ref.cast null"
+	OpcodeGCBrOnCastName         = "br_on_cast"
+	OpcodeGCBrOnCastFailName     = "br_on_cast_fail"
+	OpcodeGCAnyConvertExternName = "any.convert_extern"
+	OpcodeGCExternConvertAnyName = "extern.convert_any"
+	OpcodeGCRefI31Name           = "ref.i31"
+	OpcodeGCI31GetSName          = "i31.get_s"
+	OpcodeGCI31GetUName          = "i31.get_u"
+)
+
+var gcInstructionName = [...]string{
+	OpcodeGCStructNew:        OpcodeGCStructNewName,
+	OpcodeGCStructNewDefault: OpcodeGCStructNewDefaultName,
+	OpcodeGCStructGet:        OpcodeGCStructGetName,
+	OpcodeGCStructGetS:       OpcodeGCStructGetSName,
+	OpcodeGCStructGetU:       OpcodeGCStructGetUName,
+	OpcodeGCStructSet:        OpcodeGCStructSetName,
+	OpcodeGCArrayNew:         OpcodeGCArrayNewName,
+	OpcodeGCArrayNewDefault:  OpcodeGCArrayNewDefaultName,
+	OpcodeGCArrayNewFixed:    OpcodeGCArrayNewFixedName,
+	OpcodeGCArrayNewData:     OpcodeGCArrayNewDataName,
+	OpcodeGCArrayNewElem:     OpcodeGCArrayNewElemName,
+	OpcodeGCArrayGet:         OpcodeGCArrayGetName,
+	OpcodeGCArrayGetS:        OpcodeGCArrayGetSName,
+	OpcodeGCArrayGetU:        OpcodeGCArrayGetUName,
+	OpcodeGCArraySet:         OpcodeGCArraySetName,
+	OpcodeGCArrayLen:         OpcodeGCArrayLenName,
+	OpcodeGCArrayFill:        OpcodeGCArrayFillName,
+	OpcodeGCArrayCopy:        OpcodeGCArrayCopyName,
+	OpcodeGCArrayInitData:    OpcodeGCArrayInitDataName,
+	OpcodeGCArrayInitElem:    OpcodeGCArrayInitElemName,
+	OpcodeGCRefTest:          OpcodeGCRefTestName,
+	OpcodeGCRefTestNull:      OpcodeGCRefTestNullName,
+	OpcodeGCRefCast:          OpcodeGCRefCastName,
+	OpcodeGCRefCastNull:      OpcodeGCRefCastNullName,
+	OpcodeGCBrOnCast:         OpcodeGCBrOnCastName,
+	OpcodeGCBrOnCastFail:     OpcodeGCBrOnCastFailName,
+	OpcodeGCAnyConvertExtern: OpcodeGCAnyConvertExternName,
+	OpcodeGCExternConvertAny: OpcodeGCExternConvertAnyName,
+	OpcodeGCRefI31:           OpcodeGCRefI31Name,
+	OpcodeGCI31GetS:          OpcodeGCI31GetSName,
+	OpcodeGCI31GetU:          OpcodeGCI31GetUName,
+}
+
+// GCInstructionName returns the instruction name corresponding to the GC Opcode.
+func GCInstructionName(oc OpcodeGC) (ret string) {
+	if oc < uint32(len(gcInstructionName)) {
+		ret = gcInstructionName[oc]
+	}
+	return
+}
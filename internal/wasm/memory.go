@@ -27,6 +27,10 @@ const (
 	MemoryLimitPages = uint32(65536)
 	// MemoryPageSizeInBits satisfies the relation: "1 << MemoryPageSizeInBits == MemoryPageSize".
 	MemoryPageSizeInBits = 16
+	// Memory64LimitPages is maximum number of pages of a 64-bit memory supported by wazero (2^24, or 1 TiB).
+	// The memory64 proposal allows up to 2^48 pages, but the buffer of a memory is a Go slice indexed by pages in uint32.
+	// See https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md
+	Memory64LimitPages = uint32(1 << 24)
 )
 
 // compile-time check to ensure MemoryInstance implements api.Memory
@@ -48,6 +52,8 @@ type MemoryInstance struct {
 	Buffer        []byte
 	Min, Cap, Max uint32
 	Shared        bool
+	// Is64 is true when the memory is addressed with i64 values. See experimental.CoreFeaturesMemory64.
+	Is64 bool
 	// definition is known at compile time.
 	definition api.MemoryDefinition
 
@@ -98,6 +104,7 @@ func NewMemoryInstance(memSec *Memory, allocator experimental.MemoryAllocator, m
 		Cap:               memoryBytesNumToPages(uint64(cap(buffer))),
 		Max:               memSec.Max,
 		Shared:            memSec.IsShared,
+		Is64:              memSec.Is64,
 		expBuffer:         expBuffer,
 		ownerModuleEngine: moduleEngine,
 	}
@@ -113,8 +120,18 @@ func (m *MemoryInstance) Size() uint32 {
 	return uint32(len(m.Buffer))
 }
 
+// Size64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) Size64() uint64 {
+	return uint64(len(m.Buffer))
+}
+
 // ReadByte implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadByte(offset uint32) (byte, bool) {
+	return m.ReadByte64(uint64(offset))
+}
+
+// ReadByte64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadByte64(offset uint64) (byte, bool) {
 	if !m.hasSize(offset, 1) {
 		return 0, false
 	}
@@ -123,6 +140,11 @@ func (m *MemoryInstance) ReadByte(offset uint32) (byte, bool) {
 
 // ReadUint16Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadUint16Le(offset uint32) (uint16, bool) {
+	return m.ReadUint16Le64(uint64(offset))
+}
+
+// ReadUint16Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadUint16Le64(offset uint64) (uint16, bool) {
 	if !m.hasSize(offset, 2) {
 		return 0, false
 	}
@@ -131,11 +153,21 @@ func (m *MemoryInstance) ReadUint16Le(offset uint32) (uint16, bool) {
 
 // ReadUint32Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadUint32Le(offset uint32) (uint32, bool) {
+	return m.readUint32Le(uint64(offset))
+}
+
+// ReadUint32Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadUint32Le64(offset uint64) (uint32, bool) {
 	return m.readUint32Le(offset)
 }
 
 // ReadFloat32Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadFloat32Le(offset uint32) (float32, bool) {
+	return m.ReadFloat32Le64(uint64(offset))
+}
+
+// ReadFloat32Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadFloat32Le64(offset uint64) (float32, bool) {
 	v, ok := m.readUint32Le(offset)
 	if !ok {
 		return 0, false
@@ -145,11 +177,21 @@ func (m *MemoryInstance) ReadFloat32Le(offset uint32) (float32, bool) {
 
 // ReadUint64Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadUint64Le(offset uint32) (uint64, bool) {
+	return m.readUint64Le(uint64(offset))
+}
+
+// ReadUint64Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadUint64Le64(offset uint64) (uint64, bool) {
 	return m.readUint64Le(offset)
 }
 
 // ReadFloat64Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadFloat64Le(offset uint32) (float64, bool) {
+	return m.ReadFloat64Le64(uint64(offset))
+}
+
+// ReadFloat64Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadFloat64Le64(offset uint64) (float64, bool) {
 	v, ok := m.readUint64Le(offset)
 	if !ok {
 		return 0, false
@@ -159,7 +201,12 @@ func (m *MemoryInstance) ReadFloat64Le(offset uint32) (float64, bool) {
 
 // Read implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Read(offset, byteCount uint32) ([]byte, bool) {
-	if !m.hasSize(offset, uint64(byteCount)) {
+	return m.Read64(uint64(offset), uint64(byteCount))
+}
+
+// Read64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) Read64(offset, byteCount uint64) ([]byte, bool) {
+	if !m.hasSize(offset, byteCount) {
 		return nil, false
 	}
 	return m.Buffer[offset : offset+byteCount : offset+byteCount], true
@@ -167,6 +214,11 @@ func (m *MemoryInstance) Read(offset, byteCount uint32) ([]byte, bool) {
 
 // WriteByte implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteByte(offset uint32, v byte) bool {
+	return m.WriteByte64(uint64(offset), v)
+}
+
+// WriteByte64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteByte64(offset uint64, v byte) bool {
 	if !m.hasSize(offset, 1) {
 		return false
 	}
@@ -176,6 +228,11 @@ func (m *MemoryInstance) WriteByte(offset uint32, v byte) bool {
 
 // WriteUint16Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteUint16Le(offset uint32, v uint16) bool {
+	return m.WriteUint16Le64(uint64(offset), v)
+}
+
+// WriteUint16Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteUint16Le64(offset uint64, v uint16) bool {
 	if !m.hasSize(offset, 2) {
 		return false
 	}
@@ -185,26 +242,51 @@ func (m *MemoryInstance) WriteUint16Le(offset uint32, v uint16) bool {
 
 // WriteUint32Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteUint32Le(offset, v uint32) bool {
+	return m.writeUint32Le(uint64(offset), v)
+}
+
+// WriteUint32Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteUint32Le64(offset uint64, v uint32) bool {
 	return m.writeUint32Le(offset, v)
 }
 
 // WriteFloat32Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteFloat32Le(offset uint32, v float32) bool {
+	return m.writeUint32Le(uint64(offset), math.Float32bits(v))
+}
+
+// WriteFloat32Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteFloat32Le64(offset uint64, v float32) bool {
 	return m.writeUint32Le(offset, math.Float32bits(v))
 }
 
 // WriteUint64Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteUint64Le(offset uint32, v uint64) bool {
+	return m.writeUint64Le(uint64(offset), v)
+}
+
+// WriteUint64Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteUint64Le64(offset uint64, v uint64) bool {
 	return m.writeUint64Le(offset, v)
 }
 
 // WriteFloat64Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteFloat64Le(offset uint32, v float64) bool {
+	return m.writeUint64Le(uint64(offset), math.Float64bits(v))
+}
+
+// WriteFloat64Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteFloat64Le64(offset uint64, v float64) bool {
 	return m.writeUint64Le(offset, math.Float64bits(v))
 }
 
 // Write implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Write(offset uint32, val []byte) bool {
+	return m.Write64(uint64(offset), val)
+}
+
+// Write64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) Write64(offset uint64, val []byte) bool {
 	if !m.hasSize(offset, uint64(len(val))) {
 		return false
 	}
@@ -214,6 +296,11 @@ func (m *MemoryInstance) Write(offset uint32, val []byte) bool {
 
 // WriteString implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteString(offset uint32, val string) bool {
+	return m.WriteString64(uint64(offset), val)
+}
+
+// WriteString64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteString64(offset uint64, val string) bool {
 	if !m.hasSize(offset, uint64(len(val))) {
 		return false
 	}
@@ -332,13 +419,14 @@ func memoryBytesNumToPages(bytesNum uint64) (pages uint32) {
 // hasSize returns true if Len is sufficient for byteCount at the given offset.
 //
 // Note: This is always fine, because memory can grow, but never shrink.
-func (m *MemoryInstance) hasSize(offset uint32, byteCount uint64) bool {
-	return uint64(offset)+byteCount <= uint64(len(m.Buffer)) // uint64 prevents overflow on add
+func (m *MemoryInstance) hasSize(offset uint64, byteCount uint64) bool {
+	size := uint64(len(m.Buffer))
+	return offset <= size && byteCount <= size-offset // avoids overflow on offset+byteCount
 }
 
 // readUint32Le implements ReadUint32Le without using a context. This is extracted as both ints and floats are stored in
 // memory as uint32le.
-func (m *MemoryInstance) readUint32Le(offset uint32) (uint32, bool) {
+func (m *MemoryInstance) readUint32Le(offset uint64) (uint32, bool) {
 	if !m.hasSize(offset, 4) {
 		return 0, false
 	}
@@ -347,7 +435,7 @@ func (m *MemoryInstance) readUint32Le(offset uint32) (uint32, bool) {
 
 // readUint64Le implements ReadUint64Le without using a context. This is extracted as both ints and floats are stored in
 // memory as uint64le.
-func (m *MemoryInstance) readUint64Le(offset uint32) (uint64, bool) {
+func (m *MemoryInstance) readUint64Le(offset uint64) (uint64, bool) {
 	if !m.hasSize(offset, 8) {
 		return 0, false
 	}
@@ -356,7 +444,7 @@ func (m *MemoryInstance) readUint64Le(offset uint32) (uint64, bool) {
 
 // writeUint32Le implements WriteUint32Le without using a context. This is extracted as both ints and floats are stored
 // in memory as uint32le.
-func (m *MemoryInstance) writeUint32Le(offset uint32, v uint32) bool {
+func (m *MemoryInstance) writeUint32Le(offset uint64, v uint32) bool {
 	if !m.hasSize(offset, 4) {
 		return false
 	}
@@ -366,7 +454,7 @@ func (m *MemoryInstance) writeUint32Le(offset uint32, v uint32) bool {
 
 // writeUint64Le implements WriteUint64Le without using a context. This is extracted as both ints and floats are stored
 // in memory as uint64le.
-func (m *MemoryInstance) writeUint64Le(offset uint32, v uint64) bool {
+func (m *MemoryInstance) writeUint64Le(offset uint64, v uint64) bool {
 	if !m.hasSize(offset, 8) {
 		return false
 	}
@@ -375,7 +463,7 @@ func (m *MemoryInstance) writeUint64Le(offset uint32, v uint64) bool {
 }
 
 // Wait32 suspends the caller until the offset is notified by a different agent.
-func (m *MemoryInstance) Wait32(offset uint32, exp uint32, timeout int64, reader func(mem *MemoryInstance, offset uint32) uint32) uint64 {
+func (m *MemoryInstance) Wait32(offset uint64, exp uint32, timeout int64, reader func(mem *MemoryInstance, offset uint64) uint32) uint64 {
 	w := m.getWaiters(offset)
 	w.mux.Lock()
 
@@ -389,7 +477,7 @@ func (m *MemoryInstance) Wait32(offset uint32, exp uint32, timeout int64, reader
 }
 
 // Wait64 suspends the caller until the offset is notified by a different agent.
-func (m *MemoryInstance) Wait64(offset uint32, exp uint64, timeout int64, reader func(mem *MemoryInstance, offset uint32) uint64) uint64 {
+func (m *MemoryInstance) Wait64(offset uint64, exp uint64, timeout int64, reader func(mem *MemoryInstance, offset uint64) uint64) uint64 {
 	w := m.getWaiters(offset)
 	w.mux.Lock()
 
@@ -438,7 +526,7 @@ func (m *MemoryInstance) wait(w *waiters, timeout int64) uint64 {
 	}
 }
 
-func (m *MemoryInstance) getWaiters(offset uint32) *waiters {
+func (m *MemoryInstance) getWaiters(offset uint64) *waiters {
 	wAny, ok := m.waiters.Load(offset)
 	if !ok {
 		// The first time an address is waited on, simultaneous waits will cause extra allocations.
@@ -451,7 +539,7 @@ func (m *MemoryInstance) getWaiters(offset uint32) *waiters {
 }
 
 // Notify wakes up at most count waiters at the given offset.
-func (m *MemoryInstance) Notify(offset uint32, count uint32) uint32 {
+func (m *MemoryInstance) Notify(offset uint64, count uint32) uint32 {
 	wAny, ok := m.waiters.Load(offset)
 	if !ok {
 		return 0
@@ -184,7 +184,7 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 
 	tests := []struct {
 		name        string
-		offset      uint32
+		offset      uint64
 		sizeInBytes uint64
 		expected    bool
 	}{
@@ -196,7 +196,7 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 		},
 		{
 			name:        "maximum valid sizeInBytes",
-			offset:      memory.Size() - 8,
+			offset:      memory.Size64() - 8,
 			sizeInBytes: 8,
 			expected:    true,
 		},
@@ -208,7 +208,7 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 		},
 		{
 			name:        "offset exceeds the memory size",
-			offset:      memory.Size(),
+			offset:      memory.Size64(),
 			sizeInBytes: 1, // arbitrary size
 			expected:    false,
 		},
@@ -224,6 +224,12 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 			sizeInBytes: 1,
 			expected:    false,
 		},
+		{
+			name:        "offset + sizeInBytes overflows in uint64",
+			offset:      math.MaxUint64 - 1,
+			sizeInBytes: 4,
+			expected:    false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -499,6 +505,29 @@ func TestMemoryInstance_Read(t *testing.T) {
 	require.False(t, ok)
 }
 
+func TestMemoryInstance_Read64(t *testing.T) {
+	mem := &MemoryInstance{Buffer: []byte{0, 0, 0, 0, 16, 0, 0, 0}, Min: 1, Is64: true}
+
+	buf, ok := mem.Read64(4, 4)
+	require.True(t, ok)
+	require.Equal(t, []byte{16, 0, 0, 0}, buf)
+
+	// Offsets beyond 32 bits must not be truncated.
+	_, ok = mem.Read64(1<<32+4, 4)
+	require.False(t, ok)
+
+	_, ok = mem.Read64(math.MaxUint64, 2)
+	require.False(t, ok)
+
+	_, ok = mem.ReadUint32Le64(1 << 32)
+	require.False(t, ok)
+	require.False(t, mem.WriteUint32Le64(1<<32, 1))
+	require.True(t, mem.WriteUint32Le64(4, 1))
+	v, ok := mem.ReadUint32Le64(4)
+	require.True(t, ok)
+	require.Equal(t, uint32(1), v)
+}
+
 func TestMemoryInstance_WriteUint16Le(t *testing.T) {
 	memory := &MemoryInstance{Buffer: make([]byte, 100)}
 
@@ -877,8 +906,8 @@ func TestNewMemoryInstance_Shared(t *testing.T) {
 }
 
 func TestMemoryInstance_WaitNotifyOnce(t *testing.T) {
-	reader := func(mem *MemoryInstance, offset uint32) uint32 {
-		val, _ := mem.ReadUint32Le(offset)
+	reader := func(mem *MemoryInstance, offset uint64) uint32 {
+		val, _ := mem.ReadUint32Le64(offset)
 		return val
 	}
 	t.Run("no waiters", func(t *testing.T) {
@@ -989,7 +1018,7 @@ func notifyWaiters(t *testing.T, mem *MemoryInstance, offset, count, exp int) {
 		if tries > 100 {
 			t.Fatal("too many tries waiting for wait and notify to converge")
 		}
-		n := mem.Notify(uint32(offset), uint32(count))
+		n := mem.Notify(uint64(offset), uint32(count))
 		cur += int(n)
 		time.Sleep(1 * time.Millisecond)
 		tries++
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"slices"
 	"sort"
@@ -13,6 +12,7 @@ import (
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/wasmdebug"
 )
 
@@ -84,16 +84,17 @@ type Module struct {
 	// MemorySection contains each memory defined in this module.
 	//
 	// Note: The memory Index space begins with imported memories and ends with those defined in this module.
-	// For example, if there are two imported memories and one defined in this module, the memory Index 3 is defined in
-	// this module at TableSection[0].
+	// For example, if there are two imported memories and one defined in this module, the memory Index 2 is defined in
+	// this module at MemorySection[0].
 	//
 	// Note: Version 1.0 (20191205) of the WebAssembly spec allows at most one memory definition per module, so the
-	// length of the MemorySection can be zero or one, and can only be one if there is no imported memory.
+	// length of the MemorySection can be zero or one, and can only be one if there is no imported memory. When
+	// experimental.CoreFeaturesMultiMemory is enabled, there can be any number of memories.
 	//
 	// Note: In the Binary Format, this is SectionIDMemory.
 	//
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-section%E2%91%A0
-	MemorySection *Memory
+	MemorySection []Memory
 
 	// TagSection contains each tag defined in this module for exception handling.
 	//
@@ -183,6 +184,10 @@ type Module struct {
 	// IsHostModule true if this is the host module, false otherwise.
 	IsHostModule bool
 
+	// UsesGC is true if the module uses types or instructions of the GC proposal, which
+	// is set on validation.
+	UsesGC bool
+
 	// functionDefinitionSectionInitOnce guards FunctionDefinitionSection so that it is initialized exactly once.
 	functionDefinitionSectionInitOnce sync.Once
 
@@ -192,6 +197,12 @@ type Module struct {
 	// MemoryDefinitionSection is a wazero-specific section.
 	MemoryDefinitionSection []MemoryDefinition
 
+	// tableDefinitionSectionInitOnce guards TableDefinitionSection so that it is initialized exactly once.
+	tableDefinitionSectionInitOnce sync.Once
+
+	// TableDefinitionSection is a wazero-specific section.
+	TableDefinitionSection []TableDefinition
+
 	// DWARFLines is used to emit DWARF based stack trace. This is created from the multiple custom sections
 	// as described in https://yurydelendik.github.io/webassembly-dwarf/, though it is not specified in the Wasm
 	// specification: https://github.com/WebAssembly/debugging/issues/1
@@ -211,7 +222,7 @@ const (
 
 // AssignModuleID calculates a sha256 checksum on `wasm` and other args, and set Module.ID to the result.
 // See the doc on Module.ID on what it's used for.
-func (m *Module) AssignModuleID(wasm []byte, listeners []experimental.FunctionListener, withEnsureTermination bool) {
+func (m *Module) AssignModuleID(wasm []byte, listeners []experimental.FunctionListener, withEnsureTermination, withFuelMetering, withMemoryGuardPages bool, inliningBudget int) {
 	h := sha256.New()
 	h.Write(wasm)
 	// Use the pre-allocated space backed by m.ID below.
@@ -222,9 +233,12 @@ func (m *Module) AssignModuleID(wasm []byte, listeners []experimental.FunctionLi
 		m.ID[4] = boolToByte(l != nil)
 		h.Write(m.ID[:5])
 	}
-	// Write the flag of ensureTermination to the checksum.
+	// Write the flags of ensureTermination, fuelMetering and memoryGuardPages, and the inlining budget to the checksum.
 	m.ID[0] = boolToByte(withEnsureTermination)
-	h.Write(m.ID[:1])
+	m.ID[1] = boolToByte(withFuelMetering)
+	m.ID[2] = boolToByte(withMemoryGuardPages)
+	binary.LittleEndian.PutUint64(m.ID[3:], uint64(inliningBudget))
+	h.Write(m.ID[:11])
 	// Get checksum by passing the slice underlying m.ID.
 	h.Sum(m.ID[:0])
 }
@@ -288,15 +302,30 @@ func (m *Module) Validate(enabledFeatures api.CoreFeatures) error {
 		return err
 	}
 
+	if m.UsesGC = m.UsesGCTypes(); m.UsesGC {
+		if err := enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+			return fmt.Errorf("GC types invalid as %v", err)
+		}
+		if err := m.validateGCTypes(); err != nil {
+			return err
+		}
+	}
+
 	if err := m.validateStartSection(); err != nil {
 		return err
 	}
 
-	functions, globals, memory, tables, tags, err := m.AllDeclarations()
+	functions, globals, memories, tables, tags, err := m.AllDeclarations()
 	if err != nil {
 		return err
 	}
 
+	if len(memories) > 1 {
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesMultiMemory); err != nil {
+			return fmt.Errorf("multiple memories: %w", err)
+		}
+	}
+
 	if err = m.validateTableInitExprs(globals, uint32(len(functions))); err != nil {
 		return err
 	}
@@ -309,16 +338,16 @@ func (m *Module) Validate(enabledFeatures api.CoreFeatures) error {
 		return err
 	}
 
-	if err = m.validateMemory(memory, globals, enabledFeatures); err != nil {
+	if err = m.validateMemory(memories, globals, enabledFeatures); err != nil {
 		return err
 	}
 
-	if err = m.validateExports(enabledFeatures, functions, globals, memory, tables, tags); err != nil {
+	if err = m.validateExports(enabledFeatures, functions, globals, memories, tables, tags); err != nil {
 		return err
 	}
 
 	if m.CodeSection != nil {
-		if err = m.validateFunctions(enabledFeatures, functions, globals, memory, tables, tags, MaximumFunctionIndex); err != nil {
+		if err = m.validateFunctions(enabledFeatures, functions, globals, memories, tables, tags, MaximumFunctionIndex); err != nil {
 			return err
 		}
 	} // No need to validate host functions as NewHostModule validates
@@ -334,6 +363,13 @@ func (m *Module) Validate(enabledFeatures api.CoreFeatures) error {
 	if err = m.validateTagSection(); err != nil {
 		return err
 	}
+
+	// Constant expressions can use GC instructions even when no GC types are used.
+	if m.UsesGC {
+		if err = enabledFeatures.RequireEnabled(experimental.CoreFeaturesGC); err != nil {
+			return fmt.Errorf("GC instructions invalid as %v", err)
+		}
+	}
 	return nil
 }
 
@@ -364,6 +400,129 @@ func (m *Module) validateConcreteRefTypes() error {
 	return nil
 }
 
+// UsesGCTypes returns true if the module defines struct or array types, declares subtypes,
+// or uses any of the abstract reference types introduced by the GC proposal.
+func (m *Module) UsesGCTypes() bool {
+	for i := range m.TypeSection {
+		tp := &m.TypeSection[i]
+		if !tp.IsFunc() || tp.HasSuperType || tp.NonFinal ||
+			slices.ContainsFunc(tp.Params, IsGCRefType) || slices.ContainsFunc(tp.Results, IsGCRefType) {
+			return true
+		}
+	}
+	for i := range m.GlobalSection {
+		if IsGCRefType(m.GlobalSection[i].Type.ValType) {
+			return true
+		}
+	}
+	for i := range m.ImportSection {
+		imp := &m.ImportSection[i]
+		if IsGCRefType(imp.DescGlobal.ValType) || IsGCRefType(imp.DescTable.Type) {
+			return true
+		}
+	}
+	for i := range m.TableSection {
+		if IsGCRefType(m.TableSection[i].Type) {
+			return true
+		}
+	}
+	for i := range m.ElementSection {
+		if IsGCRefType(m.ElementSection[i].Type) {
+			return true
+		}
+	}
+	for i := range m.CodeSection {
+		if slices.ContainsFunc(m.CodeSection[i].LocalTypes, IsGCRefType) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGCTypes ensures that declared subtypes match their supertypes, and that only function
+// types are used where a function type is required.
+func (m *Module) validateGCTypes() error {
+	types := m.TypeSection
+	for i := range types {
+		tp := &types[i]
+		if !tp.HasSuperType {
+			continue
+		}
+		super := &types[tp.SuperType]
+		if !super.NonFinal {
+			return fmt.Errorf("type %d cannot be a subtype of final type %d", i, tp.SuperType)
+		}
+		if !isCompositeSubtypeOf(types, tp, super) {
+			return fmt.Errorf("type %d does not match its supertype %d", i, tp.SuperType)
+		}
+	}
+
+	for i, typeIndex := range m.FunctionSection {
+		if typeIndex < uint32(len(types)) && !types[typeIndex].IsFunc() {
+			return fmt.Errorf("function[%d] type %d is not a function type", i, typeIndex)
+		}
+	}
+	for i := range m.ImportSection {
+		imp := &m.ImportSection[i]
+		if imp.Type == ExternTypeFunc && imp.DescFunc < uint32(len(types)) && !types[imp.DescFunc].IsFunc() {
+			return fmt.Errorf("import[%d] type %d is not a function type", i, imp.DescFunc)
+		}
+	}
+	for i := range m.TagSection {
+		if typeIndex := m.TagSection[i].Type; typeIndex < uint32(len(types)) && !types[typeIndex].IsFunc() {
+			return fmt.Errorf("tag[%d] type %d is not a function type", i, typeIndex)
+		}
+	}
+	return nil
+}
+
+// isCompositeSubtypeOf returns true if the definition of sub matches the one of super, so that
+// sub can declare super as its supertype.
+func isCompositeSubtypeOf(types []FunctionType, sub, super *FunctionType) bool {
+	if sub.Kind != super.Kind {
+		return false
+	}
+	switch sub.Kind {
+	case CompositeKindFunc:
+		if len(sub.Params) != len(super.Params) || len(sub.Results) != len(super.Results) {
+			return false
+		}
+		for i := range sub.Params {
+			if !isRefSubtypeOf(types, super.Params[i], sub.Params[i]) {
+				return false
+			}
+		}
+		for i := range sub.Results {
+			if !isRefSubtypeOf(types, sub.Results[i], super.Results[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		if len(sub.Fields) < len(super.Fields) {
+			return false
+		}
+		for i := range super.Fields {
+			if !isFieldSubtypeOf(types, sub.Fields[i], super.Fields[i]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// isFieldSubtypeOf returns true if the field sub matches super. Mutable fields are invariant,
+// whereas immutable fields are covariant.
+func isFieldSubtypeOf(types []FunctionType, sub, super FieldType) bool {
+	if sub.Mutable != super.Mutable {
+		return false
+	}
+	if sub.Mutable {
+		return isRefSubtypeOf(types, sub.Type, super.Type) && isRefSubtypeOf(types, super.Type, sub.Type)
+	}
+	return isRefSubtypeOf(types, sub.Type, super.Type)
+}
+
 func (m *Module) validateTableInitExprs(globals []GlobalType, numFuncs uint32) error {
 	importedGlobals := globals[:m.ImportGlobalCount]
 	for i, t := range m.TableSection {
@@ -425,7 +584,7 @@ func (m *Module) validateGlobals(globals []GlobalType, numFuncts, maxGlobals uin
 	return nil
 }
 
-func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memory *Memory, tables []Table, tags []Index, maximumFunctionIndex uint32) error {
+func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memories []*Memory, tables []Table, tags []Index, maximumFunctionIndex uint32) error {
 	if uint32(len(functions)) > maximumFunctionIndex {
 		return fmt.Errorf("too many functions (%d) in a module", len(functions))
 	}
@@ -459,7 +618,7 @@ func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions [
 		if c.GoFunc != nil {
 			continue
 		}
-		if err = m.validateFunction(vs, enabledFeatures, Index(idx), functions, globals, memory, tables, tags, declaredFuncIndexes, br); err != nil {
+		if err = m.validateFunction(vs, enabledFeatures, Index(idx), functions, globals, memories, tables, tags, declaredFuncIndexes, br); err != nil {
 			return fmt.Errorf("invalid %s: %w", m.funcDesc(SectionIDFunction, Index(idx)), err)
 		}
 	}
@@ -498,8 +657,9 @@ func (m *Module) declaredFunctionIndexes(enabledFeatures api.CoreFeatures) (ret
 	for i := range m.GlobalSection {
 		g := &m.GlobalSection[i]
 
-		_, _, initErr := evaluateConstExpr(
+		_, _, initErr := evaluateConstExprGC(
 			&g.Init,
+			m.validationConstExprGC(),
 			func(globalIndex Index) (ValueType, uint64, uint64, error) {
 				vt, err := m.resolveConstExprGlobalType(enabledFeatures, SectionIDGlobal, Index(i), globalIndex)
 				return vt, 0, 0, err
@@ -519,8 +679,9 @@ func (m *Module) declaredFunctionIndexes(enabledFeatures api.CoreFeatures) (ret
 	for i := range m.ElementSection {
 		elem := &m.ElementSection[i]
 		for _, initExpr := range elem.Init {
-			_, _, _ = evaluateConstExpr(
+			_, _, _ = evaluateConstExprGC(
 				&initExpr,
+				m.validationConstExprGC(),
 				func(globalIndex Index) (ValueType, uint64, uint64, error) {
 					vt, err := m.resolveConstExprGlobalType(enabledFeatures, SectionIDElement, Index(i), globalIndex)
 					return vt, 0, 0, err
@@ -553,17 +714,13 @@ func (m *Module) funcDesc(sectionID SectionID, sectionIndex Index) string {
 	return fmt.Sprintf("%s[%d] export[%s]", sectionIDName, sectionIndex, strings.Join(exportNames, ","))
 }
 
-func (m *Module) validateMemory(memory *Memory, globals []GlobalType, _ api.CoreFeatures) error {
-	var activeElementCount int
+func (m *Module) validateMemory(memories []*Memory, globals []GlobalType, _ api.CoreFeatures) error {
 	for i := range m.DataSection {
 		d := &m.DataSection[i]
-		if !d.IsPassive() {
-			activeElementCount++
+		if !d.IsPassive() && d.MemoryIndex >= uint32(len(memories)) {
+			return fmt.Errorf("unknown memory")
 		}
 	}
-	if activeElementCount > 0 && memory == nil {
-		return fmt.Errorf("unknown memory")
-	}
 
 	// Constant expression can only reference imported globals.
 	// https://github.com/WebAssembly/spec/blob/5900d839f38641989a9d8df2df4aee0513365d39/test/core/data.wast#L84-L91
@@ -571,7 +728,7 @@ func (m *Module) validateMemory(memory *Memory, globals []GlobalType, _ api.Core
 	for i := range m.DataSection {
 		d := &m.DataSection[i]
 		if !d.IsPassive() {
-			if err := m.validateConstExpression(importedGlobals, 0, &d.OffsetExpression, ValueTypeI32); err != nil {
+			if err := m.validateConstExpression(importedGlobals, 0, &d.OffsetExpression, memoryAddressType(memories[d.MemoryIndex])); err != nil {
 				return fmt.Errorf("calculate offset: %w", err)
 			}
 		}
@@ -609,7 +766,7 @@ func (m *Module) validateImports(enabledFeatures api.CoreFeatures) error {
 	return nil
 }
 
-func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memory *Memory, tables []Table, tags []Index) error {
+func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memories []*Memory, tables []Table, tags []Index) error {
 	for i := range m.ExportSection {
 		exp := &m.ExportSection[i]
 		index := exp.Index
@@ -629,7 +786,7 @@ func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []I
 				return fmt.Errorf("invalid export[%q] global[%d]: %w", exp.Name, index, err)
 			}
 		case ExternTypeMemory:
-			if index > 0 || memory == nil {
+			if index >= uint32(len(memories)) {
 				return fmt.Errorf("memory for export[%q] out of range", exp.Name)
 			}
 		case ExternTypeTable:
@@ -647,8 +804,9 @@ func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []I
 
 func (m *Module) validateConstExpression(globals []GlobalType, numFuncs uint32, expr *ConstantExpression, expectedType ValueType) (err error) {
 	var lastRefFuncIdx Index
-	_, typ, err := evaluateConstExpr(
+	_, typ, err := evaluateConstExprGC(
 		expr,
+		m.validationConstExprGC(),
 		func(globalIndex Index) (ValueType, uint64, uint64, error) {
 			if uint32(len(globals)) <= globalIndex {
 				return 0, 0, 0, fmt.Errorf("global index out of range")
@@ -671,7 +829,7 @@ func (m *Module) validateConstExpression(globals []GlobalType, numFuncs uint32,
 			typ = ValueTypeConcreteRef(typeIndex, false)
 		}
 	}
-	if !isRefSubtypeOf(typ, expectedType) {
+	if !isRefSubtypeOf(m.TypeSection, typ, expectedType) {
 		return fmt.Errorf("const expression type mismatch expected %s but got %s", ValueTypeName(expectedType), ValueTypeName(typ))
 	}
 	return nil
@@ -698,6 +856,11 @@ func (m *ModuleInstance) buildTags(module *Module) {
 func (m *ModuleInstance) buildGlobals(module *Module, funcRefResolver func(funcIndex Index) Reference) {
 	importedGlobals := m.Globals[:module.ImportGlobalCount]
 
+	var gc *constExprGC
+	if module.UsesGC {
+		gc = &constExprGC{types: module.TypeSection, m: m}
+	}
+
 	me := m.Engine
 	engineOwnGlobal := me.OwnsGlobals()
 	for i := Index(0); i < Index(len(module.GlobalSection)); i++ {
@@ -709,7 +872,7 @@ func (m *ModuleInstance) buildGlobals(module *Module, funcRefResolver func(funcI
 		}
 		m.Globals[i+module.ImportGlobalCount] = g
 		g.Type = gs.Type
-		g.initialize(importedGlobals, &gs.Init, funcRefResolver)
+		g.initializeGC(gc, importedGlobals, &gs.Init, funcRefResolver)
 	}
 }
 
@@ -775,12 +938,31 @@ func paramNames(localNames IndirectNameMap, funcIdx uint32, paramLen int) []stri
 	return nil
 }
 
-func (m *ModuleInstance) buildMemory(module *Module, allocator experimental.MemoryAllocator) {
-	memSec := module.MemorySection
-	if memSec != nil {
-		m.MemoryInstance = NewMemoryInstance(memSec, allocator, m.Engine)
-		m.MemoryInstance.definition = &module.MemoryDefinitionSection[0]
+// buildMemory instantiates the memories defined in the module. When memoryGuardPages is true, the memories with
+// 32-bit addresses are backed by platform.NewGuardedMemory regardless of the given allocator.
+func (m *ModuleInstance) buildMemory(module *Module, allocator experimental.MemoryAllocator, memoryGuardPages bool) error {
+	importCount := module.ImportMemoryCount
+	for i := range module.MemorySection {
+		idx := importCount + Index(i)
+		memSec := &module.MemorySection[i]
+		memAllocator := allocator
+		if memoryGuardPages && !memSec.Is64 {
+			guarded, err := platform.NewGuardedMemory()
+			if err != nil {
+				return fmt.Errorf("memory[%d]: %w", idx, err)
+			}
+			memAllocator = experimental.MemoryAllocatorFunc(func(_, _ uint64) experimental.LinearMemory {
+				return guarded
+			})
+		}
+		mem := NewMemoryInstance(memSec, memAllocator, m.Engine)
+		mem.definition = &module.MemoryDefinitionSection[idx]
+		m.Memories[idx] = mem
+	}
+	if len(m.Memories) > 0 {
+		m.MemoryInstance = m.Memories[0]
 	}
+	return nil
 }
 
 // Index is the offset in an index, not necessarily an absolute position in a Module section. This is because
@@ -820,8 +1002,59 @@ type FunctionType struct {
 
 	// RecGroupPosition is the 0-based position of this type within its rec group.
 	RecGroupPosition int
+
+	// Kind is the kind of composite type defined. The zero value is a function type, so
+	// only struct and array types defined by the GC proposal need to set this.
+	Kind CompositeKind
+
+	// Fields are the fields of a struct type, or the single element field of an array type.
+	Fields []FieldType
+
+	// SuperType is the index of the declared supertype when HasSuperType is true.
+	SuperType Index
+
+	// HasSuperType is true when the type was declared with a supertype via "sub".
+	HasSuperType bool
+
+	// NonFinal is true when the type was declared with "sub" without "final", so that it can
+	// be used as the supertype of other types.
+	NonFinal bool
+}
+
+// CompositeKind is the kind of type defined in the type section.
+type CompositeKind byte
+
+const (
+	// CompositeKindFunc is a function type.
+	CompositeKindFunc CompositeKind = iota
+	// CompositeKindStruct is a struct type defined by the GC proposal.
+	CompositeKindStruct
+	// CompositeKindArray is an array type defined by the GC proposal.
+	CompositeKindArray
+)
+
+// FieldType is the type of struct field or an array element.
+type FieldType struct {
+	// Type is the storage type, which is either a ValueType or a packed type such as ValueTypeI8.
+	Type ValueType
+	// Mutable is true if the field can be written after allocation.
+	Mutable bool
+}
+
+// IsPacked returns true if the field is stored as a packed i8 or i16.
+func (f FieldType) IsPacked() bool { return f.Type == ValueTypeI8 || f.Type == ValueTypeI16 }
+
+// Unpacked returns the type of values read from or written to this field.
+func (f FieldType) Unpacked() ValueType {
+	if f.IsPacked() {
+		return ValueTypeI32
+	}
+	return f.Type
 }
 
+// IsFunc returns true if this is a function type, as opposed to a struct or array type.
+func (f *FunctionType) IsFunc() bool { return f.Kind == CompositeKindFunc }
+
 func (f *FunctionType) CacheNumInUint64() {
 	if f.ParamNumInUint64 == 0 {
 		for _, tp := range f.Params {
@@ -861,25 +1094,58 @@ func (f *FunctionType) key() string {
 	if f.string != "" {
 		return f.string
 	}
+	f.string = f.keyWith(ValueTypeName)
+	return f.string
+}
+
+// keyWith generates the key of this type, where valueTypeName names the value types including
+// the reference to the supertype.
+func (f *FunctionType) keyWith(valueTypeName func(ValueType) string) string {
 	var ret string
-	for _, b := range f.Params {
-		ret += ValueTypeName(b)
-	}
-	if len(f.Params) == 0 {
-		ret += "v_"
-	} else {
-		ret += "_"
-	}
-	for _, b := range f.Results {
-		ret += ValueTypeName(b)
-	}
-	if len(f.Results) == 0 {
-		ret += "v"
+	switch f.Kind {
+	case CompositeKindFunc:
+		for _, b := range f.Params {
+			ret += valueTypeName(b)
+		}
+		if len(f.Params) == 0 {
+			ret += "v_"
+		} else {
+			ret += "_"
+		}
+		for _, b := range f.Results {
+			ret += valueTypeName(b)
+		}
+		if len(f.Results) == 0 {
+			ret += "v"
+		}
+	default:
+		// Keys of struct and array types start with a keyword, e.g. "struct(i32 mut f64)",
+		// so that they never collide with the key of a function type.
+		if f.Kind == CompositeKindStruct {
+			ret = "struct("
+		} else {
+			ret = "array("
+		}
+		for i, fld := range f.Fields {
+			if i > 0 {
+				ret += " "
+			}
+			if fld.Mutable {
+				ret += "mut "
+			}
+			ret += valueTypeName(fld.Type)
+		}
+		ret += ")"
 	}
 	if f.RecGroupSize > 1 {
 		ret += fmt.Sprintf("|rec%d/%d", f.RecGroupPosition, f.RecGroupSize)
 	}
-	f.string = ret
+	if f.HasSuperType {
+		ret += "|sub" + valueTypeName(ValueTypeConcreteRef(f.SuperType, false))
+	}
+	if f.NonFinal {
+		ret += "|open"
+	}
 	return ret
 }
 
@@ -917,11 +1183,18 @@ type Memory struct {
 	IsMaxEncoded bool
 	// IsShared true if the memory is shared for access from multiple agents.
 	IsShared bool
+	// Is64 true if the memory is indexed by i64 addresses, as defined in the memory64 proposal.
+	Is64 bool
 }
 
 // Validate ensures values assigned to Min, Cap and Max are within valid thresholds.
+//
+// Note: memoryLimitPages is capped at MemoryLimitPages unless the memory is 64-bit.
 func (m *Memory) Validate(memoryLimitPages uint32) error {
 	min, capacity, max := m.Min, m.Cap, m.Max
+	if !m.Is64 && memoryLimitPages > MemoryLimitPages {
+		memoryLimitPages = MemoryLimitPages
+	}
 
 	if max > memoryLimitPages {
 		return fmt.Errorf("max %d pages (%s) over limit of %d pages (%s)",
@@ -1000,6 +1273,9 @@ type DataSegment struct {
 	OffsetExpression ConstantExpression
 	Init             []byte
 	Passive          bool
+	// MemoryIndex is the index of the memory an active segment is copied into. This is always zero unless
+	// experimental.CoreFeaturesMultiMemory is enabled.
+	MemoryIndex Index
 }
 
 // IsPassive returns true if this data segment is "passive" in the sense that memory offset and
@@ -1083,7 +1359,7 @@ type NameMapAssoc struct {
 }
 
 // AllDeclarations returns all declarations for functions, globals, memories, tables and tags in a module including imported ones.
-func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, memory *Memory, tables []Table, tags []Index, err error) {
+func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, memories []*Memory, tables []Table, tags []Index, err error) {
 	for i := range m.ImportSection {
 		imp := &m.ImportSection[i]
 		switch imp.Type {
@@ -1092,7 +1368,7 @@ func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, mem
 		case ExternTypeGlobal:
 			globals = append(globals, imp.DescGlobal)
 		case ExternTypeMemory:
-			memory = imp.DescMem
+			memories = append(memories, imp.DescMem)
 		case ExternTypeTable:
 			tables = append(tables, imp.DescTable)
 		case ExternTypeTag:
@@ -1109,12 +1385,8 @@ func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, mem
 		t := &m.TagSection[i]
 		tags = append(tags, t.Type)
 	}
-	if m.MemorySection != nil {
-		if memory != nil { // shouldn't be possible due to Validate
-			err = errors.New("at most one table allowed in module")
-			return
-		}
-		memory = m.MemorySection
+	for i := range m.MemorySection {
+		memories = append(memories, &m.MemorySection[i])
 	}
 	if m.TableSection != nil {
 		tables = append(tables, m.TableSection...)
@@ -1216,6 +1488,24 @@ const (
 	ValueTypeFuncref   ValueType = 0x70
 	ValueTypeExternref ValueType = 0x6f
 	ValueTypeExnref    ValueType = 0x69
+
+	// The following abstract reference types are defined by the GC proposal.
+
+	ValueTypeAnyref        ValueType = 0x6e
+	ValueTypeEqref         ValueType = 0x6d
+	ValueTypeI31ref        ValueType = 0x6c
+	ValueTypeStructref     ValueType = 0x6b
+	ValueTypeArrayref      ValueType = 0x6a
+	ValueTypeNullref       ValueType = 0x71
+	ValueTypeNullfuncref   ValueType = 0x73
+	ValueTypeNullexternref ValueType = 0x72
+	ValueTypeNullexnref    ValueType = 0x74
+
+	// ValueTypeI8 and ValueTypeI16 are packed storage types, which are only valid as
+	// the type of struct fields and array elements.
+
+	ValueTypeI8  ValueType = 0x78
+	ValueTypeI16 ValueType = 0x77
 )
 
 // Kind returns the base type byte (bits 0-7).
@@ -1223,9 +1513,16 @@ func (v ValueType) Kind() byte { return byte(v) }
 
 // IsRef returns true if this is a reference type (including non-nullable variants).
 func (v ValueType) IsRef() bool {
-	k := v.Kind()
-	return k == ValueTypeFuncref.Kind() || k == ValueTypeExternref.Kind() || k == ValueTypeExnref.Kind() ||
-		v&flagConcreteRef != 0
+	if v&flagConcreteRef != 0 {
+		return true
+	}
+	switch ValueType(v.Kind()) {
+	case ValueTypeFuncref, ValueTypeExternref, ValueTypeExnref,
+		ValueTypeAnyref, ValueTypeEqref, ValueTypeI31ref, ValueTypeStructref, ValueTypeArrayref,
+		ValueTypeNullref, ValueTypeNullfuncref, ValueTypeNullexternref, ValueTypeNullexnref:
+		return true
+	}
+	return false
 }
 
 // IsNullable returns true if this reference type is nullable. Must only be called on ref types.
@@ -1266,8 +1563,71 @@ const (
 	HeapTypeExtern int64 = -17
 	// HeapTypeExn is the abstract heap type for exception references.
 	HeapTypeExn int64 = -23
+	// HeapTypeAny is the abstract heap type for any internal reference.
+	HeapTypeAny int64 = -18
+	// HeapTypeEq is the abstract heap type for references comparable with ref.eq.
+	HeapTypeEq int64 = -19
+	// HeapTypeI31 is the abstract heap type for unboxed 31-bit scalars.
+	HeapTypeI31 int64 = -20
+	// HeapTypeStruct is the abstract heap type for struct references.
+	HeapTypeStruct int64 = -21
+	// HeapTypeArray is the abstract heap type for array references.
+	HeapTypeArray int64 = -22
+	// HeapTypeNone is the bottom heap type of internal references.
+	HeapTypeNone int64 = -15
+	// HeapTypeNoFunc is the bottom heap type of function references.
+	HeapTypeNoFunc int64 = -13
+	// HeapTypeNoExtern is the bottom heap type of external references.
+	HeapTypeNoExtern int64 = -14
+	// HeapTypeNoExn is the bottom heap type of exception references.
+	HeapTypeNoExn int64 = -12
 )
 
+// AbstractRefType returns the nullable reference type of the abstract heap type ht,
+// or false if ht is not an abstract heap type.
+func AbstractRefType(ht int64) (ValueType, bool) {
+	switch ht {
+	case HeapTypeFunc:
+		return ValueTypeFuncref, true
+	case HeapTypeExtern:
+		return ValueTypeExternref, true
+	case HeapTypeExn:
+		return ValueTypeExnref, true
+	case HeapTypeAny:
+		return ValueTypeAnyref, true
+	case HeapTypeEq:
+		return ValueTypeEqref, true
+	case HeapTypeI31:
+		return ValueTypeI31ref, true
+	case HeapTypeStruct:
+		return ValueTypeStructref, true
+	case HeapTypeArray:
+		return ValueTypeArrayref, true
+	case HeapTypeNone:
+		return ValueTypeNullref, true
+	case HeapTypeNoFunc:
+		return ValueTypeNullfuncref, true
+	case HeapTypeNoExtern:
+		return ValueTypeNullexternref, true
+	case HeapTypeNoExn:
+		return ValueTypeNullexnref, true
+	}
+	return 0, false
+}
+
+// IsGCRefType returns true if t is one of the abstract reference types introduced by the GC proposal.
+func IsGCRefType(t ValueType) bool {
+	if t.IsConcreteRef() {
+		return false
+	}
+	switch ValueType(t.Kind()) {
+	case ValueTypeAnyref, ValueTypeEqref, ValueTypeI31ref, ValueTypeStructref, ValueTypeArrayref,
+		ValueTypeNullref, ValueTypeNullfuncref, ValueTypeNullexternref, ValueTypeNullexnref:
+		return true
+	}
+	return false
+}
+
 // ValueTypeName returns the name of a ValueType.
 func ValueTypeName(t ValueType) string {
 	if t.IsConcreteRef() {
@@ -1302,36 +1662,198 @@ func ValueTypeName(t ValueType) string {
 			return "(ref exn)"
 		}
 		return "exnref"
+	case ValueTypeI8:
+		return "i8"
+	case ValueTypeI16:
+		return "i16"
+	}
+	if names, ok := gcRefTypeNames[t.AsNullable()]; ok {
+		if !t.IsNullable() {
+			return "(ref " + names[1] + ")"
+		}
+		return names[0]
 	}
 	return "unknown"
 }
 
+// gcRefTypeNames are the short form and heap type names of the abstract reference types
+// added by the GC proposal.
+var gcRefTypeNames = map[ValueType][2]string{
+	ValueTypeAnyref:        {"anyref", "any"},
+	ValueTypeEqref:         {"eqref", "eq"},
+	ValueTypeI31ref:        {"i31ref", "i31"},
+	ValueTypeStructref:     {"structref", "struct"},
+	ValueTypeArrayref:      {"arrayref", "array"},
+	ValueTypeNullref:       {"nullref", "none"},
+	ValueTypeNullfuncref:   {"nullfuncref", "nofunc"},
+	ValueTypeNullexternref: {"nullexternref", "noextern"},
+	ValueTypeNullexnref:    {"nullexnref", "noexn"},
+}
+
 func isReferenceValueType(vt ValueType) bool {
 	return vt.IsRef()
 }
 
 // isRefSubtypeOf returns true if actual is a subtype of (or equal to) expected.
-// Non-nullable is a subtype of nullable. Concrete function refs are subtypes of funcref.
-func isRefSubtypeOf(actual, expected ValueType) bool {
+// Non-nullable is a subtype of nullable, and heap types follow the subtyping rules of the
+// GC proposal, where types are used to resolve concrete type indices. Concrete type indices
+// out of the range of types are treated as function types.
+func isRefSubtypeOf(types []FunctionType, actual, expected ValueType) bool {
 	if actual == expected {
 		return true
 	}
-	// Non-nullable is subtype of nullable (same kind/index).
-	if actual.AsNullable() == expected.AsNullable() && expected.IsNullable() {
+	if !actual.IsRef() || !expected.IsRef() {
+		return false
+	}
+	// Nullable is never a subtype of non-nullable.
+	if actual.IsNullable() && !expected.IsNullable() {
+		return false
+	}
+	return isHeapSubtypeOf(types, actual.AsNullable(), expected.AsNullable())
+}
+
+// isHeapSubtypeOf returns true if the heap type of actual is a subtype of the one of expected.
+// Both must be nullable reference types.
+func isHeapSubtypeOf(types []FunctionType, actual, expected ValueType) bool {
+	if actual == expected {
 		return true
 	}
-	// Concrete function ref is subtype of (abstract) funcref (nullable or non-nullable).
-	if actual.IsConcreteRef() && expected.Kind() == ValueTypeFuncref.Kind() {
-		if !actual.IsNullable() || expected.IsNullable() {
+	if expected.IsConcreteRef() {
+		if actual.IsConcreteRef() {
+			return isConcreteSubtypeOf(types, actual.TypeIndex(), expected.TypeIndex())
+		}
+		switch concreteKind(types, expected.TypeIndex()) {
+		case CompositeKindFunc:
+			return actual == ValueTypeNullfuncref
+		default:
+			return actual == ValueTypeNullref
+		}
+	}
+	if actual.IsConcreteRef() {
+		switch concreteKind(types, actual.TypeIndex()) {
+		case CompositeKindFunc:
+			return expected == ValueTypeFuncref
+		case CompositeKindStruct:
+			return expected == ValueTypeStructref || expected == ValueTypeEqref || expected == ValueTypeAnyref
+		default:
+			return expected == ValueTypeArrayref || expected == ValueTypeEqref || expected == ValueTypeAnyref
+		}
+	}
+	switch expected {
+	case ValueTypeAnyref:
+		switch actual {
+		case ValueTypeEqref, ValueTypeI31ref, ValueTypeStructref, ValueTypeArrayref, ValueTypeNullref:
+			return true
+		}
+	case ValueTypeEqref:
+		switch actual {
+		case ValueTypeI31ref, ValueTypeStructref, ValueTypeArrayref, ValueTypeNullref:
 			return true
 		}
+	case ValueTypeI31ref, ValueTypeStructref, ValueTypeArrayref:
+		return actual == ValueTypeNullref
+	case ValueTypeFuncref:
+		return actual == ValueTypeNullfuncref
+	case ValueTypeExternref:
+		return actual == ValueTypeNullexternref
+	case ValueTypeExnref:
+		return actual == ValueTypeNullexnref
 	}
 	return false
 }
 
+// isConcreteSubtypeOf returns true if the type at index actual is the type at index expected,
+// or one of its declared subtypes.
+func isConcreteSubtypeOf(types []FunctionType, actual, expected Index) bool {
+	for {
+		if typesEquivalent(types, actual, expected) {
+			return true
+		}
+		if actual >= uint32(len(types)) || !types[actual].HasSuperType {
+			return false
+		}
+		actual = types[actual].SuperType
+	}
+}
+
+// typesEquivalent returns true if the types at the given indexes are the same type, which is the
+// case when their rec groups are structurally identical, and they are at the same position in them.
+func typesEquivalent(types []FunctionType, a, b Index) bool {
+	if a == b {
+		return true
+	}
+	if a >= uint32(len(types)) || b >= uint32(len(types)) {
+		return false
+	}
+	c := typeCanonicalizer{types: types, keys: map[string]int{}, ids: map[Index]int{}}
+	return c.id(a) == c.id(b)
+}
+
+// typeCanonicalizer assigns the same ID to the equivalent types of a type section.
+type typeCanonicalizer struct {
+	types []FunctionType
+	// keys maps the canonical key of a type to its ID.
+	keys map[string]int
+	// ids caches the ID of the type at each index.
+	ids map[Index]int
+}
+
+// id returns the ID of the type at index i, and those of the other types of its rec group.
+func (c *typeCanonicalizer) id(i Index) int {
+	if id, ok := c.ids[i]; ok {
+		return id
+	}
+	t := &c.types[i]
+	start := i - Index(t.RecGroupPosition)
+	end := start + Index(max(t.RecGroupSize, 1))
+	// References within the rec group are relative to it, and the others are replaced with the
+	// ID of the referenced type, which is always defined before the group.
+	name := func(vt ValueType) string {
+		if !vt.IsConcreteRef() {
+			return ValueTypeName(vt)
+		}
+		prefix := "ref "
+		if vt.IsNullable() {
+			prefix = "ref null "
+		}
+		switch idx := vt.TypeIndex(); {
+		case idx >= start && idx < end:
+			return fmt.Sprintf("(%srec.%d)", prefix, idx-start)
+		case idx < start:
+			return fmt.Sprintf("(%sid.%d)", prefix, c.id(idx))
+		default:
+			return ValueTypeName(vt)
+		}
+	}
+	var group strings.Builder
+	for j := start; j < end && j < uint32(len(c.types)); j++ {
+		group.WriteString(c.types[j].keyWith(name))
+		group.WriteByte(';')
+	}
+	for j := start; j < end && j < uint32(len(c.types)); j++ {
+		key := fmt.Sprintf("%s#%d", group.String(), j-start)
+		id, ok := c.keys[key]
+		if !ok {
+			id = len(c.keys)
+			c.keys[key] = id
+		}
+		c.ids[j] = id
+	}
+	return c.ids[i]
+}
+
+// concreteKind returns the kind of the type at index i, which defaults to a function type when
+// the index is out of range.
+func concreteKind(types []FunctionType, i Index) CompositeKind {
+	if i < uint32(len(types)) {
+		return types[i].Kind
+	}
+	return CompositeKindFunc
+}
+
 // areRefTypesCompatible returns true if either type is a subtype of the other.
-func areRefTypesCompatible(a, b ValueType) bool {
-	return isRefSubtypeOf(a, b) || isRefSubtypeOf(b, a)
+func areRefTypesCompatible(types []FunctionType, a, b ValueType) bool {
+	return isRefSubtypeOf(types, a, b) || isRefSubtypeOf(types, b, a)
 }
 
 // ExternType is an alias of api.ExternType defined to simplify imports.
@@ -151,12 +151,14 @@ func (m *ModuleInstance) ensureResourcesClosed(ctx context.Context) (err error)
 	}
 
 	if sysCtx := m.Sys; sysCtx != nil { // nil if from HostModuleBuilder
-		err = sysCtx.FS().Close()
+		if !m.sysBorrowed {
+			err = sysCtx.FS().Close()
+		}
 		m.Sys = nil
 	}
 
-	if mem := m.MemoryInstance; mem != nil && mem.ownerModuleEngine == m.Engine {
-		if mem.expBuffer != nil {
+	for _, mem := range m.Memories {
+		if mem != nil && mem.ownerModuleEngine == m.Engine && mem.expBuffer != nil {
 			mem.expBuffer.Free()
 			mem.expBuffer = nil
 		}
@@ -168,6 +170,8 @@ func (m *ModuleInstance) ensureResourcesClosed(ctx context.Context) (err error)
 		}
 		m.CodeCloser = nil
 	}
+
+	m.releaseGCHeap()
 	return err
 }
 
@@ -178,27 +182,23 @@ func (m *ModuleInstance) Memory() api.Memory {
 
 // ExportedMemory implements the same method as documented on api.Module.
 func (m *ModuleInstance) ExportedMemory(name string) api.Memory {
-	_, err := m.getExport(name, ExternTypeMemory)
+	exp, err := m.getExport(name, ExternTypeMemory)
 	if err != nil {
 		return nil
 	}
-	// We Assume that we have at most one memory.
-	return m.MemoryInstance
+	return m.Memories[exp.Index]
 }
 
 // ExportedMemoryDefinitions implements the same method as documented on
 // api.Module.
 func (m *ModuleInstance) ExportedMemoryDefinitions() map[string]api.MemoryDefinition {
-	// Special case as we currently only support one memory.
-	if mem := m.MemoryInstance; mem != nil {
-		// Now, find out if it is exported
-		for name, exp := range m.Exports {
-			if exp.Type == ExternTypeMemory {
-				return map[string]api.MemoryDefinition{name: mem.definition}
-			}
+	result := map[string]api.MemoryDefinition{}
+	for name, exp := range m.Exports {
+		if exp.Type == ExternTypeMemory {
+			result[name] = m.Memories[exp.Index].definition
 		}
 	}
-	return map[string]api.MemoryDefinition{}
+	return result
 }
 
 // ExportedFunction implements the same method as documented on api.Module.
@@ -222,6 +222,37 @@ func (m *ModuleInstance) ExportedFunctionDefinitions() map[string]api.FunctionDe
 	return result
 }
 
+// ExportedTable implements the same method as documented on api.Module.
+func (m *ModuleInstance) ExportedTable(name string) api.Table {
+	exp, err := m.getExport(name, ExternTypeTable)
+	if err != nil {
+		return nil
+	}
+	return exportedTable{t: m.Tables[exp.Index]}
+}
+
+// ExportedTableDefinitions implements the same method as documented on
+// api.Module.
+func (m *ModuleInstance) ExportedTableDefinitions() map[string]api.TableDefinition {
+	result := map[string]api.TableDefinition{}
+	for name, exp := range m.Exports {
+		if exp.Type == ExternTypeTable {
+			result[name] = m.Tables[exp.Index].definition
+		}
+	}
+	return result
+}
+
+// ExportedTag returns the exported tag instance of the given name, or nil if
+// it is not exported.
+func (m *ModuleInstance) ExportedTag(name string) *TagInstance {
+	exp, err := m.getExport(name, ExternTypeTag)
+	if err != nil {
+		return nil
+	}
+	return m.Tags[exp.Index]
+}
+
 // GlobalVal is an internal hack to get the lower 64 bits of a global.
 func (m *ModuleInstance) GlobalVal(idx Index) uint64 {
 	return m.Globals[idx].Val
@@ -462,7 +462,7 @@ func TestModuleInstance_ensureResourcesClosed(t *testing.T) {
 		owner := &mockModuleEngine{}
 		buf := &freeRecordingMemory{}
 		mem := &MemoryInstance{expBuffer: buf, ownerModuleEngine: owner}
-		m := &ModuleInstance{MemoryInstance: mem, Engine: owner}
+		m := &ModuleInstance{MemoryInstance: mem, Memories: []*MemoryInstance{mem}, Engine: owner}
 
 		require.NoError(t, m.ensureResourcesClosed(context.Background()))
 		require.True(t, buf.freed)
@@ -474,7 +474,7 @@ func TestModuleInstance_ensureResourcesClosed(t *testing.T) {
 		importer := &mockModuleEngine{}
 		buf := &freeRecordingMemory{}
 		mem := &MemoryInstance{expBuffer: buf, ownerModuleEngine: owner}
-		m := &ModuleInstance{MemoryInstance: mem, Engine: importer}
+		m := &ModuleInstance{MemoryInstance: mem, Memories: []*MemoryInstance{mem}, Engine: importer}
 
 		require.NoError(t, m.ensureResourcesClosed(context.Background()))
 		require.False(t, buf.freed)
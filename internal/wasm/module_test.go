@@ -9,6 +9,7 @@ import (
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/u32"
 	"github.com/tetratelabs/wazero/internal/u64"
@@ -138,7 +139,7 @@ func TestModule_allDeclarations(t *testing.T) {
 		module            *Module
 		expectedFunctions []Index
 		expectedGlobals   []GlobalType
-		expectedMemory    *Memory
+		expectedMemories  []*Memory
 		expectedTables    []Table
 		expectedTags      []Index
 	}{
@@ -187,13 +188,20 @@ func TestModule_allDeclarations(t *testing.T) {
 			module: &Module{
 				ImportSection: []Import{{Type: ExternTypeMemory, DescMem: &Memory{Min: 1, Max: 10}}},
 			},
-			expectedMemory: &Memory{Min: 1, Max: 10},
+			expectedMemories: []*Memory{{Min: 1, Max: 10}},
 		},
 		{
 			module: &Module{
-				MemorySection: &Memory{Min: 100},
+				MemorySection: []Memory{{Min: 100}},
 			},
-			expectedMemory: &Memory{Min: 100},
+			expectedMemories: []*Memory{{Min: 100}},
+		},
+		{
+			module: &Module{
+				ImportSection: []Import{{Type: ExternTypeMemory, DescMem: &Memory{Min: 1, Max: 10}}},
+				MemorySection: []Memory{{Min: 100}, {Min: 200}},
+			},
+			expectedMemories: []*Memory{{Min: 1, Max: 10}, {Min: 100}, {Min: 200}},
 		},
 		// Tables.
 		{
@@ -233,12 +241,12 @@ func TestModule_allDeclarations(t *testing.T) {
 	for i, tt := range tests {
 		tc := tt
 		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
-			functions, globals, memory, tables, tags, err := tc.module.AllDeclarations()
+			functions, globals, memories, tables, tags, err := tc.module.AllDeclarations()
 			require.NoError(t, err)
 			require.Equal(t, tc.expectedFunctions, functions)
 			require.Equal(t, tc.expectedGlobals, globals)
 			require.Equal(t, tc.expectedTables, tables)
-			require.Equal(t, tc.expectedMemory, memory)
+			require.Equal(t, tc.expectedMemories, memories)
 			require.Equal(t, tc.expectedTags, tags)
 		})
 	}
@@ -581,7 +589,7 @@ func TestModule_validateMemory(t *testing.T) {
 		m := Module{DataSection: []DataSegment{{
 			OffsetExpression: NewConstantExpressionFromOpcode(OpcodeUnreachable, nil),
 		}}}
-		err := m.validateMemory(&Memory{}, nil, api.CoreFeaturesV1)
+		err := m.validateMemory([]*Memory{{}}, nil, api.CoreFeaturesV1)
 		require.EqualError(t, err, "calculate offset: invalid opcode for const expression: 0x0")
 	})
 	t.Run("ok", func(t *testing.T) {
@@ -589,7 +597,7 @@ func TestModule_validateMemory(t *testing.T) {
 			Init:             []byte{0x1},
 			OffsetExpression: NewConstantExpressionFromI32(1),
 		}}}
-		err := m.validateMemory(&Memory{}, nil, api.CoreFeaturesV1)
+		err := m.validateMemory([]*Memory{{}}, nil, api.CoreFeaturesV1)
 		require.NoError(t, err)
 	})
 }
@@ -676,7 +684,7 @@ func TestModule_validateExports(t *testing.T) {
 		exportSection   []Export
 		functions       []Index
 		globals         []GlobalType
-		memory          *Memory
+		memories        []*Memory
 		tables          []Table
 		expectedErr     string
 	}{
@@ -743,7 +751,13 @@ func TestModule_validateExports(t *testing.T) {
 			name:            "memory",
 			enabledFeatures: api.CoreFeaturesV1,
 			exportSection:   []Export{{Type: ExternTypeMemory, Index: 0}},
-			memory:          &Memory{},
+			memories:        []*Memory{{}},
+		},
+		{
+			name:            "multiple memories",
+			enabledFeatures: api.CoreFeaturesV1,
+			exportSection:   []Export{{Type: ExternTypeMemory, Index: 0}, {Type: ExternTypeMemory, Index: 1}},
+			memories:        []*Memory{{}, {}},
 		},
 		{
 			name:            "memory out of range",
@@ -758,7 +772,7 @@ func TestModule_validateExports(t *testing.T) {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
 			m := Module{ExportSection: tc.exportSection}
-			err := m.validateExports(tc.enabledFeatures, tc.functions, tc.globals, tc.memory, tc.tables, nil)
+			err := m.validateExports(tc.enabledFeatures, tc.functions, tc.globals, tc.memories, tc.tables, nil)
 			if tc.expectedErr != "" {
 				require.EqualError(t, err, tc.expectedErr)
 			} else {
@@ -852,22 +866,58 @@ func TestModule_buildGlobals(t *testing.T) {
 func TestModule_buildMemoryInstance(t *testing.T) {
 	t.Run("nil", func(t *testing.T) {
 		m := ModuleInstance{}
-		m.buildMemory(&Module{}, nil)
+		require.NoError(t, m.buildMemory(&Module{}, nil, false))
 		require.Nil(t, m.MemoryInstance)
 	})
 	t.Run("non-nil", func(t *testing.T) {
 		min := uint32(1)
 		max := uint32(10)
 		mDef := MemoryDefinition{moduleName: "foo"}
-		m := ModuleInstance{}
-		m.buildMemory(&Module{
-			MemorySection:           &Memory{Min: min, Cap: min, Max: max},
+		m := ModuleInstance{Memories: make([]*MemoryInstance, 1)}
+		require.NoError(t, m.buildMemory(&Module{
+			MemorySection:           []Memory{{Min: min, Cap: min, Max: max}},
 			MemoryDefinitionSection: []MemoryDefinition{mDef},
-		}, nil)
+		}, nil, false))
 		mem := m.MemoryInstance
 		require.Equal(t, min, mem.Min)
 		require.Equal(t, max, mem.Max)
 		require.Equal(t, &mDef, mem.definition)
+		require.Equal(t, []*MemoryInstance{mem}, m.Memories)
+	})
+	t.Run("multiple", func(t *testing.T) {
+		imported := &MemoryInstance{}
+		m := ModuleInstance{Memories: []*MemoryInstance{imported, nil, nil}}
+		module := &Module{
+			ImportMemoryCount:       1,
+			MemorySection:           []Memory{{Min: 1, Cap: 1, Max: 2}, {Min: 3, Cap: 3, Max: 4}},
+			MemoryDefinitionSection: []MemoryDefinition{{index: 0}, {index: 1}, {index: 2}},
+		}
+		m.MemoryInstance = imported
+		require.NoError(t, m.buildMemory(module, nil, false))
+		require.Equal(t, imported, m.MemoryInstance)
+		require.Equal(t, imported, m.Memories[0])
+		require.Equal(t, uint32(1), m.Memories[1].Min)
+		require.Equal(t, &module.MemoryDefinitionSection[1], m.Memories[1].definition)
+		require.Equal(t, uint32(3), m.Memories[2].Min)
+		require.Equal(t, &module.MemoryDefinitionSection[2], m.Memories[2].definition)
+	})
+	t.Run("guard pages", func(t *testing.T) {
+		if !platform.MemoryGuardPagesSupported() {
+			t.Skip()
+		}
+		m := ModuleInstance{Memories: make([]*MemoryInstance, 2)}
+		require.NoError(t, m.buildMemory(&Module{
+			MemorySection:           []Memory{{Min: 1, Cap: 1, Max: 2}, {Min: 1, Cap: 1, Max: 2, Is64: true}},
+			MemoryDefinitionSection: []MemoryDefinition{{index: 0}, {index: 1}},
+		}, nil, true))
+
+		// Only the memory with 32-bit addresses is guarded.
+		guarded := m.Memories[0]
+		require.NotNil(t, guarded.expBuffer)
+		defer guarded.expBuffer.Free()
+		require.Equal(t, int(MemoryPageSize), len(guarded.Buffer))
+		require.Equal(t, int(MemoryPageSize), cap(guarded.Buffer))
+		require.Nil(t, m.Memories[1].expBuffer)
 	})
 }
 
@@ -1043,9 +1093,9 @@ func TestModule_declaredFunctionIndexes(t *testing.T) {
 }
 
 func TestModule_AssignModuleID(t *testing.T) {
-	getID := func(bin []byte, lsns []experimental.FunctionListener, withEnsureTermination bool) ModuleID {
+	getID := func(bin []byte, lsns []experimental.FunctionListener, withEnsureTermination, withFuelMetering, withMemoryGuardPages bool, inliningBudget int) ModuleID {
 		m := Module{}
-		m.AssignModuleID(bin, lsns, withEnsureTermination)
+		m.AssignModuleID(bin, lsns, withEnsureTermination, withFuelMetering, withMemoryGuardPages, inliningBudget)
 		return m.ID
 	}
 
@@ -1056,10 +1106,20 @@ func TestModule_AssignModuleID(t *testing.T) {
 	for i, tc := range []struct {
 		bin                   []byte
 		withEnsureTermination bool
+		withFuelMetering      bool
+		withMemoryGuardPages  bool
+		inliningBudget        int
 		listeners             []experimental.FunctionListener
 	}{
 		{bin: []byte{1, 2, 3}, withEnsureTermination: false},
 		{bin: []byte{1, 2, 3}, withEnsureTermination: true},
+		{bin: []byte{1, 2, 3}, withFuelMetering: true},
+		{bin: []byte{1, 2, 3}, withEnsureTermination: true, withFuelMetering: true},
+		{bin: []byte{1, 2, 3}, withMemoryGuardPages: true},
+		{bin: []byte{1, 2, 3}, withFuelMetering: true, withMemoryGuardPages: true},
+		{bin: []byte{1, 2, 3}, inliningBudget: 32},
+		{bin: []byte{1, 2, 3}, inliningBudget: 64},
+		{bin: []byte{1, 2, 3}, withEnsureTermination: true, inliningBudget: 32},
 		{
 			bin:                   []byte{1, 2, 3},
 			listeners:             []experimental.FunctionListener{ml},
@@ -1113,7 +1173,7 @@ func TestModule_AssignModuleID(t *testing.T) {
 			withEnsureTermination: false,
 		},
 	} {
-		id := getID(tc.bin, tc.listeners, tc.withEnsureTermination)
+		id := getID(tc.bin, tc.listeners, tc.withEnsureTermination, tc.withFuelMetering, tc.withMemoryGuardPages, tc.inliningBudget)
 		_, exist := exists[id]
 		require.False(t, exist, i)
 		exists[id] = struct{}{}
@@ -50,6 +50,10 @@ type (
 		// Engine is a global context for a Store which is in responsible for compilation and execution of Wasm modules.
 		Engine Engine
 
+		// MemoryGuardPages is true if the memories with 32-bit addresses are allocated with guard pages,
+		// which allows Engine to elide their bounds checks. This is read-only after the Store is created.
+		MemoryGuardPages bool
+
 		// typeIDs maps each FunctionType.String() to a unique FunctionTypeID. This is used at runtime to
 		// do type-checks on indirect function calls.
 		typeIDs map[string]FunctionTypeID
@@ -58,8 +62,25 @@ type (
 		// Note: this is fixed to 2^27 but have this a field for testability.
 		functionMaxTypes uint32
 
+		// superTypeIDs maps the FunctionTypeID of a type declaring a supertype to the FunctionTypeID of that
+		// supertype. This is used at runtime by the casts of the GC proposal.
+		superTypeIDs map[FunctionTypeID]FunctionTypeID // guarded by mux
+
 		// mux is used to guard the fields from concurrent access.
 		mux sync.RWMutex
+
+		// gcHeaps holds the GC heaps of module instances, indexed by their heap ID minus one.
+		gcHeaps []*GCHeap // guarded by gcMux
+
+		// gcExceptions holds the exceptions kept with GCKeepException, indexed by their exnref values.
+		gcExceptions map[uint64]*Exception // guarded by gcMux
+
+		// gcMux guards gcHeaps and gcExceptions. This is separate from mux as heaps are resolved on each object access.
+		gcMux sync.RWMutex
+
+		// gcActiveCalls is the number of function calls in progress on this store, which is only
+		// tracked when the GC proposal is enabled. See GCCallEnter.
+		gcActiveCalls atomic.Int32
 	}
 
 	// ModuleInstance represents instantiated wasm module.
@@ -79,6 +100,10 @@ type (
 		Tables         []*TableInstance
 		Tags           []*TagInstance
 
+		// Memories holds every memory in the memory index space, beginning with imported ones. When non-empty,
+		// Memories[0] is the same as MemoryInstance.
+		Memories []*MemoryInstance
+
 		// Engine implements function calls for this module.
 		Engine ModuleEngine
 
@@ -105,6 +130,8 @@ type (
 		//	  parameter) because we haven't thought through capabilities based
 		//	  security implications.
 		Sys *internalsys.Context
+		// sysBorrowed is true when Sys belongs to the module which spawned this one with InstantiateThread.
+		sysBorrowed bool
 
 		// Closed is used both to guard moduleEngine.CloseWithExitCode and to store the exit code.
 		//
@@ -126,6 +153,9 @@ type (
 
 		// CloseNotifier is an experimental hook called once on close.
 		CloseNotifier experimental.CloseNotifier
+
+		// gcHeap holds the objects allocated by this module, and is nil until the first allocation.
+		gcHeap *GCHeap // guarded by s.gcMux
 	}
 
 	// DataInstance holds bytes corresponding to the data segment in a module.
@@ -180,7 +210,7 @@ func (m *ModuleInstance) GetFunctionTypeID(t *FunctionType) FunctionTypeID {
 func (m *ModuleInstance) buildElementInstances(elements []ElementSegment) {
 	m.ElementInstances = make([][]Reference, len(elements))
 	for i, elm := range elements {
-		if elm.Type.Kind() == RefTypeFuncref.Kind() && elm.Mode == ElementModePassive {
+		if elm.Mode == ElementModePassive && (elm.Type.Kind() == RefTypeFuncref.Kind() || m.usesGC()) {
 			// Only passive elements can be access as element instances.
 			// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/syntax/modules.html#element-segments
 			inits := elm.Init
@@ -218,7 +248,7 @@ func (m *ModuleInstance) applyElements(elems []ElementSegment) {
 			return
 		}
 
-		if table.Type == RefTypeExternref {
+		if table.Type == RefTypeExternref && !m.usesGC() {
 			for i := 0; i < len(elem.Init); i++ {
 				references[offset+uint32(i)] = Reference(0)
 			}
@@ -253,12 +283,11 @@ func (m *ModuleInstance) validateData(data []DataSegment) (err error) {
 			if err != nil {
 				return fmt.Errorf("%s[%d] failed to evaluate offset expression: %w", SectionIDName(SectionIDData), i, err)
 			}
-			if typ != ValueTypeI32 {
-				return fmt.Errorf("%s[%d] offset expression must return i32 but was %s", SectionIDName(SectionIDData), i, ValueTypeName(typ))
+			mem := m.Memories[d.MemoryIndex]
+			if addrType := memoryInstanceAddressType(mem); typ != addrType {
+				return fmt.Errorf("%s[%d] offset expression must return %s but was %s", SectionIDName(SectionIDData), i, ValueTypeName(addrType), ValueTypeName(typ))
 			}
-			offset := int(results[0])
-			ceil := offset + len(d.Init)
-			if offset < 0 || ceil > len(m.MemoryInstance.Buffer) {
+			if !dataSegmentInBounds(mem, results[0], d.Init) {
 				return fmt.Errorf("%s[%d]: out of bounds memory access", SectionIDName(SectionIDData), i)
 			}
 		}
@@ -276,16 +305,38 @@ func (m *ModuleInstance) applyData(data []DataSegment) error {
 		m.DataInstances[i] = d.Init
 		if !d.IsPassive() {
 			offsetExprResults := evaluateConstExprInModuleInstance(&d.OffsetExpression, m)
-			offset := int(offsetExprResults[0])
-			if offset < 0 || offset+len(d.Init) > len(m.MemoryInstance.Buffer) {
+			mem := m.Memories[d.MemoryIndex]
+			if !dataSegmentInBounds(mem, offsetExprResults[0], d.Init) {
 				return fmt.Errorf("%s[%d]: out of bounds memory access", SectionIDName(SectionIDData), i)
 			}
-			copy(m.MemoryInstance.Buffer[offset:], d.Init)
+			copy(mem.Buffer[dataSegmentOffset(mem, offsetExprResults[0]):], d.Init)
 		}
 	}
 	return nil
 }
 
+// memoryInstanceAddressType returns the value type of addresses into mem.
+func memoryInstanceAddressType(mem *MemoryInstance) ValueType {
+	if mem.Is64 {
+		return ValueTypeI64
+	}
+	return ValueTypeI32
+}
+
+// dataSegmentOffset interprets the evaluated offset expression of a data segment against mem.
+func dataSegmentOffset(mem *MemoryInstance, v uint64) uint64 {
+	if mem.Is64 {
+		return v
+	}
+	return uint64(uint32(v))
+}
+
+// dataSegmentInBounds returns true if init fits in mem at the evaluated offset expression v.
+func dataSegmentInBounds(mem *MemoryInstance, v uint64, init []byte) bool {
+	offset, size := dataSegmentOffset(mem, v), uint64(len(mem.Buffer))
+	return offset <= size && uint64(len(init)) <= size-offset
+}
+
 // GetExport returns an export of the given name and type or errs if not exported or the wrong type.
 func (m *ModuleInstance) getExport(name string, et ExternType) (*Export, error) {
 	exp, ok := m.Exports[name]
@@ -305,6 +356,7 @@ func NewStore(enabledFeatures api.CoreFeatures, engine Engine) *Store {
 		EnabledFeatures:  enabledFeatures,
 		Engine:           engine,
 		typeIDs:          map[string]FunctionTypeID{},
+		superTypeIDs:     map[FunctionTypeID]FunctionTypeID{},
 		functionMaxTypes: maximumFunctionTypes,
 	}
 }
@@ -338,6 +390,22 @@ func (s *Store) Instantiate(
 	return m, nil
 }
 
+// InstantiateThread instantiates the Source of this module again without a name, for a thread spawned by wasi-threads.
+// The result resolves the same imports, notably the shared memory, and shares Sys with this module. Closing the result
+// doesn't close Sys.
+func (m *ModuleInstance) InstantiateThread(ctx context.Context) (*ModuleInstance, error) {
+	t, err := m.s.instantiate(ctx, m.Source, "", m.Sys, m.TypeIDs)
+	if err != nil {
+		return nil, err
+	}
+	t.sysBorrowed = true
+	if err = m.s.registerModule(t); err != nil {
+		_ = t.Close(ctx)
+		return nil, err
+	}
+	return t, nil
+}
+
 func (s *Store) instantiate(
 	ctx context.Context,
 	module *Module,
@@ -350,6 +418,9 @@ func (s *Store) instantiate(
 	m.Tables = make([]*TableInstance, int(module.ImportTableCount)+len(module.TableSection))
 	m.Globals = make([]*GlobalInstance, int(module.ImportGlobalCount)+len(module.GlobalSection))
 	m.Tags = make([]*TagInstance, int(module.ImportTagCount)+len(module.TagSection))
+	if memoryCount := int(module.ImportMemoryCount) + len(module.MemorySection); memoryCount > 0 {
+		m.Memories = make([]*MemoryInstance, memoryCount)
+	}
 	m.Engine, err = s.Engine.NewModuleEngine(module, m)
 	if err != nil {
 		return nil, err
@@ -370,7 +441,9 @@ func (s *Store) instantiate(
 
 	m.buildGlobals(module, m.Engine.FunctionInstanceReference)
 	m.buildTags(module)
-	m.buildMemory(module, allocator)
+	if err = m.buildMemory(module, allocator, s.MemoryGuardPages); err != nil {
+		return nil, err
+	}
 	m.Exports = module.Exports
 	for _, exp := range m.Exports {
 		if exp.Type == ExternTypeTable {
@@ -414,27 +487,45 @@ func (s *Store) instantiate(
 	return
 }
 
+// ExternResolver resolves an import to the module instance which provides it
+// and the name of its export, or returns nil if it is not provided. When set
+// with expctxkeys.ExternResolverKey, it replaces the resolution of imports by
+// module name, as imports with the same module name may be provided by
+// different instances. This is used by the component model.
+type ExternResolver func(moduleName, name string) (*ModuleInstance, string)
+
 func (m *ModuleInstance) resolveImports(ctx context.Context, module *Module) (err error) {
 	// Check if ctx contains an ImportResolver.
 	resolveImport, _ := ctx.Value(expctxkeys.ImportResolverKey{}).(experimental.ImportResolver)
+	resolveExtern, _ := ctx.Value(expctxkeys.ExternResolverKey{}).(ExternResolver)
 
 	for moduleName, imports := range module.ImportPerModule {
 		var importedModule *ModuleInstance
-		if resolveImport != nil {
-			if v := resolveImport(moduleName); v != nil {
-				importedModule = v.(*ModuleInstance)
+		if resolveExtern == nil {
+			if resolveImport != nil {
+				if v := resolveImport(moduleName); v != nil {
+					importedModule = v.(*ModuleInstance)
+				}
 			}
-		}
-		if importedModule == nil {
-			importedModule, err = m.s.module(moduleName)
-			if err != nil {
-				return err
+			if importedModule == nil {
+				importedModule, err = m.s.module(moduleName)
+				if err != nil {
+					return err
+				}
 			}
 		}
 
 		for _, i := range imports {
+			importedModule, name := importedModule, i.Name
+			if resolveExtern != nil {
+				if importedModule, name = resolveExtern(moduleName, i.Name); importedModule == nil {
+					err = errorInvalidImport(i, errors.New("not provided"))
+					return
+				}
+			}
+
 			var imported *Export
-			imported, err = importedModule.getExport(i.Name, i.Type)
+			imported, err = importedModule.getExport(name, i.Type)
 			if err != nil {
 				return
 			}
@@ -491,7 +582,7 @@ func (m *ModuleInstance) resolveImports(ctx context.Context, module *Module) (er
 				importedTable.involvingModuleInstancesMutex.Unlock()
 			case ExternTypeMemory:
 				expected := i.DescMem
-				importedMemory := importedModule.MemoryInstance
+				importedMemory := importedModule.Memories[imported.Index]
 
 				if expected.Min > memoryBytesNumToPages(uint64(len(importedMemory.Buffer))) {
 					err = errorMinSizeMismatch(i, expected.Min, importedMemory.Min)
@@ -502,8 +593,17 @@ func (m *ModuleInstance) resolveImports(ctx context.Context, module *Module) (er
 					err = errorMaxSizeMismatch(i, expected.Max, importedMemory.Max)
 					return
 				}
-				m.MemoryInstance = importedMemory
-				m.Engine.ResolveImportedMemory(importedModule.Engine)
+
+				if expected.Is64 != importedMemory.Is64 {
+					err = errorInvalidImport(i, fmt.Errorf("address type mismatch: %s != %s",
+						ValueTypeName(memoryAddressType(expected)), ValueTypeName(memoryInstanceAddressType(importedMemory))))
+					return
+				}
+				m.Memories[i.IndexPerType] = importedMemory
+				if i.IndexPerType == 0 {
+					m.MemoryInstance = importedMemory
+				}
+				m.Engine.ResolveImportedMemory(i.IndexPerType, imported.Index, importedModule.Engine)
 			case ExternTypeGlobal:
 				expected := i.DescGlobal
 				importedGlobal := importedModule.Globals[imported.Index]
@@ -515,7 +615,7 @@ func (m *ModuleInstance) resolveImports(ctx context.Context, module *Module) (er
 				}
 
 				if expected.Mutable && expected.ValType != importedGlobal.Type.ValType ||
-					!expected.Mutable && !isRefSubtypeOf(importedGlobal.Type.ValType, expected.ValType) {
+					!expected.Mutable && !isRefSubtypeOf(module.TypeSection, importedGlobal.Type.ValType, expected.ValType) {
 					err = errorInvalidImport(i, fmt.Errorf("value type mismatch: %s != %s",
 						ValueTypeName(expected.ValType), ValueTypeName(importedGlobal.Type.ValType)))
 					return
@@ -558,8 +658,14 @@ func errorInvalidImport(i *Import, err error) error {
 // Global initialization constant expression can only reference the imported globals.
 // See the note on https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#constant-expressions%E2%91%A0
 func (g *GlobalInstance) initialize(importedGlobals []*GlobalInstance, expr *ConstantExpression, funcRefResolver func(funcIndex Index) Reference) {
-	result, _, _ := evaluateConstExpr(
+	g.initializeGC(nil, importedGlobals, expr, funcRefResolver)
+}
+
+// initializeGC is the same as initialize, but gc is used to evaluate the instructions of the GC proposal.
+func (g *GlobalInstance) initializeGC(gc *constExprGC, importedGlobals []*GlobalInstance, expr *ConstantExpression, funcRefResolver func(funcIndex Index) Reference) {
+	result, _, _ := evaluateConstExprGC(
 		expr,
+		gc,
 		func(globalIndex Index) (ValueType, uint64, uint64, error) {
 			g := importedGlobals[globalIndex]
 			return g.Type.ValType, g.Val, g.ValHi, nil
@@ -609,12 +715,15 @@ func (s *Store) GetFunctionTypeIDs(ts []FunctionType) ([]FunctionTypeID, error)
 	ret := make([]FunctionTypeID, len(ts))
 	for i := range ts {
 		t := &ts[i]
-		key := structuralTypeKey(t, ret)
+		key := structuralTypeKey(t, ret[:i])
 		id, err := s.getFunctionTypeIDByKey(key)
 		if err != nil {
 			return nil, err
 		}
 		ret[i] = id
+		if t.HasSuperType {
+			s.setSuperTypeID(id, ret[t.SuperType])
+		}
 	}
 	return ret, nil
 }
@@ -636,49 +745,51 @@ func structuralValueTypeName(vt ValueType, typeIDs []FunctionTypeID) string {
 }
 
 // structuralTypeKey returns a string key for a FunctionType that is stable
-// across modules. For signatures without concrete ref types it falls back to
+// across modules. For types without concrete ref types it falls back to
 // FunctionType.key(). When concrete refs are present, local type indices are
 // replaced with their already-assigned FunctionTypeID so that two modules
 // defining structurally identical types at different indices produce the same
 // key and share a single FunctionTypeID.
 func structuralTypeKey(ft *FunctionType, typeIDs []FunctionTypeID) string {
-	hasConcreteRef := false
-	for _, p := range ft.Params {
-		if p.IsConcreteRef() {
-			hasConcreteRef = true
-			break
+	hasConcreteRef := ft.HasSuperType
+	for _, vts := range [...][]ValueType{ft.Params, ft.Results} {
+		for _, vt := range vts {
+			hasConcreteRef = hasConcreteRef || vt.IsConcreteRef()
 		}
 	}
-	if !hasConcreteRef {
-		for _, r := range ft.Results {
-			if r.IsConcreteRef() {
-				hasConcreteRef = true
-				break
-			}
-		}
+	for _, f := range ft.Fields {
+		hasConcreteRef = hasConcreteRef || f.Type.IsConcreteRef()
 	}
 	if !hasConcreteRef {
 		return ft.key()
 	}
-	var ret string
-	for _, b := range ft.Params {
-		ret += structuralValueTypeName(b, typeIDs)
-	}
-	if len(ft.Params) == 0 {
-		ret += "v_"
-	} else {
-		ret += "_"
-	}
-	for _, b := range ft.Results {
-		ret += structuralValueTypeName(b, typeIDs)
-	}
-	if len(ft.Results) == 0 {
-		ret += "v"
+	return ft.keyWith(func(vt ValueType) string {
+		return structuralValueTypeName(vt, typeIDs)
+	})
+}
+
+// setSuperTypeID records superID as the declared supertype of the type of id.
+func (s *Store) setSuperTypeID(id, superID FunctionTypeID) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.superTypeIDs == nil {
+		s.superTypeIDs = map[FunctionTypeID]FunctionTypeID{}
 	}
-	if ft.RecGroupSize > 1 {
-		ret += fmt.Sprintf("|rec%d/%d", ft.RecGroupPosition, ft.RecGroupSize)
+	s.superTypeIDs[id] = superID
+}
+
+// isSubtypeID returns true if the type of actual is the type of expected or one of its declared subtypes.
+func (s *Store) isSubtypeID(actual, expected FunctionTypeID) bool {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	for actual != expected {
+		super, ok := s.superTypeIDs[actual]
+		if !ok {
+			return false
+		}
+		actual = super
 	}
-	return ret
+	return true
 }
 
 func (s *Store) GetFunctionTypeID(t *FunctionType) (FunctionTypeID, error) {
@@ -722,5 +833,10 @@ func (s *Store) CloseWithExitCode(ctx context.Context, exitCode uint32) error {
 	s.nameToModule = nil
 	s.nameToModuleCap = 0
 	s.typeIDs = nil
+	s.superTypeIDs = nil
+	s.gcMux.Lock()
+	s.gcHeaps = nil
+	s.gcExceptions = nil
+	s.gcMux.Unlock()
 	return errors.Join(errs...)
 }
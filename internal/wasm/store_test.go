@@ -10,6 +10,7 @@ import (
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/expctxkeys"
 	"github.com/tetratelabs/wazero/internal/internalapi"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/sys"
@@ -33,14 +34,14 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory not exported, one page",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 			},
 		},
 		{
 			name: "memory exported, different name",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				ExportSection:           []Export{{Type: ExternTypeMemory, Name: "momory", Index: 0}},
 			},
@@ -48,7 +49,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, but zero length",
 			input: &Module{
-				MemorySection:           &Memory{},
+				MemorySection:           []Memory{{}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				Exports:                 map[string]*Export{"memory": {Type: ExternTypeMemory, Name: "memory"}},
 			},
@@ -57,7 +58,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, one page",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				Exports:                 map[string]*Export{"memory": {Type: ExternTypeMemory, Name: "memory"}},
 			},
@@ -67,7 +68,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, two pages",
 			input: &Module{
-				MemorySection:           &Memory{Min: 2, Cap: 2},
+				MemorySection:           []Memory{{Min: 2, Cap: 2}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				Exports:                 map[string]*Export{"memory": {Type: ExternTypeMemory, Name: "memory"}},
 			},
@@ -118,6 +119,36 @@ func TestStore_Instantiate(t *testing.T) {
 	})
 }
 
+func TestModuleInstance_InstantiateThread(t *testing.T) {
+	s := newStore()
+	m, err := NewHostModule(
+		"foo",
+		[]string{"fn"},
+		map[string]*HostFunc{"fn": {ExportName: "fn", Code: Code{GoFunc: func() {}}}},
+		api.CoreFeaturesV1,
+	)
+	require.NoError(t, err)
+
+	sysCtx := sys.DefaultContext(nil)
+	mod, err := s.Instantiate(testCtx, m, "bar", sysCtx, []FunctionTypeID{0})
+	require.NoError(t, err)
+	defer mod.Close(testCtx)
+
+	thread, err := mod.InstantiateThread(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, "", thread.ModuleName)
+	require.Equal(t, m, thread.Source)
+	require.Equal(t, sysCtx, thread.Sys)
+	require.Equal(t, thread, s.moduleList)
+	require.Equal(t, mod, s.nameToModule["bar"])
+
+	// Closing the thread leaves Sys to the module which spawned it.
+	require.NoError(t, thread.Close(testCtx))
+	require.Nil(t, thread.Sys)
+	require.Equal(t, sysCtx, mod.Sys)
+	require.Equal(t, mod, s.moduleList)
+}
+
 func TestStore_CloseWithExitCode(t *testing.T) {
 	const importedModuleName = "imported"
 	const importingModuleName = "test"
@@ -154,7 +185,7 @@ func TestStore_CloseWithExitCode(t *testing.T) {
 				ImportFunctionCount:     1,
 				TypeSection:             []FunctionType{v_v},
 				ImportSection:           []Import{{Type: ExternTypeFunc, Module: importedModuleName, Name: "fn", DescFunc: 0}},
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				GlobalSection:           []Global{{Type: GlobalType{}, Init: NewConstantExpressionFromI32(1)}},
 				TableSection:            []Table{{Min: 10}},
@@ -201,7 +232,7 @@ func TestStore_hammer(t *testing.T) {
 		TypeSection:             []FunctionType{v_v},
 		FunctionSection:         []uint32{0},
 		CodeSection:             []Code{{Body: []byte{OpcodeEnd}}},
-		MemorySection:           &Memory{Min: 1, Cap: 1},
+		MemorySection:           []Memory{{Min: 1, Cap: 1}},
 		MemoryDefinitionSection: []MemoryDefinition{{}},
 		GlobalSection: []Global{{
 			Type: GlobalType{ValType: ValueTypeI32},
@@ -260,7 +291,7 @@ func TestStore_hammer_close(t *testing.T) {
 		TypeSection:             []FunctionType{v_v},
 		FunctionSection:         []uint32{0},
 		CodeSection:             []Code{{Body: []byte{OpcodeEnd}}},
-		MemorySection:           &Memory{Min: 1, Cap: 1},
+		MemorySection:           []Memory{{Min: 1, Cap: 1}},
 		MemoryDefinitionSection: []MemoryDefinition{{}},
 		GlobalSection: []Global{{
 			Type: GlobalType{ValType: ValueTypeI32},
@@ -439,7 +470,7 @@ func (e *mockEngine) Close() error {
 }
 
 // CompileModule implements the same method as documented on wasm.Engine.
-func (e *mockEngine) CompileModule(context.Context, *Module, []experimental.FunctionListener, bool) error {
+func (e *mockEngine) CompileModule(context.Context, *Module, []experimental.FunctionListener, bool, bool, bool) error {
 	return nil
 }
 
@@ -491,7 +522,7 @@ func (e *mockModuleEngine) ResolveImportedFunction(index, _, importedIndex Index
 }
 
 // ResolveImportedMemory implements the same method as documented on wasm.ModuleEngine.
-func (e *mockModuleEngine) ResolveImportedMemory(imp ModuleEngine) {
+func (e *mockModuleEngine) ResolveImportedMemory(_, _ Index, imp ModuleEngine) {
 	e.importedMemModEngine = imp
 }
 
@@ -701,6 +732,30 @@ func Test_resolveImports(t *testing.T) {
 		err := m.resolveImports(context.Background(), &Module{ImportPerModule: map[string][]*Import{moduleName: {{Name: "unknown"}}}})
 		require.EqualError(t, err, "\"unknown\" is not exported in module \"test\"")
 	})
+	t.Run("extern resolver", func(t *testing.T) {
+		imported := &ModuleInstance{
+			Exports: map[string]*Export{"renamed": {Type: ExternTypeGlobal, Index: 0}},
+			Globals: []*GlobalInstance{{Type: GlobalType{ValType: i32}}},
+		}
+		ctx := context.WithValue(context.Background(), expctxkeys.ExternResolverKey{}, ExternResolver(
+			func(_, importName string) (*ModuleInstance, string) {
+				if importName == name {
+					return imported, "renamed"
+				}
+				return nil, ""
+			}))
+		module := &Module{ImportPerModule: map[string][]*Import{
+			// The module isn't instantiated, so the resolver is used.
+			moduleName: {{Module: moduleName, Name: name, Type: ExternTypeGlobal, DescGlobal: GlobalType{ValType: i32}}},
+		}}
+		m := &ModuleInstance{s: newStore(), Globals: make([]*GlobalInstance, 1)}
+		require.NoError(t, m.resolveImports(ctx, module))
+		require.Equal(t, imported.Globals[0], m.Globals[0])
+
+		module.ImportPerModule[moduleName][0].Name = "unknown"
+		err := m.resolveImports(ctx, module)
+		require.EqualError(t, err, "import global[test.unknown]: not provided")
+	})
 	t.Run("func", func(t *testing.T) {
 		t.Run("ok", func(t *testing.T) {
 			s := newStore()
@@ -835,13 +890,14 @@ func Test_resolveImports(t *testing.T) {
 			importedME := &mockModuleEngine{}
 			s.nameToModule[moduleName] = &ModuleInstance{
 				MemoryInstance: memoryInst,
+				Memories:       []*MemoryInstance{memoryInst},
 				Exports: map[string]*Export{name: {
 					Type: ExternTypeMemory,
 				}},
 				ModuleName: moduleName,
 				Engine:     importedME,
 			}
-			m := &ModuleInstance{s: s, Engine: &mockModuleEngine{resolveImportsCalled: map[Index]Index{}}}
+			m := &ModuleInstance{s: s, Memories: make([]*MemoryInstance, 1), Engine: &mockModuleEngine{resolveImportsCalled: map[Index]Index{}}}
 			err := m.resolveImports(context.Background(), &Module{
 				ImportPerModule: map[string][]*Import{
 					moduleName: {{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: &Memory{Max: max}}},
@@ -853,15 +909,17 @@ func Test_resolveImports(t *testing.T) {
 		})
 		t.Run("minimum size mismatch", func(t *testing.T) {
 			importMemoryType := &Memory{Min: 2, Cap: 2}
+			importedMemory := &MemoryInstance{Min: importMemoryType.Min - 1, Cap: 2}
 			s := newStore()
 			s.nameToModule[moduleName] = &ModuleInstance{
-				MemoryInstance: &MemoryInstance{Min: importMemoryType.Min - 1, Cap: 2},
+				MemoryInstance: importedMemory,
+				Memories:       []*MemoryInstance{importedMemory},
 				Exports: map[string]*Export{name: {
 					Type: ExternTypeMemory,
 				}},
 				ModuleName: moduleName,
 			}
-			m := &ModuleInstance{s: s}
+			m := &ModuleInstance{s: s, Memories: make([]*MemoryInstance, 1)}
 			err := m.resolveImports(context.Background(), &Module{
 				ImportPerModule: map[string][]*Import{
 					moduleName: {{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: importMemoryType}},
@@ -870,9 +928,11 @@ func Test_resolveImports(t *testing.T) {
 			require.EqualError(t, err, "import memory[test.target]: minimum size mismatch: 2 > 1")
 		})
 		t.Run("maximum size mismatch", func(t *testing.T) {
+			importedMemory := &MemoryInstance{Max: MemoryLimitPages}
 			s := newStore()
 			s.nameToModule[moduleName] = &ModuleInstance{
-				MemoryInstance: &MemoryInstance{Max: MemoryLimitPages},
+				MemoryInstance: importedMemory,
+				Memories:       []*MemoryInstance{importedMemory},
 				Exports: map[string]*Export{name: {
 					Type: ExternTypeMemory,
 				}},
@@ -881,7 +941,7 @@ func Test_resolveImports(t *testing.T) {
 
 			max := uint32(10)
 			importMemoryType := &Memory{Max: max}
-			m := &ModuleInstance{s: s}
+			m := &ModuleInstance{s: s, Memories: make([]*MemoryInstance, 1)}
 			err := m.resolveImports(context.Background(), &Module{
 				ImportPerModule: map[string][]*Import{moduleName: {{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: importMemoryType}}},
 			})
@@ -891,7 +951,8 @@ func Test_resolveImports(t *testing.T) {
 }
 
 func TestModuleInstance_validateData(t *testing.T) {
-	m := &ModuleInstance{MemoryInstance: &MemoryInstance{Buffer: make([]byte, 5)}}
+	mem := &MemoryInstance{Buffer: make([]byte, 5)}
+	m := &ModuleInstance{MemoryInstance: mem, Memories: []*MemoryInstance{mem}}
 	tests := []struct {
 		name   string
 		data   []DataSegment
@@ -936,7 +997,8 @@ func TestModuleInstance_validateData(t *testing.T) {
 
 func TestModuleInstance_applyData(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
-		m := &ModuleInstance{MemoryInstance: &MemoryInstance{Buffer: make([]byte, 10)}}
+		mem := &MemoryInstance{Buffer: make([]byte, 10)}
+		m := &ModuleInstance{MemoryInstance: mem, Memories: []*MemoryInstance{mem}}
 		err := m.applyData([]DataSegment{
 			{OffsetExpression: NewConstantExpressionFromI32(0), Init: []byte{0xa, 0xf}},
 			{OffsetExpression: NewConstantExpressionFromI32(8), Init: []byte{0x1, 0x5}},
@@ -946,7 +1008,8 @@ func TestModuleInstance_applyData(t *testing.T) {
 		require.Equal(t, [][]byte{{0xa, 0xf}, {0x1, 0x5}}, m.DataInstances)
 	})
 	t.Run("error", func(t *testing.T) {
-		m := &ModuleInstance{MemoryInstance: &MemoryInstance{Buffer: make([]byte, 5)}}
+		mem := &MemoryInstance{Buffer: make([]byte, 5)}
+		m := &ModuleInstance{MemoryInstance: mem, Memories: []*MemoryInstance{mem}}
 		err := m.applyData([]DataSegment{
 			{OffsetExpression: NewConstantExpressionFromI32(8), Init: []byte{}},
 		})
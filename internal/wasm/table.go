@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/internalapi"
 )
 
 // Table describes the limits of elements and its type in a table.
@@ -100,6 +101,9 @@ type TableInstance struct {
 	// Type is either RefTypeFuncref or RefTypeExternRef.
 	Type RefType
 
+	// definition is known at compile time.
+	definition api.TableDefinition
+
 	// The following is only used when the table is exported.
 
 	// involvingModuleInstances is a set of module instances which are involved in the table instance.
@@ -141,8 +145,9 @@ func (m *Module) validateTable(enabledFeatures api.CoreFeatures, tables []Table,
 
 		// Any offset applied is to the element, not the function index: validate here if the funcidx is sound.
 		for ei, init := range elem.Init {
-			_, initType, err := evaluateConstExpr(
+			_, initType, err := evaluateConstExprGC(
 				&init,
+				m.validationConstExprGC(),
 				func(globalIndex Index) (ValueType, uint64, uint64, error) {
 					if globalIndex >= Index(globalsCount) {
 						return 0, 0, 0, fmt.Errorf("%s[%d].init[%d] global index %d out of range", SectionIDName(SectionIDElement), idx, ei, globalIndex)
@@ -163,15 +168,15 @@ func (m *Module) validateTable(enabledFeatures api.CoreFeatures, tables []Table,
 
 			switch elem.Type {
 			case RefTypeFuncref:
-				if initType != ValueTypeFuncref {
+				if !isRefSubtypeOf(m.TypeSection, initType, ValueTypeFuncref) {
 					return fmt.Errorf("%s[%d].init[%d] must be funcref but was %s", SectionIDName(SectionIDElement), idx, ei, ValueTypeName(initType))
 				}
 			case RefTypeExternref:
-				if initType != ValueTypeExternref {
+				if !isRefSubtypeOf(m.TypeSection, initType, ValueTypeExternref) {
 					return fmt.Errorf("%s[%d].init[%d] must be externref but was %s", SectionIDName(SectionIDElement), idx, ei, ValueTypeName(initType))
 				}
 			default:
-				if !isRefSubtypeOf(initType, elem.Type) && initType != ValueTypeFuncref {
+				if !isRefSubtypeOf(m.TypeSection, initType, elem.Type) && initType != ValueTypeFuncref {
 					return fmt.Errorf("%s[%d].init[%d] must be %s but was %s",
 						SectionIDName(SectionIDElement), idx, ei, ValueTypeName(elem.Type), ValueTypeName(initType))
 				}
@@ -184,7 +189,7 @@ func (m *Module) validateTable(enabledFeatures api.CoreFeatures, tables []Table,
 			}
 
 			t := tables[elem.TableIndex]
-			if !isRefSubtypeOf(elem.Type, t.Type) {
+			if !isRefSubtypeOf(m.TypeSection, elem.Type, t.Type) {
 				return fmt.Errorf("element type mismatch: table has %s but element has %s",
 					RefTypeName(t.Type), RefTypeName(elem.Type),
 				)
@@ -246,7 +251,7 @@ func (m *ModuleInstance) buildTables(module *Module, skipBoundCheck bool) (err e
 		tsec := &module.TableSection[i]
 		t := &TableInstance{
 			References: make([]Reference, tsec.Min), Min: tsec.Min, Max: tsec.Max,
-			Type: tsec.Type,
+			Type: tsec.Type, definition: module.TableDefinition(idx),
 		}
 		if tsec.InitExpr != nil {
 			initVals := evaluateConstExprInModuleInstance(tsec.InitExpr, m)
@@ -319,3 +324,44 @@ func (t *TableInstance) Grow(delta uint32, initialRef Reference) (currentLen uin
 	}
 	return
 }
+
+// exportedTable wraps TableInstance to implement api.Table.
+type exportedTable struct {
+	internalapi.WazeroOnlyType
+	t *TableInstance
+}
+
+// Definition implements the same method as documented on api.Table.
+func (t exportedTable) Definition() api.TableDefinition {
+	return t.t.definition
+}
+
+// Size implements the same method as documented on api.Table.
+func (t exportedTable) Size() uint32 {
+	return uint32(len(t.t.References))
+}
+
+// Grow implements the same method as documented on api.Table.
+func (t exportedTable) Grow(delta uint32, init uint64) (previousSize uint32, ok bool) {
+	if previousSize = t.t.Grow(delta, Reference(init)); previousSize == 0xffffffff {
+		return 0, false
+	}
+	return previousSize, true
+}
+
+// Get implements the same method as documented on api.Table.
+func (t exportedTable) Get(offset uint32) (uint64, bool) {
+	if offset >= uint32(len(t.t.References)) {
+		return 0, false
+	}
+	return uint64(t.t.References[offset]), true
+}
+
+// Set implements the same method as documented on api.Table.
+func (t exportedTable) Set(offset uint32, v uint64) bool {
+	if offset >= uint32(len(t.t.References)) {
+		return false
+	}
+	t.t.References[offset] = Reference(v)
+	return true
+}
@@ -0,0 +1,49 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestModule_TableDefinition(t *testing.T) {
+	max := uint32(3)
+	m := &Module{
+		ImportSection: []Import{
+			{Type: ExternTypeFunc},
+			{Module: "env", Name: "table", Type: ExternTypeTable, DescTable: Table{Type: RefTypeFuncref}},
+		},
+		ExportSection: []Export{
+			{Name: "imported", Type: ExternTypeTable, Index: 0},
+			{Name: "defined", Type: ExternTypeTable, Index: 1},
+			{Name: "defined2", Type: ExternTypeTable, Index: 1},
+			{Name: "memory", Type: ExternTypeMemory, Index: 1},
+		},
+		ImportTableCount: 1,
+		TableSection:     []Table{{Type: RefTypeExternref, Min: 2, Max: &max}},
+	}
+
+	imported := m.TableDefinition(0)
+	require.Equal(t, uint32(0), imported.Index())
+	moduleName, name, isImport := imported.Import()
+	require.Equal(t, "env", moduleName)
+	require.Equal(t, "table", name)
+	require.True(t, isImport)
+	require.Equal(t, []string{"imported"}, imported.ExportNames())
+	require.Equal(t, api.ValueTypeFuncref, imported.Type())
+	require.Equal(t, uint32(0), imported.Min())
+	_, ok := imported.Max()
+	require.False(t, ok)
+
+	defined := m.TableDefinition(1)
+	require.Equal(t, uint32(1), defined.Index())
+	_, _, isImport = defined.Import()
+	require.False(t, isImport)
+	require.Equal(t, []string{"defined", "defined2"}, defined.ExportNames())
+	require.Equal(t, api.ValueTypeExternref, defined.Type())
+	require.Equal(t, uint32(2), defined.Min())
+	actualMax, ok := defined.Max()
+	require.True(t, ok)
+	require.Equal(t, max, actualMax)
+}
@@ -989,6 +989,11 @@ func TestModule_buildTables(t *testing.T) {
 			err := m.buildTables(tc.module, false)
 			require.NoError(t, err)
 
+			// Defined tables have the definition of the module, which is compared by identity.
+			for i := len(tc.importedTables); i < len(m.Tables); i++ {
+				require.Equal(t, api.TableDefinition(tc.module.TableDefinition(Index(i))), m.Tables[i].definition)
+				m.Tables[i].definition = nil
+			}
 			require.Equal(t, tc.expectedTables, m.Tables)
 		})
 	}
@@ -1137,3 +1142,30 @@ func TestTableInstance_Grow(t *testing.T) {
 		})
 	}
 }
+
+func TestExportedTable(t *testing.T) {
+	max := uint32(4)
+	ti := &TableInstance{References: []Reference{1, 0}, Max: &max, definition: &TableDefinition{}}
+	table := exportedTable{t: ti}
+	require.Equal(t, ti.definition, table.Definition())
+	require.Equal(t, uint32(2), table.Size())
+
+	v, ok := table.Get(0)
+	require.True(t, ok)
+	require.Equal(t, uint64(1), v)
+	_, ok = table.Get(2)
+	require.False(t, ok)
+
+	require.True(t, table.Set(1, 5))
+	require.Equal(t, Reference(5), ti.References[1])
+	require.False(t, table.Set(2, 5))
+
+	prev, ok := table.Grow(2, 7)
+	require.True(t, ok)
+	require.Equal(t, uint32(2), prev)
+	require.Equal(t, []Reference{1, 5, 7, 7}, ti.References)
+
+	_, ok = table.Grow(1, 0)
+	require.False(t, ok)
+	require.Equal(t, uint32(4), table.Size())
+}
@@ -37,6 +37,13 @@ var (
 	ErrRuntimeUncaughtException = New("uncaught exception")
 	// ErrRuntimeNullReference indicates a null reference was used where a non-null reference was expected.
 	ErrRuntimeNullReference = New("null reference")
+	// ErrRuntimeCastFailure indicates that ref.cast or a cast in br_on_cast_fail was made against a reference of
+	// an incompatible type.
+	ErrRuntimeCastFailure = New("cast failure")
+	// ErrRuntimeOutOfBoundsArrayAccess indicates that the program tried to access an array element beyond its length.
+	ErrRuntimeOutOfBoundsArrayAccess = New("out of bounds array access")
+	// ErrRuntimeAllocationFailure indicates that a struct or an array could not be allocated in the GC heap.
+	ErrRuntimeAllocationFailure = New("allocation failure")
 )
 
 // Error is returned by a wasm.Engine during the execution of Wasm functions, and they indicate that the Wasm runtime
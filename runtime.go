@@ -8,6 +8,7 @@ import (
 	"github.com/tetratelabs/wazero/api"
 	experimentalapi "github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/engine/interpreter"
+	"github.com/tetratelabs/wazero/internal/engine/tiered"
 	"github.com/tetratelabs/wazero/internal/engine/wazevo"
 	"github.com/tetratelabs/wazero/internal/expctxkeys"
 	"github.com/tetratelabs/wazero/internal/platform"
@@ -161,9 +162,12 @@ func NewRuntimeWithConfig(ctx context.Context, rConfig RuntimeConfig) Runtime {
 		}
 	}
 	if configEngine == nil {
-		if configKind == engineKindCompiler {
+		switch configKind {
+		case engineKindCompiler:
 			configEngine = wazevo.NewEngine
-		} else {
+		case engineKindTiered:
+			configEngine = tiered.NewEngine
+		default:
 			configEngine = interpreter.NewEngine
 		}
 	}
@@ -178,6 +182,8 @@ func NewRuntimeWithConfig(ctx context.Context, rConfig RuntimeConfig) Runtime {
 		engine = configEngine(ctx, config.enabledFeatures, nil)
 	}
 	store := wasm.NewStore(config.enabledFeatures, engine)
+	store.MemoryGuardPages = config.memoryGuardPages && configKind == engineKindCompiler &&
+		platform.MemoryGuardPagesSupported() && wazevo.VerifyMemoryGuardPages() == nil
 	return &runtime{
 		cache:                 cacheImpl,
 		store:                 store,
@@ -187,6 +193,7 @@ func NewRuntimeWithConfig(ctx context.Context, rConfig RuntimeConfig) Runtime {
 		dwarfDisabled:         config.dwarfDisabled,
 		storeCustomSections:   config.storeCustomSections,
 		ensureTermination:     config.ensureTermination,
+		fuelMetering:          config.fuelMetering,
 	}
 }
 
@@ -209,6 +216,7 @@ type runtime struct {
 	closed atomic.Uint64
 
 	ensureTermination bool
+	fuelMetering      bool
 }
 
 // Module implements Runtime.Module.
@@ -258,8 +266,9 @@ func (r *runtime) CompileModule(ctx context.Context, binary []byte) (CompiledMod
 	if err != nil {
 		return nil, err
 	}
-	internal.AssignModuleID(binary, listeners, r.ensureTermination)
-	if err = r.store.Engine.CompileModule(ctx, internal, listeners, r.ensureTermination); err != nil {
+	internal.AssignModuleID(binary, listeners, r.ensureTermination, r.fuelMetering, r.store.MemoryGuardPages,
+		experimentalapi.GetInliningBudget(ctx))
+	if err = r.store.Engine.CompileModule(ctx, internal, listeners, r.ensureTermination, r.fuelMetering, r.store.MemoryGuardPages); err != nil {
 		return nil, err
 	}
 	return c, nil
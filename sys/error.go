@@ -6,17 +6,20 @@ import (
 	"fmt"
 )
 
-// These two special exit codes are reserved by wazero for context Cancel and Timeout integrations.
-// The assumption here is that well-behaving Wasm programs won't use these two exit codes.
+// These special exit codes are reserved by wazero for context Cancel and Timeout integrations, and fuel metering.
+// The assumption here is that well-behaving Wasm programs won't use these exit codes.
 const (
 	// ExitCodeContextCanceled corresponds to context.Canceled and returned by ExitError.ExitCode in that case.
 	ExitCodeContextCanceled uint32 = 0xffffffff
 	// ExitCodeDeadlineExceeded corresponds to context.DeadlineExceeded and returned by ExitError.ExitCode in that case.
 	ExitCodeDeadlineExceeded uint32 = 0xefffffff
+	// ExitCodeFuelExhausted is returned by ExitError.ExitCode when the fuel budget of an api.Function call is
+	// exhausted. See RuntimeConfig.WithFuelMetering in the wazero package.
+	ExitCodeFuelExhausted uint32 = 0xdfffffff
 )
 
 // ExitError is returned to a caller of api.Function when api.Module CloseWithExitCode was invoked,
-// or context.Context passed to api.Function Call was canceled or reached the Timeout.
+// context.Context passed to api.Function Call was canceled or reached the Timeout, or the fuel was exhausted.
 //
 // ExitCode zero value means success while any other value is an error.
 //
@@ -63,6 +66,8 @@ func (e *ExitError) Error() string {
 		return fmt.Sprintf("module closed with %s", context.Canceled)
 	case ExitCodeDeadlineExceeded:
 		return fmt.Sprintf("module closed with %s", context.DeadlineExceeded)
+	case ExitCodeFuelExhausted:
+		return "module closed with fuel exhausted"
 	default:
 		return fmt.Sprintf("module closed with exit_code(%d)", e.exitCode)
 	}
@@ -65,6 +65,11 @@ func TestExitError_Error(t *testing.T) {
 		require.EqualError(t, err, "module closed with context canceled")
 		require.ErrorIs(t, err, context.Canceled, "exit code context canceled should work")
 	})
+	t.Run("fuel exhausted", func(t *testing.T) {
+		err := sys.NewExitError(sys.ExitCodeFuelExhausted)
+		require.Equal(t, sys.ExitCodeFuelExhausted, err.ExitCode())
+		require.EqualError(t, err, "module closed with fuel exhausted")
+	})
 	t.Run("normal", func(t *testing.T) {
 		err := sys.NewExitError(123)
 		require.Equal(t, uint32(123), err.ExitCode())